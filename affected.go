@@ -0,0 +1,143 @@
+package matchspec
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/greynewell/mist-go/protocol"
+)
+
+func validateSources(suite, owner string, patterns []string) error {
+	for _, p := range patterns {
+		for _, seg := range strings.Split(strings.TrimSuffix(p, "/"), "/") {
+			if _, err := path.Match(seg, ""); err != nil {
+				return fmt.Errorf("matchspec: suite %q %s source %q: %w", suite, owner, p, err)
+			}
+		}
+	}
+	return nil
+}
+
+// matchSource reports whether file matches the source pattern. Patterns
+// are slash-separated paths relative to the repository root. Within a
+// segment, path.Match syntax applies; a "**" segment matches any number of
+// segments, and a trailing slash matches everything below a directory.
+func matchSource(pattern, file string) bool {
+	if dir, ok := strings.CutSuffix(pattern, "/"); ok {
+		pattern = dir + "/**"
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(path.Clean(file), "/"))
+}
+
+func matchSegments(pattern, file []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(file); i++ {
+				if matchSegments(pattern[1:], file[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(file) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], file[0]); !ok {
+			return false
+		}
+		pattern, file = pattern[1:], file[1:]
+	}
+	return len(file) == 0
+}
+
+func matchAnySource(patterns, changed []string) bool {
+	for _, p := range patterns {
+		for _, f := range changed {
+			if matchSource(p, f) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Affected returns the names of the tasks affected by the changed files: a
+// task is affected if a change matches one of its Sources or the
+// TagSources of one of its tags. If a change matches the suite's own
+// Sources, every task is affected and all is true. Tasks without any
+// source mapping are never affected by a change.
+func (s *Suite) Affected(changed []string) (tasks []string, all bool) {
+	if matchAnySource(s.Sources, changed) {
+		return nil, true
+	}
+	hitTags := make(map[string]bool)
+	for tag, patterns := range s.TagSources {
+		if matchAnySource(patterns, changed) {
+			hitTags[tag] = true
+		}
+	}
+	for _, t := range s.Tasks {
+		if matchAnySource(t.Sources, changed) || slices.ContainsFunc(t.Tags, func(tag string) bool { return hitTags[tag] }) {
+			tasks = append(tasks, t.Name)
+		}
+	}
+	return tasks, false
+}
+
+// AffectedRuns returns one run per registered suite affected by the
+// changed files, in suite name order. A run whose suite is affected as a
+// whole has no task filter.
+func (r *SuiteRegistry) AffectedRuns(changed []string) []protocol.EvalRun {
+	names := r.Names()
+	slices.Sort(names)
+	var runs []protocol.EvalRun
+	for _, name := range names {
+		tasks, all := r.suites[name].Affected(changed)
+		if all || len(tasks) > 0 {
+			runs = append(runs, protocol.EvalRun{Suite: name, Tasks: tasks})
+		}
+	}
+	return runs
+}
+
+// ReadChangedFiles reads the files touched by a change from r. It accepts
+// either a list of paths, one per line, as printed by
+// `git diff --name-only`, or a unified diff as printed by `git diff`, in
+// which case both sides of each file header are taken.
+func ReadChangedFiles(r io.Reader) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	add := func(f string) {
+		if f != "" && f != "/dev/null" && !seen[f] {
+			seen[f] = true
+			files = append(files, f)
+		}
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), maxTaskLineBytes)
+	unified := false
+	for sc.Scan() {
+		line := sc.Text()
+		if rest, ok := strings.CutPrefix(line, "diff --git "); ok {
+			unified = true
+			if a, b, ok := strings.Cut(rest, " b/"); ok {
+				add(strings.TrimPrefix(a, "a/"))
+				add(b)
+			}
+			continue
+		}
+		if unified {
+			continue
+		}
+		add(strings.TrimSpace(line))
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("matchspec: read changed files: %w", err)
+	}
+	return files, nil
+}
@@ -0,0 +1,93 @@
+package matchspec
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchSource(t *testing.T) {
+	cases := []struct {
+		pattern, file string
+		want          bool
+	}{
+		{"prompts/math.tmpl", "prompts/math.tmpl", true},
+		{"prompts/*.tmpl", "prompts/math.tmpl", true},
+		{"prompts/*.tmpl", "prompts/sub/math.tmpl", false},
+		{"prompts/", "prompts/sub/math.tmpl", true},
+		{"prompts/", "promptsx/a", false},
+		{"**/*.go", "a/b/c.go", true},
+		{"**/*.go", "c.go", true},
+		{"src/**/retrieval.go", "src/retrieval.go", true},
+		{"src/**/retrieval.go", "src/a/b/retrieval.go", true},
+		{"src/**/retrieval.go", "lib/retrieval.go", false},
+	}
+	for _, c := range cases {
+		if got := matchSource(c.pattern, c.file); got != c.want {
+			t.Errorf("matchSource(%q, %q) = %v, want %v", c.pattern, c.file, got, c.want)
+		}
+	}
+}
+
+func TestSuiteAffected(t *testing.T) {
+	s := &Suite{
+		Name:       "rag",
+		Sources:    []string{"matchspec.yaml"},
+		TagSources: map[string][]string{"retrieval": {"src/retriever/"}},
+		Tasks: []Task{
+			{Name: "summarize", Prompt: "p", Expected: "x", Sources: []string{"prompts/summarize.tmpl"}},
+			{Name: "lookup", Prompt: "p", Expected: "x", Tags: []string{"retrieval"}},
+			{Name: "unmapped", Prompt: "p", Expected: "x"},
+		},
+	}
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	tasks, all := s.Affected([]string{"src/retriever/index.go", "README.md"})
+	if all || strings.Join(tasks, ",") != "lookup" {
+		t.Errorf("affected = %v, %v", tasks, all)
+	}
+	tasks, all = s.Affected([]string{"prompts/summarize.tmpl", "src/retriever/index.go"})
+	if all || strings.Join(tasks, ",") != "summarize,lookup" {
+		t.Errorf("affected = %v, %v", tasks, all)
+	}
+	if _, all = s.Affected([]string{"matchspec.yaml"}); !all {
+		t.Error("a change to a suite source should affect the whole suite")
+	}
+
+	reg := NewSuiteRegistry()
+	reg.Register(s)
+	reg.Register(&Suite{Name: "other", Tasks: []Task{{Name: "a", Prompt: "p", Sources: []string{"other/"}}}})
+	runs := reg.AffectedRuns([]string{"prompts/summarize.tmpl"})
+	if len(runs) != 1 || runs[0].Suite != "rag" || strings.Join(runs[0].Tasks, ",") != "summarize" {
+		t.Errorf("runs = %+v", runs)
+	}
+
+	bad := &Suite{Name: "bad", Tasks: []Task{{Name: "a", Prompt: "p", Sources: []string{"src/[/x"}}}}
+	if err := bad.Validate(); err == nil {
+		t.Error("expected error for malformed source pattern")
+	}
+}
+
+func TestReadChangedFiles(t *testing.T) {
+	files, err := ReadChangedFiles(strings.NewReader("a.go\n\nb/c.go\na.go\n"))
+	if err != nil || strings.Join(files, ",") != "a.go,b/c.go" {
+		t.Errorf("name-only = %v, %v", files, err)
+	}
+
+	diff := `diff --git a/old.go b/new.go
+similarity index 90%
+rename from old.go
+rename to new.go
+diff --git a/prompts/x.tmpl b/prompts/x.tmpl
+--- a/prompts/x.tmpl
++++ b/prompts/x.tmpl
+@@ -1 +1 @@
+-old line.go
++new line.go
+`
+	files, err = ReadChangedFiles(strings.NewReader(diff))
+	if err != nil || strings.Join(files, ",") != "old.go,new.go,prompts/x.tmpl" {
+		t.Errorf("unified = %v, %v", files, err)
+	}
+}
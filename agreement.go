@@ -0,0 +1,109 @@
+package matchspec
+
+import (
+	"context"
+	"time"
+)
+
+// MatcherVerdict is one matcher's judgment of a response.
+type MatcherVerdict struct {
+	Matcher string  `json:"matcher"`
+	Passed  bool    `json:"passed"`
+	Score   float64 `json:"score"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// MatcherAgreement reports how often two matchers reached different
+// verdicts on the same responses.
+type MatcherAgreement struct {
+	A             string  `json:"a"`
+	B             string  `json:"b"`
+	Compared      int     `json:"compared"`
+	Disagreements int     `json:"disagreements"`
+	Rate          float64 `json:"disagreement_rate"`
+}
+
+// verdicts runs the task's primary matcher and each of its extra Matchers
+// on response. The primary verdict is passed in so it is not recomputed.
+// Errors from extra matchers are recorded on their verdicts. It also
+// returns the time the extra matchers took.
+func (r *Runner) verdicts(ctx context.Context, task *Task, response string, primary MatcherVerdict) ([]MatcherVerdict, time.Duration) {
+	out := []MatcherVerdict{primary}
+	var total time.Duration
+	seen := map[string]bool{primary.Matcher: true}
+	for _, m := range task.Matchers {
+		if seen[m] {
+			continue
+		}
+		seen[m] = true
+		t := *task
+		t.Matcher = m
+		v := MatcherVerdict{Matcher: m}
+		var err error
+		var d time.Duration
+		v.Passed, v.Score, d, err = r.tracedMatch(ctx, &t, response)
+		total += d
+		if err != nil {
+			v.Error = r.redact(err.Error())
+		}
+		out = append(out, v)
+	}
+	return out, total
+}
+
+// CompareMatchers computes pairwise disagreement rates between matchers
+// over results that carry verdicts. Verdicts with errors are skipped. Pairs
+// are listed in order of first appearance. It returns nil if no result
+// has more than one verdict.
+func CompareMatchers(results []Result) []MatcherAgreement {
+	var c agreementCounter
+	for _, r := range results {
+		c.add(r)
+	}
+	return c.stats()
+}
+
+type matcherPair struct{ a, b string }
+
+// agreementCounter accumulates CompareMatchers one result at a time.
+type agreementCounter struct {
+	order []matcherPair
+	pairs map[matcherPair]*MatcherAgreement
+}
+
+func (c *agreementCounter) add(res Result) {
+	for i, va := range res.Verdicts {
+		for _, vb := range res.Verdicts[i+1:] {
+			if va.Error != "" || vb.Error != "" {
+				continue
+			}
+			k := matcherPair{va.Matcher, vb.Matcher}
+			s, ok := c.pairs[k]
+			if !ok {
+				if c.pairs == nil {
+					c.pairs = make(map[matcherPair]*MatcherAgreement)
+				}
+				s = &MatcherAgreement{A: k.a, B: k.b}
+				c.pairs[k] = s
+				c.order = append(c.order, k)
+			}
+			s.Compared++
+			if va.Passed != vb.Passed {
+				s.Disagreements++
+			}
+		}
+	}
+}
+
+func (c *agreementCounter) stats() []MatcherAgreement {
+	if len(c.order) == 0 {
+		return nil
+	}
+	out := make([]MatcherAgreement, len(c.order))
+	for i, k := range c.order {
+		s := *c.pairs[k]
+		s.Rate = float64(s.Disagreements) / float64(s.Compared)
+		out[i] = s
+	}
+	return out
+}
@@ -0,0 +1,69 @@
+package matchspec
+
+import (
+	"context"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestRunRecordsVerdicts(t *testing.T) {
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{
+		Name: "m",
+		Tasks: []Task{
+			{Name: "a", Prompt: "1+1", Expected: "echo", Matcher: "prefix", Matchers: []string{"exact", "grounded"}},
+			{Name: "b", Prompt: "1+1", Expected: "echo"},
+		},
+	})
+	r := NewRunner(reg, echoInfer, tokentrace.NewReporter("matchspec", ""))
+	results, err := r.RunDetailed(context.Background(), protocol.EvalRun{Suite: "m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := results[0]
+	if !a.Passed || len(a.Verdicts) != 3 {
+		t.Fatalf("a = %+v", a)
+	}
+	if v := a.Verdicts[0]; v.Matcher != "prefix" || !v.Passed {
+		t.Errorf("primary verdict = %+v", v)
+	}
+	if v := a.Verdicts[1]; v.Matcher != "exact" || v.Passed {
+		t.Errorf("exact verdict = %+v", v)
+	}
+	// No judge is configured, so grounded errors without failing the task.
+	if v := a.Verdicts[2]; v.Matcher != "grounded" || v.Error == "" {
+		t.Errorf("grounded verdict = %+v", v)
+	}
+	if results[1].Verdicts != nil {
+		t.Errorf("task without Matchers has verdicts: %+v", results[1].Verdicts)
+	}
+}
+
+func TestCompareMatchers(t *testing.T) {
+	v := func(m string, passed bool) MatcherVerdict { return MatcherVerdict{Matcher: m, Passed: passed} }
+	results := []Result{
+		{Verdicts: []MatcherVerdict{v("exact", true), v("grounded", true)}},
+		{Verdicts: []MatcherVerdict{v("exact", false), v("grounded", true)}},
+		{Verdicts: []MatcherVerdict{v("exact", false), v("grounded", false)}},
+		{Verdicts: []MatcherVerdict{v("exact", false), {Matcher: "grounded", Error: "judge down"}}},
+		{},
+	}
+	agreement := CompareMatchers(results)
+	if len(agreement) != 1 {
+		t.Fatalf("agreement = %+v", agreement)
+	}
+	a := agreement[0]
+	if a.A != "exact" || a.B != "grounded" || a.Compared != 3 || a.Disagreements != 1 {
+		t.Errorf("agreement = %+v", a)
+	}
+	if a.Rate != 1.0/3 {
+		t.Errorf("rate = %f", a.Rate)
+	}
+
+	if CompareMatchers([]Result{{}}) != nil {
+		t.Error("expected nil without verdicts")
+	}
+}
@@ -0,0 +1,126 @@
+package matchspec
+
+import (
+	"context"
+	"fmt"
+)
+
+// Assertion modes combine a task's Assertions.
+const (
+	AssertAll = "all"
+	AssertAny = "any"
+)
+
+// Assertion is one check of a task's response, with its own matcher and
+// expected value. Threshold, Tolerance, and JSONPath, if set, override
+// the task's for this assertion; the task's other matcher settings, such
+// as Captures or Documents, apply to every assertion.
+type Assertion struct {
+	Matcher  string `json:"matcher"`
+	Expected string `json:"expected"`
+
+	// Weight is the assertion's share of the task score, relative to the
+	// other assertions (default 1).
+	Weight float64 `json:"weight,omitempty"`
+
+	// Negate inverts the assertion, as Task.Negate does.
+	Negate bool `json:"negate,omitempty"`
+
+	Threshold float64 `json:"threshold,omitempty"`
+	Tolerance float64 `json:"tolerance,omitempty"`
+	JSONPath  string  `json:"jsonpath,omitempty"`
+}
+
+// task returns t with the assertion's matcher and settings in place of
+// its own.
+func (a *Assertion) task(t Task) Task {
+	t.Matcher, t.Expected, t.ExpectedAny, t.Negate, t.Assertions = a.Matcher, a.Expected, nil, a.Negate, nil
+	if a.Threshold != 0 {
+		t.Threshold = a.Threshold
+	}
+	if a.Tolerance != 0 {
+		t.Tolerance = a.Tolerance
+	}
+	if a.JSONPath != "" {
+		t.JSONPath = a.JSONPath
+	}
+	return t
+}
+
+func (a *Assertion) weight() float64 {
+	if a.Weight == 0 {
+		return 1
+	}
+	return a.Weight
+}
+
+// combineAssertions grades the response with each of the task's
+// Assertions using match. The score is the weighted mean of their scores.
+// The task passes if every assertion passes, or with AssertionMode "any",
+// if one does.
+func combineAssertions(t *Task, response string, match func(t *Task, response string) (bool, float64, error)) (bool, float64, error) {
+	var passed int
+	var score, total float64
+	for i := range t.Assertions {
+		a := &t.Assertions[i]
+		at := a.task(*t)
+		ok, s, err := match(&at, response)
+		if err != nil {
+			return false, 0.0, fmt.Errorf("assertion %d (%s): %w", i, a.Matcher, err)
+		}
+		if ok {
+			passed++
+		}
+		score += a.weight() * s
+		total += a.weight()
+	}
+	if total > 0 {
+		score /= total
+	}
+	if t.AssertionMode == AssertAny {
+		return passed > 0, score, nil
+	}
+	return passed == len(t.Assertions), score, nil
+}
+
+// matchAssertions is combineAssertions with the runner's matchers.
+// Details of the assertions are listed under "assertions".
+func (r *Runner) matchAssertions(ctx context.Context, t *Task, response string) (bool, float64, error) {
+	match, done := listDetails(ctx, "assertions", r.match)
+	defer done()
+	return combineAssertions(t, response, match)
+}
+
+// validateAssertions checks the task's assertion mode and weights, and
+// each assertion as its own task.
+func validateAssertions(suite string, t Task) error {
+	switch t.AssertionMode {
+	case "", AssertAll, AssertAny:
+	default:
+		return fmt.Errorf("matchspec: suite %q task %q has unknown assertion_mode %q (want %q or %q)", suite, t.Name, t.AssertionMode, AssertAll, AssertAny)
+	}
+	for i := range t.Assertions {
+		a := &t.Assertions[i]
+		if a.Weight < 0 {
+			return fmt.Errorf("matchspec: suite %q task %q assertion %d has negative weight", suite, t.Name, i)
+		}
+		if err := validateMatcher(suite, a.task(t)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// usesMatcher reports whether the task is graded by the named matcher,
+// directly or in one of its assertions.
+func usesMatcher(t *Task, name string) bool {
+	if len(t.Assertions) == 0 {
+		return t.Matcher == name
+	}
+	for _, a := range t.Assertions {
+		if a.Matcher == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package matchspec
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestTaskMatchAssertions(t *testing.T) {
+	task := Task{Assertions: []Assertion{
+		{Matcher: "contains", Expected: "Paris", Weight: 2},
+		{Matcher: "jsonpath", JSONPath: "$.population", Expected: "2100000", Weight: 1},
+		{Matcher: "regex", Expected: `(?i)capital`, Weight: 1},
+	}}
+	tests := []struct {
+		response  string
+		mode      string
+		wantPass  bool
+		wantScore float64
+	}{
+		{`{"city": "Paris", "note": "Capital", "population": 2100000}`, "", true, 1},
+		{`{"city": "Paris", "population": 2100000}`, AssertAll, false, 0.75},
+		{`{"city": "Paris", "population": 2100000}`, AssertAny, true, 0.75},
+		{`{"city": "Lyon"}`, AssertAny, false, 0},
+	}
+	for _, tt := range tests {
+		task.AssertionMode = tt.mode
+		passed, score := task.Match(tt.response)
+		if passed != tt.wantPass || math.Abs(score-tt.wantScore) > 1e-9 {
+			t.Errorf("Match(%q, %q) = %v, %f; want %v, %f", tt.response, tt.mode, passed, score, tt.wantPass, tt.wantScore)
+		}
+	}
+}
+
+func TestRunnerAssertions(t *testing.T) {
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "s", Vars: map[string]any{"who": "world"}, Tasks: []Task{{
+		Name: "greet", Prompt: "hello {{.who}}",
+		Assertions: []Assertion{
+			{Matcher: "prefix", Expected: "echo:"},
+			{Matcher: "contains", Expected: "{{.who}}"},
+			{Matcher: "semantic", Expected: "hello world", Threshold: 0.9},
+		},
+	}}})
+	embed := func(ctx context.Context, text string) ([]float64, error) {
+		return []float64{1, float64(len(strings.Fields(text)))}, nil
+	}
+	runner := NewRunner(reg, echoInfer, tokentrace.NewReporter("matchspec", ""), WithEmbedder(embed))
+	results, err := runner.Run(context.Background(), protocol.EvalRun{Suite: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := results[0]; !r.Passed || r.Error != "" {
+		t.Errorf("result = %+v", r)
+	}
+
+	// Without an embedder, the semantic assertion errors the task.
+	runner = NewRunner(reg, echoInfer, tokentrace.NewReporter("matchspec", ""))
+	results, _ = runner.Run(context.Background(), protocol.EvalRun{Suite: "s"})
+	if r := results[0]; r.Passed || !strings.Contains(r.Error, "assertion 2 (semantic)") {
+		t.Errorf("result without embedder = %+v", r)
+	}
+}
+
+func TestValidateAssertions(t *testing.T) {
+	bad := []Task{
+		{Name: "t", Prompt: "p", AssertionMode: "most", Assertions: []Assertion{{Matcher: "contains", Expected: "x"}}},
+		{Name: "t", Prompt: "p", Assertions: []Assertion{{Matcher: "contains", Expected: "x", Weight: -1}}},
+		{Name: "t", Prompt: "p", Assertions: []Assertion{{Matcher: "regex", Expected: "("}}},
+		{Name: "t", Prompt: "p", Assertions: []Assertion{{Matcher: "numeric", Expected: "many"}}},
+	}
+	for _, task := range bad {
+		if err := (&Suite{Name: "s", Tasks: []Task{task}}).Validate(); err == nil {
+			t.Errorf("task %+v: expected validation error", task)
+		}
+	}
+}
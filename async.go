@@ -0,0 +1,250 @@
+package matchspec
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/trace"
+)
+
+// Run states reported by RunStatus.
+const (
+	RunPending = "pending"
+	RunRunning = "running"
+	RunDone    = "done"
+)
+
+// RunStatus is the state of a run started with Start, or of any run the
+// runner knows.
+type RunStatus struct {
+	ID     string `json:"id"`
+	Suite  string `json:"suite"`
+	Status string `json:"status"`
+
+	// Progress counts completed, passed, and failed tasks while the run is
+	// running and once it is done.
+	Progress *Progress `json:"progress,omitempty"`
+
+	// Record and Results are set once the run is done. Results is empty
+	// for runs that did not retain them, such as streamed runs.
+	Record  *RunRecord `json:"record,omitempty"`
+	Results []Result   `json:"results,omitempty"`
+
+	// QueuePosition is the run's 1-based place among the runs waiting for
+	// a slot under WithRunLimit while it is pending, and 0 otherwise.
+	QueuePosition int `json:"queue_position,omitempty"`
+
+	// Error is why the run stopped early or never started.
+	Error string `json:"error,omitempty"`
+}
+
+// pendingRun is a run accepted by Start that has not begun executing
+// tasks.
+type pendingRun struct {
+	suite     string
+	submitted time.Time
+	ticket    *gateTicket // its place under WithRunLimit, if any
+}
+
+// failedStart is a run accepted by Start that failed before it began.
+type failedStart struct {
+	id, suite string
+	err       error
+}
+
+// maxFailedStarts is how many failedStarts a runner keeps for Status,
+// dropping the oldest beyond it.
+const maxFailedStarts = 1000
+
+// startPollInterval is how often Watch checks the start queue for a run
+// executing on another runner.
+const startPollInterval = time.Second
+
+// WithStartQueue makes Start submit runs to q instead of executing them in
+// the background, so accepted runs survive a restart. Each becomes a job
+// whose ID is the run's, and a worker running ProcessJobs on q executes it
+// under that ID with the run labels and repeats it was submitted with;
+// other values of Start's ctx are not kept. Status and Watch follow runs
+// that are queued or executing elsewhere through q.
+func WithStartQueue(q JobQueue) RunnerOption {
+	return func(r *Runner) { r.startQueue = q }
+}
+
+type runIDKey struct{}
+
+// withRunID returns a context whose next run is recorded under id.
+func withRunID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, runIDKey{}, id)
+}
+
+// Start begins run in the background and returns its ID at once, for
+// callers such as HTTP clients that cannot wait for a whole suite. Poll
+// the run with RunManager.Status and stop it with RunManager.Cancel. The
+// run is not bound to ctx's cancellation, only to its values, such as
+// run labels and inference options. Under WithRunLimit, the run takes its
+// place in the queue now, and Start fails with a QueueFullError if there
+// is none. With WithStartQueue, the run is submitted to the job queue
+// instead.
+func (r *Runner) Start(ctx context.Context, run protocol.EvalRun) (string, error) {
+	if _, ok := r.registry.Get(run.Suite); !ok {
+		return "", fmt.Errorf("matchspec: unknown suite %q", run.Suite)
+	}
+	if r.startQueue != nil {
+		return r.submit(ctx, run)
+	}
+	var ticket *gateTicket
+	if r.gate != nil {
+		var err error
+		if ticket, err = r.reserveRun(false); err != nil {
+			return "", err
+		}
+	}
+	id := trace.NewID()
+	r.mu.Lock()
+	if r.pending == nil {
+		r.pending = make(map[string]*pendingRun)
+	}
+	r.pending[id] = &pendingRun{suite: run.Suite, submitted: time.Now(), ticket: ticket}
+	r.mu.Unlock()
+	noteAuditRun(ctx, id)
+
+	ctx = withRunID(context.WithoutCancel(ctx), id)
+	if ticket != nil {
+		ctx = context.WithValue(ctx, gateTicketKey{}, ticket)
+	}
+	go func() {
+		_, recorded, err := r.run(ctx, run)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		// A run that began executing tasks left pending then.
+		p, ok := r.pending[id]
+		if !ok {
+			return
+		}
+		delete(r.pending, id)
+		if recorded == "" {
+			r.failedStarts = append(r.failedStarts, failedStart{id: id, suite: p.suite, err: err})
+			if n := len(r.failedStarts) - maxFailedStarts; n > 0 {
+				r.failedStarts = slices.Delete(r.failedStarts, 0, n)
+			}
+		}
+		r.broadcast()
+	}()
+	return id, nil
+}
+
+// submit enqueues run in the start queue as a job under a new run ID.
+func (r *Runner) submit(ctx context.Context, run protocol.EvalRun) (string, error) {
+	id := trace.NewID()
+	job := Job{ID: id, RunID: id, Run: run, RunLabels: RunLabelsFrom(ctx)}
+	if n := RunRepeatsFrom(ctx); n > 1 {
+		job.Repeats = n
+	}
+	chargeJob(ctx, &job)
+	if _, err := r.startQueue.Enqueue(ctx, job); err != nil {
+		return "", err
+	}
+	noteAuditRun(ctx, id)
+	return id, nil
+}
+
+// failedStart returns the failure of the run with the given ID, if it
+// failed before it began. The caller holds r.mu.
+func (r *Runner) failedStart(id string) (failedStart, bool) {
+	i := slices.IndexFunc(r.failedStarts, func(f failedStart) bool { return f.id == id })
+	if i < 0 {
+		return failedStart{}, false
+	}
+	return r.failedStarts[i], true
+}
+
+// Status reports the state of the run with the given ID: pending until
+// its tasks start, running with its progress, then done with its record
+// and results. A run that failed before starting is done with only an
+// Error. Soft-deleted runs are not found. Runs submitted to a start queue
+// (see WithStartQueue) that this runner does not hold are reported from
+// their job: queued runs are pending, and runs executing or finished
+// elsewhere have no progress or record until it reaches this runner.
+func (m *RunManager) Status(id string) (RunStatus, bool) {
+	if st, ok := m.status(id); ok {
+		return st, true
+	}
+	return m.queuedStatus(context.Background(), id)
+}
+
+// status reports the state of a run this runner holds.
+func (m *RunManager) status(id string) (RunStatus, bool) {
+	r := m.r
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p, ok := r.pending[id]; ok {
+		st := RunStatus{ID: id, Suite: p.suite, Status: RunPending}
+		if p.ticket != nil {
+			st.QueuePosition = r.gate.position(p.ticket)
+		}
+		return st, true
+	}
+	if f, ok := r.failedStart(id); ok {
+		return RunStatus{ID: id, Suite: f.suite, Status: RunDone, Error: r.redact(f.err.Error())}, true
+	}
+	if live, ok := r.active[id]; ok {
+		p := live.progress
+		return RunStatus{ID: id, Suite: live.rec.Suite, Status: RunRunning, Progress: &p}, true
+	}
+	for i := range r.runs {
+		rec := r.runs[i]
+		if rec.ID != id || rec.deleted() {
+			continue
+		}
+		p := Progress{
+			RunID:     rec.ID,
+			Suite:     rec.Suite,
+			Completed: rec.Summary.Total,
+			Total:     rec.Summary.Total,
+			Passed:    rec.Summary.Passed,
+			Failed:    rec.Summary.Failed,
+			Elapsed:   rec.FinishedAt.Sub(rec.StartedAt),
+		}
+		return RunStatus{
+			ID:       id,
+			Suite:    rec.Suite,
+			Status:   RunDone,
+			Progress: &p,
+			Record:   &rec,
+			Results:  slices.Clone(r.results[rec.first : rec.first+rec.count]),
+			Error:    rec.Error,
+		}, true
+	}
+	return RunStatus{}, false
+}
+
+// queuedStatus reports the state of a run submitted to the start queue
+// from its job.
+func (m *RunManager) queuedStatus(ctx context.Context, id string) (RunStatus, bool) {
+	q := m.r.startQueue
+	if q == nil {
+		return RunStatus{}, false
+	}
+	job, err := q.Get(ctx, id)
+	if err != nil || job.RunID != id {
+		return RunStatus{}, false
+	}
+	st := RunStatus{ID: id, Suite: job.Run.Suite}
+	switch job.State {
+	case JobQueued:
+		st.Status = RunPending
+		if jobs, err := q.List(ctx); err == nil {
+			st.QueuePosition = jobPosition(jobs, id)
+		}
+	case JobRunning:
+		st.Status = RunRunning
+	case JobCancelled:
+		st.Status, st.Error = RunDone, "matchspec: job cancelled before it ran"
+	default:
+		st.Status, st.Error = RunDone, m.r.redact(job.Error)
+	}
+	return st, true
+}
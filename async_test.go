@@ -0,0 +1,165 @@
+package matchspec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func getStatus(t *testing.T, h *Handler, id string) (int, RunStatus) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/runs/"+id, nil)
+	req.SetPathValue("id", id)
+	h.GetRun(w, req)
+	var st RunStatus
+	if w.Code == http.StatusOK {
+		if err := json.NewDecoder(w.Body).Decode(&st); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w.Code, st
+}
+
+func TestAsyncEval(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	infer := func(ctx context.Context, prompt string) (string, error) {
+		if prompt == "What is 3*4?" {
+			started <- struct{}{}
+			<-release
+			return "12", nil
+		}
+		return "4", nil
+	}
+	reg := driftRegistry()
+	runner := NewRunner(reg, infer, tokentrace.NewReporter("matchspec", ""))
+	h := NewHandler(runner, reg)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/eval?label=nightly", strings.NewReader(`{"suite":"math"}`))
+	req.Header.Set("Prefer", "wait=5, respond-async")
+	h.RunDirect(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var accepted RunStatus
+	if err := json.NewDecoder(w.Body).Decode(&accepted); err != nil {
+		t.Fatal(err)
+	}
+	if accepted.ID == "" || w.Header().Get("Location") != "/runs/"+accepted.ID {
+		t.Fatalf("accepted = %+v, Location %q", accepted, w.Header().Get("Location"))
+	}
+
+	<-started
+	code, st := getStatus(t, h, accepted.ID)
+	if code != http.StatusOK || st.Status != RunRunning || st.Progress == nil || st.Progress.Completed != 1 || st.Progress.Total != 2 {
+		t.Errorf("running status = %d %+v", code, st)
+	}
+	close(release)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for st.Status != RunDone && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		_, st = getStatus(t, h, accepted.ID)
+	}
+	if st.Status != RunDone || st.Record == nil || len(st.Results) != 2 || st.Progress.Passed != 2 {
+		t.Fatalf("done status = %+v", st)
+	}
+	if st.Record.ID != accepted.ID || len(st.Record.Labels) != 1 || st.Record.Labels[0] != "nightly" {
+		t.Errorf("record = %+v", st.Record)
+	}
+
+	if code, _ := getStatus(t, h, "missing"); code != http.StatusNotFound {
+		t.Errorf("unknown run = %d", code)
+	}
+	w = httptest.NewRecorder()
+	h.RunDirect(w, httptest.NewRequest(http.MethodPost, "/eval?async=true", strings.NewReader(`{"suite":"nope"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unknown suite = %d", w.Code)
+	}
+}
+
+func TestStartFailsBeforeRunning(t *testing.T) {
+	reg := driftRegistry()
+	runner := NewRunner(reg, func(context.Context, string) (string, error) { return "", nil },
+		tokentrace.NewReporter("matchspec", ""),
+		WithModelPin(ModelPin{Model: "m"}, func(context.Context) (BackendModel, error) {
+			return BackendModel{}, errors.New("probe down")
+		}))
+	id, err := runner.Start(context.Background(), protocol.EvalRun{Suite: "math"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	st, _ := runner.Manager().Status(id)
+	for st.Status != RunDone && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		st, _ = runner.Manager().Status(id)
+	}
+	if st.Status != RunDone || st.Error == "" || st.Record != nil {
+		t.Errorf("status = %+v", st)
+	}
+	runner.mu.Lock()
+	pending := len(runner.pending)
+	runner.mu.Unlock()
+	if pending != 0 {
+		t.Errorf("%d runs left pending", pending)
+	}
+}
+
+func TestStartQueueSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	q, _ := OpenFileJobQueue(path)
+	reg := driftRegistry()
+	reporter := tokentrace.NewReporter("matchspec", "")
+	first := NewRunner(reg, echoInfer, reporter, WithStartQueue(q))
+
+	id, err := first.Start(WithRunLabels(context.Background(), "nightly"), protocol.EvalRun{Suite: "math"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dropped, _ := first.Start(context.Background(), protocol.EvalRun{Suite: "math"})
+	if st, ok := first.Manager().Status(dropped); !ok || st.Status != RunPending || st.QueuePosition != 2 {
+		t.Errorf("queued status = %+v, %v", st, ok)
+	}
+	if run, err := first.Manager().Cancel(dropped); err != nil || !run.Cancelled {
+		t.Fatalf("cancel = %+v, %v", run, err)
+	}
+	if st, _ := first.Manager().Status(dropped); st.Status != RunDone || st.Error == "" {
+		t.Errorf("cancelled status = %+v", st)
+	}
+
+	// A new runner on the same queue executes the run under its ID.
+	q, _ = OpenFileJobQueue(path)
+	second := NewRunner(reg, echoInfer, reporter, WithStartQueue(q))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- second.ProcessJobs(ctx, q, WorkerConfig{PollInterval: time.Millisecond}) }()
+
+	rec, err := second.Manager().Watch(ctx, id, func(Result) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.ID != id || !slices.Contains(rec.Labels, "nightly") {
+		t.Errorf("record = %+v", rec)
+	}
+	if st, _ := second.Manager().Status(id); st.Status != RunDone || st.Record == nil {
+		t.Errorf("status = %+v", st)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("ProcessJobs = %v", err)
+	}
+}
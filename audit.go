@@ -0,0 +1,420 @@
+package matchspec
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// AuditEntry is one action in an AuditLog.
+type AuditEntry struct {
+	Seq  int64     `json:"seq"`
+	Time time.Time `json:"time"`
+
+	// Actor is who acted (see ActorFunc). Action names what they did: the
+	// API route, such as "POST /eval", or an event such as
+	// "suite.register". Target is the path or object acted on.
+	Actor  string `json:"actor"`
+	Action string `json:"action"`
+	Target string `json:"target,omitempty"`
+
+	// Status is the HTTP status of an API action.
+	Status     int    `json:"status,omitempty"`
+	RemoteAddr string `json:"remote_addr,omitempty"`
+
+	// RunIDs are the runs the action started.
+	RunIDs  []string          `json:"run_ids,omitempty"`
+	Details map[string]string `json:"details,omitempty"`
+
+	// PrevHash is the Hash of the previous entry and Hash the SHA-256 of
+	// this entry with Hash empty, chaining entries so that editing or
+	// removing one is detected by Verify.
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash"`
+}
+
+func (e AuditEntry) digest() string {
+	e.Hash = ""
+	data, _ := json.Marshal(e)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditFilter selects audit entries. Zero fields match everything.
+type AuditFilter struct {
+	Actor  string
+	Action string
+	Target string
+	Since  time.Time
+	Limit  int
+	Offset int
+}
+
+func (f AuditFilter) match(e *AuditEntry) bool {
+	switch {
+	case f.Actor != "" && e.Actor != f.Actor:
+		return false
+	case f.Action != "" && e.Action != f.Action:
+		return false
+	case f.Target != "" && e.Target != f.Target:
+		return false
+	}
+	return f.Since.IsZero() || !e.Time.Before(f.Since)
+}
+
+// AuditLog is an append-only, hash-chained record of who did what, for
+// shared evaluation infrastructure in regulated environments. Entries are
+// appended to a JSON Lines file and never rewritten. It is safe for
+// concurrent use.
+type AuditLog struct {
+	mu      sync.Mutex
+	path    string
+	entries []AuditEntry
+}
+
+// OpenAuditLog opens the audit log in the file at path, creating it if
+// needed. An empty path keeps the log in memory only.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	l := &AuditLog{path: path}
+	if path == "" {
+		return l, nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("matchspec: audit log: %w", err)
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		var e AuditEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("matchspec: audit log %s:%d: %w", path, line, err)
+		}
+		l.entries = append(l.entries, e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("matchspec: audit log: %w", err)
+	}
+	return l, nil
+}
+
+// Append records e, filling in its sequence number, time if unset, and
+// hashes, and returns the stored entry. The entry is written and synced
+// to disk before Append returns.
+func (l *AuditLog) Append(e AuditEntry) (AuditEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e.Seq = int64(len(l.entries)) + 1
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	if n := len(l.entries); n > 0 {
+		e.PrevHash = l.entries[n-1].Hash
+	}
+	e.Hash = e.digest()
+	if l.path != "" {
+		if err := l.write(e); err != nil {
+			return AuditEntry{}, err
+		}
+	}
+	l.entries = append(l.entries, e)
+	return e, nil
+}
+
+// write appends e to the log file. The caller holds l.mu.
+func (l *AuditLog) write(e AuditEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("matchspec: audit log: %w", err)
+	}
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("matchspec: audit log: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("matchspec: audit log: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("matchspec: audit log: %w", err)
+	}
+	return f.Close()
+}
+
+// Entries returns the entries matching f, newest first, along with the
+// total number of matches before paging.
+func (l *AuditLog) Entries(f AuditFilter) ([]AuditEntry, int) {
+	l.mu.Lock()
+	var matched []AuditEntry
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		if f.match(&l.entries[i]) {
+			matched = append(matched, l.entries[i])
+		}
+	}
+	l.mu.Unlock()
+
+	total := len(matched)
+	if f.Offset >= total {
+		return []AuditEntry{}, total
+	}
+	matched = matched[f.Offset:]
+	if f.Limit > 0 && f.Limit < len(matched) {
+		matched = matched[:f.Limit]
+	}
+	return matched, total
+}
+
+// Verify checks the hash chain and returns an error naming the first
+// entry that was altered, removed, or reordered.
+func (l *AuditLog) Verify() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	prev := ""
+	for i, e := range l.entries {
+		if e.Seq != int64(i)+1 || e.PrevHash != prev || e.Hash != e.digest() {
+			return fmt.Errorf("matchspec: audit log: chain broken at entry %d (seq %d)", i+1, e.Seq)
+		}
+		prev = e.Hash
+	}
+	return nil
+}
+
+// Suite audit actions recorded by RecordSuites.
+const (
+	AuditSuiteRegister = "suite.register"
+	AuditSuiteRemove   = "suite.remove"
+)
+
+// RecordSuites records the suites in reg as registered by actor, such as
+// the config file that lists them, comparing each suite's hash with the
+// log's latest entry for it: new and changed suites are recorded as
+// registered, and suites the log has registered but reg no longer holds
+// as removed. Unchanged suites add nothing, so restarting a server with
+// the same config leaves the log as it was.
+func (l *AuditLog) RecordSuites(reg *SuiteRegistry, actor string) error {
+	l.mu.Lock()
+	latest := make(map[string]string)
+	for _, e := range l.entries {
+		switch e.Action {
+		case AuditSuiteRegister:
+			latest[e.Target] = e.Details["hash"]
+		case AuditSuiteRemove:
+			delete(latest, e.Target)
+		}
+	}
+	l.mu.Unlock()
+
+	names := reg.Names()
+	slices.Sort(names)
+	for _, name := range names {
+		s, _ := reg.Get(name)
+		hash := SuiteHash(s)
+		if prev, ok := latest[name]; ok && prev == hash {
+			continue
+		}
+		e := AuditEntry{Actor: actor, Action: AuditSuiteRegister, Target: name, Details: map[string]string{"hash": hash}}
+		if _, err := l.Append(e); err != nil {
+			return err
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(latest)) {
+		if slices.Contains(names, name) {
+			continue
+		}
+		if _, err := l.Append(AuditEntry{Actor: actor, Action: AuditSuiteRemove, Target: name}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ActorFunc identifies who made a request, for the audit log.
+type ActorFunc func(r *http.Request) string
+
+// DefaultActor identifies the caller by the name of the API key that a
+// QuotaLimiter's Middleware, wrapped around the audit middleware,
+// authenticated, and otherwise reports "anonymous".
+func DefaultActor(r *http.Request) string {
+	if name, _, ok := quotaAccount(r.Context()); ok {
+		return name
+	}
+	return "anonymous"
+}
+
+// ProxyActor identifies the caller from the X-Forwarded-User or
+// X-Forwarded-Email header set by an authenticating proxy, and otherwise
+// as DefaultActor does. Any client can set those headers, so only use it
+// behind a proxy that overwrites them.
+func ProxyActor(r *http.Request) string {
+	for _, h := range []string{"X-Forwarded-User", "X-Forwarded-Email"} {
+		if v := r.Header.Get(h); v != "" {
+			return v
+		}
+	}
+	return DefaultActor(r)
+}
+
+// auditScope collects what a request did, such as the runs it started,
+// for its audit entry.
+type auditScope struct {
+	mu      sync.Mutex
+	runIDs  []string
+	details map[string]string
+}
+
+type auditScopeKey struct{}
+
+// noteAuditRun records that the request behind ctx started run id.
+func noteAuditRun(ctx context.Context, id string) {
+	if s, ok := ctx.Value(auditScopeKey{}).(*auditScope); ok {
+		s.mu.Lock()
+		if !slices.Contains(s.runIDs, id) {
+			s.runIDs = append(s.runIDs, id)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// noteAuditDetail adds a detail to the audit entry of the request behind
+// ctx.
+func noteAuditDetail(ctx context.Context, key, value string) {
+	if s, ok := ctx.Value(auditScopeKey{}).(*auditScope); ok {
+		s.mu.Lock()
+		if s.details == nil {
+			s.details = make(map[string]string)
+		}
+		s.details[key] = value
+		s.mu.Unlock()
+	}
+}
+
+// Middleware records every request to next that changes state (any method
+// but GET, HEAD, and OPTIONS) in l once it completes: the actor, route,
+// path, status, and the runs it started. Wrap the mux with it, so routes
+// are known. A failure to write the log is reported on stderr and does
+// not fail the request, which has already been served.
+func (l *AuditLog) Middleware(actor ActorFunc, next http.Handler) http.Handler {
+	if actor == nil {
+		actor = DefaultActor
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		scope := &auditScope{}
+		r = r.WithContext(context.WithValue(r.Context(), auditScopeKey{}, scope))
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		action := r.Pattern
+		if action == "" {
+			action = r.Method + " " + r.URL.Path
+		}
+		scope.mu.Lock()
+		e := AuditEntry{
+			Actor:      actor(r),
+			Action:     action,
+			Target:     r.URL.Path,
+			Status:     sw.status,
+			RemoteAddr: r.RemoteAddr,
+			RunIDs:     slices.Clone(scope.runIDs),
+			Details:    maps.Clone(scope.details),
+		}
+		scope.mu.Unlock()
+		if q := r.URL.RawQuery; q != "" {
+			if e.Details == nil {
+				e.Details = make(map[string]string)
+			}
+			e.Details["query"] = q
+		}
+		if _, err := l.Append(e); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	})
+}
+
+// statusWriter records the status code written through it.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// AuditHandler serves an AuditLog to administrators.
+type AuditHandler struct {
+	log *AuditLog
+}
+
+// NewAuditHandler creates handlers for l.
+func NewAuditHandler(l *AuditLog) *AuditHandler {
+	return &AuditHandler{log: l}
+}
+
+// AuditResponse is the JSON body for GET /admin/audit.
+type AuditResponse struct {
+	Entries []AuditEntry `json:"entries"`
+	Total   int          `json:"total"`
+}
+
+// List handles GET /admin/audit — lists entries newest first. Supports
+// ?actor=, ?action=, ?target=, ?since= (RFC 3339), ?limit= and ?offset=.
+func (h *AuditHandler) List(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := AuditFilter{Actor: q.Get("actor"), Action: q.Get("action"), Target: q.Get("target")}
+	var err error
+	if f.Limit, err = intParam(q.Get("limit")); err != nil {
+		http.Error(w, "invalid limit: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if f.Offset, err = intParam(q.Get("offset")); err != nil {
+		http.Error(w, "invalid offset: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if since := q.Get("since"); since != "" {
+		if f.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			http.Error(w, "invalid since: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	entries, total := h.log.Entries(f)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AuditResponse{Entries: entries, Total: total})
+}
+
+// Verify handles GET /admin/audit/verify — responds 200 if the hash chain
+// is intact and 409 naming the first broken entry if not.
+func (h *AuditHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	if err := h.log.Verify(); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"ok": true})
+}
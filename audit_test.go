@@ -0,0 +1,166 @@
+package matchspec
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestAuditLogPersistAndVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, actor := range []string{"alice", "bob", "alice"} {
+		if _, err := l.Append(AuditEntry{Actor: actor, Action: "POST /eval"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reopened, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	e, _ := reopened.Append(AuditEntry{Actor: "carol", Action: "DELETE /runs/{id}"})
+	if e.Seq != 4 || e.PrevHash == "" {
+		t.Errorf("appended = %+v", e)
+	}
+	entries, total := reopened.Entries(AuditFilter{Actor: "alice", Limit: 1})
+	if total != 2 || len(entries) != 1 || entries[0].Seq != 3 {
+		t.Errorf("entries = %+v, total %d", entries, total)
+	}
+
+	// Editing an entry on disk breaks the chain.
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, []byte(strings.Replace(string(data), `"actor":"bob"`, `"actor":"eve"`, 1)), 0o600)
+	tampered, err := OpenAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tampered.Verify(); err == nil || !strings.Contains(err.Error(), "entry 2") {
+		t.Errorf("Verify of tampered log: %v", err)
+	}
+}
+
+func TestAuditMiddleware(t *testing.T) {
+	reg := driftRegistry()
+	infer := func(ctx context.Context, prompt string) (string, error) {
+		if prompt == "What is 2+2?" {
+			return "4", nil
+		}
+		return "12", nil
+	}
+	runner := NewRunner(reg, infer, tokentrace.NewReporter("matchspec", ""))
+	mux := http.NewServeMux()
+	h := NewHandler(runner, reg)
+	mux.HandleFunc("POST /eval", h.RunDirect)
+	mux.HandleFunc("GET /runs", h.Runs)
+	l, _ := OpenAuditLog("")
+	quotas := NewQuotaLimiter(time.Hour, Quota{})
+	quotas.AddKey("alice", "secret-alice", "")
+	quotas.AddKey("bob", "secret-bob", "")
+	srv := quotas.Middleware(l.Middleware(nil, mux).ServeHTTP)
+
+	req := httptest.NewRequest(http.MethodPost, "/eval?label=nightly", strings.NewReader(`{"suite":"math"}`))
+	req.Header.Set("X-API-Key", "secret-alice")
+	srv(httptest.NewRecorder(), req)
+	// The actor comes from the API key, not from headers the client sets.
+	req = httptest.NewRequest(http.MethodPost, "/eval", strings.NewReader(`{"suite":"nope"}`))
+	req.Header.Set("X-API-Key", "secret-bob")
+	req.Header.Set("X-Forwarded-User", "alice")
+	srv(httptest.NewRecorder(), req)
+	req = httptest.NewRequest(http.MethodGet, "/runs", nil)
+	req.Header.Set("X-API-Key", "secret-bob")
+	srv(httptest.NewRecorder(), req)
+
+	entries, total := l.Entries(AuditFilter{})
+	if total != 2 {
+		t.Fatalf("entries = %+v, want reads unaudited", entries)
+	}
+	bob, alice := entries[0], entries[1]
+	runs, _ := runner.Runs(RunFilter{})
+	if alice.Actor != "alice" || alice.Action != "POST /eval" || alice.Status != http.StatusOK ||
+		len(runs) != 1 || len(alice.RunIDs) != 1 || alice.RunIDs[0] != runs[0].ID || alice.Details["query"] != "label=nightly" {
+		t.Errorf("alice = %+v, runs = %+v", alice, runs)
+	}
+	if bob.Actor != "bob" || bob.Status == http.StatusOK || len(bob.RunIDs) != 0 {
+		t.Errorf("bob = %+v", bob)
+	}
+	if err := l.Verify(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAuditActors(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/eval", nil)
+	req.Header.Set("X-Forwarded-User", "alice")
+	req.SetBasicAuth("bob", "secret")
+	if got := DefaultActor(req); got != "anonymous" {
+		t.Errorf("DefaultActor = %q, want anonymous for unauthenticated headers", got)
+	}
+	if got := ProxyActor(req); got != "alice" {
+		t.Errorf("ProxyActor = %q, want alice", got)
+	}
+	req.Header.Del("X-Forwarded-User")
+	req = req.WithContext(NewQuotaLimiter(0, Quota{}).Charging(req.Context(), "ci", ""))
+	if got := ProxyActor(req); got != "ci" {
+		t.Errorf("ProxyActor without headers = %q, want the API key name", got)
+	}
+}
+
+func TestAuditRecordSuites(t *testing.T) {
+	l, _ := OpenAuditLog("")
+	reg := driftRegistry()
+	if err := l.RecordSuites(reg, "config"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.RecordSuites(reg, "config"); err != nil {
+		t.Fatal(err)
+	}
+	if _, total := l.Entries(AuditFilter{Action: AuditSuiteRegister}); total != 1 {
+		t.Errorf("register entries = %d, want 1 after an unchanged restart", total)
+	}
+
+	if err := l.RecordSuites(NewSuiteRegistry(), "config"); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := l.Entries(AuditFilter{Action: AuditSuiteRemove})
+	if len(entries) != 1 || entries[0].Target != "math" {
+		t.Errorf("remove entries = %+v", entries)
+	}
+}
+
+func TestAuditHandler(t *testing.T) {
+	l, _ := OpenAuditLog("")
+	l.Append(AuditEntry{Actor: "alice", Action: "POST /eval"})
+	h := NewAuditHandler(l)
+
+	w := httptest.NewRecorder()
+	h.List(w, httptest.NewRequest(http.MethodGet, "/admin/audit?actor=alice", nil))
+	var resp AuditResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Total != 1 {
+		t.Errorf("list = %+v, %v", resp, err)
+	}
+	w = httptest.NewRecorder()
+	h.List(w, httptest.NewRequest(http.MethodGet, "/admin/audit?since=yesterday", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid since = %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	h.Verify(w, httptest.NewRequest(http.MethodGet, "/admin/audit/verify", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("verify = %d: %s", w.Code, w.Body)
+	}
+}
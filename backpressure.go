@@ -0,0 +1,299 @@
+package matchspec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Queues named by QueueFullError and the queue metrics.
+const (
+	QueueRuns = "runs" // runs waiting for a slot under WithRunLimit
+	QueueJobs = "jobs" // jobs waiting in a JobQueue
+)
+
+// ErrQueueFull matches every QueueFullError.
+var ErrQueueFull = errors.New("matchspec: queue full")
+
+// QueueFullError reports a run or job turned away because the queue it
+// would wait in is full. Handlers answer it with 429 Too Many Requests.
+type QueueFullError struct {
+	Queue string `json:"queue"`
+	Depth int    `json:"depth"` // entries waiting when it was turned away
+	Limit int    `json:"limit"`
+
+	// RetryAfter estimates when a place is likely to free up, or is zero
+	// if there is no estimate.
+	RetryAfter time.Duration `json:"-"`
+}
+
+func (e *QueueFullError) Error() string {
+	return fmt.Sprintf("matchspec: %s queue full (%d waiting, limit %d)", e.Queue, e.Depth, e.Limit)
+}
+
+// Is makes errors.Is(err, ErrQueueFull) true.
+func (e *QueueFullError) Is(target error) bool { return target == ErrQueueFull }
+
+// WithRunLimit lets at most concurrent runs execute at once. Further runs
+// wait their turn, first come first served, up to queued of them; beyond
+// that a run fails at once with a QueueFullError. Runs taken from a job
+// queue always wait, since the job queue bounds them. concurrent <= 0
+// means no limit.
+func WithRunLimit(concurrent, queued int) RunnerOption {
+	return func(r *Runner) {
+		if concurrent > 0 {
+			r.gate = &runGate{limit: concurrent, maxQueued: max(queued, 0)}
+		}
+	}
+}
+
+// runGate admits runs under WithRunLimit.
+type runGate struct {
+	mu        sync.Mutex
+	limit     int
+	maxQueued int
+	running   int
+	queue     []*gateTicket
+
+	// avgHold is a moving average of how long runs hold a slot, for
+	// QueueFullError.RetryAfter.
+	avgHold time.Duration
+}
+
+// gateTicket is a run's place at the gate. ready is closed once the run
+// holds a slot.
+type gateTicket struct {
+	ready    chan struct{}
+	reserved time.Time
+	granted  time.Time
+}
+
+type gateTicketKey struct{}
+type gateWaitKey struct{}
+
+// reserve takes a slot, or a place in the queue, for a run. It fails with
+// a QueueFullError if the queue is full, unless wait is set.
+func (g *runGate) reserve(wait bool) (*gateTicket, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	t := &gateTicket{ready: make(chan struct{}), reserved: time.Now()}
+	switch {
+	case g.running < g.limit && len(g.queue) == 0:
+		g.running++
+		t.granted = t.reserved
+		close(t.ready)
+	case wait || len(g.queue) < g.maxQueued:
+		g.queue = append(g.queue, t)
+	default:
+		err := &QueueFullError{Queue: QueueRuns, Depth: len(g.queue), Limit: g.maxQueued}
+		if g.avgHold > 0 {
+			err.RetryAfter = g.avgHold * time.Duration(len(g.queue)+1) / time.Duration(g.limit)
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+// wait blocks until t holds a slot. If ctx is done first, t gives up its
+// place, or its slot if it was granted meanwhile.
+func (g *runGate) wait(ctx context.Context, t *gateTicket) error {
+	select {
+	case <-t.ready:
+		return nil
+	case <-ctx.Done():
+	}
+	g.mu.Lock()
+	if i := slices.Index(g.queue, t); i >= 0 {
+		g.queue = slices.Delete(g.queue, i, i+1)
+		g.mu.Unlock()
+		return ctx.Err()
+	}
+	g.mu.Unlock()
+	g.release(t)
+	return ctx.Err()
+}
+
+// release frees t's slot and hands it to the next waiting run.
+func (g *runGate) release(t *gateTicket) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	hold := time.Since(t.granted)
+	if g.avgHold == 0 {
+		g.avgHold = hold
+	} else {
+		g.avgHold = (4*g.avgHold + hold) / 5
+	}
+	g.running--
+	for g.running < g.limit && len(g.queue) > 0 {
+		next := g.queue[0]
+		g.queue = g.queue[1:]
+		g.running++
+		next.granted = time.Now()
+		close(next.ready)
+	}
+}
+
+// position returns t's 1-based place in the queue, or 0 if it holds a
+// slot or has left.
+func (g *runGate) position(t *gateTicket) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return slices.Index(g.queue, t) + 1
+}
+
+// depth returns the number of waiting runs.
+func (g *runGate) depth() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.queue)
+}
+
+// admit waits for a slot for a run, using the ticket Start reserved if
+// ctx carries one. The returned func releases the slot; it is a no-op
+// without a run limit.
+func (r *Runner) admit(ctx context.Context, suite string) (func(), error) {
+	g := r.gate
+	if g == nil {
+		return func() {}, nil
+	}
+	t, _ := ctx.Value(gateTicketKey{}).(*gateTicket)
+	if t == nil {
+		var err error
+		wait, _ := ctx.Value(gateWaitKey{}).(bool)
+		if t, err = r.reserveRun(wait); err != nil {
+			return nil, err
+		}
+	}
+	if err := g.wait(ctx, t); err != nil {
+		r.observeQueueDepth()
+		return nil, err
+	}
+	r.observeQueueDepth()
+	if r.metrics != nil {
+		r.metrics.observeQueueWait(QueueRuns, suite, t.granted.Sub(t.reserved))
+	}
+	return func() {
+		g.release(t)
+		r.observeQueueDepth()
+	}, nil
+}
+
+// reserveRun reserves a place at the gate, counting rejections.
+func (r *Runner) reserveRun(wait bool) (*gateTicket, error) {
+	t, err := r.gate.reserve(wait)
+	if err != nil {
+		if r.metrics != nil {
+			r.metrics.queueRejected(QueueRuns)
+		}
+		return nil, err
+	}
+	r.observeQueueDepth()
+	return t, nil
+}
+
+func (r *Runner) observeQueueDepth() {
+	if r.metrics != nil && r.gate != nil {
+		r.metrics.setQueueDepth(QueueRuns, r.gate.depth())
+	}
+}
+
+// writeQueueFull responds 429 if err is a QueueFullError and reports
+// whether it did. X-Queue-Position is the place the request would have
+// taken and X-Queue-Depth the number already waiting.
+func writeQueueFull(w http.ResponseWriter, err error) bool {
+	var full *QueueFullError
+	if !errors.As(err, &full) {
+		return false
+	}
+	retry := max(int(full.RetryAfter.Round(time.Second)/time.Second), 1)
+	w.Header().Set("Retry-After", strconv.Itoa(retry))
+	w.Header().Set("X-Queue-Position", strconv.Itoa(full.Depth+1))
+	w.Header().Set("X-Queue-Depth", strconv.Itoa(full.Depth))
+	http.Error(w, err.Error(), http.StatusTooManyRequests)
+	return true
+}
+
+// InferLimits caps the runner's inference calls, across all of its runs:
+// at most Concurrency at once and RPS started per second. Zero fields are
+// unlimited. SweepConcurrency recommends them for a backend.
+type InferLimits struct {
+	Concurrency int     `json:"concurrency,omitempty"`
+	RPS         float64 `json:"rps,omitempty"`
+}
+
+// WithInferLimits applies l to every inference call. Calls wait for room
+// rather than failing, and give up when their context is done.
+func WithInferLimits(l InferLimits) RunnerOption {
+	return func(r *Runner) {
+		if l.Concurrency <= 0 && l.RPS <= 0 {
+			return
+		}
+		lim := &inferLimiter{}
+		if l.Concurrency > 0 {
+			lim.slots = make(chan struct{}, l.Concurrency)
+		}
+		if l.RPS > 0 {
+			lim.interval = time.Duration(float64(time.Second) / l.RPS)
+		}
+		infer := r.infer
+		r.infer = func(ctx context.Context, prompt string) (string, error) {
+			release, err := lim.wait(ctx)
+			if err != nil {
+				return "", err
+			}
+			defer release()
+			return infer(ctx, prompt)
+		}
+	}
+}
+
+// inferLimiter paces calls under WithInferLimits.
+type inferLimiter struct {
+	slots    chan struct{}
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time // when the next call may start
+}
+
+// wait blocks until a call may start and returns the function that ends
+// it.
+func (l *inferLimiter) wait(ctx context.Context) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, context.Cause(ctx)
+	}
+	release := func() {}
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+			release = func() { <-l.slots }
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		}
+	}
+	if l.interval > 0 {
+		l.mu.Lock()
+		at := time.Now()
+		if l.next.After(at) {
+			at = l.next
+		}
+		l.next = at.Add(l.interval)
+		l.mu.Unlock()
+		if d := time.Until(at); d > 0 {
+			t := time.NewTimer(d)
+			defer t.Stop()
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				release()
+				return nil, context.Cause(ctx)
+			}
+		}
+	}
+	return release, nil
+}
@@ -0,0 +1,183 @@
+package matchspec
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestRunLimit(t *testing.T) {
+	release := make(chan struct{})
+	infer := func(ctx context.Context, prompt string) (string, error) {
+		<-release
+		return "4", nil
+	}
+	m := NewMetrics()
+	runner := NewRunner(driftRegistry(), infer, tokentrace.NewReporter("matchspec", ""), WithMetrics(m), WithRunLimit(1, 1))
+	h := NewHandler(runner, driftRegistry())
+	post := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.RunDirect(w, httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"suite":"math"}`)))
+		return w
+	}
+
+	if w := post("/eval?async=true"); w.Code != http.StatusAccepted || w.Header().Get("X-Queue-Position") != "0" {
+		t.Fatalf("first run: %d, position %q", w.Code, w.Header().Get("X-Queue-Position"))
+	}
+	w := post("/eval?async=true")
+	if w.Code != http.StatusAccepted || w.Header().Get("X-Queue-Position") != "1" {
+		t.Fatalf("second run: %d, position %q", w.Code, w.Header().Get("X-Queue-Position"))
+	}
+	queued := strings.TrimPrefix(w.Header().Get("Location"), "/runs/")
+	if st, _ := runner.Manager().Status(queued); st.QueuePosition != 1 {
+		t.Errorf("status = %+v", st)
+	}
+
+	// The queue is full: sync and async requests alike are turned away.
+	for _, target := range []string{"/eval", "/eval?async=true"} {
+		w := post(target)
+		if w.Code != http.StatusTooManyRequests || w.Header().Get("X-Queue-Position") != "2" ||
+			w.Header().Get("X-Queue-Depth") != "1" || w.Header().Get("Retry-After") != "1" {
+			t.Errorf("%s: %d %v", target, w.Code, w.Header())
+		}
+	}
+	if _, err := runner.Run(context.Background(), protocol.EvalRun{Suite: "math"}); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Run with a full queue: %v", err)
+	}
+
+	close(release)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if runs, _ := runner.Runs(RunFilter{}); len(runs) == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("queued run never ran")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := runner.Run(context.Background(), protocol.EvalRun{Suite: "math"}); err != nil {
+		t.Errorf("Run after the queue drained: %v", err)
+	}
+
+	var sb strings.Builder
+	m.WritePrometheus(&sb)
+	for _, want := range []string{
+		`matchspec_queue_wait_seconds_count{queue="runs",suite="math"} 3`,
+		`matchspec_queue_depth{queue="runs"} 0`,
+		`matchspec_queue_rejected_total{queue="runs"} 3`,
+	} {
+		if !strings.Contains(sb.String(), want) {
+			t.Errorf("metrics missing %q:\n%s", want, sb.String())
+		}
+	}
+}
+
+func TestRunGateCancel(t *testing.T) {
+	g := &runGate{limit: 1}
+	held, _ := g.reserve(false)
+	if _, err := g.reserve(false); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("reserve with no queue: %v", err)
+	}
+	waiting, err := g.reserve(true)
+	if err != nil || g.position(waiting) != 1 {
+		t.Fatalf("reserve(wait) = %v, position %d", err, g.position(waiting))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := g.wait(ctx, waiting); !errors.Is(err, context.Canceled) || g.depth() != 0 {
+		t.Errorf("wait = %v, depth %d", err, g.depth())
+	}
+
+	// The slot passes to the next run in line.
+	next, _ := g.reserve(true)
+	g.release(held)
+	if err := g.wait(context.Background(), next); err != nil {
+		t.Fatal(err)
+	}
+	if g.running != 1 || g.avgHold <= 0 {
+		t.Errorf("running %d, avgHold %v", g.running, g.avgHold)
+	}
+}
+
+func TestJobQueueMaxQueued(t *testing.T) {
+	q, _ := OpenFileJobQueue("")
+	q.MaxQueued = 2
+	h := NewJobHandler(q)
+	enqueue := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.Enqueue(w, httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(body)))
+		return w
+	}
+
+	if w := enqueue(`{"run":{"suite":"math"}}`); w.Code != http.StatusAccepted || w.Header().Get("X-Queue-Position") != "1" {
+		t.Fatalf("first job: %d, position %q", w.Code, w.Header().Get("X-Queue-Position"))
+	}
+	// A higher-priority job is claimed first.
+	if w := enqueue(`{"run":{"suite":"math"},"priority":5}`); w.Code != http.StatusAccepted || w.Header().Get("X-Queue-Position") != "1" {
+		t.Fatalf("priority job: %d, position %q", w.Code, w.Header().Get("X-Queue-Position"))
+	}
+	w := enqueue(`{"run":{"suite":"math"}}`)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("X-Queue-Depth") != "2" {
+		t.Fatalf("full queue: %d %v", w.Code, w.Header())
+	}
+
+	// Claimed jobs no longer count against the limit.
+	if _, ok, err := q.Claim(context.Background(), "w1"); !ok || err != nil {
+		t.Fatalf("claim: %v, %v", ok, err)
+	}
+	if w := enqueue(`{"run":{"suite":"math"}}`); w.Code != http.StatusAccepted || w.Header().Get("X-Queue-Position") != "2" {
+		t.Errorf("after claim: %d, position %q", w.Code, w.Header().Get("X-Queue-Position"))
+	}
+}
+
+func TestInferLimits(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	slow := func(ctx context.Context, prompt string) (string, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(20 * time.Millisecond)
+		return prompt, nil
+	}
+	calls := func(r *Runner) time.Duration {
+		start := time.Now()
+		var wg sync.WaitGroup
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				r.infer(context.Background(), "p")
+			}()
+		}
+		wg.Wait()
+		return time.Since(start)
+	}
+
+	r := NewRunner(NewSuiteRegistry(), slow, tokentrace.NewReporter("matchspec", ""), WithInferLimits(InferLimits{Concurrency: 2}))
+	if calls(r); peak.Load() != 2 {
+		t.Errorf("peak concurrency = %d, want 2", peak.Load())
+	}
+
+	// Eight calls at 200 rps start over at least 35ms.
+	r = NewRunner(NewSuiteRegistry(), echoInfer, tokentrace.NewReporter("matchspec", ""), WithInferLimits(InferLimits{RPS: 200}))
+	if d := calls(r); d < 35*time.Millisecond {
+		t.Errorf("8 calls took %v at 200 rps", d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := r.infer(ctx, "p"); err == nil {
+		t.Error("call with a done context was not refused")
+	}
+}
@@ -0,0 +1,367 @@
+package matchspec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxScoreDrop is the score drop beyond which the CLI flags a task
+// as regressed against its baseline.
+const DefaultMaxScoreDrop = 0.1
+
+// Baseline is the recorded outcome of every task in one run of a suite,
+// which later runs are compared with task by task.
+type Baseline struct {
+	Suite      string                  `json:"suite"`
+	RunID      string                  `json:"run_id"`
+	Model      string                  `json:"model,omitempty"`
+	RecordedAt time.Time               `json:"recorded_at"`
+	PassRate   float64                 `json:"pass_rate"`
+	Tasks      map[string]BaselineTask `json:"tasks"`
+}
+
+// BaselineTask is a task's outcome in a Baseline. A task run more than
+// once, such as under repeats, has its mean score and passes only if
+// every run passed.
+type BaselineTask struct {
+	Passed bool    `json:"passed"`
+	Score  float64 `json:"score"`
+}
+
+// NewBaseline records the outcome of each task in results, the results of
+// run rec. Prompt variants are recorded separately, as task@variant.
+func NewBaseline(rec RunRecord, results []Result) Baseline {
+	return Baseline{
+		Suite:      rec.Suite,
+		RunID:      rec.ID,
+		Model:      rec.Model,
+		RecordedAt: time.Now().UTC(),
+		PassRate:   rec.Summary.PassRate,
+		Tasks:      baselineTasks(results),
+	}
+}
+
+// baselineKey names a result's task in a Baseline.
+func baselineKey(res Result) string {
+	if res.Variant == "" || res.Variant == BaseVariant {
+		return res.Task
+	}
+	return res.Task + "@" + res.Variant
+}
+
+// baselineTasks folds results into one outcome per task.
+func baselineTasks(results []Result) map[string]BaselineTask {
+	tasks := make(map[string]BaselineTask)
+	runs := make(map[string]int)
+	for _, res := range results {
+		key := baselineKey(res)
+		t, seen := tasks[key]
+		n := runs[key]
+		if !seen {
+			t.Passed = true
+		}
+		t.Passed = t.Passed && res.Passed
+		t.Score = (t.Score*float64(n) + res.Score) / float64(n+1)
+		tasks[key] = t
+		runs[key] = n + 1
+	}
+	return tasks
+}
+
+// BaselineComparison is how a run fared against its suite's baseline.
+type BaselineComparison struct {
+	// RunID and RecordedAt identify the baseline.
+	RunID      string    `json:"run_id"`
+	RecordedAt time.Time `json:"recorded_at"`
+
+	// Compared is the number of tasks in both the run and the baseline.
+	Compared int `json:"compared"`
+
+	// NewlyFailing are tasks that passed in the baseline and failed in
+	// the run, and Fixed the reverse.
+	NewlyFailing []string `json:"newly_failing,omitempty"`
+	Fixed        []string `json:"fixed,omitempty"`
+
+	// ScoreDrops are the other compared tasks whose score fell by more
+	// than the allowed drop.
+	ScoreDrops []ScoreDrop `json:"score_drops,omitempty"`
+
+	// New are tasks the baseline does not have.
+	New []string `json:"new,omitempty"`
+
+	// Regressed is true if any task is newly failing or dropped in score.
+	Regressed bool `json:"regressed"`
+}
+
+// ScoreDrop is a task that scored lower than in the baseline.
+type ScoreDrop struct {
+	Task     string  `json:"task"`
+	Baseline float64 `json:"baseline"`
+	Score    float64 `json:"score"`
+	Delta    float64 `json:"delta"`
+}
+
+// String summarizes the comparison in one line.
+func (c *BaselineComparison) String() string {
+	if !c.Regressed {
+		return fmt.Sprintf("no regressions against baseline run %s (%d tasks compared, %d fixed)", c.RunID, c.Compared, len(c.Fixed))
+	}
+	return fmt.Sprintf("regressed against baseline run %s: %d newly failing, %d score drops (%d tasks compared)",
+		c.RunID, len(c.NewlyFailing), len(c.ScoreDrops), c.Compared)
+}
+
+// Compare compares results with the baseline. A task regresses if it
+// passed in the baseline and fails now, or if its score fell by more than
+// maxScoreDrop. Tasks in the baseline but not in results, such as those
+// left out by a task filter, are ignored.
+func (b Baseline) Compare(results []Result, maxScoreDrop float64) *BaselineComparison {
+	c := &BaselineComparison{RunID: b.RunID, RecordedAt: b.RecordedAt}
+	tasks := baselineTasks(results)
+	keys := make([]string, 0, len(tasks))
+	for key := range tasks {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		now := tasks[key]
+		was, ok := b.Tasks[key]
+		if !ok {
+			c.New = append(c.New, key)
+			continue
+		}
+		c.Compared++
+		switch {
+		case was.Passed && !now.Passed:
+			c.NewlyFailing = append(c.NewlyFailing, key)
+		case !was.Passed && now.Passed:
+			c.Fixed = append(c.Fixed, key)
+		case was.Score-now.Score > maxScoreDrop:
+			c.ScoreDrops = append(c.ScoreDrops, ScoreDrop{Task: key, Baseline: was.Score, Score: now.Score, Delta: now.Score - was.Score})
+		}
+	}
+	c.Regressed = len(c.NewlyFailing) > 0 || len(c.ScoreDrops) > 0
+	return c
+}
+
+// BaselineStore holds the baseline of each suite.
+type BaselineStore interface {
+	Get(suite string) (Baseline, bool, error)
+	Put(b Baseline) error
+	List() ([]Baseline, error)
+}
+
+// MemoryBaselineStore is an in-process BaselineStore. It is safe for
+// concurrent use.
+type MemoryBaselineStore struct {
+	mu        sync.RWMutex
+	baselines map[string]Baseline
+}
+
+// NewMemoryBaselineStore returns an empty in-process baseline store.
+func NewMemoryBaselineStore() *MemoryBaselineStore {
+	return &MemoryBaselineStore{baselines: make(map[string]Baseline)}
+}
+
+// Get returns the baseline of a suite.
+func (s *MemoryBaselineStore) Get(suite string) (Baseline, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b, ok := s.baselines[suite]
+	return b, ok, nil
+}
+
+// Put stores the baseline of its suite, replacing any previous one.
+func (s *MemoryBaselineStore) Put(b Baseline) error {
+	s.mu.Lock()
+	s.baselines[b.Suite] = b
+	s.mu.Unlock()
+	return nil
+}
+
+// List returns every baseline, ordered by suite.
+func (s *MemoryBaselineStore) List() ([]Baseline, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Baseline, 0, len(s.baselines))
+	for _, b := range s.baselines {
+		out = append(out, b)
+	}
+	slices.SortFunc(out, func(a, b Baseline) int { return strings.Compare(a.Suite, b.Suite) })
+	return out, nil
+}
+
+// DirBaselineStore is a BaselineStore that keeps one JSON file per suite
+// in a directory meant to be committed alongside the suites.
+type DirBaselineStore struct {
+	dir string
+	mu  sync.Mutex
+	storeConfig
+}
+
+// NewDirBaselineStore returns a store in dir, which is created on first
+// Put.
+func NewDirBaselineStore(dir string, opts ...StoreOption) *DirBaselineStore {
+	return &DirBaselineStore{dir: dir, storeConfig: newStoreConfig(opts)}
+}
+
+func (s *DirBaselineStore) path(suite string) string {
+	return filepath.Join(s.dir, url.PathEscape(suite)+".json")
+}
+
+// read loads the baseline of suite. The caller must hold s.mu.
+func (s *DirBaselineStore) read(suite string) (Baseline, bool, error) {
+	data, err := os.ReadFile(s.path(suite))
+	if errors.Is(err, os.ErrNotExist) {
+		return Baseline{}, false, nil
+	}
+	if err != nil {
+		return Baseline{}, false, fmt.Errorf("matchspec: baselines: %w", err)
+	}
+	if data, err = s.open(data, suite); err != nil {
+		return Baseline{}, false, fmt.Errorf("matchspec: baselines: %w", err)
+	}
+	var b Baseline
+	if err := json.Unmarshal(data, &b); err != nil {
+		return Baseline{}, false, fmt.Errorf("matchspec: baselines: %s: %w", s.path(suite), err)
+	}
+	return b, true, nil
+}
+
+// Get returns the baseline of a suite.
+func (s *DirBaselineStore) Get(suite string) (Baseline, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read(suite)
+}
+
+// Put writes the baseline of its suite, replacing any previous one.
+func (s *DirBaselineStore) Put(b Baseline) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	if data, err = s.seal(append(data, '\n'), b.Suite); err != nil {
+		return fmt.Errorf("matchspec: baselines: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("matchspec: baselines: %w", err)
+	}
+	f, err := os.CreateTemp(s.dir, url.PathEscape(b.Suite)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("matchspec: baselines: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("matchspec: baselines: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("matchspec: baselines: %w", err)
+	}
+	if err := os.Rename(f.Name(), s.path(b.Suite)); err != nil {
+		return fmt.Errorf("matchspec: baselines: %w", err)
+	}
+	return nil
+}
+
+// List returns every baseline in the directory, ordered by suite.
+func (s *DirBaselineStore) List() ([]Baseline, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("matchspec: baselines: %w", err)
+	}
+	out := []Baseline{}
+	for _, file := range files {
+		suite, err := url.PathUnescape(strings.TrimSuffix(filepath.Base(file), ".json"))
+		if err != nil {
+			continue
+		}
+		b, ok, err := s.read(suite)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out = append(out, b)
+		}
+	}
+	slices.SortFunc(out, func(a, b Baseline) int { return strings.Compare(a.Suite, b.Suite) })
+	return out, nil
+}
+
+// WithBaselines compares every run with its suite's baseline in store, if
+// it has one, and records the comparison on the run record (see
+// RunRecord.Baseline). Each result gets its task's baseline score and the
+// change from it. A task regresses if it newly fails or its score drops
+// by more than maxScoreDrop. Streamed runs are not compared.
+func WithBaselines(store BaselineStore, maxScoreDrop float64) RunnerOption {
+	return func(r *Runner) {
+		r.baselines = store
+		r.maxScoreDrop = maxScoreDrop
+	}
+}
+
+// Baselines returns the runner's baseline store, or nil if it has none.
+func (r *Runner) Baselines() BaselineStore {
+	return r.baselines
+}
+
+// compareBaseline compares the results of rec with the suite's baseline,
+// setting each result's Baseline and Delta. Failing to read the baseline
+// adds a warning to rec.
+func (r *Runner) compareBaseline(rec *RunRecord, results []Result) {
+	b, ok, err := r.baselines.Get(rec.Suite)
+	if err != nil {
+		rec.Warnings = append(rec.Warnings, err.Error())
+		return
+	}
+	if !ok {
+		return
+	}
+	for i := range results {
+		if t, ok := b.Tasks[baselineKey(results[i])]; ok {
+			results[i].Baseline = t.Score
+			results[i].Delta = results[i].Score - t.Score
+		}
+	}
+	rec.Baseline = b.Compare(results, r.maxScoreDrop)
+}
+
+// RecordBaseline makes the recorded run id the baseline of its suite. Runs
+// that failed, had tasks error, or whose results were not retained cannot
+// be baselines.
+func (r *Runner) RecordBaseline(id string) (Baseline, error) {
+	if r.baselines == nil {
+		return Baseline{}, fmt.Errorf("matchspec: no baseline store")
+	}
+	rec, ok := r.GetRun(id)
+	if !ok {
+		return Baseline{}, fmt.Errorf("%w: %q", ErrRunNotFound, id)
+	}
+	if rec.Error != "" {
+		return Baseline{}, fmt.Errorf("matchspec: run %s failed (%s); it cannot be a baseline", id, rec.Error)
+	}
+	results, _ := r.QueryResults(ResultFilter{RunID: id})
+	if len(results) == 0 {
+		return Baseline{}, fmt.Errorf("matchspec: run %s has no results to record", id)
+	}
+	if n := rec.Summary.Errors; n > 0 {
+		return Baseline{}, fmt.Errorf("matchspec: %d tasks of run %s errored; it cannot be a baseline", n, id)
+	}
+	b := NewBaseline(rec, results)
+	if err := r.baselines.Put(b); err != nil {
+		return Baseline{}, err
+	}
+	return b, nil
+}
@@ -0,0 +1,142 @@
+package matchspec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestBaselineCompare(t *testing.T) {
+	result := func(task string, passed bool, score float64) Result {
+		return Result{EvalResult: protocol.EvalResult{Suite: "s", Task: task, Passed: passed, Score: score}}
+	}
+	b := NewBaseline(RunRecord{ID: "base", Suite: "s"}, []Result{
+		result("steady", true, 1),
+		result("breaks", true, 1),
+		result("fixed", false, 0),
+		result("slips", true, 0.9),
+		result("wobbles", true, 0.9),
+		result("dropped", true, 1),
+	})
+	c := b.Compare([]Result{
+		result("steady", true, 1),
+		result("breaks", false, 0),
+		result("fixed", true, 1),
+		result("slips", true, 0.7),
+		result("wobbles", true, 0.85),
+		result("added", true, 1),
+	}, 0.1)
+	if !c.Regressed || c.Compared != 5 || c.RunID != "base" {
+		t.Errorf("comparison = %+v", c)
+	}
+	if strings.Join(c.NewlyFailing, ",") != "breaks" || strings.Join(c.Fixed, ",") != "fixed" || strings.Join(c.New, ",") != "added" {
+		t.Errorf("failing %v, fixed %v, new %v", c.NewlyFailing, c.Fixed, c.New)
+	}
+	if len(c.ScoreDrops) != 1 || c.ScoreDrops[0].Task != "slips" {
+		t.Errorf("score drops = %+v", c.ScoreDrops)
+	}
+	if !strings.Contains(c.String(), "1 newly failing, 1 score drops") {
+		t.Errorf("String = %q", c)
+	}
+
+	// Repeated tasks pass only if every run did.
+	repeated := NewBaseline(RunRecord{Suite: "s"}, []Result{result("r", true, 1), result("r", false, 0.5)})
+	if got := repeated.Tasks["r"]; got.Passed || got.Score != 0.75 {
+		t.Errorf("repeated task = %+v", got)
+	}
+}
+
+func TestRunnerBaselines(t *testing.T) {
+	var broken atomic.Bool
+	infer := func(ctx context.Context, prompt string) (string, error) {
+		if broken.Load() && prompt == "What is 3*4?" {
+			return "11", nil
+		}
+		return map[string]string{"What is 2+2?": "4", "What is 3*4?": "12"}[prompt], nil
+	}
+	store := NewDirBaselineStore(t.TempDir())
+	runner := NewRunner(driftRegistry(), infer, tokentrace.NewReporter("matchspec", ""), WithBaselines(store, 0.1))
+	ctx := context.Background()
+
+	// Without a baseline there is nothing to compare.
+	if _, err := runner.Run(ctx, protocol.EvalRun{Suite: "math"}); err != nil {
+		t.Fatal(err)
+	}
+	runs, _ := runner.Runs(RunFilter{})
+	if runs[0].Baseline != nil {
+		t.Errorf("compared without a baseline: %+v", runs[0].Baseline)
+	}
+	b, err := runner.RecordBaseline(runs[0].ID)
+	if err != nil || len(b.Tasks) != 2 || b.PassRate != 1 {
+		t.Fatalf("RecordBaseline = %+v, %v", b, err)
+	}
+	if got, ok, _ := store.Get("math"); !ok || got.RunID != runs[0].ID {
+		t.Errorf("stored baseline = %+v", got)
+	}
+
+	broken.Store(true)
+	results, _ := runner.Run(ctx, protocol.EvalRun{Suite: "math"})
+	runs, _ = runner.Runs(RunFilter{})
+	c := runs[0].Baseline
+	if c == nil || !c.Regressed || strings.Join(c.NewlyFailing, ",") != "mul" {
+		t.Fatalf("comparison = %+v", c)
+	}
+	for _, res := range results {
+		if res.Task == "mul" && (res.Baseline != 1 || res.Delta != -1) {
+			t.Errorf("mul baseline %v, delta %v", res.Baseline, res.Delta)
+		}
+	}
+	if _, err := runner.RecordBaseline("missing"); err == nil {
+		t.Error("recorded a missing run")
+	}
+
+	h := NewHandler(runner, driftRegistry())
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /baselines", h.Baselines)
+	mux.HandleFunc("GET /baselines/{suite...}", h.GetBaseline)
+	mux.HandleFunc("POST /baselines", h.RecordBaseline)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/baselines", strings.NewReader(`{"run_id":"`+runs[0].ID+`"}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /baselines = %d %s", w.Code, w.Body)
+	}
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/baselines", nil))
+	var list BaselinesResponse
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if len(list.Baselines) != 1 || list.Baselines[0].RunID != runs[0].ID || list.Baselines[0].Tasks["mul"].Passed {
+		t.Errorf("GET /baselines = %s", w.Body)
+	}
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/baselines/nope", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing baseline = %d", w.Code)
+	}
+}
+
+func TestDirBaselineStoreEncrypted(t *testing.T) {
+	c, err := NewCipher(bytes.Repeat([]byte{3}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	store := NewDirBaselineStore(dir, EncryptWith(c))
+	if err := store.Put(Baseline{Suite: "team/secret", Tasks: map[string]BaselineTask{"leak": {Passed: true, Score: 1}}}); err != nil {
+		t.Fatal(err)
+	}
+	if list, err := store.List(); err != nil || len(list) != 1 || list[0].Suite != "team/secret" {
+		t.Errorf("List = %+v, %v", list, err)
+	}
+	if _, _, err := NewDirBaselineStore(dir).Get("team/secret"); err == nil {
+		t.Error("read an encrypted baseline without the key")
+	}
+}
@@ -0,0 +1,195 @@
+package matchspec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/greynewell/mist-go/parallel"
+)
+
+// SweepConfig controls a concurrency sweep.
+type SweepConfig struct {
+	// Levels are the concurrency levels to measure, in increasing order.
+	Levels []int
+	// RequestsPerLevel is the number of prompts sent at each level.
+	RequestsPerLevel int
+	// MinGain is the relative throughput gain below which the previous
+	// level is considered the knee (default 0.1, i.e. 10%).
+	MinGain float64
+	// MaxErrorRate stops the sweep at the first level whose error rate
+	// exceeds it (default 0.05).
+	MaxErrorRate float64
+}
+
+// SweepLevel is the measurement at one concurrency level.
+type SweepLevel struct {
+	Concurrency  int     `json:"concurrency"`
+	Requests     int     `json:"requests"`
+	Errors       int     `json:"errors"`
+	DurationMS   int64   `json:"duration_ms"`
+	Throughput   float64 `json:"throughput_rps"`
+	LatencyP50MS float64 `json:"latency_p50_ms"`
+	LatencyP95MS float64 `json:"latency_p95_ms"`
+}
+
+// SweepReport is the outcome of a concurrency sweep. The knee is the
+// highest level that still improved throughput meaningfully; the
+// recommended rate keeps 20% headroom below the knee's throughput.
+type SweepReport struct {
+	Levels                 []SweepLevel `json:"levels"`
+	KneeConcurrency        int          `json:"knee_concurrency"`
+	RecommendedConcurrency int          `json:"recommended_concurrency"`
+	RecommendedRPS         float64      `json:"recommended_rps"`
+}
+
+// SweepConcurrency measures inference throughput at increasing concurrency
+// levels using prompts round-robin, stopping early once throughput stops
+// improving or errors climb.
+func SweepConcurrency(ctx context.Context, infer InferFunc, prompts []string, cfg SweepConfig) (SweepReport, error) {
+	if len(prompts) == 0 {
+		return SweepReport{}, fmt.Errorf("matchspec: sweep needs at least one prompt")
+	}
+	if len(cfg.Levels) == 0 {
+		cfg.Levels = []int{1, 2, 4, 8, 16, 32}
+	}
+	if cfg.RequestsPerLevel <= 0 {
+		cfg.RequestsPerLevel = 20
+	}
+	if cfg.MinGain <= 0 {
+		cfg.MinGain = 0.1
+	}
+	if cfg.MaxErrorRate <= 0 {
+		cfg.MaxErrorRate = 0.05
+	}
+
+	var report SweepReport
+	for _, level := range cfg.Levels {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		m := measureLevel(ctx, infer, prompts, level, cfg.RequestsPerLevel)
+		report.Levels = append(report.Levels, m)
+
+		if float64(m.Errors)/float64(m.Requests) > cfg.MaxErrorRate {
+			break
+		}
+		if report.KneeConcurrency != 0 {
+			prev := report.Levels[len(report.Levels)-2]
+			if m.Throughput < prev.Throughput*(1+cfg.MinGain) {
+				break
+			}
+		}
+		report.KneeConcurrency = level
+		report.RecommendedRPS = m.Throughput * 0.8
+	}
+	report.RecommendedConcurrency = report.KneeConcurrency
+	return report, nil
+}
+
+// Limits returns the recommended settings as InferLimits.
+func (r SweepReport) Limits() InferLimits {
+	return InferLimits{Concurrency: r.RecommendedConcurrency, RPS: r.RecommendedRPS}
+}
+
+// RunnerOptions returns the options applying the recommended settings to a
+// runner (see WithInferLimits).
+func (r SweepReport) RunnerOptions() []RunnerOption {
+	return []RunnerOption{WithInferLimits(r.Limits())}
+}
+
+// WriteConfigLimits sets the "infer_limits" of the project config at path
+// to l, creating the file if it does not exist. A YAML file keeps its
+// other lines, comments included; a JSON file is rewritten indented.
+func WriteConfigLimits(path string, l InferLimits) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("matchspec: %w", err)
+	}
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		data = setYAMLLimits(data, l)
+	case ".json":
+		doc := make(map[string]any)
+		if len(bytes.TrimSpace(data)) > 0 {
+			if err := json.Unmarshal(data, &doc); err != nil {
+				return fmt.Errorf("matchspec: %s: %w", path, err)
+			}
+		}
+		doc["infer_limits"] = l
+		if data, err = json.MarshalIndent(doc, "", "  "); err != nil {
+			return fmt.Errorf("matchspec: %s: %w", path, err)
+		}
+		data = append(data, '\n')
+	default:
+		return fmt.Errorf("matchspec: %s: unsupported config file type", path)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("matchspec: %w", err)
+	}
+	return nil
+}
+
+// setYAMLLimits replaces the top-level infer_limits block of a YAML
+// document, or appends one.
+func setYAMLLimits(data []byte, l InferLimits) []byte {
+	var out []string
+	skipping := false
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		top := line != "" && line[0] != ' ' && line[0] != '\t' && line[0] != '#' && strings.TrimSpace(line) != ""
+		if top {
+			skipping = strings.HasPrefix(line, "infer_limits:")
+		}
+		// Comments at the top level are kept, as they may belong to
+		// the next key.
+		if (!skipping || strings.HasPrefix(line, "#")) && line != "" {
+			out = append(out, line)
+		}
+	}
+	doc := strings.Join(out, "")
+	if doc != "" && !strings.HasSuffix(doc, "\n") {
+		doc += "\n"
+	}
+	return fmt.Appendf([]byte(doc), "infer_limits:\n  concurrency: %d\n  rps: %s\n", l.Concurrency, strconv.FormatFloat(l.RPS, 'f', -1, 64))
+}
+
+func measureLevel(ctx context.Context, infer InferFunc, prompts []string, level, n int) SweepLevel {
+	inputs := make([]string, n)
+	for i := range inputs {
+		inputs[i] = prompts[i%len(prompts)]
+	}
+
+	start := time.Now()
+	results := parallel.Map(ctx, parallel.NewPool(level), inputs, func(ctx context.Context, prompt string) (float64, error) {
+		t := time.Now()
+		_, err := infer(ctx, prompt)
+		return float64(time.Since(t).Milliseconds()), err
+	})
+	elapsed := time.Since(start)
+
+	m := SweepLevel{Concurrency: level, Requests: n, DurationMS: elapsed.Milliseconds()}
+	lat := make([]float64, 0, n)
+	for _, r := range results {
+		if r.Err != nil {
+			m.Errors++
+			continue
+		}
+		lat = append(lat, r.Value)
+	}
+	sort.Float64s(lat)
+	if secs := elapsed.Seconds(); secs > 0 {
+		m.Throughput = float64(n-m.Errors) / secs
+	}
+	m.LatencyP50MS = percentile(lat, 50)
+	m.LatencyP95MS = percentile(lat, 95)
+	return m
+}
@@ -0,0 +1,101 @@
+package matchspec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// saturatingInfer simulates a backend that serves at most capacity
+// requests in parallel.
+func saturatingInfer(capacity int) InferFunc {
+	sem := make(chan struct{}, capacity)
+	return func(ctx context.Context, prompt string) (string, error) {
+		sem <- struct{}{}
+		defer func() { <-sem }()
+		time.Sleep(5 * time.Millisecond)
+		return "ok", nil
+	}
+}
+
+func TestSweepConcurrencyFindsKnee(t *testing.T) {
+	report, err := SweepConcurrency(context.Background(), saturatingInfer(4), []string{"p"}, SweepConfig{
+		Levels:           []int{1, 2, 4, 8, 16},
+		RequestsPerLevel: 32,
+		MinGain:          0.3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.KneeConcurrency != 4 {
+		t.Errorf("knee = %d, want 4; levels: %+v", report.KneeConcurrency, report.Levels)
+	}
+	if report.RecommendedRPS <= 0 || report.RecommendedConcurrency != report.KneeConcurrency {
+		t.Errorf("unexpected recommendation: %+v", report)
+	}
+}
+
+func TestSweepConcurrencyStopsOnErrors(t *testing.T) {
+	var calls atomic.Int64
+	infer := func(context.Context, string) (string, error) {
+		if calls.Add(1) > 10 {
+			return "", fmt.Errorf("overloaded")
+		}
+		return "ok", nil
+	}
+	report, err := SweepConcurrency(context.Background(), infer, []string{"p"}, SweepConfig{
+		Levels:           []int{1, 2, 4},
+		RequestsPerLevel: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Levels) != 2 || report.KneeConcurrency != 1 {
+		t.Errorf("expected sweep to stop at level 2 with knee 1, got %+v", report)
+	}
+}
+
+func TestSweepConcurrencyNoPrompts(t *testing.T) {
+	if _, err := SweepConcurrency(context.Background(), echoInfer, nil, SweepConfig{}); err == nil {
+		t.Error("expected error without prompts")
+	}
+}
+
+func TestWriteConfigLimits(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "matchspec.yaml")
+	os.WriteFile(yamlPath, []byte("# project config\nsuites:\n  - suites\ninfer_limits:\n  concurrency: 1\n# notifiers below\nnotifiers: []\n"), 0o644)
+	report := SweepReport{RecommendedConcurrency: 8, RecommendedRPS: 12.5}
+	if err := WriteConfigLimits(yamlPath, report.Limits()); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(yamlPath)
+	want := "# project config\nsuites:\n  - suites\n# notifiers below\nnotifiers: []\ninfer_limits:\n  concurrency: 8\n  rps: 12.5\n"
+	if string(data) != want {
+		t.Errorf("yaml config =\n%s\nwant\n%s", data, want)
+	}
+
+	jsonPath := filepath.Join(dir, "matchspec.json")
+	os.WriteFile(jsonPath, []byte(`{"suites": ["suites"]}`), 0o644)
+	if err := WriteConfigLimits(jsonPath, report.Limits()); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{yamlPath, jsonPath} {
+		c, err := LoadConfig(path)
+		if err != nil || c.InferLimits == nil || *c.InferLimits != report.Limits() || len(c.Suites) != 1 {
+			t.Errorf("%s: config %+v, %v", path, c, err)
+		}
+	}
+
+	created := filepath.Join(dir, "new.yaml")
+	if err := WriteConfigLimits(created, report.Limits()); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.RunnerOptions()) != 1 {
+		t.Error("no runner options for the recommendation")
+	}
+}
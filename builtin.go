@@ -0,0 +1,54 @@
+package matchspec
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"sort"
+)
+
+// BuiltinPrefix is the name prefix shared by all built-in sample suites.
+const BuiltinPrefix = "builtin/"
+
+//go:embed builtin/*.json
+var builtinFS embed.FS
+
+// BuiltinSuites returns the embedded demonstration suites (arithmetic,
+// extraction, formatting), sorted by name. Each call returns fresh copies.
+func BuiltinSuites() ([]*Suite, error) {
+	paths, err := fs.Glob(builtinFS, "builtin/*.json")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	suites := make([]*Suite, 0, len(paths))
+	for _, p := range paths {
+		data, err := builtinFS.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		var s Suite
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, fmt.Errorf("matchspec: builtin %s: %w", p, err)
+		}
+		suites = append(suites, &s)
+	}
+	return suites, nil
+}
+
+// RegisterBuiltins adds the built-in sample suites to the registry under
+// names like "builtin/arithmetic".
+func RegisterBuiltins(r *SuiteRegistry) error {
+	suites, err := BuiltinSuites()
+	if err != nil {
+		return err
+	}
+	for _, s := range suites {
+		if err := r.Register(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package matchspec
+
+import (
+	"context"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestRegisterBuiltins(t *testing.T) {
+	reg := NewSuiteRegistry()
+	if err := RegisterBuiltins(reg); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"builtin/arithmetic", "builtin/extraction", "builtin/formatting"} {
+		if _, ok := reg.Get(name); !ok {
+			t.Errorf("missing builtin suite %q", name)
+		}
+	}
+}
+
+func TestBuiltinSuitesRunnable(t *testing.T) {
+	reg := NewSuiteRegistry()
+	RegisterBuiltins(reg)
+	s, _ := reg.Get("builtin/arithmetic")
+
+	answers := make(map[string]string, len(s.Tasks))
+	for _, task := range s.Tasks {
+		answers[task.Prompt] = task.Expected
+	}
+	infer := func(_ context.Context, prompt string) (string, error) {
+		return answers[prompt], nil
+	}
+
+	runner := NewRunner(reg, infer, tokentrace.NewReporter("matchspec", ""))
+	results, err := runner.Run(context.Background(), protocol.EvalRun{Suite: "builtin/arithmetic"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(s.Tasks) {
+		t.Fatalf("results = %d, want %d", len(results), len(s.Tasks))
+	}
+	for _, r := range results {
+		if !r.Passed {
+			t.Errorf("task %s should pass with the expected answer", r.Task)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package matchspec
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/trace"
+)
+
+// RunCache stores checkpoints of green runs by cache key (see RunCacheKey)
+// so that unchanged runs can be skipped, typically in CI.
+type RunCache interface {
+	Get(key string) (Checkpoint, bool, error)
+	Put(key string, cp Checkpoint) error
+}
+
+// DirRunCache is a RunCache that keeps one checkpoint file per key in a
+// directory, suitable for a CI cache step.
+type DirRunCache struct {
+	dir string
+	storeConfig
+}
+
+// NewDirRunCache returns a cache in dir, which is created on first Put.
+func NewDirRunCache(dir string, opts ...StoreOption) *DirRunCache {
+	return &DirRunCache{dir: dir, storeConfig: newStoreConfig(opts)}
+}
+
+func (c *DirRunCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// Get returns the checkpoint stored under key.
+func (c *DirRunCache) Get(key string) (Checkpoint, bool, error) {
+	data, err := os.ReadFile(c.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return Checkpoint{}, false, nil
+	}
+	if err == nil {
+		data, err = c.open(data, key)
+	}
+	if err != nil {
+		return Checkpoint{}, false, fmt.Errorf("matchspec: run cache: %w", err)
+	}
+	cp, err := ReadCheckpoint(bytes.NewReader(data))
+	if err != nil {
+		return Checkpoint{}, false, err
+	}
+	return cp, true, nil
+}
+
+// Put stores cp under key, replacing any previous entry.
+func (c *DirRunCache) Put(key string, cp Checkpoint) error {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return fmt.Errorf("matchspec: run cache: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := WriteCheckpoint(&buf, cp); err != nil {
+		return fmt.Errorf("matchspec: run cache: %w", err)
+	}
+	data, err := c.seal(buf.Bytes(), key)
+	if err != nil {
+		return fmt.Errorf("matchspec: run cache: %w", err)
+	}
+	f, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("matchspec: run cache: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("matchspec: run cache: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("matchspec: run cache: %w", err)
+	}
+	if err := os.Rename(f.Name(), c.path(key)); err != nil {
+		return fmt.Errorf("matchspec: run cache: %w", err)
+	}
+	return nil
+}
+
+// Erase removes the cached runs holding a result whose text matches
+// pattern (see EraseResult). The runs are dropped rather than rewritten,
+// since their hashes cover the results; they run again on the next miss.
+func (c *DirRunCache) Erase(pattern *regexp.Regexp) (int, error) {
+	files, err := filepath.Glob(filepath.Join(c.dir, "*.json"))
+	if err != nil {
+		return 0, fmt.Errorf("matchspec: run cache: %w", err)
+	}
+	n := 0
+	for _, file := range files {
+		cp, ok, err := c.Get(strings.TrimSuffix(filepath.Base(file), ".json"))
+		if err != nil {
+			return n, err
+		}
+		if !ok || !slices.ContainsFunc(cp.Results, func(res Result) bool { return EraseResult(&res, pattern) }) {
+			continue
+		}
+		if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return n, fmt.Errorf("matchspec: run cache: %w", err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// SuiteHash returns the hex SHA-256 of the suite's JSON encoding, which
+// covers its tasks, matchers, and SLOs but not its generator or owner.
+func SuiteHash(s *Suite) string {
+	c := *s
+	c.Owner = nil
+	data, _ := json.Marshal(&c)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// RunCacheKey returns the cache key of run against suite s with inference
+// options opts: a hash of the suite's content, the run's task filter and
+// tags, and the model and parameters. A seeded generator contributes its
+// seed and exported fields. It returns "" if the suite's tasks are not
+// reproducible, because it has a generator without a seed.
+func RunCacheKey(s *Suite, run protocol.EvalRun, opts InferOptions) string {
+	var seed int64
+	if s.Generator != nil {
+		sg, ok := s.Generator.(SeededGenerator)
+		if !ok {
+			return ""
+		}
+		seed = sg.Seed()
+	}
+	data, _ := json.Marshal(struct {
+		Suite     string            `json:"suite"`
+		Generator TaskGenerator     `json:"generator,omitempty"`
+		Seed      int64             `json:"seed,omitempty"`
+		Tasks     []string          `json:"tasks,omitempty"`
+		Tags      map[string]string `json:"tags,omitempty"`
+		Options   InferOptions      `json:"options"`
+	}{SuiteHash(s), s.Generator, seed, run.Tasks, run.Tags, opts})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// RunCached is Run with a cache of green runs. If cache holds a run with
+// the same key (see RunCacheKey) and force is false, the run is skipped:
+// its results are sent to the sinks again, its record is imported, and
+// cached is true. Otherwise the suite runs, and a run with no failures,
+// errors, or SLO violations replaces the cached entry.
+func (r *Runner) RunCached(ctx context.Context, run protocol.EvalRun, cache RunCache, force bool) (results []Result, cached bool, err error) {
+	suite, ok := r.registry.Get(run.Suite)
+	if !ok {
+		return nil, false, fmt.Errorf("matchspec: unknown suite %q", run.Suite)
+	}
+	opts, ok := InferOptionsFrom(ctx)
+	if !ok {
+		opts.Model = run.Tags["model"]
+	}
+	key := r.varsCacheKey(RunCacheKey(suite, run, opts))
+	if n := RunRepeatsFrom(ctx); n > 1 && key != "" {
+		key = repeatCacheKey(key, n)
+	}
+
+	if key != "" && !force {
+		cp, ok, err := cache.Get(key)
+		if err != nil {
+			return nil, false, err
+		}
+		if ok && cp.Complete {
+			if results, err := r.replay(ctx, cp, key); err == nil {
+				return results, true, nil
+			}
+		}
+	}
+
+	results, id, err := r.run(ctx, run)
+	if err != nil || key == "" {
+		return results, false, err
+	}
+	if sum := Summarize(results); sum.Failed > 0 || !SLOsPassed(suite.CheckLatency(results)) || !GatesPassed(suite.CheckGates(sum)) {
+		return results, false, nil
+	}
+	cp, err := r.ExportRun(id)
+	if err == nil {
+		err = cache.Put(key, cp)
+	}
+	return results, false, err
+}
+
+// replay re-reports a cached run: it imports the run's record and sends
+// its results to the sinks.
+func (r *Runner) replay(ctx context.Context, cp Checkpoint, key string) ([]Result, error) {
+	ctx, span := trace.Start(ctx, "matchspec.eval")
+	span.SetAttr("suite", cp.Run.Suite)
+	span.SetAttr("run_id", cp.Record.ID)
+	span.SetAttr("cache_key", key)
+	span.SetAttr("cache_hit", true)
+
+	if err := r.ImportRun(cp); err != nil && !errors.Is(err, ErrRunExists) {
+		span.SetAttr("error", err.Error())
+		span.End("error")
+		r.reporter.Report(ctx, span)
+		return nil, err
+	}
+	for _, res := range cp.Results {
+		r.emit(ctx, sinkRun{id: cp.Record.ID, suite: cp.Record.Suite, started: cp.Record.StartedAt, replayed: true}, res)
+	}
+	span.SetAttr("total", len(cp.Results))
+	span.SetAttr("cached_from", cp.Record.FinishedAt.Format(time.RFC3339))
+	span.End("ok")
+	r.reporter.Report(ctx, span)
+	return cp.Results, nil
+}
@@ -0,0 +1,85 @@
+package matchspec
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+)
+
+func TestRunCached(t *testing.T) {
+	ctx := context.Background()
+	cache := NewDirRunCache(t.TempDir())
+	var calls atomic.Int64
+	infer := func(ctx context.Context, prompt string) (string, error) {
+		calls.Add(1)
+		return echoInfer(ctx, prompt)
+	}
+	run := protocol.EvalRun{Suite: "math", Tags: map[string]string{"model": "m"}}
+
+	results, cached, err := testRunner(infer).RunCached(ctx, run, cache, false)
+	if err != nil || cached || len(results) != 2 || calls.Load() != 2 {
+		t.Fatalf("first run: %d results, cached=%v, err=%v, calls=%d", len(results), cached, err, calls.Load())
+	}
+
+	// A fresh runner (as in the next CI job) reports the cached results.
+	runner := testRunner(infer)
+	var emitted int
+	WithSink(ResultSinkFunc(func(context.Context, Result) error { emitted++; return nil }))(runner)
+	results, cached, err = runner.RunCached(ctx, run, cache, false)
+	if err != nil || !cached || len(results) != 2 || calls.Load() != 2 || emitted != 2 {
+		t.Fatalf("cached run: %d results, cached=%v, err=%v, calls=%d, emitted=%d", len(results), cached, err, calls.Load(), emitted)
+	}
+	if runs, _ := runner.Runs(RunFilter{}); len(runs) != 1 {
+		t.Errorf("runs = %d, want the imported record", len(runs))
+	}
+
+	if _, cached, _ = testRunner(infer).RunCached(ctx, run, cache, true); cached || calls.Load() != 4 {
+		t.Errorf("forced run: cached=%v, calls=%d", cached, calls.Load())
+	}
+
+	other := protocol.EvalRun{Suite: "math", Tags: map[string]string{"model": "other"}}
+	if _, cached, _ = testRunner(infer).RunCached(ctx, other, cache, false); cached {
+		t.Error("a different model should miss the cache")
+	}
+}
+
+func TestRunCachedSkipsFailingRuns(t *testing.T) {
+	ctx := context.Background()
+	cache := NewDirRunCache(t.TempDir())
+	run := protocol.EvalRun{Suite: "math"}
+	testRunner(failInfer).RunCached(ctx, run, cache, false)
+	if _, cached, _ := testRunner(echoInfer).RunCached(ctx, run, cache, false); cached {
+		t.Error("a failing run should not be cached")
+	}
+}
+
+func TestRunCacheKey(t *testing.T) {
+	s := &Suite{Name: "s", Tasks: []Task{{Name: "a", Prompt: "p", Expected: "x"}}}
+	run := protocol.EvalRun{Suite: "s"}
+	k := RunCacheKey(s, run, InferOptions{Model: "m"})
+	if k == "" || k != RunCacheKey(s, run, InferOptions{Model: "m"}) {
+		t.Fatalf("key %q is not stable", k)
+	}
+	if k == RunCacheKey(s, run, InferOptions{Model: "m", Params: map[string]any{"temperature": 0.2}}) {
+		t.Error("params should change the key")
+	}
+	s.Tasks[0].Expected = "y"
+	if k == RunCacheKey(s, run, InferOptions{Model: "m"}) {
+		t.Error("suite content should change the key")
+	}
+	s.Generator = NewArithmeticGenerator(5, 1)
+	k = RunCacheKey(s, run, InferOptions{})
+	if k == "" || k == RunCacheKey(&Suite{Name: "s", Tasks: s.Tasks, Generator: NewArithmeticGenerator(5, 2)}, run, InferOptions{}) {
+		t.Error("the generator seed should be part of the key")
+	}
+	s.Generator = unseededGenerator{}
+	if RunCacheKey(s, run, InferOptions{}) != "" {
+		t.Error("an unseeded generator should make the run uncacheable")
+	}
+}
+
+type unseededGenerator struct{}
+
+func (unseededGenerator) Generate(context.Context) ([]Task, error) { return nil, nil }
@@ -0,0 +1,180 @@
+package matchspec
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/greynewell/mist-go/trace"
+)
+
+// DefaultKappaThreshold is the minimum Cohen's kappa a calibration accepts
+// when CalibrationSet.Threshold is unset. 0.6 is the conventional boundary
+// of "substantial" agreement.
+const DefaultKappaThreshold = 0.6
+
+// CalibrationSet is a set of human-labeled responses used to check that a
+// judge-based matcher agrees with human graders.
+type CalibrationSet struct {
+	Name     string               `json:"name"`
+	Examples []CalibrationExample `json:"examples"`
+
+	// Threshold is the minimum acceptable kappa. Zero means
+	// DefaultKappaThreshold.
+	Threshold float64 `json:"threshold,omitempty"`
+}
+
+// CalibrationExample is one response with a human pass/fail label. Task
+// supplies the prompt, documents, and matcher the judge sees.
+type CalibrationExample struct {
+	Task     Task   `json:"task"`
+	Response string `json:"response"`
+	Label    bool   `json:"label"`
+}
+
+// CalibrationReport describes how well the matcher's verdicts agree with
+// the human labels.
+type CalibrationReport struct {
+	Name      string  `json:"name"`
+	Total     int     `json:"total"`
+	Agreed    int     `json:"agreed"`
+	Errors    int     `json:"errors"`
+	Agreement float64 `json:"agreement"`
+	Kappa     float64 `json:"kappa"`
+	Threshold float64 `json:"threshold"`
+
+	// Drifted is true when Kappa is below Threshold.
+	Drifted bool `json:"drifted"`
+
+	Disagreements []CalibrationDisagreement `json:"disagreements,omitempty"`
+}
+
+// CalibrationDisagreement records an example the matcher graded differently
+// from the human label, or could not grade.
+type CalibrationDisagreement struct {
+	Index   int    `json:"index"`
+	Task    string `json:"task"`
+	Label   bool   `json:"label"`
+	Verdict bool   `json:"verdict"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Validate checks that the calibration set is well-formed.
+func (c *CalibrationSet) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("matchspec: calibration set name is required")
+	}
+	if len(c.Examples) == 0 {
+		return fmt.Errorf("matchspec: calibration set %q has no examples", c.Name)
+	}
+	for i, e := range c.Examples {
+		if err := validateTask(c.Name, i, e.Task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadCalibrationSet reads a calibration set from a .yaml, .yml, or .json
+// file.
+func LoadCalibrationSet(path string) (*CalibrationSet, error) {
+	var c CalibrationSet
+	if err := loadConfigFile(path, "calibration", &c); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Calibrate grades every example with the runner's matchers, including the
+// judge, and compares the verdicts with the human labels using Cohen's
+// kappa. Examples the matcher fails to grade count as disagreements and are
+// excluded from the kappa computation.
+func (r *Runner) Calibrate(ctx context.Context, set *CalibrationSet) (*CalibrationReport, error) {
+	if err := set.Validate(); err != nil {
+		return nil, err
+	}
+	ctx, span := trace.Start(ctx, "matchspec.calibrate")
+	span.SetAttr("calibration", set.Name)
+
+	report := &CalibrationReport{Name: set.Name, Total: len(set.Examples), Threshold: set.Threshold}
+	if report.Threshold == 0 {
+		report.Threshold = DefaultKappaThreshold
+	}
+
+	var labels, verdicts []bool
+	for i, e := range set.Examples {
+		if err := ctx.Err(); err != nil {
+			span.End("error")
+			r.reporter.Report(context.WithoutCancel(ctx), span)
+			return nil, fmt.Errorf("matchspec: calibration %q: %w", set.Name, err)
+		}
+		task := e.Task
+		verdict, _, err := r.match(ctx, &task, e.Response)
+		if err != nil {
+			report.Errors++
+			report.Disagreements = append(report.Disagreements, CalibrationDisagreement{
+				Index: i, Task: task.Name, Label: e.Label, Error: r.redact(err.Error()),
+			})
+			continue
+		}
+		labels = append(labels, e.Label)
+		verdicts = append(verdicts, verdict)
+		if verdict == e.Label {
+			report.Agreed++
+		} else {
+			report.Disagreements = append(report.Disagreements, CalibrationDisagreement{
+				Index: i, Task: task.Name, Label: e.Label, Verdict: verdict,
+			})
+		}
+	}
+
+	if graded := len(labels); graded > 0 {
+		report.Agreement = float64(report.Agreed) / float64(graded)
+	}
+	report.Kappa = cohensKappa(labels, verdicts)
+	report.Drifted = report.Kappa < report.Threshold
+
+	span.SetAttr("kappa", report.Kappa)
+	span.SetAttr("agreement", report.Agreement)
+	span.SetAttr("drifted", report.Drifted)
+	if report.Drifted {
+		span.End("error")
+	} else {
+		span.End("ok")
+	}
+	r.reporter.Report(ctx, span)
+	return report, nil
+}
+
+// cohensKappa returns Cohen's kappa for two binary raters. When chance
+// agreement is total (both raters always give the same single answer) it
+// returns 1 if they agree and 0 otherwise.
+func cohensKappa(a, b []bool) float64 {
+	n := float64(len(a))
+	if n == 0 {
+		return 0
+	}
+	var agree, aYes, bYes float64
+	for i := range a {
+		if a[i] == b[i] {
+			agree++
+		}
+		if a[i] {
+			aYes++
+		}
+		if b[i] {
+			bYes++
+		}
+	}
+	po := agree / n
+	pe := (aYes/n)*(bYes/n) + (1-aYes/n)*(1-bYes/n)
+	if pe == 1 {
+		if po == 1 {
+			return 1
+		}
+		return 0
+	}
+	return (po - pe) / (1 - pe)
+}
@@ -0,0 +1,108 @@
+package matchspec
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestCohensKappa(t *testing.T) {
+	a := []bool{true, true, true, true, false, false, false, false, true, false}
+	b := []bool{true, true, true, false, false, false, false, true, true, false}
+	// po = 0.8, pe = 0.5*0.5 + 0.5*0.5 = 0.5, kappa = 0.6
+	if k := cohensKappa(a, b); math.Abs(k-0.6) > 1e-9 {
+		t.Errorf("kappa = %f, want 0.6", k)
+	}
+	if k := cohensKappa([]bool{true, true}, []bool{true, true}); k != 1 {
+		t.Errorf("constant agreement kappa = %f, want 1", k)
+	}
+	if k := cohensKappa(nil, nil); k != 0 {
+		t.Errorf("empty kappa = %f, want 0", k)
+	}
+}
+
+func calibrationRunner(judge InferFunc) *Runner {
+	return NewRunner(NewSuiteRegistry(), nil, tokentrace.NewReporter("matchspec", ""), WithJudge(judge))
+}
+
+func TestCalibrate(t *testing.T) {
+	// The judge says YES whenever the answer mentions Paris.
+	judge := func(_ context.Context, prompt string) (string, error) {
+		if strings.Contains(prompt, "Answer: Paris") {
+			return "YES", nil
+		}
+		return "NO", nil
+	}
+	task := Task{Name: "capital", Prompt: "Capital of France?", Documents: []string{"Paris is the capital of France."}, Matcher: "grounded"}
+	set := &CalibrationSet{Name: "grounding", Examples: []CalibrationExample{
+		{Task: task, Response: "Paris", Label: true},
+		{Task: task, Response: "Paris", Label: true},
+		{Task: task, Response: "Lyon", Label: false},
+		{Task: task, Response: "Lyon", Label: true},
+	}}
+
+	report, err := calibrationRunner(judge).Calibrate(context.Background(), set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Agreed != 3 || report.Agreement != 0.75 {
+		t.Errorf("agreed = %d (%f), want 3 (0.75)", report.Agreed, report.Agreement)
+	}
+	// po = 0.75, pe = 0.75*0.5 + 0.25*0.5 = 0.5, kappa = 0.5
+	if math.Abs(report.Kappa-0.5) > 1e-9 {
+		t.Errorf("kappa = %f, want 0.5", report.Kappa)
+	}
+	if !report.Drifted || report.Threshold != DefaultKappaThreshold {
+		t.Errorf("expected drift below default threshold: %+v", report)
+	}
+	if len(report.Disagreements) != 1 || report.Disagreements[0].Index != 3 {
+		t.Errorf("disagreements = %+v", report.Disagreements)
+	}
+
+	set.Threshold = 0.4
+	report, _ = calibrationRunner(judge).Calibrate(context.Background(), set)
+	if report.Drifted {
+		t.Error("kappa 0.5 should pass threshold 0.4")
+	}
+}
+
+func TestCalibrateJudgeErrors(t *testing.T) {
+	set := &CalibrationSet{Name: "g", Examples: []CalibrationExample{
+		{Task: Task{Name: "t", Prompt: "p", Matcher: "grounded"}, Response: "r", Label: true},
+	}}
+	report, err := calibrationRunner(nil).Calibrate(context.Background(), set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Errors != 1 || report.Disagreements[0].Error == "" || !report.Drifted {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestLoadCalibrationSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cal.yaml")
+	os.WriteFile(path, []byte(`name: grounding
+threshold: 0.7
+examples:
+  - task: {name: capital, prompt: "Capital of France?", matcher: grounded}
+    response: Paris
+    label: true
+`), 0o644)
+
+	set, err := LoadCalibrationSet(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.Threshold != 0.7 || len(set.Examples) != 1 || !set.Examples[0].Label || set.Examples[0].Task.Matcher != "grounded" {
+		t.Errorf("set = %+v", set)
+	}
+
+	if _, err := LoadCalibrationSet(filepath.Join(t.TempDir(), "cal.txt")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
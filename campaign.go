@@ -0,0 +1,175 @@
+package matchspec
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/trace"
+)
+
+// Campaign is a named set of (suite, model, params) combinations executed
+// together, such as a nightly sweep. Parallel is how many entries run at
+// once; zero or one runs them in order.
+type Campaign struct {
+	Name     string          `json:"name"`
+	Entries  []CampaignEntry `json:"entries"`
+	Parallel int             `json:"parallel,omitempty"`
+}
+
+// CampaignEntry is one suite run within a campaign. Model and Params are
+// passed to the inference function via WithInferOptions. PromptTemplate,
+// if set, wraps every task prompt; PromptPlaceholder marks where the
+// task prompt goes.
+type CampaignEntry struct {
+	Suite          string            `json:"suite"`
+	Model          string            `json:"model,omitempty"`
+	Params         map[string]any    `json:"params,omitempty"`
+	PromptTemplate string            `json:"prompt_template,omitempty"`
+	Tags           map[string]string `json:"tags,omitempty"`
+}
+
+// PromptPlaceholder marks where the task prompt is substituted into a
+// CampaignEntry.PromptTemplate.
+const PromptPlaceholder = "{{prompt}}"
+
+type promptTemplateKey struct{}
+
+// promptFor returns the task's rendered prompt, wrapped in the prompt
+// template carried by ctx, if any.
+func promptFor(ctx context.Context, t *Task) string {
+	prompt := t.RenderPrompt()
+	if tmpl, ok := ctx.Value(promptTemplateKey{}).(string); ok && tmpl != "" {
+		return strings.ReplaceAll(tmpl, PromptPlaceholder, prompt)
+	}
+	return prompt
+}
+
+// Validate checks that the campaign is well-formed.
+func (c *Campaign) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("matchspec: campaign name is required")
+	}
+	if len(c.Entries) == 0 {
+		return fmt.Errorf("matchspec: campaign %q has no entries", c.Name)
+	}
+	if c.Parallel < 0 {
+		return fmt.Errorf("matchspec: campaign %q has negative parallel %d", c.Name, c.Parallel)
+	}
+	for i, e := range c.Entries {
+		if e.Suite == "" {
+			return fmt.Errorf("matchspec: campaign %q entry[%d] has no suite", c.Name, i)
+		}
+	}
+	return nil
+}
+
+// LoadCampaign reads a campaign definition from a .yaml, .yml, or .json
+// file.
+func LoadCampaign(path string) (*Campaign, error) {
+	var c Campaign
+	if err := loadConfigFile(path, "campaign", &c); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// CampaignReport is the combined outcome of a campaign. ID identifies the
+// campaign run; every entry's run carries it in its "campaign_id" tag.
+type CampaignReport struct {
+	ID         string              `json:"id"`
+	Name       string              `json:"name"`
+	StartedAt  time.Time           `json:"started_at"`
+	FinishedAt time.Time           `json:"finished_at"`
+	Entries    []CampaignRunReport `json:"entries"`
+	Summary    Summary             `json:"summary"`
+}
+
+// CampaignRunReport is the outcome of one campaign entry.
+type CampaignRunReport struct {
+	CampaignEntry
+	RunID   string  `json:"run_id,omitempty"`
+	Summary Summary `json:"summary"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// Failed reports whether any entry errored or had failing tasks.
+func (r *CampaignReport) Failed() bool {
+	for _, e := range r.Entries {
+		if e.Error != "" || e.Summary.Failed > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// RunCampaign executes the entries of c, up to c.Parallel at a time, and
+// reports them in the order they are listed. An entry that fails to run is
+// recorded in the report and does not stop the campaign.
+func (r *Runner) RunCampaign(ctx context.Context, c *Campaign) (*CampaignReport, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	report := &CampaignReport{ID: trace.NewID(), Name: c.Name, StartedAt: time.Now()}
+	report.Entries = make([]CampaignRunReport, len(c.Entries))
+	results := make([][]Result, len(c.Entries))
+	sem := make(chan struct{}, max(c.Parallel, 1))
+	var wg sync.WaitGroup
+	var stopped error
+	for i, e := range c.Entries {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if stopped = ctx.Err(); stopped != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			report.Entries[i], results[i] = r.runCampaignEntry(ctx, c.Name, report.ID, e)
+		}()
+	}
+	wg.Wait()
+	if stopped != nil {
+		return nil, stopped
+	}
+
+	report.FinishedAt = time.Now()
+	report.Summary = Summarize(slices.Concat(results...))
+	return report, nil
+}
+
+// runCampaignEntry runs one entry of campaign name.
+func (r *Runner) runCampaignEntry(ctx context.Context, name, id string, e CampaignEntry) (CampaignRunReport, []Result) {
+	tags := map[string]string{"campaign": name, "campaign_id": id}
+	for k, v := range e.Tags {
+		tags[k] = v
+	}
+	if e.Model != "" {
+		tags["model"] = e.Model
+	}
+
+	ctx = WithInferOptions(ctx, InferOptions{Model: e.Model, Params: e.Params})
+	if e.PromptTemplate != "" {
+		ctx = context.WithValue(ctx, promptTemplateKey{}, e.PromptTemplate)
+	}
+	// Entries wait for a run slot: turning one away would leave the
+	// campaign half done.
+	ctx = context.WithValue(ctx, gateWaitKey{}, true)
+	results, runID, err := r.run(ctx, protocol.EvalRun{Suite: e.Suite, Tags: tags})
+
+	er := CampaignRunReport{CampaignEntry: e, RunID: runID, Summary: Summarize(results)}
+	if err != nil {
+		er.Error = r.redact(err.Error())
+	}
+	return er, results
+}
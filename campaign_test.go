@@ -0,0 +1,135 @@
+package matchspec
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestLoadCampaignYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nightly.yaml")
+	os.WriteFile(path, []byte(`name: nightly
+entries:
+  - suite: math
+    model: small
+    params:
+      temperature: 0.2
+  - suite: math
+    model: large
+`), 0o644)
+
+	c, err := LoadCampaign(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "nightly" || len(c.Entries) != 2 || c.Entries[0].Params["temperature"] != 0.2 {
+		t.Errorf("unexpected campaign: %+v", c)
+	}
+}
+
+func TestLoadCampaignInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.json")
+	os.WriteFile(path, []byte(`{"name": "x"}`), 0o644)
+	if _, err := LoadCampaign(path); err == nil {
+		t.Error("expected error for campaign without entries")
+	}
+}
+
+func TestRunnerRunCampaign(t *testing.T) {
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "math", Tasks: []Task{{Name: "add", Prompt: "1+1", Expected: "large"}}})
+
+	infer := func(ctx context.Context, prompt string) (string, error) {
+		opts, _ := InferOptionsFrom(ctx)
+		return opts.Model, nil
+	}
+	runner := NewRunner(reg, infer, tokentrace.NewReporter("matchspec", ""))
+
+	report, err := runner.RunCampaign(context.Background(), &Campaign{
+		Name: "nightly",
+		Entries: []CampaignEntry{
+			{Suite: "math", Model: "small"},
+			{Suite: "math", Model: "large"},
+			{Suite: "missing"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Entries) != 3 {
+		t.Fatalf("entries = %d, want 3", len(report.Entries))
+	}
+	if report.Entries[0].Summary.Passed != 0 || report.Entries[1].Summary.Passed != 1 {
+		t.Errorf("model was not routed per entry: %+v", report.Entries)
+	}
+	if report.Entries[1].RunID == "" || report.Entries[2].Error == "" {
+		t.Errorf("expected run ID and error: %+v", report.Entries)
+	}
+	if report.Summary.Total != 2 || !report.Failed() {
+		t.Errorf("unexpected combined summary: %+v", report.Summary)
+	}
+
+	runs, _ := runner.Runs(RunFilter{Model: "large"})
+	if len(runs) != 1 || runs[0].Tags["campaign"] != "nightly" {
+		t.Errorf("campaign runs should be tagged: %+v", runs)
+	}
+}
+
+func TestRunnerRunCampaignParallel(t *testing.T) {
+	reg := NewSuiteRegistry()
+	for _, name := range []string{"a", "b", "c"} {
+		reg.Register(&Suite{Name: name, Tasks: []Task{{Name: "t", Prompt: name, Expected: "ok"}}})
+	}
+	// Every suite blocks until all three are in flight at once.
+	var arrived sync.WaitGroup
+	arrived.Add(3)
+	infer := func(ctx context.Context, prompt string) (string, error) {
+		arrived.Done()
+		arrived.Wait()
+		return "ok", nil
+	}
+	runner := NewRunner(reg, infer, tokentrace.NewReporter("matchspec", ""))
+
+	done := make(chan *CampaignReport)
+	go func() {
+		report, err := runner.RunCampaign(context.Background(), &Campaign{
+			Name:     "nightly",
+			Parallel: 3,
+			Entries:  []CampaignEntry{{Suite: "a"}, {Suite: "b"}, {Suite: "c"}},
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		done <- report
+	}()
+	var report *CampaignReport
+	select {
+	case report = <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("entries did not run concurrently")
+	}
+
+	if report.ID == "" || report.Summary.Total != 3 || report.Summary.Passed != 3 {
+		t.Errorf("report = %+v", report)
+	}
+	for i, want := range []string{"a", "b", "c"} {
+		if report.Entries[i].Suite != want {
+			t.Errorf("entry %d = %q, want %q", i, report.Entries[i].Suite, want)
+		}
+	}
+	runs, _ := runner.Runs(RunFilter{})
+	for _, run := range runs {
+		if run.Tags["campaign_id"] != report.ID {
+			t.Errorf("run %s tags = %v", run.Suite, run.Tags)
+		}
+	}
+
+	if err := (&Campaign{Name: "x", Entries: []CampaignEntry{{Suite: "a"}}, Parallel: -1}).Validate(); err == nil {
+		t.Error("accepted negative parallel")
+	}
+}
@@ -0,0 +1,66 @@
+package matchspec
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// validateRegex checks that a "regex" task's pattern compiles and names
+// every group its Captures refer to.
+func validateRegex(suite string, t Task) error {
+	re, err := regexp.Compile(t.Expected)
+	if err != nil {
+		return fmt.Errorf("matchspec: suite %q task %q pattern: %w", suite, t.Name, err)
+	}
+	for name := range t.Captures {
+		if !slices.Contains(re.SubexpNames(), name) {
+			return fmt.Errorf("matchspec: suite %q task %q captures unknown group %q", suite, t.Name, name)
+		}
+	}
+	return nil
+}
+
+// matchRegex applies the pattern in Expected to the response. Without
+// Captures, it passes if the pattern matches. With Captures, it compares
+// each named group of the first match, trimmed of surrounding space, to
+// its expected value; the score is the fraction of groups that agree. Its
+// details hold the first match and its named groups.
+func matchRegex(t *Task, response string) (bool, float64, map[string]any) {
+	re, err := regexp.Compile(t.Expected)
+	if err != nil {
+		return false, 0.0, map[string]any{"error": err.Error()}
+	}
+	m := re.FindStringSubmatch(response)
+	if m == nil {
+		return false, 0.0, map[string]any{"matched": false}
+	}
+	details := map[string]any{"matched": true, "match": m[0]}
+	groups := make(map[string]any)
+	for i, name := range re.SubexpNames() {
+		if name != "" {
+			groups[name] = strings.TrimSpace(m[i])
+		}
+	}
+	if len(groups) > 0 {
+		details["groups"] = groups
+	}
+	if len(t.Captures) == 0 {
+		return true, 1.0, details
+	}
+	found := 0
+	var mismatched []string
+	for name, want := range t.Captures {
+		if i := re.SubexpIndex(name); i >= 0 && strings.TrimSpace(m[i]) == want {
+			found++
+		} else {
+			mismatched = append(mismatched, name)
+		}
+	}
+	if len(mismatched) > 0 {
+		slices.Sort(mismatched)
+		details["mismatched"] = mismatched
+	}
+	return found == len(t.Captures), float64(found) / float64(len(t.Captures)), details
+}
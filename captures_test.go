@@ -0,0 +1,56 @@
+package matchspec
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTaskMatchRegex(t *testing.T) {
+	task := Task{
+		Name:     "invoice",
+		Matcher:  "regex",
+		Expected: `(?m)^Invoice: (?P<id>\S+)\s*$[\s\S]*^Total: (?P<total>.+)$`,
+		Captures: map[string]string{"id": "INV-7", "total": "$42.00"},
+	}
+	tests := []struct {
+		response  string
+		wantPass  bool
+		wantScore float64
+	}{
+		{"Invoice: INV-7\nItems: 3\nTotal: $42.00 ", true, 1},
+		{"Invoice: INV-8\nTotal: $42.00", false, 0.5},
+		{"No invoice here.", false, 0},
+	}
+	for _, tt := range tests {
+		passed, score := task.Match(tt.response)
+		if passed != tt.wantPass || score != tt.wantScore {
+			t.Errorf("Match(%q) = %v, %f; want %v, %f", tt.response, passed, score, tt.wantPass, tt.wantScore)
+		}
+	}
+
+	bare := Task{Name: "bare", Matcher: "regex", Expected: `^\d{3}-\d{4}$`}
+	if passed, _ := bare.Match("555-1234"); !passed {
+		t.Error("pattern without captures did not match")
+	}
+}
+
+func TestValidateRegex(t *testing.T) {
+	tests := []struct {
+		task    Task
+		wantErr string
+	}{
+		{Task{Name: "ok", Prompt: "p", Matcher: "regex", Expected: `(?P<a>\d+)`, Captures: map[string]string{"a": "1"}}, ""},
+		{Task{Name: "bad", Prompt: "p", Matcher: "regex", Expected: `(`}, "pattern"},
+		{Task{Name: "group", Prompt: "p", Matcher: "regex", Expected: `(?P<a>\d+)`, Captures: map[string]string{"b": "1"}}, `unknown group "b"`},
+	}
+	for _, tt := range tests {
+		err := (&Suite{Name: "s", Tasks: []Task{tt.task}}).Validate()
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tt.task.Name, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: err = %v, want %q", tt.task.Name, err, tt.wantErr)
+		}
+	}
+}
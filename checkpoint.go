@@ -0,0 +1,372 @@
+package matchspec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/greynewell/mist-go/checkpoint"
+	"github.com/greynewell/mist-go/protocol"
+)
+
+// CheckpointVersion is the format version written by ExportRun.
+const CheckpointVersion = 1
+
+// Checkpoint is a portable snapshot of a run: the request that started it,
+// its record, and the results completed so far. Checkpoints move runs
+// between instances: ImportRun stores a checkpoint as-is and ResumeRun
+// finishes an incomplete one.
+type Checkpoint struct {
+	Version int              `json:"version"`
+	Run     protocol.EvalRun `json:"run"`
+	Record  RunRecord        `json:"record"`
+	Results []Result         `json:"results"`
+
+	// Complete is false for a run exported while in progress or one that
+	// stopped before all of its tasks ran.
+	Complete bool `json:"complete"`
+}
+
+var (
+	// ErrRunNotFound is returned by ExportRun for an unknown run ID.
+	ErrRunNotFound = errors.New("matchspec: run not found")
+
+	// ErrRunExists is returned when importing or resuming a run whose ID
+	// is already known to the runner.
+	ErrRunExists = errors.New("matchspec: run already exists")
+)
+
+// liveRun is a run in progress, tracked so it can be exported, watched,
+// and cancelled (see RunManager).
+type liveRun struct {
+	rec       RunRecord
+	results   []Result
+	progress  Progress
+	cancel    context.CancelCauseFunc
+	cancelled bool
+}
+
+// trackRun marks rec as in progress with results already completed; cancel
+// stops it. It reports false if a run with the same ID is already known.
+func (r *Runner) trackRun(rec RunRecord, results []Result, cancel context.CancelCauseFunc) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.knownRun(rec.ID) {
+		return false
+	}
+	if r.active == nil {
+		r.active = make(map[string]*liveRun)
+	}
+	r.active[rec.ID] = &liveRun{rec: rec, results: results, cancel: cancel}
+	delete(r.pending, rec.ID)
+	r.broadcast()
+	return true
+}
+
+// trackProgress updates the results and progress of an in-progress run.
+// Results are only ever appended, so the run shares its slice with the
+// tracker.
+func (r *Runner) trackProgress(id string, results []Result, p Progress) {
+	r.mu.Lock()
+	if live, ok := r.active[id]; ok {
+		live.results = results
+		live.progress = p
+		r.broadcast()
+	}
+	r.mu.Unlock()
+}
+
+// knownRun reports whether id names a recorded or in-progress run. The
+// caller must hold r.mu.
+func (r *Runner) knownRun(id string) bool {
+	if _, ok := r.active[id]; ok {
+		return true
+	}
+	return slices.ContainsFunc(r.runs, func(rec RunRecord) bool { return rec.ID == id })
+}
+
+// ExportRun returns a checkpoint of the run with the given ID, which may
+// still be in progress. Runs whose results were not retained, such as
+// streamed runs, cannot be exported.
+func (r *Runner) ExportRun(id string) (Checkpoint, error) {
+	r.mu.Lock()
+	if live, ok := r.active[id]; ok {
+		rec, results := live.rec, slices.Clone(live.results)
+		r.mu.Unlock()
+		rec.Summary = Summarize(results)
+		var err error
+		if rec.Hash, rec.Signature, err = digestResults(r.signingKey, results); err != nil {
+			return Checkpoint{}, err
+		}
+		return Checkpoint{Version: CheckpointVersion, Run: rec.run, Record: rec, Results: results}, nil
+	}
+	defer r.mu.Unlock()
+	for _, rec := range r.runs {
+		if rec.ID != id || rec.deleted() {
+			continue
+		}
+		if rec.count != rec.Summary.Total {
+			return Checkpoint{}, fmt.Errorf("matchspec: run %q did not retain its results", id)
+		}
+		return Checkpoint{
+			Version:  CheckpointVersion,
+			Run:      rec.run,
+			Record:   rec,
+			Results:  slices.Clone(r.results[rec.first : rec.first+rec.count]),
+			Complete: rec.Error == "",
+		}, nil
+	}
+	return Checkpoint{}, fmt.Errorf("%w: %q", ErrRunNotFound, id)
+}
+
+// validate checks that cp can be imported by r: a known version, results
+// matching the recorded hash, and, if r has a signing key and cp is
+// signed, a valid signature.
+func (cp *Checkpoint) validate(r *Runner) error {
+	if cp.Version != CheckpointVersion {
+		return fmt.Errorf("matchspec: unsupported checkpoint version %d", cp.Version)
+	}
+	if cp.Record.ID == "" {
+		return fmt.Errorf("matchspec: checkpoint has no run ID")
+	}
+	if cp.Run.Suite != cp.Record.Suite {
+		return fmt.Errorf("matchspec: checkpoint run is for suite %q but its record is for %q", cp.Run.Suite, cp.Record.Suite)
+	}
+	sig := cp.Record.Signature
+	if len(r.signingKey) == 0 {
+		sig = ""
+	}
+	if !VerifyResults(cp.Results, cp.Record.Hash, sig, r.signingKey) {
+		return fmt.Errorf("matchspec: checkpoint of run %q does not match its hash", cp.Record.ID)
+	}
+	return nil
+}
+
+// ImportRun stores the run in cp, with its results, as if it had run on
+// this instance. Incomplete runs are stored as they are; use ResumeRun to
+// finish them.
+func (r *Runner) ImportRun(cp Checkpoint) error {
+	if err := cp.validate(r); err != nil {
+		return err
+	}
+	rec := cp.Record
+	rec.run = cp.Run
+	rec.DeletedAt = time.Time{}
+	if !cp.Complete && rec.Error == "" {
+		rec.Error = "run incomplete"
+	}
+
+	r.storeMu.Lock()
+	defer r.storeMu.Unlock()
+	r.mu.Lock()
+	if r.knownRun(rec.ID) {
+		r.mu.Unlock()
+		return fmt.Errorf("%w: %q", ErrRunExists, rec.ID)
+	}
+	rec.first, rec.count = len(r.results), len(cp.Results)
+	r.results = append(r.results, cp.Results...)
+	r.runs = append(r.runs, rec)
+	var stored []Checkpoint
+	if r.store != nil {
+		stored = append(stored, r.storedRun(rec))
+	}
+	r.mu.Unlock()
+	return r.storeRuns(context.Background(), stored)
+}
+
+// ResumeRun continues the run in cp under the same run ID, running only the
+// tasks that have no result yet, and returns all of the run's results. The
+// suite must be registered with this runner.
+func (r *Runner) ResumeRun(ctx context.Context, cp Checkpoint) ([]Result, error) {
+	if err := cp.validate(r); err != nil {
+		return nil, err
+	}
+	results, _, err := r.execute(ctx, cp.Run, &cp)
+	return results, err
+}
+
+// remainingTasks returns the tasks that have no result in done. Tasks are
+// matched by name, prompt variant, and repeat.
+func remainingTasks(tasks []Task, done []Result) []Task {
+	type key struct {
+		task, variant string
+		repeat        int
+	}
+	seen := make(map[key]bool, len(done))
+	for _, res := range done {
+		seen[key{res.Task, res.Variant, res.Repeat}] = true
+	}
+	return slices.DeleteFunc(slices.Clone(tasks), func(t Task) bool {
+		return seen[key{t.Name, t.variant, t.repeat}]
+	})
+}
+
+// WriteCheckpoint writes cp to w as JSON.
+func WriteCheckpoint(w io.Writer, cp Checkpoint) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(cp)
+}
+
+// ReadCheckpoint reads a checkpoint written by WriteCheckpoint, which may
+// have been gzipped since.
+func ReadCheckpoint(r io.Reader) (Checkpoint, error) {
+	r, err := DecompressReader(r)
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("matchspec: read checkpoint: %w", err)
+	}
+	var cp Checkpoint
+	if err := json.NewDecoder(r).Decode(&cp); err != nil {
+		return Checkpoint{}, fmt.Errorf("matchspec: read checkpoint: %w", err)
+	}
+	return cp, nil
+}
+
+// CheckpointSink journals each result of a run to a directory as its task
+// completes, so a run killed before it could write a Checkpoint, such as
+// by a crash or SIGKILL, loses no finished tasks: Recover rebuilds its
+// checkpoint for ResumeRun. Each run is a mist-go checkpoint log named by
+// its run ID, removed once the run is recorded.
+type CheckpointSink struct {
+	dir string
+
+	mu   sync.Mutex
+	runs map[string]*checkpoint.Tracker
+}
+
+// NewCheckpointSink creates a sink journaling runs to dir.
+func NewCheckpointSink(dir string) *CheckpointSink {
+	return &CheckpointSink{dir: dir, runs: make(map[string]*checkpoint.Tracker)}
+}
+
+// Journal steps: the run comes first, then one step per result, numbered
+// in the order the results completed.
+const (
+	journalRunStep    = "run"
+	journalResultStep = "result-"
+)
+
+// Write journals the result under its run. Results replayed from a cache
+// and runs without an ID are not journaled.
+func (s *CheckpointSink) Write(ctx context.Context, result Result) error {
+	run, _ := ctx.Value(sinkRunKey{}).(sinkRun)
+	if run.id == "" || run.replayed {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.runs[run.id]
+	if !ok {
+		var err error
+		if t, err = checkpoint.Open(s.dir, run.id); err != nil {
+			return fmt.Errorf("matchspec: journal run %q: %w", run.id, err)
+		}
+		s.runs[run.id] = t
+		req := run.run
+		if req.Suite == "" {
+			req.Suite = run.suite
+		}
+		rec := RunRecord{ID: run.id, Suite: run.suite, Model: req.Tags["model"], Tags: req.Tags, Labels: run.labels, StartedAt: run.started}
+		err = t.Step(ctx, journalRunStep, func(context.Context) (any, error) {
+			return Checkpoint{Version: CheckpointVersion, Run: req, Record: rec}, nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	// The run's step counts too, so results are numbered from 1, after
+	// any journaled before a restart.
+	step := fmt.Sprintf("%s%06d", journalResultStep, len(t.CompletedSteps()))
+	return t.Step(ctx, step, func(context.Context) (any, error) { return result, nil })
+}
+
+// RunCompleted removes the run's journal: the run is recorded, and a
+// Checkpoint of it can be exported instead.
+func (s *CheckpointSink) RunCompleted(ctx context.Context, rec RunRecord, results []Result) error {
+	s.mu.Lock()
+	t, ok := s.runs[rec.ID]
+	delete(s.runs, rec.ID)
+	s.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	t.Close()
+	if err := t.Reset(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// RegressionDetected does nothing.
+func (s *CheckpointSink) RegressionDetected(context.Context, RunRecord, []Result, []DriftAlert) error {
+	return nil
+}
+
+// Recover returns an incomplete checkpoint of every run journaled in the
+// sink's directory, such as runs killed before they were recorded, sorted
+// by run ID.
+func (s *CheckpointSink) Recover() ([]Checkpoint, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	var cps []Checkpoint
+	for _, path := range paths {
+		cp, err := recoverRun(s.dir, strings.TrimSuffix(filepath.Base(path), ".jsonl"))
+		if err != nil {
+			return nil, err
+		}
+		cps = append(cps, cp)
+	}
+	return cps, nil
+}
+
+// recoverRun rebuilds the checkpoint of the run journaled as id in dir.
+func recoverRun(dir, id string) (Checkpoint, error) {
+	t, err := checkpoint.Open(dir, id)
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("matchspec: recover run %q: %w", id, err)
+	}
+	defer t.Close()
+	var cp Checkpoint
+	if err := decodeStep(t.Result(journalRunStep), &cp); err != nil || cp.Record.ID != id {
+		return Checkpoint{}, fmt.Errorf("matchspec: recover run %q: journal has no run", id)
+	}
+	steps := slices.DeleteFunc(t.CompletedSteps(), func(step string) bool {
+		return !strings.HasPrefix(step, journalResultStep)
+	})
+	slices.Sort(steps)
+	for _, step := range steps {
+		var res Result
+		if err := decodeStep(t.Result(step), &res); err != nil {
+			return Checkpoint{}, fmt.Errorf("matchspec: recover run %q: %s: %w", id, step, err)
+		}
+		cp.Results = append(cp.Results, res)
+	}
+	cp.Record.Summary = Summarize(cp.Results)
+	if cp.Record.Hash, _, err = digestResults(nil, cp.Results); err != nil {
+		return Checkpoint{}, err
+	}
+	return cp, nil
+}
+
+// decodeStep decodes a journaled step result, which the checkpoint log
+// hands back as decoded JSON, into v.
+func decodeStep(result any, v any) error {
+	if result == nil {
+		return errors.New("no result")
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,131 @@
+package matchspec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+)
+
+func TestExportImportRun(t *testing.T) {
+	ctx := context.Background()
+	src := testRunner(echoInfer)
+	src.Run(ctx, protocol.EvalRun{Suite: "math", Tags: map[string]string{"model": "m"}})
+	runs, _ := src.Runs(RunFilter{})
+
+	cp, err := src.ExportRun(runs[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cp.Complete || len(cp.Results) != 2 || cp.Run.Suite != "math" {
+		t.Fatalf("checkpoint = %+v", cp)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCheckpoint(&buf, cp); err != nil {
+		t.Fatal(err)
+	}
+	cp, err = ReadCheckpoint(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := testRunner(failInfer)
+	if err := dst.ImportRun(cp); err != nil {
+		t.Fatal(err)
+	}
+	rec, ok := dst.GetRun(runs[0].ID)
+	if !ok || rec.Model != "m" || rec.Hash != runs[0].Hash || len(dst.Results()) != 2 {
+		t.Fatalf("imported run = %+v, %d results", rec, len(dst.Results()))
+	}
+	if err := dst.ImportRun(cp); !errors.Is(err, ErrRunExists) {
+		t.Errorf("second import err = %v, want ErrRunExists", err)
+	}
+
+	cp.Results[0].Passed = false
+	if err := testRunner(echoInfer).ImportRun(cp); err == nil {
+		t.Error("importing a tampered checkpoint should fail")
+	}
+	if _, err := src.ExportRun("missing"); !errors.Is(err, ErrRunNotFound) {
+		t.Errorf("err = %v, want ErrRunNotFound", err)
+	}
+}
+
+func TestResumeInProgressRun(t *testing.T) {
+	var src *Runner
+	var inFlight Checkpoint
+	src = testRunner(func(ctx context.Context, prompt string) (string, error) {
+		if prompt == "2*3" {
+			src.mu.Lock()
+			var id string
+			for id = range src.active {
+			}
+			src.mu.Unlock()
+			inFlight, _ = src.ExportRun(id)
+		}
+		return echoInfer(ctx, prompt)
+	})
+	if _, err := src.Run(context.Background(), protocol.EvalRun{Suite: "math"}); err != nil {
+		t.Fatal(err)
+	}
+	if inFlight.Complete || len(inFlight.Results) != 1 || inFlight.Results[0].Task != "add" {
+		t.Fatalf("in-flight checkpoint = %+v", inFlight)
+	}
+
+	var calls []string
+	dst := testRunner(func(ctx context.Context, prompt string) (string, error) {
+		calls = append(calls, prompt)
+		return echoInfer(ctx, prompt)
+	})
+	results, err := dst.ResumeRun(context.Background(), inFlight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 1 || calls[0] != "2*3" || len(results) != 2 {
+		t.Fatalf("calls = %v, results = %d", calls, len(results))
+	}
+	runs, _ := dst.Runs(RunFilter{})
+	if len(runs) != 1 || runs[0].ID != inFlight.Record.ID || runs[0].Summary.Passed != 2 || runs[0].Error != "" {
+		t.Fatalf("runs = %+v", runs)
+	}
+	if _, err := dst.ResumeRun(context.Background(), inFlight); !errors.Is(err, ErrRunExists) {
+		t.Errorf("second resume err = %v, want ErrRunExists", err)
+	}
+}
+
+func TestCheckpointSinkRecoversKilledRun(t *testing.T) {
+	dir := t.TempDir()
+	ctx, kill := context.WithCancel(context.Background())
+	defer kill()
+	src := testRunner(func(ctx context.Context, prompt string) (string, error) {
+		if prompt == "2*3" {
+			kill()
+			return "", ctx.Err()
+		}
+		return echoInfer(ctx, prompt)
+	})
+	// A process killed mid-run never records it, so its journal stays.
+	journal := NewCheckpointSink(dir)
+	WithSink(ResultSinkFunc(journal.Write))(src)
+	src.Run(ctx, protocol.EvalRun{Suite: "math", Tags: map[string]string{"model": "m"}})
+
+	cps, err := NewCheckpointSink(dir).Recover()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cps) != 1 || cps[0].Complete || cps[0].Run.Tags["model"] != "m" || len(cps[0].Results) != 1 || cps[0].Results[0].Task != "add" {
+		t.Fatalf("recovered = %+v", cps)
+	}
+
+	dst := testRunner(echoInfer)
+	WithSink(NewCheckpointSink(dir))(dst)
+	results, err := dst.ResumeRun(context.Background(), cps[0])
+	if err != nil || len(results) != 2 {
+		t.Fatalf("resume = %d results, %v", len(results), err)
+	}
+	if cps, err := NewCheckpointSink(dir).Recover(); err != nil || len(cps) != 0 {
+		t.Errorf("journal after the run was recorded = %+v, %v", cps, err)
+	}
+}
@@ -1,44 +1,1929 @@
 package main
 
 import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/greynewell/matchspec"
 	"github.com/greynewell/mist-go/cli"
+	"github.com/greynewell/mist-go/health"
+	"github.com/greynewell/mist-go/output"
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/retry"
+	"github.com/greynewell/mist-go/tokentrace"
 )
 
+// version is reported by --version and the health endpoints.
+const version = "0.1.0"
+
 func main() {
-	app := cli.NewApp("matchspec", "0.1.0")
+	app := cli.NewApp("matchspec", version)
 
 	eval := &cli.Command{
 		Name:  "eval",
 		Usage: "Run an evaluation suite",
 	}
-	eval.AddStringFlag("suite", "", "Suite name to evaluate")
-	eval.AddStringFlag("config", "matchspec.yaml", "Config file path")
+	eval.AddStringFlag("suite", "", "Suite name to evaluate (builtin/arithmetic, builtin/extraction, builtin/formatting)")
+	eval.AddStringFlag("labels", "", "Comma-separated labels recorded on the run (e.g. pr-1234,nightly)")
+	eval.AddStringFlag("config", defaultConfig, "Config file listing suite files and directories")
 	eval.AddIntFlag("samples", 0, "Limit number of samples (0 = all)")
+	eval.AddStringFlag("infer-url", "http://localhost:8081", "InferMux base URL")
+	addTransportFlags(eval)
+	eval.AddStringFlag("model", "auto", "Model name sent to InferMux")
+	eval.AddStringFlag("tokentrace-url", "", "TokenTrace base URL for span reporting")
+	eval.AddBoolFlag("ndjson", false, "Stream results to stdout as NDJSON instead of a table")
+	eval.AddIntFlag("warmup", 0, "Unmeasured warm-up inferences before the suite")
+	eval.AddIntFlag("repeats", 1, "Execute each task this many times and report pass@k and flaky tasks")
+	eval.AddStringFlag("jsonl", "", "Stream tasks from a JSONL file instead of loading the suite (results go to stdout as NDJSON)")
+	eval.AddIntFlag("workers", 1, "Concurrent tasks when streaming with --jsonl")
+	eval.AddIntFlag("retries", 0, "Retries per task for rate-limited or failing backend calls")
+	eval.AddIntFlag("retry-budget", 0, "Total retries allowed across the run (0 = no run-wide cap)")
+	eval.AddIntFlag("seed", 0, "Send each task a sampling seed derived from this seed and record it in results (0 = no seed)")
+	eval.AddStringFlag("reproduce", "", "Run the task execution recorded in this result JSON file again")
+	eval.AddStringFlag("changed", "", "Run only tasks affected by the files in this list or diff (git diff [--name-only] output; - for stdin)")
+	eval.AddStringFlag("cache-dir", "", "Skip the run and report cached results if nothing changed since the last green run")
+	eval.AddBoolFlag("force", false, "Run even if --cache-dir holds results for an unchanged run")
+	eval.AddStringFlag("cache", "", "Cache inference responses in this directory by model, prompt, and parameters, and reuse them instead of calling the backend")
+	eval.AddStringFlag("checkpoint", "", "Write a checkpoint of the run to this file when it finishes or is interrupted (gzipped if it ends in .gz); until then, results are journaled to <file>.journal")
+	eval.AddBoolFlag("verbose", false, "Record matcher details such as diffs on results and print them after the table")
+	eval.AddStringFlag("resume", "", "Resume the run in this checkpoint file, or in its journal if the run was killed before writing it, skipping tasks it already completed")
+	eval.AddStringFlag("env", "", "Render task templates with the variables of this environment (e.g. dev, staging, prod)")
+	eval.AddStringFlag("env-dir", "env", "Directory holding <env>.yaml, .yml, or .json variable files")
+	eval.AddStringFlag("deadline", "", "Cancel the run after this duration (e.g. 30m) and warn as soon as it is projected to overrun")
+	eval.AddBoolFlag("progress", false, "Print progress and ETA to stderr after each task")
+	eval.AddStringFlag("shadow-url", "", "Also send every task to this candidate InferMux backend and report the comparison (does not gate the run)")
+	eval.AddStringFlag("shadow-model", "", "Model name sent to the shadow backend (default: --model)")
+	eval.AddBoolFlag("update-golden", false, "Rewrite the expected outputs of failing exact, diff, and expected_file tasks from their responses, printing a review diff")
+	eval.AddStringFlag("pin-model", "", "Fail unless the backend reports serving this model version (a glob; overrides the config's model_pin)")
+	eval.AddStringFlag("snapshot-dir", "snapshots", "Directory holding the accepted responses that snapshot tasks are compared with")
+	eval.AddStringFlag("encryption-key-env", "", "Encrypt cached responses, cached runs, and snapshots with the base64 AES key in this environment variable")
+	eval.AddBoolFlag("read-plaintext", false, "Also read files written before encryption was enabled, encrypting them as they are rewritten")
+	eval.AddBoolFlag("compress", false, "Gzip cached responses, cached runs, snapshots, and baselines (compressed files are always readable)")
+	eval.AddBoolFlag("update-snapshots", false, "Accept every snapshot task's response as its new baseline")
+	eval.AddStringFlag("baseline-dir", "baselines", "Directory holding the suite baselines runs are compared with (see baseline record)")
+	eval.AddFloat64Flag("max-score-drop", matchspec.DefaultMaxScoreDrop, "Flag tasks whose score falls more than this below the baseline")
+	eval.AddBoolFlag("fail-on-regression", false, "Exit non-zero if any task regressed against the baseline")
+	eval.AddStringFlag("share", "", "Write an anonymized sharing bundle of the run to this file, redacted per the config's share section")
+	eval.AddStringFlag("curve", matchspec.DefaultScoringCurve, "Scoring curve weighting tasks by difficulty (uniform, linear, quadratic, exponential)")
 	eval.Run = func(cmd *cli.Command, args []string) error {
+		if path := cmd.GetString("reproduce"); path != "" {
+			return reproduceEval(cmd, path)
+		}
+
+		var resume *matchspec.Checkpoint
+		if path := cmd.GetString("resume"); path != "" {
+			cp, err := resumeCheckpoint(path)
+			if err != nil {
+				return err
+			}
+			resume = &cp
+		}
+
 		suite := cmd.GetString("suite")
+		if suite == "" && resume != nil {
+			suite = resume.Run.Suite
+		}
 		if suite == "" {
 			return fmt.Errorf("--suite is required")
 		}
-		fmt.Printf("Running suite %q with config=%s\n", suite, cmd.GetString("config"))
+
+		if path := cmd.GetString("jsonl"); path != "" {
+			return streamEval(cmd, suite, path)
+		}
+
+		reg, s, err := loadSuite(cmd.GetString("config"), suite)
+		if err != nil {
+			return err
+		}
+
+		run := protocol.EvalRun{
+			Suite:    suite,
+			InferURL: cmd.GetString("infer-url"),
+			Tags:     map[string]string{"model": cmd.GetString("model")},
+		}
+		if n := cmd.GetInt("samples"); n > 0 && n < len(s.Tasks) {
+			for _, t := range s.Tasks[:n] {
+				run.Tasks = append(run.Tasks, t.Name)
+			}
+		}
+		if path := cmd.GetString("changed"); path != "" {
+			changed, err := readChangedFiles(path)
+			if err != nil {
+				return err
+			}
+			affected, all := s.Affected(changed)
+			if !all {
+				if len(run.Tasks) > 0 {
+					affected = slices.DeleteFunc(affected, func(t string) bool { return !slices.Contains(run.Tasks, t) })
+				}
+				if len(affected) == 0 {
+					fmt.Printf("no tasks in suite %q affected by %d changed files\n", suite, len(changed))
+					return nil
+				}
+				run.Tasks = affected
+			}
+		}
+
+		reporter := tokentrace.NewReporter(protocol.SourceMatchSpec, cmd.GetString("tokentrace-url"))
+		infer, err := inferMux(cmd, run.InferURL, cmd.GetString("model"))
+		if err != nil {
+			return err
+		}
+		envOpts, err := envOptions(cmd)
+		if err != nil {
+			return err
+		}
+		notifyOpts, err := configOptions(cmd.GetString("config"))
+		if err != nil {
+			return err
+		}
+		opts := append(runnerOptions(cmd), envOpts...)
+		opts = append(opts, notifyOpts...)
+		opts = append(opts, matchspec.WithWarmup(cmd.GetInt("warmup")), progressOption(cmd.GetBool("progress")))
+		storeOpts, err := storeOptions(cmd)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, matchspec.WithSnapshots(matchspec.NewDirSnapshotStore(cmd.GetString("snapshot-dir"), storeOpts...)))
+		opts = append(opts, matchspec.WithBaselines(matchspec.NewDirBaselineStore(cmd.GetString("baseline-dir"), storeOpts...), cmd.GetFloat64("max-score-drop")))
+		if cmd.GetBool("update-snapshots") {
+			opts = append(opts, matchspec.WithSnapshotUpdate())
+		}
+		if dir := cmd.GetString("cache"); dir != "" {
+			opts = append(opts, matchspec.WithResponseCache(matchspec.NewDirResponseCache(dir, storeOpts...), cmd.GetString("infer-url")))
+		}
+		var share *matchspec.ShareRecorder
+		if cmd.GetString("share") != "" {
+			share = matchspec.NewShareRecorder()
+			opts = append(opts, matchspec.WithShareRecorder(share))
+		}
+		var golden *matchspec.GoldenRecorder
+		if cmd.GetBool("update-golden") {
+			golden = matchspec.NewGoldenRecorder()
+			opts = append(opts, matchspec.WithGoldenRecorder(golden))
+		}
+		pinOpt, err := modelPinOption(cmd, run.InferURL, cmd.GetString("model"))
+		if err != nil {
+			return err
+		}
+		if pinOpt != nil {
+			opts = append(opts, pinOpt)
+		}
+		if url := cmd.GetString("shadow-url"); url != "" {
+			model := cmd.GetString("shadow-model")
+			if model == "" {
+				model = cmd.GetString("model")
+			}
+			shadow, err := inferMux(cmd, url, model)
+			if err != nil {
+				return err
+			}
+			opts = append(opts, matchspec.WithShadow(url, shadow))
+		}
+		ndjson := cmd.GetBool("ndjson")
+		if ndjson {
+			opts = append(opts, matchspec.WithSink(matchspec.NewNDJSONSink(os.Stdout)))
+		}
+		if path := cmd.GetString("checkpoint"); path != "" {
+			opts = append(opts, matchspec.WithSink(matchspec.NewCheckpointSink(path+".journal")))
+		}
+		runner := matchspec.NewRunner(reg, infer, reporter, opts...)
+
+		ctx, stop := interruptContext()
+		defer stop()
+		if d := cmd.GetString("deadline"); d != "" {
+			timeout, err := time.ParseDuration(d)
+			if err != nil {
+				return fmt.Errorf("--deadline: %w", err)
+			}
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		labels, err := matchspec.ParseLabels(cmd.GetString("labels"))
+		if err != nil {
+			return fmt.Errorf("--labels: %w", err)
+		}
+		ctx = matchspec.WithRunLabels(ctx, labels...)
+		if n := cmd.GetInt("repeats"); n != 1 {
+			if err := matchspec.ValidateRepeats(n); err != nil {
+				return fmt.Errorf("--repeats: %w", err)
+			}
+			ctx = matchspec.WithRunRepeats(ctx, n)
+		}
+		var results []matchspec.Result
+		switch dir := cmd.GetString("cache-dir"); {
+		case resume != nil:
+			results, err = runner.ResumeRun(ctx, *resume)
+		case dir != "":
+			var cached bool
+			results, cached, err = runner.RunCached(ctx, run, matchspec.NewDirRunCache(dir, storeOpts...), cmd.GetBool("force"))
+			if cached {
+				fmt.Fprintln(os.Stderr, "suite, model, and parameters unchanged since the last green run; reporting cached results")
+			}
+		default:
+			results, err = runner.RunDetailed(ctx, run)
+		}
+		if path := cmd.GetString("checkpoint"); path != "" {
+			if cerr := writeCheckpointFile(runner, path); cerr != nil {
+				fmt.Fprintln(os.Stderr, cerr)
+			}
+		}
+		if err != nil && len(results) == 0 {
+			return err
+		}
+		if !ndjson {
+			printResults(results, cmd.GetString("curve"))
+		}
+		var regressed bool
+		if runs, _ := runner.Runs(matchspec.RunFilter{Limit: 1}); len(runs) > 0 {
+			for _, w := range runs[0].Warnings {
+				fmt.Fprintln(os.Stderr, "warning:", w)
+			}
+			if b := runs[0].Baseline; b != nil {
+				printBaseline(b)
+				regressed = b.Regressed
+			}
+			if share != nil {
+				if serr := writeShareBundle(cmd, share, runs[0], results); serr != nil {
+					return serr
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if golden != nil {
+			return updateGolden(s, golden.Updates())
+		}
+
+		checks := s.CheckLatency(results)
+		for _, c := range checks {
+			fmt.Fprintln(os.Stderr, c)
+		}
+		if !matchspec.SLOsPassed(checks) {
+			return fmt.Errorf("latency SLO violated")
+		}
+		gates := s.CheckGates(matchspec.Summarize(results))
+		for _, g := range gates {
+			fmt.Fprintln(os.Stderr, "gate", g)
+		}
+		if !matchspec.GatesPassed(gates) {
+			return fmt.Errorf("suite quality gates failed")
+		}
+		if regressed && cmd.GetBool("fail-on-regression") {
+			return fmt.Errorf("regressed against the baseline")
+		}
 		return nil
 	}
 	app.AddCommand(eval)
 
+	baseline := &cli.Command{
+		Name:  "baseline",
+		Usage: "Record a suite's baseline from a fresh run (baseline record) or print it (baseline show)",
+	}
+	baseline.AddStringFlag("suite", "", "Suite name")
+	baseline.AddStringFlag("config", defaultConfig, "Config file listing suite files and directories")
+	baseline.AddStringFlag("infer-url", "http://localhost:8081", "InferMux base URL")
+	addTransportFlags(baseline)
+	baseline.AddStringFlag("model", "auto", "Model name sent to InferMux")
+	baseline.AddStringFlag("tokentrace-url", "", "TokenTrace base URL for span reporting")
+	baseline.AddStringFlag("baseline-dir", "baselines", "Directory holding suite baselines")
+	baseline.AddStringFlag("encryption-key-env", "", "Encrypt baselines with the base64 AES key in this environment variable")
+	baseline.AddBoolFlag("read-plaintext", false, "Also read files written before encryption was enabled, encrypting them as they are rewritten")
+	baseline.Run = func(cmd *cli.Command, args []string) error {
+		if len(args) == 0 || args[0] != "record" && args[0] != "show" {
+			return fmt.Errorf("usage: matchspec baseline record|show --suite NAME")
+		}
+		// Flags after the action are not parsed with the command's.
+		if err := cmd.Flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		suite := cmd.GetString("suite")
+		if suite == "" {
+			return fmt.Errorf("--suite is required")
+		}
+		storeOpts, err := storeOptions(cmd)
+		if err != nil {
+			return err
+		}
+		store := matchspec.NewDirBaselineStore(cmd.GetString("baseline-dir"), storeOpts...)
+		if args[0] == "show" {
+			b, ok, err := store.Get(suite)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("no baseline for suite %q in %s", suite, cmd.GetString("baseline-dir"))
+			}
+			printBaselineTasks(b)
+			return nil
+		}
+
+		reg, _, err := loadSuite(cmd.GetString("config"), suite)
+		if err != nil {
+			return err
+		}
+		reporter := tokentrace.NewReporter(protocol.SourceMatchSpec, cmd.GetString("tokentrace-url"))
+		infer, err := inferMux(cmd, cmd.GetString("infer-url"), cmd.GetString("model"))
+		if err != nil {
+			return err
+		}
+		runner := matchspec.NewRunner(reg, infer, reporter, matchspec.WithBaselines(store, matchspec.DefaultMaxScoreDrop))
+		ctx, stop := interruptContext()
+		defer stop()
+		if _, err := runner.Run(ctx, protocol.EvalRun{Suite: suite, Tags: map[string]string{"model": cmd.GetString("model")}}); err != nil {
+			return err
+		}
+		runs, _ := runner.Runs(matchspec.RunFilter{Limit: 1})
+		if b := runs[0].Baseline; b != nil {
+			printBaseline(b)
+		}
+		b, err := runner.RecordBaseline(runs[0].ID)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("recorded baseline of suite %q from run %s: %d tasks, pass rate %.1f%%\n", suite, b.RunID, len(b.Tasks), b.PassRate*100)
+		return nil
+	}
+	app.AddCommand(baseline)
+
+	campaign := &cli.Command{
+		Name:  "campaign",
+		Usage: "Run a campaign of (suite, model, params) combinations from a YAML or JSON file",
+	}
+	campaign.AddStringFlag("file", "", "Campaign definition file")
+	campaign.AddStringFlag("config", defaultConfig, "Config file listing suite files and directories")
+	campaign.AddStringFlag("infer-url", "http://localhost:8081", "InferMux base URL")
+	addTransportFlags(campaign)
+	campaign.AddStringFlag("tokentrace-url", "", "TokenTrace base URL for span reporting")
+	campaign.AddIntFlag("parallel", 0, "Entries to run at once, overriding the campaign's parallel (0 keeps it)")
+	campaign.Run = func(cmd *cli.Command, args []string) error {
+		if cmd.GetString("file") == "" {
+			return fmt.Errorf("--file is required")
+		}
+		c, err := matchspec.LoadCampaign(cmd.GetString("file"))
+		if err != nil {
+			return err
+		}
+		if n := cmd.GetInt("parallel"); n > 0 {
+			c.Parallel = n
+		}
+
+		reg, err := suiteRegistry(cmd.GetString("config"))
+		if err != nil {
+			return err
+		}
+		reporter := tokentrace.NewReporter(protocol.SourceMatchSpec, cmd.GetString("tokentrace-url"))
+		infer, err := inferMux(cmd, cmd.GetString("infer-url"), "")
+		if err != nil {
+			return err
+		}
+		runner := matchspec.NewRunner(reg, infer, reporter)
+
+		report, err := runner.RunCampaign(context.Background(), c)
+		if err != nil {
+			return err
+		}
+		if err := output.New("json").JSON(report); err != nil {
+			return err
+		}
+		if report.Failed() {
+			return fmt.Errorf("campaign %q had failures", c.Name)
+		}
+		return nil
+	}
+	app.AddCommand(campaign)
+
+	matrix := &cli.Command{
+		Name:  "matrix",
+		Usage: "Run a suite over a grid of models, parameters, and prompt variants",
+	}
+	matrix.AddStringFlag("file", "", "Matrix definition file (YAML or JSON)")
+	matrix.AddStringFlag("config", defaultConfig, "Config file listing suite files and directories")
+	matrix.AddStringFlag("infer-url", "http://localhost:8081", "InferMux base URL")
+	addTransportFlags(matrix)
+	matrix.AddStringFlag("tokentrace-url", "", "TokenTrace base URL for span reporting")
+	matrix.Run = func(cmd *cli.Command, args []string) error {
+		if cmd.GetString("file") == "" {
+			return fmt.Errorf("--file is required")
+		}
+		m, err := matchspec.LoadMatrix(cmd.GetString("file"))
+		if err != nil {
+			return err
+		}
+		reg, _, err := loadSuite(cmd.GetString("config"), m.Suite)
+		if err != nil {
+			return err
+		}
+		reporter := tokentrace.NewReporter(protocol.SourceMatchSpec, cmd.GetString("tokentrace-url"))
+		infer, err := inferMux(cmd, cmd.GetString("infer-url"), "")
+		if err != nil {
+			return err
+		}
+		runner := matchspec.NewRunner(reg, infer, reporter)
+
+		report, err := runner.RunMatrix(context.Background(), m)
+		if err != nil {
+			return err
+		}
+		rows := make([][]string, 0, len(report.Cells))
+		for i, c := range report.Cells {
+			best := ""
+			if i == report.Best {
+				best = "*"
+			}
+			rows = append(rows, []string{
+				c.Model, c.Variant, fmt.Sprint(c.Params),
+				strconv.FormatFloat(c.Summary.PassRate, 'f', 3, 64),
+				strconv.FormatFloat(c.Summary.MeanScore, 'f', 3, 64),
+				best,
+			})
+		}
+		output.New("table").Table([]string{"MODEL", "VARIANT", "PARAMS", "PASS_RATE", "MEAN_SCORE", "BEST"}, rows)
+		return nil
+	}
+	app.AddCommand(matrix)
+
+	calibrate := &cli.Command{
+		Name:  "calibrate",
+		Usage: "Check a judge model's agreement with human-labeled examples",
+	}
+	calibrate.AddStringFlag("file", "", "Calibration set file (YAML or JSON)")
+	calibrate.AddStringFlag("judge-url", "http://localhost:8081", "InferMux base URL for the judge")
+	calibrate.AddStringFlag("judge-model", "auto", "Judge model name sent to InferMux")
+	calibrate.AddStringFlag("tokentrace-url", "", "TokenTrace base URL for span reporting")
+	calibrate.Run = func(cmd *cli.Command, args []string) error {
+		if cmd.GetString("file") == "" {
+			return fmt.Errorf("--file is required")
+		}
+		set, err := matchspec.LoadCalibrationSet(cmd.GetString("file"))
+		if err != nil {
+			return err
+		}
+		reporter := tokentrace.NewReporter(protocol.SourceMatchSpec, cmd.GetString("tokentrace-url"))
+		judge := matchspec.InferMuxFunc(cmd.GetString("judge-url"), cmd.GetString("judge-model"))
+		runner := matchspec.NewRunner(matchspec.NewSuiteRegistry(), nil, reporter, matchspec.WithJudge(judge))
+
+		report, err := runner.Calibrate(context.Background(), set)
+		if err != nil {
+			return err
+		}
+		if err := output.New("json").JSON(report); err != nil {
+			return err
+		}
+		if report.Drifted {
+			return fmt.Errorf("judge kappa %.3f is below threshold %.3f", report.Kappa, report.Threshold)
+		}
+		return nil
+	}
+	app.AddCommand(calibrate)
+
+	bench := &cli.Command{
+		Name:  "bench",
+		Usage: "Sweep concurrency against the backend and recommend runner settings",
+	}
+	bench.AddStringFlag("suite", "", "Suite whose prompts are replayed")
+	bench.AddStringFlag("config", defaultConfig, "Config file listing suite files and directories")
+	bench.AddStringFlag("levels", "1,2,4,8,16,32", "Comma-separated concurrency levels")
+	bench.AddIntFlag("requests", 20, "Requests per concurrency level")
+	bench.AddStringFlag("infer-url", "http://localhost:8081", "InferMux base URL")
+	addTransportFlags(bench)
+	bench.AddStringFlag("model", "auto", "Model name sent to InferMux")
+	bench.AddBoolFlag("write-config", false, "Write the recommended settings to the config's infer_limits, which eval, monitor, and serve apply")
+	bench.Run = func(cmd *cli.Command, args []string) error {
+		suite := cmd.GetString("suite")
+		if suite == "" {
+			return fmt.Errorf("--suite is required")
+		}
+		_, s, err := loadSuite(cmd.GetString("config"), suite)
+		if err != nil {
+			return err
+		}
+
+		var levels []int
+		for _, f := range strings.Split(cmd.GetString("levels"), ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(f))
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid --levels entry %q", f)
+			}
+			levels = append(levels, n)
+		}
+		prompts := make([]string, len(s.Tasks))
+		for i, t := range s.Tasks {
+			prompts[i] = t.RenderPrompt()
+		}
+
+		infer, err := inferMux(cmd, cmd.GetString("infer-url"), cmd.GetString("model"))
+		if err != nil {
+			return err
+		}
+		report, err := matchspec.SweepConcurrency(context.Background(), infer, prompts, matchspec.SweepConfig{
+			Levels:           levels,
+			RequestsPerLevel: cmd.GetInt("requests"),
+		})
+		if err != nil {
+			return err
+		}
+
+		rows := make([][]string, 0, len(report.Levels))
+		for _, l := range report.Levels {
+			rows = append(rows, []string{
+				strconv.Itoa(l.Concurrency),
+				strconv.FormatFloat(l.Throughput, 'f', 2, 64),
+				strconv.FormatFloat(l.LatencyP50MS, 'f', 0, 64),
+				strconv.FormatFloat(l.LatencyP95MS, 'f', 0, 64),
+				strconv.Itoa(l.Errors),
+			})
+		}
+		output.New("table").Table([]string{"CONCURRENCY", "RPS", "P50_MS", "P95_MS", "ERRORS"}, rows)
+		fmt.Printf("\nrecommended: concurrency=%d rate=%.1f rps\n", report.RecommendedConcurrency, report.RecommendedRPS)
+		if cmd.GetBool("write-config") {
+			if report.RecommendedConcurrency == 0 {
+				return fmt.Errorf("no level finished within the error budget; nothing to write")
+			}
+			path := cmd.GetString("config")
+			if err := matchspec.WriteConfigLimits(path, report.Limits()); err != nil {
+				return err
+			}
+			fmt.Printf("wrote infer_limits to %s\n", path)
+		}
+		return nil
+	}
+	app.AddCommand(bench)
+
+	load := &cli.Command{
+		Name:  "load",
+		Usage: "Replay a suite's prompts at a target rate to load-test the backend",
+	}
+	load.AddStringFlag("suite", "", "Suite whose prompts are replayed")
+	load.AddStringFlag("config", defaultConfig, "Config file listing suite files and directories")
+	load.AddFloat64Flag("rps", 10, "Target requests per second")
+	load.AddStringFlag("duration", "1m", "How long to sustain the load")
+	load.AddIntFlag("max-in-flight", 256, "Maximum concurrent requests; requests beyond it are dropped")
+	load.AddFloat64Flag("max-error-rate", 0, "Fail if the error rate exceeds this fraction (0 = no limit)")
+	load.AddStringFlag("infer-url", "http://localhost:8081", "InferMux base URL")
+	addTransportFlags(load)
+	load.AddStringFlag("model", "auto", "Model name sent to InferMux")
+	load.AddStringFlag("tokentrace-url", "", "TokenTrace base URL for span reporting")
+	load.Run = func(cmd *cli.Command, args []string) error {
+		suite := cmd.GetString("suite")
+		if suite == "" {
+			return fmt.Errorf("--suite is required")
+		}
+		duration, err := time.ParseDuration(cmd.GetString("duration"))
+		if err != nil {
+			return fmt.Errorf("--duration: %w", err)
+		}
+		reg, _, err := loadSuite(cmd.GetString("config"), suite)
+		if err != nil {
+			return err
+		}
+		infer, err := inferMux(cmd, cmd.GetString("infer-url"), cmd.GetString("model"))
+		if err != nil {
+			return err
+		}
+		reporter := tokentrace.NewReporter(protocol.SourceMatchSpec, cmd.GetString("tokentrace-url"))
+		runner := matchspec.NewRunner(reg, infer, reporter)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		report, err := runner.RunLoadTest(ctx, suite, matchspec.LoadTestConfig{
+			RPS:          cmd.GetFloat64("rps"),
+			Duration:     duration,
+			MaxInFlight:  cmd.GetInt("max-in-flight"),
+			MaxErrorRate: cmd.GetFloat64("max-error-rate"),
+		})
+		if report.Requests > 0 {
+			fmt.Printf("%d requests in %.1fs: %.1f rps (target %.1f), %d errors (%.2f%%), %d dropped\n",
+				report.Requests, float64(report.DurationMS)/1000, report.AchievedRPS, report.TargetRPS,
+				report.Errors, report.ErrorRate*100, report.Dropped)
+			fmt.Printf("latency p50=%.0fms p95=%.0fms p99=%.0fms\n", report.LatencyP50MS, report.LatencyP95MS, report.LatencyP99MS)
+			for _, c := range report.SLOs {
+				fmt.Println(c)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if !report.Passed {
+			return fmt.Errorf("load test on suite %q violated its SLOs", suite)
+		}
+		return nil
+	}
+	app.AddCommand(load)
+
+	monitor := &cli.Command{
+		Name:  "monitor",
+		Usage: "Run a suite on a schedule and alert when its results drift from their trailing baseline",
+	}
+	monitor.AddStringFlag("suite", "", "Suite to monitor")
+	monitor.AddStringFlag("config", defaultConfig, "Config file listing suite files and directories")
+	monitor.AddStringFlag("interval", "1h", "Time between runs")
+	monitor.AddStringFlag("labels", "", "Comma-separated labels recorded on each run (e.g. canary)")
+	monitor.AddIntFlag("window", matchspec.DefaultDriftWindow, "Preceding runs averaged into the baseline")
+	monitor.AddIntFlag("min-runs", matchspec.DefaultDriftMinRuns, "Runs needed before drift is checked")
+	monitor.AddFloat64Flag("max-pass-rate-delta", 0.1, "Alert when the pass rate moves more than this from the baseline (0 = off)")
+	monitor.AddFloat64Flag("max-score-delta", 0, "Alert when the mean score moves more than this from the baseline (0 = off)")
+	monitor.AddStringFlag("alert-url", "", "Webhook that alerts are POSTed to as JSON (alerts are always printed to stderr)")
+	monitor.AddStringFlag("snapshot-dir", "snapshots", "Directory holding the accepted responses that snapshot tasks are compared with")
+	monitor.AddStringFlag("encryption-key-env", "", "Encrypt snapshots with the base64 AES key in this environment variable")
+	monitor.AddBoolFlag("read-plaintext", false, "Also read files written before encryption was enabled, encrypting them as they are rewritten")
+	monitor.AddStringFlag("infer-url", "http://localhost:8081", "InferMux base URL")
+	addTransportFlags(monitor)
+	monitor.AddStringFlag("model", "auto", "Model name sent to InferMux")
+	monitor.AddStringFlag("tokentrace-url", "", "TokenTrace base URL for span reporting")
+	monitor.Run = func(cmd *cli.Command, args []string) error {
+		suite := cmd.GetString("suite")
+		if suite == "" {
+			return fmt.Errorf("--suite is required")
+		}
+		interval, err := time.ParseDuration(cmd.GetString("interval"))
+		if err != nil {
+			return fmt.Errorf("--interval: %w", err)
+		}
+		reg, _, err := loadSuite(cmd.GetString("config"), suite)
+		if err != nil {
+			return err
+		}
+		infer, err := inferMux(cmd, cmd.GetString("infer-url"), cmd.GetString("model"))
+		if err != nil {
+			return err
+		}
+		notifyOpts, err := configOptions(cmd.GetString("config"))
+		if err != nil {
+			return err
+		}
+		reporter := tokentrace.NewReporter(protocol.SourceMatchSpec, cmd.GetString("tokentrace-url"))
+		storeOpts, err := storeOptions(cmd)
+		if err != nil {
+			return err
+		}
+		runner := matchspec.NewRunner(reg, infer, reporter,
+			append(notifyOpts, matchspec.WithSnapshots(matchspec.NewDirSnapshotStore(cmd.GetString("snapshot-dir"), storeOpts...)))...)
+
+		alertURL := cmd.GetString("alert-url")
+		client := &http.Client{Timeout: 10 * time.Second}
+		alert := func(ctx context.Context, a matchspec.DriftAlert) {
+			fmt.Fprintln(os.Stderr, a)
+			if alertURL == "" {
+				return
+			}
+			if err := matchspec.PostDriftAlert(ctx, client, alertURL, a); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+
+		labels, err := matchspec.ParseLabels(cmd.GetString("labels"))
+		if err != nil {
+			return fmt.Errorf("--labels: %w", err)
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		ctx = matchspec.WithRunLabels(ctx, labels...)
+		run := protocol.EvalRun{Suite: suite, Tags: map[string]string{"model": cmd.GetString("model")}}
+		return runner.MonitorDrift(ctx, run, matchspec.DriftConfig{
+			Interval:         interval,
+			Window:           cmd.GetInt("window"),
+			MinRuns:          cmd.GetInt("min-runs"),
+			MaxPassRateDelta: cmd.GetFloat64("max-pass-rate-delta"),
+			MaxScoreDelta:    cmd.GetFloat64("max-score-delta"),
+		}, alert)
+	}
+	app.AddCommand(monitor)
+
+	watch := &cli.Command{
+		Name:  "watch",
+		Usage: "Follow a run on a matchspec server, printing each result as it completes",
+	}
+	watch.AddStringFlag("server", "http://localhost:8080", "matchspec server base URL")
+	watch.AddStringFlag("run", "", "Run ID (from POST /eval?async=true or GET /runs/active)")
+	watch.Run = func(cmd *cli.Command, args []string) error {
+		id := cmd.GetString("run")
+		if id == "" {
+			return fmt.Errorf("--run is required")
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return watchRun(ctx, strings.TrimRight(cmd.GetString("server"), "/")+"/runs/"+id+"/stream")
+	}
+	app.AddCommand(watch)
+
+	compare := &cli.Command{
+		Name:  "compare",
+		Usage: "Test whether run B is significantly better than run A (compare A.json B.json, from eval --checkpoint)",
+	}
+	compare.AddBoolFlag("json", false, "Print the comparison as JSON")
+	compare.AddBoolFlag("fail-if-worse", false, "Exit non-zero if B is significantly worse than A")
+	compare.Run = func(cmd *cli.Command, args []string) error {
+		if len(args) < 2 {
+			return fmt.Errorf("usage: matchspec compare A.json B.json")
+		}
+		// Flags after the checkpoints are not parsed with the command's.
+		if err := cmd.Flags.Parse(args[2:]); err != nil {
+			return err
+		}
+		var cps [2]matchspec.Checkpoint
+		for i, path := range args[:2] {
+			cp, err := readCheckpointFile(path)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			cps[i] = cp
+		}
+		if a, b := cps[0].Record.Suite, cps[1].Record.Suite; a != b {
+			return fmt.Errorf("%s is a run of suite %q and %s of %q", args[0], a, args[1], b)
+		}
+		c := matchspec.CompareRuns(cps[0].Results, cps[1].Results)
+		c.A, c.B = cps[0].Record.ID, cps[1].Record.ID
+		if cmd.GetBool("json") {
+			if err := output.New("json").JSON(c); err != nil {
+				return err
+			}
+		} else {
+			fmt.Println(c)
+		}
+		if c.Better == "a" && cmd.GetBool("fail-if-worse") {
+			return fmt.Errorf("run B is significantly worse than run A")
+		}
+		return nil
+	}
+	app.AddCommand(compare)
+
+	erase := &cli.Command{
+		Name:  "erase",
+		Usage: "Erase a data subject's text from on-disk caches and snapshots",
+	}
+	erase.AddStringFlag("text", "", "Erase entries containing this text, such as a user ID or e-mail address")
+	erase.AddStringFlag("pattern", "", "Erase entries matching this regular expression instead")
+	erase.AddStringFlag("cache", "", "Response cache directory (eval --cache)")
+	erase.AddStringFlag("cache-dir", "", "Run cache directory (eval --cache-dir)")
+	erase.AddStringFlag("snapshot-dir", "", "Snapshot directory (eval --snapshot-dir)")
+	erase.AddStringFlag("encryption-key-env", "", "Environment variable holding the key the stores are encrypted with")
+	erase.AddBoolFlag("read-plaintext", false, "Also read files written before encryption was enabled, encrypting them as they are rewritten")
+	erase.Run = func(cmd *cli.Command, args []string) error {
+		pattern, err := matchspec.ErasurePattern(cmd.GetString("text"), cmd.GetString("pattern"))
+		if err != nil {
+			return err
+		}
+		storeOpts, err := storeOptions(cmd)
+		if err != nil {
+			return err
+		}
+		var stores []matchspec.Eraser
+		if dir := cmd.GetString("cache"); dir != "" {
+			stores = append(stores, matchspec.NewDirResponseCache(dir, storeOpts...))
+		}
+		if dir := cmd.GetString("cache-dir"); dir != "" {
+			stores = append(stores, matchspec.NewDirRunCache(dir, storeOpts...))
+		}
+		if dir := cmd.GetString("snapshot-dir"); dir != "" {
+			stores = append(stores, matchspec.NewDirSnapshotStore(dir, storeOpts...))
+		}
+		if len(stores) == 0 {
+			return fmt.Errorf("set at least one of --cache, --cache-dir, or --snapshot-dir")
+		}
+		total := 0
+		for _, s := range stores {
+			n, err := s.Erase(pattern)
+			total += n
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "erased %d entries before failing\n", total)
+				return err
+			}
+		}
+		fmt.Printf("erased %d entries\n", total)
+		return nil
+	}
+	app.AddCommand(erase)
+
 	serve := &cli.Command{
 		Name:  "serve",
 		Usage: "Start the matchspec HTTP server",
 	}
 	serve.AddStringFlag("addr", ":8080", "Listen address")
+	serve.AddStringFlag("config", defaultConfig, "Config file listing suite files and directories")
+	serve.AddStringFlag("infer-url", "http://localhost:8081", "InferMux base URL")
+	addTransportFlags(serve)
+	serve.AddStringFlag("tokentrace-url", "", "TokenTrace base URL for span reporting")
+	serve.AddStringFlag("ws-origins", "", `Comma-separated origins of other sites whose pages may connect to GET /ws, such as "https://dash.example.com" ("*" for any)`)
+	serve.AddStringFlag("health-interval", "30s", "How often to health-check the inference backend for GET /backends")
+	serve.AddStringFlag("queue", "", `Job queue: "store" for the config's SQL result store, or a file; replicas sharing it split queued runs between them, and asynchronous runs are submitted to it`)
+	serve.AddBoolFlag("no-worker", false, "Accept jobs without running them on this replica")
+	serve.AddStringFlag("worker-id", "", "Worker ID for job leases (default: hostname)")
+	serve.AddStringFlag("audit-log", "", "Append-only audit log file of API actions, served at GET /admin/audit to admin API keys")
+	serve.AddBoolFlag("trust-forwarded-user", false, "Name audit log actors from X-Forwarded-User or X-Forwarded-Email; only behind a proxy that sets them")
+	serve.AddIntFlag("max-runs", 0, "Most runs executing at once (0 = no limit)")
+	serve.AddIntFlag("max-queued", 0, "Most runs waiting for a slot under --max-runs before requests get 429")
+	serve.AddIntFlag("max-queued-jobs", 0, "Most queued jobs before POST /jobs gets 429 (0 = no limit)")
+	serve.AddStringFlag("baseline-dir", "", "Directory of suite baselines to compare runs with, served at GET /baselines")
+	serve.AddFloat64Flag("max-score-drop", matchspec.DefaultMaxScoreDrop, "Flag tasks whose score falls more than this below the baseline")
+	serve.AddStringFlag("store-sync", "30s", "How often to pick up runs other replicas wrote to a shared result store (0 disables)")
+	serve.AddBoolFlag("check", false, "Load the config, suites, and storage, check that backends are reachable, print a report, and exit")
 	serve.Run = func(cmd *cli.Command, args []string) error {
-		fmt.Printf("matchspec server listening on %s\n", cmd.GetString("addr"))
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if cmd.GetBool("check") {
+			return checkServe(ctx, cmd)
+		}
+
+		reg, err := suiteRegistry(cmd.GetString("config"))
+		if err != nil {
+			return err
+		}
+		interval, err := time.ParseDuration(cmd.GetString("health-interval"))
+		if err != nil {
+			return fmt.Errorf("--health-interval: %w", err)
+		}
+		syncInterval, err := time.ParseDuration(cmd.GetString("store-sync"))
+		if err != nil {
+			return fmt.Errorf("--store-sync: %w", err)
+		}
+		inferURL := cmd.GetString("infer-url")
+		backends := matchspec.NewBackendMonitor(0)
+		backends.Register("infermux", matchspec.HTTPHealthCheck(strings.TrimRight(inferURL, "/")+"/healthz", nil))
+		go backends.Run(ctx, interval)
+
+		reporter := tokentrace.NewReporter(protocol.SourceMatchSpec, cmd.GetString("tokentrace-url"))
+		infer, err := inferMux(cmd, inferURL, "")
+		if err != nil {
+			return err
+		}
+		infer = backends.Wrap("infermux", infer)
+		notifyOpts, err := configOptions(cmd.GetString("config"))
+		if err != nil {
+			return err
+		}
+		store, err := resultStore(ctx, cmd.GetString("config"))
+		if err != nil {
+			return err
+		}
+		if store != nil {
+			notifyOpts = append(notifyOpts, matchspec.WithResultStore(store))
+		}
+		if dir := cmd.GetString("baseline-dir"); dir != "" {
+			notifyOpts = append(notifyOpts, matchspec.WithBaselines(matchspec.NewDirBaselineStore(dir), cmd.GetFloat64("max-score-drop")))
+		}
+		spool, _ := store.(*matchspec.SpoolResultStore)
+		if spool != nil {
+			backends.Register("result-store", spool.HealthCheck())
+			go spool.Run(ctx)
+		}
+		// Asynchronous runs go through the job queue so they survive a
+		// restart.
+		var q matchspec.JobQueue
+		if path := cmd.GetString("queue"); path != "" {
+			q, err = jobQueue(path, store, cmd.GetInt("max-queued-jobs"))
+			if err != nil {
+				return err
+			}
+			notifyOpts = append(notifyOpts, matchspec.WithStartQueue(q))
+		}
+		metrics := matchspec.NewMetrics()
+		events := matchspec.NewEventHub(0)
+		if origins := cmd.GetString("ws-origins"); origins != "" {
+			events.AllowOrigins(strings.Split(origins, ",")...)
+		}
+		runner := matchspec.NewRunner(reg, infer, reporter, append(notifyOpts, matchspec.WithMetrics(metrics), matchspec.WithEvents(events),
+			matchspec.WithRunLimit(cmd.GetInt("max-runs"), cmd.GetInt("max-queued")))...)
+
+		loaded, err := runner.LoadStore(ctx)
+		switch {
+		case err != nil && spool == nil:
+			return err
+		case err != nil:
+			// Runs are spooled until the store is back, so serve without
+			// the stored history and load it once the store answers.
+			fmt.Fprintln(os.Stderr, "result store unavailable, loading history in the background:", err)
+			go retryLoadStore(ctx, runner)
+		case loaded > 0:
+			fmt.Printf("loaded %d runs from the result store\n", loaded)
+		}
+		if store != nil && syncInterval > 0 {
+			go runner.SyncStore(ctx, syncInterval)
+		}
+		mux := newServeMux(matchspec.NewHandler(runner, reg))
+		probes := health.New("matchspec", version)
+		backends.AddTo(probes)
+		mux.Handle("GET /healthz", probes.Liveness())
+		mux.Handle("GET /readyz", probes.Readiness())
+		mux.Handle("GET /backends", backends)
+		mux.Handle("GET /metrics", metrics)
+		mux.Handle("GET /ws", events)
+
+		quotas, err := quotaLimiter(cmd.GetString("config"))
+		if err != nil {
+			return err
+		}
+
+		workerErr := make(chan error, 1)
+		var workerDone chan struct{}
+		if q != nil {
+			jh := matchspec.NewJobHandler(q)
+			mux.HandleFunc("POST /jobs", jh.Enqueue)
+			mux.HandleFunc("GET /jobs", jh.List)
+			mux.HandleFunc("GET /jobs/{id}", jh.Get)
+			mux.HandleFunc("DELETE /jobs/{id}", jh.Cancel)
+			mux.HandleFunc("POST /jobs/{id}/priority", jh.SetPriority)
+
+			if !cmd.GetBool("no-worker") {
+				id := cmd.GetString("worker-id")
+				if id == "" {
+					id, _ = os.Hostname()
+				}
+				workerDone = make(chan struct{})
+				go func() {
+					defer close(workerDone)
+					workerErr <- runner.ProcessJobs(ctx, q, matchspec.WorkerConfig{ID: id, Quotas: quotas})
+				}()
+			}
+		}
+
+		var handler http.Handler = mux
+		if path := cmd.GetString("audit-log"); path != "" {
+			audit, err := matchspec.OpenAuditLog(path)
+			if err != nil {
+				return err
+			}
+			if err := audit.RecordSuites(reg, "config:"+cmd.GetString("config")); err != nil {
+				return err
+			}
+			ah := matchspec.NewAuditHandler(audit)
+			mux.HandleFunc("GET /admin/audit", requireAdmin(quotas, ah.List))
+			mux.HandleFunc("GET /admin/audit/verify", requireAdmin(quotas, ah.Verify))
+			var actor matchspec.ActorFunc
+			if cmd.GetBool("trust-forwarded-user") {
+				actor = matchspec.ProxyActor
+			}
+			handler = audit.Middleware(actor, mux)
+		}
+
+		if quotas != nil {
+			mux.HandleFunc("GET /usage", quotas.UsageHandler)
+			handler = withQuotas(quotas, handler)
+		}
+
+		srv := &http.Server{Addr: cmd.GetString("addr"), Handler: handler}
+		go func() {
+			select {
+			case <-ctx.Done():
+			case err := <-workerErr:
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "job worker:", err)
+				}
+			}
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			srv.Shutdown(shutdownCtx)
+		}()
+
+		fmt.Printf("matchspec server listening on %s\n", srv.Addr)
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		if workerDone != nil {
+			// Let the worker hand its job back before exiting.
+			stop()
+			<-workerDone
+		}
 		return nil
 	}
 	app.AddCommand(serve)
 
 	if err := app.Execute(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
+
+// checkServe loads everything serve would, checks that the services it
+// talks to are reachable, and prints a report. It fails if any check does.
+func checkServe(ctx context.Context, cmd *cli.Command) error {
+	config := cmd.GetString("config")
+	var (
+		c      *matchspec.Config
+		loaded bool
+		client *http.Client
+	)
+	skipped := func(what string) error { return fmt.Errorf("%w: %s failed", matchspec.ErrPreflightSkipped, what) }
+	dial := func(addr string) (string, error) {
+		if err := matchspec.DialCheck(addr)(ctx); err != nil {
+			return "", err
+		}
+		return addr + " reachable", nil
+	}
+
+	checks := []matchspec.PreflightCheck{
+		{Name: "config", Check: func(ctx context.Context) (string, error) {
+			var err error
+			if c, err = loadConfig(config); err != nil {
+				return "", err
+			}
+			loaded = true
+			if c == nil {
+				return "no config file; built-in suites only", nil
+			}
+			return fmt.Sprintf("%s: %d suite paths, %d notifiers", config, len(c.Suites), len(c.Notifiers)), nil
+		}},
+		{Name: "suites", Check: func(ctx context.Context) (string, error) {
+			if !loaded {
+				return "", skipped("config")
+			}
+			reg, err := suiteRegistry(config)
+			if err != nil {
+				return "", err
+			}
+			tasks := 0
+			for _, name := range reg.Names() {
+				s, _ := reg.Get(name)
+				tasks += len(s.Tasks)
+			}
+			return fmt.Sprintf("%d suites, %d tasks", len(reg.Names()), tasks), nil
+		}},
+		{Name: "quotas", Check: func(ctx context.Context) (string, error) {
+			if !loaded {
+				return "", skipped("config")
+			}
+			if c == nil || c.Quotas == nil {
+				return "none configured", nil
+			}
+			if _, err := c.Quotas.Limiter(); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%d API keys", len(c.Quotas.Keys)), nil
+		}},
+		{Name: "flags", Check: func(ctx context.Context) (string, error) {
+			for _, flag := range []string{"health-interval", "store-sync"} {
+				if _, err := time.ParseDuration(cmd.GetString(flag)); err != nil {
+					return "", fmt.Errorf("--%s: %w", flag, err)
+				}
+			}
+			var err error
+			if client, err = transportClient(cmd); err != nil {
+				return "", err
+			}
+			return "valid", nil
+		}},
+		{Name: "listen " + cmd.GetString("addr"), Check: func(ctx context.Context) (string, error) {
+			ln, err := net.Listen("tcp", cmd.GetString("addr"))
+			if err != nil {
+				return "", err
+			}
+			ln.Close()
+			return "address free", nil
+		}},
+		{Name: "infermux", Check: func(ctx context.Context) (string, error) {
+			if client == nil {
+				return "", skipped("flags")
+			}
+			url := strings.TrimRight(cmd.GetString("infer-url"), "/") + "/healthz"
+			if err := matchspec.HTTPHealthCheck(url, client)(ctx); err != nil {
+				return "", err
+			}
+			return url + " healthy", nil
+		}},
+	}
+	if u := cmd.GetString("tokentrace-url"); u != "" {
+		checks = append(checks, matchspec.PreflightCheck{Name: "tokentrace", Check: func(ctx context.Context) (string, error) {
+			addr, err := matchspec.URLAddr(u)
+			if err != nil {
+				return "", err
+			}
+			return dial(addr)
+		}})
+	}
+	for _, flag := range []string{"queue", "audit-log"} {
+		path := cmd.GetString(flag)
+		if path == "" {
+			continue
+		}
+		checks = append(checks, matchspec.PreflightCheck{Name: flag, Check: func(ctx context.Context) (string, error) {
+			if flag == "queue" {
+				var store matchspec.ResultStore
+				if path == "store" {
+					s, err := resultStore(ctx, cmd.GetString("config"))
+					if err != nil {
+						return "", err
+					}
+					if c, ok := s.(interface{ Close() error }); ok {
+						defer c.Close()
+					}
+					store = s
+				}
+				q, err := jobQueue(path, store, 0)
+				if err != nil {
+					return "", err
+				}
+				jobs, err := q.List(ctx)
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("%s: %d jobs", path, len(jobs)), nil
+			}
+			audit, err := matchspec.OpenAuditLog(path)
+			if err != nil {
+				return "", err
+			}
+			if err := audit.Verify(); err != nil {
+				return "", err
+			}
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+			if err != nil {
+				return "", err
+			}
+			f.Close()
+			return path + ": chain intact, writable", nil
+		}})
+	}
+
+	checks = append(checks,
+		matchspec.PreflightCheck{Name: "notifiers", Check: func(ctx context.Context) (string, error) {
+			if !loaded {
+				return "", skipped("config")
+			}
+			if c == nil || len(c.Notifiers) == 0 {
+				return "none configured", nil
+			}
+			var reached []string
+			for i, nc := range c.Notifiers {
+				if _, err := matchspec.NewNotifier(nc, client); err != nil {
+					return "", fmt.Errorf("notifier %d: %w", i+1, err)
+				}
+				addr, err := nc.Endpoint()
+				if err == nil && addr != "" {
+					_, err = dial(addr)
+				}
+				if err != nil {
+					return "", fmt.Errorf("notifier %d (%s): %w", i+1, nc.Type, err)
+				}
+				if addr != "" {
+					reached = append(reached, addr)
+				}
+			}
+			detail := fmt.Sprintf("%d configured", len(c.Notifiers))
+			if len(reached) > 0 {
+				detail += "; reached " + strings.Join(reached, ", ")
+			}
+			return detail, nil
+		}},
+		matchspec.PreflightCheck{Name: "store", Check: func(ctx context.Context) (string, error) {
+			if !loaded {
+				return "", skipped("config")
+			}
+			if c == nil || c.Store == nil {
+				return "none configured; run history is in memory", nil
+			}
+			if err := missingStoreDriver(*c.Store); err != nil {
+				return "", err
+			}
+			return matchspec.PreflightStore(*c.Store).Check(ctx)
+		}},
+	)
+
+	report := matchspec.RunPreflight(ctx, 10*time.Second, checks...)
+	rows := make([][]string, 0, len(report.Results))
+	for _, r := range report.Results {
+		rows = append(rows, []string{r.Name, r.Status, strconv.FormatInt(r.LatencyMS, 10), r.Detail})
+	}
+	output.New("table").Table([]string{"CHECK", "STATUS", "MS", "DETAIL"}, rows)
+	if !report.OK {
+		return errors.New("configuration check failed")
+	}
+	fmt.Println("\nconfiguration ok")
+	return nil
+}
+
+// newServeMux registers the API routes of h.
+func newServeMux(h *matchspec.Handler) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /mist", h.Ingest)
+	mux.HandleFunc("POST /eval", h.RunDirect)
+	mux.HandleFunc("POST /score", h.Score)
+	mux.HandleFunc("POST /campaigns", h.RunCampaign)
+	mux.HandleFunc("GET /suites", h.Suites)
+	mux.HandleFunc("GET /suites/{name}/stats", h.SuiteStats)
+	mux.HandleFunc("GET /results", h.Results)
+	mux.HandleFunc("DELETE /results", h.DeleteResults)
+	mux.HandleFunc("POST /results/purge", h.PurgeResults)
+	mux.HandleFunc("GET /summary", h.Summary)
+	mux.HandleFunc("GET /runs", h.Runs)
+	mux.HandleFunc("GET /trend", h.Trend)
+	mux.HandleFunc("GET /runs/active", h.ActiveRuns)
+	mux.HandleFunc("GET /runs/compare", h.CompareRuns)
+	mux.HandleFunc("GET /runs/{id}", h.GetRun)
+	mux.HandleFunc("GET /runs/{id}/checkpoint", h.ExportRun)
+	mux.HandleFunc("GET /runs/{id}/stream", h.StreamRun)
+	mux.HandleFunc("POST /runs/{id}/cancel", h.CancelRun)
+	mux.HandleFunc("DELETE /runs/{id}", h.DeleteRun)
+	mux.HandleFunc("POST /runs/{id}/restore", h.RestoreRun)
+	mux.HandleFunc("POST /runs/import", h.ImportRun)
+	mux.HandleFunc("GET /baselines", h.Baselines)
+	mux.HandleFunc("GET /baselines/{suite...}", h.GetBaseline)
+	mux.HandleFunc("POST /baselines", h.RecordBaseline)
+	mux.HandleFunc("POST /erasure", h.Erase)
+	return mux
+}
+
+// writeCheckpointFile writes a checkpoint of the runner's latest run to
+// path.
+func writeCheckpointFile(runner *matchspec.Runner, path string) error {
+	runs, _ := runner.Runs(matchspec.RunFilter{Limit: 1})
+	if len(runs) == 0 {
+		return nil
+	}
+	cp, err := runner.ExportRun(runs[0].ID)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	var w io.Writer = f
+	var zw *gzip.Writer
+	if strings.HasSuffix(path, ".gz") {
+		zw = gzip.NewWriter(f)
+		w = zw
+	}
+	err = matchspec.WriteCheckpoint(w, cp)
+	if err == nil && zw != nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readChangedFiles reads a changed-file list or diff from path, or from
+// stdin if path is "-".
+func readChangedFiles(path string) ([]string, error) {
+	if path == "-" {
+		return matchspec.ReadChangedFiles(os.Stdin)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return matchspec.ReadChangedFiles(f)
+}
+
+func readCheckpointFile(path string) (matchspec.Checkpoint, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return matchspec.Checkpoint{}, err
+	}
+	defer f.Close()
+	return matchspec.ReadCheckpoint(f)
+}
+
+// resumeCheckpoint reads the checkpoint file at path or, if there is
+// none, recovers the run journaled next to it by eval --checkpoint.
+func resumeCheckpoint(path string) (matchspec.Checkpoint, error) {
+	cp, err := readCheckpointFile(path)
+	if !errors.Is(err, fs.ErrNotExist) {
+		return cp, err
+	}
+	cps, rerr := matchspec.NewCheckpointSink(path + ".journal").Recover()
+	switch {
+	case rerr != nil:
+		return matchspec.Checkpoint{}, rerr
+	case len(cps) == 0:
+		return matchspec.Checkpoint{}, err
+	case len(cps) > 1:
+		return matchspec.Checkpoint{}, fmt.Errorf("%s.journal holds %d runs; resume one with the library's ResumeRun", path, len(cps))
+	}
+	fmt.Fprintf(os.Stderr, "recovered %d results of run %s from %s.journal\n", len(cps[0].Results), cps[0].Record.ID, path)
+	return cps[0], nil
+}
+
+// runnerOptions returns the retry, seeding, and verbosity options set by
+// eval flags.
+func runnerOptions(cmd *cli.Command) []matchspec.RunnerOption {
+	opts := retryOptions(cmd)
+	if seed := cmd.GetInt("seed"); seed != 0 {
+		opts = append(opts, matchspec.WithSamplingSeed(int64(seed)))
+	}
+	if cmd.GetBool("verbose") {
+		opts = append(opts, matchspec.WithVerbose())
+	}
+	return opts
+}
+
+// configOptions returns runner options for the notifiers, regression
+// check, and inference limits in the config file, if any.
+func configOptions(config string) ([]matchspec.RunnerOption, error) {
+	c, err := loadConfig(config)
+	if err != nil || c == nil {
+		return nil, err
+	}
+	opts, err := c.NotifierOptions(nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.InferLimits != nil {
+		opts = append(opts, matchspec.WithInferLimits(*c.InferLimits))
+	}
+	return opts, nil
+}
+
+// storeBuildTags are the build tags that link a database driver for each
+// SQL store type.
+var storeBuildTags = map[string]string{"sqlite": "sqlite", "postgres": "postgres"}
+
+// resultStore opens the config's result store, or returns nil if it has
+// none.
+func resultStore(ctx context.Context, config string) (matchspec.ResultStore, error) {
+	c, err := loadConfig(config)
+	if err != nil || c == nil || c.Store == nil {
+		return nil, err
+	}
+	if err := missingStoreDriver(*c.Store); err != nil {
+		return nil, fmt.Errorf("%s: %w", config, err)
+	}
+	return matchspec.OpenResultStore(ctx, *c.Store)
+}
+
+// missingStoreDriver reports a SQL store type whose driver this binary
+// was built without. The stock binary links no database drivers, and the
+// library's advice to import one does not help someone running it.
+func missingStoreDriver(cfg matchspec.ResultStoreConfig) error {
+	tag, ok := storeBuildTags[cfg.Type]
+	if !ok || cfg.Driver != "" || slices.Contains(sql.Drivers(), cfg.Type) {
+		return nil
+	}
+	return fmt.Errorf("store type %q needs a database driver this matchspec was built without; build it with -tags %s", cfg.Type, tag)
+}
+
+// jobQueue opens the --queue job queue: the database of store if path is
+// "store", else the queue file at path.
+func jobQueue(path string, store matchspec.ResultStore, maxQueued int) (matchspec.JobQueue, error) {
+	if path != "store" {
+		q, err := matchspec.OpenFileJobQueue(path)
+		if err != nil {
+			return nil, err
+		}
+		q.MaxQueued = maxQueued
+		return q, nil
+	}
+	if store == nil {
+		return nil, fmt.Errorf("--queue store: the config has no result store")
+	}
+	q, err := matchspec.StoreJobQueue(store)
+	if err != nil {
+		return nil, err
+	}
+	q.MaxQueued = maxQueued
+	return q, nil
+}
+
+// quotaLimiter returns the config's quota limiter, or nil if it has none.
+func quotaLimiter(config string) (*matchspec.QuotaLimiter, error) {
+	c, err := loadConfig(config)
+	if err != nil || c == nil || c.Quotas == nil {
+		return nil, err
+	}
+	return c.Quotas.Limiter()
+}
+
+// withQuotas enforces quotas on every request but health checks.
+func withQuotas(q *matchspec.QuotaLimiter, next http.Handler) http.Handler {
+	limited := q.Middleware(next.ServeHTTP)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		limited(w, r)
+	})
+}
+
+// requireAdmin serves next only to the config's admin API keys; without
+// quotas there are none, so every request gets 403.
+func requireAdmin(q *matchspec.QuotaLimiter, next http.HandlerFunc) http.HandlerFunc {
+	if q == nil {
+		return func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "admin API key required; add one to the config's quotas with admin: true", http.StatusForbidden)
+		}
+	}
+	return q.RequireAdmin(next)
+}
+
+// retryLoadStore calls LoadStore with exponential backoff until it
+// succeeds or ctx is done.
+func retryLoadStore(ctx context.Context, runner *matchspec.Runner) {
+	wait := matchspec.DefaultSpoolMinBackoff
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		loaded, err := runner.LoadStore(ctx)
+		if err == nil {
+			fmt.Printf("loaded %d runs from the result store\n", loaded)
+			return
+		}
+		wait = min(wait*2, matchspec.DefaultSpoolMaxBackoff)
+	}
+}
+
+// modelPinOption returns a runner option checking the config's model pin,
+// or --pin-model if given, against the InferMux backend, or nil if there
+// is no pin.
+func modelPinOption(cmd *cli.Command, url, model string) (matchspec.RunnerOption, error) {
+	var pin matchspec.ModelPin
+	c, err := loadConfig(cmd.GetString("config"))
+	if err != nil {
+		return nil, err
+	}
+	if c != nil && c.ModelPin != nil {
+		pin = *c.ModelPin
+	}
+	if m := cmd.GetString("pin-model"); m != "" {
+		pin.Model = m
+	}
+	if pin.Model == "" {
+		return nil, nil
+	}
+	if err := pin.Validate(); err != nil {
+		return nil, err
+	}
+	client, err := transportClient(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return matchspec.WithModelPin(pin, matchspec.InferMuxModelProbe(url, model, client)), nil
+}
+
+// writeShareBundle writes the sharing bundle of a run to the --share file,
+// redacted per the config's share section.
+func writeShareBundle(cmd *cli.Command, share *matchspec.ShareRecorder, rec matchspec.RunRecord, results []matchspec.Result) error {
+	var cfg matchspec.ShareConfig
+	c, err := loadConfig(cmd.GetString("config"))
+	if err != nil {
+		return err
+	}
+	if c != nil && c.Share != nil {
+		cfg = *c.Share
+	}
+	bundle, err := share.Bundle(rec, results, cfg)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(cmd.GetString("share"), append(data, '\n'), 0o644)
+}
+
+// updateGolden prints a review diff of each golden update to stderr and
+// writes the updates to the suite's files.
+func updateGolden(s *matchspec.Suite, updates []matchspec.GoldenUpdate) error {
+	for _, u := range updates {
+		fmt.Fprintf(os.Stderr, "\n--- %s/%s expected\n+++ %s/%s response\n%s", u.Suite, u.Task, u.Suite, u.Task, u.Diff)
+	}
+	n, err := s.ApplyGolden(updates)
+	fmt.Fprintf(os.Stderr, "\nupdated %d golden outputs in suite %q\n", n, s.Name)
+	return err
+}
+
+// progressOption returns a runner option that logs progress to stderr:
+// every event if verbose, and otherwise only the first projected deadline
+// overrun.
+func progressOption(verbose bool) matchspec.RunnerOption {
+	warned := false
+	return matchspec.WithProgress(func(p matchspec.Progress) {
+		switch {
+		case verbose:
+			fmt.Fprintln(os.Stderr, p)
+		case p.Overrun && !warned:
+			fmt.Fprintln(os.Stderr, "warning:", p)
+		}
+		warned = warned || p.Overrun
+	})
+}
+
+// envOptions returns the runner options for the --env and --env-dir
+// flags, plus secret variables from MATCHSPEC_SECRET_* environment
+// variables.
+func envOptions(cmd *cli.Command) ([]matchspec.RunnerOption, error) {
+	var opts []matchspec.RunnerOption
+	if secrets := matchspec.SecretVarsFromEnv("MATCHSPEC_SECRET_"); len(secrets) > 0 {
+		opts = append(opts, matchspec.WithSecretVars(secrets))
+	}
+	name := cmd.GetString("env")
+	if name == "" {
+		return opts, nil
+	}
+	vars, err := matchspec.LoadEnv(cmd.GetString("env-dir"), name)
+	if err != nil {
+		return nil, err
+	}
+	return append(opts, matchspec.WithVars(vars)), nil
+}
+
+// retryOptions returns the runner options for the --retries and
+// --retry-budget flags.
+func retryOptions(cmd *cli.Command) []matchspec.RunnerOption {
+	n := cmd.GetInt("retries")
+	if n <= 0 {
+		return nil
+	}
+	p := retry.DefaultPolicy
+	p.MaxAttempts = n + 1
+	return []matchspec.RunnerOption{matchspec.WithRetry(p, cmd.GetInt("retry-budget"))}
+}
+
+// streamEval runs the tasks in a JSONL file without holding them or their
+// results in memory, writing results to stdout as NDJSON and the run
+// summary to stderr.
+func streamEval(cmd *cli.Command, suite, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reporter := tokentrace.NewReporter(protocol.SourceMatchSpec, cmd.GetString("tokentrace-url"))
+	infer, err := inferMux(cmd, cmd.GetString("infer-url"), cmd.GetString("model"))
+	if err != nil {
+		return err
+	}
+	opts := append(runnerOptions(cmd), matchspec.WithSink(matchspec.NewNDJSONSink(os.Stdout)))
+	runner := matchspec.NewRunner(matchspec.NewSuiteRegistry(), infer, reporter, opts...)
+
+	labels, err := matchspec.ParseLabels(cmd.GetString("labels"))
+	if err != nil {
+		return fmt.Errorf("--labels: %w", err)
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	rec, err := runner.RunStream(matchspec.WithRunLabels(ctx, labels...), suite, matchspec.NewJSONLTaskReader(suite, f), cmd.GetInt("workers"))
+	s := rec.Summary
+	fmt.Fprintf(os.Stderr, "passed %d/%d (%.1f%%)  mean=%.3f  hash=%s\n",
+		s.Passed, s.Total, s.PassRate*100, s.MeanScore, rec.Hash)
+	return err
+}
+
+// watchRun prints the events of a run's result stream until its summary.
+func watchRun(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var event string
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if e, ok := strings.CutPrefix(line, "event: "); ok {
+			event = e
+			continue
+		}
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		switch event {
+		case "result":
+			var r matchspec.Result
+			if err := json.Unmarshal([]byte(data), &r); err != nil {
+				return err
+			}
+			status := "PASS"
+			if !r.Passed {
+				status = "FAIL"
+			}
+			fmt.Printf("%s  %-30s  score=%.2f  %dms  %s\n", status, r.Task, r.Score, r.DurationMS, r.Error)
+		case "summary":
+			var rec matchspec.RunRecord
+			if err := json.Unmarshal([]byte(data), &rec); err != nil {
+				return err
+			}
+			s := rec.Summary
+			fmt.Printf("\npassed %d/%d (%.1f%%)  mean=%.3f\n", s.Passed, s.Total, s.PassRate*100, s.MeanScore)
+			if rec.Error != "" {
+				return errors.New(rec.Error)
+			}
+			return nil
+		case "error":
+			var e struct {
+				Error string `json:"error"`
+			}
+			json.Unmarshal([]byte(data), &e)
+			return errors.New(e.Error)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("stream ended before the run finished")
+}
+
+// storeOptions returns the options for the on-disk stores, encrypting
+// them if --encryption-key-env is set.
+func storeOptions(cmd *cli.Command) ([]matchspec.StoreOption, error) {
+	var opts []matchspec.StoreOption
+	if cmd.GetBool("compress") {
+		opts = append(opts, matchspec.Compress())
+	}
+	name := cmd.GetString("encryption-key-env")
+	if name == "" {
+		return opts, nil
+	}
+	c, err := matchspec.CipherFromEnv(name)
+	if err != nil {
+		return nil, fmt.Errorf("--encryption-key-env: %w", err)
+	}
+	opts = append(opts, matchspec.EncryptWith(c))
+	if cmd.GetBool("read-plaintext") {
+		opts = append(opts, matchspec.ReadPlaintext())
+	}
+	return opts, nil
+}
+
+// interruptContext returns a context cancelled by the first interrupt or
+// SIGTERM, so a run stops after the task in flight and its partial results
+// are still reported and checkpointed. A second interrupt exits at once.
+func interruptContext() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		if _, ok := <-sigs; !ok {
+			return
+		}
+		fmt.Fprintln(os.Stderr, "interrupted; finishing the current task (interrupt again to exit now)")
+		cancel()
+		if _, ok := <-sigs; ok {
+			os.Exit(130)
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(sigs)
+		close(sigs)
+		cancel()
+	}
+}
+
+// reproduceEval runs the task execution recorded in a result file (such
+// as a line of --ndjson output) again and prints both results.
+func reproduceEval(cmd *cli.Command, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var orig matchspec.Result
+	if err := json.Unmarshal(data, &orig); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	reg, _, err := loadSuite(cmd.GetString("config"), orig.Suite)
+	if err != nil {
+		return err
+	}
+
+	reporter := tokentrace.NewReporter(protocol.SourceMatchSpec, cmd.GetString("tokentrace-url"))
+	infer, err := inferMux(cmd, cmd.GetString("infer-url"), cmd.GetString("model"))
+	if err != nil {
+		return err
+	}
+	envOpts, err := envOptions(cmd)
+	if err != nil {
+		return err
+	}
+	runner := matchspec.NewRunner(reg, infer, reporter, append(retryOptions(cmd), envOpts...)...)
+
+	res, err := runner.Reproduce(context.Background(), orig)
+	if res.Task == "" {
+		return err
+	}
+	rows := make([][]string, 0, 2)
+	for _, r := range []struct {
+		name string
+		res  matchspec.Result
+	}{{"original", orig}, {"reproduced", res}} {
+		rows = append(rows, []string{
+			r.name,
+			strconv.FormatBool(r.res.Passed),
+			strconv.FormatFloat(r.res.Score, 'f', 2, 64),
+			strconv.FormatInt(r.res.DurationMS, 10),
+			r.res.Error,
+		})
+	}
+	output.New("table").Table([]string{orig.Task, "PASSED", "SCORE", "MS", "ERROR"}, rows)
+	return err
+}
+
+// addTransportFlags adds the flags tuning connections to the inference
+// backend.
+func addTransportFlags(cmd *cli.Command) {
+	cmd.AddIntFlag("max-idle-conns", matchspec.DefaultMaxIdleConnsPerHost, "Idle connections kept open to the inference backend")
+	cmd.AddIntFlag("max-conns", 0, "Maximum connections to the inference backend (0 = no limit)")
+	cmd.AddStringFlag("keep-alive", "30s", "TCP keep-alive interval for backend connections (0 disables connection reuse)")
+	cmd.AddStringFlag("http2", "", "HTTP/2 mode for the backend: off, or h2c for cleartext HTTP/2 (default negotiates over TLS)")
+	cmd.AddStringFlag("proxy", "", "Proxy URL for backend requests, or direct (default from HTTP_PROXY/HTTPS_PROXY)")
+}
+
+// inferMux returns an InferMux inference function using the transport
+// flags.
+func inferMux(cmd *cli.Command, url, model string) (matchspec.InferFunc, error) {
+	client, err := transportClient(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return matchspec.InferMuxClientFunc(url, model, client), nil
+}
+
+// transportClient builds the HTTP client described by the transport flags.
+func transportClient(cmd *cli.Command) (*http.Client, error) {
+	keepAlive, err := time.ParseDuration(cmd.GetString("keep-alive"))
+	if err != nil {
+		return nil, fmt.Errorf("--keep-alive: %w", err)
+	}
+	return matchspec.TransportConfig{
+		MaxIdleConnsPerHost: cmd.GetInt("max-idle-conns"),
+		MaxConnsPerHost:     cmd.GetInt("max-conns"),
+		KeepAlive:           keepAlive,
+		DisableKeepAlives:   keepAlive == 0,
+		HTTP2:               cmd.GetString("http2"),
+		Proxy:               cmd.GetString("proxy"),
+	}.Client()
+}
+
+// defaultConfig is the config file read when --config is not given. Unlike
+// an explicit --config, it may be missing.
+const defaultConfig = "matchspec.yaml"
+
+// suiteRegistry builds a registry of the built-in suites and the suites
+// listed in the config file.
+func suiteRegistry(config string) (*matchspec.SuiteRegistry, error) {
+	reg := matchspec.NewSuiteRegistry()
+	if err := matchspec.RegisterBuiltins(reg); err != nil {
+		return nil, err
+	}
+	c, err := loadConfig(config)
+	if err != nil || c == nil {
+		return reg, err
+	}
+	if err := c.RegisterSuites(reg); err != nil {
+		return nil, err
+	}
+	return reg, nil
+}
+
+// loadConfig reads the config file. It returns nil if there is none.
+func loadConfig(config string) (*matchspec.Config, error) {
+	if config == "" {
+		return nil, nil
+	}
+	if _, err := os.Stat(config); errors.Is(err, fs.ErrNotExist) && config == defaultConfig {
+		return nil, nil
+	}
+	return matchspec.LoadConfig(config)
+}
+
+// loadSuite builds a registry from the config file and returns it with
+// the named suite.
+func loadSuite(config, name string) (*matchspec.SuiteRegistry, *matchspec.Suite, error) {
+	reg, err := suiteRegistry(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	s, ok := reg.Get(name)
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown suite %q", name)
+	}
+	return reg, s, nil
+}
+
+// printBaseline prints how a run compared with its baseline to stderr.
+func printBaseline(b *matchspec.BaselineComparison) {
+	fmt.Fprintln(os.Stderr, b)
+	for _, t := range b.NewlyFailing {
+		fmt.Fprintf(os.Stderr, "  newly failing: %s\n", t)
+	}
+	for _, d := range b.ScoreDrops {
+		fmt.Fprintf(os.Stderr, "  score drop: %s %.3f -> %.3f (%+.3f)\n", d.Task, d.Baseline, d.Score, d.Delta)
+	}
+	for _, t := range b.Fixed {
+		fmt.Fprintf(os.Stderr, "  fixed: %s\n", t)
+	}
+}
+
+// printBaselineTasks prints a baseline's tasks as a table.
+func printBaselineTasks(b matchspec.Baseline) {
+	fmt.Printf("suite %s, run %s, model %s, recorded %s\n\n", b.Suite, b.RunID, b.Model, b.RecordedAt.Format(time.RFC3339))
+	rows := make([][]string, 0, len(b.Tasks))
+	for _, name := range slices.Sorted(maps.Keys(b.Tasks)) {
+		t := b.Tasks[name]
+		rows = append(rows, []string{name, strconv.FormatBool(t.Passed), strconv.FormatFloat(t.Score, 'f', 2, 64)})
+	}
+	output.New("table").Table([]string{"TASK", "PASSED", "SCORE"}, rows)
+}
+
+func printResults(results []matchspec.Result, curve string) {
+	rows := make([][]string, 0, len(results))
+	for _, r := range results {
+		rows = append(rows, []string{
+			r.Task,
+			strconv.FormatBool(r.Passed),
+			strconv.FormatFloat(r.Score, 'f', 2, 64),
+			strconv.FormatInt(r.DurationMS, 10),
+			r.Error,
+		})
+	}
+	output.New("table").Table([]string{"TASK", "PASSED", "SCORE", "MS", "ERROR"}, rows)
+
+	s := matchspec.Summarize(results)
+	fmt.Printf("\npassed %d/%d (%.1f%%)  mean=%.3f  p50=%.3f  p90=%.3f  min=%.3f  max=%.3f\n",
+		s.Passed, s.Total, s.PassRate*100, s.MeanScore, s.Percentiles.P50, s.Percentiles.P90, s.MinScore, s.MaxScore)
+	for _, b := range s.Histogram {
+		fmt.Printf("  [%.1f, %.1f) %s %d\n", b.Lower, b.Upper, strings.Repeat("#", b.Count), b.Count)
+	}
+	if rs := s.Repeats; rs != nil {
+		fmt.Printf("\npass@1=%.3f  pass@%d=%.3f  flaky %d/%d\n", rs.PassAt1, rs.K, rs.PassAtK, len(rs.Flaky), len(rs.Tasks))
+		for _, t := range rs.Tasks {
+			if t.Flaky {
+				fmt.Printf("  flaky: %s passed %d/%d  mean=%.3f  stddev=%.3f\n", t.Task, t.Passed, t.Runs, t.MeanScore, t.StdDevScore)
+			}
+		}
+	}
+	if s.Weighted != nil {
+		if w, err := matchspec.WeightedScores(results, curve); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		} else {
+			fmt.Printf("weighted (%s): score=%.3f  pass=%.1f%%\n", w.Curve, w.Score, w.PassRate*100)
+			for _, l := range w.Levels {
+				fmt.Printf("  difficulty %g (weight %g): passed %d/%d  mean=%.3f\n", l.Difficulty, l.Weight, l.Passed, l.Total, l.MeanScore)
+			}
+		}
+	}
+
+	if s.CacheHits+s.CacheMisses > 0 {
+		fmt.Printf("response cache: %d hits, %d misses\n", s.CacheHits, s.CacheMisses)
+	}
+
+	if sh := s.Shadow; sh != nil {
+		fmt.Printf("shadow %s: pass %.1f%% vs %.1f%% primary  score delta=%+.3f  latency delta=%+.0fms  improved=%d regressed=%d errors=%d\n",
+			sh.Backend, sh.CandidatePassRate*100, sh.PrimaryPassRate*100, sh.MeanScoreDelta, sh.LatencyDeltaMS,
+			sh.Improved, sh.Regressed, sh.CandidateErrors)
+	}
+
+	if len(s.Variants) > 0 {
+		fmt.Println()
+		rows = make([][]string, 0, len(s.Variants))
+		for _, v := range s.Variants {
+			sig := ""
+			if v.Significant {
+				sig = "*"
+			}
+			rows = append(rows, []string{
+				v.Variant,
+				fmt.Sprintf("%d/%d", v.Passed, v.Total),
+				strconv.FormatFloat(v.PassRate, 'f', 3, 64),
+				strconv.FormatFloat(v.Delta, 'f', 3, 64),
+				strconv.FormatFloat(v.PValue, 'f', 4, 64),
+				sig,
+			})
+		}
+		output.New("table").Table([]string{"VARIANT", "PASSED", "PASS_RATE", "DELTA", "P_VALUE", "SIGNIFICANT"}, rows)
+	}
+
+	for _, r := range results {
+		if r.Diff != "" {
+			fmt.Printf("\n--- %s (expected -, response +)\n%s", r.Task, r.Diff)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+//go:build postgres
+
+package main
+
+// Built with -tags postgres, the binary can open "postgres" result stores.
+import _ "github.com/lib/pq"
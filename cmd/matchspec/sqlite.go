@@ -0,0 +1,6 @@
+//go:build sqlite
+
+package main
+
+// Built with -tags sqlite, the binary can open "sqlite" result stores.
+import _ "modernc.org/sqlite"
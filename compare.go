@@ -0,0 +1,210 @@
+package matchspec
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// BootstrapSamples is the number of resamples behind a RunComparison's
+// score confidence interval.
+const BootstrapSamples = 10000
+
+// MinBootstrapTasks is the fewest paired tasks the bootstrap is run on.
+// Fewer resample to too few distinct means to estimate their spread, so
+// the comparison reports a bootstrap p-value of 1.
+const MinBootstrapTasks = 10
+
+// RunComparison is a paired significance test of run B against run A over
+// the tasks both ran. Pass/fail outcomes are compared with an exact
+// McNemar test and mean scores with a bootstrap of per-task differences,
+// so the result says whether B is better than A beyond the noise of the
+// tasks that happened to flip. Repeated tasks are folded as for baselines:
+// a task passes only if every run of it passed, and its score is the mean.
+type RunComparison struct {
+	A string `json:"a"`
+	B string `json:"b"`
+
+	// Paired is the number of tasks in both runs; OnlyInA and OnlyInB are
+	// the tasks in only one, which the tests leave out.
+	Paired  int      `json:"paired"`
+	OnlyInA []string `json:"only_in_a,omitempty"`
+	OnlyInB []string `json:"only_in_b,omitempty"`
+
+	// PassRateA and PassRateB are over the paired tasks. PassedOnlyA and
+	// PassedOnlyB count the tasks one run passed and the other failed, the
+	// discordant pairs McNemarP is computed from.
+	PassRateA   float64 `json:"pass_rate_a"`
+	PassRateB   float64 `json:"pass_rate_b"`
+	PassedOnlyA int     `json:"passed_only_a"`
+	PassedOnlyB int     `json:"passed_only_b"`
+	McNemarP    float64 `json:"mcnemar_p"`
+
+	// ScoreDelta is B's mean score minus A's over the paired tasks, with
+	// its 95% bootstrap confidence interval and two-sided p-value.
+	MeanScoreA float64    `json:"mean_score_a"`
+	MeanScoreB float64    `json:"mean_score_b"`
+	ScoreDelta float64    `json:"score_delta"`
+	ScoreCI    [2]float64 `json:"score_ci"`
+	BootstrapP float64    `json:"bootstrap_p"`
+
+	// Better is "a" or "b" if that run is significantly better at
+	// SignificanceLevel: by McNemar if the pass/fail test is significant,
+	// otherwise by the bootstrap. It is empty if neither test is.
+	Better string `json:"better,omitempty"`
+}
+
+// String describes the comparison in a few lines.
+func (c RunComparison) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d paired tasks\n", c.Paired)
+	fmt.Fprintf(&b, "pass rate %.3f -> %.3f (only a passed %d, only b passed %d; McNemar p=%.3f)\n",
+		c.PassRateA, c.PassRateB, c.PassedOnlyA, c.PassedOnlyB, c.McNemarP)
+	fmt.Fprintf(&b, "mean score %.3f -> %.3f (%+.3f, 95%% CI [%+.3f, %+.3f]; bootstrap p=%.3f)\n",
+		c.MeanScoreA, c.MeanScoreB, c.ScoreDelta, c.ScoreCI[0], c.ScoreCI[1], c.BootstrapP)
+	if c.Better == "" {
+		b.WriteString("no significant difference")
+	} else {
+		fmt.Fprintf(&b, "%s is significantly better", c.Better)
+	}
+	return b.String()
+}
+
+// CompareRuns tests the results of run b against those of run a. The
+// bootstrap is seeded, so the same results always compare the same way.
+func CompareRuns(a, b []Result) RunComparison {
+	ta, tb := baselineTasks(a), baselineTasks(b)
+	var c RunComparison
+	var diffs []float64
+	var passedA, passedB int
+	var sumA, sumB float64
+	for _, key := range slices.Sorted(maps.Keys(ta)) {
+		x := ta[key]
+		y, ok := tb[key]
+		if !ok {
+			c.OnlyInA = append(c.OnlyInA, key)
+			continue
+		}
+		c.Paired++
+		if x.Passed {
+			passedA++
+		}
+		if y.Passed {
+			passedB++
+		}
+		switch {
+		case x.Passed && !y.Passed:
+			c.PassedOnlyA++
+		case y.Passed && !x.Passed:
+			c.PassedOnlyB++
+		}
+		sumA += x.Score
+		sumB += y.Score
+		diffs = append(diffs, y.Score-x.Score)
+	}
+	for _, key := range slices.Sorted(maps.Keys(tb)) {
+		if _, ok := ta[key]; !ok {
+			c.OnlyInB = append(c.OnlyInB, key)
+		}
+	}
+
+	c.McNemarP = mcNemarPValue(c.PassedOnlyA, c.PassedOnlyB)
+	c.ScoreCI, c.BootstrapP = bootstrapMeanDiff(diffs)
+	if n := float64(c.Paired); n > 0 {
+		c.PassRateA, c.PassRateB = float64(passedA)/n, float64(passedB)/n
+		c.MeanScoreA, c.MeanScoreB = sumA/n, sumB/n
+		c.ScoreDelta = c.MeanScoreB - c.MeanScoreA
+	}
+	switch {
+	case c.McNemarP < SignificanceLevel:
+		c.Better = betterOf(float64(c.PassedOnlyB - c.PassedOnlyA))
+	case c.BootstrapP < SignificanceLevel:
+		c.Better = betterOf(c.ScoreDelta)
+	}
+	return c
+}
+
+// Compare tests recorded run b against recorded run a (see CompareRuns).
+// Both runs must be of the same suite and have retained results.
+func (r *Runner) Compare(a, b string) (RunComparison, error) {
+	var suite string
+	var results [2][]Result
+	for i, id := range []string{a, b} {
+		rec, ok := r.GetRun(id)
+		if !ok {
+			return RunComparison{}, fmt.Errorf("%w: %q", ErrRunNotFound, id)
+		}
+		if i > 0 && rec.Suite != suite {
+			return RunComparison{}, fmt.Errorf("matchspec: run %s is of suite %q and run %s of %q", a, suite, b, rec.Suite)
+		}
+		suite = rec.Suite
+		results[i], _ = r.QueryResults(ResultFilter{RunID: id})
+		if len(results[i]) == 0 {
+			return RunComparison{}, fmt.Errorf("matchspec: run %s has no results to compare", id)
+		}
+	}
+	c := CompareRuns(results[0], results[1])
+	c.A, c.B = a, b
+	return c, nil
+}
+
+func betterOf(delta float64) string {
+	switch {
+	case delta > 0:
+		return "b"
+	case delta < 0:
+		return "a"
+	}
+	return ""
+}
+
+// mcNemarPValue returns the two-sided p-value of an exact McNemar test on
+// b and c discordant pairs: the binomial probability of a split at least
+// as uneven under even odds.
+func mcNemarPValue(b, c int) float64 {
+	n := b + c
+	if n == 0 {
+		return 1
+	}
+	k := min(b, c)
+	lgN, _ := math.Lgamma(float64(n + 1))
+	var p float64
+	for i := 0; i <= k; i++ {
+		lgI, _ := math.Lgamma(float64(i + 1))
+		lgR, _ := math.Lgamma(float64(n - i + 1))
+		p += math.Exp(lgN - lgI - lgR - float64(n)*math.Ln2)
+	}
+	return math.Min(1, 2*p)
+}
+
+// bootstrapMeanDiff resamples diffs BootstrapSamples times and returns the
+// 95% percentile interval of their mean and the two-sided p-value of a
+// zero mean.
+func bootstrapMeanDiff(diffs []float64) ([2]float64, float64) {
+	if len(diffs) < MinBootstrapTasks {
+		return [2]float64{}, 1
+	}
+	rng := newRand(1)
+	means := make([]float64, BootstrapSamples)
+	var below, above int
+	for i := range means {
+		var sum float64
+		for range diffs {
+			sum += diffs[rng.IntN(len(diffs))]
+		}
+		m := sum / float64(len(diffs))
+		means[i] = m
+		if m <= 0 {
+			below++
+		}
+		if m >= 0 {
+			above++
+		}
+	}
+	sort.Float64s(means)
+	p := 2 * float64(min(below, above)) / BootstrapSamples
+	return [2]float64{percentile(means, 2.5), percentile(means, 97.5)}, math.Min(1, p)
+}
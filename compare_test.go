@@ -0,0 +1,111 @@
+package matchspec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestMcNemarPValue(t *testing.T) {
+	for _, tt := range []struct {
+		b, c int
+		want float64
+	}{
+		{0, 0, 1},
+		{3, 3, 1},
+		{0, 5, 0.0625},
+		{1, 6, 0.125},
+		{2, 12, 0.0129},
+	} {
+		if got := mcNemarPValue(tt.b, tt.c); math.Abs(got-tt.want) > 1e-4 {
+			t.Errorf("mcNemarPValue(%d, %d) = %.4f, want %.4f", tt.b, tt.c, got, tt.want)
+		}
+	}
+}
+
+func TestCompareRuns(t *testing.T) {
+	result := func(task string, passed bool, score float64) Result {
+		return Result{EvalResult: protocol.EvalResult{Suite: "s", Task: task, Passed: passed, Score: score}}
+	}
+	var a, b []Result
+	for i := range 30 {
+		task := fmt.Sprintf("t%d", i)
+		// B fixes ten of A's failures and breaks none.
+		a = append(a, result(task, i >= 10, 0.5))
+		b = append(b, result(task, true, 0.9))
+	}
+	a = append(a, result("dropped", true, 1))
+	b = append(b, result("added", true, 1))
+
+	c := CompareRuns(a, b)
+	if c.Paired != 30 || c.PassedOnlyB != 10 || c.PassedOnlyA != 0 || c.Better != "b" {
+		t.Errorf("comparison = %+v", c)
+	}
+	if c.McNemarP >= SignificanceLevel || c.BootstrapP >= SignificanceLevel {
+		t.Errorf("McNemar p %v, bootstrap p %v", c.McNemarP, c.BootstrapP)
+	}
+	if math.Abs(c.ScoreDelta-0.4) > 1e-9 || math.Abs(c.ScoreCI[0]-0.4) > 1e-9 || math.Abs(c.ScoreCI[1]-0.4) > 1e-9 {
+		t.Errorf("delta %v, CI %v", c.ScoreDelta, c.ScoreCI)
+	}
+	if strings.Join(c.OnlyInA, ",") != "dropped" || strings.Join(c.OnlyInB, ",") != "added" {
+		t.Errorf("only in a %v, only in b %v", c.OnlyInA, c.OnlyInB)
+	}
+	if reverse := CompareRuns(b, a); reverse.Better != "a" {
+		t.Errorf("reversed comparison = %+v", reverse)
+	}
+
+	// Identical runs differ in nothing.
+	if same := CompareRuns(a, a); same.Better != "" || same.McNemarP != 1 || same.BootstrapP != 1 {
+		t.Errorf("self comparison = %+v", same)
+	}
+	if !strings.Contains(CompareRuns(a, a).String(), "no significant difference") {
+		t.Error("String of an even comparison")
+	}
+}
+
+func TestRunnerCompare(t *testing.T) {
+	var broken atomic.Bool
+	infer := func(ctx context.Context, prompt string) (string, error) {
+		if broken.Load() {
+			return "", nil
+		}
+		return map[string]string{"What is 2+2?": "4", "What is 3*4?": "12"}[prompt], nil
+	}
+	runner := NewRunner(driftRegistry(), infer, tokentrace.NewReporter("matchspec", ""))
+	ctx := context.Background()
+	runner.Run(ctx, protocol.EvalRun{Suite: "math"})
+	broken.Store(true)
+	runner.Run(ctx, protocol.EvalRun{Suite: "math"})
+	runs, _ := runner.Runs(RunFilter{})
+	good, bad := runs[1].ID, runs[0].ID
+
+	h := NewHandler(runner, driftRegistry())
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /runs/compare", h.CompareRuns)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/runs/compare?a="+good+"&b="+bad, nil))
+	var c RunComparison
+	json.Unmarshal(w.Body.Bytes(), &c)
+	if w.Code != http.StatusOK || c.A != good || c.Paired != 2 || c.PassedOnlyA != 2 {
+		t.Errorf("GET /runs/compare = %d %s", w.Code, w.Body)
+	}
+	// Two tasks are too few for either test to be significant.
+	if c.Better != "" {
+		t.Errorf("better = %q", c.Better)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/runs/compare?a="+good+"&b=missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing run = %d", w.Code)
+	}
+}
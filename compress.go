@@ -0,0 +1,128 @@
+package matchspec
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// gzipMagic starts every gzip stream.
+const gzipMagic = "\x1f\x8b"
+
+// compressedMagic prefixes every blob compressBlob writes, so compressed and
+// plain artifacts can share a store without guessing from the content.
+const compressedMagic = "MSGZ1\x00"
+
+// maxDecompressed caps the size of decompressed content, so a small
+// crafted blob cannot expand to exhaust memory. It is a variable so tests
+// can lower it.
+var maxDecompressed = 256 << 20
+
+// sqlGzipPrefix marks a SQLResultStore checkpoint stored compressed, as
+// base64 so it fits a text column.
+const sqlGzipPrefix = "gzip:"
+
+// Compress gzips the files a Dir store writes, which suits stores of long
+// responses such as verbose judge outputs. Compressed files start with a
+// header and are decompressed on read whether or not the store has the
+// option, so it can be turned on or off for a store that already holds
+// files. With
+// EncryptWith, files are compressed before they are encrypted.
+func Compress() StoreOption {
+	return func(sc *storeConfig) { sc.compress = true }
+}
+
+// gzipBytes compresses data.
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// compressBlob compresses a store blob behind compressedMagic.
+func compressBlob(data []byte) ([]byte, error) {
+	z, err := gzipBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(compressedMagic), z...), nil
+}
+
+// decompressBlob decompresses a blob written by compressBlob and returns
+// other data as is. It fails if the content decompresses to more than
+// maxDecompressed bytes.
+func decompressBlob(data []byte) ([]byte, error) {
+	z, ok := bytes.CutPrefix(data, []byte(compressedMagic))
+	if !ok {
+		return data, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(z))
+	if err != nil {
+		return nil, fmt.Errorf("matchspec: decompress: %w", err)
+	}
+	defer zr.Close()
+	out, err := io.ReadAll(io.LimitReader(zr, int64(maxDecompressed)+1))
+	if err != nil {
+		return nil, fmt.Errorf("matchspec: decompress: %w", err)
+	}
+	if len(out) > maxDecompressed {
+		return nil, fmt.Errorf("matchspec: decompress: content exceeds %d bytes", maxDecompressed)
+	}
+	return out, nil
+}
+
+// DecompressReader returns a reader of r's content, decompressing it as it
+// is read if r holds a gzip stream, such as a bundle gzipped by the client
+// or served gzipped. Other content is passed through. Decompressed content
+// ends after maxDecompressed bytes, which the caller's decoder reports as
+// truncated.
+func DecompressReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(gzipMagic))
+	if err != nil || string(magic) != gzipMagic {
+		// Short input is not gzip; let the caller's decoder report it.
+		return br, nil
+	}
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, fmt.Errorf("matchspec: decompress: %w", err)
+	}
+	return io.LimitReader(zr, int64(maxDecompressed)), nil
+}
+
+// encodeSQLCheckpoint returns the column value of a checkpoint's JSON,
+// compressed if compress is set.
+func encodeSQLCheckpoint(data []byte, compress bool) (string, error) {
+	if !compress {
+		return string(data), nil
+	}
+	z, err := gzipBytes(data)
+	if err != nil {
+		return "", err
+	}
+	return sqlGzipPrefix + base64.StdEncoding.EncodeToString(z), nil
+}
+
+// decodeSQLCheckpoint returns a reader of the checkpoint JSON in a column
+// value written by encodeSQLCheckpoint, decompressing as it is read.
+func decodeSQLCheckpoint(value string) (io.Reader, error) {
+	rest, ok := strings.CutPrefix(value, sqlGzipPrefix)
+	if !ok {
+		return strings.NewReader(value), nil
+	}
+	zr, err := gzip.NewReader(base64.NewDecoder(base64.StdEncoding, strings.NewReader(rest)))
+	if err != nil {
+		return nil, fmt.Errorf("matchspec: decompress: %w", err)
+	}
+	return io.LimitReader(zr, int64(maxDecompressed)), nil
+}
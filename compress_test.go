@@ -0,0 +1,159 @@
+package matchspec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+)
+
+func TestCompressedStores(t *testing.T) {
+	long := strings.Repeat("The judge considered the response at length. ", 200)
+	c, err := NewCipher(bytes.Repeat([]byte{9}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, opts := range map[string][]StoreOption{
+		"compressed":           {Compress()},
+		"compressed encrypted": {Compress(), EncryptWith(c)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			responses := NewDirResponseCache(dir, opts...)
+			if err := responses.Put("key", long); err != nil {
+				t.Fatal(err)
+			}
+			data, _ := os.ReadFile(filepath.Join(dir, "key.json"))
+			if len(data) > len(long)/10 {
+				t.Errorf("stored %d bytes for a %d-byte response", len(data), len(long))
+			}
+			if got, ok, err := responses.Get("key"); err != nil || !ok || got != long {
+				t.Errorf("Get = %d bytes, %v, %v", len(got), ok, err)
+			}
+		})
+	}
+
+	// A store without the option reads compressed files, and its own
+	// plain files stay readable once compression is turned on.
+	dir := t.TempDir()
+	NewDirResponseCache(dir, Compress()).Put("zipped", long)
+	NewDirResponseCache(dir).Put("plain", "short")
+	for _, s := range []*DirResponseCache{NewDirResponseCache(dir), NewDirResponseCache(dir, Compress())} {
+		if got, _, err := s.Get("zipped"); err != nil || got != long {
+			t.Errorf("compressed Get: %v", err)
+		}
+		if got, _, err := s.Get("plain"); err != nil || got != "short" {
+			t.Errorf("plain Get = %q, %v", got, err)
+		}
+	}
+
+	runs := NewDirRunCache(t.TempDir(), Compress())
+	runs.Put("run", Checkpoint{Version: CheckpointVersion, Record: RunRecord{Suite: "math"}})
+	if cp, ok, err := runs.Get("run"); err != nil || !ok || cp.Record.Suite != "math" {
+		t.Errorf("run cache Get = %+v, %v, %v", cp, ok, err)
+	}
+}
+
+func TestCompressedCheckpoints(t *testing.T) {
+	src := testRunner(echoInfer)
+	src.Run(context.Background(), protocol.EvalRun{Suite: "math"})
+	runs, _ := src.Runs(RunFilter{})
+	h := NewHandler(src, nil)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /runs/{id}/checkpoint", h.ExportRun)
+
+	req := httptest.NewRequest(http.MethodGet, "/runs/"+runs[0].ID+"/checkpoint", nil)
+	req.Header.Set("Accept-Encoding", "br, gzip;q=0.8")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Header().Get("Content-Encoding") != "gzip" || !bytes.HasPrefix(w.Body.Bytes(), []byte(gzipMagic)) {
+		t.Fatalf("export: Content-Encoding %q, body %q", w.Header().Get("Content-Encoding"), w.Body.Bytes()[:10])
+	}
+
+	// The gzipped bundle imports as is.
+	dst := NewHandler(testRunner(failInfer), nil)
+	w2 := httptest.NewRecorder()
+	dst.ImportRun(w2, httptest.NewRequest(http.MethodPost, "/runs/import", bytes.NewReader(w.Body.Bytes())))
+	if w2.Code != http.StatusCreated {
+		t.Fatalf("import = %d %s", w2.Code, w2.Body)
+	}
+
+	req.Header.Set("Accept-Encoding", "gzip;q=0")
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("gzipped a response the client refused")
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte("not json"))
+	zw.Close()
+	if _, err := ReadCheckpoint(&buf); err == nil {
+		t.Error("read a gzipped non-checkpoint")
+	}
+}
+
+func TestDecompressBlob(t *testing.T) {
+	// Only the header marks a blob compressed; a bare gzip stream is
+	// data like any other.
+	z, _ := gzipBytes([]byte("plain"))
+	if got, err := decompressBlob(z); err != nil || !bytes.Equal(got, z) {
+		t.Errorf("bare gzip = %q, %v; want it as is", got, err)
+	}
+
+	defer func(n int) { maxDecompressed = n }(maxDecompressed)
+	maxDecompressed = 1 << 10
+	for size, ok := range map[int]bool{1 << 10: true, 1<<10 + 1: false} {
+		blob, _ := compressBlob(make([]byte, size))
+		if got, err := decompressBlob(blob); (err == nil) != ok || ok && len(got) != size {
+			t.Errorf("%d bytes: got %d bytes, %v", size, len(got), err)
+		}
+	}
+}
+
+func TestSQLResultStoreCompress(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewSQLResultStore(ctx, openFakeStore(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	long := strings.Repeat("verbose judge output ", 500)
+	cp := func(id string) Checkpoint {
+		return Checkpoint{Version: CheckpointVersion, Record: RunRecord{ID: id, Suite: "math"},
+			Results: []Result{{EvalResult: protocol.EvalResult{Suite: "math", Task: "add", Error: long}}}}
+	}
+	store.Append(ctx, cp("plain"))
+	store.Compress = true
+	store.Append(ctx, cp("zipped"))
+
+	testStoreSQL.mu.Lock()
+	for _, row := range testStoreSQL.tables[t.Name()]["matchspec_runs"] {
+		value := row["checkpoint"].(string)
+		if zipped := strings.HasPrefix(value, sqlGzipPrefix); zipped != (row["id"] == "zipped") {
+			t.Errorf("row %v stored compressed = %v", row["id"], zipped)
+		}
+		if row["id"] == "zipped" && len(value) > len(long)/10 {
+			t.Errorf("compressed row is %d bytes", len(value))
+		}
+	}
+	testStoreSQL.mu.Unlock()
+
+	cps, total, err := store.Query(ctx, RunFilter{})
+	if err != nil || total != 2 {
+		t.Fatalf("Query = %d, %v", total, err)
+	}
+	for _, c := range cps {
+		if len(c.Results) != 1 || c.Results[0].Error != long {
+			t.Errorf("run %s lost its results", c.Record.ID)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package matchspec
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const monthPattern = `(jan(?:uary)?|feb(?:ruary)?|mar(?:ch)?|apr(?:il)?|may|june?|july?|aug(?:ust)?|sept?(?:ember)?|oct(?:ober)?|nov(?:ember)?|dec(?:ember)?)\.?`
+
+var months = map[string]time.Month{
+	"jan": time.January, "feb": time.February, "mar": time.March, "apr": time.April,
+	"may": time.May, "jun": time.June, "jul": time.July, "aug": time.August,
+	"sep": time.September, "oct": time.October, "nov": time.November, "dec": time.December,
+}
+
+// datePatterns recognize calendar dates. Each reports year, month, and day
+// from its submatches. Slash dates with the year last are read as
+// month/day/year, and dotted dates as day.month.year.
+var datePatterns = []struct {
+	re  *regexp.Regexp
+	ymd func(m []string) (y, mo, d string)
+}{
+	{regexp.MustCompile(`\b(\d{4})[-/](\d{1,2})[-/](\d{1,2})`), func(m []string) (string, string, string) { return m[1], m[2], m[3] }},
+	{regexp.MustCompile(`\b(\d{1,2})/(\d{1,2})/(\d{4})\b`), func(m []string) (string, string, string) { return m[3], m[1], m[2] }},
+	{regexp.MustCompile(`\b(\d{1,2})\.(\d{1,2})\.(\d{4})\b`), func(m []string) (string, string, string) { return m[3], m[2], m[1] }},
+	{regexp.MustCompile(`(?i)\b` + monthPattern + `\s+(\d{1,2})(?:st|nd|rd|th)?,?\s+(\d{4})\b`), func(m []string) (string, string, string) { return m[3], m[1], m[2] }},
+	{regexp.MustCompile(`(?i)\b(\d{1,2})(?:st|nd|rd|th)?\s+(?:of\s+)?` + monthPattern + `,?\s+(\d{4})\b`), func(m []string) (string, string, string) { return m[3], m[2], m[1] }},
+}
+
+const clockPattern = `(\d{1,2})(?::(\d{2})(?::(\d{2}))?\s*([ap]\.?m\b\.?)?|\s*([ap]\.?m\b\.?))(?:\s*(Z|UTC\b|[+-]\d{2}:?\d{2}))?`
+
+var (
+	// dateTimePattern matches a time of day directly after a date.
+	dateTimePattern = regexp.MustCompile(`(?i)^(?:T|\s*,?\s*(?:at\s+)?)` + clockPattern)
+	timePattern     = regexp.MustCompile(`(?i)\b` + clockPattern)
+)
+
+// dateValue is a date, a time of day, or both.
+type dateValue struct {
+	t                time.Time
+	hasDate, hasTime bool
+}
+
+// String returns the canonical form: 2006-01-02, 15:04:05, or
+// 2006-01-02T15:04:05Z.
+func (v dateValue) String() string {
+	switch {
+	case v.hasDate && v.hasTime:
+		return v.t.Format("2006-01-02T15:04:05Z")
+	case v.hasDate:
+		return v.t.Format("2006-01-02")
+	}
+	return v.t.Format("15:04:05")
+}
+
+// equal compares v with want at want's granularity.
+func (v dateValue) equal(want dateValue) bool {
+	switch {
+	case want.hasDate && want.hasTime:
+		return v.hasDate && v.hasTime && v.t.Equal(want.t)
+	case want.hasDate:
+		return v.hasDate && v.t.Format("2006-01-02") == want.t.Format("2006-01-02")
+	}
+	return v.hasTime && v.t.Format("15:04:05") == want.t.Format("15:04:05")
+}
+
+// parseClock parses the submatches of clockPattern into hours, minutes,
+// seconds, and a zone offset in seconds.
+func parseClock(m []string) (h, min, sec, offset int, ok bool) {
+	h, _ = strconv.Atoi(m[0])
+	min, _ = strconv.Atoi(m[1])
+	sec, _ = strconv.Atoi(m[2])
+	ampm := strings.ToLower(strings.ReplaceAll(m[3]+m[4], ".", ""))
+	switch ampm {
+	case "am", "pm":
+		if h < 1 || h > 12 {
+			return 0, 0, 0, 0, false
+		}
+		h %= 12
+		if ampm == "pm" {
+			h += 12
+		}
+	}
+	if h > 23 || min > 59 || sec > 59 {
+		return 0, 0, 0, 0, false
+	}
+	if zone := strings.ReplaceAll(m[5], ":", ""); len(zone) == 5 {
+		zh, _ := strconv.Atoi(zone[1:3])
+		zm, _ := strconv.Atoi(zone[3:])
+		offset = zh*3600 + zm*60
+		if zone[0] == '-' {
+			offset = -offset
+		}
+	}
+	return h, min, sec, offset, true
+}
+
+// parseDates returns the dates and times in s, in order of appearance.
+// Times without a zone are taken as UTC.
+func parseDates(s string) []dateValue {
+	type span struct {
+		start, end int
+		v          dateValue
+	}
+	var found []span
+	taken := func(start, end int) bool {
+		return slices.ContainsFunc(found, func(f span) bool { return start < f.end && f.start < end })
+	}
+
+	for _, p := range datePatterns {
+		for _, loc := range p.re.FindAllStringSubmatchIndex(s, -1) {
+			if taken(loc[0], loc[1]) {
+				continue
+			}
+			m := make([]string, len(loc)/2)
+			for i := range m {
+				if loc[2*i] >= 0 {
+					m[i] = s[loc[2*i]:loc[2*i+1]]
+				}
+			}
+			ys, ms, ds := p.ymd(m)
+			y, _ := strconv.Atoi(ys)
+			mo, err := strconv.Atoi(ms)
+			if err != nil {
+				mo = int(months[strings.ToLower(ms)[:3]])
+			}
+			d, _ := strconv.Atoi(ds)
+			t := time.Date(y, time.Month(mo), d, 0, 0, 0, 0, time.UTC)
+			if mo < 1 || mo > 12 || t.Day() != d {
+				continue
+			}
+			v := span{start: loc[0], end: loc[1], v: dateValue{t: t, hasDate: true}}
+			if tm := dateTimePattern.FindStringSubmatchIndex(s[loc[1]:]); tm != nil {
+				sub := make([]string, 6)
+				for i := range sub {
+					if tm[2*i+2] >= 0 {
+						sub[i] = s[loc[1]+tm[2*i+2] : loc[1]+tm[2*i+3]]
+					}
+				}
+				if h, min, sec, off, ok := parseClock(sub); ok {
+					v.v.t = t.Add(time.Duration(h*3600+min*60+sec-off) * time.Second)
+					v.v.hasTime = true
+					v.end = loc[1] + tm[1]
+				}
+			}
+			found = append(found, v)
+		}
+	}
+
+	for _, loc := range timePattern.FindAllStringSubmatchIndex(s, -1) {
+		if taken(loc[0], loc[1]) {
+			continue
+		}
+		sub := make([]string, 6)
+		for i := range sub {
+			if loc[2*i+2] >= 0 {
+				sub[i] = s[loc[2*i+2]:loc[2*i+3]]
+			}
+		}
+		// A bare number is not a time; require minutes or am/pm.
+		if sub[1] == "" && sub[4] == "" {
+			continue
+		}
+		if h, min, sec, off, ok := parseClock(sub); ok {
+			t := time.Date(0, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(h*3600+min*60+sec-off) * time.Second)
+			found = append(found, span{start: loc[0], end: loc[1], v: dateValue{t: t, hasTime: true}})
+		}
+	}
+
+	slices.SortFunc(found, func(a, b span) int { return a.start - b.start })
+	out := make([]dateValue, len(found))
+	for i, f := range found {
+		out[i] = f.v
+	}
+	return out
+}
+
+// NormalizeDates returns the dates and times found in s in canonical form
+// (2006-01-02, 15:04:05, or 2006-01-02T15:04:05Z), in order of appearance.
+// It recognizes ISO 8601 dates and times, "March 3, 2024", "3 March 2024",
+// month/day/year and day.month.year dates, and 12- or 24-hour times.
+func NormalizeDates(s string) []string {
+	vals := parseDates(s)
+	out := make([]string, len(vals))
+	for i, v := range vals {
+		out[i] = v.String()
+	}
+	return out
+}
+
+// matchDate passes when every date or time in Expected appears in the
+// response, compared in canonical form at the granularity Expected gives:
+// a date alone matches any time that day. The score is the fraction of
+// expected values found.
+func matchDate(t *Task, response string) (bool, float64) {
+	want := parseDates(t.Expected)
+	if len(want) == 0 {
+		return false, 0.0
+	}
+	got := parseDates(response)
+	found := 0
+	for _, w := range want {
+		if slices.ContainsFunc(got, func(g dateValue) bool { return g.equal(w) }) {
+			found++
+		}
+	}
+	return found == len(want), float64(found) / float64(len(want))
+}
@@ -0,0 +1,49 @@
+package matchspec
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNormalizeDates(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"It was 2024-03-03.", []string{"2024-03-03"}},
+		{"On March 3, 2024 and on 4th of July, 2025.", []string{"2024-03-03", "2025-07-04"}},
+		{"Due 3 Mar. 2024", []string{"2024-03-03"}},
+		{"03/04/2024 vs 03.04.2024", []string{"2024-03-04", "2024-04-03"}},
+		{"2024-03-03T10:00:00+01:00", []string{"2024-03-03T09:00:00Z"}},
+		{"March 3, 2024 at 3:30 PM", []string{"2024-03-03T15:30:00Z"}},
+		{"Meet at 9am or 14:05.", []string{"09:00:00", "14:05:00"}},
+		{"Version 3 of 2024, February 30, 2024, 1.5 km", nil},
+	}
+	for _, tt := range tests {
+		if got := NormalizeDates(tt.in); !slices.Equal(got, tt.want) {
+			t.Errorf("NormalizeDates(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTaskMatchDate(t *testing.T) {
+	task := Task{Name: "t", Prompt: "p", Matcher: "date", Expected: "2024-03-03"}
+	tests := []struct {
+		expected, response string
+		wantPass           bool
+	}{
+		{"2024-03-03", "The launch happened on March 3, 2024.", true},
+		{"2024-03-03", "It was on 3/3/2024 at 10:00.", true},
+		{"2024-03-03", "The launch happened on March 4, 2024.", false},
+		{"2024-03-03T15:30", "March 3, 2024 at 3:30 pm", true},
+		{"2024-03-03T15:30", "March 3, 2024", false},
+		{"15:30", "Around 3:30 PM.", true},
+		{"not a date", "March 3, 2024", false},
+	}
+	for _, tt := range tests {
+		task.Expected = tt.expected
+		if passed, _ := task.Match(tt.response); passed != tt.wantPass {
+			t.Errorf("Match(%q) with Expected %q = %v, want %v", tt.response, tt.expected, passed, tt.wantPass)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package matchspec
+
+import (
+	"context"
+	"maps"
+)
+
+// DetailedMatcher is a Matcher that also explains its verdicts, such as
+// the regex groups it matched or where a JSON document differs from the
+// expected one. Matchers registered with RegisterMatcher may implement it;
+// their details are recorded on results (see Result.Details), so failures
+// explain themselves without a re-run. Details are keyed by name and must
+// encode as JSON.
+type DetailedMatcher interface {
+	Matcher
+	MatchDetails(task Task, response string) (bool, float64, map[string]any)
+}
+
+// DetailedMatcherFunc adapts an ordinary function to the DetailedMatcher
+// interface.
+type DetailedMatcherFunc func(task Task, response string) (bool, float64, map[string]any)
+
+// Match calls f(task, response) and drops the details.
+func (f DetailedMatcherFunc) Match(task Task, response string) (bool, float64) {
+	passed, score, _ := f(task, response)
+	return passed, score
+}
+
+// MatchDetails calls f(task, response).
+func (f DetailedMatcherFunc) MatchDetails(task Task, response string) (bool, float64, map[string]any) {
+	return f(task, response)
+}
+
+type matchDetailsKey struct{}
+
+// matchDetails collects the details matchers attach while a response is
+// matched.
+type matchDetails struct {
+	m map[string]any
+}
+
+// withMatchDetails returns ctx carrying a new collector of match details.
+func withMatchDetails(ctx context.Context) (context.Context, *matchDetails) {
+	d := &matchDetails{}
+	return context.WithValue(ctx, matchDetailsKey{}, d), d
+}
+
+// addMatchDetails records details on the collector carried by ctx, if
+// any. Later keys replace earlier ones of the same name.
+func addMatchDetails(ctx context.Context, details map[string]any) {
+	d, _ := ctx.Value(matchDetailsKey{}).(*matchDetails)
+	if d == nil || len(details) == 0 {
+		return
+	}
+	if d.m == nil {
+		d.m = make(map[string]any, len(details))
+	}
+	maps.Copy(d.m, details)
+}
+
+// details returns the collected details, or nil if there are none.
+func (d *matchDetails) details() map[string]any {
+	return d.m
+}
+
+// listDetails wraps match so that each call, one per assertion or
+// acceptable answer, collects its details apart from the others. The
+// returned done records them on ctx as a list under key, in call order
+// with nil for calls without details, if any call had some.
+func listDetails(ctx context.Context, key string, match func(ctx context.Context, t *Task, response string) (bool, float64, error)) (func(t *Task, response string) (bool, float64, error), func()) {
+	var list []any
+	found := false
+	each := func(t *Task, response string) (bool, float64, error) {
+		cctx, d := withMatchDetails(ctx)
+		passed, score, err := match(cctx, t, response)
+		if m := d.details(); m != nil {
+			list = append(list, m)
+			found = true
+		} else {
+			list = append(list, nil)
+		}
+		return passed, score, err
+	}
+	done := func() {
+		if found {
+			addMatchDetails(ctx, map[string]any{key: list})
+		}
+	}
+	return each, done
+}
+
+// redactDetails applies the runner's redactors to every string in details.
+func (r *Runner) redactDetails(details map[string]any) map[string]any {
+	if details == nil || len(r.redactors) == 0 {
+		return details
+	}
+	return mapStrings(details, r.redact).(map[string]any)
+}
+
+// mapStrings returns a copy of v, a decoded JSON value, with f applied to
+// every string in it. Other values are returned as they are.
+func mapStrings(v any, f func(string) string) any {
+	switch v := v.(type) {
+	case string:
+		return f(v)
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			out[k] = mapStrings(e, f)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = mapStrings(e, f)
+		}
+		return out
+	case []string:
+		out := make([]string, len(v))
+		for i, e := range v {
+			out[i] = f(e)
+		}
+		return out
+	}
+	return v
+}
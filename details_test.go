@@ -0,0 +1,98 @@
+package matchspec
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestMatchDetails(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		task Task
+		resp string
+		want string
+	}{
+		{"regex", Task{Matcher: "regex", Expected: `(?P<id>INV-\d+) (?P<total>\S+)`, Captures: map[string]string{"id": "INV-7", "total": "$42.00"}},
+			"Invoice INV-7 $41.00", `{"groups":{"id":"INV-7","total":"$41.00"},"match":"INV-7 $41.00","matched":true,"mismatched":["total"]}`},
+		{"regex miss", Task{Matcher: "regex", Expected: `\d+`}, "none", `{"matched":false}`},
+		{"diff", Task{Matcher: "diff", Expected: "the quick brown fox"}, "the slow brown fox jumps",
+			`{"added":2,"ratio":0.6666666666666666,"removed":1,"threshold":1}`},
+		{"json", Task{Matcher: "json", Expected: `{"a":1,"b":[1,2],"c":{"d":"x"}}`}, `{"a":2,"b":[1],"c":{},"e":true}`,
+			`{"mismatches":["$.a: want 1, got 2","$.b: want 2 elements, got 1","$.c.d: missing","$.e: unexpected"]}`},
+		{"json invalid", Task{Matcher: "json", Expected: `{}`}, "not json", `{"error":"response: invalid character 'o' in literal null (expecting 'u')"}`},
+		{"plain", Task{Matcher: "contains", Expected: "x"}, "x", `null`},
+	} {
+		_, _, details := tt.task.matchDetails(tt.resp)
+		b, _ := json.Marshal(details)
+		if string(b) != tt.want {
+			t.Errorf("%s: details = %s, want %s", tt.name, b, tt.want)
+		}
+	}
+}
+
+func TestResultDetails(t *testing.T) {
+	RegisterMatcher("test-detailed", DetailedMatcherFunc(func(task Task, response string) (bool, float64, map[string]any) {
+		return true, 1, map[string]any{"seen": response}
+	}))
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "d", Tasks: []Task{
+		{Name: "custom", Prompt: "secret-1", Matcher: "test-detailed"},
+		{Name: "asserts", Prompt: "7", Assertions: []Assertion{
+			{Matcher: "contains", Expected: "7"},
+			{Matcher: "regex", Expected: `(?P<n>\d)`},
+		}},
+		{Name: "any", Prompt: "7", Matcher: "regex", ExpectedAny: []string{`8`, `7`}},
+		{Name: "grounded", Prompt: "Capital of France?", Documents: []string{"Paris is the capital of France."}, Matcher: "grounded",
+			Matchers: []string{"regex"}, Expected: `x`},
+		{Name: "plain", Prompt: "1", Expected: "1"},
+	}})
+	judge := func(ctx context.Context, prompt string) (string, error) {
+		return "NO. The documents do not mention echoes.", nil
+	}
+	redactor := RedactorFunc(func(s string) string { return strings.ReplaceAll(s, "secret-1", "[redacted]") })
+	runner := NewRunner(reg, echoInfer, tokentrace.NewReporter("matchspec", ""), WithJudge(judge), WithRedactor(redactor))
+	results, err := runner.RunDetailed(context.Background(), protocol.EvalRun{Suite: "d"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{
+		`{"seen":"echo: [redacted]"}`,
+		`{"assertions":[null,{"groups":{"n":"7"},"match":"7","matched":true}]}`,
+		`{"expected_any":[{"matched":false},{"match":"7","matched":true}]}`,
+		// The extra regex matcher's details are not the grounded verdict's.
+		`{"rationale":"The documents do not mention echoes."}`,
+		`null`,
+	} {
+		b, _ := json.Marshal(results[i].Details)
+		if string(b) != want {
+			t.Errorf("%s details = %s, want %s", results[i].Task, b, want)
+		}
+	}
+
+	res := results[1]
+	if !EraseResult(&res, regexp.MustCompile(`^7$`)) || res.Details["assertions"].([]any)[1].(map[string]any)["match"] != ErasedText {
+		t.Errorf("erased details = %v", res.Details)
+	}
+	if results[1].Details["assertions"].([]any)[1].(map[string]any)["match"] != "7" {
+		t.Error("erasing a copy changed the original details")
+	}
+}
+
+func TestJudgeRationale(t *testing.T) {
+	for in, want := range map[string]string{
+		"YES":                        "",
+		"yes - every claim is cited": "every claim is cited",
+		"NO: Paris is not mentioned": "Paris is not mentioned",
+		"maybe":                      "",
+	} {
+		if got := judgeRationale(in); got != want {
+			t.Errorf("judgeRationale(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package matchspec
+
+import (
+	"slices"
+	"strings"
+)
+
+// WithVerbose records matcher details on results for human review, such as
+// the diff between Expected and the response for the "diff" matcher.
+func WithVerbose() RunnerOption {
+	return func(r *Runner) {
+		r.verbose = true
+	}
+}
+
+// diffTokens splits text for diffing: into lines if it spans several
+// lines, otherwise into words.
+func diffTokens(expected, s string) []string {
+	if strings.Contains(strings.TrimSpace(expected), "\n") {
+		return strings.Split(strings.TrimRight(strings.ReplaceAll(s, "\r\n", "\n"), "\n"), "\n")
+	}
+	return strings.Fields(s)
+}
+
+// diffOp is one step of an edit script: a token kept (' '), deleted from
+// the first sequence ('-'), or inserted from the second ('+').
+type diffOp struct {
+	kind byte
+	text string
+}
+
+// myers runs Myers' O((N+M)D) algorithm and returns D, the length of a
+// shortest edit script from a to b. If trace is non-nil, it receives the
+// furthest-reaching x of every diagonal, round by round, for backtrack;
+// without it memory use is O(N+M).
+func myers(a, b []string, trace *[][]int) int {
+	n, m := len(a), len(b)
+	off := n + m + 1
+	v := make([]int, 2*off+1)
+	for d := 0; ; d++ {
+		if trace != nil {
+			*trace = append(*trace, append([]int(nil), v[off-d-1:off+d+2]...))
+		}
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				return d
+			}
+		}
+	}
+}
+
+// diffSeqs returns a shortest edit script from a to b.
+func diffSeqs(a, b []string) []diffOp {
+	var trace [][]int
+	d := myers(a, b, &trace)
+
+	var ops []diffOp
+	x, y := len(a), len(b)
+	for ; d > 0; d-- {
+		// trace[d] holds diagonals -d-1 through d+1.
+		v := func(k int) int { return trace[d][k+d+1] }
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && v(k-1) < v(k+1)) {
+			prevK = k + 1
+		}
+		prevX := v(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, diffOp{' ', a[x]})
+		}
+		if x == prevX {
+			y--
+			ops = append(ops, diffOp{'+', b[y]})
+		} else {
+			x--
+			ops = append(ops, diffOp{'-', a[x]})
+		}
+	}
+	for x > 0 {
+		x--
+		ops = append(ops, diffOp{' ', a[x]})
+	}
+	slices.Reverse(ops)
+	return ops
+}
+
+// DiffRatio returns the similarity of expected and response as 2*M/T,
+// where M is the number of tokens the two share in order and T the total
+// number of tokens in both. Texts spanning several lines are compared line
+// by line, others word by word. Two empty texts have ratio 1.
+func DiffRatio(expected, response string) float64 {
+	a, b := diffTokens(expected, expected), diffTokens(expected, response)
+	if len(a)+len(b) == 0 {
+		return 1
+	}
+	// Each edit is one token in a or b that the other lacks.
+	same := len(a) + len(b) - myers(a, b, nil)
+	return float64(same) / float64(len(a)+len(b))
+}
+
+// Diff returns the differences between expected and response, one token
+// per line prefixed with "-" for tokens only in expected, "+" for tokens
+// only in the response, and " " for tokens in both. Tokens are lines or
+// words as in DiffRatio. It returns "" if there are no differences.
+func Diff(expected, response string) string {
+	ops := diffSeqs(diffTokens(expected, expected), diffTokens(expected, response))
+	var sb strings.Builder
+	changed := false
+	for _, op := range ops {
+		changed = changed || op.kind != ' '
+		sb.WriteByte(op.kind)
+		sb.WriteString(op.text)
+		sb.WriteByte('\n')
+	}
+	if !changed {
+		return ""
+	}
+	return sb.String()
+}
+
+// matchDiff scores the response by DiffRatio and passes when the ratio
+// reaches Threshold, or is 1 if Threshold is unset. Its details summarize
+// the diff: the ratio, the threshold, and the tokens removed and added.
+func matchDiff(t *Task, response string) (bool, float64, map[string]any) {
+	ratio := DiffRatio(t.Expected, response)
+	threshold := t.Threshold
+	if threshold == 0 {
+		threshold = 1
+	}
+	return ratio >= threshold, ratio, diffSummary(t.Expected, response, ratio, threshold)
+}
+
+// diffSummary counts the tokens Diff would remove and add.
+func diffSummary(expected, response string, ratio, threshold float64) map[string]any {
+	removed, added := 0, 0
+	for _, op := range diffSeqs(diffTokens(expected, expected), diffTokens(expected, response)) {
+		switch op.kind {
+		case '-':
+			removed++
+		case '+':
+			added++
+		}
+	}
+	return map[string]any{"ratio": ratio, "threshold": threshold, "removed": removed, "added": added}
+}
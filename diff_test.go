@@ -0,0 +1,88 @@
+package matchspec
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestDiffRatio(t *testing.T) {
+	tests := []struct {
+		expected, response string
+		want               float64
+	}{
+		{"the quick brown fox", "the quick brown fox", 1},
+		{"the quick brown fox", "the  quick\tbrown fox", 1},
+		{"the quick brown fox", "the slow brown fox", 0.75},
+		{"the quick brown fox", "", 0},
+		{"", "", 1},
+		// Multi-line text is compared by line.
+		{"a b\nc d\ne f\n", "a b\nc d\nx y", 2.0 * 2 / 6},
+	}
+	for _, tt := range tests {
+		if got := DiffRatio(tt.expected, tt.response); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("DiffRatio(%q, %q) = %f, want %f", tt.expected, tt.response, got, tt.want)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	got := Diff("one\ntwo\nthree\n", "one\n2\nthree\nfour\n")
+	want := " one\n-two\n+2\n three\n+four\n"
+	if got != want {
+		t.Errorf("Diff = %q, want %q", got, want)
+	}
+	if got := Diff("same words", "same  words"); got != "" {
+		t.Errorf("Diff of equal texts = %q, want empty", got)
+	}
+}
+
+func TestTaskMatchDiff(t *testing.T) {
+	task := Task{Name: "t", Matcher: "diff", Expected: "the quick brown fox"}
+	if passed, score := task.Match("the slow brown fox"); passed || score != 0.75 {
+		t.Errorf("Match = %v, %f; want false, 0.75", passed, score)
+	}
+	task.Threshold = 0.7
+	if passed, _ := task.Match("the slow brown fox"); !passed {
+		t.Error("Match below threshold failed, want pass")
+	}
+}
+
+func TestRunnerVerboseDiff(t *testing.T) {
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "essay", Tasks: []Task{
+		{Name: "close", Prompt: "a b c", Matcher: "diff", Expected: "echo: a b d", Threshold: 0.5},
+		{Name: "same", Prompt: "a b c", Matcher: "diff", Expected: "echo: a b c"},
+	}})
+
+	run := func(opts ...RunnerOption) []Result {
+		runner := NewRunner(reg, echoInfer, tokentrace.NewReporter("matchspec", ""), opts...)
+		results, err := runner.RunDetailed(context.Background(), protocol.EvalRun{Suite: "essay"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return results
+	}
+
+	for _, res := range run() {
+		if res.Diff != "" {
+			t.Errorf("%s: diff recorded without WithVerbose", res.Task)
+		}
+	}
+	for _, res := range run(WithVerbose()) {
+		switch res.Task {
+		case "close":
+			if !res.Passed || !strings.Contains(res.Diff, "-d\n+c\n") {
+				t.Errorf("close: passed=%v diff=%q", res.Passed, res.Diff)
+			}
+		case "same":
+			if !res.Passed || res.Diff != "" {
+				t.Errorf("same: passed=%v diff=%q", res.Passed, res.Diff)
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package matchspec
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+)
+
+// ScoringCurve maps a task's difficulty to its weight in the aggregate
+// score. Tasks without a difficulty are weighted as difficulty 1.
+type ScoringCurve func(difficulty float64) float64
+
+// DefaultScoringCurve is the curve used for Summary.Weighted.
+const DefaultScoringCurve = "linear"
+
+// ScoringCurves are the built-in curves by name: "uniform" weighs every
+// task alike, "linear" by difficulty, "quadratic" by its square, and
+// "exponential" doubles the weight with each level of difficulty.
+var ScoringCurves = map[string]ScoringCurve{
+	"uniform":     func(float64) float64 { return 1 },
+	"linear":      func(d float64) float64 { return d },
+	"quadratic":   func(d float64) float64 { return d * d },
+	"exponential": func(d float64) float64 { return math.Exp2(d - 1) },
+}
+
+// WeightedSummary is the difficulty-weighted aggregate of a result set.
+type WeightedSummary struct {
+	Curve string `json:"curve"`
+
+	// Score and PassRate are the means of score and pass (1 or 0) with
+	// each result weighted by the curve.
+	Score       float64 `json:"score"`
+	PassRate    float64 `json:"pass_rate"`
+	TotalWeight float64 `json:"total_weight"`
+
+	// Levels break results down by difficulty, easiest first.
+	Levels []DifficultyStats `json:"levels"`
+}
+
+// DifficultyStats summarizes the results at one difficulty.
+type DifficultyStats struct {
+	Difficulty float64 `json:"difficulty"`
+	Weight     float64 `json:"weight"`
+	Total      int     `json:"total"`
+	Passed     int     `json:"passed"`
+	PassRate   float64 `json:"pass_rate"`
+	MeanScore  float64 `json:"mean_score"`
+}
+
+// WeightedScores aggregates results with each weighted by the named
+// scoring curve (see ScoringCurves) applied to its difficulty.
+func WeightedScores(results []Result, curve string) (WeightedSummary, error) {
+	if _, ok := ScoringCurves[curve]; !ok {
+		return WeightedSummary{}, fmt.Errorf("matchspec: unknown scoring curve %q", curve)
+	}
+	var c difficultyCounter
+	for _, r := range results {
+		c.add(r)
+	}
+	return c.summary(curve), nil
+}
+
+// difficultyCounter accumulates per-difficulty counts for a weighted
+// summary.
+type difficultyCounter struct {
+	levels map[float64]*DifficultyStats
+	rated  bool
+}
+
+func (c *difficultyCounter) add(r Result) {
+	d := r.Difficulty
+	if d <= 0 {
+		d = 1
+	} else {
+		c.rated = true
+	}
+	if c.levels == nil {
+		c.levels = make(map[float64]*DifficultyStats)
+	}
+	l := c.levels[d]
+	if l == nil {
+		l = &DifficultyStats{Difficulty: d}
+		c.levels[d] = l
+	}
+	l.Total++
+	if r.Passed {
+		l.Passed++
+	}
+	// MeanScore holds the sum until summary.
+	l.MeanScore += r.Score
+}
+
+// summary returns the weighted summary under curve, or the zero value if
+// no result has a difficulty.
+func (c *difficultyCounter) summary(curve string) WeightedSummary {
+	w := WeightedSummary{Curve: curve}
+	f := ScoringCurves[curve]
+	var score, passed float64
+	for _, d := range slices.Sorted(maps.Keys(c.levels)) {
+		l := *c.levels[d]
+		l.Weight = max(f(d), 0)
+		score += l.Weight * l.MeanScore
+		passed += l.Weight * float64(l.Passed)
+		w.TotalWeight += l.Weight * float64(l.Total)
+		l.PassRate = float64(l.Passed) / float64(l.Total)
+		l.MeanScore /= float64(l.Total)
+		w.Levels = append(w.Levels, l)
+	}
+	if w.TotalWeight > 0 {
+		w.Score = score / w.TotalWeight
+		w.PassRate = passed / w.TotalWeight
+	}
+	return w
+}
@@ -0,0 +1,61 @@
+package matchspec
+
+import (
+	"math"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+)
+
+func TestWeightedScores(t *testing.T) {
+	res := func(d float64, passed bool) Result {
+		r := Result{EvalResult: protocol.EvalResult{Passed: passed}, Difficulty: d}
+		if passed {
+			r.Score = 1
+		}
+		return r
+	}
+	// Easy tasks pass, the hard one fails.
+	results := []Result{res(1, true), res(1, true), res(3, false)}
+
+	tests := []struct {
+		curve    string
+		wantPass float64
+	}{
+		{"uniform", 2.0 / 3},
+		{"linear", 2.0 / 5},
+		{"quadratic", 2.0 / 11},
+		{"exponential", 2.0 / 6},
+	}
+	for _, tt := range tests {
+		w, err := WeightedScores(results, tt.curve)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(w.PassRate-tt.wantPass) > 1e-9 || w.Score != w.PassRate {
+			t.Errorf("%s: pass rate %f, score %f; want %f", tt.curve, w.PassRate, w.Score, tt.wantPass)
+		}
+	}
+
+	w, _ := WeightedScores(results, "linear")
+	if len(w.Levels) != 2 || w.Levels[0].Difficulty != 1 || w.Levels[0].Passed != 2 || w.Levels[1].Weight != 3 || w.Levels[1].PassRate != 0 {
+		t.Errorf("levels = %+v", w.Levels)
+	}
+	if _, err := WeightedScores(results, "cubic"); err == nil {
+		t.Error("unknown curve accepted")
+	}
+}
+
+func TestSummarizeWeighted(t *testing.T) {
+	if s := Summarize([]Result{{EvalResult: protocol.EvalResult{Passed: true, Score: 1}}}); s.Weighted != nil {
+		t.Errorf("Weighted = %+v without difficulties, want nil", s.Weighted)
+	}
+	// An unrated task counts as difficulty 1.
+	s := Summarize([]Result{
+		{EvalResult: protocol.EvalResult{Passed: true, Score: 1}},
+		{EvalResult: protocol.EvalResult{Passed: false, Score: 0.5}, Difficulty: 4},
+	})
+	if s.Weighted == nil || s.Weighted.Curve != DefaultScoringCurve || math.Abs(s.Weighted.Score-3.0/5) > 1e-9 || math.Abs(s.Weighted.PassRate-1.0/5) > 1e-9 {
+		t.Errorf("Weighted = %+v", s.Weighted)
+	}
+}
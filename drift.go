@@ -0,0 +1,165 @@
+package matchspec
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/greynewell/mist-go/protocol"
+)
+
+// Drift monitoring defaults.
+const (
+	DefaultDriftWindow  = 10
+	DefaultDriftMinRuns = 3
+)
+
+// DriftConfig configures drift detection: how a suite's trailing baseline
+// is formed and how far a run may move from it.
+type DriftConfig struct {
+	// Interval is the time between runs in MonitorDrift.
+	Interval time.Duration `json:"-"`
+
+	// Window is the number of preceding runs averaged into the baseline
+	// (default DefaultDriftWindow). MinRuns is the number needed before
+	// drift is checked at all (default DefaultDriftMinRuns).
+	Window  int `json:"window,omitempty"`
+	MinRuns int `json:"min_runs,omitempty"`
+
+	// MaxPassRateDelta and MaxScoreDelta are the largest changes in pass
+	// rate and mean score from the baseline, in either direction, that do
+	// not raise an alert. Zero disables the check.
+	MaxPassRateDelta float64 `json:"max_pass_rate_delta,omitempty"`
+	MaxScoreDelta    float64 `json:"max_score_delta,omitempty"`
+
+	// Labels, if set, limits the baseline to runs carrying all of them,
+	// such as "nightly", so ad hoc runs do not move it.
+	Labels []string `json:"labels,omitempty"`
+}
+
+// DriftAlert reports a run whose metric moved too far from its suite's
+// trailing baseline.
+type DriftAlert struct {
+	Suite string `json:"suite"`
+	Model string `json:"model,omitempty"`
+	RunID string `json:"run_id"`
+
+	// Metric is "pass_rate" or "mean_score". Delta is Value minus
+	// Baseline, the mean over BaselineRuns preceding runs.
+	Metric       string  `json:"metric"`
+	Value        float64 `json:"value"`
+	Baseline     float64 `json:"baseline"`
+	Delta        float64 `json:"delta"`
+	MaxDelta     float64 `json:"max_delta"`
+	BaselineRuns int     `json:"baseline_runs"`
+
+	At time.Time `json:"at"`
+}
+
+func (a DriftAlert) String() string {
+	return fmt.Sprintf("drift: suite %q %s %.3f is %+.3f from its baseline %.3f over %d runs (max %.3f)",
+		a.Suite, a.Metric, a.Value, a.Delta, a.Baseline, a.BaselineRuns, a.MaxDelta)
+}
+
+// DriftAlertFunc receives drift alerts from MonitorDrift.
+type DriftAlertFunc func(ctx context.Context, alert DriftAlert)
+
+// CheckDrift compares a run with the trailing baseline of its suite: the
+// runs of the same suite and model that started before it, up to
+// cfg.Window of them, newest first. Runs that ended in an error are left
+// out of the baseline, and are not checked themselves.
+func (r *Runner) CheckDrift(rec RunRecord, cfg DriftConfig) []DriftAlert {
+	window := cfg.Window
+	if window <= 0 {
+		window = DefaultDriftWindow
+	}
+	minRuns := cfg.MinRuns
+	if minRuns <= 0 {
+		minRuns = DefaultDriftMinRuns
+	}
+	if rec.Error != "" {
+		return nil
+	}
+
+	prior, _ := r.Runs(RunFilter{Suite: rec.Suite, Model: rec.Model, Labels: cfg.Labels})
+	var n int
+	var passRate, score float64
+	for _, p := range prior {
+		if n == window {
+			break
+		}
+		if p.ID == rec.ID || p.Error != "" || !p.StartedAt.Before(rec.StartedAt) {
+			continue
+		}
+		passRate += p.Summary.PassRate
+		score += p.Summary.MeanScore
+		n++
+	}
+	if n < minRuns {
+		return nil
+	}
+
+	var alerts []DriftAlert
+	check := func(metric string, value, baseline, max float64) {
+		if max <= 0 || math.Abs(value-baseline) <= max {
+			return
+		}
+		alerts = append(alerts, DriftAlert{
+			Suite:        rec.Suite,
+			Model:        rec.Model,
+			RunID:        rec.ID,
+			Metric:       metric,
+			Value:        value,
+			Baseline:     baseline,
+			Delta:        value - baseline,
+			MaxDelta:     max,
+			BaselineRuns: n,
+			At:           rec.FinishedAt,
+		})
+	}
+	check("pass_rate", rec.Summary.PassRate, passRate/float64(n), cfg.MaxPassRateDelta)
+	check("mean_score", rec.Summary.MeanScore, score/float64(n), cfg.MaxScoreDelta)
+	return alerts
+}
+
+// MonitorDrift runs the suite now and then every cfg.Interval until ctx is
+// done, checking each run with CheckDrift and passing any alerts to alert.
+// Suites with "snapshot" tasks also catch drift in responses that have no
+// expected output. A run that fails to start, such as for an unknown
+// suite, stops the monitor with its error; other run errors are recorded
+// on the run and do not.
+func (r *Runner) MonitorDrift(ctx context.Context, run protocol.EvalRun, cfg DriftConfig, alert DriftAlertFunc) error {
+	if cfg.Interval <= 0 {
+		return fmt.Errorf("matchspec: drift monitor interval must be positive")
+	}
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+	// A busy runner delays a scheduled run rather than stopping the monitor.
+	ctx = context.WithValue(ctx, gateWaitKey{}, true)
+	for {
+		_, id, err := r.run(ctx, run)
+		if id == "" && err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if rec, ok := r.GetRun(id); ok {
+			for _, a := range r.CheckDrift(rec, cfg) {
+				alert(ctx, a)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// PostDriftAlert sends alert as JSON to a webhook URL.
+func PostDriftAlert(ctx context.Context, client *http.Client, url string, alert DriftAlert) error {
+	return postJSON(ctx, client, url, "drift alert", alert)
+}
@@ -0,0 +1,120 @@
+package matchspec
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func driftRegistry() *SuiteRegistry {
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "math", Tasks: []Task{
+		{Name: "add", Prompt: "What is 2+2?", Expected: "4", Matcher: "exact"},
+		{Name: "mul", Prompt: "What is 3*4?", Expected: "12", Matcher: "exact"},
+	}})
+	return reg
+}
+
+func TestCheckDrift(t *testing.T) {
+	var broken atomic.Bool
+	infer := func(ctx context.Context, prompt string) (string, error) {
+		if broken.Load() && prompt == "What is 3*4?" {
+			return "11", nil
+		}
+		return map[string]string{"What is 2+2?": "4", "What is 3*4?": "12"}[prompt], nil
+	}
+	reg := driftRegistry()
+	runner := NewRunner(reg, infer, tokentrace.NewReporter("matchspec", ""))
+	cfg := DriftConfig{Window: 3, MinRuns: 2, MaxPassRateDelta: 0.2}
+	run := protocol.EvalRun{Suite: "math", Tags: map[string]string{"model": "m"}}
+
+	latest := func() RunRecord {
+		t.Helper()
+		if _, err := runner.Run(context.Background(), run); err != nil {
+			t.Fatal(err)
+		}
+		runs, _ := runner.Runs(RunFilter{Limit: 1})
+		return runs[0]
+	}
+
+	// Too few runs for a baseline.
+	latest()
+	if alerts := runner.CheckDrift(latest(), cfg); len(alerts) != 0 {
+		t.Fatalf("alerts with one prior run = %+v", alerts)
+	}
+	if alerts := runner.CheckDrift(latest(), cfg); len(alerts) != 0 {
+		t.Fatalf("alerts for a stable run = %+v", alerts)
+	}
+
+	broken.Store(true)
+	rec := latest()
+	alerts := runner.CheckDrift(rec, cfg)
+	if len(alerts) != 1 {
+		t.Fatalf("alerts = %+v, want one", alerts)
+	}
+	if a := alerts[0]; a.Metric != "pass_rate" || a.Value != 0.5 || a.Baseline != 1 || a.Delta != -0.5 || a.BaselineRuns != 3 || a.RunID != rec.ID {
+		t.Errorf("alert = %+v", a)
+	}
+
+	// Another model has its own baseline.
+	other := protocol.EvalRun{Suite: "math", Tags: map[string]string{"model": "other"}}
+	runner.Run(context.Background(), other)
+	runs, _ := runner.Runs(RunFilter{Model: "other"})
+	if alerts := runner.CheckDrift(runs[0], cfg); len(alerts) != 0 {
+		t.Errorf("alerts across models = %+v", alerts)
+	}
+}
+
+func TestMonitorDrift(t *testing.T) {
+	var calls atomic.Int32
+	infer := func(ctx context.Context, prompt string) (string, error) {
+		// Passes for the first two runs (four calls), then fails.
+		if calls.Add(1) > 4 {
+			return "wrong", nil
+		}
+		return map[string]string{"What is 2+2?": "4", "What is 3*4?": "12"}[prompt], nil
+	}
+	reg := driftRegistry()
+	runner := NewRunner(reg, infer, tokentrace.NewReporter("matchspec", ""))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var got []DriftAlert
+	err := runner.MonitorDrift(ctx, protocol.EvalRun{Suite: "math"}, DriftConfig{
+		Interval: time.Millisecond, MinRuns: 2, MaxScoreDelta: 0.5,
+	}, func(ctx context.Context, a DriftAlert) {
+		got = append(got, a)
+		cancel()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Metric != "mean_score" || got[0].Value != 0 {
+		t.Errorf("alerts = %+v", got)
+	}
+
+	if err := runner.MonitorDrift(context.Background(), protocol.EvalRun{Suite: "missing"}, DriftConfig{Interval: time.Second}, nil); err == nil {
+		t.Error("expected error for unknown suite")
+	}
+}
+
+func TestPostDriftAlert(t *testing.T) {
+	var got DriftAlert
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+	if err := PostDriftAlert(context.Background(), srv.Client(), srv.URL, DriftAlert{Suite: "s", Metric: "pass_rate"}); err != nil {
+		t.Fatal(err)
+	}
+	if got.Suite != "s" || got.Metric != "pass_rate" {
+		t.Errorf("posted %+v", got)
+	}
+}
@@ -0,0 +1,201 @@
+package matchspec
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// encryptedMagic starts every artifact sealed by a Cipher.
+const encryptedMagic = "MSENC1\x00"
+
+// ErrNotEncrypted is returned by Cipher.Open for data that was not sealed
+// by a Cipher.
+var ErrNotEncrypted = errors.New("matchspec: data is not encrypted")
+
+// Cipher encrypts artifacts at rest, such as cached responses, cached
+// runs, and snapshot baselines, with AES-GCM. It works on whole files, so
+// it serves object storage as well as the Dir stores (see EncryptWith).
+type Cipher struct {
+	current [4]byte
+	keys    map[[4]byte]cipher.AEAD
+}
+
+// NewCipher returns a cipher that encrypts with key, which must be 16, 24,
+// or 32 bytes (AES-128, -192, or -256). Artifacts encrypted with any of
+// previous can still be read, so keys can be rotated without rewriting
+// every file at once.
+func NewCipher(key []byte, previous ...[]byte) (*Cipher, error) {
+	c := &Cipher{keys: make(map[[4]byte]cipher.AEAD)}
+	for i, k := range append([][]byte{key}, previous...) {
+		block, err := aes.NewCipher(k)
+		if err != nil {
+			return nil, fmt.Errorf("matchspec: encryption key: %w", err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("matchspec: encryption key: %w", err)
+		}
+		id := keyID(k)
+		if i == 0 {
+			c.current = id
+		}
+		if _, ok := c.keys[id]; !ok {
+			c.keys[id] = aead
+		}
+	}
+	return c, nil
+}
+
+// CipherFromEnv returns a cipher whose key is the base64-encoded value of
+// the environment variable name. Previous keys, for rotation, may follow
+// it separated by commas.
+func CipherFromEnv(name string) (*Cipher, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil, fmt.Errorf("matchspec: encryption key env %s is not set", name)
+	}
+	var keys [][]byte
+	for _, s := range strings.Split(value, ",") {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("matchspec: encryption key env %s: %w", name, err)
+		}
+		keys = append(keys, key)
+	}
+	return NewCipher(keys[0], keys[1:]...)
+}
+
+// KeyFunc fetches data keys, current first, from a key management
+// service: for example by asking the KMS to decrypt a wrapped data key.
+type KeyFunc func(ctx context.Context) ([][]byte, error)
+
+// CipherFromKeyFunc returns a cipher with the keys fn returns.
+func CipherFromKeyFunc(ctx context.Context, fn KeyFunc) (*Cipher, error) {
+	keys, err := fn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("matchspec: fetching encryption key: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("matchspec: key function returned no keys")
+	}
+	return NewCipher(keys[0], keys[1:]...)
+}
+
+func keyID(key []byte) [4]byte {
+	sum := sha256.Sum256(key)
+	return [4]byte(sum[:4])
+}
+
+// Seal encrypts data with the current key. name identifies the artifact,
+// such as its cache key; it is authenticated but not stored, so an
+// artifact only opens under the name it was sealed with and files cannot
+// be swapped.
+func (c *Cipher) Seal(data []byte, name string) ([]byte, error) {
+	aead := c.keys[c.current]
+	header := slices.Concat([]byte(encryptedMagic), c.current[:])
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("matchspec: encrypt: %w", err)
+	}
+	return aead.Seal(slices.Concat(header, nonce), nonce, data, slices.Concat(header, []byte(name))), nil
+}
+
+// Open decrypts an artifact sealed by Seal under name with any of the
+// cipher's keys. Data that is not encrypted fails with an error wrapping
+// ErrNotEncrypted, so a plain file planted where an encrypted one belongs
+// is not trusted; see ReadPlaintext to accept such files while migrating.
+func (c *Cipher) Open(data []byte, name string) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(encryptedMagic)) {
+		return nil, fmt.Errorf("%w: %s", ErrNotEncrypted, name)
+	}
+	n := len(encryptedMagic) + 4
+	if len(data) < n {
+		return nil, fmt.Errorf("matchspec: decrypt %s: truncated header", name)
+	}
+	header := data[:n]
+	aead, ok := c.keys[[4]byte(header[len(encryptedMagic):])]
+	if !ok {
+		return nil, fmt.Errorf("matchspec: decrypt %s: encrypted with an unknown key", name)
+	}
+	rest := data[n:]
+	if len(rest) < aead.NonceSize() {
+		return nil, fmt.Errorf("matchspec: decrypt %s: truncated nonce", name)
+	}
+	plain, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], slices.Concat(header, []byte(name)))
+	if err != nil {
+		return nil, fmt.Errorf("matchspec: decrypt %s: %w", name, err)
+	}
+	return plain, nil
+}
+
+// StoreOption configures a Dir store (DirResponseCache, DirRunCache,
+// DirSnapshotStore, or DirBaselineStore) or a SpoolResultStore.
+type StoreOption func(*storeConfig)
+
+type storeConfig struct {
+	cipher        *Cipher
+	compress      bool
+	readPlaintext bool
+}
+
+// EncryptWith encrypts the files a Dir store writes with c, and decrypts
+// them on read. Files that are not encrypted are errors unless the store
+// also has ReadPlaintext.
+func EncryptWith(c *Cipher) StoreOption {
+	return func(sc *storeConfig) { sc.cipher = c }
+}
+
+// ReadPlaintext lets a store with EncryptWith read files that are not
+// encrypted, for turning encryption on for a store that already holds
+// plain files; they are encrypted as they are rewritten. Anyone who can
+// write to the store can then plant files it will read, so drop the
+// option once the old files are gone.
+func ReadPlaintext() StoreOption {
+	return func(sc *storeConfig) { sc.readPlaintext = true }
+}
+
+func newStoreConfig(opts []StoreOption) storeConfig {
+	var sc storeConfig
+	for _, opt := range opts {
+		opt(&sc)
+	}
+	return sc
+}
+
+func (sc storeConfig) seal(data []byte, name string) ([]byte, error) {
+	if sc.compress {
+		var err error
+		if data, err = compressBlob(data); err != nil {
+			return nil, err
+		}
+	}
+	if sc.cipher == nil {
+		return data, nil
+	}
+	return sc.cipher.Seal(data, name)
+}
+
+func (sc storeConfig) open(data []byte, name string) ([]byte, error) {
+	if sc.cipher != nil {
+		var err error
+		plain, err := sc.cipher.Open(data, name)
+		switch {
+		case errors.Is(err, ErrNotEncrypted) && sc.readPlaintext:
+		case err != nil:
+			return nil, err
+		default:
+			data = plain
+		}
+	}
+	return decompressBlob(data)
+}
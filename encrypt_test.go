@@ -0,0 +1,152 @@
+package matchspec
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func TestCipher(t *testing.T) {
+	c, err := NewCipher(testKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := c.Seal([]byte("secret response"), "k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, []byte("secret")) {
+		t.Error("sealed data holds plaintext")
+	}
+	if got, err := c.Open(sealed, "k1"); err != nil || string(got) != "secret response" {
+		t.Errorf("Open = %q, %v", got, err)
+	}
+	if _, err := c.Open(sealed, "k2"); err == nil {
+		t.Error("opened under another name")
+	}
+	sealed[len(sealed)-1] ^= 1
+	if _, err := c.Open(sealed, "k1"); err == nil {
+		t.Error("opened tampered data")
+	}
+	if got, err := c.Open([]byte("plain"), "k1"); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("Open of plain data = %q, %v", got, err)
+	}
+	if _, err := NewCipher([]byte("short")); err == nil {
+		t.Error("short key accepted")
+	}
+}
+
+func TestCipherRotation(t *testing.T) {
+	old, _ := NewCipher(testKey(1))
+	sealed, _ := old.Seal([]byte("v1"), "k")
+
+	rotated, err := NewCipher(testKey(2), testKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := rotated.Open(sealed, "k"); err != nil || string(got) != "v1" {
+		t.Errorf("rotated Open = %q, %v", got, err)
+	}
+	resealed, _ := rotated.Seal([]byte("v2"), "k")
+	if _, err := old.Open(resealed, "k"); err == nil || !strings.Contains(err.Error(), "unknown key") {
+		t.Errorf("old cipher opened data sealed with the new key: %v", err)
+	}
+}
+
+func TestCipherFromEnvAndKeyFunc(t *testing.T) {
+	t.Setenv("MATCHSPEC_TEST_KEY", base64.StdEncoding.EncodeToString(testKey(3))+", "+base64.StdEncoding.EncodeToString(testKey(4)))
+	c, err := CipherFromEnv("MATCHSPEC_TEST_KEY")
+	if err != nil || len(c.keys) != 2 {
+		t.Fatalf("CipherFromEnv = %v, %v", c, err)
+	}
+	if _, err := CipherFromEnv("MATCHSPEC_TEST_UNSET"); err == nil {
+		t.Error("unset env accepted")
+	}
+	t.Setenv("MATCHSPEC_TEST_KEY", "not base64!")
+	if _, err := CipherFromEnv("MATCHSPEC_TEST_KEY"); err == nil {
+		t.Error("invalid base64 accepted")
+	}
+
+	kms := func(ctx context.Context) ([][]byte, error) { return [][]byte{testKey(5)}, nil }
+	if _, err := CipherFromKeyFunc(context.Background(), kms); err != nil {
+		t.Error(err)
+	}
+	failing := func(ctx context.Context) ([][]byte, error) { return nil, errors.New("kms down") }
+	if _, err := CipherFromKeyFunc(context.Background(), failing); err == nil || !strings.Contains(err.Error(), "kms down") {
+		t.Errorf("failing key func: %v", err)
+	}
+}
+
+func TestEncryptedStores(t *testing.T) {
+	c, _ := NewCipher(testKey(6))
+	noPlaintext := func(dir string) {
+		t.Helper()
+		files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+		if len(files) == 0 {
+			t.Fatalf("no files in %s", dir)
+		}
+		for _, f := range files {
+			data, _ := os.ReadFile(f)
+			if bytes.Contains(data, []byte("alice")) {
+				t.Errorf("%s holds plaintext: %q", f, data)
+			}
+		}
+	}
+
+	dir := t.TempDir()
+	responses := NewDirResponseCache(dir, EncryptWith(c))
+	responses.Put("key", "hello alice")
+	noPlaintext(dir)
+	if got, ok, err := responses.Get("key"); err != nil || !ok || got != "hello alice" {
+		t.Errorf("response cache Get = %q, %v, %v", got, ok, err)
+	}
+	if _, _, err := NewDirResponseCache(dir).Get("key"); err == nil {
+		t.Error("encrypted response read without the key")
+	}
+
+	dir = t.TempDir()
+	snapshots := NewDirSnapshotStore(dir, EncryptWith(c))
+	snapshots.Put("suite", "task", "alice's order")
+	noPlaintext(dir)
+	if got, ok, err := snapshots.Get("suite", "task"); err != nil || !ok || got != "alice's order" {
+		t.Errorf("snapshot Get = %q, %v, %v", got, ok, err)
+	}
+
+	dir = t.TempDir()
+	runs := NewDirRunCache(dir, EncryptWith(c))
+	runs.Put("run", Checkpoint{Version: CheckpointVersion, Record: RunRecord{Suite: "alice"}})
+	noPlaintext(dir)
+	if cp, ok, err := runs.Get("run"); err != nil || !ok || cp.Record.Suite != "alice" {
+		t.Errorf("run cache Get = %+v, %v, %v", cp, ok, err)
+	}
+	// Swapping files between keys fails authentication.
+	os.Rename(filepath.Join(dir, "run.json"), filepath.Join(dir, "other.json"))
+	if _, _, err := runs.Get("other"); err == nil {
+		t.Error("opened a file under another key")
+	}
+
+	// Plain files written before encryption was enabled are refused
+	// unless the store opts in to reading them.
+	dir = t.TempDir()
+	NewDirSnapshotStore(dir).Put("suite", "task", "old")
+	if _, _, err := NewDirSnapshotStore(dir, EncryptWith(c)).Get("suite", "task"); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("plain snapshot read without ReadPlaintext: %v", err)
+	}
+	migrating := NewDirSnapshotStore(dir, EncryptWith(c), ReadPlaintext())
+	if got, _, err := migrating.Get("suite", "task"); err != nil || got != "old" {
+		t.Errorf("plain snapshot = %q, %v", got, err)
+	}
+	migrating.Put("suite", "task", "new")
+	if data, _ := os.ReadFile(filepath.Join(dir, "suite.json")); !bytes.HasPrefix(data, []byte(encryptedMagic)) {
+		t.Errorf("rewritten snapshot not encrypted: %q", data)
+	}
+}
@@ -0,0 +1,150 @@
+package matchspec
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Entity is a typed span of text, such as a person's name or an email
+// address.
+type Entity struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// EntityExtractor finds entities in text. Implementations range from
+// regular expressions to a named-entity recognition model.
+type EntityExtractor interface {
+	Entities(ctx context.Context, text string) ([]Entity, error)
+}
+
+// EntityExtractorFunc adapts a function to the EntityExtractor interface.
+type EntityExtractorFunc func(ctx context.Context, text string) ([]Entity, error)
+
+// Entities calls f.
+func (f EntityExtractorFunc) Entities(ctx context.Context, text string) ([]Entity, error) {
+	return f(ctx, text)
+}
+
+// DefaultEntityPatterns are the patterns of the default extractor, keyed by
+// entity type. "name" is a heuristic: two or more capitalized words in a
+// row. Use a NER model through WithEntityExtractor for better recall.
+var DefaultEntityPatterns = map[string]string{
+	"email":  `[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`,
+	"amount": `(?:[$€£¥]\s?\d[\d,]*(?:\.\d+)?|\b\d[\d,]*(?:\.\d+)?\s?(?:USD|EUR|GBP|JPY|dollars|euros|pounds)\b)`,
+	"phone":  `(?:\+?\d{1,3}[ .\-]?)?\(?\d{3}\)?[ .\-]?\d{3}[ .\-]?\d{4}\b`,
+	"name":   `\b[A-Z][a-z]+(?:\s+[A-Z]\.)?(?:\s+[A-Z][a-z]+)+\b`,
+}
+
+// RegexEntityExtractor extracts entities with one regular expression per
+// entity type.
+type RegexEntityExtractor struct {
+	types    []string
+	patterns map[string]*regexp.Regexp
+}
+
+// NewRegexEntityExtractor compiles patterns, keyed by entity type, into an
+// extractor.
+func NewRegexEntityExtractor(patterns map[string]string) (*RegexEntityExtractor, error) {
+	x := &RegexEntityExtractor{patterns: make(map[string]*regexp.Regexp, len(patterns))}
+	for typ, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("matchspec: entity pattern %q: %w", typ, err)
+		}
+		x.types = append(x.types, typ)
+		x.patterns[typ] = re
+	}
+	sort.Strings(x.types)
+	return x, nil
+}
+
+// Entities returns every match of every pattern, grouped by type in type
+// order.
+func (x *RegexEntityExtractor) Entities(_ context.Context, text string) ([]Entity, error) {
+	var out []Entity
+	for _, typ := range x.types {
+		for _, v := range x.patterns[typ].FindAllString(text, -1) {
+			out = append(out, Entity{Type: typ, Value: v})
+		}
+	}
+	return out, nil
+}
+
+var defaultEntities, _ = NewRegexEntityExtractor(DefaultEntityPatterns)
+
+// WithEntityExtractor sets the extractor used by the "entities" matcher.
+// The default is a RegexEntityExtractor of DefaultEntityPatterns.
+func WithEntityExtractor(x EntityExtractor) RunnerOption {
+	return func(r *Runner) {
+		r.entities = x
+	}
+}
+
+// entityKey canonicalizes an entity for comparison: case and spacing are
+// ignored, and amounts compare by number, so "$1,200" equals "$1200.00".
+func entityKey(e Entity) string {
+	v := strings.ToLower(strings.Join(strings.Fields(e.Value), " "))
+	if e.Type == "amount" {
+		if nums := ParseNumbers(strings.ReplaceAll(v, ",", "")); len(nums) == 1 {
+			v = strconv.FormatFloat(nums[0], 'g', -1, 64)
+		}
+	}
+	return e.Type + "\x00" + v
+}
+
+// EntityScores compares extracted entities with expected ones, as sets.
+// Extracted entities of types that none of want has are ignored, so a
+// task checking emails is not penalized for names. Empty sets score 1.
+func EntityScores(want, got []Entity) (precision, recall, f1 float64) {
+	types := make(map[string]bool)
+	wantKeys := make(map[string]bool)
+	for _, e := range want {
+		types[e.Type] = true
+		wantKeys[entityKey(e)] = true
+	}
+	gotKeys := make(map[string]bool)
+	for _, e := range got {
+		if types[e.Type] {
+			gotKeys[entityKey(e)] = true
+		}
+	}
+
+	tp := 0
+	for k := range gotKeys {
+		if wantKeys[k] {
+			tp++
+		}
+	}
+	precision, recall = 1, 1
+	if len(gotKeys) > 0 {
+		precision = float64(tp) / float64(len(gotKeys))
+	}
+	if len(wantKeys) > 0 {
+		recall = float64(tp) / float64(len(wantKeys))
+	}
+	if precision+recall > 0 {
+		f1 = 2 * precision * recall / (precision + recall)
+	}
+	return precision, recall, f1
+}
+
+// matchEntities extracts entities from the response and scores them
+// against the task's Entities by F1, passing when the score reaches
+// Threshold (by default, an exact set match).
+func matchEntities(ctx context.Context, x EntityExtractor, t *Task, response string) (bool, float64, error) {
+	got, err := x.Entities(ctx, response)
+	if err != nil {
+		return false, 0.0, fmt.Errorf("matchspec: entity extractor: %w", err)
+	}
+	_, _, f1 := EntityScores(t.Entities, got)
+	threshold := t.Threshold
+	if threshold == 0 {
+		threshold = 1
+	}
+	return f1 >= threshold, f1, nil
+}
@@ -0,0 +1,83 @@
+package matchspec
+
+import (
+	"context"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestRegexEntityExtractor(t *testing.T) {
+	got, err := defaultEntities.Entities(context.Background(), "Refund $1,250.00 to Ada Lovelace (ada@example.com), or call 555-123-4567.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Entity{
+		{"amount", "$1,250.00"},
+		{"email", "ada@example.com"},
+		{"name", "Ada Lovelace"},
+		{"phone", "555-123-4567"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Entities = %v, want %v", got, want)
+	}
+
+	if _, err := NewRegexEntityExtractor(map[string]string{"bad": "("}); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+}
+
+func TestEntityScores(t *testing.T) {
+	want := []Entity{{"email", "a@x.io"}, {"email", "b@x.io"}, {"amount", "$1,200"}}
+	got := []Entity{{"email", "A@X.io"}, {"email", "c@x.io"}, {"amount", "1200.00 USD"}, {"name", "Ada Lovelace"}}
+	p, r, f1 := EntityScores(want, got)
+	// Names are ignored; 2 of 3 extracted are right, 2 of 3 expected found.
+	if math.Abs(p-2.0/3) > 1e-9 || math.Abs(r-2.0/3) > 1e-9 || math.Abs(f1-2.0/3) > 1e-9 {
+		t.Errorf("EntityScores = %f, %f, %f; want 2/3 each", p, r, f1)
+	}
+	if p, r, f1 := EntityScores(nil, nil); p != 1 || r != 1 || f1 != 1 {
+		t.Errorf("empty EntityScores = %f, %f, %f; want 1", p, r, f1)
+	}
+}
+
+func TestTaskMatchEntities(t *testing.T) {
+	task := Task{Name: "t", Matcher: "entities", Entities: []Entity{{"email", "ada@example.com"}, {"name", "Ada Lovelace"}}}
+	if passed, score := task.Match("Ada Lovelace <ada@example.com>"); !passed || score != 1 {
+		t.Errorf("full match: %v, %f", passed, score)
+	}
+	if passed, score := task.Match("Write to ada@example.com."); passed || math.Abs(score-2.0/3) > 1e-9 {
+		t.Errorf("partial match: %v, %f; want false, 0.667", passed, score)
+	}
+	task.Threshold = 0.6
+	if passed, _ := task.Match("Write to ada@example.com."); !passed {
+		t.Error("partial match below threshold failed")
+	}
+}
+
+func TestRunnerEntityExtractor(t *testing.T) {
+	ner := EntityExtractorFunc(func(ctx context.Context, text string) ([]Entity, error) {
+		var out []Entity
+		for _, w := range strings.Fields(text) {
+			if w == "Paris" || w == "Lyon" {
+				out = append(out, Entity{Type: "city", Value: w})
+			}
+		}
+		return out, nil
+	})
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "ner", Tasks: []Task{
+		{Name: "cities", Prompt: "Paris and Lyon", Matcher: "entities", Entities: []Entity{{"city", "paris"}, {"city", "lyon"}}},
+	}})
+	runner := NewRunner(reg, echoInfer, tokentrace.NewReporter("matchspec", ""), WithEntityExtractor(ner))
+	results, err := runner.Run(context.Background(), protocol.EvalRun{Suite: "ner"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !results[0].Passed {
+		t.Errorf("result = %+v, want pass", results[0])
+	}
+}
@@ -0,0 +1,131 @@
+package matchspec
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/greynewell/mist-go/protocol"
+)
+
+const modulePath = "github.com/greynewell/matchspec"
+
+// RunEnvironment records what a run executed with, so its results can be
+// interpreted long after the fact.
+type RunEnvironment struct {
+	// MatchspecVersion is the module version of matchspec in the running
+	// binary, or "(devel)" for an unreleased build.
+	MatchspecVersion string `json:"matchspec_version"`
+	GoVersion        string `json:"go_version"`
+	OS               string `json:"os"`
+	Arch             string `json:"arch"`
+	Host             string `json:"host,omitempty"`
+
+	// SuiteHash is the suite's SuiteHash. ConfigHash also covers the
+	// run's task filter and tags, the model and parameters, and the
+	// runner's template variables (see RunCacheKey); it is empty for
+	// suites with an unseeded generator.
+	SuiteHash  string `json:"suite_hash,omitempty"`
+	ConfigHash string `json:"config_hash,omitempty"`
+
+	// Backends are the providers and models that served the run, as
+	// reported by the backend (see RecordBackend).
+	Backends []BackendModel `json:"backends,omitempty"`
+}
+
+// BackendModel is a provider and model that answered a run's inference
+// calls.
+type BackendModel struct {
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model"`
+	Calls    int64  `json:"calls"`
+}
+
+// RecordBackend notes that the inference call made with ctx was served by
+// the given provider and model, as reported in the backend's response.
+// InferMuxFunc calls it automatically; custom inference functions may call
+// it too. Judge calls are not recorded.
+func RecordBackend(ctx context.Context, provider, model string) {
+	if (model == "" && provider == "") || ctx.Value(judgeRoleKey{}) != nil {
+		return
+	}
+	if u, ok := ctx.Value(runUsageKey{}).(*runUsage); ok {
+		u.backends.add(BackendModel{Provider: provider, Model: model, Calls: 1})
+	}
+}
+
+// backendSet counts inference calls by provider and model.
+type backendSet struct {
+	mu    sync.Mutex
+	calls map[[2]string]int64
+}
+
+func (s *backendSet) add(b BackendModel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.calls == nil {
+		s.calls = make(map[[2]string]int64)
+	}
+	s.calls[[2]string{b.Provider, b.Model}] += b.Calls
+}
+
+// list returns the backends sorted by provider and model.
+func (s *backendSet) list() []BackendModel {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []BackendModel
+	for k, n := range s.calls {
+		out = append(out, BackendModel{Provider: k[0], Model: k[1], Calls: n})
+	}
+	slices.SortFunc(out, func(a, b BackendModel) int {
+		if c := strings.Compare(a.Provider, b.Provider); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Model, b.Model)
+	})
+	return out
+}
+
+// environment captures the environment of a run of suite s, or of a
+// streamed or scored run if s is nil.
+func (r *Runner) environment(s *Suite, run protocol.EvalRun, opts InferOptions) *RunEnvironment {
+	env := &RunEnvironment{
+		MatchspecVersion: matchspecVersion(),
+		GoVersion:        runtime.Version(),
+		OS:               runtime.GOOS,
+		Arch:             runtime.GOARCH,
+	}
+	env.Host, _ = os.Hostname()
+	if s != nil {
+		env.SuiteHash = SuiteHash(s)
+		env.ConfigHash = r.varsCacheKey(RunCacheKey(s, run, opts))
+	}
+	return env
+}
+
+// matchspecVersion returns the version of this module in the running
+// binary's build info.
+func matchspecVersion() string {
+	version := ""
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if info.Main.Path == modulePath {
+			version = info.Main.Version
+		}
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath {
+				version = dep.Version
+				if dep.Replace != nil {
+					version = dep.Replace.Version
+				}
+			}
+		}
+	}
+	if version == "" {
+		return "(devel)"
+	}
+	return version
+}
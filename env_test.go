@@ -0,0 +1,65 @@
+package matchspec
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestRunEnvironment(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req protocol.InferRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		model := "gpt-x-2024-06"
+		if req.Messages[0].Content == "What is 3*4?" {
+			model = "gpt-x-2024-08"
+		}
+		json.NewEncoder(w).Encode(protocol.InferResponse{Provider: "openai", Model: model, Content: "4"})
+	}))
+	defer srv.Close()
+
+	reg := driftRegistry()
+	runner := NewRunner(reg, InferMuxFunc(srv.URL, "gpt-x"), tokentrace.NewReporter("matchspec", ""))
+	run := protocol.EvalRun{Suite: "math", Tags: map[string]string{"model": "gpt-x"}}
+	if _, err := runner.Run(context.Background(), run); err != nil {
+		t.Fatal(err)
+	}
+	runs, _ := runner.Runs(RunFilter{})
+	env := runs[0].Environment
+	if env == nil {
+		t.Fatal("no environment on run record")
+	}
+	if env.MatchspecVersion == "" || env.GoVersion != runtime.Version() || env.OS != runtime.GOOS {
+		t.Errorf("environment = %+v", env)
+	}
+	s, _ := reg.Get("math")
+	if env.SuiteHash != SuiteHash(s) || env.ConfigHash == "" || env.ConfigHash == env.SuiteHash {
+		t.Errorf("hashes = %q, %q", env.SuiteHash, env.ConfigHash)
+	}
+	want := []BackendModel{
+		{Provider: "openai", Model: "gpt-x-2024-06", Calls: 1},
+		{Provider: "openai", Model: "gpt-x-2024-08", Calls: 1},
+	}
+	if !slices.Equal(env.Backends, want) {
+		t.Errorf("backends = %+v, want %+v", env.Backends, want)
+	}
+
+	// A different model changes the config hash but not the suite hash.
+	runner.Run(context.Background(), protocol.EvalRun{Suite: "math", Tags: map[string]string{"model": "other"}})
+	runs, _ = runner.Runs(RunFilter{Model: "other"})
+	if other := runs[0].Environment; other.SuiteHash != env.SuiteHash || other.ConfigHash == env.ConfigHash {
+		t.Errorf("other model hashes = %q, %q", other.SuiteHash, other.ConfigHash)
+	}
+}
+
+func TestRecordBackendOutsideRun(t *testing.T) {
+	// No run in progress: nothing to record into, and no panic.
+	RecordBackend(context.Background(), "p", "m")
+}
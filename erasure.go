@@ -0,0 +1,177 @@
+package matchspec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"time"
+)
+
+// ErasedText replaces result text erased by Runner.Erase.
+const ErasedText = "[erased]"
+
+// Eraser is implemented by stores that keep prompts, responses, or other
+// task text, so a data subject's text can be erased from them on request.
+// Erase removes every entry whose text matches pattern and returns how
+// many it removed.
+//
+// The runner erases from its response cache, judge cache, snapshot store,
+// and share recorder when they implement Eraser; register other stores,
+// such as a DirRunCache, with WithErasers.
+type Eraser interface {
+	Erase(pattern *regexp.Regexp) (int, error)
+}
+
+// WithErasers adds stores that Runner.Erase erases from, in addition to
+// the ones the runner already uses.
+func WithErasers(e ...Eraser) RunnerOption {
+	return func(r *Runner) { r.erasers = append(r.erasers, e...) }
+}
+
+// ErasureReport counts what Runner.Erase erased.
+type ErasureReport struct {
+	// Runs and Results count the recorded runs and results whose text was
+	// replaced with ErasedText.
+	Runs    int `json:"runs"`
+	Results int `json:"results"`
+
+	// Entries counts the cache, snapshot, and artifact entries removed.
+	Entries int `json:"entries"`
+}
+
+// ErasurePattern compiles the pattern for an erasure request: text
+// matched literally, or a regular expression. Exactly one must be set.
+func ErasurePattern(text, pattern string) (*regexp.Regexp, error) {
+	switch {
+	case (text == "") == (pattern == ""):
+		return nil, fmt.Errorf("matchspec: erasure needs exactly one of text or pattern")
+	case text != "":
+		return regexp.MustCompile(regexp.QuoteMeta(text)), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("matchspec: erasure pattern: %w", err)
+	}
+	return re, nil
+}
+
+// EraseResult replaces each text field of res that matches pattern, such
+// as an error message or diff that quotes the response, with ErasedText.
+// It reports whether anything was erased.
+func EraseResult(res *Result, pattern *regexp.Regexp) bool {
+	erased := false
+	erase := func(s *string) {
+		if *s != "" && pattern.MatchString(*s) {
+			*s, erased = ErasedText, true
+		}
+	}
+	erase(&res.Error)
+	erase(&res.Diff)
+	for i := range res.Verdicts {
+		erase(&res.Verdicts[i].Error)
+	}
+	if res.Shadow != nil {
+		erase(&res.Shadow.Error)
+	}
+	if res.Repro != nil {
+		erase(&res.Repro.PromptTemplate)
+	}
+	// Metadata is shared with the task, so it is copied before erasing.
+	cloned := false
+	for k, v := range res.Metadata {
+		if s, ok := v.(string); ok && pattern.MatchString(s) {
+			if !cloned {
+				res.Metadata, cloned = maps.Clone(res.Metadata), true
+			}
+			res.Metadata[k], erased = ErasedText, true
+		}
+	}
+	if res.Details != nil {
+		res.Details = mapStrings(res.Details, func(s string) string {
+			if pattern.MatchString(s) {
+				s, erased = ErasedText, true
+			}
+			return s
+		}).(map[string]any)
+	}
+	return erased
+}
+
+// Erase removes every stored text matching pattern, such as a user's
+// e-mail address or account ID, to satisfy a data-subject erasure
+// request. Matching text in recorded results (including soft-deleted runs)
+// is replaced with ErasedText, and those runs are re-hashed and re-signed
+// and marked with ErasedAt, and their copies in the result store are
+// replaced. Matching entries are removed from the runner's stores that
+// implement Eraser. Runs in progress are not
+// touched; erase again once they finish.
+//
+// Erase keeps going when a store fails and returns the errors joined, so
+// the report counts everything that was erased.
+func (r *Runner) Erase(pattern *regexp.Regexp) (ErasureReport, error) {
+	var report ErasureReport
+	now := time.Now()
+	r.storeMu.Lock()
+	defer r.storeMu.Unlock()
+	r.mu.Lock()
+	var stored []Checkpoint
+	for i := range r.runs {
+		rec := &r.runs[i]
+		results := r.results[rec.first : rec.first+rec.count]
+		n := 0
+		for j := range results {
+			if EraseResult(&results[j], pattern) {
+				n++
+			}
+		}
+		recErased := rec.Error != "" && pattern.MatchString(rec.Error)
+		if recErased {
+			rec.Error = ErasedText
+		}
+		if n == 0 && !recErased {
+			continue
+		}
+		if n > 0 {
+			// Erasing only replaces text, so results that hashed when
+			// the run was recorded still encode.
+			rec.Hash, rec.Signature, _ = digestResults(r.signingKey, results)
+		}
+		rec.ErasedAt = now
+		report.Runs++
+		report.Results += n
+		if r.store != nil {
+			stored = append(stored, r.storedRun(*rec))
+		}
+	}
+	r.mu.Unlock()
+
+	// Stored copies are replaced with the erased runs.
+	var errs []error
+	if err := r.storeRuns(context.Background(), stored); err != nil {
+		errs = append(errs, err)
+	}
+	for _, e := range r.eraseTargets() {
+		n, err := e.Erase(pattern)
+		report.Entries += n
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return report, errors.Join(errs...)
+}
+
+// eraseTargets returns the runner's stores that implement Eraser.
+func (r *Runner) eraseTargets() []Eraser {
+	var targets []Eraser
+	for _, store := range []any{r.responseCache, r.judgeCache, r.snapshots} {
+		if e, ok := store.(Eraser); ok {
+			targets = append(targets, e)
+		}
+	}
+	if r.share != nil {
+		targets = append(targets, r.share)
+	}
+	return append(targets, r.erasers...)
+}
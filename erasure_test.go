@@ -0,0 +1,146 @@
+package matchspec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestErase(t *testing.T) {
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "support", Tasks: []Task{
+		{Name: "greet", Prompt: "Greet alice@example.com", Expected: "Hello", Matcher: "contains", Metadata: map[string]any{"user": "alice@example.com"}},
+		{Name: "refund", Prompt: "Refund bob@example.com", Expected: "done", Matcher: "contains"},
+	}})
+	infer := func(ctx context.Context, prompt string) (string, error) {
+		if strings.Contains(prompt, "bob") {
+			return "", errors.New("backend rejected bob@example.com")
+		}
+		return "Hello alice@example.com", nil
+	}
+	cache := NewMemoryResponseCache()
+	share := NewShareRecorder()
+	runCache := NewDirRunCache(t.TempDir())
+	key := []byte("k")
+	runner := NewRunner(reg, infer, tokentrace.NewReporter("matchspec", ""),
+		WithResponseCache(cache, ""), WithShareRecorder(share), WithErasers(runCache), WithSigningKey(key))
+	runner.Run(context.Background(), protocol.EvalRun{Suite: "support"})
+	runCache.Put("run", Checkpoint{Version: CheckpointVersion, Results: allResults(runner)})
+	runCache.Put("other", Checkpoint{Version: CheckpointVersion})
+
+	if _, err := ErasurePattern("", ""); err == nil {
+		t.Error("empty erasure accepted")
+	}
+	if _, err := ErasurePattern("a", "b"); err == nil {
+		t.Error("text and pattern both accepted")
+	}
+	if _, err := ErasurePattern("", "("); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+
+	pattern, _ := ErasurePattern("bob@example.com", "")
+	report, err := runner.Erase(pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// One result, plus the run cache entry holding it and the recorded
+	// exchange for its prompt. Failed calls leave nothing in the response
+	// cache.
+	if report.Runs != 1 || report.Results != 1 || report.Entries != 2 {
+		t.Errorf("report = %+v", report)
+	}
+	rec, _ := runner.GetRun(runner.runs[0].ID)
+	results := allResults(runner)
+	for _, res := range results {
+		if strings.Contains(res.Error, "bob") {
+			t.Errorf("result still holds erased text: %+v", res)
+		}
+	}
+	if results[1].Error != ErasedText {
+		t.Errorf("error = %q", results[1].Error)
+	}
+	if rec.ErasedAt.IsZero() || !VerifyResults(results, rec.Hash, rec.Signature, key) {
+		t.Errorf("erased run not re-hashed: %+v", rec)
+	}
+	if _, ok, _ := runCache.Get("run"); ok {
+		t.Error("run cache entry holding erased text kept")
+	}
+	if _, ok, _ := runCache.Get("other"); !ok {
+		t.Error("unrelated run cache entry removed")
+	}
+
+	// Metadata is erased on results without touching the suite.
+	report, err = runner.Erase(regexp.MustCompile(`alice@\S+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := allResults(runner)[0].Metadata["user"]; got != ErasedText {
+		t.Errorf("metadata = %v", got)
+	}
+	if s, _ := reg.Get("support"); s.Tasks[0].Metadata["user"] != "alice@example.com" {
+		t.Error("erasing a result changed the task's metadata")
+	}
+	if cache.Len() != 0 || report.Entries != 2 {
+		t.Errorf("cache = %d entries, report = %+v", cache.Len(), report)
+	}
+}
+
+func TestDirStoresErase(t *testing.T) {
+	pattern := regexp.MustCompile(`carol`)
+
+	responses := NewDirResponseCache(t.TempDir())
+	responses.Put("a", "hi carol")
+	responses.Put("b", "hi dave")
+	if n, err := responses.Erase(pattern); err != nil || n != 1 {
+		t.Errorf("response cache erase = %d, %v", n, err)
+	}
+	if _, ok, _ := responses.Get("a"); ok {
+		t.Error("response not erased")
+	}
+	if _, ok, _ := responses.Get("b"); !ok {
+		t.Error("unrelated response erased")
+	}
+
+	snapshots := NewDirSnapshotStore(t.TempDir())
+	snapshots.Put("s/1", "t1", "carol's order")
+	snapshots.Put("s/1", "t2", "dave's order")
+	if n, err := snapshots.Erase(pattern); err != nil || n != 1 {
+		t.Errorf("snapshot erase = %d, %v", n, err)
+	}
+	if _, ok, _ := snapshots.Get("s/1", "t1"); ok {
+		t.Error("snapshot not erased")
+	}
+	if _, ok, _ := snapshots.Get("s/1", "t2"); !ok {
+		t.Error("unrelated snapshot erased")
+	}
+
+	if n, err := NewDirRunCache(t.TempDir() + "/missing").Erase(pattern); err != nil || n != 0 {
+		t.Errorf("erase of missing dir = %d, %v", n, err)
+	}
+}
+
+func TestEraseHandler(t *testing.T) {
+	runner, _ := retentionRunner(t, 1)
+	h := NewHandler(runner, driftRegistry())
+
+	w := httptest.NewRecorder()
+	h.Erase(w, httptest.NewRequest(http.MethodPost, "/erasure", strings.NewReader(`{"text":"nobody"}`)))
+	var report ErasureReport
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil || w.Code != http.StatusOK || report != (ErasureReport{}) {
+		t.Errorf("erase = %d %+v, %v", w.Code, report, err)
+	}
+
+	w = httptest.NewRecorder()
+	h.Erase(w, httptest.NewRequest(http.MethodPost, "/erasure", strings.NewReader(`{}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("empty erasure = %d", w.Code)
+	}
+}
@@ -0,0 +1,159 @@
+package matchspec
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Run lifecycle event types published to an EventHub.
+const (
+	EventRunStarted         = "run.started"
+	EventTaskFinished       = "task.finished"
+	EventRunCompleted       = "run.completed"
+	EventRegressionDetected = "regression.detected"
+)
+
+// Event is a run lifecycle event.
+type Event struct {
+	Type  string    `json:"type"`
+	Time  time.Time `json:"time"`
+	RunID string    `json:"run_id"`
+	Suite string    `json:"suite"`
+
+	// Record is the run as it started for run.started, and as recorded for
+	// run.completed and regression.detected.
+	Record *RunRecord `json:"record,omitempty"`
+
+	// Result is the finished task for task.finished, with the run's
+	// progress so far. Streamed runs report no progress.
+	Result   *Result   `json:"result,omitempty"`
+	Progress *Progress `json:"progress,omitempty"`
+
+	// Alerts are the drops below baseline for regression.detected.
+	Alerts []DriftAlert `json:"alerts,omitempty"`
+}
+
+// EventHub fans out run lifecycle events from a runner (see WithEvents)
+// to subscribers, such as live dashboards connected to GET /ws. Publishing
+// never blocks a run: a subscriber that falls more than its buffer behind
+// is dropped and its channel closed.
+type EventHub struct {
+	buffer int
+
+	mu      sync.Mutex
+	subs    map[*eventSub]struct{}
+	origins []string // allowed cross-origin WebSocket clients
+}
+
+type eventSub struct {
+	ch    chan Event
+	match func(Event) bool
+}
+
+// NewEventHub creates a hub whose subscribers may each fall buffer events
+// behind; buffer <= 0 uses 256.
+func NewEventHub(buffer int) *EventHub {
+	if buffer <= 0 {
+		buffer = 256
+	}
+	return &EventHub{buffer: buffer, subs: make(map[*eventSub]struct{})}
+}
+
+// AllowOrigins lets browser pages served from the given origins, such as
+// "https://dashboard.example.com", connect to the hub's WebSocket; "*"
+// allows any. Without it only pages on the hub's own host may connect.
+// Clients that send no Origin header, which browsers always do, are not
+// affected.
+func (h *EventHub) AllowOrigins(origins ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.origins = append(h.origins, origins...)
+}
+
+// WithEvents publishes the runner's run lifecycle events to h: when a run
+// starts, as each task finishes, when the run is recorded, and when it
+// regresses (see WithRegressionCheck).
+func WithEvents(h *EventHub) RunnerOption {
+	return func(r *Runner) {
+		r.events = h
+		r.notifiers = append(r.notifiers, h)
+	}
+}
+
+// Subscribe returns a channel of the events for which match reports true,
+// or all events if match is nil. The channel is closed when ctx is done or
+// the subscriber falls behind.
+func (h *EventHub) Subscribe(ctx context.Context, match func(Event) bool) <-chan Event {
+	sub := &eventSub{ch: make(chan Event, h.buffer), match: match}
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		h.drop(sub)
+	}()
+	return sub.ch
+}
+
+func (h *EventHub) drop(sub *eventSub) {
+	h.mu.Lock()
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.ch)
+	}
+	h.mu.Unlock()
+}
+
+// Publish sends e to every subscriber that matches it.
+func (h *EventHub) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs {
+		if sub.match != nil && !sub.match(e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			delete(h.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// Subscribers returns the number of current subscribers.
+func (h *EventHub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
+
+// RunCompleted publishes run.completed.
+func (h *EventHub) RunCompleted(_ context.Context, rec RunRecord, _ []Result) error {
+	h.Publish(Event{Type: EventRunCompleted, RunID: rec.ID, Suite: rec.Suite, Record: &rec})
+	return nil
+}
+
+// RegressionDetected publishes regression.detected.
+func (h *EventHub) RegressionDetected(_ context.Context, rec RunRecord, _ []Result, alerts []DriftAlert) error {
+	h.Publish(Event{Type: EventRegressionDetected, RunID: rec.ID, Suite: rec.Suite, Record: &rec, Alerts: alerts})
+	return nil
+}
+
+// publishStarted publishes run.started for rec, if the runner has a hub.
+func (r *Runner) publishStarted(rec RunRecord) {
+	if r.events != nil {
+		r.events.Publish(Event{Type: EventRunStarted, RunID: rec.ID, Suite: rec.Suite, Record: &rec})
+	}
+}
+
+// publishTask publishes task.finished for res; p may be nil.
+func (r *Runner) publishTask(runID string, res Result, p *Progress) {
+	if r.events != nil {
+		r.events.Publish(Event{Type: EventTaskFinished, RunID: runID, Suite: res.Suite, Result: &res, Progress: p})
+	}
+}
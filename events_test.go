@@ -0,0 +1,221 @@
+package matchspec
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestEventHubRunLifecycle(t *testing.T) {
+	var broken atomic.Bool
+	infer := func(ctx context.Context, prompt string) (string, error) {
+		if broken.Load() {
+			return "wrong", nil
+		}
+		return map[string]string{"What is 2+2?": "4", "What is 3*4?": "12"}[prompt], nil
+	}
+	hub := NewEventHub(0)
+	runner := NewRunner(driftRegistry(), infer, tokentrace.NewReporter("matchspec", ""),
+		WithEvents(hub), WithRegressionCheck(DriftConfig{MinRuns: 2, MaxPassRateDelta: 0.2}))
+	run := protocol.EvalRun{Suite: "math", Tags: map[string]string{"model": "m"}}
+	for range 2 {
+		runner.Run(context.Background(), run)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := hub.Subscribe(ctx, nil)
+	broken.Store(true)
+	runner.Run(context.Background(), run)
+
+	var types []string
+	for range 5 {
+		e := <-events
+		types = append(types, e.Type)
+		switch e.Type {
+		case EventTaskFinished:
+			if e.Result == nil || e.Progress == nil || e.Suite != "math" || e.RunID == "" {
+				t.Errorf("task event = %+v", e)
+			}
+		case EventRegressionDetected:
+			if len(e.Alerts) == 0 || e.Record == nil {
+				t.Errorf("regression event = %+v", e)
+			}
+		}
+	}
+	want := []string{EventRunStarted, EventTaskFinished, EventTaskFinished, EventRunCompleted, EventRegressionDetected}
+	if strings.Join(types, ",") != strings.Join(want, ",") {
+		t.Errorf("events = %v, want %v", types, want)
+	}
+
+	cancel()
+	if _, ok := <-events; ok {
+		t.Error("channel open after cancel")
+	}
+	if n := hub.Subscribers(); n != 0 {
+		t.Errorf("subscribers = %d", n)
+	}
+}
+
+func TestEventHubDropsSlowSubscriber(t *testing.T) {
+	hub := NewEventHub(1)
+	events := hub.Subscribe(context.Background(), func(e Event) bool { return e.Suite == "math" })
+	hub.Publish(Event{Type: EventRunStarted, Suite: "other"})
+	hub.Publish(Event{Type: EventRunStarted, Suite: "math"})
+	hub.Publish(Event{Type: EventRunCompleted, Suite: "math"})
+	if e := <-events; e.Type != EventRunStarted {
+		t.Errorf("first event = %+v", e)
+	}
+	if _, ok := <-events; ok {
+		t.Error("slow subscriber not dropped")
+	}
+}
+
+// dialWS opens a WebSocket to url and returns the connection and a reader
+// positioned after the handshake.
+func dialWS(t *testing.T, url string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	path := "/ws?type=" + EventTaskFinished
+	fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: x\r\nConnection: Upgrade\r\nUpgrade: websocket\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n", path)
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake = %d %v", resp.StatusCode, resp.Header)
+	}
+	return conn, br
+}
+
+// writeClientFrame sends a masked frame, as browsers do.
+func writeClientFrame(conn net.Conn, opcode byte, payload []byte) {
+	var mask [4]byte
+	rand.Read(mask[:])
+	frame := append([]byte{0x80 | opcode, 0x80 | byte(len(payload))}, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	conn.Write(frame)
+}
+
+func TestEventHubWebSocket(t *testing.T) {
+	hub := NewEventHub(0)
+	srv := httptest.NewServer(hub)
+	defer srv.Close()
+	conn, br := dialWS(t, srv.URL)
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	for hub.Subscribers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	hub.Publish(Event{Type: EventRunStarted, RunID: "r1", Suite: "math"})
+	hub.Publish(Event{Type: EventTaskFinished, RunID: "r1", Suite: "math", Result: &Result{EvalResult: protocol.EvalResult{Task: "add", Passed: true}}})
+
+	op, payload, err := wsReadFrame(br)
+	if err != nil || op != wsText {
+		t.Fatalf("frame = %d, %v", op, err)
+	}
+	var e Event
+	if err := json.Unmarshal(payload, &e); err != nil || e.Type != EventTaskFinished || e.Result.Task != "add" {
+		t.Errorf("event = %+v, %v", e, err)
+	}
+
+	writeClientFrame(conn, wsPing, []byte("hi"))
+	if op, payload, err := wsReadFrame(br); err != nil || op != wsPong || string(payload) != "hi" {
+		t.Errorf("pong = %d %q, %v", op, payload, err)
+	}
+	writeClientFrame(conn, wsClose, binary.BigEndian.AppendUint16(nil, wsCloseNormal))
+	if op, payload, err := wsReadFrame(br); err != nil || op != wsClose || binary.BigEndian.Uint16(payload) != wsCloseNormal {
+		t.Errorf("close = %d %v, %v", op, payload, err)
+	}
+	for hub.Subscribers() != 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	resp, err := http.Get(srv.URL + "/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("plain GET = %d", resp.StatusCode)
+	}
+}
+
+func TestEventHubWebSocketBadControlFrames(t *testing.T) {
+	hub := NewEventHub(0)
+	srv := httptest.NewServer(hub)
+	defer srv.Close()
+
+	long := append([]byte{0x80 | wsPing, 0x80 | 126}, binary.BigEndian.AppendUint16(nil, 200)...)
+	long = append(long, make([]byte, 4+200)...)
+	for name, frame := range map[string][]byte{
+		"oversized ping":  long,
+		"fragmented ping": {wsPing, 0x80 | 2, 0, 0, 0, 0, 'h', 'i'},
+	} {
+		conn, br := dialWS(t, srv.URL)
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		conn.Write(frame)
+		op, payload, err := wsReadFrame(br)
+		if err != nil || op != wsClose || len(payload) < 2 || binary.BigEndian.Uint16(payload) != wsCloseProtocol {
+			t.Errorf("%s: reply = %d %v, %v; want close %d", name, op, payload, err, wsCloseProtocol)
+		}
+		conn.Close()
+	}
+}
+
+func TestEventHubWebSocketOrigin(t *testing.T) {
+	hub := NewEventHub(0)
+	hub.AllowOrigins("https://dash.example.com")
+	for _, tc := range []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},
+		{"http://matchspec:8080", true},
+		{"http://MATCHSPEC:8080", true},
+		{"https://dash.example.com", true},
+		{"https://evil.example.com", false},
+		{"http://matchspec:9090", false},
+		{"null", false},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "http://matchspec:8080/ws", nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		if tc.origin != "" {
+			req.Header.Set("Origin", tc.origin)
+		}
+		w := httptest.NewRecorder()
+		hub.ServeHTTP(w, req)
+		// The recorder cannot be hijacked, so an allowed upgrade fails
+		// after the origin check.
+		if refused := w.Code == http.StatusForbidden; refused == tc.want {
+			t.Errorf("Origin %q: status %d", tc.origin, w.Code)
+		}
+	}
+
+	hub.AllowOrigins("*")
+	if !wsOriginAllowed(&http.Request{Host: "a", Header: http.Header{"Origin": {"https://b"}}}, hub.origins) {
+		t.Error("* did not allow every origin")
+	}
+}
@@ -0,0 +1,70 @@
+package matchspec
+
+import (
+	"fmt"
+	"slices"
+)
+
+// answerMatchers are the matchers that compare the response with a literal
+// expected answer, so a task using one must have Expected or ExpectedAny.
+var answerMatchers = []string{"exact", "contains", "not_contains", "prefix", "suffix", "diff", "levenshtein", "token_f1", "rouge_l", "numeric"}
+
+// candidates returns the task once for each acceptable answer, with that
+// answer as Expected: Expected, if set, then each of ExpectedAny.
+func (t *Task) candidates() []Task {
+	answers := t.ExpectedAny
+	if t.Expected != "" {
+		answers = append([]string{t.Expected}, answers...)
+	}
+	out := make([]Task, len(answers))
+	for i, a := range answers {
+		out[i] = *t
+		out[i].Expected, out[i].ExpectedAny = a, nil
+	}
+	return out
+}
+
+// matchAnyExpected grades the response against each of the task's
+// acceptable answers using match. The task passes if any answer does, and
+// scores the best answer's score.
+func matchAnyExpected(t *Task, response string, match func(t *Task, response string) (bool, float64, error)) (bool, float64, error) {
+	var passed bool
+	var best float64
+	for _, ct := range t.candidates() {
+		ok, score, err := match(&ct, response)
+		if err != nil {
+			return false, 0.0, fmt.Errorf("expected %q: %w", ct.Expected, err)
+		}
+		passed = passed || ok
+		best = max(best, score)
+	}
+	return passed, best, nil
+}
+
+// validateExpected checks that a task whose matcher compares against an
+// answer has one, and validates each acceptable answer for its matcher. A
+// task without a matcher uses DefaultMatcher, which needs an answer.
+func validateExpected(suite string, t Task) error {
+	matcher := t.Matcher
+	if matcher == "" {
+		matcher = DefaultMatcher
+	}
+	if len(t.ExpectedAny) == 0 {
+		if t.Expected == "" && len(t.Assertions) == 0 && slices.Contains(answerMatchers, matcher) {
+			return fmt.Errorf("matchspec: suite %q task %q has no expected or expected_any", suite, t.Name)
+		}
+		return validateMatcher(suite, t)
+	}
+	if len(t.Assertions) > 0 {
+		return fmt.Errorf("matchspec: suite %q task %q sets both expected_any and assertions", suite, t.Name)
+	}
+	for _, ct := range t.candidates() {
+		if ct.Expected == "" {
+			return fmt.Errorf("matchspec: suite %q task %q has an empty expected_any answer", suite, t.Name)
+		}
+		if err := validateMatcher(suite, ct); err != nil {
+			return err
+		}
+	}
+	return nil
+}
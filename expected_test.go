@@ -0,0 +1,71 @@
+package matchspec
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestTaskMatchExpectedAny(t *testing.T) {
+	task := Task{Expected: "4", ExpectedAny: []string{"four", "IV"}, Matcher: "exact", Normalization: Normalization{TrimWhitespace: true}}
+	for _, resp := range []string{"4", "four\n", "IV"} {
+		if passed, score := task.Match(resp); !passed || score != 1 {
+			t.Errorf("Match(%q) = %v, %v", resp, passed, score)
+		}
+	}
+	if passed, _ := task.Match("five"); passed {
+		t.Error("unlisted answer passed")
+	}
+
+	// The score is the best candidate's.
+	task = Task{ExpectedAny: []string{"kitten", "sitting"}, Matcher: "levenshtein", Threshold: 0.9}
+	if passed, score := task.Match("sittin"); passed || score < 0.85 || score > 0.86 {
+		t.Errorf("levenshtein = %v, %v", passed, score)
+	}
+
+	// Negated, no candidate may match.
+	task = Task{ExpectedAny: []string{"As an AI", "I cannot"}, Matcher: "contains", Negate: true}
+	if passed, _ := task.Match("I cannot help with that."); passed {
+		t.Error("negated expected_any passed on a match")
+	}
+}
+
+func TestRunnerExpectedAny(t *testing.T) {
+	reg := NewSuiteRegistry()
+	err := reg.Register(&Suite{Name: "s", Vars: map[string]any{"n": "1"}, Tasks: []Task{
+		{Name: "t", Prompt: "1+1", ExpectedAny: []string{"two", "echo: {{.n}}+{{.n}}"}, Matcher: "exact"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner := NewRunner(reg, echoInfer, tokentrace.NewReporter("matchspec", ""))
+	results, err := runner.Run(context.Background(), protocol.EvalRun{Suite: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !results[0].Passed {
+		t.Errorf("result = %+v", results[0])
+	}
+}
+
+func TestValidateExpectedAny(t *testing.T) {
+	tests := []struct {
+		task Task
+		want string
+	}{
+		{Task{Name: "t", Prompt: "p", Matcher: "numeric"}, "no expected"},
+		{Task{Name: "t", Prompt: "p"}, "no expected"},
+		{Task{Name: "t", Prompt: "p", ExpectedAny: []string{"a", ""}}, "empty expected_any"},
+		{Task{Name: "t", Prompt: "p", Matcher: "regex", ExpectedAny: []string{`\d`, "("}}, "pattern"},
+		{Task{Name: "t", Prompt: "p", ExpectedAny: []string{"a"}, Assertions: []Assertion{{Matcher: "contains", Expected: "a"}}}, "assertions"},
+	}
+	for _, tt := range tests {
+		err := validateTask("s", 0, tt.task)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("validateTask(%+v) = %v, want %q", tt.task, err, tt.want)
+		}
+	}
+}
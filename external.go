@@ -0,0 +1,100 @@
+package matchspec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/greynewell/mist-go/trace"
+)
+
+// ExternalMatchRequest is the JSON body an external matcher service
+// receives for each response to grade.
+type ExternalMatchRequest struct {
+	Matcher  string         `json:"matcher"`
+	Task     string         `json:"task"`
+	Prompt   string         `json:"prompt"`
+	Response string         `json:"response"`
+	Expected string         `json:"expected"`
+	Options  map[string]any `json:"options,omitempty"`
+}
+
+// ExternalMatchResponse is the JSON body an external matcher service
+// returns. Details are free-form diagnostics, such as per-token scores,
+// recorded on the task's trace span and result (see Result.Details).
+type ExternalMatchResponse struct {
+	Passed  bool           `json:"passed"`
+	Score   float64        `json:"score"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+type externalMatcher struct {
+	url    string
+	client *http.Client
+}
+
+// WithExternalMatcher registers a matcher served over HTTP, so scorers
+// written in other languages (BERTScore, custom classifiers) can grade
+// tasks whose Matcher is name. Each response is POSTed to url as an
+// ExternalMatchRequest, with the task's MatcherOptions as options, and
+// graded by the ExternalMatchResponse. A registered name takes precedence
+// over a built-in matcher of the same name. A nil client uses one with a
+// 30 second timeout.
+func WithExternalMatcher(name, url string, client *http.Client) RunnerOption {
+	return func(r *Runner) {
+		if client == nil {
+			client = &http.Client{Timeout: 30 * time.Second}
+		}
+		if r.external == nil {
+			r.external = make(map[string]externalMatcher)
+		}
+		r.external[name] = externalMatcher{url: url, client: client}
+	}
+}
+
+// matchExternal grades a response with an external matcher service.
+func (r *Runner) matchExternal(ctx context.Context, m externalMatcher, t *Task, response string) (bool, float64, error) {
+	body, err := json.Marshal(ExternalMatchRequest{
+		Matcher:  t.Matcher,
+		Task:     t.Name,
+		Prompt:   t.Prompt,
+		Response: response,
+		Expected: t.Expected,
+		Options:  t.MatcherOptions,
+	})
+	if err != nil {
+		return false, 0.0, fmt.Errorf("matchspec: matcher %q: %w", t.Matcher, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.url, bytes.NewReader(body))
+	if err != nil {
+		return false, 0.0, fmt.Errorf("matchspec: matcher %q: %w", t.Matcher, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return false, 0.0, fmt.Errorf("matchspec: matcher %q: %w", t.Matcher, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return false, 0.0, fmt.Errorf("matchspec: matcher %q: status %d: %s", t.Matcher, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var out ExternalMatchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return false, 0.0, fmt.Errorf("matchspec: matcher %q: decode response: %w", t.Matcher, err)
+	}
+	if span := trace.FromContext(ctx); span != nil && len(out.Details) > 0 {
+		if b, err := json.Marshal(out.Details); err == nil {
+			span.SetAttr("matcher_details", r.redact(string(b)))
+		}
+	}
+	addMatchDetails(ctx, out.Details)
+	return out.Passed, out.Score, nil
+}
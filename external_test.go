@@ -0,0 +1,68 @@
+package matchspec
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestExternalMatcher(t *testing.T) {
+	var got []ExternalMatchRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ExternalMatchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		got = append(got, req)
+		if req.Task == "broken" {
+			http.Error(w, "model not loaded", http.StatusServiceUnavailable)
+			return
+		}
+		score := 0.4
+		if strings.Contains(req.Response, req.Expected) {
+			score = 0.9
+		}
+		json.NewEncoder(w).Encode(ExternalMatchResponse{
+			Passed:  score >= req.Options["threshold"].(float64),
+			Score:   score,
+			Details: map[string]any{"model": "roberta"},
+		})
+	}))
+	defer srv.Close()
+
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "ext", Tasks: []Task{
+		{Name: "good", Prompt: "hello", Expected: "hello", Matcher: "bertscore", MatcherOptions: map[string]any{"threshold": 0.8}},
+		{Name: "bad", Prompt: "hello", Expected: "goodbye", Matcher: "bertscore", MatcherOptions: map[string]any{"threshold": 0.8}},
+		{Name: "broken", Prompt: "hello", Expected: "hello", Matcher: "bertscore"},
+	}})
+	runner := NewRunner(reg, echoInfer, tokentrace.NewReporter("matchspec", ""), WithExternalMatcher("bertscore", srv.URL, nil))
+	results, err := runner.RunDetailed(context.Background(), protocol.EvalRun{Suite: "ext"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 3 || got[0].Matcher != "bertscore" || got[0].Response != "echo: hello" || got[0].Expected != "hello" || got[0].Prompt != "hello" {
+		t.Fatalf("requests = %+v", got)
+	}
+	byTask := make(map[string]Result)
+	for _, res := range results {
+		byTask[res.Task] = res
+	}
+	if r := byTask["good"]; !r.Passed || r.Score != 0.9 || r.Details["model"] != "roberta" {
+		t.Errorf("good = %v, %f, %v; want pass, 0.9, roberta", r.Passed, r.Score, r.Details)
+	}
+	if r := byTask["bad"]; r.Passed || r.Score != 0.4 {
+		t.Errorf("bad = %v, %f; want fail, 0.4", r.Passed, r.Score)
+	}
+	if r := byTask["broken"]; r.Passed || !strings.Contains(r.Error, "status 503: model not loaded") {
+		t.Errorf("broken error = %q", r.Error)
+	}
+}
@@ -0,0 +1,72 @@
+package matchspec
+
+import "fmt"
+
+// Quality gates a suite can declare (see Suite.MinPassRate and
+// Suite.MinAvgScore).
+const (
+	GatePassRate = "pass_rate"
+	GateAvgScore = "avg_score"
+)
+
+// GateResult is the outcome of one suite quality gate: the run's Actual
+// value of Gate must be at least Min.
+type GateResult struct {
+	Gate   string  `json:"gate"`
+	Min    float64 `json:"min"`
+	Actual float64 `json:"actual"`
+	Passed bool    `json:"passed"`
+}
+
+// String describes the check in a single line.
+func (g GateResult) String() string {
+	status := "ok"
+	if !g.Passed {
+		status = "FAILED"
+	}
+	return fmt.Sprintf("%s %.3f >= %.3f: %s", g.Gate, g.Actual, g.Min, status)
+}
+
+// CheckGates evaluates the suite's quality gates against a run summary. A
+// run with no results fails every gate the suite declares.
+func (s *Suite) CheckGates(sum Summary) []GateResult {
+	var gates []GateResult
+	check := func(gate string, min, actual float64) {
+		gates = append(gates, GateResult{
+			Gate:   gate,
+			Min:    min,
+			Actual: actual,
+			Passed: sum.Total > 0 && actual >= min,
+		})
+	}
+	if s.MinPassRate > 0 {
+		check(GatePassRate, s.MinPassRate, sum.PassRate)
+	}
+	if s.MinAvgScore > 0 {
+		check(GateAvgScore, s.MinAvgScore, sum.MeanScore)
+	}
+	return gates
+}
+
+// GatesPassed reports whether every gate passed.
+func GatesPassed(gates []GateResult) bool {
+	for _, g := range gates {
+		if !g.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// validateGates checks that the suite's gates are fractions.
+func validateGates(s *Suite) error {
+	for _, g := range []struct {
+		name string
+		min  float64
+	}{{"min_pass_rate", s.MinPassRate}, {"min_avg_score", s.MinAvgScore}} {
+		if g.min < 0 || g.min > 1 {
+			return fmt.Errorf("matchspec: suite %q %s %g is not between 0 and 1", s.Name, g.name, g.min)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package matchspec
+
+import (
+	"context"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestCheckGates(t *testing.T) {
+	s := &Suite{Name: "s", MinPassRate: 0.5, MinAvgScore: 0.8}
+	gates := s.CheckGates(Summary{Total: 4, PassRate: 0.75, MeanScore: 0.7})
+	if len(gates) != 2 || !gates[0].Passed || gates[1].Passed || GatesPassed(gates) {
+		t.Errorf("gates = %+v", gates)
+	}
+	if got := gates[1].String(); got != "avg_score 0.700 >= 0.800: FAILED" {
+		t.Errorf("String = %q", got)
+	}
+	if gates := s.CheckGates(Summary{}); GatesPassed(gates) {
+		t.Error("an empty run passed its gates")
+	}
+	if gates := (&Suite{Name: "s"}).CheckGates(Summary{}); len(gates) != 0 {
+		t.Errorf("suite without gates checked %+v", gates)
+	}
+
+	s.Tasks = []Task{{Name: "t", Prompt: "p", Expected: "e"}}
+	s.MinPassRate = 90
+	if err := s.Validate(); err == nil {
+		t.Error("accepted min_pass_rate 90")
+	}
+}
+
+func TestRunnerGates(t *testing.T) {
+	reg := driftRegistry()
+	suite, _ := reg.Get("math")
+	suite.MinPassRate = 0.75
+	infer := func(ctx context.Context, prompt string) (string, error) {
+		return "4", nil
+	}
+	runner := NewRunner(reg, infer, tokentrace.NewReporter("matchspec", ""))
+	if _, err := runner.Run(context.Background(), protocol.EvalRun{Suite: "math"}); err != nil {
+		t.Fatal(err)
+	}
+	runs, _ := runner.Runs(RunFilter{})
+	if g := runs[0].Gates; len(g) != 1 || g[0].Gate != GatePassRate || g[0].Actual != 0.5 || g[0].Passed {
+		t.Errorf("run gates = %+v", g)
+	}
+}
@@ -0,0 +1,227 @@
+package matchspec
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// TaskGenerator synthesizes tasks at run time. A suite that references a
+// generator has its generated tasks appended to its static tasks on every run.
+type TaskGenerator interface {
+	Generate(ctx context.Context) ([]Task, error)
+}
+
+// SeededGenerator is a TaskGenerator driven by a random seed. The runner
+// records the seed on the run's trace span so a run can be reproduced by
+// constructing the generator with the same seed.
+type SeededGenerator interface {
+	TaskGenerator
+	Seed() int64
+}
+
+// resolveSeed returns seed, or a time-derived seed when seed is zero.
+func resolveSeed(seed int64) int64 {
+	if seed != 0 {
+		return seed
+	}
+	return time.Now().UnixNano()
+}
+
+func newRand(seed int64) *rand.Rand {
+	return rand.New(rand.NewPCG(uint64(seed), uint64(seed)>>32))
+}
+
+// ArithmeticGenerator produces random addition, subtraction, and
+// multiplication problems with operands in [0, Max].
+type ArithmeticGenerator struct {
+	Count int
+	Max   int
+	seed  int64
+}
+
+// NewArithmeticGenerator creates a generator for count problems. A zero seed
+// picks a fresh seed, which is reported by Seed.
+func NewArithmeticGenerator(count int, seed int64) *ArithmeticGenerator {
+	return &ArithmeticGenerator{Count: count, Max: 100, seed: resolveSeed(seed)}
+}
+
+// Seed returns the seed used to draw problems.
+func (g *ArithmeticGenerator) Seed() int64 { return g.seed }
+
+// WithSeed returns a copy of the generator that uses seed.
+func (g *ArithmeticGenerator) WithSeed(seed int64) TaskGenerator {
+	c := *g
+	c.seed = seed
+	return &c
+}
+
+// Generate returns Count arithmetic tasks. The same seed always yields the
+// same tasks.
+func (g *ArithmeticGenerator) Generate(_ context.Context) ([]Task, error) {
+	rng := newRand(g.seed)
+	ops := []string{"+", "-", "*"}
+	tasks := make([]Task, 0, g.Count)
+	for i := 0; i < g.Count; i++ {
+		a, b := rng.IntN(g.Max+1), rng.IntN(g.Max+1)
+		op := ops[rng.IntN(len(ops))]
+		var answer int
+		switch op {
+		case "+":
+			answer = a + b
+		case "-":
+			answer = a - b
+		case "*":
+			answer = a * b
+		}
+		tasks = append(tasks, Task{
+			Name:     fmt.Sprintf("arith-%d", i),
+			Prompt:   fmt.Sprintf("What is %d %s %d? Answer with only the number.", a, op, b),
+			Expected: strconv.Itoa(answer),
+			Matcher:  "contains",
+		})
+	}
+	return tasks, nil
+}
+
+// DateMathGenerator produces "N days after date" problems. Base dates fall
+// within the year following Start.
+type DateMathGenerator struct {
+	Count   int
+	Start   time.Time
+	MaxDays int
+	seed    int64
+}
+
+// NewDateMathGenerator creates a generator for count date problems starting
+// from 2024-01-01. A zero seed picks a fresh seed, which is reported by Seed.
+func NewDateMathGenerator(count int, seed int64) *DateMathGenerator {
+	return &DateMathGenerator{
+		Count:   count,
+		Start:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		MaxDays: 90,
+		seed:    resolveSeed(seed),
+	}
+}
+
+// Seed returns the seed used to draw problems.
+func (g *DateMathGenerator) Seed() int64 { return g.seed }
+
+// WithSeed returns a copy of the generator that uses seed.
+func (g *DateMathGenerator) WithSeed(seed int64) TaskGenerator {
+	c := *g
+	c.seed = seed
+	return &c
+}
+
+// Generate returns Count date arithmetic tasks expecting YYYY-MM-DD answers.
+func (g *DateMathGenerator) Generate(_ context.Context) ([]Task, error) {
+	rng := newRand(g.seed)
+	tasks := make([]Task, 0, g.Count)
+	for i := 0; i < g.Count; i++ {
+		base := g.Start.AddDate(0, 0, rng.IntN(365))
+		days := 1 + rng.IntN(g.MaxDays)
+		tasks = append(tasks, Task{
+			Name: fmt.Sprintf("date-%d", i),
+			Prompt: fmt.Sprintf("What date is %d days after %s? Answer in YYYY-MM-DD format.",
+				days, base.Format("2006-01-02")),
+			Expected: base.AddDate(0, 0, days).Format("2006-01-02"),
+			Matcher:  "contains",
+		})
+	}
+	return tasks, nil
+}
+
+// TemplateGenerator renders Prompt and Expected text/templates once per row
+// of variables. Rows are shuffled by the seed and truncated to Count when
+// Count is positive, so different seeds exercise different orderings.
+// Templates can call json, upper, lower, trim, truncate, choice, include,
+// and default, plus functions added with RegisterTemplateFunc; choice
+// draws from the seed too, so it is reproducible. Variables from the
+// suite and runner (see WithVars) are available beneath each row's.
+type TemplateGenerator struct {
+	Name     string
+	Prompt   string
+	Expected string
+	Matcher  string
+	Rows     []map[string]string
+	Count    int
+
+	// Dir is the directory include paths are relative to. Empty means the
+	// working directory.
+	Dir string
+
+	seed int64
+}
+
+// NewTemplateGenerator creates a template generator. A zero seed picks a
+// fresh seed, which is reported by Seed.
+func NewTemplateGenerator(name, prompt, expected string, rows []map[string]string, seed int64) *TemplateGenerator {
+	return &TemplateGenerator{
+		Name:     name,
+		Prompt:   prompt,
+		Expected: expected,
+		Rows:     rows,
+		seed:     resolveSeed(seed),
+	}
+}
+
+// Seed returns the seed used to shuffle rows.
+func (g *TemplateGenerator) Seed() int64 { return g.seed }
+
+// WithSeed returns a copy of the generator that uses seed.
+func (g *TemplateGenerator) WithSeed(seed int64) TaskGenerator {
+	c := *g
+	c.seed = seed
+	return &c
+}
+
+// Generate renders one task per (shuffled) row.
+func (g *TemplateGenerator) Generate(ctx context.Context) ([]Task, error) {
+	rng := newRand(g.seed)
+	funcs := templateFuncs(rng, g.Dir)
+	promptTmpl, err := template.New("prompt").Option("missingkey=error").Funcs(funcs).Parse(g.Prompt)
+	if err != nil {
+		return nil, fmt.Errorf("matchspec: generator %q prompt: %w", g.Name, err)
+	}
+	expectedTmpl, err := template.New("expected").Option("missingkey=error").Funcs(funcs).Parse(g.Expected)
+	if err != nil {
+		return nil, fmt.Errorf("matchspec: generator %q expected: %w", g.Name, err)
+	}
+
+	order := rng.Perm(len(g.Rows))
+	if g.Count > 0 && g.Count < len(order) {
+		order = order[:g.Count]
+	}
+
+	tasks := make([]Task, 0, len(order))
+	vars := varsFrom(ctx)
+	for i, idx := range order {
+		row := maps.Clone(vars)
+		if row == nil {
+			row = make(map[string]any, len(g.Rows[idx]))
+		}
+		for k, v := range g.Rows[idx] {
+			row[k] = v
+		}
+		var prompt, expected strings.Builder
+		if err := promptTmpl.Execute(&prompt, row); err != nil {
+			return nil, fmt.Errorf("matchspec: generator %q row %d: %w", g.Name, idx, err)
+		}
+		if err := expectedTmpl.Execute(&expected, row); err != nil {
+			return nil, fmt.Errorf("matchspec: generator %q row %d: %w", g.Name, idx, err)
+		}
+		tasks = append(tasks, Task{
+			Name:     fmt.Sprintf("%s-%d", g.Name, i),
+			Prompt:   prompt.String(),
+			Expected: expected.String(),
+			Matcher:  g.Matcher,
+		})
+	}
+	return tasks, nil
+}
@@ -0,0 +1,87 @@
+package matchspec
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestArithmeticGeneratorReproducible(t *testing.T) {
+	a, _ := NewArithmeticGenerator(5, 42).Generate(context.Background())
+	b, _ := NewArithmeticGenerator(5, 42).Generate(context.Background())
+	if len(a) != 5 {
+		t.Fatalf("tasks = %d, want 5", len(a))
+	}
+	for i := range a {
+		if a[i].Prompt != b[i].Prompt || a[i].Expected != b[i].Expected {
+			t.Errorf("task %d differs for same seed: %+v vs %+v", i, a[i], b[i])
+		}
+	}
+}
+
+func TestGeneratorZeroSeedIsRecorded(t *testing.T) {
+	g := NewDateMathGenerator(3, 0)
+	if g.Seed() == 0 {
+		t.Error("zero seed should be replaced by a fresh seed")
+	}
+}
+
+func TestTemplateGenerator(t *testing.T) {
+	g := NewTemplateGenerator("capital", "What is the capital of {{.country}}?", "{{.capital}}",
+		[]map[string]string{
+			{"country": "France", "capital": "Paris"},
+			{"country": "Japan", "capital": "Tokyo"},
+			{"country": "Peru", "capital": "Lima"},
+		}, 7)
+	g.Count = 2
+
+	tasks, err := g.Generate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("tasks = %d, want 2", len(tasks))
+	}
+	for _, task := range tasks {
+		if task.Expected == "" || task.Prompt == "" {
+			t.Errorf("unrendered task: %+v", task)
+		}
+	}
+}
+
+func TestTemplateGeneratorMissingKey(t *testing.T) {
+	g := NewTemplateGenerator("bad", "{{.missing}}", "x", []map[string]string{{"a": "b"}}, 1)
+	if _, err := g.Generate(context.Background()); err == nil {
+		t.Error("expected error for missing template key")
+	}
+}
+
+type failingGenerator struct{}
+
+func (failingGenerator) Generate(context.Context) ([]Task, error) {
+	return nil, fmt.Errorf("boom")
+}
+
+func TestRunnerGeneratedSuite(t *testing.T) {
+	reg := NewSuiteRegistry()
+	if err := reg.Register(&Suite{Name: "gen", Generator: NewArithmeticGenerator(4, 1)}); err != nil {
+		t.Fatal(err)
+	}
+	reg.Register(&Suite{Name: "broken", Generator: failingGenerator{}})
+
+	runner := NewRunner(reg, echoInfer, tokentrace.NewReporter("matchspec", ""))
+	results, err := runner.Run(context.Background(), protocol.EvalRun{Suite: "gen"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 4 {
+		t.Errorf("results = %d, want 4", len(results))
+	}
+
+	if _, err := runner.Run(context.Background(), protocol.EvalRun{Suite: "broken"}); err == nil {
+		t.Error("expected generator error to fail the run")
+	}
+}
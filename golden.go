@@ -0,0 +1,170 @@
+package matchspec
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// goldenMatchers are the matchers whose Expected is a literal answer that
+// a response can replace. Tasks with an ExpectedFile are updatable with
+// any matcher.
+var goldenMatchers = []string{"exact", "diff"}
+
+// GoldenUpdate is a proposed new expected output for a task, taken from
+// its current response.
+type GoldenUpdate struct {
+	Suite    string `json:"suite"`
+	Task     string `json:"task"`
+	Expected string `json:"expected"`
+
+	// Diff is the change from the old expected output (see Diff).
+	Diff string `json:"diff"`
+}
+
+// GoldenRecorder collects golden updates from a run. Pass it to a runner
+// with WithGoldenRecorder, review Updates, and write them with
+// Suite.ApplyGolden.
+type GoldenRecorder struct {
+	mu      sync.Mutex
+	updates []GoldenUpdate
+}
+
+// NewGoldenRecorder creates an empty recorder.
+func NewGoldenRecorder() *GoldenRecorder {
+	return &GoldenRecorder{}
+}
+
+// WithGoldenRecorder records the response of every failed task whose
+// expected output can be updated: tasks with an ExpectedFile, and "exact"
+// and "diff" tasks. Tasks that error, negated tasks, tasks with
+// ExpectedAny or Assertions, and responses to tasks whose Expected is a
+// template, are not recorded. Responses are
+// redacted first, so secrets do not reach golden files.
+func WithGoldenRecorder(g *GoldenRecorder) RunnerOption {
+	return func(r *Runner) { r.golden = g }
+}
+
+// Updates returns the recorded updates in the order tasks finished. A task
+// run more than once, such as with prompt variants, keeps its first
+// response.
+func (g *GoldenRecorder) Updates() []GoldenUpdate {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return slices.Clone(g.updates)
+}
+
+// record proposes response as the expected output of t, a task of suite
+// as it was run. orig is the task as defined, or nil for generated tasks,
+// which have nowhere to write an update.
+func (g *GoldenRecorder) record(suite string, orig, t *Task, response string) {
+	if orig == nil || strings.Contains(orig.Expected, "{{") || len(t.Assertions) > 0 || len(t.ExpectedAny) > 0 || t.Negate {
+		return
+	}
+	if t.ExpectedFile == "" && !slices.Contains(goldenMatchers, t.Matcher) {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, u := range g.updates {
+		if u.Suite == suite && u.Task == t.Name {
+			return
+		}
+	}
+	g.updates = append(g.updates, GoldenUpdate{
+		Suite:    suite,
+		Task:     t.Name,
+		Expected: response,
+		Diff:     Diff(t.Expected, response),
+	})
+}
+
+// ApplyGolden writes updates for this suite to its golden files and suite
+// file, and sets the new Expected on its tasks. Tasks with an ExpectedFile
+// have that file rewritten; other tasks are rewritten in place in a JSON
+// suite file. A suite not loaded with LoadSuiteFile, or inline expected
+// outputs in a YAML suite file, cannot be updated; move them to an
+// expected_file. It returns the number of tasks updated.
+func (s *Suite) ApplyGolden(updates []GoldenUpdate) (int, error) {
+	inline := make(map[string]string)
+	applied := 0
+	for _, u := range updates {
+		if u.Suite != s.Name {
+			continue
+		}
+		i := slices.IndexFunc(s.Tasks, func(t Task) bool { return t.Name == u.Task })
+		if i < 0 {
+			return applied, fmt.Errorf("matchspec: suite %q has no task %q", s.Name, u.Task)
+		}
+		t := &s.Tasks[i]
+		if t.ExpectedFile == "" {
+			inline[t.Name] = u.Expected
+			continue
+		}
+		if err := os.WriteFile(t.ExpectedFile, []byte(u.Expected), 0o644); err != nil {
+			return applied, fmt.Errorf("matchspec: %w", err)
+		}
+		t.Expected = u.Expected
+		applied++
+	}
+	if len(inline) == 0 {
+		return applied, nil
+	}
+
+	if s.file == "" || filepath.Ext(s.file) != ".json" {
+		names := slices.Sorted(maps.Keys(inline))
+		where := "was not loaded from a file"
+		if s.file != "" {
+			where = "is defined in " + s.file
+		}
+		return applied, fmt.Errorf("matchspec: suite %q %s; give tasks %s an expected_file to update them",
+			s.Name, where, strings.Join(names, ", "))
+	}
+	data, err := os.ReadFile(s.file)
+	if err != nil {
+		return applied, fmt.Errorf("matchspec: %w", err)
+	}
+	var raw Suite
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return applied, fmt.Errorf("matchspec: %s: %w", s.file, err)
+	}
+	for i := range raw.Tasks {
+		if expected, ok := inline[raw.Tasks[i].Name]; ok {
+			raw.Tasks[i].Expected = expected
+		}
+	}
+	out, err := json.MarshalIndent(&raw, "", "  ")
+	if err != nil {
+		return applied, err
+	}
+	if err := os.WriteFile(s.file, append(out, '\n'), 0o644); err != nil {
+		return applied, fmt.Errorf("matchspec: %w", err)
+	}
+	for i := range s.Tasks {
+		if expected, ok := inline[s.Tasks[i].Name]; ok {
+			s.Tasks[i].Expected = expected
+			applied++
+		}
+	}
+	return applied, nil
+}
+
+// definedTask returns the named task as defined in the registered suite,
+// or nil if there is none.
+func (r *Runner) definedTask(suite, name string) *Task {
+	s, ok := r.registry.Get(suite)
+	if !ok {
+		return nil
+	}
+	for i := range s.Tasks {
+		if s.Tasks[i].Name == name {
+			return &s.Tasks[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package matchspec
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestGoldenUpdateJSONSuite(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "summary.txt"), []byte("old summary"), 0o644)
+	path := filepath.Join(dir, "s.json")
+	os.WriteFile(path, []byte(`{"name": "s", "tasks": [
+		{"name": "greet", "prompt": "hello", "expected": "hi", "matcher": "exact"},
+		{"name": "summary", "prompt": "summarize", "expected_file": "summary.txt", "matcher": "contains"},
+		{"name": "loose", "prompt": "anything", "expected": "zzz", "matcher": "contains"},
+		{"name": "ok", "prompt": "same", "expected": "echo: same", "matcher": "exact"}
+	]}`), 0o644)
+
+	s, err := LoadSuiteFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Tasks[1].Expected != "old summary" {
+		t.Fatalf("expected_file not loaded: %q", s.Tasks[1].Expected)
+	}
+	reg := NewSuiteRegistry()
+	reg.Register(s)
+	golden := NewGoldenRecorder()
+	runner := NewRunner(reg, echoInfer, tokentrace.NewReporter("matchspec", ""), WithGoldenRecorder(golden))
+	if _, err := runner.Run(context.Background(), protocol.EvalRun{Suite: "s"}); err != nil {
+		t.Fatal(err)
+	}
+
+	updates := golden.Updates()
+	if len(updates) != 2 {
+		t.Fatalf("updates = %+v, want greet and summary only", updates)
+	}
+	if u := updates[0]; u.Task != "greet" || u.Expected != "echo: hello" || !strings.Contains(u.Diff, "-hi\n") {
+		t.Errorf("greet update = %+v", u)
+	}
+
+	n, err := s.ApplyGolden(updates)
+	if err != nil || n != 2 {
+		t.Fatalf("ApplyGolden = %d, %v", n, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "summary.txt")); string(data) != "echo: summarize" {
+		t.Errorf("golden file = %q", data)
+	}
+
+	// The rewritten suite passes when run again.
+	s, err = LoadSuiteFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Tasks[0].Expected != "echo: hello" || s.Tasks[1].ExpectedFile == "" || s.Tasks[2].Expected != "zzz" {
+		t.Errorf("reloaded tasks = %+v", s.Tasks)
+	}
+}
+
+func TestGoldenUpdateYAMLInline(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "s.yaml")
+	os.WriteFile(path, []byte("name: s\ntasks:\n  - {name: t, prompt: p, expected: x, matcher: exact}\n"), 0o644)
+	s, err := LoadSuiteFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.ApplyGolden([]GoldenUpdate{{Suite: "s", Task: "t", Expected: "y"}})
+	if err == nil || !strings.Contains(err.Error(), "expected_file") {
+		t.Errorf("err = %v, want advice to use expected_file", err)
+	}
+}
+
+func TestGoldenSkipsTemplates(t *testing.T) {
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "s", Vars: map[string]any{"x": "1"}, Tasks: []Task{
+		{Name: "t", Prompt: "p", Expected: "{{.x}}", Matcher: "exact"},
+	}})
+	golden := NewGoldenRecorder()
+	runner := NewRunner(reg, echoInfer, tokentrace.NewReporter("matchspec", ""), WithGoldenRecorder(golden))
+	runner.Run(context.Background(), protocol.EvalRun{Suite: "s"})
+	if u := golden.Updates(); len(u) != 0 {
+		t.Errorf("updates = %+v, want templated expected outputs left alone", u)
+	}
+}
+
+func TestLoadSuiteFileExpectedConflict(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "s.json")
+	os.WriteFile(filepath.Join(dir, "g.txt"), []byte("x"), 0o644)
+	os.WriteFile(path, []byte(`{"name": "s", "tasks": [{"name": "t", "prompt": "p", "expected": "x", "expected_file": "g.txt"}]}`), 0o644)
+	if _, err := LoadSuiteFile(path); err == nil {
+		t.Error("expected error for expected and expected_file together")
+	}
+}
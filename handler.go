@@ -1,8 +1,17 @@
 package matchspec
 
 import (
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/greynewell/mist-go/protocol"
 )
@@ -43,17 +52,47 @@ func (h *Handler) Ingest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	results, err := h.runner.Run(r.Context(), run)
+	ctx, err := requestLabels(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	results, err := h.runner.RunDetailed(ctx, run)
+	writeRunResults(w, results, err)
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(results)
+// requestLabels returns r's context carrying the run labels given as
+// ?label= query parameters, which may repeat.
+func requestLabels(r *http.Request) (context.Context, error) {
+	labels := r.URL.Query()["label"]
+	for _, l := range labels {
+		if err := ValidateLabel(l); err != nil {
+			return nil, err
+		}
+	}
+	return WithRunLabels(r.Context(), labels...), nil
+}
+
+// requestRepeats adds the ?repeats= query parameter, if any, to ctx.
+func requestRepeats(ctx context.Context, r *http.Request) (context.Context, error) {
+	v := r.URL.Query().Get("repeats")
+	if v == "" {
+		return ctx, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return nil, fmt.Errorf("matchspec: invalid repeats %q", v)
+	}
+	if err := ValidateRepeats(n); err != nil {
+		return nil, err
+	}
+	return WithRunRepeats(ctx, n), nil
 }
 
-// RunDirect handles POST /eval — accepts a direct EvalRun JSON body.
+// RunDirect handles POST /eval — accepts a direct EvalRun JSON body. With
+// ?async=true or "Prefer: respond-async" it starts the run in the
+// background and responds 202 with its status; poll GET /runs/{id}.
+// ?repeats=N executes each task N times (see WithRunRepeats).
 func (h *Handler) RunDirect(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -65,8 +104,103 @@ func (h *Handler) RunDirect(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
 		return
 	}
+	ctx, err := requestLabels(r)
+	if err == nil {
+		ctx, err = requestRepeats(ctx, r)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if wantsAsync(r) {
+		id, err := h.runner.Start(ctx, run)
+		if writeQueueFull(w, err) {
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		status, _ := h.runner.Manager().Status(id)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/runs/"+id)
+		w.Header().Set("X-Queue-Position", strconv.Itoa(status.QueuePosition))
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(status)
+		return
+	}
+
+	results, err := h.runner.RunDetailed(ctx, run)
+	writeRunResults(w, results, err)
+}
+
+// wantsAsync reports whether the client asked for a run to be started in
+// the background, with ?async=true or "Prefer: respond-async".
+func wantsAsync(r *http.Request) bool {
+	if r.URL.Query().Get("async") == "true" {
+		return true
+	}
+	for _, v := range r.Header.Values("Prefer") {
+		for pref := range strings.SplitSeq(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(pref), "respond-async") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RunCampaign handles POST /campaigns — executes a Campaign JSON body and
+// returns the combined CampaignReport.
+func (h *Handler) RunCampaign(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var c Campaign
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.runner.RunCampaign(r.Context(), &c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// ScoreRequest is the JSON body for POST /score.
+type ScoreRequest struct {
+	Suite     string         `json:"suite"`
+	Responses []TaskResponse `json:"responses"`
+}
+
+// Score handles POST /score — grades already-generated responses or
+// transcripts against a suite without running inference.
+func (h *Handler) Score(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ScoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	results, err := h.runner.Run(r.Context(), run)
+	ctx, err := requestLabels(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	results, err := h.runner.Score(ctx, req.Suite, req.Responses)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -76,6 +210,33 @@ func (h *Handler) RunDirect(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(results)
 }
 
+// PartialResults is the 207 Multi-Status body returned when a run stops
+// midway: the completed results plus the run-level error.
+type PartialResults struct {
+	Results []Result  `json:"results"`
+	Error   *RunError `json:"error"`
+}
+
+// writeRunResults writes the outcome of Runner.Run. Runs that stopped
+// midway keep their completed results in a 207 response.
+func writeRunResults(w http.ResponseWriter, results []Result, err error) {
+	if writeQueueFull(w, err) {
+		return
+	}
+	var runErr *RunError
+	switch {
+	case errors.As(err, &runErr):
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMultiStatus)
+		json.NewEncoder(w).Encode(PartialResults{Results: results, Error: runErr})
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(results)
+	}
+}
+
 // SuitesResponse is the JSON body for GET /suites.
 type SuitesResponse struct {
 	Suites []SuiteInfo `json:"suites"`
@@ -83,18 +244,25 @@ type SuitesResponse struct {
 
 // SuiteInfo describes a registered suite.
 type SuiteInfo struct {
-	Name      string `json:"name"`
-	TaskCount int    `json:"task_count"`
+	Name      string      `json:"name"`
+	TaskCount int         `json:"task_count"`
+	Owner     *SuiteOwner `json:"owner,omitempty"`
 }
 
-// Suites handles GET /suites — lists all registered suites.
+// Suites handles GET /suites — lists all registered suites. Supports
+// ?owner= to list the suites a team owns.
 func (h *Handler) Suites(w http.ResponseWriter, r *http.Request) {
 	var resp SuitesResponse
+	owner := r.URL.Query().Get("owner")
 	for _, name := range h.registry.Names() {
 		if s, ok := h.registry.Get(name); ok {
+			if owner != "" && (s.Owner == nil || s.Owner.Name != owner) {
+				continue
+			}
 			resp.Suites = append(resp.Suites, SuiteInfo{
 				Name:      s.Name,
 				TaskCount: len(s.Tasks),
+				Owner:     s.Owner,
 			})
 		}
 	}
@@ -102,16 +270,664 @@ func (h *Handler) Suites(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
-// Results handles GET /results — returns all collected results.
+// SuiteStatsResponse is the JSON body for GET /suites/{name}/stats.
+type SuiteStatsResponse struct {
+	SuiteStats
+	LastRun *RunRecord `json:"last_run,omitempty"`
+}
+
+// SuiteStats handles GET /suites/{name}/stats — reports matcher and tag
+// breakdowns, average prompt length, and the most recent run of a suite.
+// Names containing "/" must be escaped (builtin%2Farithmetic).
+func (h *Handler) SuiteStats(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	s, ok := h.registry.Get(name)
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown suite %q", name), http.StatusNotFound)
+		return
+	}
+
+	resp := SuiteStatsResponse{SuiteStats: s.Stats()}
+	if runs, _ := h.runner.Runs(RunFilter{Suite: name, Limit: 1}); len(runs) > 0 {
+		resp.LastRun = &runs[0]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Results handles GET /results — returns collected results in the order
+// they were collected. Supports ?suite=, ?task=, ?run_id=, ?passed=true
+// or false, ?since= and ?until= (RFC 3339, bounding the start of the
+// result's run), ?limit= and ?offset=. The X-Total-Count header holds the
+// number of matches before paging.
 func (h *Handler) Results(w http.ResponseWriter, r *http.Request) {
-	suite := r.URL.Query().Get("suite")
-	var results []protocol.EvalResult
-	if suite != "" {
-		results = h.runner.ResultsBySuite(suite)
-	} else {
-		results = h.runner.Results()
+	q := r.URL.Query()
+	f := ResultFilter{Suite: q.Get("suite"), Task: q.Get("task"), RunID: q.Get("run_id")}
+
+	var err error
+	if f.Limit, err = intParam(q.Get("limit")); err != nil {
+		http.Error(w, "invalid limit: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if f.Offset, err = intParam(q.Get("offset")); err != nil {
+		http.Error(w, "invalid offset: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if passed := q.Get("passed"); passed != "" {
+		b, err := strconv.ParseBool(passed)
+		if err != nil {
+			http.Error(w, "invalid passed: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.Passed = &b
+	}
+	for _, p := range []struct {
+		name string
+		t    *time.Time
+	}{{"since", &f.Since}, {"until", &f.Until}} {
+		if v := q.Get(p.name); v != "" {
+			if *p.t, err = time.Parse(time.RFC3339, v); err != nil {
+				http.Error(w, "invalid "+p.name+": "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
 	}
 
+	results, total := h.runner.QueryResults(f)
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
 	json.NewEncoder(w).Encode(results)
 }
+
+// Summary handles GET /summary — returns pass counts and the score
+// distribution of collected results, optionally filtered by ?suite=.
+func (h *Handler) Summary(w http.ResponseWriter, r *http.Request) {
+	results, _ := h.runner.QueryResults(ResultFilter{Suite: r.URL.Query().Get("suite")})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Summarize(results))
+}
+
+// RunsResponse is the JSON body for GET /runs.
+type RunsResponse struct {
+	Runs  []RunRecord `json:"runs"`
+	Total int         `json:"total"`
+}
+
+// Runs handles GET /runs — lists run records newest first. Supports
+// ?suite=, ?model=, ?label= (repeatable; runs must carry all), ?since=
+// (RFC 3339), ?limit= and ?offset=. ?deleted=true lists soft-deleted runs
+// awaiting purge instead.
+func (h *Handler) Runs(w http.ResponseWriter, r *http.Request) {
+	f, ok := runFilter(w, r)
+	if !ok {
+		return
+	}
+	runs, total := h.runner.Runs(f)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RunsResponse{Runs: runs, Total: total})
+}
+
+// TrendResponse is the JSON body for GET /trend.
+type TrendResponse struct {
+	Points []TrendPoint `json:"points"`
+}
+
+// Trend handles GET /trend — the pass rate and mean score of matching runs,
+// oldest first. It takes the same parameters as GET /runs.
+func (h *Handler) Trend(w http.ResponseWriter, r *http.Request) {
+	f, ok := runFilter(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(TrendResponse{Points: h.runner.Trend(f)})
+}
+
+// runFilter parses the run filter query parameters of r, responding 400
+// and returning false if they are invalid.
+func runFilter(w http.ResponseWriter, r *http.Request) (RunFilter, bool) {
+	q := r.URL.Query()
+	f := RunFilter{Suite: q.Get("suite"), Model: q.Get("model"), Labels: q["label"], Deleted: q.Get("deleted") == "true"}
+
+	var err error
+	if f.Limit, err = intParam(q.Get("limit")); err != nil {
+		http.Error(w, "invalid limit: "+err.Error(), http.StatusBadRequest)
+		return f, false
+	}
+	if f.Offset, err = intParam(q.Get("offset")); err != nil {
+		http.Error(w, "invalid offset: "+err.Error(), http.StatusBadRequest)
+		return f, false
+	}
+	if since := q.Get("since"); since != "" {
+		if f.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			http.Error(w, "invalid since: "+err.Error(), http.StatusBadRequest)
+			return f, false
+		}
+	}
+	return f, true
+}
+
+// ExportRun handles GET /runs/{id}/checkpoint — returns a checkpoint of a
+// finished or in-progress run, gzipped if the client accepts it.
+func (h *Handler) ExportRun(w http.ResponseWriter, r *http.Request) {
+	cp, err := h.runner.ExportRun(r.PathValue("id"))
+	if errors.Is(err, ErrRunNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Add("Vary", "Accept-Encoding")
+	if !acceptsGzip(r) {
+		json.NewEncoder(w).Encode(cp)
+		return
+	}
+	w.Header().Set("Content-Encoding", "gzip")
+	zw := gzip.NewWriter(w)
+	json.NewEncoder(zw).Encode(cp)
+	zw.Close()
+}
+
+// acceptsGzip reports whether the client accepts gzip responses.
+func acceptsGzip(r *http.Request) bool {
+	for _, v := range r.Header.Values("Accept-Encoding") {
+		for enc := range strings.SplitSeq(v, ",") {
+			name, q, _ := strings.Cut(strings.TrimSpace(enc), ";")
+			if strings.EqualFold(strings.TrimSpace(name), "gzip") && strings.ReplaceAll(q, " ", "") != "q=0" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetRun handles GET /runs/{id} — the status of a run: pending, running
+// with its progress, or done with its record and results.
+func (h *Handler) GetRun(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	status, ok := h.runner.Manager().Status(id)
+	if !ok {
+		http.Error(w, fmt.Sprintf("%v: %q", ErrRunNotFound, id), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// CompareRuns handles GET /runs/compare?a=ID&b=ID — a paired significance
+// test of run b against run a (see Runner.Compare).
+func (h *Handler) CompareRuns(w http.ResponseWriter, r *http.Request) {
+	a, b := r.URL.Query().Get("a"), r.URL.Query().Get("b")
+	if a == "" || b == "" {
+		http.Error(w, "a and b run IDs are required", http.StatusBadRequest)
+		return
+	}
+	c, err := h.runner.Compare(a, b)
+	if errors.Is(err, ErrRunNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
+
+// StreamRun handles GET /runs/{id}/stream — a Server-Sent Events stream
+// of the run's results. Each completed task is a "result" event whose ID
+// is its position in the run, and the stream ends with a "summary" event
+// carrying the run record, or an "error" event if the run never started.
+// A reconnecting client's Last-Event-ID skips the results it has seen.
+func (h *Handler) StreamRun(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, ok := h.runner.Manager().Status(id); !ok {
+		http.Error(w, fmt.Sprintf("%v: %q", ErrRunNotFound, id), http.StatusNotFound)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	skip := 0
+	if last := r.Header.Get("Last-Event-ID"); last != "" {
+		n, err := strconv.Atoi(last)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		skip = n + 1
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	n := 0
+	rec, err := h.runner.Manager().Watch(r.Context(), id, func(res Result) error {
+		defer func() { n++ }()
+		if n < skip {
+			return nil
+		}
+		if err := writeEvent(w, "result", strconv.Itoa(n), res); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	})
+	switch {
+	case r.Context().Err() != nil:
+		return
+	case err != nil:
+		writeEvent(w, "error", "", map[string]string{"error": err.Error()})
+	default:
+		writeEvent(w, "summary", "", rec)
+	}
+	flusher.Flush()
+}
+
+// writeEvent writes one Server-Sent Event with a JSON data line.
+func writeEvent(w io.Writer, event, id string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
+
+// ActiveRuns handles GET /runs/active — lists the runs in progress with
+// their progress.
+func (h *Handler) ActiveRuns(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.runner.Manager().Active())
+}
+
+// CancelRun handles POST /runs/{id}/cancel — stops a run in progress and
+// responds 202 with it. The run is recorded with the results completed
+// before it stopped.
+func (h *Handler) CancelRun(w http.ResponseWriter, r *http.Request) {
+	run, err := h.runner.Manager().Cancel(r.PathValue("id"))
+	switch {
+	case errors.Is(err, ErrRunNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(run)
+}
+
+// DeleteResponse is the JSON body for DELETE /runs/{id} and
+// DELETE /results.
+type DeleteResponse struct {
+	Deleted int `json:"deleted"`
+}
+
+// DeleteRun handles DELETE /runs/{id} — soft-deletes a run and its
+// results.
+func (h *Handler) DeleteRun(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	n, err := h.runner.DeleteRuns(DeleteFilter{RunID: id})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if n == 0 {
+		http.Error(w, fmt.Sprintf("%v: %q", ErrRunNotFound, id), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(DeleteResponse{Deleted: n})
+}
+
+// DeleteResults handles DELETE /results — soft-deletes the runs, and their
+// results, matching ?run=, ?suite=, and ?before= (RFC 3339) or
+// ?older_than= (a duration such as 720h). At least one is required.
+func (h *Handler) DeleteResults(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := DeleteFilter{RunID: q.Get("run"), Suite: q.Get("suite")}
+	before, olderThan := q.Get("before"), q.Get("older_than")
+	switch {
+	case before != "" && olderThan != "":
+		http.Error(w, "set before or older_than, not both", http.StatusBadRequest)
+		return
+	case before != "":
+		t, err := time.Parse(time.RFC3339, before)
+		if err != nil {
+			http.Error(w, "invalid before: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.Before = t
+	case olderThan != "":
+		d, err := time.ParseDuration(olderThan)
+		if err != nil || d <= 0 {
+			http.Error(w, "invalid older_than: must be a positive duration", http.StatusBadRequest)
+			return
+		}
+		f.Before = time.Now().Add(-d)
+	}
+	n, err := h.runner.DeleteRuns(f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(DeleteResponse{Deleted: n})
+}
+
+// RestoreRun handles POST /runs/{id}/restore — undoes the soft delete of a
+// run not yet purged and returns its record.
+func (h *Handler) RestoreRun(w http.ResponseWriter, r *http.Request) {
+	rec, err := h.runner.RestoreRun(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rec)
+}
+
+// PurgeResults handles POST /results/purge — permanently removes
+// soft-deleted runs and their results. ?deleted_before= (RFC 3339) spares
+// runs deleted after it.
+func (h *Handler) PurgeResults(w http.ResponseWriter, r *http.Request) {
+	var before time.Time
+	if s := r.URL.Query().Get("deleted_before"); s != "" {
+		var err error
+		if before, err = time.Parse(time.RFC3339, s); err != nil {
+			http.Error(w, "invalid deleted_before: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.runner.Purge(before))
+}
+
+// ErasureRequest is the JSON body for POST /erasure. Set exactly one of
+// Text, matched literally, or Pattern, a regular expression.
+type ErasureRequest struct {
+	Text    string `json:"text,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// Erase handles POST /erasure — erases stored text matching a data
+// subject's identifier from results, caches, and artifacts, and returns an
+// ErasureReport. It responds 500 with the report's counts in the body if
+// a store failed partway.
+func (h *Handler) Erase(w http.ResponseWriter, r *http.Request) {
+	var req ErasureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	pattern, err := ErasurePattern(req.Text, req.Pattern)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	report, err := h.runner.Erase(pattern)
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(struct {
+			ErasureReport
+			Error string `json:"error"`
+		}{report, err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// ImportRun handles POST /runs/import — stores a checkpointed run, plain
+// or gzipped, and responds 201 with its record.
+func (h *Handler) ImportRun(w http.ResponseWriter, r *http.Request) {
+	cp, err := ReadCheckpoint(r.Body)
+	if err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch err := h.runner.ImportRun(cp); {
+	case errors.Is(err, ErrRunExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rec, _ := h.runner.GetRun(cp.Record.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rec)
+}
+
+// BaselinesResponse is the JSON body for GET /baselines.
+type BaselinesResponse struct {
+	Baselines []Baseline `json:"baselines"`
+}
+
+// Baselines handles GET /baselines — lists the baseline of every suite
+// that has one, ordered by suite. It is empty without a baseline store.
+func (h *Handler) Baselines(w http.ResponseWriter, r *http.Request) {
+	baselines := []Baseline{}
+	if store := h.runner.Baselines(); store != nil {
+		var err error
+		if baselines, err = store.List(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(BaselinesResponse{Baselines: baselines})
+}
+
+// GetBaseline handles GET /baselines/{suite} — returns a suite's
+// baseline.
+func (h *Handler) GetBaseline(w http.ResponseWriter, r *http.Request) {
+	suite := r.PathValue("suite")
+	var (
+		b   Baseline
+		ok  bool
+		err error
+	)
+	if store := h.runner.Baselines(); store != nil {
+		b, ok, err = store.Get(suite)
+	}
+	switch {
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	case !ok:
+		http.Error(w, fmt.Sprintf("no baseline for suite %q", suite), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(b)
+}
+
+// RecordBaselineRequest is the JSON body for POST /baselines.
+type RecordBaselineRequest struct {
+	RunID string `json:"run_id"`
+}
+
+// RecordBaseline handles POST /baselines — makes a recorded run the
+// baseline of its suite and responds 201 with the baseline.
+func (h *Handler) RecordBaseline(w http.ResponseWriter, r *http.Request) {
+	var req RecordBaselineRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	b, err := h.runner.RecordBaseline(req.RunID)
+	switch {
+	case errors.Is(err, ErrRunNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(b)
+}
+
+// intParam parses a non-negative integer query parameter. Empty means 0.
+func intParam(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("must not be negative")
+	}
+	return n, nil
+}
+
+// JobHandler provides HTTP handlers for a JobQueue.
+type JobHandler struct {
+	queue JobQueue
+}
+
+// NewJobHandler creates handlers for q.
+func NewJobHandler(q JobQueue) *JobHandler {
+	return &JobHandler{queue: q}
+}
+
+// EnqueueJobRequest is the JSON body for POST /jobs.
+type EnqueueJobRequest struct {
+	Run      protocol.EvalRun  `json:"run"`
+	Priority int               `json:"priority,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+}
+
+// Enqueue handles POST /jobs — queues a run and responds 202 with the job.
+func (h *JobHandler) Enqueue(w http.ResponseWriter, r *http.Request) {
+	var req EnqueueJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	job := Job{Run: req.Run, Priority: req.Priority, Labels: req.Labels}
+	chargeJob(r.Context(), &job)
+	job, err := h.queue.Enqueue(r.Context(), job)
+	if writeQueueFull(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	noteAuditDetail(r.Context(), "job_id", job.ID)
+	if jobs, err := h.queue.List(r.Context()); err == nil {
+		w.Header().Set("X-Queue-Position", strconv.Itoa(jobPosition(jobs, job.ID)))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// jobPosition returns the 1-based place of the queued job id in claim
+// order: behind every queued job of higher priority, and every earlier one
+// of equal priority. It returns 0 if the job is not queued.
+func jobPosition(jobs []Job, id string) int {
+	i := slices.IndexFunc(jobs, func(j Job) bool { return j.ID == id })
+	if i < 0 || jobs[i].State != JobQueued {
+		return 0
+	}
+	pos := 1
+	for k, j := range jobs {
+		if j.State == JobQueued && (j.Priority > jobs[i].Priority || j.Priority == jobs[i].Priority && k < i) {
+			pos++
+		}
+	}
+	return pos
+}
+
+// JobsResponse is the JSON body for GET /jobs.
+type JobsResponse struct {
+	Jobs []Job `json:"jobs"`
+}
+
+// List handles GET /jobs — lists jobs in claim order. Supports ?state=.
+func (h *JobHandler) List(w http.ResponseWriter, r *http.Request) {
+	jobs, err := h.queue.List(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if state := JobState(r.URL.Query().Get("state")); state != "" {
+		jobs = slices.DeleteFunc(jobs, func(j Job) bool { return j.State != state })
+	}
+	if jobs == nil {
+		jobs = []Job{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(JobsResponse{Jobs: jobs})
+}
+
+// Get handles GET /jobs/{id}.
+func (h *JobHandler) Get(w http.ResponseWriter, r *http.Request) {
+	job, err := h.queue.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeJobError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// Cancel handles DELETE /jobs/{id} — cancels a queued job.
+func (h *JobHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	if err := h.queue.Cancel(r.Context(), r.PathValue("id")); err != nil {
+		writeJobError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetPriority handles POST /jobs/{id}/priority with a {"priority": n} body.
+func (h *JobHandler) SetPriority(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Priority int `json:"priority"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.queue.SetPriority(r.Context(), r.PathValue("id"), req.Priority); err != nil {
+		writeJobError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeJobError maps queue errors to 404 for unknown jobs and 409 for jobs
+// in the wrong state.
+func writeJobError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrJobNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusConflict)
+}
@@ -0,0 +1,70 @@
+package matchspec
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+)
+
+type headersKey struct{}
+
+// WithHeaders returns a context carrying HTTP headers for inference
+// requests, merged over any headers ctx already carries. Inference
+// functions that support them, such as InferMuxFunc, read them with
+// HeadersFrom.
+func WithHeaders(ctx context.Context, headers map[string]string) context.Context {
+	if len(headers) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, headersKey{}, mergeHeaders(HeadersFrom(ctx), headers))
+}
+
+// HeadersFrom returns the request headers carried by ctx, if any.
+func HeadersFrom(ctx context.Context) map[string]string {
+	h, _ := ctx.Value(headersKey{}).(map[string]string)
+	return h
+}
+
+// withoutHeaders returns a context that carries no request headers, for
+// calls to backends other than the one under evaluation.
+func withoutHeaders(ctx context.Context) context.Context {
+	if HeadersFrom(ctx) == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, headersKey{}, map[string]string(nil))
+}
+
+// mergeHeaders returns base overlaid with over, matching names
+// case-insensitively. The result takes the spelling from over.
+func mergeHeaders(base, over map[string]string) map[string]string {
+	if len(base) == 0 {
+		return over
+	}
+	if len(over) == 0 {
+		return base
+	}
+	merged := maps.Clone(base)
+	for k, v := range over {
+		for b := range merged {
+			if strings.EqualFold(b, k) {
+				delete(merged, b)
+			}
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+// validateHeaders checks that header names and values can be sent.
+func validateHeaders(suite, owner string, headers map[string]string) error {
+	for name, value := range headers {
+		if name == "" || strings.ContainsAny(name, " \t\r\n:") {
+			return fmt.Errorf("matchspec: suite %q %s has invalid header name %q", suite, owner, name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("matchspec: suite %q %s header %q contains a line break", suite, owner, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package matchspec
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestRunnerHeaders(t *testing.T) {
+	var mu sync.Mutex
+	sent := make(map[string]map[string]string)
+	infer := func(ctx context.Context, prompt string) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		sent[prompt] = HeadersFrom(ctx)
+		return "ok", nil
+	}
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{
+		Name:    "gateway",
+		Headers: map[string]string{"X-Tenant": "acme", "Authorization": "Bearer {{.token}}"},
+		Tasks: []Task{
+			{Name: "default", Prompt: "a", Expected: "ok"},
+			{Name: "override", Prompt: "b", Expected: "ok", Headers: map[string]string{"x-tenant": "globex", "X-Flag": "beta"}},
+		},
+	})
+	runner := NewRunner(reg, infer, tokentrace.NewReporter("matchspec", ""),
+		WithSecretVars(map[string]string{"token": "sk-123"}))
+	if _, err := runner.Run(context.Background(), protocol.EvalRun{Suite: "gateway"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if h := sent["a"]; len(h) != 2 || h["X-Tenant"] != "acme" || h["Authorization"] != "Bearer sk-123" {
+		t.Errorf("default task headers = %v", h)
+	}
+	if h := sent["b"]; len(h) != 3 || h["x-tenant"] != "globex" || h["X-Flag"] != "beta" {
+		t.Errorf("override task headers = %v, want task headers over suite headers", h)
+	}
+	if s, _ := reg.Get("gateway"); s.Tasks[0].Headers != nil {
+		t.Error("suite headers were written into the registered suite")
+	}
+}
+
+func TestInferMuxHeaders(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+		w.Write([]byte(`{"content": "ok"}`))
+	}))
+	defer srv.Close()
+
+	ctx := WithHeaders(context.Background(), map[string]string{"X-Tenant": "acme", "Content-Type": "text/plain"})
+	ctx = WithHeaders(ctx, map[string]string{"X-Flag": "beta"})
+	if _, err := InferMuxFunc(srv.URL, "m")(ctx, "p"); err != nil {
+		t.Fatal(err)
+	}
+	if got.Get("X-Tenant") != "acme" || got.Get("X-Flag") != "beta" || got.Get("Content-Type") != "application/json" {
+		t.Errorf("headers = %v", got)
+	}
+}
+
+func TestJudgeDoesNotInheritHeaders(t *testing.T) {
+	var judged map[string]string
+	judge := func(ctx context.Context, prompt string) (string, error) {
+		judged = HeadersFrom(ctx)
+		return "SUPPORTED", nil
+	}
+	r := NewRunner(NewSuiteRegistry(), echoInfer, tokentrace.NewReporter("matchspec", ""), WithJudge(judge))
+	ctx := WithHeaders(context.Background(), map[string]string{"X-Tenant": "acme"})
+	r.judgeInfer()(ctx, "p")
+	if judged != nil {
+		t.Errorf("judge received headers %v", judged)
+	}
+}
+
+func TestValidateHeaders(t *testing.T) {
+	for _, h := range []map[string]string{
+		{"": "x"},
+		{"X Tenant": "x"},
+		{"X-Tenant": "a\r\nX-Injected: b"},
+	} {
+		s := &Suite{Name: "s", Tasks: []Task{{Name: "t", Prompt: "p", Headers: h}}}
+		if err := s.Validate(); err == nil {
+			t.Errorf("headers %q: expected error", h)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package matchspec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/greynewell/mist-go/health"
+)
+
+// HealthCheck probes a backend, returning nil if it is healthy.
+type HealthCheck func(ctx context.Context) error
+
+// HTTPHealthCheck returns a HealthCheck that GETs url and treats any 2xx
+// response as healthy. A nil client uses one with a 10s timeout.
+func HTTPHealthCheck(url string, client *http.Client) HealthCheck {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("health check: status %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// Backend health states reported by BackendStatus.
+const (
+	BackendUnknown = "unknown" // not checked yet
+	BackendUp      = "up"
+	BackendDown    = "down"
+)
+
+// BackendStatus is the health of one backend, as served by GET /backends.
+type BackendStatus struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+
+	// LatencyMS is the duration of the last health check.
+	LatencyMS int64     `json:"latency_ms"`
+	LastCheck time.Time `json:"last_check,omitzero"`
+	LastError string    `json:"last_error,omitempty"`
+
+	// ErrorRate is the fraction of failures among the backend's recent
+	// health checks and inference calls (see BackendMonitor.Wrap), and
+	// Samples the number of outcomes it covers.
+	ErrorRate float64 `json:"error_rate"`
+	Samples   int     `json:"samples"`
+}
+
+// DefaultHealthWindow is the number of recent outcomes per backend that
+// BackendMonitor computes error rates over.
+const DefaultHealthWindow = 100
+
+// BackendMonitor tracks the health of registered backends with periodic
+// checks and the outcomes of real inference calls. It adds error rates and
+// background probing to the mist-go health package, whose Handler serves
+// liveness and readiness; see AddTo.
+type BackendMonitor struct {
+	mu       sync.Mutex
+	window   int
+	backends map[string]*backendHealth
+	probes   *health.Handler
+}
+
+type backendHealth struct {
+	check  HealthCheck
+	status BackendStatus
+	// outcomes is a ring of recent results, true for failures.
+	outcomes []bool
+	next     int
+}
+
+// NewBackendMonitor creates a monitor computing error rates over the last
+// window outcomes of each backend (DefaultHealthWindow if window <= 0).
+func NewBackendMonitor(window int) *BackendMonitor {
+	if window <= 0 {
+		window = DefaultHealthWindow
+	}
+	return &BackendMonitor{window: window, backends: make(map[string]*backendHealth)}
+}
+
+// Register adds a backend probed by check. A nil check tracks the backend
+// only through wrapped inference calls.
+func (m *BackendMonitor) Register(name string, check HealthCheck) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.backends[name] = &backendHealth{
+		check:  check,
+		status: BackendStatus{Name: name, Status: BackendUnknown},
+	}
+	if m.probes != nil {
+		m.probes.AddCheck(name, m.ready(name))
+	}
+}
+
+// AddTo makes every backend, including those registered later, a
+// readiness check of h, so h.Readiness reports the service degraded while
+// a backend is down. The checks read the monitor's latest status rather
+// than probing again.
+func (m *BackendMonitor) AddTo(h *health.Handler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.probes = h
+	for name := range m.backends {
+		h.AddCheck(name, m.ready(name))
+	}
+}
+
+// ready returns a readiness check that fails while the named backend is
+// down.
+func (m *BackendMonitor) ready(name string) health.CheckFunc {
+	return func() error {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		b := m.backends[name]
+		if b == nil || b.status.Status != BackendDown {
+			return nil
+		}
+		if b.status.LastError != "" {
+			return errors.New(b.status.LastError)
+		}
+		return errors.New(BackendDown)
+	}
+}
+
+// Wrap returns an InferFunc that records each call's outcome against the
+// named backend, so the error rate reflects real traffic between checks.
+// Calls canceled by their context are not counted.
+func (m *BackendMonitor) Wrap(name string, infer InferFunc) InferFunc {
+	return func(ctx context.Context, prompt string) (string, error) {
+		out, err := infer(ctx, prompt)
+		if ctx.Err() == nil {
+			m.record(name, err)
+		}
+		return out, err
+	}
+}
+
+// Check runs every backend's health check once, concurrently.
+func (m *BackendMonitor) Check(ctx context.Context) {
+	m.mu.Lock()
+	checks := make(map[string]HealthCheck, len(m.backends))
+	for name, b := range m.backends {
+		if b.check != nil {
+			checks[name] = b.check
+		}
+	}
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := check(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			b := m.backends[name]
+			if b == nil {
+				return
+			}
+			b.status.LatencyMS = time.Since(start).Milliseconds()
+			b.status.LastCheck = start
+			b.status.LastError = ""
+			b.status.Status = BackendUp
+			if err != nil {
+				b.status.LastError = err.Error()
+				b.status.Status = BackendDown
+			}
+			b.add(err != nil, m.window)
+		}()
+	}
+	wg.Wait()
+}
+
+// Run checks every backend each interval until ctx is done.
+func (m *BackendMonitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Statuses returns the status of every backend, sorted by name.
+func (m *BackendMonitor) Statuses() []BackendStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]BackendStatus, 0, len(m.backends))
+	for _, b := range m.backends {
+		out = append(out, b.status)
+	}
+	slices.SortFunc(out, func(a, b BackendStatus) int { return strings.Compare(a.Name, b.Name) })
+	return out
+}
+
+// BackendsResponse is the JSON body for GET /backends.
+type BackendsResponse struct {
+	Backends []BackendStatus `json:"backends"`
+}
+
+// ServeHTTP handles GET /backends — reports each backend's status, last
+// check latency, and recent error rate.
+func (m *BackendMonitor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(BackendsResponse{Backends: m.Statuses()})
+}
+
+// record counts an inference outcome for the named backend. An unchecked
+// backend's status follows its calls.
+func (m *BackendMonitor) record(name string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b := m.backends[name]
+	if b == nil {
+		return
+	}
+	if err != nil {
+		b.status.LastError = err.Error()
+	}
+	if b.check == nil {
+		b.status.Status = BackendUp
+		if err != nil {
+			b.status.Status = BackendDown
+		}
+	}
+	b.add(err != nil, m.window)
+}
+
+func (b *backendHealth) add(failed bool, window int) {
+	if len(b.outcomes) < window {
+		b.outcomes = append(b.outcomes, failed)
+	} else {
+		b.outcomes[b.next] = failed
+		b.next = (b.next + 1) % window
+	}
+	n := 0
+	for _, f := range b.outcomes {
+		if f {
+			n++
+		}
+	}
+	b.status.Samples = len(b.outcomes)
+	b.status.ErrorRate = float64(n) / float64(len(b.outcomes))
+}
@@ -0,0 +1,116 @@
+package matchspec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/greynewell/mist-go/health"
+)
+
+func TestBackendMonitorCheck(t *testing.T) {
+	healthy := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	m := NewBackendMonitor(4)
+	m.Register("infermux", HTTPHealthCheck(srv.URL, nil))
+	if s := m.Statuses()[0]; s.Status != BackendUnknown {
+		t.Errorf("status before check = %q, want unknown", s.Status)
+	}
+
+	m.Check(context.Background())
+	healthy = false
+	m.Check(context.Background())
+	s := m.Statuses()[0]
+	if s.Status != BackendDown || s.LastError == "" || s.ErrorRate != 0.5 || s.Samples != 2 {
+		t.Errorf("unexpected status: %+v", s)
+	}
+
+	// The window keeps only the last four outcomes.
+	healthy = true
+	for range 4 {
+		m.Check(context.Background())
+	}
+	if s := m.Statuses()[0]; s.Status != BackendUp || s.LastError != "" || s.ErrorRate != 0 || s.Samples != 4 {
+		t.Errorf("unexpected status after recovery: %+v", s)
+	}
+}
+
+func TestBackendMonitorWrap(t *testing.T) {
+	m := NewBackendMonitor(0)
+	m.Register("a", nil)
+	m.Register("b", func(context.Context) error { return nil })
+
+	infer := m.Wrap("a", echoInfer)
+	failing := m.Wrap("a", failInfer)
+	infer(context.Background(), "x")
+	failing(context.Background(), "x")
+	failing(context.Background(), "x")
+	failing(context.Background(), "x")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m.Wrap("a", func(ctx context.Context, _ string) (string, error) { return "", ctx.Err() })(ctx, "x")
+
+	statuses := m.Statuses()
+	if len(statuses) != 2 || statuses[0].Name != "a" || statuses[1].Name != "b" {
+		t.Fatalf("statuses = %+v, want a and b", statuses)
+	}
+	a := statuses[0]
+	if a.Status != BackendDown || a.ErrorRate != 0.75 || a.Samples != 4 {
+		t.Errorf("a = %+v, want down with error rate 0.75 over 4 calls", a)
+	}
+	if statuses[1].Status != BackendUnknown {
+		t.Errorf("b = %+v, want unknown until checked", statuses[1])
+	}
+}
+
+func TestBackendsHandler(t *testing.T) {
+	m := NewBackendMonitor(0)
+	m.Register("infermux", func(context.Context) error { return errors.New("connection refused") })
+	m.Check(context.Background())
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/backends", nil))
+	var resp BackendsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Backends) != 1 || resp.Backends[0].LastError != "connection refused" || resp.Backends[0].ErrorRate != 1 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestBackendMonitorReadiness(t *testing.T) {
+	var down error
+	m := NewBackendMonitor(0)
+	m.Register("infermux", func(context.Context) error { return down })
+	probes := health.New("matchspec", "test")
+	m.AddTo(probes)
+	// Backends registered later are checked too.
+	m.Register("result-store", nil)
+
+	ready := func() (int, health.Response) {
+		rec := httptest.NewRecorder()
+		probes.Readiness()(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var resp health.Response
+		json.NewDecoder(rec.Body).Decode(&resp)
+		return rec.Code, resp
+	}
+	if code, resp := ready(); code != http.StatusOK || len(resp.Checks) != 2 {
+		t.Errorf("unchecked backends: %d %+v", code, resp)
+	}
+	down = errors.New("connection refused")
+	m.Check(context.Background())
+	if code, resp := ready(); code != http.StatusServiceUnavailable || resp.Checks["infermux"] != "connection refused" || resp.Checks["result-store"] != "ok" {
+		t.Errorf("infermux down: %d %+v", code, resp)
+	}
+}
@@ -0,0 +1,224 @@
+package matchspec
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Incident API endpoints used by default.
+const (
+	PagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+	OpsgenieAlertsURL  = "https://api.opsgenie.com/v2/alerts"
+)
+
+// maxIncidentDetails caps the run report attached to an incident, within
+// the limits of both PagerDuty and Opsgenie.
+const maxIncidentDetails = 15000
+
+// incidentReason reports why rec is an incident: it ended in an error or
+// its pass rate is below minPassRate (1 if zero, so any failed task is).
+// It returns "" if rec is healthy.
+func incidentReason(rec RunRecord, minPassRate float64) string {
+	if minPassRate == 0 {
+		minPassRate = 1
+	}
+	switch {
+	case rec.Error != "":
+		return "run failed: " + rec.Error
+	case rec.Summary.Total > 0 && rec.Summary.PassRate < minPassRate:
+		return fmt.Sprintf("pass rate %.1f%% is below %.1f%%", 100*rec.Summary.PassRate, 100*minPassRate)
+	}
+	return ""
+}
+
+// incidentKey identifies the incident of a suite and model, so repeated
+// failures update one incident and a healthy run resolves it.
+func incidentKey(rec RunRecord) string {
+	key := "matchspec:" + rec.Suite
+	if rec.Model != "" {
+		key += ":" + rec.Model
+	}
+	return key
+}
+
+// incidentDetails is the Markdown report of a run, truncated to fit an
+// incident.
+func incidentDetails(rec RunRecord, results []Result, alerts []DriftAlert) string {
+	details := NewRunReport(rec, results, alerts).Markdown()
+	if len(details) > maxIncidentDetails {
+		details = details[:maxIncidentDetails]
+	}
+	return details
+}
+
+// PagerDutyNotifier opens a PagerDuty incident through the Events API v2
+// when a run breaches its pass criteria or regresses, and resolves it when
+// a later run of the same suite and model is healthy. It is meant for
+// canary suites run on a schedule, such as by MonitorDrift.
+type PagerDutyNotifier struct {
+	RoutingKey string
+
+	// MinPassRate is the lowest pass rate that is not an incident. Zero
+	// means 1: any failed task opens one. Runs that end in an error
+	// always do.
+	MinPassRate float64
+
+	// Severity is the PagerDuty event severity (default "critical").
+	Severity string
+
+	// URL is the Events API endpoint (default PagerDutyEventsURL).
+	URL    string
+	Client *http.Client
+}
+
+func newPagerDutyNotifier(cfg NotifierConfig, client *http.Client) (Notifier, error) {
+	if cfg.RoutingKeyEnv == "" {
+		return nil, fmt.Errorf("matchspec: pagerduty notifier has no routing_key_env")
+	}
+	key := os.Getenv(cfg.RoutingKeyEnv)
+	if key == "" {
+		return nil, fmt.Errorf("matchspec: pagerduty notifier routing key $%s is not set", cfg.RoutingKeyEnv)
+	}
+	return &PagerDutyNotifier{RoutingKey: key, MinPassRate: cfg.MinPassRate, URL: cfg.URL, Client: client}, nil
+}
+
+// pagerDutyEvent is an Events API v2 event.
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string         `json:"summary"`
+	Source        string         `json:"source"`
+	Severity      string         `json:"severity"`
+	Component     string         `json:"component,omitempty"`
+	Group         string         `json:"group,omitempty"`
+	CustomDetails map[string]any `json:"custom_details,omitempty"`
+}
+
+// RunCompleted triggers an incident if rec breaches the pass criteria,
+// and resolves any open one otherwise.
+func (p *PagerDutyNotifier) RunCompleted(ctx context.Context, rec RunRecord, results []Result) error {
+	reason := incidentReason(rec, p.MinPassRate)
+	if reason == "" {
+		return p.send(ctx, pagerDutyEvent{EventAction: "resolve", DedupKey: incidentKey(rec)})
+	}
+	return p.trigger(ctx, rec, reason, incidentDetails(rec, results, nil))
+}
+
+// RegressionDetected triggers an incident for the regression.
+func (p *PagerDutyNotifier) RegressionDetected(ctx context.Context, rec RunRecord, results []Result, alerts []DriftAlert) error {
+	return p.trigger(ctx, rec, "regressed from its baseline", incidentDetails(rec, results, alerts))
+}
+
+func (p *PagerDutyNotifier) trigger(ctx context.Context, rec RunRecord, reason, details string) error {
+	severity := p.Severity
+	if severity == "" {
+		severity = "critical"
+	}
+	return p.send(ctx, pagerDutyEvent{
+		EventAction: "trigger",
+		DedupKey:    incidentKey(rec),
+		Payload: &pagerDutyPayload{
+			Summary:       fmt.Sprintf("matchspec: suite %q %s", rec.Suite, reason),
+			Source:        "matchspec",
+			Severity:      severity,
+			Component:     rec.Suite,
+			Group:         rec.Model,
+			CustomDetails: map[string]any{"run_id": rec.ID, "report": details},
+		},
+	})
+}
+
+func (p *PagerDutyNotifier) send(ctx context.Context, ev pagerDutyEvent) error {
+	ev.RoutingKey = p.RoutingKey
+	endpoint := p.URL
+	if endpoint == "" {
+		endpoint = PagerDutyEventsURL
+	}
+	return postJSON(ctx, p.Client, endpoint, "pagerduty event", ev)
+}
+
+// OpsgenieNotifier opens an Opsgenie alert when a run breaches its pass
+// criteria or regresses, and closes it when a later run of the same suite
+// and model is healthy. It is meant for canary suites run on a schedule,
+// such as by MonitorDrift.
+type OpsgenieNotifier struct {
+	APIKey string
+
+	// MinPassRate is the lowest pass rate that is not an incident, as for
+	// PagerDutyNotifier.
+	MinPassRate float64
+
+	// Priority is the alert priority, "P1" to "P5" (default "P1").
+	Priority string
+
+	// URL is the Alert API endpoint (default OpsgenieAlertsURL).
+	URL    string
+	Client *http.Client
+}
+
+func newOpsgenieNotifier(cfg NotifierConfig, client *http.Client) (Notifier, error) {
+	if cfg.APIKeyEnv == "" {
+		return nil, fmt.Errorf("matchspec: opsgenie notifier has no api_key_env")
+	}
+	key := os.Getenv(cfg.APIKeyEnv)
+	if key == "" {
+		return nil, fmt.Errorf("matchspec: opsgenie notifier API key $%s is not set", cfg.APIKeyEnv)
+	}
+	return &OpsgenieNotifier{APIKey: key, MinPassRate: cfg.MinPassRate, URL: cfg.URL, Client: client}, nil
+}
+
+// RunCompleted opens an alert if rec breaches the pass criteria, and
+// closes any open one otherwise.
+func (o *OpsgenieNotifier) RunCompleted(ctx context.Context, rec RunRecord, results []Result) error {
+	reason := incidentReason(rec, o.MinPassRate)
+	if reason == "" {
+		endpoint := o.endpoint() + "/" + url.PathEscape(incidentKey(rec)) + "/close?identifierType=alias"
+		return o.post(ctx, endpoint, map[string]string{"source": "matchspec"})
+	}
+	return o.open(ctx, rec, reason, incidentDetails(rec, results, nil))
+}
+
+// RegressionDetected opens an alert for the regression.
+func (o *OpsgenieNotifier) RegressionDetected(ctx context.Context, rec RunRecord, results []Result, alerts []DriftAlert) error {
+	return o.open(ctx, rec, "regressed from its baseline", incidentDetails(rec, results, alerts))
+}
+
+func (o *OpsgenieNotifier) open(ctx context.Context, rec RunRecord, reason, details string) error {
+	priority := o.Priority
+	if priority == "" {
+		priority = "P1"
+	}
+	message := fmt.Sprintf("matchspec: suite %q %s", rec.Suite, reason)
+	if len(message) > 130 {
+		message = message[:130]
+	}
+	return o.post(ctx, o.endpoint(), map[string]any{
+		"message":     message,
+		"alias":       incidentKey(rec),
+		"description": details,
+		"priority":    priority,
+		"source":      "matchspec",
+		"tags":        []string{"matchspec", rec.Suite},
+		"details":     map[string]string{"run_id": rec.ID, "model": rec.Model},
+	})
+}
+
+func (o *OpsgenieNotifier) endpoint() string {
+	if o.URL == "" {
+		return OpsgenieAlertsURL
+	}
+	return strings.TrimRight(o.URL, "/")
+}
+
+func (o *OpsgenieNotifier) post(ctx context.Context, endpoint string, v any) error {
+	return sendJSON(ctx, o.Client, endpoint, "opsgenie alert", http.Header{"Authorization": {"GenieKey " + o.APIKey}}, v)
+}
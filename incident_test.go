@@ -0,0 +1,119 @@
+package matchspec
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// incidentRequest is a request received by an incidentServer.
+type incidentRequest struct {
+	path, auth string
+	body       map[string]any
+}
+
+// incidentServer starts a server that records the requests made to it.
+func incidentServer(t *testing.T) (*httptest.Server, *[]incidentRequest) {
+	var reqs []incidentRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		reqs = append(reqs, incidentRequest{r.URL.RequestURI(), r.Header.Get("Authorization"), body})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &reqs
+}
+
+func TestIncidentReason(t *testing.T) {
+	for _, tc := range []struct {
+		rec  RunRecord
+		min  float64
+		want string
+	}{
+		{RunRecord{Summary: Summary{Total: 2, Passed: 2, PassRate: 1}}, 0, ""},
+		{RunRecord{Summary: Summary{Total: 2, Passed: 1, PassRate: 0.5}}, 0, "pass rate 50.0% is below 100.0%"},
+		{RunRecord{Summary: Summary{Total: 2, Passed: 1, PassRate: 0.5}}, 0.5, ""},
+		{RunRecord{Error: "backend down"}, 0.5, "run failed: backend down"},
+	} {
+		if got := incidentReason(tc.rec, tc.min); got != tc.want {
+			t.Errorf("incidentReason(%+v, %v) = %q, want %q", tc.rec.Summary, tc.min, got, tc.want)
+		}
+	}
+}
+
+func TestPagerDutyNotifier(t *testing.T) {
+	srv, reqs := incidentServer(t)
+	t.Setenv("MATCHSPEC_TEST_PD_KEY", "rk")
+	n, err := NewNotifier(NotifierConfig{Type: "pagerduty", URL: srv.URL, RoutingKeyEnv: "MATCHSPEC_TEST_PD_KEY"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failing := RunRecord{ID: "r1", Suite: "canary", Model: "m", Summary: Summary{Total: 2, Passed: 1, PassRate: 0.5}}
+	if err := n.RunCompleted(context.Background(), failing, nil); err != nil {
+		t.Fatal(err)
+	}
+	healthy := RunRecord{ID: "r2", Suite: "canary", Model: "m", Summary: Summary{Total: 2, Passed: 2, PassRate: 1}}
+	if err := n.RunCompleted(context.Background(), healthy, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(*reqs) != 2 {
+		t.Fatalf("requests = %+v", *reqs)
+	}
+	trigger, resolve := (*reqs)[0].body, (*reqs)[1].body
+	if trigger["event_action"] != "trigger" || trigger["routing_key"] != "rk" || trigger["dedup_key"] != "matchspec:canary:m" {
+		t.Errorf("trigger = %v", trigger)
+	}
+	payload, _ := trigger["payload"].(map[string]any)
+	if summary, _ := payload["summary"].(string); !strings.Contains(summary, "pass rate 50.0%") || payload["severity"] != "critical" {
+		t.Errorf("payload = %v", payload)
+	}
+	if resolve["event_action"] != "resolve" || resolve["dedup_key"] != "matchspec:canary:m" || resolve["payload"] != nil {
+		t.Errorf("resolve = %v", resolve)
+	}
+}
+
+func TestOpsgenieNotifier(t *testing.T) {
+	srv, reqs := incidentServer(t)
+	t.Setenv("MATCHSPEC_TEST_OG_KEY", "gk")
+	n, err := NewNotifier(NotifierConfig{Type: "opsgenie", URL: srv.URL, APIKeyEnv: "MATCHSPEC_TEST_OG_KEY", Suites: []string{"canary"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	n.RunCompleted(ctx, RunRecord{Suite: "nightly", Error: "boom"}, nil)
+	if err := n.RegressionDetected(ctx, RunRecord{ID: "r1", Suite: "canary"}, nil, []DriftAlert{{Suite: "canary", Metric: "pass_rate"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.RunCompleted(ctx, RunRecord{Suite: "canary", Summary: Summary{Total: 1, Passed: 1, PassRate: 1}}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(*reqs) != 2 {
+		t.Fatalf("requests = %+v", *reqs)
+	}
+	open, closed := (*reqs)[0], (*reqs)[1]
+	if open.path != "/" || open.auth != "GenieKey gk" || open.body["alias"] != "matchspec:canary" || open.body["priority"] != "P1" {
+		t.Errorf("open = %+v", open)
+	}
+	if desc, _ := open.body["description"].(string); !strings.Contains(desc, "## Regressions") {
+		t.Errorf("description = %q", desc)
+	}
+	if closed.path != "/matchspec:canary/close?identifierType=alias" || closed.auth != "GenieKey gk" {
+		t.Errorf("close = %+v", closed)
+	}
+}
+
+func TestIncidentNotifierKeys(t *testing.T) {
+	for _, cfg := range []NotifierConfig{
+		{Type: "pagerduty"},
+		{Type: "pagerduty", RoutingKeyEnv: "MATCHSPEC_TEST_UNSET_KEY"},
+		{Type: "opsgenie"},
+		{Type: "opsgenie", APIKeyEnv: "MATCHSPEC_TEST_UNSET_KEY"},
+	} {
+		if _, err := NewNotifier(cfg, nil); err == nil {
+			t.Errorf("NewNotifier(%+v) succeeded without a key", cfg)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package matchspec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/greynewell/mist-go/protocol"
+)
+
+// InferMuxFunc returns an InferFunc that sends each prompt as a single user
+// message to an InferMux server's POST /infer endpoint. The model and params
+// can be overridden per call with WithInferOptions, and headers added with
+// WithHeaders.
+func InferMuxFunc(baseURL, model string) InferFunc {
+	client, _ := TransportConfig{}.Client()
+	return InferMuxClientFunc(baseURL, model, client)
+}
+
+// InferMuxClientFunc is like InferMuxFunc but sends requests with client,
+// such as one built from a TransportConfig for the backend.
+func InferMuxClientFunc(baseURL, model string, client *http.Client) InferFunc {
+	endpoint := strings.TrimRight(baseURL, "/") + "/infer"
+	if model == "" {
+		model = "auto"
+	}
+
+	return func(ctx context.Context, prompt string) (string, error) {
+		req := protocol.InferRequest{
+			Model:    model,
+			Messages: []protocol.ChatMessage{{Role: "user", Content: prompt}},
+		}
+		if opts, ok := InferOptionsFrom(ctx); ok {
+			if opts.Model != "" {
+				req.Model = opts.Model
+			}
+			req.Params = opts.Params
+		}
+		out, err := postInfer(ctx, client, endpoint, req)
+		if err != nil {
+			return "", err
+		}
+		RecordTokens(ctx, out.TokensIn+out.TokensOut)
+		RecordBackend(ctx, out.Provider, out.Model)
+		return out.Content, nil
+	}
+}
+
+// postInfer sends req to an InferMux /infer endpoint with the headers
+// carried by ctx.
+func postInfer(ctx context.Context, client *http.Client, endpoint string, req protocol.InferRequest) (protocol.InferResponse, error) {
+	var out protocol.InferResponse
+	body, err := json.Marshal(req)
+	if err != nil {
+		return out, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return out, err
+	}
+	for name, v := range HeadersFrom(ctx) {
+		httpReq.Header.Set(name, v)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return out, fmt.Errorf("infermux: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return out, &BackendError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+			Message:    strings.TrimSpace(string(msg)),
+		}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return out, fmt.Errorf("infermux: decode response: %w", err)
+	}
+	return out, nil
+}
+
+// BackendError is an error response from an inference backend.
+type BackendError struct {
+	StatusCode int
+	// RetryAfter is the delay requested by the backend's Retry-After
+	// header, or zero.
+	RetryAfter time.Duration
+	Message    string
+}
+
+func (e *BackendError) Error() string {
+	return fmt.Sprintf("infermux: status %d: %s", e.StatusCode, e.Message)
+}
+
+// Temporary reports whether the request may succeed if retried: rate
+// limiting (429) and server errors (5xx).
+func (e *BackendError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// parseRetryAfter parses a Retry-After header in delay-seconds or
+// HTTP-date form. It returns zero if the header is absent or invalid.
+func parseRetryAfter(h string, now time.Time) time.Duration {
+	if h == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(h); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(h); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+type inferOptionsKey struct{}
+
+// InferOptions selects the model and sampling parameters for inference
+// calls made with a context. Inference functions that support them, such
+// as InferMuxFunc, read them with InferOptionsFrom.
+type InferOptions struct {
+	Model  string         `json:"model,omitempty"`
+	Params map[string]any `json:"params,omitempty"`
+}
+
+// WithInferOptions returns a context carrying opts.
+func WithInferOptions(ctx context.Context, opts InferOptions) context.Context {
+	return context.WithValue(ctx, inferOptionsKey{}, opts)
+}
+
+// InferOptionsFrom returns the options carried by ctx, if any.
+func InferOptionsFrom(ctx context.Context) (InferOptions, bool) {
+	opts, ok := ctx.Value(inferOptionsKey{}).(InferOptions)
+	return opts, ok
+}
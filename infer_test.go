@@ -0,0 +1,46 @@
+package matchspec
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+)
+
+func TestInferMuxFunc(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/infer" {
+			t.Errorf("path = %s, want /infer", r.URL.Path)
+		}
+		var req protocol.InferRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Model != "test-model" || len(req.Messages) != 1 {
+			t.Errorf("unexpected request: %+v", req)
+		}
+		json.NewEncoder(w).Encode(protocol.InferResponse{Content: "re: " + req.Messages[0].Content})
+	}))
+	defer srv.Close()
+
+	infer := InferMuxFunc(srv.URL, "test-model")
+	got, err := infer(context.Background(), "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "re: hi" {
+		t.Errorf("response = %q, want %q", got, "re: hi")
+	}
+}
+
+func TestInferMuxFuncStatusError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no provider", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	if _, err := InferMuxFunc(srv.URL, "")(context.Background(), "hi"); err == nil {
+		t.Error("expected error on 502")
+	}
+}
@@ -0,0 +1,233 @@
+package matchspec
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/greynewell/mist-go/trace"
+)
+
+// Cache outcomes recorded in Result.Cache.
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+// ResponseCache stores subject-model responses by ResponseCacheKey, so
+// prompts that have not changed are not sent again, as when iterating on
+// matchers against an expensive API. Only successful responses are stored.
+type ResponseCache interface {
+	Get(key string) (response string, ok bool, err error)
+	Put(key, response string) error
+}
+
+// ResponseCacheKey returns the cache key of prompt sent to backend with
+// opts and headers: a hash of the backend, the model, the parameters
+// (including any sampling seed), the headers, and the prompt. Header names
+// are compared case-insensitively.
+func ResponseCacheKey(backend string, opts InferOptions, headers map[string]string, prompt string) string {
+	canon := make(map[string]string, len(headers))
+	for name, v := range headers {
+		canon[strings.ToLower(name)] = v
+	}
+	data, _ := json.Marshal(struct {
+		Backend string            `json:"backend"`
+		Options InferOptions      `json:"options"`
+		Headers map[string]string `json:"headers,omitempty"`
+		Prompt  string            `json:"prompt"`
+	}{backend, opts, canon, prompt})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// WithResponseCache answers inference calls from c when it holds the
+// response to the same backend, model, parameters, headers, and prompt,
+// and stores new responses in it. backend identifies the runner's
+// inference backend, such as its URL, so a cache shared between backends
+// keeps their responses apart. Results record whether they were a cache
+// hit (see Result.Cache); hits take no time at the backend, so they are
+// left out of latency percentiles, SLOs, and duration metrics.
+func WithResponseCache(c ResponseCache, backend string) RunnerOption {
+	return func(r *Runner) { r.responseCache, r.responseBackend = c, backend }
+}
+
+// MemoryResponseCache is an in-process ResponseCache. It is safe for
+// concurrent use and grows without bound.
+type MemoryResponseCache struct {
+	mu        sync.RWMutex
+	responses map[string]string
+}
+
+// NewMemoryResponseCache returns an empty in-process response cache.
+func NewMemoryResponseCache() *MemoryResponseCache {
+	return &MemoryResponseCache{responses: make(map[string]string)}
+}
+
+// Get returns the cached response for key.
+func (c *MemoryResponseCache) Get(key string) (string, bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	v, ok := c.responses[key]
+	return v, ok, nil
+}
+
+// Put stores a response.
+func (c *MemoryResponseCache) Put(key, response string) error {
+	c.mu.Lock()
+	c.responses[key] = response
+	c.mu.Unlock()
+	return nil
+}
+
+// Len returns the number of cached responses.
+func (c *MemoryResponseCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.responses)
+}
+
+// Erase removes the cached responses that match pattern.
+func (c *MemoryResponseCache) Erase(pattern *regexp.Regexp) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for k, v := range c.responses {
+		if pattern.MatchString(v) {
+			delete(c.responses, k)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// DirResponseCache is a ResponseCache that keeps one JSON file per key in
+// a directory, so responses survive between runs.
+type DirResponseCache struct {
+	dir string
+	storeConfig
+}
+
+// NewDirResponseCache returns a cache in dir, which is created on first
+// Put.
+func NewDirResponseCache(dir string, opts ...StoreOption) *DirResponseCache {
+	return &DirResponseCache{dir: dir, storeConfig: newStoreConfig(opts)}
+}
+
+// cachedResponse is the file format of DirResponseCache.
+type cachedResponse struct {
+	Response string    `json:"response"`
+	StoredAt time.Time `json:"stored_at"`
+}
+
+func (c *DirResponseCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// Get returns the response stored under key.
+func (c *DirResponseCache) Get(key string) (string, bool, error) {
+	data, err := os.ReadFile(c.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("matchspec: response cache: %w", err)
+	}
+	if data, err = c.open(data, key); err != nil {
+		return "", false, fmt.Errorf("matchspec: response cache: %w", err)
+	}
+	var cr cachedResponse
+	if err := json.Unmarshal(data, &cr); err != nil {
+		return "", false, fmt.Errorf("matchspec: response cache %s: %w", c.path(key), err)
+	}
+	return cr.Response, true, nil
+}
+
+// Put stores response under key, replacing any previous entry.
+func (c *DirResponseCache) Put(key, response string) error {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return fmt.Errorf("matchspec: response cache: %w", err)
+	}
+	data, err := json.Marshal(cachedResponse{Response: response, StoredAt: time.Now().UTC()})
+	if err == nil {
+		data, err = c.seal(data, key)
+	}
+	if err != nil {
+		return fmt.Errorf("matchspec: response cache: %w", err)
+	}
+	f, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("matchspec: response cache: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("matchspec: response cache: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("matchspec: response cache: %w", err)
+	}
+	if err := os.Rename(f.Name(), c.path(key)); err != nil {
+		return fmt.Errorf("matchspec: response cache: %w", err)
+	}
+	return nil
+}
+
+// Erase removes the cached responses that match pattern. Prompts are only
+// stored as part of the key hash, so they cannot be matched.
+func (c *DirResponseCache) Erase(pattern *regexp.Regexp) (int, error) {
+	files, err := filepath.Glob(filepath.Join(c.dir, "*.json"))
+	if err != nil {
+		return 0, fmt.Errorf("matchspec: response cache: %w", err)
+	}
+	n := 0
+	for _, file := range files {
+		resp, ok, err := c.Get(strings.TrimSuffix(filepath.Base(file), ".json"))
+		if err != nil {
+			return n, err
+		}
+		if !ok || !pattern.MatchString(resp) {
+			continue
+		}
+		if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return n, fmt.Errorf("matchspec: response cache: %w", err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// inferCached is inferWithRetry answered from the runner's response cache
+// when possible. It returns the cache outcome, "" without a cache. Cache
+// errors are recorded on span and otherwise treated as misses.
+func (r *Runner) inferCached(ctx context.Context, span *trace.Span, task *Task, prompt string) (response string, attempts int, timedOut bool, cache string, err error) {
+	if r.responseCache == nil {
+		response, attempts, timedOut, err = r.inferWithRetry(ctx, task, prompt)
+		return response, attempts, timedOut, "", err
+	}
+	opts, _ := InferOptionsFrom(ctx)
+	key := repeatCacheKey(ResponseCacheKey(r.responseBackend, opts, HeadersFrom(ctx), prompt), task.repeat)
+	if cached, ok, cerr := r.responseCache.Get(key); cerr != nil {
+		span.SetAttr("cache_error", cerr.Error())
+	} else if ok {
+		span.SetAttr("cache", CacheHit)
+		return cached, 0, false, CacheHit, nil
+	}
+	span.SetAttr("cache", CacheMiss)
+	response, attempts, timedOut, err = r.inferWithRetry(ctx, task, prompt)
+	if err == nil {
+		if cerr := r.responseCache.Put(key, response); cerr != nil {
+			span.SetAttr("cache_error", cerr.Error())
+		}
+	}
+	return response, attempts, timedOut, CacheMiss, err
+}
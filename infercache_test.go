@@ -0,0 +1,110 @@
+package matchspec
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestResponseCache(t *testing.T) {
+	for name, cache := range map[string]ResponseCache{
+		"memory": NewMemoryResponseCache(),
+		"dir":    NewDirResponseCache(filepath.Join(t.TempDir(), "responses")),
+	} {
+		t.Run(name, func(t *testing.T) {
+			var calls atomic.Int64
+			infer := func(ctx context.Context, prompt string) (string, error) {
+				calls.Add(1)
+				if prompt == "What is 3*4?" {
+					return "", errors.New("backend down")
+				}
+				return "4", nil
+			}
+			runner := NewRunner(driftRegistry(), infer, tokentrace.NewReporter("matchspec", ""), WithResponseCache(cache, ""))
+			run := protocol.EvalRun{Suite: "math", Tags: map[string]string{"model": "m"}}
+
+			results, _ := runner.RunDetailed(context.Background(), run)
+			if s := Summarize(results); s.CacheHits != 0 || s.CacheMisses != 2 {
+				t.Errorf("first run: %d hits, %d misses", s.CacheHits, s.CacheMisses)
+			}
+			results, _ = runner.RunDetailed(context.Background(), run)
+			s := Summarize(results)
+			// The failed call was not cached, so it is retried.
+			if s.CacheHits != 1 || s.CacheMisses != 1 || calls.Load() != 3 {
+				t.Errorf("second run: %d hits, %d misses, %d calls", s.CacheHits, s.CacheMisses, calls.Load())
+			}
+			for _, res := range results {
+				if res.Task == "add" && (res.Cache != CacheHit || !res.Passed || res.Attempts != 0) {
+					t.Errorf("cached result = %+v", res)
+				}
+			}
+
+			// Another model misses.
+			runner.Run(context.Background(), protocol.EvalRun{Suite: "math", Tasks: []string{"add"}, Tags: map[string]string{"model": "other"}})
+			if calls.Load() != 4 {
+				t.Errorf("calls after model change = %d", calls.Load())
+			}
+		})
+	}
+}
+
+func TestResponseCacheKey(t *testing.T) {
+	opts := InferOptions{Model: "m", Params: map[string]any{"temperature": 0, "seed": 1}}
+	base := ResponseCacheKey("http://a", opts, map[string]string{"X-Tenant": "t1"}, "p")
+	if base != ResponseCacheKey("http://a", InferOptions{Model: "m", Params: map[string]any{"seed": 1, "temperature": 0}}, map[string]string{"x-tenant": "t1"}, "p") {
+		t.Error("key depends on parameter order or header case")
+	}
+	for _, other := range []string{
+		ResponseCacheKey("http://a", InferOptions{Model: "m2", Params: map[string]any{"temperature": 0, "seed": 1}}, map[string]string{"X-Tenant": "t1"}, "p"),
+		ResponseCacheKey("http://a", InferOptions{Model: "m", Params: map[string]any{"temperature": 0, "seed": 2}}, map[string]string{"X-Tenant": "t1"}, "p"),
+		ResponseCacheKey("http://a", opts, map[string]string{"X-Tenant": "t1"}, "q"),
+		ResponseCacheKey("http://b", opts, map[string]string{"X-Tenant": "t1"}, "p"),
+		ResponseCacheKey("http://a", opts, map[string]string{"X-Tenant": "t2"}, "p"),
+		ResponseCacheKey("http://a", opts, nil, "p"),
+	} {
+		if other == base {
+			t.Error("different inputs share a key")
+		}
+	}
+}
+
+func TestResponseCacheHitsLeaveLatency(t *testing.T) {
+	results := []Result{
+		{EvalResult: protocol.EvalResult{Task: "slow", DurationMS: 400}, Cache: CacheMiss},
+		{EvalResult: protocol.EvalResult{Task: "fast", DurationMS: 0}, Cache: CacheHit},
+		{EvalResult: protocol.EvalResult{Task: "fast", DurationMS: 0}, Cache: CacheHit},
+	}
+	if s := Summarize(results); s.Latency.P50 != 400 || s.CacheHits != 2 {
+		t.Errorf("summary latency = %+v, hits = %d", s.Latency, s.CacheHits)
+	}
+	suite := Suite{
+		Name:        "s",
+		Tasks:       []Task{{Name: "fast", MaxLatencyMS: 100}},
+		LatencySLOs: []LatencySLO{{Percentile: 50, MaxMS: 300}},
+	}
+	checks := suite.CheckLatency(results)
+	if len(checks) != 1 || checks[0].Passed || checks[0].ActualMS != 400 {
+		t.Errorf("checks = %+v", checks)
+	}
+	if checks := suite.CheckLatency(results[1:]); len(checks) != 0 {
+		t.Errorf("checks of cache hits = %+v", checks)
+	}
+}
+
+func TestDirResponseCacheCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	c := NewDirResponseCache(dir)
+	os.WriteFile(filepath.Join(dir, "k.json"), []byte("{"), 0o644)
+	if _, _, err := c.Get("k"); err == nil {
+		t.Error("expected error for corrupt entry")
+	}
+	if _, ok, err := c.Get("missing"); ok || err != nil {
+		t.Errorf("Get(missing) = %v, %v", ok, err)
+	}
+}
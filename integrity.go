@@ -0,0 +1,122 @@
+package matchspec
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+)
+
+// WithSigningKey makes the runner sign every run's content hash with
+// HMAC-SHA256 using key. Unsigned runs still carry the hash.
+func WithSigningKey(key []byte) RunnerOption {
+	return func(r *Runner) { r.signingKey = key }
+}
+
+// HashResults returns the hex SHA-256 of the canonical JSON encoding of
+// results, in order, or "" if a result cannot be encoded, such as one
+// holding a NaN in its metadata.
+func HashResults(results []Result) string {
+	hash, _, _ := digestResults(nil, results)
+	return hash
+}
+
+// SignResults returns the hex HMAC-SHA256 of the canonical encoding of
+// results under key, or "" if a result cannot be encoded.
+func SignResults(key []byte, results []Result) string {
+	_, sig, _ := digestResults(key, results)
+	return sig
+}
+
+// VerifyResults reports whether results match the hash and, if signature is
+// non-empty, the HMAC signature under key.
+func VerifyResults(results []Result, hash, signature string, key []byte) bool {
+	gotHash, gotSig, err := digestResults(key, results)
+	if err != nil || gotHash != hash {
+		return false
+	}
+	if signature == "" {
+		return true
+	}
+	want, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	got, _ := hex.DecodeString(gotSig)
+	return hmac.Equal(got, want)
+}
+
+func digestResults(key []byte, results []Result) (hash, sig string, err error) {
+	d := newResultDigest(key)
+	for _, r := range results {
+		if err := d.add(r); err != nil {
+			return "", "", err
+		}
+	}
+	return d.sums()
+}
+
+// resultDigest hashes the canonical encoding of a result sequence
+// incrementally: the JSON array of the results, in order. It produces the
+// same hash as encoding the whole slice at once.
+type resultDigest struct {
+	hash hash.Hash
+	mac  hash.Hash // nil without a key
+	n    int
+	err  error // the first result that could not be encoded
+}
+
+func newResultDigest(key []byte) *resultDigest {
+	d := &resultDigest{hash: sha256.New()}
+	if len(key) > 0 {
+		d.mac = hmac.New(sha256.New, key)
+	}
+	return d
+}
+
+func (d *resultDigest) write(p []byte) {
+	d.hash.Write(p)
+	if d.mac != nil {
+		d.mac.Write(p)
+	}
+}
+
+// add appends r to the digest. A result that cannot be encoded, such as
+// one whose metadata holds a NaN, spoils the digest: add and sums return
+// the error.
+func (d *resultDigest) add(r Result) error {
+	if d.err != nil {
+		return d.err
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		d.err = fmt.Errorf("matchspec: hash result of task %q: %w", r.Task, err)
+		return d.err
+	}
+	if d.n == 0 {
+		d.write([]byte("["))
+	} else {
+		d.write([]byte(","))
+	}
+	d.write(data)
+	d.n++
+	return nil
+}
+
+// sums returns the hex hash and, if the digest has a key, the hex HMAC.
+func (d *resultDigest) sums() (hash, sig string, err error) {
+	if d.err != nil {
+		return "", "", d.err
+	}
+	if d.n == 0 {
+		d.write([]byte("["))
+	}
+	d.write([]byte("]"))
+	hash = hex.EncodeToString(d.hash.Sum(nil))
+	if d.mac != nil {
+		sig = hex.EncodeToString(d.mac.Sum(nil))
+	}
+	return hash, sig, nil
+}
@@ -0,0 +1,81 @@
+package matchspec
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestHashAndVerifyResults(t *testing.T) {
+	results := []Result{{EvalResult: protocol.EvalResult{Suite: "math", Task: "add", Passed: true, Score: 1}}}
+	key := []byte("secret")
+
+	hash := HashResults(results)
+	sig := SignResults(key, results)
+	if !VerifyResults(results, hash, sig, key) {
+		t.Fatal("untampered results should verify")
+	}
+	if !VerifyResults(results, hash, "", nil) {
+		t.Error("hash-only verification should pass")
+	}
+
+	tampered := []Result{{EvalResult: protocol.EvalResult{Suite: "math", Task: "add", Passed: true, Score: 0.9}}}
+	if VerifyResults(tampered, hash, sig, key) {
+		t.Error("tampered results should not verify")
+	}
+	if VerifyResults(results, hash, sig, []byte("wrong")) {
+		t.Error("wrong key should not verify")
+	}
+}
+
+func TestRunnerSignsRuns(t *testing.T) {
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "math", Tasks: []Task{{Name: "add", Prompt: "1+1", Expected: "echo"}}})
+	key := []byte("k")
+	runner := NewRunner(reg, echoInfer, tokentrace.NewReporter("matchspec", ""), WithSigningKey(key))
+
+	results, _ := runner.RunDetailed(context.Background(), protocol.EvalRun{Suite: "math"})
+	runs, _ := runner.Runs(RunFilter{})
+	if len(runs) != 1 || runs[0].Hash == "" || runs[0].Signature == "" {
+		t.Fatalf("expected hashed and signed run, got %+v", runs)
+	}
+	if !VerifyResults(results, runs[0].Hash, runs[0].Signature, key) {
+		t.Error("run signature should verify")
+	}
+}
+
+func TestNonFiniteResultsDoNotPanic(t *testing.T) {
+	nan := []Result{{EvalResult: protocol.EvalResult{Task: "add"}, Metadata: map[string]any{"x": math.NaN()}}}
+	if HashResults(nan) != "" || VerifyResults(nan, "", "", nil) {
+		t.Error("unencodable results hashed")
+	}
+
+	reg := NewSuiteRegistry()
+	if err := reg.Register(&Suite{Name: "bad", Tasks: []Task{{Name: "add", Prompt: "1+1", Expected: "2", Metadata: map[string]any{"w": math.Inf(1)}}}}); err == nil {
+		t.Error("registered a task with infinite metadata")
+	}
+
+	RegisterMatcher("test-nan", MatcherFunc(func(Task, string) (bool, float64) { return true, math.NaN() }))
+	reg.Register(&Suite{Name: "math", Tasks: []Task{
+		{Name: "nan-score", Prompt: "1+1", Expected: "x", Matcher: "test-nan"},
+		// Validation sees only decoded JSON values; a Go slice of
+		// floats reaches the run.
+		{Name: "embedding", Prompt: "2+2", Expected: "echo", Metadata: map[string]any{"v": []float64{math.NaN()}}},
+	}})
+	runner := NewRunner(reg, echoInfer, tokentrace.NewReporter("matchspec", ""))
+	results, err := runner.Run(context.Background(), protocol.EvalRun{Suite: "math"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Passed || !strings.Contains(results[0].Error, "score NaN") {
+		t.Errorf("NaN score result = %+v", results[0])
+	}
+	runs, _ := runner.Runs(RunFilter{})
+	if len(runs) != 1 || runs[0].Hash != "" || !strings.Contains(runs[0].Error, `hash result of task "embedding"`) {
+		t.Errorf("run = %+v", runs)
+	}
+}
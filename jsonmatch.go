@@ -0,0 +1,313 @@
+package matchspec
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var jsonFencePattern = regexp.MustCompile("(?is)```(?:json)?\\s*\\n(.*?)```")
+
+// ExtractJSON returns the JSON document in a response: the first fenced
+// code block if there is one, otherwise the whole response, or the text
+// from its first '{' or '[' to its last '}' or ']' if the whole response
+// is not valid JSON.
+func ExtractJSON(response string) string {
+	if m := jsonFencePattern.FindStringSubmatch(response); m != nil {
+		return strings.TrimSpace(m[1])
+	}
+	s := strings.TrimSpace(response)
+	if json.Valid([]byte(s)) {
+		return s
+	}
+	start := strings.IndexAny(s, "{[")
+	end := strings.LastIndexAny(s, "}]")
+	if start < 0 || end < start {
+		return s
+	}
+	return s[start : end+1]
+}
+
+// matchJSON parses Expected and the JSON in the response and compares them
+// structurally, so key order, whitespace, and number formatting do not
+// matter. The score is 1 or 0. Its details hold the parse error, or the
+// paths at which the documents differ.
+func matchJSON(t *Task, response string) (bool, float64, map[string]any) {
+	var want, got any
+	if err := json.Unmarshal([]byte(t.Expected), &want); err != nil {
+		return false, 0.0, map[string]any{"error": "expected: " + err.Error()}
+	}
+	if err := json.Unmarshal([]byte(ExtractJSON(response)), &got); err != nil {
+		return false, 0.0, map[string]any{"error": "response: " + err.Error()}
+	}
+	if reflect.DeepEqual(want, got) {
+		return true, 1.0, nil
+	}
+	return false, 0.0, map[string]any{"mismatches": jsonMismatches("$", want, got, nil)}
+}
+
+// maxJSONMismatches bounds the mismatches matchJSON reports.
+const maxJSONMismatches = 20
+
+// jsonMismatches appends a description of each path at which got differs
+// from want, such as "$.total: want 42, got 41" or "$.id: missing", up to
+// maxJSONMismatches.
+func jsonMismatches(path string, want, got any, out []string) []string {
+	if len(out) >= maxJSONMismatches {
+		return out
+	}
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			break
+		}
+		for _, k := range slices.Sorted(maps.Keys(w)) {
+			p := path + "." + k
+			if gv, ok := g[k]; ok {
+				out = jsonMismatches(p, w[k], gv, out)
+			} else if len(out) < maxJSONMismatches {
+				out = append(out, p+": missing")
+			}
+		}
+		for _, k := range slices.Sorted(maps.Keys(g)) {
+			if _, ok := w[k]; !ok && len(out) < maxJSONMismatches {
+				out = append(out, path+"."+k+": unexpected")
+			}
+		}
+		return out
+	case []any:
+		g, ok := got.([]any)
+		if !ok {
+			break
+		}
+		if len(w) != len(g) {
+			return append(out, fmt.Sprintf("%s: want %d elements, got %d", path, len(w), len(g)))
+		}
+		for i := range w {
+			out = jsonMismatches(fmt.Sprintf("%s[%d]", path, i), w[i], g[i], out)
+		}
+		return out
+	}
+	if reflect.DeepEqual(want, got) {
+		return out
+	}
+	wb, _ := json.Marshal(want)
+	gb, _ := json.Marshal(got)
+	return append(out, fmt.Sprintf("%s: want %s, got %s", path, wb, gb))
+}
+
+// matchJSONPath evaluates the task's JSONPath against the JSON in the
+// response and compares the selected value with Expected. Expected is
+// parsed as JSON if it can be, and is otherwise a string, so "Paris"
+// matches the JSON string "Paris". A path with a wildcard or recursive
+// descent selects an array of every match. A path that selects nothing
+// fails. The score is 1 or 0.
+func matchJSONPath(t *Task, response string) (bool, float64) {
+	path, err := ParseJSONPath(t.JSONPath)
+	if err != nil {
+		return false, 0.0
+	}
+	var doc any
+	if err := json.Unmarshal([]byte(ExtractJSON(response)), &doc); err != nil {
+		return false, 0.0
+	}
+	got, ok := path.Select(doc)
+	if !ok {
+		return false, 0.0
+	}
+	var want any
+	if err := json.Unmarshal([]byte(t.Expected), &want); err != nil {
+		want = t.Expected
+	}
+	return boolScore(reflect.DeepEqual(want, got))
+}
+
+// validateJSON checks the JSON a "json" or "jsonpath" task needs.
+// Templated values are checked when rendered.
+func validateJSON(suite string, t Task) error {
+	if t.Matcher == "jsonpath" {
+		if _, err := ParseJSONPath(t.JSONPath); err != nil {
+			return fmt.Errorf("matchspec: suite %q task %q: %w", suite, t.Name, err)
+		}
+		return nil
+	}
+	if strings.Contains(t.Expected, "{{") {
+		return nil
+	}
+	if !json.Valid([]byte(t.Expected)) {
+		return fmt.Errorf("matchspec: suite %q task %q expected is not valid JSON", suite, t.Name)
+	}
+	return nil
+}
+
+// JSONPath is a parsed JSONPath expression. It supports the root "$",
+// child names (".name", "['name']"), array indexes ("[0]", negative from
+// the end), wildcards (".*", "[*]"), and recursive descent ("..name",
+// "..*"). Filters, slices, and unions are not supported.
+type JSONPath struct {
+	steps []jsonPathStep
+}
+
+type jsonPathStep struct {
+	descend  bool
+	wildcard bool
+	key      string
+	index    int
+	isIndex  bool
+}
+
+// ParseJSONPath parses a JSONPath expression.
+func ParseJSONPath(expr string) (*JSONPath, error) {
+	p := strings.TrimSpace(expr)
+	if !strings.HasPrefix(p, "$") {
+		return nil, fmt.Errorf("matchspec: jsonpath %q must start with $", expr)
+	}
+	var steps []jsonPathStep
+	for i := 1; i < len(p); {
+		var step jsonPathStep
+		switch {
+		case strings.HasPrefix(p[i:], ".."):
+			step.descend = true
+			i += 2
+		case p[i] == '.':
+			i++
+		case p[i] == '[':
+		default:
+			return nil, fmt.Errorf("matchspec: jsonpath %q: unexpected %q at offset %d", expr, p[i], i)
+		}
+		switch {
+		case i < len(p) && p[i] == '[':
+			end, err := parseJSONPathBracket(p[i:], &step)
+			if err != nil {
+				return nil, fmt.Errorf("matchspec: jsonpath %q: %w", expr, err)
+			}
+			i += end
+		case i < len(p) && p[i] == '*':
+			step.wildcard = true
+			i++
+		default:
+			n := strings.IndexAny(p[i:], ".[")
+			if n < 0 {
+				n = len(p) - i
+			}
+			if n == 0 {
+				return nil, fmt.Errorf("matchspec: jsonpath %q: empty name at offset %d", expr, i)
+			}
+			step.key = p[i : i+n]
+			i += n
+		}
+		steps = append(steps, step)
+	}
+	return &JSONPath{steps: steps}, nil
+}
+
+// parseJSONPathBracket parses the bracketed selector at the start of s
+// into step and returns its length.
+func parseJSONPathBracket(s string, step *jsonPathStep) (int, error) {
+	if len(s) > 1 && (s[1] == '\'' || s[1] == '"') {
+		end := strings.IndexByte(s[2:], s[1])
+		if end < 0 || 2+end+1 >= len(s) || s[2+end+1] != ']' {
+			return 0, fmt.Errorf("unterminated name in %q", s)
+		}
+		step.key = s[2 : 2+end]
+		return 2 + end + 2, nil
+	}
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return 0, fmt.Errorf("unterminated bracket in %q", s)
+	}
+	inner := strings.TrimSpace(s[1:end])
+	if inner == "*" {
+		step.wildcard = true
+		return end + 1, nil
+	}
+	n, err := strconv.Atoi(inner)
+	if err != nil {
+		return 0, fmt.Errorf("unsupported selector [%s]", inner)
+	}
+	step.index, step.isIndex = n, true
+	return end + 1, nil
+}
+
+// Select evaluates the path against a decoded JSON document. A path
+// without wildcards or recursive descent selects a single value; others
+// select an array of every match, in document order with object keys
+// sorted. It reports false if nothing matches.
+func (p *JSONPath) Select(doc any) (any, bool) {
+	nodes := []any{doc}
+	definite := true
+	for _, step := range p.steps {
+		if step.descend || step.wildcard {
+			definite = false
+		}
+		var next []any
+		for _, n := range nodes {
+			if !step.descend {
+				next = step.selectFrom(n, next)
+				continue
+			}
+			for _, d := range jsonDescendants(n, nil) {
+				next = step.selectFrom(d, next)
+			}
+		}
+		nodes = next
+	}
+	if len(nodes) == 0 {
+		return nil, false
+	}
+	if definite {
+		return nodes[0], true
+	}
+	return nodes, true
+}
+
+// selectFrom appends the children of v that step selects to out.
+func (step jsonPathStep) selectFrom(v any, out []any) []any {
+	switch v := v.(type) {
+	case map[string]any:
+		if step.wildcard {
+			for _, k := range slices.Sorted(maps.Keys(v)) {
+				out = append(out, v[k])
+			}
+		} else if c, ok := v[step.key]; ok && !step.isIndex {
+			out = append(out, c)
+		}
+	case []any:
+		if step.wildcard {
+			return append(out, v...)
+		}
+		if step.isIndex {
+			i := step.index
+			if i < 0 {
+				i += len(v)
+			}
+			if i >= 0 && i < len(v) {
+				out = append(out, v[i])
+			}
+		}
+	}
+	return out
+}
+
+// jsonDescendants appends v and everything beneath it to out, in document
+// order with object keys sorted.
+func jsonDescendants(v any, out []any) []any {
+	out = append(out, v)
+	switch v := v.(type) {
+	case map[string]any:
+		for _, k := range slices.Sorted(maps.Keys(v)) {
+			out = jsonDescendants(v[k], out)
+		}
+	case []any:
+		for _, c := range v {
+			out = jsonDescendants(c, out)
+		}
+	}
+	return out
+}
@@ -0,0 +1,94 @@
+package matchspec
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractJSON(t *testing.T) {
+	tests := []struct{ response, want string }{
+		{`{"a": 1}`, `{"a": 1}`},
+		{"Here you go:\n```json\n{\"a\": 1}\n```\nDone.", `{"a": 1}`},
+		{"```\n[1, 2]\n```", `[1, 2]`},
+		{`The answer is {"a": [1]} as requested.`, `{"a": [1]}`},
+		{"no json", "no json"},
+	}
+	for _, tt := range tests {
+		if got := ExtractJSON(tt.response); got != tt.want {
+			t.Errorf("ExtractJSON(%q) = %q, want %q", tt.response, got, tt.want)
+		}
+	}
+}
+
+func TestTaskMatchJSON(t *testing.T) {
+	task := Task{Matcher: "json", Expected: `{"name": "Ada", "langs": ["en", "fr"], "age": 36}`}
+	tests := []struct {
+		response string
+		want     bool
+	}{
+		{"```json\n{\"age\": 36.0,\n \"langs\": [\"en\", \"fr\"], \"name\": \"Ada\"}\n```", true},
+		{`{"name": "Ada", "langs": ["fr", "en"], "age": 36}`, false},
+		{`{"name": "Ada", "langs": ["en", "fr"], "age": 36, "extra": true}`, false},
+		{`{"name": "Ada"`, false},
+	}
+	for _, tt := range tests {
+		if passed, _ := task.Match(tt.response); passed != tt.want {
+			t.Errorf("Match(%q) = %v, want %v", tt.response, passed, tt.want)
+		}
+	}
+}
+
+func TestTaskMatchJSONPath(t *testing.T) {
+	response := "```json\n" + `{"address": {"city": "Paris", "zip": "75001"},
+		"items": [{"id": 1, "tags": ["a"]}, {"id": 2, "tags": ["b", "c"]}]}` + "\n```"
+	tests := []struct {
+		path, expected string
+		want           bool
+	}{
+		{"$.address.city", "Paris", true},
+		{"$['address']['zip']", `"75001"`, true},
+		{"$.items[1].id", "2", true},
+		{"$.items[-1].tags", `["b", "c"]`, true},
+		{"$.items[*].id", "[1, 2]", true},
+		{"$..tags[0]", `["a", "b"]`, true},
+		{"$.address.*", `["Paris", "75001"]`, true},
+		{"$.address.city", "London", false},
+		{"$.missing", "null", false},
+		{"$.items[5]", "1", false},
+	}
+	for _, tt := range tests {
+		task := Task{Matcher: "jsonpath", JSONPath: tt.path, Expected: tt.expected}
+		if passed, _ := task.Match(response); passed != tt.want {
+			t.Errorf("%s = %q: passed %v, want %v", tt.path, tt.expected, passed, tt.want)
+		}
+	}
+}
+
+func TestParseJSONPath(t *testing.T) {
+	for _, bad := range []string{"", "items", "$.", "$[", "$['a'", "$[1:2]", "$.a[?(@.b)]"} {
+		if _, err := ParseJSONPath(bad); err == nil {
+			t.Errorf("ParseJSONPath(%q): expected error", bad)
+		}
+	}
+	p, err := ParseJSONPath("$")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := map[string]any{"a": 1.0}
+	if got, ok := p.Select(doc); !ok || !reflect.DeepEqual(got, doc) {
+		t.Errorf("$ selected %v, %v", got, ok)
+	}
+}
+
+func TestValidateJSONTasks(t *testing.T) {
+	bad := []Task{
+		{Name: "t", Prompt: "p", Matcher: "json", Expected: "{not json"},
+		{Name: "t", Prompt: "p", Matcher: "jsonpath", Expected: "x"},
+		{Name: "t", Prompt: "p", Matcher: "jsonpath", JSONPath: "a.b", Expected: "x"},
+	}
+	for _, task := range bad {
+		if err := (&Suite{Name: "s", Tasks: []Task{task}}).Validate(); err == nil {
+			t.Errorf("task %+v: expected validation error", task)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package matchspec
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"sync"
+	"sync/atomic"
+)
+
+// JudgeCache stores judge-model verdicts by prompt. It is kept apart from
+// any cache of subject-model responses: a judge verdict depends only on the
+// judge prompt, so it stays valid when the subject model changes.
+type JudgeCache interface {
+	Get(key string) (verdict string, ok bool)
+	Put(key, verdict string)
+}
+
+// MemoryJudgeCache is an in-process JudgeCache. It is safe for concurrent
+// use and grows without bound.
+type MemoryJudgeCache struct {
+	mu       sync.RWMutex
+	verdicts map[string]string
+}
+
+// NewMemoryJudgeCache returns an empty in-process judge cache.
+func NewMemoryJudgeCache() *MemoryJudgeCache {
+	return &MemoryJudgeCache{verdicts: make(map[string]string)}
+}
+
+// Get returns the cached verdict for key.
+func (c *MemoryJudgeCache) Get(key string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	v, ok := c.verdicts[key]
+	return v, ok
+}
+
+// Put stores a verdict.
+func (c *MemoryJudgeCache) Put(key, verdict string) {
+	c.mu.Lock()
+	c.verdicts[key] = verdict
+	c.mu.Unlock()
+}
+
+// Len returns the number of cached verdicts.
+func (c *MemoryJudgeCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.verdicts)
+}
+
+// Erase removes the cached verdicts that match pattern.
+func (c *MemoryJudgeCache) Erase(pattern *regexp.Regexp) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for k, v := range c.verdicts {
+		if pattern.MatchString(v) {
+			delete(c.verdicts, k)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// WithJudgeCache reuses judge verdicts for identical judge prompts.
+func WithJudgeCache(c JudgeCache) RunnerOption {
+	return func(r *Runner) { r.judgeCache = c }
+}
+
+// WithTokenPrices sets the price per million tokens of the subject model
+// and of the judge model, used to report cost in run summaries.
+func WithTokenPrices(subjectPerMillion, judgePerMillion float64) RunnerOption {
+	return func(r *Runner) {
+		r.subjectPrice = subjectPerMillion
+		r.judgePrice = judgePerMillion
+	}
+}
+
+// TokenUsage attributes a run's token spend to the subject model under
+// evaluation and to the judge model grading it. Tokens are counted from
+// RecordTokens calls, which InferMuxFunc makes automatically.
+type TokenUsage struct {
+	SubjectTokens  int64   `json:"subject_tokens"`
+	JudgeTokens    int64   `json:"judge_tokens"`
+	JudgeCalls     int64   `json:"judge_calls"`
+	JudgeCacheHits int64   `json:"judge_cache_hits"`
+	SubjectCost    float64 `json:"subject_cost,omitempty"`
+	JudgeCost      float64 `json:"judge_cost,omitempty"`
+}
+
+type runUsageKey struct{}
+
+type judgeRoleKey struct{}
+
+// runUsage accumulates token counts for one run.
+type runUsage struct {
+	subjectTokens  atomic.Int64
+	judgeTokens    atomic.Int64
+	judgeCalls     atomic.Int64
+	judgeCacheHits atomic.Int64
+	backends       backendSet
+}
+
+func withRunUsage(ctx context.Context) (context.Context, *runUsage) {
+	u := new(runUsage)
+	return context.WithValue(ctx, runUsageKey{}, u), u
+}
+
+// add carries usage recorded elsewhere, such as by the instance that
+// started a resumed run, into u. A nil t adds nothing.
+func (u *runUsage) add(t *TokenUsage) {
+	if t == nil {
+		return
+	}
+	u.subjectTokens.Add(t.SubjectTokens)
+	u.judgeTokens.Add(t.JudgeTokens)
+	u.judgeCalls.Add(t.JudgeCalls)
+	u.judgeCacheHits.Add(t.JudgeCacheHits)
+}
+
+// recordRunTokens charges n tokens to the run that ctx belongs to, as judge
+// tokens if ctx is a judge call.
+func recordRunTokens(ctx context.Context, n int64) {
+	u, ok := ctx.Value(runUsageKey{}).(*runUsage)
+	if !ok {
+		return
+	}
+	if ctx.Value(judgeRoleKey{}) != nil {
+		u.judgeTokens.Add(n)
+	} else {
+		u.subjectTokens.Add(n)
+	}
+}
+
+// tokenUsage snapshots u, pricing it with the runner's token prices. It
+// returns nil for a nil u.
+func (r *Runner) tokenUsage(u *runUsage) *TokenUsage {
+	if u == nil {
+		return nil
+	}
+	t := &TokenUsage{
+		SubjectTokens:  u.subjectTokens.Load(),
+		JudgeTokens:    u.judgeTokens.Load(),
+		JudgeCalls:     u.judgeCalls.Load(),
+		JudgeCacheHits: u.judgeCacheHits.Load(),
+	}
+	t.SubjectCost = float64(t.SubjectTokens) * r.subjectPrice / 1e6
+	t.JudgeCost = float64(t.JudgeTokens) * r.judgePrice / 1e6
+	return t
+}
+
+// judgeInfer returns the judge wrapped with caching and usage attribution,
+// or nil if the runner has no judge. Judge calls do not inherit the subject
+// model's InferOptions or request headers.
+func (r *Runner) judgeInfer() InferFunc {
+	if r.judge == nil {
+		return nil
+	}
+	return func(ctx context.Context, prompt string) (string, error) {
+		ctx = context.WithValue(WithInferOptions(withoutHeaders(ctx), InferOptions{}), judgeRoleKey{}, true)
+		u, _ := ctx.Value(runUsageKey{}).(*runUsage)
+		if u != nil {
+			u.judgeCalls.Add(1)
+		}
+
+		var key string
+		if r.judgeCache != nil {
+			sum := sha256.Sum256([]byte(prompt))
+			key = hex.EncodeToString(sum[:])
+			if v, ok := r.judgeCache.Get(key); ok {
+				if u != nil {
+					u.judgeCacheHits.Add(1)
+				}
+				return v, nil
+			}
+		}
+
+		verdict, err := r.judge(ctx, prompt)
+		if err != nil {
+			return "", err
+		}
+		if r.judgeCache != nil {
+			r.judgeCache.Put(key, verdict)
+		}
+		return verdict, nil
+	}
+}
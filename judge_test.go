@@ -0,0 +1,68 @@
+package matchspec
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestJudgeCacheAndUsage(t *testing.T) {
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{
+		Name: "rag",
+		Tasks: []Task{
+			{Name: "a", Prompt: "q", Documents: []string{"doc"}, Matcher: "grounded"},
+			{Name: "b", Prompt: "q", Documents: []string{"doc"}, Matcher: "grounded"},
+		},
+	})
+	infer := func(ctx context.Context, prompt string) (string, error) {
+		RecordTokens(ctx, 10)
+		return "answer", nil
+	}
+	var judgeCalls atomic.Int64
+	judge := func(ctx context.Context, prompt string) (string, error) {
+		if opts, _ := InferOptionsFrom(ctx); opts.Model != "" {
+			t.Errorf("judge inherited subject model %q", opts.Model)
+		}
+		judgeCalls.Add(1)
+		RecordTokens(ctx, 100)
+		return "YES", nil
+	}
+	cache := NewMemoryJudgeCache()
+	r := NewRunner(reg, infer, tokentrace.NewReporter("matchspec", ""),
+		WithJudge(judge), WithJudgeCache(cache), WithTokenPrices(1, 10))
+
+	run := protocol.EvalRun{Suite: "rag", Tags: map[string]string{"model": "small"}}
+	results, err := r.Run(context.Background(), run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !results[0].Passed || !results[1].Passed {
+		t.Fatalf("results = %+v", results)
+	}
+	// Both tasks produce the same judge prompt, so the second is a hit.
+	if judgeCalls.Load() != 1 || cache.Len() != 1 {
+		t.Errorf("judge calls = %d, cache len = %d", judgeCalls.Load(), cache.Len())
+	}
+
+	runs, _ := r.Runs(RunFilter{})
+	u := runs[0].Summary.Usage
+	if u == nil {
+		t.Fatal("run summary has no usage")
+	}
+	if u.SubjectTokens != 20 || u.JudgeTokens != 100 || u.JudgeCalls != 2 || u.JudgeCacheHits != 1 {
+		t.Errorf("usage = %+v", u)
+	}
+	if u.SubjectCost != 20.0/1e6 || u.JudgeCost != 1000.0/1e6 {
+		t.Errorf("cost = %f/%f", u.SubjectCost, u.JudgeCost)
+	}
+}
+
+func TestSummarizeHasNoUsage(t *testing.T) {
+	if Summarize([]Result{{}}).Usage != nil {
+		t.Error("Summarize should leave Usage nil")
+	}
+}
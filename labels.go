@@ -0,0 +1,122 @@
+package matchspec
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// MaxLabelLength is the longest run label accepted.
+const MaxLabelLength = 128
+
+type runLabelsKey struct{}
+
+// WithRunLabels returns a context whose runs are labeled with labels, in
+// addition to any labels ctx already carries. Labels are free-form
+// strings such as "pr-1234", "nightly", or "model=gpt-x"; they are recorded
+// on the run (see RunRecord.Labels) and select runs in RunFilter and
+// DriftConfig.
+func WithRunLabels(ctx context.Context, labels ...string) context.Context {
+	if len(labels) == 0 {
+		return ctx
+	}
+	merged := slices.Concat(RunLabelsFrom(ctx), labels)
+	slices.Sort(merged)
+	return context.WithValue(ctx, runLabelsKey{}, slices.Compact(merged))
+}
+
+// RunLabelsFrom returns the run labels carried by ctx, sorted.
+func RunLabelsFrom(ctx context.Context) []string {
+	labels, _ := ctx.Value(runLabelsKey{}).([]string)
+	return labels
+}
+
+// ValidateLabel checks that label is usable as a run label: non-empty, at
+// most MaxLabelLength bytes, and free of whitespace and commas, which
+// separate labels on the command line.
+func ValidateLabel(label string) error {
+	switch {
+	case label == "":
+		return fmt.Errorf("matchspec: empty run label")
+	case len(label) > MaxLabelLength:
+		return fmt.Errorf("matchspec: run label %q is longer than %d bytes", label[:16]+"...", MaxLabelLength)
+	case strings.ContainsFunc(label, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }):
+		return fmt.Errorf("matchspec: run label %q contains whitespace or a comma", label)
+	}
+	return nil
+}
+
+// ParseLabels splits a comma-separated list of run labels and validates
+// them.
+func ParseLabels(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	labels := strings.Split(s, ",")
+	for _, l := range labels {
+		if err := ValidateLabel(l); err != nil {
+			return nil, err
+		}
+	}
+	return labels, nil
+}
+
+// jobLabels converts the labels of a queued job to run labels of the form
+// "key=value".
+func jobLabels(labels map[string]string) []string {
+	out := make([]string, 0, len(labels))
+	for k, v := range labels {
+		out = append(out, k+"="+v)
+	}
+	return out
+}
+
+// HasLabels reports whether the run carries every one of labels.
+func (rec *RunRecord) HasLabels(labels []string) bool {
+	for _, l := range labels {
+		if !slices.Contains(rec.Labels, l) {
+			return false
+		}
+	}
+	return true
+}
+
+// TrendPoint is one run in a Trend.
+type TrendPoint struct {
+	RunID     string    `json:"run_id"`
+	Model     string    `json:"model,omitempty"`
+	Labels    []string  `json:"labels,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	Total     int       `json:"total"`
+	PassRate  float64   `json:"pass_rate"`
+	MeanScore float64   `json:"mean_score"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Trend returns the pass rate and mean score of the runs matching f,
+// oldest first, for charting a suite's history. f.Limit keeps the most
+// recent runs.
+func (r *Runner) Trend(f RunFilter) []TrendPoint {
+	runs, _ := r.Runs(f)
+	points := make([]TrendPoint, len(runs))
+	for i, rec := range runs {
+		points[i] = TrendPoint{
+			RunID:     rec.ID,
+			Model:     rec.Model,
+			Labels:    rec.Labels,
+			StartedAt: rec.StartedAt,
+			Total:     rec.Summary.Total,
+			PassRate:  rec.Summary.PassRate,
+			MeanScore: rec.Summary.MeanScore,
+			Error:     rec.Error,
+		}
+	}
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].StartedAt.Before(points[j].StartedAt)
+	})
+	return points
+}
@@ -0,0 +1,114 @@
+package matchspec
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestRunLabels(t *testing.T) {
+	runner, reg := testRunnerAndRegistry()
+	h := NewHandler(runner, reg)
+	ctx := WithRunLabels(context.Background(), "nightly")
+	runner.Run(WithRunLabels(ctx, "pr-1234", "nightly"), protocol.EvalRun{Suite: "math"})
+	runner.Run(ctx, protocol.EvalRun{Suite: "math"})
+
+	req := httptest.NewRequest(http.MethodPost, "/eval?label=pr-99&label=model=gpt-x", strings.NewReader(`{"suite": "math"}`))
+	w := httptest.NewRecorder()
+	h.RunDirect(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /eval status = %d: %s", w.Code, w.Body)
+	}
+
+	for query, want := range map[string]int{
+		"":                             3,
+		"?label=nightly":               2,
+		"?label=nightly&label=pr-1234": 1,
+		"?label=model=gpt-x":           1,
+		"?label=weekly":                0,
+	} {
+		w := httptest.NewRecorder()
+		h.Runs(w, httptest.NewRequest(http.MethodGet, "/runs"+query, nil))
+		var resp RunsResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if resp.Total != want {
+			t.Errorf("GET /runs%s: total = %d, want %d", query, resp.Total, want)
+		}
+	}
+	runs, _ := runner.Runs(RunFilter{Labels: []string{"pr-1234"}})
+	if len(runs) != 1 || !slices.Equal(runs[0].Labels, []string{"nightly", "pr-1234"}) {
+		t.Errorf("labels = %+v", runs)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/eval?label=has+space", strings.NewReader(`{"suite": "math"}`))
+	w = httptest.NewRecorder()
+	h.RunDirect(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid label status = %d, want 400", w.Code)
+	}
+}
+
+func TestTrend(t *testing.T) {
+	runner, reg := testRunnerAndRegistry()
+	h := NewHandler(runner, reg)
+	for range 3 {
+		runner.Run(WithRunLabels(context.Background(), "nightly"), protocol.EvalRun{Suite: "math"})
+	}
+	runner.Run(context.Background(), protocol.EvalRun{Suite: "math"})
+
+	w := httptest.NewRecorder()
+	h.Trend(w, httptest.NewRequest(http.MethodGet, "/trend?suite=math&label=nightly&limit=2", nil))
+	var resp TrendResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Points) != 2 || resp.Points[0].StartedAt.After(resp.Points[1].StartedAt) || resp.Points[0].Total != 1 {
+		t.Fatalf("points = %+v", resp.Points)
+	}
+	all := runner.Trend(RunFilter{Labels: []string{"nightly"}})
+	if len(all) != 3 || all[2].RunID != resp.Points[1].RunID {
+		t.Errorf("trend = %+v, limited %+v", all, resp.Points)
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	labels, err := ParseLabels("pr-1234,nightly,model=gpt-x")
+	if err != nil || len(labels) != 3 {
+		t.Errorf("ParseLabels = %v, %v", labels, err)
+	}
+	for _, bad := range []string{"a,,b", "a b", strings.Repeat("x", MaxLabelLength+1)} {
+		if _, err := ParseLabels(bad); err == nil {
+			t.Errorf("ParseLabels(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestDriftBaselineLabels(t *testing.T) {
+	var broken atomic.Bool
+	runner := NewRunner(driftRegistry(), func(ctx context.Context, prompt string) (string, error) {
+		if broken.Load() {
+			return "", nil
+		}
+		return map[string]string{"What is 2+2?": "4", "What is 3*4?": "12"}[prompt], nil
+	}, tokentrace.NewReporter("matchspec", ""))
+	for range 3 {
+		runner.Run(WithRunLabels(context.Background(), "nightly"), protocol.EvalRun{Suite: "math"})
+	}
+	broken.Store(true)
+	_, id, _ := runner.run(context.Background(), protocol.EvalRun{Suite: "math"})
+	rec, _ := runner.GetRun(id)
+
+	if alerts := runner.CheckDrift(rec, DriftConfig{MaxPassRateDelta: 0.1, Labels: []string{"nightly"}}); len(alerts) != 1 {
+		t.Errorf("nightly baseline alerts = %v", alerts)
+	}
+	// There are no weekly runs to form a baseline.
+	if alerts := runner.CheckDrift(rec, DriftConfig{MaxPassRateDelta: 0.1, Labels: []string{"weekly"}}); alerts != nil {
+		t.Errorf("weekly baseline alerts = %v", alerts)
+	}
+}
@@ -0,0 +1,148 @@
+package matchspec
+
+import (
+	"strings"
+	"unicode"
+)
+
+// languageNames maps language names accepted in Expected to ISO 639-1
+// codes.
+var languageNames = map[string]string{
+	"english": "en", "spanish": "es", "french": "fr", "german": "de",
+	"italian": "it", "portuguese": "pt", "dutch": "nl", "russian": "ru",
+	"ukrainian": "uk", "chinese": "zh", "japanese": "ja", "korean": "ko",
+	"arabic": "ar", "hebrew": "he", "greek": "el", "hindi": "hi", "thai": "th",
+}
+
+// stopwords are frequent function words used to tell apart languages
+// written in Latin script.
+var stopwords = map[string][]string{
+	"en": {"the", "and", "is", "are", "of", "to", "in", "that", "it", "for", "with", "was", "on", "this", "you", "be", "not", "have", "as", "at", "by", "from", "or", "an", "they", "which", "we", "will", "there", "can"},
+	"es": {"el", "la", "los", "las", "de", "que", "y", "en", "un", "una", "es", "por", "con", "para", "del", "se", "no", "su", "al", "lo", "como", "más", "pero", "sus", "le", "ya", "está", "son", "también", "muy"},
+	"fr": {"le", "la", "les", "de", "des", "et", "est", "un", "une", "du", "en", "que", "qui", "dans", "pour", "pas", "sur", "au", "avec", "ce", "il", "elle", "sont", "nous", "vous", "mais", "ou", "aux", "cette", "être"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "zu", "den", "von", "mit", "sich", "des", "auf", "für", "im", "dem", "auch", "es", "an", "werden", "aus", "er", "sie", "hat", "dass", "wir", "ich", "sind"},
+	"it": {"il", "la", "di", "che", "e", "è", "un", "una", "per", "non", "sono", "del", "della", "con", "gli", "le", "nel", "si", "anche", "come", "più", "ma", "lo", "questo", "alla", "dei", "delle", "ha", "essere", "molto"},
+	"pt": {"o", "a", "os", "as", "de", "que", "e", "do", "da", "em", "um", "uma", "para", "com", "não", "por", "mais", "dos", "das", "se", "na", "no", "como", "mas", "foi", "ao", "ele", "ela", "são", "também"},
+	"nl": {"de", "het", "een", "en", "van", "is", "dat", "niet", "op", "te", "zijn", "voor", "met", "die", "ook", "als", "aan", "er", "maar", "om", "hij", "zij", "wordt", "bij", "nog", "naar", "wel", "dit", "worden", "heeft"},
+}
+
+// stopwordLangs is the inverse of stopwords.
+var stopwordLangs = func() map[string][]string {
+	m := make(map[string][]string)
+	for lang, words := range stopwords {
+		for _, w := range words {
+			m[w] = append(m[w], lang)
+		}
+	}
+	return m
+}()
+
+// scriptLanguage returns the language implied by a letter's script, "latin"
+// for Latin letters, or "" for scripts it does not recognize.
+func scriptLanguage(r rune) string {
+	switch {
+	case unicode.Is(unicode.Latin, r):
+		return "latin"
+	case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+		return "ja"
+	case unicode.Is(unicode.Han, r):
+		return "zh"
+	case unicode.Is(unicode.Hangul, r):
+		return "ko"
+	case unicode.Is(unicode.Cyrillic, r):
+		if strings.ContainsRune("іїєґІЇЄҐ", r) {
+			return "uk"
+		}
+		return "ru"
+	case unicode.Is(unicode.Arabic, r):
+		return "ar"
+	case unicode.Is(unicode.Hebrew, r):
+		return "he"
+	case unicode.Is(unicode.Greek, r):
+		return "el"
+	case unicode.Is(unicode.Devanagari, r):
+		return "hi"
+	case unicode.Is(unicode.Thai, r):
+		return "th"
+	}
+	return ""
+}
+
+// LanguageScores estimates the share of text written in each language,
+// keyed by ISO 639-1 code. Scripts with a single language decide by letter
+// count; Latin text is split between languages by stopword frequency, and
+// Latin text without any known stopword is not attributed. Kana marks
+// Japanese even alongside Han characters, and Ukrainian-only letters mark
+// Cyrillic text as Ukrainian. The shares sum to at most 1.
+func LanguageScores(text string) map[string]float64 {
+	letters := make(map[string]int)
+	total := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		total++
+		if lang := scriptLanguage(r); lang != "" {
+			letters[lang]++
+		}
+	}
+	scores := make(map[string]float64)
+	if total == 0 {
+		return scores
+	}
+	if letters["ja"] > 0 {
+		letters["ja"] += letters["zh"]
+		delete(letters, "zh")
+	}
+	if letters["uk"] > 0 {
+		letters["uk"] += letters["ru"]
+		delete(letters, "ru")
+	}
+	for lang, n := range letters {
+		if lang != "latin" {
+			scores[lang] = float64(n) / float64(total)
+		}
+	}
+
+	if latin := letters["latin"]; latin > 0 {
+		hits := make(map[string]float64)
+		var sum float64
+		for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+			langs := stopwordLangs[w]
+			for _, lang := range langs {
+				// Words shared by several languages are split between them.
+				hits[lang] += 1 / float64(len(langs))
+				sum += 1 / float64(len(langs))
+			}
+		}
+		share := float64(latin) / float64(total)
+		for lang, h := range hits {
+			scores[lang] = share * h / sum
+		}
+	}
+	return scores
+}
+
+// DetectLanguage returns the ISO 639-1 code of the language with the
+// largest share of text (see LanguageScores), or "" if none is detected.
+func DetectLanguage(text string) string {
+	var best string
+	var bestScore float64
+	for lang, s := range LanguageScores(text) {
+		if s > bestScore || (s == bestScore && lang < best) {
+			best, bestScore = lang, s
+		}
+	}
+	return best
+}
+
+// matchLanguage passes when the response is detected as the language in
+// Expected, given as an ISO 639-1 code or an English name. The score is the
+// expected language's share of the response.
+func matchLanguage(t *Task, response string) (bool, float64) {
+	want := strings.ToLower(strings.TrimSpace(t.Expected))
+	if code, ok := languageNames[want]; ok {
+		want = code
+	}
+	return DetectLanguage(response) == want, LanguageScores(response)[want]
+}
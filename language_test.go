@@ -0,0 +1,45 @@
+package matchspec
+
+import "testing"
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"The weather is nice today and we are going to the park.", "en"},
+		{"El tiempo es muy bueno hoy y vamos al parque con los niños.", "es"},
+		{"Le temps est beau aujourd'hui et nous allons au parc avec les enfants.", "fr"},
+		{"Das Wetter ist heute schön und wir gehen mit den Kindern in den Park.", "de"},
+		{"Il tempo è bello oggi e andiamo al parco con i bambini.", "it"},
+		{"O tempo está bom hoje e vamos ao parque com as crianças.", "pt"},
+		{"Het weer is vandaag mooi en we gaan met de kinderen naar het park.", "nl"},
+		{"Сегодня хорошая погода, и мы идём в парк.", "ru"},
+		{"Сьогодні гарна погода, і ми йдемо в парк.", "uk"},
+		{"今天天气很好，我们去公园。", "zh"},
+		{"今日は天気がいいので、公園に行きます。", "ja"},
+		{"오늘은 날씨가 좋아서 공원에 갑니다.", "ko"},
+		{"12345 !!!", ""},
+	}
+	for _, tt := range tests {
+		if got := DetectLanguage(tt.text); got != tt.want {
+			t.Errorf("DetectLanguage(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTaskMatchLanguage(t *testing.T) {
+	task := Task{Name: "t", Prompt: "p", Matcher: "language", Expected: "French"}
+	passed, score := task.Match("Bien sûr, voici la réponse à votre question sur les impôts.")
+	if !passed || score < 0.5 {
+		t.Errorf("French response: passed=%v score=%f", passed, score)
+	}
+	passed, _ = task.Match("Sure, here is the answer to your question about taxes.")
+	if passed {
+		t.Error("English response should fail a French language assertion")
+	}
+
+	task.Expected = "ja"
+	if passed, _ := task.Match("はい、わかりました。"); !passed {
+		t.Error("Japanese response should pass")
+	}
+}
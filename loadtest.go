@@ -0,0 +1,180 @@
+package matchspec
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/greynewell/mist-go/trace"
+)
+
+// LoadTestConfig controls a synthetic load test.
+type LoadTestConfig struct {
+	// RPS is the target request rate and Duration how long to sustain it.
+	RPS      float64
+	Duration time.Duration
+
+	// MaxInFlight caps concurrent requests (default 256). Requests due
+	// while the cap is reached are dropped and counted, so a saturated
+	// endpoint shows up as missed rate rather than unbounded goroutines.
+	MaxInFlight int
+
+	// LatencySLOs are checked against request latencies; they default to
+	// the suite's. MaxErrorRate, if positive, is the highest error rate
+	// that complies.
+	LatencySLOs  []LatencySLO
+	MaxErrorRate float64
+}
+
+// LoadTestReport is the outcome of a load test. Responses are not scored.
+type LoadTestReport struct {
+	Suite       string  `json:"suite"`
+	TargetRPS   float64 `json:"target_rps"`
+	AchievedRPS float64 `json:"achieved_rps"`
+	DurationMS  int64   `json:"duration_ms"`
+
+	Requests  int     `json:"requests"`
+	Errors    int     `json:"errors"`
+	Dropped   int     `json:"dropped"`
+	ErrorRate float64 `json:"error_rate"`
+
+	LatencyP50MS float64 `json:"latency_p50_ms"`
+	LatencyP95MS float64 `json:"latency_p95_ms"`
+	LatencyP99MS float64 `json:"latency_p99_ms"`
+
+	// SLOs are the latency checks, and Passed reports whether they and
+	// the error rate limit held.
+	SLOs   []SLOResult `json:"slos,omitempty"`
+	Passed bool        `json:"passed"`
+}
+
+// RunLoadTest replays the suite's prompts round-robin against the inference
+// backend at cfg.RPS for cfg.Duration, purely to load-test the endpoint.
+// Requests are sent open-loop, on schedule regardless of how slowly
+// earlier ones complete, and are not retried. Task headers and the
+// context's InferOptions apply as in a normal run.
+func (r *Runner) RunLoadTest(ctx context.Context, suiteName string, cfg LoadTestConfig) (LoadTestReport, error) {
+	suite, ok := r.registry.Get(suiteName)
+	if !ok {
+		return LoadTestReport{}, fmt.Errorf("matchspec: unknown suite %q", suiteName)
+	}
+	if cfg.RPS <= 0 || cfg.Duration <= 0 {
+		return LoadTestReport{}, fmt.Errorf("matchspec: load test needs a positive RPS and duration")
+	}
+	if cfg.MaxInFlight <= 0 {
+		cfg.MaxInFlight = 256
+	}
+	if cfg.LatencySLOs == nil {
+		cfg.LatencySLOs = suite.LatencySLOs
+	}
+
+	ctx, span := trace.Start(ctx, "matchspec.load")
+	span.SetAttr("suite", suiteName)
+	span.SetAttr("target_rps", cfg.RPS)
+	tasks, err := r.suiteTasks(ctx, suite, span)
+	if err == nil && len(tasks) == 0 {
+		err = fmt.Errorf("matchspec: suite %q has no tasks", suiteName)
+	}
+	if err != nil {
+		span.SetAttr("error", r.redact(err.Error()))
+		span.End("error")
+		r.reporter.Report(ctx, span)
+		return LoadTestReport{}, err
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		lat      []float64
+		errs     int
+		dropped  int
+		inFlight = make(chan struct{}, cfg.MaxInFlight)
+	)
+	send := func(t Task) {
+		defer wg.Done()
+		defer func() { <-inFlight }()
+		ctx := WithHeaders(ctx, t.Headers)
+		start := time.Now()
+		_, err := r.infer(ctx, promptFor(ctx, &t))
+		ms := float64(time.Since(start).Milliseconds())
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			errs++
+			return
+		}
+		lat = append(lat, ms)
+	}
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / cfg.RPS))
+	defer ticker.Stop()
+	deadline := time.NewTimer(cfg.Duration)
+	defer deadline.Stop()
+	start := time.Now()
+	sent := 0
+loop:
+	for {
+		select {
+		case inFlight <- struct{}{}:
+			wg.Add(1)
+			go send(tasks[sent%len(tasks)])
+			sent++
+		default:
+			dropped++
+		}
+		select {
+		case <-ctx.Done():
+			break loop
+		case <-deadline.C:
+			break loop
+		case <-ticker.C:
+		}
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	sort.Float64s(lat)
+	rep := LoadTestReport{
+		Suite:        suiteName,
+		TargetRPS:    cfg.RPS,
+		DurationMS:   elapsed.Milliseconds(),
+		Requests:     sent,
+		Errors:       errs,
+		Dropped:      dropped,
+		LatencyP50MS: percentile(lat, 50),
+		LatencyP95MS: percentile(lat, 95),
+		LatencyP99MS: percentile(lat, 99),
+		Passed:       true,
+	}
+	if secs := elapsed.Seconds(); secs > 0 {
+		rep.AchievedRPS = float64(sent-errs) / secs
+	}
+	if sent > 0 {
+		rep.ErrorRate = float64(errs) / float64(sent)
+	}
+	if cfg.MaxErrorRate > 0 && rep.ErrorRate > cfg.MaxErrorRate {
+		rep.Passed = false
+	}
+	if len(lat) > 0 {
+		for _, slo := range cfg.LatencySLOs {
+			actual := percentile(lat, slo.Percentile)
+			c := SLOResult{Percentile: slo.Percentile, MaxMS: slo.MaxMS, ActualMS: actual, Passed: actual <= float64(slo.MaxMS)}
+			rep.SLOs = append(rep.SLOs, c)
+			rep.Passed = rep.Passed && c.Passed
+		}
+	}
+
+	span.SetAttr("requests", rep.Requests)
+	span.SetAttr("errors", rep.Errors)
+	span.SetAttr("dropped", rep.Dropped)
+	span.SetAttr("achieved_rps", rep.AchievedRPS)
+	status := "ok"
+	if !rep.Passed {
+		status = "error"
+	}
+	span.End(status)
+	r.reporter.Report(ctx, span)
+	return rep, ctx.Err()
+}
@@ -0,0 +1,82 @@
+package matchspec
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestRunLoadTest(t *testing.T) {
+	var mu sync.Mutex
+	prompts := make(map[string]int)
+	var calls atomic.Int64
+	infer := func(ctx context.Context, prompt string) (string, error) {
+		mu.Lock()
+		prompts[prompt]++
+		mu.Unlock()
+		if calls.Add(1)%5 == 0 {
+			return "", errors.New("overloaded")
+		}
+		return "", nil
+	}
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "s",
+		Tasks:       []Task{{Name: "a", Prompt: "a", Expected: "x"}, {Name: "b", Prompt: "b", Expected: "x"}},
+		LatencySLOs: []LatencySLO{{Percentile: 95, MaxMS: 1000}},
+	})
+	runner := NewRunner(reg, infer, tokentrace.NewReporter("matchspec", ""))
+
+	rep, err := runner.RunLoadTest(context.Background(), "s", LoadTestConfig{RPS: 200, Duration: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Requests < 20 || rep.Requests > 60 {
+		t.Errorf("requests = %d, want about 40", rep.Requests)
+	}
+	if prompts["a"] == 0 || prompts["b"] == 0 {
+		t.Errorf("prompts = %v, want both replayed", prompts)
+	}
+	if rep.Errors != rep.Requests/5 || !rep.Passed || len(rep.SLOs) != 1 {
+		t.Errorf("report = %+v", rep)
+	}
+
+	rep, _ = runner.RunLoadTest(context.Background(), "s", LoadTestConfig{RPS: 200, Duration: 100 * time.Millisecond, MaxErrorRate: 0.1})
+	if rep.Passed {
+		t.Errorf("error rate %.2f should violate the 0.1 limit", rep.ErrorRate)
+	}
+}
+
+func TestRunLoadTestDropsWhenSaturated(t *testing.T) {
+	release := make(chan struct{})
+	infer := func(ctx context.Context, prompt string) (string, error) {
+		<-release
+		return "", nil
+	}
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "s", Tasks: []Task{{Name: "a", Prompt: "a", Expected: "x"}}})
+	runner := NewRunner(reg, infer, tokentrace.NewReporter("matchspec", ""))
+
+	time.AfterFunc(150*time.Millisecond, func() { close(release) })
+	rep, err := runner.RunLoadTest(context.Background(), "s", LoadTestConfig{RPS: 100, Duration: 100 * time.Millisecond, MaxInFlight: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Requests != 2 || rep.Dropped == 0 {
+		t.Errorf("requests = %d, dropped = %d; want 2 sent and the rest dropped", rep.Requests, rep.Dropped)
+	}
+}
+
+func TestRunLoadTestInvalid(t *testing.T) {
+	runner, _ := testRunnerAndRegistry()
+	if _, err := runner.RunLoadTest(context.Background(), "math", LoadTestConfig{}); err == nil {
+		t.Error("expected error for zero RPS")
+	}
+	if _, err := runner.RunLoadTest(context.Background(), "nope", LoadTestConfig{RPS: 1, Duration: time.Second}); err == nil {
+		t.Error("expected error for unknown suite")
+	}
+}
@@ -0,0 +1,108 @@
+package matchspec
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Matcher grades a response against a task. It returns whether the task
+// passed and a score in [0, 1].
+type Matcher interface {
+	Match(task Task, response string) (bool, float64)
+}
+
+// MatcherFunc adapts an ordinary function to the Matcher interface.
+type MatcherFunc func(task Task, response string) (bool, float64)
+
+// Match calls f(task, response).
+func (f MatcherFunc) Match(task Task, response string) (bool, float64) {
+	return f(task, response)
+}
+
+// DefaultMatcher is the matcher used by tasks that name none, or name one
+// that is not registered.
+const DefaultMatcher = "contains"
+
+var (
+	matchersMu sync.RWMutex
+	matchers   = map[string]Matcher{
+		"exact":    MatcherFunc(func(t Task, resp string) (bool, float64) { return boolScore(resp == t.Expected) }),
+		"contains": MatcherFunc(func(t Task, resp string) (bool, float64) { return boolScore(strings.Contains(resp, t.Expected)) }),
+		"not_contains": MatcherFunc(func(t Task, resp string) (bool, float64) {
+			return boolScore(!strings.Contains(resp, t.Expected))
+		}),
+		"prefix":   MatcherFunc(func(t Task, resp string) (bool, float64) { return boolScore(strings.HasPrefix(resp, t.Expected)) }),
+		"suffix":   MatcherFunc(func(t Task, resp string) (bool, float64) { return boolScore(strings.HasSuffix(resp, t.Expected)) }),
+		"citation": MatcherFunc(func(t Task, resp string) (bool, float64) { return matchCitation(&t, resp) }),
+		// Requires a judge model; evaluated by Runner.
+		"grounded": MatcherFunc(func(Task, string) (bool, float64) { return false, 0.0 }),
+		"language": MatcherFunc(func(t Task, resp string) (bool, float64) { return matchLanguage(&t, resp) }),
+		"numbers":  MatcherFunc(func(t Task, resp string) (bool, float64) { return matchNumbers(&t, resp) }),
+		"numeric":  MatcherFunc(func(t Task, resp string) (bool, float64) { return matchNumeric(&t, resp) }),
+		"date":     MatcherFunc(func(t Task, resp string) (bool, float64) { return matchDate(&t, resp) }),
+		"quantity": MatcherFunc(func(t Task, resp string) (bool, float64) { return matchQuantity(&t, resp) }),
+		"urls":     MatcherFunc(func(t Task, resp string) (bool, float64) { return matchURLs(&t, resp, nil) }),
+		// Requires a database driver; evaluated by Runner.
+		"sql": MatcherFunc(func(Task, string) (bool, float64) { return false, 0.0 }),
+		// Requires an embedding function; evaluated by Runner.
+		"semantic": MatcherFunc(func(Task, string) (bool, float64) { return false, 0.0 }),
+		// Requires a snapshot store; evaluated by Runner.
+		"snapshot": MatcherFunc(func(Task, string) (bool, float64) { return false, 0.0 }),
+		"diff":     DetailedMatcherFunc(func(t Task, resp string) (bool, float64, map[string]any) { return matchDiff(&t, resp) }),
+		"levenshtein": MatcherFunc(func(t Task, resp string) (bool, float64) {
+			return matchGraded(&t, resp, LevenshteinSimilarity)
+		}),
+		"token_f1": MatcherFunc(func(t Task, resp string) (bool, float64) { return matchGraded(&t, resp, TokenF1) }),
+		"rouge_l":  MatcherFunc(func(t Task, resp string) (bool, float64) { return matchGraded(&t, resp, RougeL) }),
+		"regex":    DetailedMatcherFunc(func(t Task, resp string) (bool, float64, map[string]any) { return matchRegex(&t, resp) }),
+		"json":     DetailedMatcherFunc(func(t Task, resp string) (bool, float64, map[string]any) { return matchJSON(&t, resp) }),
+		"jsonpath": MatcherFunc(func(t Task, resp string) (bool, float64) { return matchJSONPath(&t, resp) }),
+		"toxicity": MatcherFunc(func(t Task, resp string) (bool, float64) {
+			passed, score, _ := matchToxicity(context.Background(), defaultToxicity, &t, resp)
+			return passed, score
+		}),
+		"entities": MatcherFunc(func(t Task, resp string) (bool, float64) {
+			passed, score, _ := matchEntities(context.Background(), defaultEntities, &t, resp)
+			return passed, score
+		}),
+	}
+)
+
+// RegisterMatcher makes m available to tasks as name, so applications can
+// ship custom graders. It replaces any matcher of the same name, including
+// a built-in one. Runner options such as WithJudge or WithExternalMatcher
+// take precedence for the matcher names they handle.
+func RegisterMatcher(name string, m Matcher) {
+	if name == "" || m == nil {
+		panic(fmt.Sprintf("matchspec: RegisterMatcher(%q) with empty name or nil matcher", name))
+	}
+	matchersMu.Lock()
+	defer matchersMu.Unlock()
+	matchers[name] = m
+}
+
+// LookupMatcher returns the matcher registered as name.
+func LookupMatcher(name string) (Matcher, bool) {
+	matchersMu.RLock()
+	defer matchersMu.RUnlock()
+	m, ok := matchers[name]
+	return m, ok
+}
+
+// Matchers returns the names of all registered matchers, sorted.
+func Matchers() []string {
+	matchersMu.RLock()
+	defer matchersMu.RUnlock()
+	return slices.Sorted(maps.Keys(matchers))
+}
+
+func boolScore(ok bool) (bool, float64) {
+	if ok {
+		return true, 1.0
+	}
+	return false, 0.0
+}
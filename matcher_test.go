@@ -0,0 +1,96 @@
+package matchspec
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestRegisterMatcher(t *testing.T) {
+	RegisterMatcher("test-word-count", MatcherFunc(func(task Task, response string) (bool, float64) {
+		n := len(strings.Fields(response))
+		return n <= 3, 3 / float64(max(n, 3))
+	}))
+
+	task := Task{Name: "t", Prompt: "p", Matcher: "test-word-count"}
+	if passed, score := task.Match("one two"); !passed || score != 1 {
+		t.Errorf("short response = %v, %v", passed, score)
+	}
+	if passed, score := task.Match("one two three four five six"); passed || score != 0.5 {
+		t.Errorf("long response = %v, %v", passed, score)
+	}
+
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "s", Tasks: []Task{task}})
+	runner := NewRunner(reg, echoInfer, tokentrace.NewReporter("matchspec", ""))
+	results, err := runner.Run(context.Background(), protocol.EvalRun{Suite: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !results[0].Passed {
+		t.Errorf("runner did not use the registered matcher: %+v", results[0])
+	}
+
+	if !slices.Contains(Matchers(), "test-word-count") || !slices.Contains(Matchers(), "exact") {
+		t.Errorf("Matchers() = %v", Matchers())
+	}
+}
+
+func TestMatcherDefault(t *testing.T) {
+	for _, name := range []string{"", "no-such-matcher"} {
+		task := Task{Matcher: name, Expected: "42"}
+		if passed, _ := task.Match("the answer is 42"); !passed {
+			t.Errorf("matcher %q did not fall back to %s", name, DefaultMatcher)
+		}
+	}
+}
+
+func TestRegisterMatcherNil(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for nil matcher")
+		}
+	}()
+	RegisterMatcher("test-nil", nil)
+}
+
+func TestMatcherNegate(t *testing.T) {
+	task := Task{Expected: `\d{3}-\d{2}-\d{4}`, Matcher: "regex", Negate: true}
+	if passed, score := task.Match("no numbers here"); !passed || score != 1 {
+		t.Errorf("negated miss = %v, %v", passed, score)
+	}
+	if passed, score := task.Match("SSN 123-45-6789"); passed || score != 0 {
+		t.Errorf("negated hit = %v, %v", passed, score)
+	}
+
+	// Partial credit is inverted too.
+	task = Task{Expected: "kitten", Matcher: "levenshtein", Threshold: 0.9, Negate: true}
+	if passed, score := task.Match("sitting"); !passed || score < 0.42 || score > 0.43 {
+		t.Errorf("negated levenshtein = %v, %v", passed, score)
+	}
+
+	// Assertions negate individually, and the runner applies Negate.
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "s", Tasks: []Task{
+		{Name: "whole", Prompt: "1+1", Expected: "echo", Negate: true},
+		{Name: "parts", Prompt: "1+1", Assertions: []Assertion{
+			{Matcher: "contains", Expected: "1+1"},
+			{Matcher: "contains", Expected: "secret", Negate: true},
+		}},
+	}})
+	runner := NewRunner(reg, echoInfer, tokentrace.NewReporter("matchspec", ""))
+	results, err := runner.Run(context.Background(), protocol.EvalRun{Suite: "s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Passed || results[0].Score != 0 {
+		t.Errorf("negated task = %+v", results[0])
+	}
+	if !results[1].Passed || results[1].Score != 1 {
+		t.Errorf("negated assertion = %+v", results[1])
+	}
+}
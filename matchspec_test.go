@@ -4,10 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/greynewell/mist-go/protocol"
 	"github.com/greynewell/mist-go/tokentrace"
@@ -39,6 +43,18 @@ func TestTaskMatchContains(t *testing.T) {
 	}
 }
 
+func TestTaskMatchNotContains(t *testing.T) {
+	task := Task{Name: "t1", Prompt: "p", Expected: "As an AI", Matcher: "not_contains"}
+	passed, score := task.Match("The answer is 4.")
+	if !passed || score != 1 {
+		t.Error("not_contains match should succeed")
+	}
+	passed, _ = task.Match("As an AI, I cannot answer.")
+	if passed {
+		t.Error("not_contains match should fail")
+	}
+}
+
 func TestTaskMatchPrefix(t *testing.T) {
 	task := Task{Name: "t1", Prompt: "p", Expected: "hello", Matcher: "prefix"}
 	passed, _ := task.Match("hello world")
@@ -79,11 +95,13 @@ func TestSuiteValidation(t *testing.T) {
 		suite   Suite
 		wantErr bool
 	}{
-		{"valid", Suite{Name: "math", Tasks: []Task{{Name: "add", Prompt: "1+1"}}}, false},
+		{"valid", Suite{Name: "math", Tasks: []Task{{Name: "add", Prompt: "1+1", Expected: "x"}}}, false},
 		{"no name", Suite{Tasks: []Task{{Name: "t", Prompt: "p"}}}, true},
 		{"no tasks", Suite{Name: "empty"}, true},
 		{"task no name", Suite{Name: "s", Tasks: []Task{{Prompt: "p"}}}, true},
 		{"task no prompt", Suite{Name: "s", Tasks: []Task{{Name: "t"}}}, true},
+		{"exact no expected", Suite{Name: "s", Tasks: []Task{{Name: "t", Prompt: "p", Matcher: "exact"}}}, true},
+		{"exact expected_any", Suite{Name: "s", Tasks: []Task{{Name: "t", Prompt: "p", Matcher: "exact", ExpectedAny: []string{"4", "four"}}}}, false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -118,8 +136,8 @@ func TestSuiteRegistryRegisterAndGet(t *testing.T) {
 
 func TestSuiteRegistryNames(t *testing.T) {
 	reg := NewSuiteRegistry()
-	reg.Register(&Suite{Name: "a", Tasks: []Task{{Name: "t", Prompt: "p"}}})
-	reg.Register(&Suite{Name: "b", Tasks: []Task{{Name: "t", Prompt: "p"}}})
+	reg.Register(&Suite{Name: "a", Tasks: []Task{{Name: "t", Prompt: "p", Expected: "x"}}})
+	reg.Register(&Suite{Name: "b", Tasks: []Task{{Name: "t", Prompt: "p", Expected: "x"}}})
 
 	if len(reg.Names()) != 2 {
 		t.Errorf("Names = %d, want 2", len(reg.Names()))
@@ -224,6 +242,32 @@ func TestRunnerRunInferError(t *testing.T) {
 	}
 }
 
+func TestRunnerMetadataPassthrough(t *testing.T) {
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "meta", Tasks: []Task{
+		{Name: "ok", Prompt: "p", Expected: "p", Metadata: map[string]any{"source": "gsm8k", "difficulty": 3}},
+		{Name: "plain", Prompt: "p", Expected: "p"},
+	}})
+	for _, infer := range []InferFunc{echoInfer, failInfer} {
+		runner := NewRunner(reg, infer, tokentrace.NewReporter("matchspec", ""))
+		results, err := runner.RunDetailed(context.Background(), protocol.EvalRun{Suite: "meta"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if md := results[0].Metadata; md["source"] != "gsm8k" || md["difficulty"] != 3 {
+			t.Errorf("metadata = %v", md)
+		}
+		if results[1].Metadata != nil {
+			t.Errorf("plain metadata = %v, want nil", results[1].Metadata)
+		}
+	}
+
+	data, _ := json.Marshal(Result{Metadata: map[string]any{"source": "gsm8k"}})
+	if !strings.Contains(string(data), `"metadata":{"source":"gsm8k"}`) {
+		t.Errorf("JSON = %s", data)
+	}
+}
+
 func TestRunnerResults(t *testing.T) {
 	runner := testRunner(echoInfer)
 	runner.Run(context.Background(), protocol.EvalRun{Suite: "math"})
@@ -367,6 +411,53 @@ func TestHandlerResultsBySuite(t *testing.T) {
 	}
 }
 
+func TestHandlerResultsQuery(t *testing.T) {
+	runner := NewRunner(driftRegistry(), func(ctx context.Context, prompt string) (string, error) { return "4", nil },
+		tokentrace.NewReporter("matchspec", ""))
+	h := NewHandler(runner, driftRegistry())
+	for range 3 {
+		runner.Run(context.Background(), protocol.EvalRun{Suite: "math"})
+		time.Sleep(time.Millisecond)
+	}
+	runs, _ := runner.Runs(RunFilter{}) // newest first
+	at := func(i int) string { return url.QueryEscape(runs[i].StartedAt.Format(time.RFC3339Nano)) }
+
+	for _, tt := range []struct {
+		query string
+		tasks string
+		total string
+	}{
+		{"", "add,mul,add,mul,add,mul", "6"},
+		{"?limit=2&offset=3", "mul,add", "6"},
+		{"?passed=false", "mul,mul,mul", "3"},
+		{"?task=add&limit=1", "add", "3"},
+		{"?run_id=" + runs[0].ID, "add,mul", "2"},
+		{"?since=" + at(1), "add,mul,add,mul", "4"},
+		{"?since=" + at(1) + "&until=" + at(0), "add,mul", "2"},
+		{"?suite=other", "", "0"},
+	} {
+		w := httptest.NewRecorder()
+		h.Results(w, httptest.NewRequest(http.MethodGet, "/results"+tt.query, nil))
+		var results []Result
+		json.Unmarshal(w.Body.Bytes(), &results)
+		var tasks []string
+		for _, r := range results {
+			tasks = append(tasks, r.Task)
+		}
+		if got := strings.Join(tasks, ","); got != tt.tasks || w.Header().Get("X-Total-Count") != tt.total {
+			t.Errorf("GET /results%s = %s (total %s), want %s (total %s)", tt.query, got, w.Header().Get("X-Total-Count"), tt.tasks, tt.total)
+		}
+	}
+
+	for _, query := range []string{"?passed=maybe", "?limit=-1", "?until=yesterday"} {
+		w := httptest.NewRecorder()
+		h.Results(w, httptest.NewRequest(http.MethodGet, "/results"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("GET /results%s = %d, want 400", query, w.Code)
+		}
+	}
+}
+
 func TestHandlerMethodNotAllowed(t *testing.T) {
 	runner, reg := testRunnerAndRegistry()
 	h := NewHandler(runner, reg)
@@ -379,3 +470,254 @@ func TestHandlerMethodNotAllowed(t *testing.T) {
 		t.Errorf("status = %d, want 405", w.Code)
 	}
 }
+
+func TestHandlerScore(t *testing.T) {
+	runner, reg := testRunnerAndRegistry()
+	h := NewHandler(runner, reg)
+
+	body, _ := json.Marshal(ScoreRequest{
+		Suite: "math",
+		Responses: []TaskResponse{{
+			Task: "add",
+			Transcript: []protocol.ChatMessage{
+				{Role: "user", Content: "1+1"},
+				{Role: "assistant", Content: "echo: 1+1"},
+			},
+		}},
+	})
+	req := httptest.NewRequest("POST", "/score", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	h.Score(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200, body: %s", w.Code, w.Body.String())
+	}
+	var results []protocol.EvalResult
+	json.Unmarshal(w.Body.Bytes(), &results)
+	if len(results) != 1 || !results[0].Passed {
+		t.Errorf("expected 1 passing result, got %+v", results)
+	}
+	if len(runner.Results()) != 1 {
+		t.Error("scored results should be collected")
+	}
+}
+
+func TestHandlerScoreUnknownTask(t *testing.T) {
+	runner, reg := testRunnerAndRegistry()
+	h := NewHandler(runner, reg)
+
+	body, _ := json.Marshal(ScoreRequest{Suite: "math", Responses: []TaskResponse{{Task: "nope", Response: "x"}}})
+	req := httptest.NewRequest("POST", "/score", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	h.Score(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", w.Code)
+	}
+}
+
+func TestHandlerSummary(t *testing.T) {
+	runner, reg := testRunnerAndRegistry()
+	h := NewHandler(runner, reg)
+	runner.Run(context.Background(), protocol.EvalRun{Suite: "math"})
+
+	req := httptest.NewRequest("GET", "/summary?suite=math", nil)
+	w := httptest.NewRecorder()
+	h.Summary(w, req)
+
+	var s Summary
+	json.Unmarshal(w.Body.Bytes(), &s)
+	if s.Total != 1 || s.Passed != 1 || s.Histogram[HistogramBuckets-1].Count != 1 {
+		t.Errorf("unexpected summary: %+v", s)
+	}
+}
+
+func TestRunnerRunCancelledReturnsPartial(t *testing.T) {
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "math", Tasks: []Task{
+		{Name: "add", Prompt: "1+1", Expected: "echo: 1+1"},
+		{Name: "mul", Prompt: "2*3", Expected: "echo: 2*3"},
+		{Name: "sub", Prompt: "3-1", Expected: "echo: 3-1"},
+	}})
+	ctx, cancel := context.WithCancel(context.Background())
+	infer := func(_ context.Context, prompt string) (string, error) {
+		cancel()
+		return "echo: " + prompt, nil
+	}
+	runner := NewRunner(reg, infer, tokentrace.NewReporter("matchspec", ""))
+
+	results, err := runner.Run(ctx, protocol.EvalRun{Suite: "math"})
+	var runErr *RunError
+	if !errors.As(err, &runErr) {
+		t.Fatalf("err = %v, want *RunError", err)
+	}
+	if len(results) != 1 || runErr.Completed != 1 || runErr.Total != 3 {
+		t.Errorf("results = %d, err = %+v", len(results), runErr)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Error("RunError should unwrap to context.Canceled")
+	}
+}
+
+func TestWriteRunResultsPartial(t *testing.T) {
+	w := httptest.NewRecorder()
+	runErr := &RunError{Suite: "math", Completed: 1, Total: 2, Cause: fmt.Errorf("backend down")}
+	writeRunResults(w, []Result{{EvalResult: protocol.EvalResult{Suite: "math", Task: "add", Passed: true}}}, runErr)
+
+	if w.Code != http.StatusMultiStatus {
+		t.Fatalf("status = %d, want 207", w.Code)
+	}
+	var resp struct {
+		Results []protocol.EvalResult `json:"results"`
+		Error   struct {
+			Completed int    `json:"completed"`
+			Message   string `json:"message"`
+		} `json:"error"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Results) != 1 || resp.Error.Completed != 1 || resp.Error.Message == "" {
+		t.Errorf("unexpected body: %s", w.Body.String())
+	}
+}
+
+func TestHandlerRuns(t *testing.T) {
+	runner, reg := testRunnerAndRegistry()
+	h := NewHandler(runner, reg)
+	for _, model := range []string{"a", "b", "a"} {
+		runner.Run(context.Background(), protocol.EvalRun{Suite: "math", Tags: map[string]string{"model": model}})
+	}
+
+	req := httptest.NewRequest("GET", "/runs?model=a&limit=1", nil)
+	w := httptest.NewRecorder()
+	h.Runs(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	var resp RunsResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Total != 2 || len(resp.Runs) != 1 {
+		t.Fatalf("total = %d, runs = %d; want 2, 1", resp.Total, len(resp.Runs))
+	}
+	run := resp.Runs[0]
+	if run.ID == "" || run.Model != "a" || run.Summary.Total != 1 || run.FinishedAt.Before(run.StartedAt) {
+		t.Errorf("unexpected run record: %+v", run)
+	}
+	if _, ok := runner.GetRun(run.ID); !ok {
+		t.Error("GetRun should find the listed run")
+	}
+}
+
+func TestHandlerRunsInvalidLimit(t *testing.T) {
+	runner, reg := testRunnerAndRegistry()
+	h := NewHandler(runner, reg)
+
+	req := httptest.NewRequest("GET", "/runs?limit=-1", nil)
+	w := httptest.NewRecorder()
+	h.Runs(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", w.Code)
+	}
+}
+
+func TestHandlerSuiteStats(t *testing.T) {
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "mixed", Tasks: []Task{
+		{Name: "a", Prompt: "abcd", Expected: "abcd", Matcher: "exact", Tags: []string{"easy"}},
+		{Name: "b", Prompt: "ab", Expected: "ab", Tags: []string{"easy", "math"}},
+	}})
+	runner := NewRunner(reg, echoInfer, tokentrace.NewReporter("matchspec", ""))
+	h := NewHandler(runner, reg)
+	runner.Run(context.Background(), protocol.EvalRun{Suite: "mixed"})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /suites/{name}/stats", h.SuiteStats)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/suites/mixed/stats", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+
+	var resp SuiteStatsResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.TaskCount != 2 || resp.Matchers["exact"] != 1 || resp.Matchers["contains"] != 1 {
+		t.Errorf("unexpected matchers: %+v", resp)
+	}
+	if resp.Tags["easy"] != 2 || resp.Tags["math"] != 1 || resp.AvgPromptLength != 3 {
+		t.Errorf("unexpected tags/length: %+v", resp)
+	}
+	if resp.LastRun == nil || resp.LastRun.Summary.Total != 2 {
+		t.Errorf("expected last run summary, got %+v", resp.LastRun)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/suites/missing/stats", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", w.Code)
+	}
+}
+
+func TestRunnerWarmup(t *testing.T) {
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "math", Tasks: []Task{{Name: "add", Prompt: "1+1", Expected: "echo: 1+1"}}})
+	var calls int
+	infer := func(ctx context.Context, prompt string) (string, error) {
+		calls++
+		return echoInfer(ctx, prompt)
+	}
+	runner := NewRunner(reg, infer, tokentrace.NewReporter("matchspec", ""), WithWarmup(3))
+
+	results, err := runner.Run(context.Background(), protocol.EvalRun{Suite: "math"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 4 {
+		t.Errorf("infer calls = %d, want 3 warm-up + 1 measured", calls)
+	}
+	if len(results) != 1 || len(runner.Results()) != 1 {
+		t.Errorf("warm-up inferences must not produce results")
+	}
+}
+
+func TestRunOrdersByPriority(t *testing.T) {
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{
+		Name: "p",
+		Tasks: []Task{
+			{Name: "low", Prompt: "a", Expected: "x", Priority: -1},
+			{Name: "normal1", Prompt: "b", Expected: "x"},
+			{Name: "critical", Prompt: "c", Expected: "x", Priority: 10},
+			{Name: "normal2", Prompt: "d", Expected: "x"},
+		},
+	})
+	runner := NewRunner(reg, echoInfer, tokentrace.NewReporter("matchspec", ""))
+	results, err := runner.Run(context.Background(), protocol.EvalRun{Suite: "p"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"critical", "normal1", "normal2", "low"}
+	for i, r := range results {
+		if r.Task != want[i] {
+			t.Errorf("results[%d] = %s, want %s", i, r.Task, want[i])
+		}
+	}
+	s, _ := reg.Get("p")
+	if s.Tasks[0].Name != "low" {
+		t.Error("priority ordering modified the suite")
+	}
+}
+
+// allResults returns every result r has collected, with matchspec's
+// fields.
+func allResults(r *Runner) []Result {
+	results, _ := r.QueryResults(ResultFilter{})
+	return results
+}
+
+// suiteResults returns the results r has collected for suite.
+func suiteResults(r *Runner, suite string) []Result {
+	results, _ := r.QueryResults(ResultFilter{Suite: suite})
+	return results
+}
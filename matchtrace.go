@@ -0,0 +1,52 @@
+package matchspec
+
+import (
+	"context"
+	"time"
+
+	"github.com/greynewell/mist-go/trace"
+)
+
+// tracedMatch is match under a "matchspec.match" span of its own, so the
+// time heavy matchers (the judge, embeddings, SQL and code execution) take
+// shows apart from inference in traces and in MetricMatchDuration. It
+// returns how long matching took.
+func (r *Runner) tracedMatch(ctx context.Context, task *Task, response string) (bool, float64, time.Duration, error) {
+	suite := suiteNameFrom(ctx)
+	matcher := matcherName(task)
+	ctx, span := trace.Start(ctx, "matchspec.match")
+	span.SetAttr("suite", suite)
+	span.SetAttr("task", task.Name)
+	span.SetAttr("matcher", matcher)
+
+	start := time.Now()
+	passed, score, err := r.match(ctx, task, response)
+	d := time.Since(start)
+
+	span.SetAttr("duration_ms", d.Milliseconds())
+	if err != nil {
+		span.SetAttr("error", r.redact(err.Error()))
+		span.End("error")
+	} else {
+		span.SetAttr("passed", passed)
+		span.SetAttr("score", score)
+		span.End("ok")
+	}
+	r.reporter.Report(ctx, span)
+	if r.metrics != nil {
+		r.metrics.observeMatch(suite, matcher, d, span)
+	}
+	return passed, score, d, err
+}
+
+// matcherName names the matcher that decides task: "assertions" for tasks
+// with Assertions, otherwise its Matcher or the default.
+func matcherName(task *Task) string {
+	switch {
+	case len(task.Assertions) > 0:
+		return "assertions"
+	case task.Matcher == "":
+		return DefaultMatcher
+	}
+	return task.Matcher
+}
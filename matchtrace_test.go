@@ -0,0 +1,63 @@
+package matchspec
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestMatchTiming(t *testing.T) {
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "geo", Tasks: []Task{
+		{Name: "capital", Prompt: "paris is the capital of france", Expected: "Paris is the capital of France", Matcher: "semantic", Matchers: []string{"contains"}},
+		{Name: "plain", Prompt: "4", Expected: "4"},
+	}})
+	// The embedder is slow enough to stand out from inference.
+	slowEmbed := func(ctx context.Context, text string) ([]float64, error) {
+		time.Sleep(20 * time.Millisecond)
+		return bagOfWords(ctx, text)
+	}
+	m := NewMetrics()
+	runner := NewRunner(reg, echoInfer, tokentrace.NewReporter("matchspec", ""), WithEmbedder(slowEmbed), WithMetrics(m))
+	results, err := runner.RunDetailed(context.Background(), protocol.EvalRun{Suite: "geo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].MatchMS < 40 || results[0].DurationMS >= 40 {
+		t.Errorf("semantic task: match %dms, inference %dms", results[0].MatchMS, results[0].DurationMS)
+	}
+	if results[1].MatchMS >= 40 {
+		t.Errorf("plain task matched in %dms", results[1].MatchMS)
+	}
+
+	var prom strings.Builder
+	m.WritePrometheus(&prom)
+	for _, want := range []string{
+		`matchspec_match_duration_seconds_count{suite="geo",matcher="semantic"} 1`,
+		// The extra matcher and the default matcher of the plain task.
+		`matchspec_match_duration_seconds_count{suite="geo",matcher="contains"} 2`,
+	} {
+		if !strings.Contains(prom.String(), want) {
+			t.Errorf("metrics missing %q:\n%s", want, prom.String())
+		}
+	}
+}
+
+func TestMatcherName(t *testing.T) {
+	for _, tt := range []struct {
+		task Task
+		want string
+	}{
+		{Task{}, DefaultMatcher},
+		{Task{Matcher: "judge"}, "judge"},
+		{Task{Matcher: "exact", Assertions: []Assertion{{Matcher: "exact"}}}, "assertions"},
+	} {
+		if got := matcherName(&tt.task); got != tt.want {
+			t.Errorf("matcherName(%+v) = %q, want %q", tt.task, got, tt.want)
+		}
+	}
+}
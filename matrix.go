@@ -0,0 +1,201 @@
+package matchspec
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Matrix declares a parameter grid over one suite. Every combination of
+// model, parameter value, and prompt variant becomes one run.
+type Matrix struct {
+	Name     string           `json:"name"`
+	Suite    string           `json:"suite"`
+	Models   []string         `json:"models,omitempty"`
+	Params   map[string][]any `json:"params,omitempty"`
+	Variants []PromptVariant  `json:"variants,omitempty"`
+}
+
+// PromptVariant is a named prompt template; PromptPlaceholder marks where
+// the task prompt is substituted.
+type PromptVariant struct {
+	Name     string `json:"name"`
+	Template string `json:"template"`
+}
+
+// MatrixCell is one point of an expanded matrix.
+type MatrixCell struct {
+	Model   string         `json:"model,omitempty"`
+	Params  map[string]any `json:"params,omitempty"`
+	Variant string         `json:"variant,omitempty"`
+}
+
+// LoadMatrix reads a matrix definition from a .yaml, .yml, or .json file.
+func LoadMatrix(path string) (*Matrix, error) {
+	var m Matrix
+	if err := loadConfigFile(path, "matrix", &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Expand returns the campaign that runs every cell of the matrix, along
+// with the cells in the same order as the campaign entries. Parameters are
+// expanded in sorted key order so the grid is deterministic.
+func (m *Matrix) Expand() (*Campaign, []MatrixCell) {
+	models := m.Models
+	if len(models) == 0 {
+		models = []string{""}
+	}
+	variants := m.Variants
+	if len(variants) == 0 {
+		variants = []PromptVariant{{}}
+	}
+
+	keys := make([]string, 0, len(m.Params))
+	for k := range m.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	grids := []map[string]any{{}}
+	for _, k := range keys {
+		var next []map[string]any
+		for _, g := range grids {
+			for _, v := range m.Params[k] {
+				cell := make(map[string]any, len(g)+1)
+				for gk, gv := range g {
+					cell[gk] = gv
+				}
+				cell[k] = v
+				next = append(next, cell)
+			}
+		}
+		grids = next
+	}
+
+	name := m.Name
+	if name == "" {
+		name = "matrix:" + m.Suite
+	}
+	c := &Campaign{Name: name}
+	var cells []MatrixCell
+	for _, model := range models {
+		for _, params := range grids {
+			for _, v := range variants {
+				cell := MatrixCell{Model: model, Variant: v.Name}
+				tags := map[string]string{}
+				if len(params) > 0 {
+					cell.Params = params
+				}
+				if v.Name != "" {
+					tags["variant"] = v.Name
+				}
+				for k, pv := range params {
+					tags["param."+k] = fmt.Sprint(pv)
+				}
+				cells = append(cells, cell)
+				c.Entries = append(c.Entries, CampaignEntry{
+					Suite:          m.Suite,
+					Model:          model,
+					Params:         cell.Params,
+					PromptTemplate: v.Template,
+					Tags:           tags,
+				})
+			}
+		}
+	}
+	return c, cells
+}
+
+// MatrixReport compares the cells of a matrix run.
+type MatrixReport struct {
+	Name  string             `json:"name"`
+	Suite string             `json:"suite"`
+	Cells []MatrixCellReport `json:"cells"`
+
+	// Best is the index of the cell with the highest pass rate (ties go to
+	// the higher mean score), or -1 if no cell ran.
+	Best int `json:"best"`
+
+	// Marginals maps each dimension ("model", "variant", or a parameter
+	// name) to the pass rate of every value, pooled across other dimensions.
+	Marginals map[string]map[string]float64 `json:"marginals"`
+}
+
+// MatrixCellReport is the outcome of one cell.
+type MatrixCellReport struct {
+	MatrixCell
+	RunID   string  `json:"run_id,omitempty"`
+	Summary Summary `json:"summary"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// RunMatrix expands m and runs every cell, returning a comparison report.
+func (r *Runner) RunMatrix(ctx context.Context, m *Matrix) (*MatrixReport, error) {
+	if m.Suite == "" {
+		return nil, fmt.Errorf("matchspec: matrix suite is required")
+	}
+	if _, ok := r.registry.Get(m.Suite); !ok {
+		return nil, fmt.Errorf("matchspec: unknown suite %q", m.Suite)
+	}
+
+	c, cells := m.Expand()
+	cr, err := r.RunCampaign(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &MatrixReport{Name: c.Name, Suite: m.Suite, Best: -1, Marginals: make(map[string]map[string]float64)}
+	type tally struct{ passed, total int }
+	tallies := make(map[string]map[string]*tally)
+	add := func(dim, value string, s Summary) {
+		if tallies[dim] == nil {
+			tallies[dim] = make(map[string]*tally)
+		}
+		t := tallies[dim][value]
+		if t == nil {
+			t = &tally{}
+			tallies[dim][value] = t
+		}
+		t.passed += s.Passed
+		t.total += s.Total
+	}
+
+	for i, e := range cr.Entries {
+		cell := MatrixCellReport{MatrixCell: cells[i], RunID: e.RunID, Summary: e.Summary, Error: e.Error}
+		report.Cells = append(report.Cells, cell)
+
+		if len(m.Models) > 0 {
+			add("model", cell.Model, e.Summary)
+		}
+		if len(m.Variants) > 0 {
+			add("variant", cell.Variant, e.Summary)
+		}
+		for k, v := range cell.Params {
+			add(k, fmt.Sprint(v), e.Summary)
+		}
+
+		if e.Summary.Total == 0 {
+			continue
+		}
+		if report.Best < 0 {
+			report.Best = i
+			continue
+		}
+		best := report.Cells[report.Best].Summary
+		if e.Summary.PassRate > best.PassRate ||
+			(e.Summary.PassRate == best.PassRate && e.Summary.MeanScore > best.MeanScore) {
+			report.Best = i
+		}
+	}
+
+	for dim, values := range tallies {
+		report.Marginals[dim] = make(map[string]float64, len(values))
+		for v, t := range values {
+			if t.total > 0 {
+				report.Marginals[dim][v] = float64(t.passed) / float64(t.total)
+			}
+		}
+	}
+	return report, nil
+}
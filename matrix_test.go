@@ -0,0 +1,63 @@
+package matchspec
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestMatrixExpand(t *testing.T) {
+	m := &Matrix{
+		Suite:    "math",
+		Models:   []string{"a", "b"},
+		Params:   map[string][]any{"temperature": {0.0, 0.7}, "top_p": {1.0}},
+		Variants: []PromptVariant{{Name: "plain", Template: "{{prompt}}"}, {Name: "cot", Template: "Think step by step. {{prompt}}"}},
+	}
+	c, cells := m.Expand()
+	if len(c.Entries) != 8 || len(cells) != 8 {
+		t.Fatalf("entries = %d, want 2 models x 2 temps x 1 top_p x 2 variants", len(c.Entries))
+	}
+	if c.Entries[0].Tags["param.temperature"] != "0" || c.Entries[1].PromptTemplate != m.Variants[1].Template {
+		t.Errorf("unexpected first entries: %+v", c.Entries[:2])
+	}
+}
+
+func TestRunnerRunMatrix(t *testing.T) {
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "math", Tasks: []Task{{Name: "add", Prompt: "1+1", Expected: "2"}}})
+
+	// Only model "b" with the step-by-step variant answers correctly.
+	infer := func(ctx context.Context, prompt string) (string, error) {
+		opts, _ := InferOptionsFrom(ctx)
+		if opts.Model == "b" && strings.HasPrefix(prompt, "Think") {
+			return "2", nil
+		}
+		return "?", nil
+	}
+	runner := NewRunner(reg, infer, tokentrace.NewReporter("matchspec", ""))
+
+	report, err := runner.RunMatrix(context.Background(), &Matrix{
+		Suite:    "math",
+		Models:   []string{"a", "b"},
+		Variants: []PromptVariant{{Name: "plain", Template: "{{prompt}}"}, {Name: "cot", Template: "Think. {{prompt}}"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Cells) != 4 {
+		t.Fatalf("cells = %d, want 4", len(report.Cells))
+	}
+	best := report.Cells[report.Best]
+	if best.Model != "b" || best.Variant != "cot" {
+		t.Errorf("best cell = %+v", best.MatrixCell)
+	}
+	if report.Marginals["model"]["b"] != 0.5 || report.Marginals["variant"]["plain"] != 0 {
+		t.Errorf("unexpected marginals: %+v", report.Marginals)
+	}
+
+	if _, err := runner.RunMatrix(context.Background(), &Matrix{Suite: "missing"}); err == nil {
+		t.Error("expected error for unknown suite")
+	}
+}
@@ -0,0 +1,360 @@
+package matchspec
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/greynewell/mist-go/metrics"
+	"github.com/greynewell/mist-go/trace"
+)
+
+// Metric names exported by Metrics. They are stable: the Grafana dashboard
+// in dashboards/ and any alert rules built on it depend on them, so they
+// only ever gain labels, never change.
+const (
+	MetricRuns             = "matchspec_runs_total"
+	MetricRunDuration      = "matchspec_run_duration_seconds"
+	MetricRunPassRatio     = "matchspec_run_pass_ratio"
+	MetricRunMeanScore     = "matchspec_run_mean_score"
+	MetricRunLastTimestamp = "matchspec_run_last_timestamp_seconds"
+	MetricTasks            = "matchspec_tasks_total"
+	MetricTaskDuration     = "matchspec_task_duration_seconds"
+	MetricTaskRetries      = "matchspec_task_retries_total"
+	MetricTaskTimeouts     = "matchspec_task_timeouts_total"
+	MetricMatchDuration    = "matchspec_match_duration_seconds"
+	MetricQueueWait        = "matchspec_queue_wait_seconds"
+	MetricQueueDepth       = "matchspec_queue_depth"
+	MetricQueueRejected    = "matchspec_queue_rejected_total"
+)
+
+// TaskDurationBuckets, MatchDurationBuckets, RunDurationBuckets, and
+// QueueWaitBuckets are the histogram bounds, in seconds, of
+// MetricTaskDuration, MetricMatchDuration, MetricRunDuration, and
+// MetricQueueWait.
+var (
+	TaskDurationBuckets  = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+	MatchDurationBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+	RunDurationBuckets   = []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600}
+	QueueWaitBuckets     = []float64{0.01, 0.1, 0.5, 1, 5, 15, 60, 300, 900, 3600}
+)
+
+// openMetricsType is the content type of the OpenMetrics exposition, the
+// only one that carries exemplars.
+const openMetricsType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// Metrics collects Prometheus metrics about a runner's runs and tasks (see
+// WithMetrics) and serves them over HTTP. Series are labeled by suite and
+// model. Values are kept in a mist-go metrics registry; Metrics adds the
+// help text and label names the Prometheus exposition needs, and
+// exemplars: duration histograms link each bucket to the trace of a run or
+// task that fell in it, so a latency spike in Grafana leads straight to
+// its trace.
+type Metrics struct {
+	reg      *metrics.Registry
+	families []*metricFamily
+	byName   map[string]*metricFamily
+
+	mu        sync.Mutex
+	exemplars map[string][]*exemplar // by series key, per bucket plus +Inf
+}
+
+// NewMetrics creates an empty metric set.
+func NewMetrics() *Metrics {
+	m := &Metrics{reg: metrics.NewRegistry(), byName: map[string]*metricFamily{}, exemplars: map[string][]*exemplar{}}
+	m.add(MetricRuns, "counter", "Completed runs by status (ok or error).", nil, "suite", "model", "status")
+	m.add(MetricRunDuration, "histogram", "Wall-clock duration of runs.", RunDurationBuckets, "suite", "model")
+	m.add(MetricRunPassRatio, "gauge", "Fraction of tasks that passed in the latest run.", nil, "suite", "model")
+	m.add(MetricRunMeanScore, "gauge", "Mean task score of the latest run.", nil, "suite", "model")
+	m.add(MetricRunLastTimestamp, "gauge", "Unix time the latest run finished.", nil, "suite", "model")
+	m.add(MetricTasks, "counter", "Executed tasks by outcome (passed, failed, or error).", nil, "suite", "model", "outcome")
+	m.add(MetricTaskDuration, "histogram", "Inference latency of tasks, including retries.", TaskDurationBuckets, "suite", "model")
+	m.add(MetricTaskRetries, "counter", "Inference calls retried.", nil, "suite", "model")
+	m.add(MetricTaskTimeouts, "counter", "Tasks with an inference call that timed out.", nil, "suite", "model")
+	m.add(MetricMatchDuration, "histogram", "Time matchers took to evaluate responses, apart from inference.", MatchDurationBuckets, "suite", "matcher")
+	m.add(MetricQueueWait, "histogram", "Time runs and jobs waited before starting, by queue (runs or jobs).", QueueWaitBuckets, "queue", "suite")
+	m.add(MetricQueueDepth, "gauge", "Runs waiting for a slot under the run limit.", nil, "queue")
+	m.add(MetricQueueRejected, "counter", "Runs and jobs turned away because their queue was full.", nil, "queue")
+	return m
+}
+
+func (m *Metrics) add(name, typ, help string, buckets []float64, labels ...string) {
+	f := &metricFamily{name: name, typ: typ, help: help, labels: labels, buckets: buckets}
+	m.families = append(m.families, f)
+	m.byName[name] = f
+}
+
+// Registry returns the mist-go registry holding the metric values, whose
+// Handler serves them as JSON like other MIST tools' metrics.
+func (m *Metrics) Registry() *metrics.Registry {
+	return m.reg
+}
+
+// WithMetrics records the runner's runs and tasks in m.
+func WithMetrics(m *Metrics) RunnerOption {
+	return func(r *Runner) { r.metrics = m }
+}
+
+// metricFamily describes one metric: its help text, label names, and for
+// histograms, bucket bounds.
+type metricFamily struct {
+	name, typ, help string
+	labels          []string
+	buckets         []float64
+}
+
+// pairs returns the registry labels of the series with the given values.
+// The registry tells series apart by their labels joined with commas, so
+// commas in values are escaped.
+func (f *metricFamily) pairs(values []string) []string {
+	pairs := make([]string, 0, 2*len(values))
+	for i, v := range values {
+		pairs = append(pairs, f.labels[i], pairEscaper.Replace(v))
+	}
+	return pairs
+}
+
+var (
+	pairEscaper   = strings.NewReplacer(`\`, `\\`, `,`, `\,`)
+	pairUnescaper = strings.NewReplacer(`\\`, `\`, `\,`, `,`)
+)
+
+// seriesKey identifies a series of the named metric.
+func seriesKey(name string, values []string) string {
+	return name + "\xff" + strings.Join(values, "\xff")
+}
+
+// exemplar is a sample observation linked to its trace and, for tasks,
+// its span.
+type exemplar struct {
+	traceID, spanID string
+	value           float64
+	at              time.Time
+}
+
+func (e *exemplar) labels() ([]string, []string) {
+	if e.spanID == "" {
+		return []string{"trace_id"}, []string{e.traceID}
+	}
+	return []string{"trace_id", "span_id"}, []string{e.traceID, e.spanID}
+}
+
+func (m *Metrics) counter(name string, values ...string) *metrics.Counter {
+	return m.reg.Counter(name, m.byName[name].pairs(values)...)
+}
+
+func (m *Metrics) gauge(name string, values ...string) *metrics.Gauge {
+	return m.reg.Gauge(name, m.byName[name].pairs(values)...)
+}
+
+// observe adds v to a histogram series, keeping the trace, if any, as the
+// exemplar of its bucket.
+func (m *Metrics) observe(name string, v float64, traceID, spanID string, at time.Time, values ...string) {
+	f := m.byName[name]
+	m.reg.Histogram(name, f.buckets, f.pairs(values)...).Observe(v)
+	if traceID == "" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := seriesKey(name, values)
+	ex, ok := m.exemplars[key]
+	if !ok {
+		ex = make([]*exemplar, len(f.buckets)+1)
+		m.exemplars[key] = ex
+	}
+	ex[sort.SearchFloat64s(f.buckets, v)] = &exemplar{traceID: traceID, spanID: spanID, value: v, at: at}
+}
+
+// observeTask records a task executed with inference in the given span.
+func (m *Metrics) observeTask(suite, model string, res Result, span *trace.Span) {
+	outcome := "passed"
+	switch {
+	case res.Error != "":
+		outcome = "error"
+	case !res.Passed:
+		outcome = "failed"
+	}
+	m.counter(MetricTasks, suite, model, outcome).Inc()
+	if res.Cache != CacheHit {
+		m.observe(MetricTaskDuration, float64(res.DurationMS)/1000, span.TraceID, span.SpanID, time.Now(), suite, model)
+	}
+	if res.Attempts > 1 {
+		m.counter(MetricTaskRetries, suite, model).Add(int64(res.Attempts - 1))
+	}
+	if res.TimedOut {
+		m.counter(MetricTaskTimeouts, suite, model).Inc()
+	}
+}
+
+// observeMatch records a matcher evaluation in the given span.
+func (m *Metrics) observeMatch(suite, matcher string, d time.Duration, span *trace.Span) {
+	m.observe(MetricMatchDuration, d.Seconds(), span.TraceID, span.SpanID, time.Now(), suite, matcher)
+}
+
+// observeRun records a completed run.
+func (m *Metrics) observeRun(rec RunRecord) {
+	status := "ok"
+	if rec.Error != "" {
+		status = "error"
+	}
+	m.counter(MetricRuns, rec.Suite, rec.Model, status).Inc()
+	m.observe(MetricRunDuration, rec.FinishedAt.Sub(rec.StartedAt).Seconds(), rec.TraceID, "", rec.FinishedAt, rec.Suite, rec.Model)
+	if rec.Summary.Total > 0 {
+		m.gauge(MetricRunPassRatio, rec.Suite, rec.Model).Set(rec.Summary.PassRate)
+		m.gauge(MetricRunMeanScore, rec.Suite, rec.Model).Set(rec.Summary.MeanScore)
+	}
+	m.gauge(MetricRunLastTimestamp, rec.Suite, rec.Model).Set(float64(rec.FinishedAt.UnixMilli()) / 1000)
+}
+
+// observeQueueWait records how long a run or job waited in queue.
+func (m *Metrics) observeQueueWait(queue, suite string, d time.Duration) {
+	m.observe(MetricQueueWait, d.Seconds(), "", "", time.Now(), queue, suite)
+}
+
+// setQueueDepth records the number of entries waiting in queue.
+func (m *Metrics) setQueueDepth(queue string, n int) {
+	m.gauge(MetricQueueDepth, queue).Set(float64(n))
+}
+
+// queueRejected counts an entry turned away from a full queue.
+func (m *Metrics) queueRejected(queue string) {
+	m.counter(MetricQueueRejected, queue).Inc()
+}
+
+// ServeHTTP handles GET /metrics. Scrapers that accept OpenMetrics, as
+// Prometheus does with exemplar storage enabled, get exemplars; others get
+// the Prometheus text format without them.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text") {
+		w.Header().Set("Content-Type", openMetricsType)
+		m.WriteOpenMetrics(w)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WritePrometheus(w)
+}
+
+// WritePrometheus writes the metrics in the Prometheus text format.
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	return m.write(w, false)
+}
+
+// WriteOpenMetrics writes the metrics in the OpenMetrics text format, with
+// exemplars.
+func (m *Metrics) WriteOpenMetrics(w io.Writer) error {
+	return m.write(w, true)
+}
+
+// seriesOut is one series of a snapshot, ready to write.
+type seriesOut struct {
+	values []string
+	value  string
+	hist   metrics.HistogramSnapshot
+}
+
+// labelValues returns the values of registry label pairs.
+func labelValues(pairs []string) []string {
+	values := make([]string, 0, len(pairs)/2)
+	for i := 1; i < len(pairs); i += 2 {
+		values = append(values, pairUnescaper.Replace(pairs[i]))
+	}
+	return values
+}
+
+func (m *Metrics) write(w io.Writer, openMetrics bool) error {
+	snap := m.reg.Snapshot()
+	series := map[string][]seriesOut{}
+	for _, c := range snap.Counters {
+		series[c.Name] = append(series[c.Name], seriesOut{values: labelValues(c.Labels), value: strconv.FormatInt(c.Value, 10)})
+	}
+	for _, g := range snap.Gauges {
+		series[g.Name] = append(series[g.Name], seriesOut{values: labelValues(g.Labels), value: formatFloat(g.Value)})
+	}
+	for _, h := range snap.Histograms {
+		series[h.Name] = append(series[h.Name], seriesOut{values: labelValues(h.Labels), hist: h})
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var sb strings.Builder
+	for _, f := range m.families {
+		name := f.name
+		if openMetrics && f.typ == "counter" {
+			// OpenMetrics names the counter family without the suffix.
+			name = strings.TrimSuffix(name, "_total")
+		}
+		fmt.Fprintf(&sb, "# HELP %s %s\n# TYPE %s %s\n", name, f.help, name, f.typ)
+
+		out := series[f.name]
+		sort.Slice(out, func(i, j int) bool {
+			return strings.Join(out[i].values, "\xff") < strings.Join(out[j].values, "\xff")
+		})
+		for _, s := range out {
+			labels := formatLabels(f.labels, s.values)
+			if f.typ != "histogram" {
+				fmt.Fprintf(&sb, "%s%s %s\n", f.name, labels, s.value)
+				continue
+			}
+			exemplars := m.exemplars[seriesKey(f.name, s.values)]
+			for i := range len(f.buckets) + 1 {
+				le, cumulative := "+Inf", s.hist.Count
+				if i < len(f.buckets) {
+					le, cumulative = formatFloat(f.buckets[i]), s.hist.Buckets[f.buckets[i]]
+				}
+				fmt.Fprintf(&sb, "%s_bucket%s,le=\"%s\"} %d", f.name, strings.TrimSuffix(labels, "}"), le, cumulative)
+				if openMetrics && exemplars != nil && exemplars[i] != nil {
+					e := exemplars[i]
+					fmt.Fprintf(&sb, " # %s %s %.3f", formatLabels(e.labels()), formatFloat(e.value), float64(e.at.UnixMilli())/1000)
+				}
+				sb.WriteByte('\n')
+			}
+			fmt.Fprintf(&sb, "%s_sum%s %s\n%s_count%s %d\n", f.name, labels, formatFloat(s.hist.Sum), f.name, labels, s.hist.Count)
+		}
+	}
+	if openMetrics {
+		sb.WriteString("# EOF\n")
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + `="` + escapeLabel(values[i]) + `"`
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// observeTask records res in the runner's metrics, if any.
+func (r *Runner) observeTask(ctx context.Context, suite string, span *trace.Span, res Result) {
+	if r.metrics == nil {
+		return
+	}
+	opts, _ := InferOptionsFrom(ctx)
+	r.metrics.observeTask(suite, opts.Model, res, span)
+}
@@ -0,0 +1,144 @@
+package matchspec
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+	"github.com/greynewell/mist-go/trace"
+)
+
+func TestMetrics(t *testing.T) {
+	m := NewMetrics()
+	infer := func(ctx context.Context, prompt string) (string, error) {
+		if prompt == "What is 3*4?" {
+			return "11", nil
+		}
+		return "4", nil
+	}
+	runner := NewRunner(driftRegistry(), infer, tokentrace.NewReporter("matchspec", ""), WithMetrics(m))
+	if _, err := runner.Run(context.Background(), protocol.EvalRun{Suite: "math", Tags: map[string]string{"model": "m"}}); err != nil {
+		t.Fatal(err)
+	}
+	runs, _ := runner.Runs(RunFilter{})
+	rec := runs[0]
+
+	var prom strings.Builder
+	m.WritePrometheus(&prom)
+	for _, want := range []string{
+		"# TYPE matchspec_runs_total counter\n",
+		`matchspec_runs_total{suite="math",model="m",status="ok"} 1` + "\n",
+		`matchspec_tasks_total{suite="math",model="m",outcome="passed"} 1` + "\n",
+		`matchspec_tasks_total{suite="math",model="m",outcome="failed"} 1` + "\n",
+		`matchspec_run_pass_ratio{suite="math",model="m"} 0.5` + "\n",
+		`matchspec_task_duration_seconds_bucket{suite="math",model="m",le="+Inf"} 2` + "\n",
+		`matchspec_task_duration_seconds_count{suite="math",model="m"} 2` + "\n",
+	} {
+		if !strings.Contains(prom.String(), want) {
+			t.Errorf("prometheus output missing %q:\n%s", want, prom.String())
+		}
+	}
+	if strings.Contains(prom.String(), "trace_id") {
+		t.Error("prometheus output has exemplars")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, req)
+	om := w.Body.String()
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "application/openmetrics-text") || !strings.HasSuffix(om, "# EOF\n") {
+		t.Fatalf("openmetrics response %q:\n%s", w.Header().Get("Content-Type"), om)
+	}
+	if !strings.Contains(om, "# TYPE matchspec_runs counter\n") {
+		t.Errorf("counter family not named without _total:\n%s", om)
+	}
+	taskExemplar := regexp.MustCompile(`matchspec_task_duration_seconds_bucket\{suite="math",model="m",le="0\.05"\} 2 # \{trace_id="` + rec.TraceID + `",span_id="[0-9a-f]+"\} \S+ \d+\.\d{3}\n`)
+	if !taskExemplar.MatchString(om) {
+		t.Errorf("no task exemplar for trace %s:\n%s", rec.TraceID, om)
+	}
+	if !strings.Contains(om, `matchspec_run_duration_seconds_bucket{suite="math",model="m",le="1"} 1 # {trace_id="`+rec.TraceID+`"}`) {
+		t.Errorf("no run exemplar:\n%s", om)
+	}
+}
+
+func TestMetricsRetriesAndTimeouts(t *testing.T) {
+	m := NewMetrics()
+	m.observeRun(RunRecord{Suite: "s", Error: "boom"})
+	m.observeTask("s", "", Result{EvalResult: protocol.EvalResult{Error: "timed out"}, Attempts: 3, TimedOut: true}, &trace.Span{})
+	var sb strings.Builder
+	m.WritePrometheus(&sb)
+	for _, want := range []string{
+		`matchspec_runs_total{suite="s",model="",status="error"} 1`,
+		`matchspec_tasks_total{suite="s",model="",outcome="error"} 1`,
+		`matchspec_task_retries_total{suite="s",model=""} 2`,
+		`matchspec_task_timeouts_total{suite="s",model=""} 1`,
+	} {
+		if !strings.Contains(sb.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, sb.String())
+		}
+	}
+	// A run with no tasks leaves the pass ratio unset.
+	if strings.Contains(sb.String(), "matchspec_run_pass_ratio{") {
+		t.Errorf("output:\n%s", sb.String())
+	}
+}
+
+func TestMetricsLabelsWithCommas(t *testing.T) {
+	m := NewMetrics()
+	m.queueRejected("a,b")
+	m.observeTask("x,model,y", "z", Result{}, &trace.Span{})
+	m.observeTask("x", "y,model,z", Result{}, &trace.Span{})
+	var sb strings.Builder
+	m.WritePrometheus(&sb)
+	for _, want := range []string{
+		`matchspec_queue_rejected_total{queue="a,b"} 1`,
+		`matchspec_tasks_total{suite="x,model,y",model="z",outcome="failed"} 1`,
+		`matchspec_tasks_total{suite="x",model="y,model,z",outcome="failed"} 1`,
+	} {
+		if !strings.Contains(sb.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, sb.String())
+		}
+	}
+	if snap := m.Registry().Snapshot(); len(snap.Counters) != 3 {
+		t.Errorf("registry counters = %+v", snap.Counters)
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	if got := formatLabels([]string{"suite"}, []string{"a\"b\\c\nd"}); got != `{suite="a\"b\\c\nd"}` {
+		t.Errorf("formatLabels = %s", got)
+	}
+}
+
+// TestDashboardMetrics checks that the shipped dashboard only queries
+// metrics that exist.
+func TestDashboardMetrics(t *testing.T) {
+	data, err := os.ReadFile("dashboards/matchspec.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dash any
+	if err := json.Unmarshal(data, &dash); err != nil {
+		t.Fatal(err)
+	}
+	known := map[string]bool{}
+	for _, f := range NewMetrics().families {
+		known[f.name] = true
+		if f.typ == "histogram" {
+			known[f.name+"_bucket"] = true
+		}
+	}
+	for _, name := range regexp.MustCompile(`matchspec_[a-z_]+`).FindAllString(string(data), -1) {
+		if !known[name] {
+			t.Errorf("dashboard uses unknown metric %s", name)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package matchspec
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// normalizedMatchers are the matchers that apply a task's Normalization.
+var normalizedMatchers = []string{"exact", "contains", "not_contains", "prefix", "suffix", "diff", "levenshtein", "token_f1", "rouge_l"}
+
+// Normalization options are applied to both the response and Expected
+// before string matchers compare them, so trivial formatting differences
+// in model output do not fail a task. They apply to "exact", "contains",
+// "not_contains", "prefix", "suffix", "diff", "levenshtein", "token_f1",
+// and "rouge_l".
+type Normalization struct {
+	IgnoreCase         bool `json:"ignore_case,omitempty"`
+	TrimWhitespace     bool `json:"trim_whitespace,omitempty"`
+	CollapseWhitespace bool `json:"collapse_whitespace,omitempty"`
+	StripPunctuation   bool `json:"strip_punctuation,omitempty"`
+
+	// StripMarkdown removes code fences, headings, blockquote and list
+	// markers, asterisk emphasis, strikethrough and inline code marks, and
+	// link targets, keeping the text.
+	StripMarkdown bool `json:"strip_markdown,omitempty"`
+}
+
+func (n Normalization) enabled() bool {
+	return n != Normalization{}
+}
+
+var (
+	mdFencePattern    = regexp.MustCompile("(?m)^[ \t]*(```|~~~).*$\n?")
+	mdLinePattern     = regexp.MustCompile(`(?m)^[ \t]*(?:#{1,6}[ \t]+|>[ \t]?|[-*+][ \t]+|\d+[.)][ \t]+)`)
+	mdLinkPattern     = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+	mdEmphasisPattern = regexp.MustCompile("(\\*\\*|\\*|~~|`)([^\\s*~`](?:[^*~`]*[^\\s*~`])?)(\\*\\*|\\*|~~|`)")
+)
+
+// Normalize applies the enabled options to s, in the order markdown,
+// punctuation, whitespace, case.
+func (n Normalization) Normalize(s string) string {
+	if n.StripMarkdown {
+		s = stripMarkdown(s)
+	}
+	if n.StripPunctuation {
+		s = strings.Map(func(r rune) rune {
+			if unicode.IsPunct(r) {
+				return -1
+			}
+			return r
+		}, s)
+	}
+	if n.CollapseWhitespace {
+		s = collapseSpaces(s)
+	}
+	if n.TrimWhitespace {
+		s = strings.TrimSpace(s)
+	}
+	if n.IgnoreCase {
+		s = strings.ToLower(s)
+	}
+	return s
+}
+
+// collapseSpaces replaces each run of whitespace with a single space,
+// keeping leading and trailing space so TrimWhitespace stays separate.
+func collapseSpaces(s string) string {
+	var sb strings.Builder
+	space := false
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			if !space {
+				sb.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		space = false
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+func stripMarkdown(s string) string {
+	s = mdFencePattern.ReplaceAllString(s, "")
+	s = mdLinePattern.ReplaceAllString(s, "")
+	s = mdLinkPattern.ReplaceAllString(s, "$1")
+	// Nested emphasis, such as ***bold italic***, takes more than one pass.
+	for {
+		out := mdEmphasisPattern.ReplaceAllStringFunc(s, func(m string) string {
+			sub := mdEmphasisPattern.FindStringSubmatch(m)
+			if sub[1] != sub[3] {
+				return m
+			}
+			return sub[2]
+		})
+		if out == s {
+			return s
+		}
+		s = out
+	}
+}
+
+// normalizeFor returns the task and response as the named matcher sees
+// them after the task's Normalization.
+func normalizeFor(name string, t Task, response string) (Task, string) {
+	if !t.Normalization.enabled() || !slices.Contains(normalizedMatchers, name) {
+		return t, response
+	}
+	t.Expected = t.Normalize(t.Expected)
+	return t, t.Normalize(response)
+}
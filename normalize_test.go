@@ -0,0 +1,65 @@
+package matchspec
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		n    Normalization
+		in   string
+		want string
+	}{
+		{Normalization{IgnoreCase: true}, "Paris", "paris"},
+		{Normalization{TrimWhitespace: true}, "  Paris \n", "Paris"},
+		{Normalization{CollapseWhitespace: true}, " a \t b\n\nc ", " a b c "},
+		{Normalization{StripPunctuation: true}, "Yes, it's Paris!", "Yes its Paris"},
+		{Normalization{StripMarkdown: true}, "## Answer\n\n**Paris** is the *capital*.", "Answer\n\nParis is the capital."},
+		{Normalization{StripMarkdown: true}, "```python\nprint(1)\n```\n", "print(1)\n"},
+		{Normalization{StripMarkdown: true}, "- see [the docs](https://x.y) and `f()`", "see the docs and f()"},
+		{Normalization{StripMarkdown: true}, "> ***quoted*** ~~old~~", "quoted old"},
+		{Normalization{StripMarkdown: true}, "2 * 3 * 4 and snake_case_name", "2 * 3 * 4 and snake_case_name"},
+		{Normalization{StripMarkdown: true, StripPunctuation: true, CollapseWhitespace: true, TrimWhitespace: true, IgnoreCase: true},
+			"**The answer:**\n\n  Paris.  ", "the answer paris"},
+	}
+	for _, tt := range tests {
+		if got := tt.n.Normalize(tt.in); got != tt.want {
+			t.Errorf("%+v.Normalize(%q) = %q, want %q", tt.n, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTaskMatchNormalized(t *testing.T) {
+	task := Task{Matcher: "exact", Expected: "Paris"}
+	response := "**Paris.**\n"
+	if passed, _ := task.Match(response); passed {
+		t.Fatal("unnormalized exact match passed")
+	}
+	task.Normalization = Normalization{StripMarkdown: true, StripPunctuation: true, TrimWhitespace: true}
+	if passed, _ := task.Match(response); !passed {
+		t.Error("normalized exact match failed")
+	}
+
+	// Unknown matchers fall back to contains, which normalizes too.
+	task = Task{Matcher: "nope", Expected: "PARIS", Normalization: Normalization{IgnoreCase: true}}
+	if passed, _ := task.Match("It is paris."); !passed {
+		t.Error("normalized default matcher failed")
+	}
+
+	// Regex patterns are not normalized.
+	task = Task{Matcher: "regex", Expected: `^\d+$`, Normalization: Normalization{StripPunctuation: true}}
+	if passed, _ := task.Match("42"); !passed {
+		t.Error("regex with normalization failed")
+	}
+}
+
+func TestNormalizationJSON(t *testing.T) {
+	var task Task
+	if err := json.Unmarshal([]byte(`{"name": "t", "ignore_case": true, "strip_markdown": true}`), &task); err != nil {
+		t.Fatal(err)
+	}
+	if !task.IgnoreCase || !task.StripMarkdown || task.TrimWhitespace {
+		t.Errorf("normalization = %+v", task.Normalization)
+	}
+}
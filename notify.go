@@ -0,0 +1,407 @@
+package matchspec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Notifier receives run events from a runner. Register it with
+// WithNotifier. Its methods are called synchronously after each run is
+// recorded, with the run's results (nil for streamed runs); errors are
+// counted by NotifyErrors and never fail the run.
+type Notifier interface {
+	// RunCompleted is called for every recorded run, including runs that
+	// ended in an error or were cancelled.
+	RunCompleted(ctx context.Context, rec RunRecord, results []Result) error
+
+	// RegressionDetected is called after RunCompleted when the run scored
+	// worse than its suite's baseline (see WithRegressionCheck).
+	RegressionDetected(ctx context.Context, rec RunRecord, results []Result, alerts []DriftAlert) error
+}
+
+// WithNotifier adds a notifier that is told about every run.
+func WithNotifier(n Notifier) RunnerOption {
+	return func(r *Runner) { r.notifiers = append(r.notifiers, n) }
+}
+
+// WithRegressionCheck compares every run with its suite's trailing
+// baseline, as CheckDrift does, and reports drops beyond cfg's limits to
+// the runner's notifiers. Improvements are not reported. cfg.Interval is
+// ignored.
+func WithRegressionCheck(cfg DriftConfig) RunnerOption {
+	return func(r *Runner) { r.regression = &cfg }
+}
+
+// notify passes rec and its results, and any regression it shows, to
+// every notifier. It is not cancelled with ctx, so cancelled runs are
+// still reported.
+func (r *Runner) notify(ctx context.Context, rec RunRecord, results []Result) {
+	if len(r.notifiers) == 0 {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	var regressions []DriftAlert
+	if r.regression != nil {
+		for _, a := range r.CheckDrift(rec, *r.regression) {
+			if a.Delta < 0 {
+				regressions = append(regressions, a)
+			}
+		}
+	}
+	for _, n := range r.notifiers {
+		err := n.RunCompleted(ctx, rec, results)
+		if err == nil && len(regressions) > 0 {
+			err = n.RegressionDetected(ctx, rec, results, regressions)
+		}
+		if err != nil {
+			r.mu.Lock()
+			r.notifyErrors++
+			r.mu.Unlock()
+		}
+	}
+}
+
+// NotifyErrors returns the number of failed notifications.
+func (r *Runner) NotifyErrors() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.notifyErrors
+}
+
+// Notifier events, for NotifierConfig.On.
+const (
+	NotifyAlways     = "always"
+	NotifyRegression = "regression"
+)
+
+// NotifierConfig configures a notifier in a project config file. Type
+// selects the notifier: "webhook", "slack", "email", "pagerduty",
+// "opsgenie", or a type registered with RegisterNotifier.
+type NotifierConfig struct {
+	Type string `json:"type"`
+
+	// On is NotifyAlways (the default) to notify of every run, or
+	// NotifyRegression to notify only of regressions.
+	On string `json:"on,omitempty"`
+
+	// Suites, if set, limits the notifier to runs of these suites, such
+	// as the canary suites that should page someone.
+	Suites []string `json:"suites,omitempty"`
+
+	// URL is the endpoint of "webhook" and "slack" notifiers.
+	URL string `json:"url,omitempty"`
+
+	// SMTP settings of "email" notifiers. The password is read from the
+	// environment variable named by PasswordEnv; without one, mail is
+	// sent unauthenticated. Without To, mail goes to the contact address
+	// of each suite's owner (see SuiteOwner).
+	SMTPAddr    string   `json:"smtp_addr,omitempty"`
+	From        string   `json:"from,omitempty"`
+	To          []string `json:"to,omitempty"`
+	Username    string   `json:"username,omitempty"`
+	PasswordEnv string   `json:"password_env,omitempty"`
+
+	// Incident settings of "pagerduty" and "opsgenie" notifiers: the
+	// environment variables holding the PagerDuty routing key or Opsgenie
+	// API key, and the lowest healthy pass rate (see
+	// PagerDutyNotifier.MinPassRate). URL overrides the API endpoint.
+	RoutingKeyEnv string  `json:"routing_key_env,omitempty"`
+	APIKeyEnv     string  `json:"api_key_env,omitempty"`
+	MinPassRate   float64 `json:"min_pass_rate,omitempty"`
+
+	// Options holds settings for registered notifier types.
+	Options map[string]any `json:"options,omitempty"`
+}
+
+// NotifierFactory builds a notifier from its config. client is the HTTP
+// client for notifiers that call HTTP endpoints.
+type NotifierFactory func(cfg NotifierConfig, client *http.Client) (Notifier, error)
+
+var (
+	notifiersMu sync.RWMutex
+	notifiers   = map[string]NotifierFactory{
+		"webhook": func(cfg NotifierConfig, client *http.Client) (Notifier, error) {
+			if cfg.URL == "" {
+				return nil, fmt.Errorf("matchspec: webhook notifier has no url")
+			}
+			return &WebhookNotifier{URL: cfg.URL, Client: client}, nil
+		},
+		"slack": func(cfg NotifierConfig, client *http.Client) (Notifier, error) {
+			if cfg.URL == "" {
+				return nil, fmt.Errorf("matchspec: slack notifier has no url")
+			}
+			return &SlackNotifier{WebhookURL: cfg.URL, Client: client}, nil
+		},
+		"email":     newEmailNotifier,
+		"pagerduty": newPagerDutyNotifier,
+		"opsgenie":  newOpsgenieNotifier,
+	}
+)
+
+// RegisterNotifier makes a notifier type available to config files under
+// typ, replacing any notifier type of that name. It panics if typ is empty
+// or f is nil.
+func RegisterNotifier(typ string, f NotifierFactory) {
+	if typ == "" || f == nil {
+		panic(fmt.Sprintf("matchspec: RegisterNotifier(%q) with empty type or nil factory", typ))
+	}
+	notifiersMu.Lock()
+	defer notifiersMu.Unlock()
+	notifiers[typ] = f
+}
+
+// NewNotifier builds the notifier cfg describes.
+func NewNotifier(cfg NotifierConfig, client *http.Client) (Notifier, error) {
+	notifiersMu.RLock()
+	f, ok := notifiers[cfg.Type]
+	notifiersMu.RUnlock()
+	if !ok {
+		notifiersMu.RLock()
+		types := slices.Sorted(maps.Keys(notifiers))
+		notifiersMu.RUnlock()
+		return nil, fmt.Errorf("matchspec: unknown notifier type %q (want one of %s)", cfg.Type, strings.Join(types, ", "))
+	}
+	if cfg.On != "" && cfg.On != NotifyAlways && cfg.On != NotifyRegression {
+		return nil, fmt.Errorf("matchspec: %s notifier on %q must be %q or %q", cfg.Type, cfg.On, NotifyAlways, NotifyRegression)
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	n, err := f(cfg, client)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.On == NotifyRegression {
+		n = regressionsOnly{n}
+	}
+	if len(cfg.Suites) > 0 {
+		n = suiteNotifier{n, cfg.Suites}
+	}
+	return n, nil
+}
+
+// regressionsOnly passes on only RegressionDetected.
+type regressionsOnly struct{ Notifier }
+
+func (regressionsOnly) RunCompleted(context.Context, RunRecord, []Result) error { return nil }
+
+// suiteNotifier passes on only events of the listed suites.
+type suiteNotifier struct {
+	Notifier
+	suites []string
+}
+
+func (n suiteNotifier) RunCompleted(ctx context.Context, rec RunRecord, results []Result) error {
+	if !slices.Contains(n.suites, rec.Suite) {
+		return nil
+	}
+	return n.Notifier.RunCompleted(ctx, rec, results)
+}
+
+func (n suiteNotifier) RegressionDetected(ctx context.Context, rec RunRecord, results []Result, alerts []DriftAlert) error {
+	if !slices.Contains(n.suites, rec.Suite) {
+		return nil
+	}
+	return n.Notifier.RegressionDetected(ctx, rec, results, alerts)
+}
+
+// Notification is the JSON body a WebhookNotifier posts. Event is
+// "run_completed" or "regression_detected"; Alerts is set for the latter.
+type Notification struct {
+	Event  string       `json:"event"`
+	Run    RunRecord    `json:"run"`
+	Alerts []DriftAlert `json:"alerts,omitempty"`
+}
+
+// WebhookNotifier posts each event to URL as a JSON Notification.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// RunCompleted posts a "run_completed" notification.
+func (w *WebhookNotifier) RunCompleted(ctx context.Context, rec RunRecord, _ []Result) error {
+	return postJSON(ctx, w.Client, w.URL, "notification", Notification{Event: "run_completed", Run: rec})
+}
+
+// RegressionDetected posts a "regression_detected" notification.
+func (w *WebhookNotifier) RegressionDetected(ctx context.Context, rec RunRecord, _ []Result, alerts []DriftAlert) error {
+	return postJSON(ctx, w.Client, w.URL, "notification", Notification{Event: "regression_detected", Run: rec, Alerts: alerts})
+}
+
+// SlackNotifier posts a one-line message for each event to a Slack
+// incoming webhook.
+type SlackNotifier struct {
+	WebhookURL string
+	Client     *http.Client
+}
+
+// RunCompleted posts the run's summary.
+func (s *SlackNotifier) RunCompleted(ctx context.Context, rec RunRecord, _ []Result) error {
+	return postJSON(ctx, s.Client, s.WebhookURL, "slack notification", map[string]string{"text": runMessage(rec)})
+}
+
+// RegressionDetected posts each alert.
+func (s *SlackNotifier) RegressionDetected(ctx context.Context, rec RunRecord, _ []Result, alerts []DriftAlert) error {
+	return postJSON(ctx, s.Client, s.WebhookURL, "slack notification", map[string]string{"text": regressionMessage(rec, alerts)})
+}
+
+// EmailNotifier mails the RunReport of each event through an SMTP server,
+// as HTML with a Markdown alternative for plain-text mail readers.
+type EmailNotifier struct {
+	Addr string
+	From string
+	To   []string
+	Auth smtp.Auth
+
+	// send is smtp.SendMail, replaced in tests.
+	send func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func newEmailNotifier(cfg NotifierConfig, _ *http.Client) (Notifier, error) {
+	if cfg.SMTPAddr == "" || cfg.From == "" {
+		return nil, fmt.Errorf("matchspec: email notifier needs smtp_addr and from")
+	}
+	e := &EmailNotifier{Addr: cfg.SMTPAddr, From: cfg.From, To: cfg.To}
+	if cfg.PasswordEnv != "" {
+		host, _, _ := strings.Cut(cfg.SMTPAddr, ":")
+		e.Auth = smtp.PlainAuth("", cfg.Username, os.Getenv(cfg.PasswordEnv), host)
+	}
+	return e, nil
+}
+
+// RunCompleted mails the run's report.
+func (e *EmailNotifier) RunCompleted(ctx context.Context, rec RunRecord, results []Result) error {
+	return e.mail(NewRunReport(rec, results, nil))
+}
+
+// RegressionDetected mails the run's report with its regressions.
+func (e *EmailNotifier) RegressionDetected(ctx context.Context, rec RunRecord, results []Result, alerts []DriftAlert) error {
+	return e.mail(NewRunReport(rec, results, alerts))
+}
+
+// recipients returns To, or else the email contact of the run's suite
+// owner.
+func (e *EmailNotifier) recipients(rec RunRecord) []string {
+	if len(e.To) > 0 {
+		return e.To
+	}
+	if addr := rec.Owner.Email(); addr != "" {
+		return []string{addr}
+	}
+	return nil
+}
+
+// mail sends rep to its recipients. Runs with none, because the notifier
+// has no To and the suite no owner email, are not mailed.
+func (e *EmailNotifier) mail(rep RunReport) error {
+	to := e.recipients(rep.Run)
+	if len(to) == 0 {
+		return nil
+	}
+	var msg bytes.Buffer
+	mw := multipart.NewWriter(&msg)
+	fmt.Fprintf(&msg, "From: %s\r\nTo: %s\r\nSubject: %s\r\n", e.From, strings.Join(to, ", "), mime.QEncoding.Encode("utf-8", rep.Title()))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\nContent-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+	for _, part := range []struct{ typ, body string }{
+		{"text/plain", rep.Markdown()},
+		{"text/html", rep.HTML()},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.typ + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return fmt.Errorf("matchspec: email notification: %w", err)
+		}
+		qw := quotedprintable.NewWriter(w)
+		qw.Write([]byte(part.body))
+		qw.Close()
+	}
+	mw.Close()
+
+	send := e.send
+	if send == nil {
+		send = smtp.SendMail
+	}
+	if err := send(e.Addr, e.Auth, e.From, to, msg.Bytes()); err != nil {
+		return fmt.Errorf("matchspec: email notification: %w", err)
+	}
+	return nil
+}
+
+// runMessage summarizes rec in one line.
+func runMessage(rec RunRecord) string {
+	s := rec.Summary
+	msg := fmt.Sprintf("matchspec: suite %q", rec.Suite)
+	if rec.Model != "" {
+		msg += fmt.Sprintf(" on %s", rec.Model)
+	}
+	msg += fmt.Sprintf(": %d/%d passed (%.1f%%), mean score %.3f", s.Passed, s.Total, 100*s.PassRate, s.MeanScore)
+	if rec.Error != "" {
+		msg += "; error: " + rec.Error
+	}
+	if rec.Owner != nil {
+		msg += "; owner: " + rec.Owner.String()
+	}
+	return msg
+}
+
+// regressionMessage describes alerts, one per line.
+func regressionMessage(rec RunRecord, alerts []DriftAlert) string {
+	lines := []string{fmt.Sprintf("matchspec: run %s of suite %q regressed", rec.ID, rec.Suite)}
+	if rec.Owner != nil {
+		lines[0] += "; owner: " + rec.Owner.String()
+	}
+	for _, a := range alerts {
+		lines = append(lines, a.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// postJSON posts v as JSON to url. what names the request in errors.
+func postJSON(ctx context.Context, client *http.Client, url, what string, v any) error {
+	return sendJSON(ctx, client, url, what, nil, v)
+}
+
+// sendJSON is postJSON with extra request headers.
+func sendJSON(ctx context.Context, client *http.Client, url, what string, header http.Header, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("matchspec: %s: %w", what, err)
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("matchspec: %s: %w", what, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("matchspec: %s: %s returned %s", what, url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package matchspec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+// recordingNotifier records the events it is told about.
+type recordingNotifier struct {
+	mu          sync.Mutex
+	runs        []RunRecord
+	regressions [][]DriftAlert
+	err         error
+}
+
+func (n *recordingNotifier) RunCompleted(ctx context.Context, rec RunRecord, _ []Result) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.runs = append(n.runs, rec)
+	return n.err
+}
+
+func (n *recordingNotifier) RegressionDetected(ctx context.Context, rec RunRecord, _ []Result, alerts []DriftAlert) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.regressions = append(n.regressions, alerts)
+	return n.err
+}
+
+func TestRunnerNotifiers(t *testing.T) {
+	var broken atomic.Bool
+	infer := func(ctx context.Context, prompt string) (string, error) {
+		if broken.Load() && prompt == "What is 3*4?" {
+			return "11", nil
+		}
+		return map[string]string{"What is 2+2?": "4", "What is 3*4?": "12"}[prompt], nil
+	}
+	n := &recordingNotifier{}
+	failing := &recordingNotifier{err: errors.New("down")}
+	runner := NewRunner(driftRegistry(), infer, tokentrace.NewReporter("matchspec", ""),
+		WithNotifier(n), WithNotifier(failing), WithRegressionCheck(DriftConfig{MinRuns: 2, MaxPassRateDelta: 0.2}))
+	run := protocol.EvalRun{Suite: "math", Tags: map[string]string{"model": "m"}}
+
+	for range 2 {
+		runner.Run(context.Background(), run)
+	}
+	if len(n.runs) != 2 || len(n.regressions) != 0 {
+		t.Fatalf("after stable runs: %d runs, %d regressions", len(n.runs), len(n.regressions))
+	}
+	broken.Store(true)
+	runner.Run(context.Background(), run)
+	if len(n.runs) != 3 || len(n.regressions) != 1 || n.regressions[0][0].Metric != "pass_rate" {
+		t.Fatalf("after regression: %d runs, regressions %+v", len(n.runs), n.regressions)
+	}
+
+	// Improvements are not regressions.
+	broken.Store(false)
+	runner.Run(context.Background(), run)
+	if len(n.regressions) != 1 {
+		t.Errorf("improvement reported as regression: %+v", n.regressions)
+	}
+	if got := runner.NotifyErrors(); got != 4 {
+		t.Errorf("NotifyErrors = %d, want 4", got)
+	}
+
+	// Offline scoring notifies too.
+	runner.Score(context.Background(), "math", []TaskResponse{{Task: "add", Response: "4"}})
+	if len(n.runs) != 5 {
+		t.Errorf("runs after Score = %d", len(n.runs))
+	}
+}
+
+func TestWebhookAndSlackNotifiers(t *testing.T) {
+	var bodies []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+	}))
+	defer srv.Close()
+
+	rec := RunRecord{ID: "r1", Suite: "math", Model: "m", Summary: Summary{Total: 2, Passed: 1, PassRate: 0.5, MeanScore: 0.5}}
+	alerts := []DriftAlert{{Suite: "math", Metric: "pass_rate", Value: 0.5, Baseline: 1, Delta: -0.5, BaselineRuns: 3}}
+	for _, typ := range []string{"webhook", "slack"} {
+		n, err := NewNotifier(NotifierConfig{Type: typ, URL: srv.URL}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := n.RunCompleted(context.Background(), rec, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := n.RegressionDetected(context.Background(), rec, nil, alerts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(bodies) != 4 {
+		t.Fatalf("bodies = %v", bodies)
+	}
+	if bodies[0]["event"] != "run_completed" || bodies[1]["event"] != "regression_detected" || bodies[1]["alerts"] == nil {
+		t.Errorf("webhook bodies = %v", bodies[:2])
+	}
+	if text, _ := bodies[2]["text"].(string); !strings.Contains(text, `suite "math" on m: 1/2 passed (50.0%)`) {
+		t.Errorf("slack run text = %q", text)
+	}
+	if text, _ := bodies[3]["text"].(string); !strings.Contains(text, "pass_rate 0.500 is -0.500") {
+		t.Errorf("slack regression text = %q", text)
+	}
+}
+
+func TestEmailNotifier(t *testing.T) {
+	t.Setenv("MATCHSPEC_TEST_SMTP_PASSWORD", "hunter2")
+	n, err := NewNotifier(NotifierConfig{
+		Type: "email", SMTPAddr: "smtp.example.com:587", From: "evals@example.com",
+		To: []string{"a@example.com", "b@example.com"}, Username: "evals", PasswordEnv: "MATCHSPEC_TEST_SMTP_PASSWORD",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := n.(*EmailNotifier)
+	if e.Auth == nil {
+		t.Error("no SMTP auth")
+	}
+	var addr string
+	var to []string
+	var msg []byte
+	e.send = func(a string, _ smtp.Auth, from string, rcpt []string, m []byte) error {
+		addr, to, msg = a, rcpt, m
+		return nil
+	}
+	rec := RunRecord{ID: "r1", Suite: "math", Summary: Summary{Total: 2, Passed: 1}}
+	results := []Result{{EvalResult: protocol.EvalResult{Task: "add", Passed: true}}, {EvalResult: protocol.EvalResult{Task: "mul", Error: "timeout"}}}
+	if err := e.RegressionDetected(context.Background(), rec, results, []DriftAlert{{Suite: "math", Metric: "mean_score"}}); err != nil {
+		t.Fatal(err)
+	}
+	if addr != "smtp.example.com:587" || len(to) != 2 {
+		t.Errorf("sent to %s %v", addr, to)
+	}
+	m, err := mail.ReadMessage(bytes.NewReader(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if subject := m.Header.Get("Subject"); subject != `matchspec: suite "math" regressed: 1/2 passed` {
+		t.Errorf("subject = %q", subject)
+	}
+	_, params, _ := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	mr := multipart.NewReader(m.Body, params["boundary"])
+	var types []string
+	for {
+		p, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		body, _ := io.ReadAll(p)
+		types = append(types, p.Header.Get("Content-Type"))
+		if !strings.Contains(string(body), "mul") || !strings.Contains(string(body), "mean_score") {
+			t.Errorf("%s part = %s", p.Header.Get("Content-Type"), body)
+		}
+	}
+	if len(types) != 2 || !strings.HasPrefix(types[0], "text/plain") || !strings.HasPrefix(types[1], "text/html") {
+		t.Errorf("parts = %v", types)
+	}
+
+	if _, err := NewNotifier(NotifierConfig{Type: "email", SMTPAddr: "x:25"}, nil); err == nil {
+		t.Error("expected error for email notifier without recipients")
+	}
+}
+
+func TestRegisterNotifier(t *testing.T) {
+	n := &recordingNotifier{}
+	RegisterNotifier("test-recording", func(cfg NotifierConfig, _ *http.Client) (Notifier, error) {
+		if cfg.Options["channel"] != "evals" {
+			return nil, errors.New("missing channel")
+		}
+		return n, nil
+	})
+	got, err := NewNotifier(NotifierConfig{Type: "test-recording", Options: map[string]any{"channel": "evals"}}, nil)
+	if err != nil || got != n {
+		t.Errorf("NewNotifier = %v, %v", got, err)
+	}
+	got, err = NewNotifier(NotifierConfig{Type: "test-recording", On: NotifyRegression, Options: map[string]any{"channel": "evals"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got.RunCompleted(context.Background(), RunRecord{}, nil)
+	got.RegressionDetected(context.Background(), RunRecord{}, nil, []DriftAlert{{}})
+	if len(n.runs) != 0 || len(n.regressions) != 1 {
+		t.Errorf("on regression: %d runs, %d regressions", len(n.runs), len(n.regressions))
+	}
+	if _, err := NewNotifier(NotifierConfig{Type: "test-recording", On: "weekly"}, nil); err == nil {
+		t.Error("expected error for unknown on")
+	}
+
+	if _, err := NewNotifier(NotifierConfig{Type: "pager"}, nil); err == nil || !strings.Contains(err.Error(), "webhook") {
+		t.Errorf("unknown type err = %v", err)
+	}
+}
+
+func TestLoadConfigNotifiers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "matchspec.yaml")
+	os.WriteFile(path, []byte(`notifiers:
+  - type: slack
+    url: https://hooks.example.com/x
+regression:
+  max_pass_rate_delta: 0.05
+  min_runs: 5
+`), 0o644)
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Notifiers) != 1 || c.Notifiers[0].Type != "slack" || c.Regression == nil || c.Regression.MaxPassRateDelta != 0.05 || c.Regression.MinRuns != 5 {
+		t.Errorf("config = %+v, regression %+v", c, c.Regression)
+	}
+	opts, err := c.NotifierOptions(nil)
+	if err != nil || len(opts) != 2 {
+		t.Errorf("NotifierOptions = %d options, %v", len(opts), err)
+	}
+}
@@ -0,0 +1,98 @@
+package matchspec
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var numberPattern = regexp.MustCompile(`[-+]?(?:\d+\.?\d*|\.\d+)(?:[eE][-+]?\d+)?`)
+
+// ParseNumbers returns every number in s, in order. Thousands separators
+// are not recognized, so "1,200" is read as 1 and 200.
+func ParseNumbers(s string) []float64 {
+	var out []float64
+	for _, m := range numberPattern.FindAllString(s, -1) {
+		if f, err := strconv.ParseFloat(m, 64); err == nil {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// matchNumbers compares the numbers in the response element-wise with the
+// numbers in Expected, each within the task's Tolerance. With Unordered
+// set, both lists are compared as sorted multisets. The score is the
+// fraction of positions that match, out of the longer list.
+func matchNumbers(t *Task, response string) (bool, float64) {
+	want, got := ParseNumbers(t.Expected), ParseNumbers(response)
+	n := max(len(want), len(got))
+	if n == 0 {
+		return true, 1.0
+	}
+	if t.Unordered {
+		slices.Sort(want)
+		slices.Sort(got)
+	}
+	matched := 0
+	for i := range min(len(want), len(got)) {
+		if withinTolerance(t, want[i], got[i]) {
+			matched++
+		}
+	}
+	return matched == n, float64(matched) / float64(n)
+}
+
+// thousandsPattern matches a digit followed by a comma-separated group of
+// three digits, as in "1,200".
+var thousandsPattern = regexp.MustCompile(`(\d),(\d{3})\b`)
+
+// LastNumber returns the last number in s, the usual place for the final
+// answer of a worked solution. Unlike ParseNumbers, it reads thousands
+// separators, so "1,200" is 1200.
+func LastNumber(s string) (float64, bool) {
+	for {
+		joined := thousandsPattern.ReplaceAllString(s, "$1$2")
+		if joined == s {
+			break
+		}
+		s = joined
+	}
+	nums := ParseNumbers(s)
+	if len(nums) == 0 {
+		return 0, false
+	}
+	return nums[len(nums)-1], true
+}
+
+// matchNumeric compares the last number in the response with Expected,
+// parsed as a number, within the task's Tolerance or RelTolerance.
+func matchNumeric(t *Task, response string) (bool, float64) {
+	want, err := strconv.ParseFloat(strings.TrimSpace(t.Expected), 64)
+	if err != nil {
+		return false, 0.0
+	}
+	got, ok := LastNumber(response)
+	return boolScore(ok && withinTolerance(t, want, got))
+}
+
+// withinTolerance reports whether got equals want within the task's
+// absolute Tolerance or its RelTolerance of want, whichever is larger.
+func withinTolerance(t *Task, want, got float64) bool {
+	return math.Abs(want-got) <= max(t.Tolerance, t.RelTolerance*math.Abs(want))
+}
+
+// validateNumeric checks that a "numeric" task's Expected is a number.
+// Templated values are checked when rendered.
+func validateNumeric(suite string, t Task) error {
+	if strings.Contains(t.Expected, "{{") {
+		return nil
+	}
+	if _, err := strconv.ParseFloat(strings.TrimSpace(t.Expected), 64); err != nil {
+		return fmt.Errorf("matchspec: suite %q task %q expected %q is not a number", suite, t.Name, t.Expected)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package matchspec
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseNumbers(t *testing.T) {
+	got := ParseNumbers("Values: [1, -2.5, .75, 3e2] and 10.")
+	want := []float64{1, -2.5, 0.75, 300, 10}
+	if !slices.Equal(got, want) {
+		t.Errorf("ParseNumbers = %v, want %v", got, want)
+	}
+}
+
+func TestTaskMatchNumbers(t *testing.T) {
+	task := Task{Name: "t", Prompt: "p", Matcher: "numbers", Expected: "1, 2, 3", Tolerance: 0.01}
+	tests := []struct {
+		response  string
+		unordered bool
+		wantPass  bool
+		wantScore float64
+	}{
+		{"The values are 1.0, 2.001 and 3.", false, true, 1},
+		{"[1, 2, 3.5]", false, false, 2.0 / 3},
+		{"3, 1, 2", false, false, 0},
+		{"3, 1, 2", true, true, 1},
+		{"1, 2", false, false, 2.0 / 3},
+		{"1, 2, 3, 4", false, false, 0.75},
+	}
+	for _, tt := range tests {
+		task.Unordered = tt.unordered
+		passed, score := task.Match(tt.response)
+		if passed != tt.wantPass || score != tt.wantScore {
+			t.Errorf("Match(%q, unordered=%v) = %v, %f; want %v, %f", tt.response, tt.unordered, passed, score, tt.wantPass, tt.wantScore)
+		}
+	}
+}
+
+func TestTaskMatchNumeric(t *testing.T) {
+	tests := []struct {
+		expected   string
+		abs, rel   float64
+		response   string
+		wantPassed bool
+	}{
+		{"42", 0, 0, "First 7, then 6, so the answer is 42.", true},
+		{"42", 0, 0, "42 apples, minus 1 leaves 41", false},
+		{"1250", 0, 0, "The total is 1,250.", true},
+		{"1200300", 0, 0, "1,200,300", true},
+		{"3.14", 0.01, 0, "pi is about 3.1416", true},
+		{"100", 0, 0.05, "about 104", true},
+		{"100", 0, 0.05, "about 106", false},
+		{"100", 10, 0.05, "about 106", true},
+		{" -2.5 ", 0, 0, "x = -2.5", true},
+		{"7", 0, 0, "no number here", false},
+		{"seven", 0, 0, "7", false},
+	}
+	for _, tt := range tests {
+		task := Task{Matcher: "numeric", Expected: tt.expected, Tolerance: tt.abs, RelTolerance: tt.rel}
+		passed, score := task.Match(tt.response)
+		if wantPassed, wantScore := boolScore(tt.wantPassed); passed != wantPassed || score != wantScore {
+			t.Errorf("Match(%q, %q, abs=%v, rel=%v) = %v, %f; want %v", tt.expected, tt.response, tt.abs, tt.rel, passed, score, tt.wantPassed)
+		}
+	}
+}
+
+func TestValidateNumericExpected(t *testing.T) {
+	s := &Suite{Name: "s", Tasks: []Task{{Name: "t", Prompt: "p", Expected: "seven", Matcher: "numeric"}}}
+	if err := s.Validate(); err == nil {
+		t.Error("expected error for non-numeric expected")
+	}
+	s.Tasks[0].Expected = "{{.n}}"
+	if err := s.Validate(); err != nil {
+		t.Errorf("templated expected: %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package matchspec
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+)
+
+// SuiteOwner is the team or person responsible for a suite.
+type SuiteOwner struct {
+	// Name is the owning team or person, such as "search-quality".
+	Name string `json:"name,omitempty"`
+
+	// Contact is where to reach them: an email address, which email
+	// notifiers without recipients send to, or a chat channel or handle.
+	Contact string `json:"contact,omitempty"`
+
+	// URL links to the suite's runbook, dashboard, or docs.
+	URL string `json:"url,omitempty"`
+}
+
+// Email returns the owner's contact address, or "" if Contact is not an
+// email address.
+func (o *SuiteOwner) Email() string {
+	if o == nil {
+		return ""
+	}
+	addr, err := mail.ParseAddress(o.Contact)
+	if err != nil {
+		return ""
+	}
+	return addr.Address
+}
+
+// String describes the owner in one line, such as
+// "search-quality <sq@example.com>".
+func (o *SuiteOwner) String() string {
+	if o == nil {
+		return ""
+	}
+	parts := make([]string, 0, 3)
+	for _, s := range []string{o.Name, o.Contact} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	if len(parts) == 2 {
+		parts[1] = "<" + parts[1] + ">"
+	}
+	if o.URL != "" {
+		parts = append(parts, o.URL)
+	}
+	return strings.Join(parts, " ")
+}
+
+func (o *SuiteOwner) validate(suite string) error {
+	if o.Name == "" && o.Contact == "" {
+		return fmt.Errorf("matchspec: suite %q: owner needs a name or contact", suite)
+	}
+	if o.URL != "" {
+		if u, err := url.Parse(o.URL); err != nil || !u.IsAbs() {
+			return fmt.Errorf("matchspec: suite %q: owner url %q is not an absolute URL", suite, o.URL)
+		}
+	}
+	return nil
+}
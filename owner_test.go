@@ -0,0 +1,114 @@
+package matchspec
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func ownedRegistry() *SuiteRegistry {
+	reg := driftRegistry()
+	s, _ := reg.Get("math")
+	s.Owner = &SuiteOwner{Name: "arith", Contact: "Arith Team <arith@example.com>", URL: "https://wiki.example.com/arith"}
+	return reg
+}
+
+func TestLoadSuiteFileOwner(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "owned.yaml")
+	os.WriteFile(path, []byte(`name: owned
+owner:
+  name: search-quality
+  contact: "#search-evals"
+  url: https://runbooks.example.com/search
+tasks:
+  - {name: t, prompt: p, expected: e}
+`), 0o644)
+	s, err := LoadSuiteFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Owner == nil || s.Owner.Name != "search-quality" || s.Owner.Email() != "" {
+		t.Errorf("owner = %+v", s.Owner)
+	}
+	if got := s.Owner.String(); got != "search-quality <#search-evals> https://runbooks.example.com/search" {
+		t.Errorf("String = %q", got)
+	}
+
+	bad := &Suite{Name: "s", Tasks: []Task{{Name: "t", Prompt: "p", Expected: "e"}}, Owner: &SuiteOwner{Name: "x", URL: "runbooks/x"}}
+	if err := bad.Validate(); err == nil || !strings.Contains(err.Error(), "owner url") {
+		t.Errorf("relative url: %v", err)
+	}
+	bad.Owner = &SuiteOwner{URL: "https://x"}
+	if err := bad.Validate(); err == nil {
+		t.Error("owner without name or contact accepted")
+	}
+}
+
+func TestSuiteHashIgnoresOwner(t *testing.T) {
+	s := &Suite{Name: "s", Tasks: []Task{{Name: "t", Prompt: "p", Expected: "e"}}}
+	before := SuiteHash(s)
+	s.Owner = &SuiteOwner{Name: "team"}
+	if SuiteHash(s) != before {
+		t.Error("changing the owner changed the suite hash")
+	}
+}
+
+func TestRunRecordOwner(t *testing.T) {
+	reg := ownedRegistry()
+	runner := NewRunner(reg, func(ctx context.Context, prompt string) (string, error) { return "4", nil }, tokentrace.NewReporter("matchspec", ""))
+	runner.Run(context.Background(), protocol.EvalRun{Suite: "math"})
+	runs, _ := runner.Runs(RunFilter{})
+	if len(runs) != 1 || runs[0].Owner == nil || runs[0].Owner.Name != "arith" {
+		t.Fatalf("runs = %+v", runs)
+	}
+	rep := NewRunReport(runs[0], nil, nil)
+	if md := rep.Markdown(); !strings.Contains(md, "| Owner | arith <Arith Team <arith@example.com>> https://wiki.example.com/arith |") {
+		t.Errorf("report:\n%s", md)
+	}
+	if msg := runMessage(runs[0]); !strings.Contains(msg, "owner: arith") {
+		t.Errorf("message = %q", msg)
+	}
+
+	h := NewHandler(runner, reg)
+	for query, want := range map[string]int{"": 1, "?owner=arith": 1, "?owner=other": 0} {
+		w := httptest.NewRecorder()
+		h.Suites(w, httptest.NewRequest(http.MethodGet, "/suites"+query, nil))
+		var resp SuitesResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		if len(resp.Suites) != want || (want == 1 && resp.Suites[0].Owner.URL != "https://wiki.example.com/arith") {
+			t.Errorf("GET /suites%s = %+v", query, resp.Suites)
+		}
+	}
+}
+
+func TestEmailNotifierOwnerRecipient(t *testing.T) {
+	n, err := NewNotifier(NotifierConfig{Type: "email", SMTPAddr: "smtp.example.com:25", From: "evals@example.com"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := n.(*EmailNotifier)
+	var to [][]string
+	e.send = func(_ string, _ smtp.Auth, _ string, rcpt []string, _ []byte) error {
+		to = append(to, rcpt)
+		return nil
+	}
+	owned := RunRecord{ID: "r1", Suite: "math", Owner: &SuiteOwner{Name: "arith", Contact: "Arith Team <arith@example.com>"}}
+	unowned := RunRecord{ID: "r2", Suite: "other", Owner: &SuiteOwner{Name: "x", Contact: "#x"}}
+	for _, rec := range []RunRecord{owned, unowned, {ID: "r3", Suite: "none"}} {
+		if err := e.RunCompleted(context.Background(), rec, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(to) != 1 || len(to[0]) != 1 || to[0][0] != "arith@example.com" {
+		t.Errorf("recipients = %v", to)
+	}
+}
@@ -0,0 +1,131 @@
+package matchspec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/greynewell/mist-go/protocol"
+)
+
+// Model pin mismatch policies.
+const (
+	PinFail = "fail"
+	PinWarn = "warn"
+)
+
+// ErrModelPinMismatch is returned by runs whose backend does not report
+// the pinned model.
+var ErrModelPinMismatch = errors.New("matchspec: backend model does not match pin")
+
+// ModelPin is the model version a run's backend must report serving, so
+// that a provider swapping the model behind an alias does not silently
+// corrupt comparisons between runs.
+type ModelPin struct {
+	// Model is the model version the backend must report, such as
+	// "gpt-4o-2024-08-06", or a path.Match pattern such as
+	// "claude-3-5-sonnet-*". Provider, if set, must match exactly.
+	Model    string `json:"model"`
+	Provider string `json:"provider,omitempty"`
+
+	// OnMismatch is PinFail (the default) to refuse to run, or PinWarn to
+	// run anyway and record a warning on the run record.
+	OnMismatch string `json:"on_mismatch,omitempty"`
+}
+
+// Validate checks the pin's pattern and policy.
+func (p ModelPin) Validate() error {
+	if p.Model == "" {
+		return fmt.Errorf("matchspec: model pin has no model")
+	}
+	if _, err := path.Match(p.Model, ""); err != nil {
+		return fmt.Errorf("matchspec: model pin %q: %w", p.Model, err)
+	}
+	switch p.OnMismatch {
+	case "", PinFail, PinWarn:
+		return nil
+	}
+	return fmt.Errorf("matchspec: model pin on_mismatch %q must be %q or %q", p.OnMismatch, PinFail, PinWarn)
+}
+
+// Matches reports whether b is the pinned model.
+func (p ModelPin) Matches(b BackendModel) bool {
+	if p.Provider != "" && b.Provider != p.Provider {
+		return false
+	}
+	ok, _ := path.Match(p.Model, b.Model)
+	return ok
+}
+
+// ModelProbe asks a backend which model it serves for calls made with
+// ctx, which carries the run's InferOptions.
+type ModelProbe func(ctx context.Context) (BackendModel, error)
+
+// InferMuxModelProbe returns a ModelProbe that sends a one-token request
+// to an InferMux server and reads the provider and model from its
+// response.
+func InferMuxModelProbe(baseURL, model string, client *http.Client) ModelProbe {
+	endpoint := strings.TrimRight(baseURL, "/") + "/infer"
+	if model == "" {
+		model = "auto"
+	}
+	return func(ctx context.Context) (BackendModel, error) {
+		req := protocol.InferRequest{
+			Model:    model,
+			Messages: []protocol.ChatMessage{{Role: "user", Content: "ping"}},
+			Params:   map[string]any{"max_tokens": 1},
+		}
+		if opts, ok := InferOptionsFrom(ctx); ok && opts.Model != "" {
+			req.Model = opts.Model
+		}
+		out, err := postInfer(ctx, client, endpoint, req)
+		if err != nil {
+			return BackendModel{}, err
+		}
+		return BackendModel{Provider: out.Provider, Model: out.Model, Calls: 1}, nil
+	}
+}
+
+// WithModelPin probes the backend with probe before every run and checks
+// the model it reports against pin. On a mismatch, or if the probe fails,
+// the run fails with ErrModelPinMismatch before any task runs, or with
+// PinWarn, runs with a warning on its record.
+func WithModelPin(pin ModelPin, probe ModelProbe) RunnerOption {
+	return func(r *Runner) {
+		r.modelPin = &pin
+		r.modelProbe = probe
+	}
+}
+
+// checkModelPin probes the backend and returns a warning, or an error if
+// the run must not start.
+func (r *Runner) checkModelPin(ctx context.Context) (string, error) {
+	if r.modelPin == nil {
+		return "", nil
+	}
+	pin := *r.modelPin
+	got, err := r.modelProbe(ctx)
+	var msg string
+	switch {
+	case err != nil:
+		msg = fmt.Sprintf("cannot check model pin %q: %v", pin.Model, err)
+	case !pin.Matches(got):
+		msg = fmt.Sprintf("backend reports model %q", got.Model)
+		if got.Provider != "" {
+			msg += fmt.Sprintf(" from %q", got.Provider)
+		}
+		msg += fmt.Sprintf(", not pinned %q", pin.Model)
+		if pin.Provider != "" {
+			msg += fmt.Sprintf(" from %q", pin.Provider)
+		}
+	default:
+		return "", nil
+	}
+	if pin.OnMismatch == PinWarn {
+		return r.redact(msg), nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrModelPinMismatch, r.redact(msg))
+}
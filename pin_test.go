@@ -0,0 +1,122 @@
+package matchspec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestModelPinMatches(t *testing.T) {
+	pin := ModelPin{Model: "claude-3-5-sonnet-*", Provider: "anthropic"}
+	tests := []struct {
+		b    BackendModel
+		want bool
+	}{
+		{BackendModel{Provider: "anthropic", Model: "claude-3-5-sonnet-20241022"}, true},
+		{BackendModel{Provider: "anthropic", Model: "claude-3-haiku-20240307"}, false},
+		{BackendModel{Provider: "bedrock", Model: "claude-3-5-sonnet-20241022"}, false},
+	}
+	for _, tt := range tests {
+		if got := pin.Matches(tt.b); got != tt.want {
+			t.Errorf("Matches(%+v) = %v, want %v", tt.b, got, tt.want)
+		}
+	}
+	for _, bad := range []ModelPin{{}, {Model: "["}, {Model: "m", OnMismatch: "ignore"}} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v): expected error", bad)
+		}
+	}
+}
+
+func TestRunnerModelPin(t *testing.T) {
+	var probes, tasks atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req protocol.InferRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Messages[0].Content == "ping" {
+			probes.Add(1)
+			if req.Model != "gpt-x" || req.Params["max_tokens"] != 1.0 {
+				t.Errorf("probe request = %+v", req)
+			}
+		} else {
+			tasks.Add(1)
+		}
+		json.NewEncoder(w).Encode(protocol.InferResponse{Provider: "openai", Model: "gpt-x-2024-08", Content: "4"})
+	}))
+	defer srv.Close()
+
+	reg := driftRegistry()
+	probe := InferMuxModelProbe(srv.URL, "auto", srv.Client())
+	run := protocol.EvalRun{Suite: "math", Tags: map[string]string{"model": "gpt-x"}}
+	newRunner := func(pin ModelPin) *Runner {
+		return NewRunner(reg, InferMuxFunc(srv.URL, "gpt-x"), tokentrace.NewReporter("matchspec", ""), WithModelPin(pin, probe))
+	}
+
+	// Matching pin: the run proceeds without warnings.
+	runner := newRunner(ModelPin{Model: "gpt-x-2024-*"})
+	if _, err := runner.Run(context.Background(), run); err != nil {
+		t.Fatal(err)
+	}
+	if runs, _ := runner.Runs(RunFilter{}); len(runs[0].Warnings) != 0 {
+		t.Errorf("warnings = %v", runs[0].Warnings)
+	}
+
+	// Mismatch: the run fails before any task.
+	tasks.Store(0)
+	runner = newRunner(ModelPin{Model: "gpt-x-2024-06"})
+	results, err := runner.Run(context.Background(), run)
+	if !errors.Is(err, ErrModelPinMismatch) || !strings.Contains(err.Error(), `"gpt-x-2024-08"`) {
+		t.Fatalf("err = %v", err)
+	}
+	if len(results) != 0 || tasks.Load() != 0 {
+		t.Errorf("ran %d tasks despite mismatch", tasks.Load())
+	}
+
+	// Mismatch with PinWarn: the run proceeds with a warning.
+	runner = newRunner(ModelPin{Model: "gpt-x-2024-06", OnMismatch: PinWarn})
+	if _, err := runner.Run(context.Background(), run); err != nil {
+		t.Fatal(err)
+	}
+	runs, _ := runner.Runs(RunFilter{})
+	if len(runs[0].Warnings) != 1 || !strings.Contains(runs[0].Warnings[0], "not pinned") {
+		t.Errorf("warnings = %v", runs[0].Warnings)
+	}
+	if probes.Load() != 3 {
+		t.Errorf("probes = %d, want one per run", probes.Load())
+	}
+}
+
+func TestModelPinProbeError(t *testing.T) {
+	probe := func(ctx context.Context) (BackendModel, error) { return BackendModel{}, errors.New("unreachable") }
+	runner := NewRunner(driftRegistry(), echoInfer, tokentrace.NewReporter("matchspec", ""), WithModelPin(ModelPin{Model: "m"}, probe))
+	if _, err := runner.Run(context.Background(), protocol.EvalRun{Suite: "math"}); !errors.Is(err, ErrModelPinMismatch) {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestLoadConfigModelPin(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "matchspec.yaml")
+	os.WriteFile(path, []byte("model_pin:\n  model: gpt-x-2024-08\n  on_mismatch: warn\n"), 0o644)
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ModelPin == nil || c.ModelPin.Model != "gpt-x-2024-08" || c.ModelPin.OnMismatch != PinWarn {
+		t.Errorf("pin = %+v", c.ModelPin)
+	}
+	os.WriteFile(path, []byte("model_pin:\n  model: m\n  on_mismatch: maybe\n"), 0o644)
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("expected error for unknown on_mismatch")
+	}
+}
@@ -0,0 +1,122 @@
+//go:build postgres
+
+package matchspec
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// openPostgres connects to the database named by
+// MATCHSPEC_TEST_POSTGRES_DSN, skipping the test if it is unset, and drops
+// matchspec's tables so each test starts from an empty schema. Point it at
+// a throwaway database; CI runs a Postgres service container for it.
+func openPostgres(t *testing.T) *sql.DB {
+	t.Helper()
+	db := connectPostgres(t)
+	if _, err := db.Exec(`DROP TABLE IF EXISTS matchspec_jobs, matchspec_runs, matchspec_schema_migrations`); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// connectPostgres opens another connection pool to the test database, as
+// a second replica would.
+func connectPostgres(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("MATCHSPEC_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("MATCHSPEC_TEST_POSTGRES_DSN is not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestPostgresDialectResultStore(t *testing.T) {
+	ctx := context.Background()
+	db := openPostgres(t)
+	store, err := NewPostgresResultStore(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testResultStore(t, store)
+}
+
+func TestPostgresDialectConcurrentMigrations(t *testing.T) {
+	ctx := context.Background()
+	db := openPostgres(t)
+	// Replicas starting together take turns under the advisory lock, so
+	// each either migrates the schema or finds it current.
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for range 4 {
+		replica := connectPostgres(t)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := NewPostgresResultStore(ctx, replica)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	var version int
+	if err := db.QueryRow(`SELECT MAX(version) FROM matchspec_schema_migrations`).Scan(&version); err != nil || version != len(sqlStoreMigrations) {
+		t.Errorf("schema version = %d, %v", version, err)
+	}
+}
+
+func TestPostgresDialectJobQueue(t *testing.T) {
+	ctx := context.Background()
+	db := openPostgres(t)
+	store, err := NewPostgresResultStore(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := store.JobQueue()
+	q.MaxQueued = 100
+	testJobQueue(t, q)
+}
+
+func TestPostgresDialectChanges(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewPostgresResultStore(ctx, openPostgres(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Append(ctx, Checkpoint{Version: CheckpointVersion, Record: RunRecord{ID: "old"}})
+	since := time.Now()
+	store.Append(ctx, Checkpoint{Version: CheckpointVersion, Record: RunRecord{ID: "new"}})
+	cps, err := store.Changes(ctx, since)
+	if err != nil || len(cps) != 1 || cps[0].Record.ID != "new" {
+		t.Errorf("Changes = %+v, %v", cps, err)
+	}
+}
+
+func TestPostgresDialectJobQueueSharedBetweenReplicas(t *testing.T) {
+	ctx := context.Background()
+	a, err := NewPostgresResultStore(ctx, openPostgres(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewPostgresResultStore(ctx, connectPostgres(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSharedJobQueue(t, a.JobQueue(), b.JobQueue())
+}
@@ -0,0 +1,144 @@
+package matchspec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+)
+
+// Preflight check outcomes reported by PreflightResult.
+const (
+	PreflightOK   = "ok"
+	PreflightFail = "fail"
+	PreflightSkip = "skip" // not run because a check it depends on failed
+)
+
+// ErrPreflightSkipped is wrapped by the errors of checks that could not
+// run, such as a store check after the config failed to load.
+var ErrPreflightSkipped = errors.New("skipped")
+
+// PreflightCheck is one step of checking a server's configuration before
+// it is deployed. Check returns a short description of what it found.
+type PreflightCheck struct {
+	Name  string
+	Check func(ctx context.Context) (string, error)
+}
+
+// PreflightResult is the outcome of one PreflightCheck.
+type PreflightResult struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	LatencyMS int64  `json:"latency_ms"`
+}
+
+// PreflightReport is the outcome of RunPreflight.
+type PreflightReport struct {
+	Results []PreflightResult `json:"results"`
+
+	// OK is true if no check failed. Skipped checks do not count, since a
+	// failed check is what skipped them.
+	OK bool `json:"ok"`
+}
+
+// RunPreflight runs checks in order, each with the given timeout (none if
+// timeout <= 0), and reports every outcome. Checks may close over values
+// set by earlier ones.
+func RunPreflight(ctx context.Context, timeout time.Duration, checks ...PreflightCheck) PreflightReport {
+	report := PreflightReport{OK: true}
+	for _, c := range checks {
+		checkCtx, cancel := ctx, context.CancelFunc(func() {})
+		if timeout > 0 {
+			checkCtx, cancel = context.WithTimeout(ctx, timeout)
+		}
+		start := time.Now()
+		detail, err := c.Check(checkCtx)
+		cancel()
+		res := PreflightResult{Name: c.Name, Status: PreflightOK, Detail: detail, LatencyMS: time.Since(start).Milliseconds()}
+		switch {
+		case errors.Is(err, ErrPreflightSkipped):
+			res.Status, res.Detail = PreflightSkip, err.Error()
+		case err != nil:
+			res.Status, res.Detail = PreflightFail, err.Error()
+			report.OK = false
+		}
+		report.Results = append(report.Results, res)
+	}
+	return report
+}
+
+// DialCheck returns a HealthCheck that opens a TCP connection to addr, a
+// host:port, and closes it. It checks that a service is reachable without
+// sending it anything.
+func DialCheck(addr string) HealthCheck {
+	return func(ctx context.Context) error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
+
+// URLAddr returns the host:port an http or https URL connects to.
+func URLAddr(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	port := u.Port()
+	switch {
+	case u.Hostname() == "":
+		return "", fmt.Errorf("matchspec: url %q has no host", rawURL)
+	case port != "":
+	case u.Scheme == "http":
+		port = "80"
+	case u.Scheme == "https":
+		port = "443"
+	default:
+		return "", fmt.Errorf("matchspec: url %q is not http or https", rawURL)
+	}
+	return net.JoinHostPort(u.Hostname(), port), nil
+}
+
+// Endpoint returns the host:port the notifier connects to, or "" if it is
+// not known from the config, as for incident notifiers using their
+// default API endpoint.
+func (c NotifierConfig) Endpoint() (string, error) {
+	switch {
+	case c.SMTPAddr != "":
+		return c.SMTPAddr, nil
+	case c.URL != "":
+		return URLAddr(c.URL)
+	}
+	return "", nil
+}
+
+// PreflightStore returns a check that opens the result store cfg
+// describes and queries it, reporting how many live runs it holds and how
+// many writes wait in its spool. The store is closed afterwards if it has
+// a Close method.
+func PreflightStore(cfg ResultStoreConfig) PreflightCheck {
+	return PreflightCheck{Name: "store " + cfg.Type, Check: func(ctx context.Context) (string, error) {
+		s, err := OpenResultStore(ctx, cfg)
+		if err != nil {
+			return "", err
+		}
+		if c, ok := s.(interface{ Close() error }); ok {
+			defer c.Close()
+		}
+		_, total, err := s.Query(ctx, RunFilter{Limit: 1})
+		if err != nil {
+			return "", err
+		}
+		detail := fmt.Sprintf("%d runs", total)
+		if spool, ok := s.(*SpoolResultStore); ok {
+			detail += fmt.Sprintf(", %d writes spooled", spool.Status().Pending)
+		}
+		return detail, nil
+	}}
+}
@@ -0,0 +1,97 @@
+package matchspec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunPreflight(t *testing.T) {
+	var loaded bool
+	report := RunPreflight(context.Background(), time.Second,
+		PreflightCheck{Name: "config", Check: func(ctx context.Context) (string, error) {
+			return "", errors.New("bad yaml")
+		}},
+		PreflightCheck{Name: "store", Check: func(ctx context.Context) (string, error) {
+			if !loaded {
+				return "", fmt.Errorf("%w: config failed", ErrPreflightSkipped)
+			}
+			return "ok", nil
+		}},
+		PreflightCheck{Name: "slow", Check: func(ctx context.Context) (string, error) {
+			if _, ok := ctx.Deadline(); !ok {
+				return "", errors.New("no timeout")
+			}
+			return "fine", nil
+		}},
+	)
+	if report.OK {
+		t.Error("report OK despite a failed check")
+	}
+	var got []string
+	for _, r := range report.Results {
+		got = append(got, r.Name+"="+r.Status+":"+r.Detail)
+	}
+	want := "config=fail:bad yaml,store=skip:skipped: config failed,slow=ok:fine"
+	if strings.Join(got, ",") != want {
+		t.Errorf("results = %v", got)
+	}
+
+	if ok := RunPreflight(context.Background(), 0).OK; !ok {
+		t.Error("empty report not OK")
+	}
+}
+
+func TestDialCheck(t *testing.T) {
+	srv := httptest.NewServer(nil)
+	addr, err := URLAddr(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := DialCheck(addr)(context.Background()); err != nil {
+		t.Errorf("dial %s: %v", addr, err)
+	}
+	srv.Close()
+	if err := DialCheck(addr)(context.Background()); err == nil {
+		t.Error("dial of closed server succeeded")
+	}
+}
+
+func TestURLAddr(t *testing.T) {
+	for in, want := range map[string]string{
+		"https://hooks.slack.com/services/x": "hooks.slack.com:443",
+		"http://localhost/hook":              "localhost:80",
+		"http://[::1]:9000/x":                "[::1]:9000",
+		"ftp://example.com/x":                "",
+		"/relative":                          "",
+	} {
+		got, err := URLAddr(in)
+		if got != want || (want == "") != (err != nil) {
+			t.Errorf("URLAddr(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+
+	for cfg, want := range map[*NotifierConfig]string{
+		{Type: "email", SMTPAddr: "smtp.example.com:587"}:        "smtp.example.com:587",
+		{Type: "webhook", URL: "https://evals.example.com/hook"}: "evals.example.com:443",
+		{Type: "pagerduty", RoutingKeyEnv: "PD_KEY"}:             "",
+	} {
+		if got, err := cfg.Endpoint(); err != nil || got != want {
+			t.Errorf("%s Endpoint = %q, %v; want %q", cfg.Type, got, err, want)
+		}
+	}
+}
+
+func TestPreflightStore(t *testing.T) {
+	ctx := context.Background()
+	if detail, err := PreflightStore(ResultStoreConfig{Type: "memory"}).Check(ctx); err != nil || detail != "0 runs" {
+		t.Errorf("memory = %q, %v", detail, err)
+	}
+	if _, err := PreflightStore(ResultStoreConfig{Type: "sqlite", DSN: "x.db", Driver: "matchspec-unregistered"}).Check(ctx); err == nil || !strings.Contains(err.Error(), "not registered") {
+		t.Errorf("sqlite without driver: %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package matchspec
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Progress reports a run's advance after each completed task.
+type Progress struct {
+	RunID     string `json:"run_id"`
+	Suite     string `json:"suite"`
+	Completed int    `json:"completed"`
+	Total     int    `json:"total"`
+	Passed    int    `json:"passed"`
+	Failed    int    `json:"failed"`
+
+	// Elapsed is the time since the run started. ETA projects the time
+	// left from the mean time per task completed so far in this run.
+	Elapsed time.Duration `json:"elapsed_ns"`
+	ETA     time.Duration `json:"eta_ns"`
+
+	// EstimatedFinish is when the run is projected to finish.
+	EstimatedFinish time.Time `json:"estimated_finish"`
+
+	// Deadline is the run context's deadline, if it has one. Overrun is
+	// true when EstimatedFinish falls after it.
+	Deadline time.Time `json:"deadline,omitzero"`
+	Overrun  bool      `json:"overrun,omitempty"`
+}
+
+// String formats the progress for logs, such as "12/40 tasks, ETA 1m30s".
+func (p Progress) String() string {
+	s := fmt.Sprintf("%s: %d/%d tasks, ETA %s", p.Suite, p.Completed, p.Total, p.ETA.Round(time.Second))
+	if p.Overrun {
+		s += fmt.Sprintf(" (projected finish %s is %s past the deadline)",
+			p.EstimatedFinish.Format(time.TimeOnly), p.EstimatedFinish.Sub(p.Deadline).Round(time.Second))
+	}
+	return s
+}
+
+// ProgressFunc receives progress events. It is called synchronously
+// between tasks, so it should return quickly.
+type ProgressFunc func(Progress)
+
+// WithProgress sets a function called after every task of a run with the
+// run's progress and ETA. A run whose context has a deadline it is
+// projected to miss reports Overrun, so operators can intervene before
+// the deadline cancels it.
+func WithProgress(fn ProgressFunc) RunnerOption {
+	return func(r *Runner) {
+		r.progress = fn
+	}
+}
+
+// progressTracker computes ETAs for one run.
+type progressTracker struct {
+	p        Progress
+	start    time.Time
+	done     int // tasks completed in this execution, excluding resumed ones
+	deadline bool
+	warned   bool
+}
+
+// newProgressTracker starts tracking a run of total tasks against ctx's
+// deadline. prior are results completed before this execution, such as
+// those of a resumed checkpoint.
+func newProgressTracker(ctx context.Context, runID, suite string, total int, prior []Result) *progressTracker {
+	deadline, ok := ctx.Deadline()
+	t := &progressTracker{
+		p:        Progress{RunID: runID, Suite: suite, Total: total, Completed: len(prior), Deadline: deadline},
+		start:    time.Now(),
+		deadline: ok,
+	}
+	for _, res := range prior {
+		if res.Passed {
+			t.p.Passed++
+		} else {
+			t.p.Failed++
+		}
+	}
+	return t
+}
+
+// update records a completed task and returns the new progress. The first
+// projected overrun of a run is reported by firstOverrun.
+func (t *progressTracker) update(res Result, now time.Time) (p Progress, firstOverrun bool) {
+	t.done++
+	t.p.Completed++
+	if res.Passed {
+		t.p.Passed++
+	} else {
+		t.p.Failed++
+	}
+	t.p.Elapsed = now.Sub(t.start)
+	perTask := t.p.Elapsed / time.Duration(t.done)
+	t.p.ETA = perTask * time.Duration(max(t.p.Total-t.p.Completed, 0))
+	t.p.EstimatedFinish = now.Add(t.p.ETA)
+	t.p.Overrun = t.deadline && t.p.EstimatedFinish.After(t.p.Deadline)
+	if t.p.Overrun && !t.warned {
+		t.warned = true
+		firstOverrun = true
+	}
+	return t.p, firstOverrun
+}
@@ -0,0 +1,57 @@
+package matchspec
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestProgressTracker(t *testing.T) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Hour))
+	defer cancel()
+	tr := newProgressTracker(ctx, "run-1", "s", 10, []Result{{EvalResult: protocol.EvalResult{Passed: true}}})
+	start := tr.start
+
+	p, overrun := tr.update(Result{}, start.Add(10*time.Minute))
+	if p.Completed != 2 || p.Passed != 1 || p.Failed != 1 {
+		t.Errorf("counts = %+v", p)
+	}
+	// One task took 10m in this execution; 8 remain.
+	if p.ETA != 80*time.Minute || !p.Overrun || !overrun {
+		t.Errorf("ETA = %s, overrun = %v/%v; want 80m, true", p.ETA, p.Overrun, overrun)
+	}
+	if !strings.Contains(p.String(), "past the deadline") {
+		t.Errorf("String = %q", p.String())
+	}
+	// The overrun is reported as new only once.
+	if _, overrun := tr.update(Result{}, start.Add(20*time.Minute)); overrun {
+		t.Error("second overrun reported as first")
+	}
+
+	tr = newProgressTracker(context.Background(), "run-2", "s", 2, nil)
+	if p, _ := tr.update(Result{}, tr.start.Add(time.Minute)); p.Overrun || p.ETA != time.Minute || !p.Deadline.IsZero() {
+		t.Errorf("without deadline: %+v", p)
+	}
+}
+
+func TestRunnerProgress(t *testing.T) {
+	var events []Progress
+	_, reg := testRunnerAndRegistry()
+	reg.Register(&Suite{Name: "two", Tasks: []Task{{Name: "a", Prompt: "a", Expected: "x"}, {Name: "b", Prompt: "b", Expected: "x"}}})
+	runner := NewRunner(reg, echoInfer, tokentrace.NewReporter("matchspec", ""), WithProgress(func(p Progress) { events = append(events, p) }))
+	_, id, err := runner.run(context.Background(), protocol.EvalRun{Suite: "two"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("events = %d, want 2", len(events))
+	}
+	last := events[1]
+	if last.RunID != id || last.Suite != "two" || last.Completed != 2 || last.Total != 2 || last.ETA != 0 {
+		t.Errorf("last event = %+v", last)
+	}
+}
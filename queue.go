@@ -0,0 +1,560 @@
+package matchspec
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/trace"
+)
+
+// JobState is the lifecycle state of a queued run.
+type JobState string
+
+const (
+	JobQueued    JobState = "queued"
+	JobRunning   JobState = "running"
+	JobDone      JobState = "done"
+	JobFailed    JobState = "failed"
+	JobCancelled JobState = "cancelled"
+)
+
+// ErrJobNotFound is returned for operations on an unknown job ID.
+var ErrJobNotFound = errors.New("matchspec: job not found")
+
+// ErrJobLost is returned to a worker that reports on a job it no longer
+// holds, because the job was reassigned after its heartbeat went stale.
+var ErrJobLost = errors.New("matchspec: job reassigned to another worker")
+
+// Job is a run waiting in, or taken from, a JobQueue.
+type Job struct {
+	ID       string            `json:"id"`
+	Run      protocol.EvalRun  `json:"run"`
+	Priority int               `json:"priority,omitempty"`
+	State    JobState          `json:"state"`
+	Labels   map[string]string `json:"labels,omitempty"`
+
+	EnqueuedAt time.Time `json:"enqueued_at"`
+	StartedAt  time.Time `json:"started_at,omitempty"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+
+	// Worker holds a running job; HeartbeatAt is when it last reported.
+	Worker      string    `json:"worker,omitempty"`
+	HeartbeatAt time.Time `json:"heartbeat_at,omitempty"`
+	Attempts    int       `json:"attempts,omitempty"`
+
+	// RunID is the ID of the run record once the job has run. Jobs
+	// submitted by Runner.Start carry it from the start, and run under it.
+	RunID string `json:"run_id,omitempty"`
+	Error string `json:"error,omitempty"`
+
+	// RunLabels and Repeats are the run labels and repeats of a job
+	// submitted by Runner.Start (see WithRunLabels and WithRunRepeats).
+	RunLabels []string `json:"run_labels,omitempty"`
+	Repeats   int      `json:"repeats,omitempty"`
+
+	// QuotaKey and QuotaNamespace are the API key name and namespace of
+	// the request that submitted the job, charged for its tokens by
+	// workers with WorkerConfig.Quotas.
+	QuotaKey       string `json:"quota_key,omitempty"`
+	QuotaNamespace string `json:"quota_namespace,omitempty"`
+}
+
+// chargeJob accounts job to the API key that ctx is charged to, if any.
+func chargeJob(ctx context.Context, job *Job) {
+	job.QuotaKey, job.QuotaNamespace, _ = quotaAccount(ctx)
+}
+
+// JobQueue holds runs waiting to execute. Claim hands out the queued job
+// with the highest priority, oldest first.
+//
+// A worker holding a job must call Heartbeat periodically. Reclaim returns
+// jobs whose worker has gone quiet to the queue, so a crashed worker does
+// not stall them; the old worker then gets ErrJobLost from Heartbeat,
+// Complete, and Release.
+type JobQueue interface {
+	Enqueue(ctx context.Context, job Job) (Job, error)
+	Claim(ctx context.Context, worker string) (Job, bool, error)
+	Heartbeat(ctx context.Context, id, worker string) error
+	Complete(ctx context.Context, id, worker, runID string, runErr error) error
+	Release(ctx context.Context, id, worker string) error
+	Reclaim(ctx context.Context, staleAfter time.Duration) ([]Job, error)
+	Cancel(ctx context.Context, id string) error
+	SetPriority(ctx context.Context, id string, priority int) error
+	Get(ctx context.Context, id string) (Job, error)
+	List(ctx context.Context) ([]Job, error)
+}
+
+// FileJobQueue is a JobQueue persisted to a single JSON file, rewritten
+// atomically on every change, so queued runs survive restarts.
+//
+// Every operation takes an exclusive lock file next to the queue and
+// re-reads it, so several processes, such as server replicas sharing a
+// volume, can use the same queue without a coordinator. Jobs held by a
+// process that dies are recovered through Reclaim once their heartbeat
+// goes stale. The lock relies on exclusive file creation, which some
+// network filesystems do not provide; replicas with a SQL result store
+// should share its SQLJobQueue instead.
+type FileJobQueue struct {
+	path string
+
+	// MaxQueued, if positive, bounds the number of queued jobs across
+	// every process sharing the queue; Enqueue fails with a
+	// QueueFullError beyond it.
+	MaxQueued int
+
+	mu   sync.Mutex
+	jobs []Job
+}
+
+// jobQueueLockTimeout is how old a lock file must be before it is assumed
+// to belong to a crashed process and removed.
+const jobQueueLockTimeout = 30 * time.Second
+
+// OpenFileJobQueue opens the queue stored at path, creating it on first
+// write. An empty path gives an in-memory queue.
+func OpenFileJobQueue(path string) (*FileJobQueue, error) {
+	q := &FileJobQueue{path: path}
+	if path == "" {
+		return q, nil
+	}
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// load reads the queue from disk. The caller holds q.mu.
+func (q *FileJobQueue) load() error {
+	data, err := os.ReadFile(q.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		q.jobs = nil
+		return nil
+	case err != nil:
+		return fmt.Errorf("matchspec: job queue: %w", err)
+	}
+	var jobs []Job
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return fmt.Errorf("matchspec: job queue %s: %w", q.path, err)
+	}
+	q.jobs = jobs
+	return nil
+}
+
+// save writes the queue to disk. The caller holds q.mu.
+func (q *FileJobQueue) save() error {
+	data, err := json.Marshal(q.jobs)
+	if err != nil {
+		return fmt.Errorf("matchspec: job queue: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(q.path), ".jobs-*")
+	if err != nil {
+		return fmt.Errorf("matchspec: job queue: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("matchspec: job queue: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("matchspec: job queue: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), q.path); err != nil {
+		return fmt.Errorf("matchspec: job queue: %w", err)
+	}
+	return nil
+}
+
+// lockFile acquires the cross-process lock, returning its release func.
+func (q *FileJobQueue) lockFile(ctx context.Context) (func(), error) {
+	name := q.path + ".lock"
+	for {
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(name) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("matchspec: job queue: %w", err)
+		}
+		if fi, err := os.Stat(name); err == nil && time.Since(fi.ModTime()) > jobQueueLockTimeout {
+			os.Remove(name)
+			continue
+		}
+		if err := sleep(ctx, 5*time.Millisecond); err != nil {
+			return nil, fmt.Errorf("matchspec: job queue: %w", err)
+		}
+	}
+}
+
+// txn runs fn against the current queue contents under both locks and
+// saves the queue if fn reports a change.
+func (q *FileJobQueue) txn(ctx context.Context, fn func() (changed bool, err error)) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.path == "" {
+		_, err := fn()
+		return err
+	}
+	unlock, err := q.lockFile(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := q.load(); err != nil {
+		return err
+	}
+	changed, err := fn()
+	if err != nil || !changed {
+		return err
+	}
+	return q.save()
+}
+
+func requeue(j *Job) {
+	j.State = JobQueued
+	j.StartedAt = time.Time{}
+	j.Worker = ""
+	j.HeartbeatAt = time.Time{}
+}
+
+// update applies fn to the job with the given ID and saves the queue.
+func (q *FileJobQueue) update(ctx context.Context, id string, fn func(*Job) error) error {
+	return q.txn(ctx, func() (bool, error) {
+		for i := range q.jobs {
+			if q.jobs[i].ID == id {
+				return true, fn(&q.jobs[i])
+			}
+		}
+		return false, ErrJobNotFound
+	})
+}
+
+// Enqueue adds a job in the queued state, assigning an ID if it has none.
+func (q *FileJobQueue) Enqueue(ctx context.Context, job Job) (Job, error) {
+	if job.Run.Suite == "" {
+		return Job{}, fmt.Errorf("matchspec: job has no suite")
+	}
+	if job.ID == "" {
+		job.ID = trace.NewID()
+	}
+	job.State = JobQueued
+	job.EnqueuedAt = time.Now()
+
+	err := q.txn(ctx, func() (bool, error) {
+		if q.MaxQueued > 0 {
+			queued := 0
+			for _, j := range q.jobs {
+				if j.State == JobQueued {
+					queued++
+				}
+			}
+			if queued >= q.MaxQueued {
+				return false, &QueueFullError{Queue: QueueJobs, Depth: queued, Limit: q.MaxQueued}
+			}
+		}
+		q.jobs = append(q.jobs, job)
+		return true, nil
+	})
+	if err != nil {
+		return Job{}, err
+	}
+	return job, nil
+}
+
+// Claim marks the next queued job running on worker and returns it. It
+// reports false if no job is queued.
+func (q *FileJobQueue) Claim(ctx context.Context, worker string) (Job, bool, error) {
+	var claimed Job
+	var ok bool
+	err := q.txn(ctx, func() (bool, error) {
+		next := -1
+		for i, j := range q.jobs {
+			if j.State != JobQueued {
+				continue
+			}
+			if next < 0 || j.Priority > q.jobs[next].Priority {
+				next = i
+			}
+		}
+		if next < 0 {
+			return false, nil
+		}
+		now := time.Now()
+		j := &q.jobs[next]
+		j.State = JobRunning
+		j.StartedAt = now
+		j.Worker = worker
+		j.HeartbeatAt = now
+		j.Attempts++
+		claimed, ok = *j, true
+		return true, nil
+	})
+	if err != nil {
+		return Job{}, false, err
+	}
+	return claimed, ok, nil
+}
+
+// held reports ErrJobLost unless j is running on worker.
+func held(j *Job, worker string) error {
+	if j.State != JobRunning || j.Worker != worker {
+		return ErrJobLost
+	}
+	return nil
+}
+
+// Heartbeat records that worker is still running the job.
+func (q *FileJobQueue) Heartbeat(ctx context.Context, id, worker string) error {
+	return q.update(ctx, id, func(j *Job) error {
+		if err := held(j, worker); err != nil {
+			return err
+		}
+		j.HeartbeatAt = time.Now()
+		return nil
+	})
+}
+
+// Complete records the outcome of a job running on worker.
+func (q *FileJobQueue) Complete(ctx context.Context, id, worker, runID string, runErr error) error {
+	return q.update(ctx, id, func(j *Job) error {
+		if err := held(j, worker); err != nil {
+			return err
+		}
+		j.State = JobDone
+		if runID != "" {
+			j.RunID = runID
+		}
+		j.FinishedAt = time.Now()
+		if runErr != nil {
+			j.State = JobFailed
+			j.Error = runErr.Error()
+		}
+		return nil
+	})
+}
+
+// Release returns a job running on worker to the queue, for a worker
+// shutting down before the job finished.
+func (q *FileJobQueue) Release(ctx context.Context, id, worker string) error {
+	return q.update(ctx, id, func(j *Job) error {
+		if err := held(j, worker); err != nil {
+			return err
+		}
+		requeue(j)
+		return nil
+	})
+}
+
+// Reclaim queues again every running job whose last heartbeat is older
+// than staleAfter, and returns them as they were before reassignment.
+func (q *FileJobQueue) Reclaim(ctx context.Context, staleAfter time.Duration) ([]Job, error) {
+	var reclaimed []Job
+	err := q.txn(ctx, func() (bool, error) {
+		cutoff := time.Now().Add(-staleAfter)
+		for i := range q.jobs {
+			if j := &q.jobs[i]; j.State == JobRunning && j.HeartbeatAt.Before(cutoff) {
+				reclaimed = append(reclaimed, *j)
+				requeue(j)
+			}
+		}
+		return len(reclaimed) > 0, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reclaimed, nil
+}
+
+// Cancel removes a queued job from consideration. Jobs that have already
+// started cannot be cancelled through the queue.
+func (q *FileJobQueue) Cancel(ctx context.Context, id string) error {
+	return q.update(ctx, id, func(j *Job) error {
+		if j.State != JobQueued {
+			return fmt.Errorf("matchspec: job %s is %s, not queued", id, j.State)
+		}
+		j.State = JobCancelled
+		j.FinishedAt = time.Now()
+		return nil
+	})
+}
+
+// SetPriority changes the priority of a queued job.
+func (q *FileJobQueue) SetPriority(ctx context.Context, id string, priority int) error {
+	return q.update(ctx, id, func(j *Job) error {
+		if j.State != JobQueued {
+			return fmt.Errorf("matchspec: job %s is %s, not queued", id, j.State)
+		}
+		j.Priority = priority
+		return nil
+	})
+}
+
+// Get returns the job with the given ID.
+func (q *FileJobQueue) Get(ctx context.Context, id string) (Job, error) {
+	var found Job
+	err := q.txn(ctx, func() (bool, error) {
+		for _, j := range q.jobs {
+			if j.ID == id {
+				found = j
+				return false, nil
+			}
+		}
+		return false, ErrJobNotFound
+	})
+	return found, err
+}
+
+// List returns all jobs in the order Claim would consider them: highest
+// priority first, then oldest first. Finished jobs are included.
+func (q *FileJobQueue) List(ctx context.Context) ([]Job, error) {
+	var jobs []Job
+	err := q.txn(ctx, func() (bool, error) {
+		jobs = slices.Clone(q.jobs)
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(jobs, func(a, b Job) int { return cmp.Compare(b.Priority, a.Priority) })
+	return jobs, nil
+}
+
+// WorkerConfig configures Runner.ProcessJobs.
+type WorkerConfig struct {
+	// ID identifies this worker in the queue. Empty means a random ID.
+	ID string
+
+	// PollInterval is how often an idle worker checks for jobs. Zero
+	// means one second.
+	PollInterval time.Duration
+
+	// HeartbeatInterval is how often the worker reports a running job.
+	// Zero means ten seconds.
+	HeartbeatInterval time.Duration
+
+	// StaleAfter is how long a job may go without a heartbeat before any
+	// worker reassigns it. Zero means three heartbeat intervals.
+	StaleAfter time.Duration
+
+	// Quotas, if set, is charged the tokens each job spends, to the API
+	// key that submitted it.
+	Quotas *QuotaLimiter
+}
+
+func (c WorkerConfig) withDefaults() WorkerConfig {
+	if c.ID == "" {
+		c.ID = trace.NewID()
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = time.Second
+	}
+	if c.HeartbeatInterval <= 0 {
+		c.HeartbeatInterval = 10 * time.Second
+	}
+	if c.StaleAfter <= 0 {
+		c.StaleAfter = 3 * c.HeartbeatInterval
+	}
+	return c
+}
+
+// ProcessJobs claims and runs jobs from q until ctx is cancelled. While
+// idle it also reclaims jobs from workers whose heartbeats have gone
+// stale, so any worker can recover a crashed peer's work. A job
+// interrupted by cancellation is released back to the queue rather than
+// marked failed, and a job reassigned away from this worker is abandoned.
+func (r *Runner) ProcessJobs(ctx context.Context, q JobQueue, cfg WorkerConfig) error {
+	cfg = cfg.withDefaults()
+	for {
+		if _, err := q.Reclaim(ctx, cfg.StaleAfter); err != nil {
+			return err
+		}
+		job, ok, err := q.Claim(ctx, cfg.ID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			if err := sleep(ctx, cfg.PollInterval); err != nil {
+				return nil
+			}
+			continue
+		}
+		if err := r.processJob(ctx, q, job, cfg); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// processJob runs one claimed job, sending heartbeats until it finishes.
+func (r *Runner) processJob(ctx context.Context, q JobQueue, job Job, cfg WorkerConfig) error {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var lost bool
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		t := time.NewTicker(cfg.HeartbeatInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-runCtx.Done():
+				return
+			case <-t.C:
+				if err := q.Heartbeat(runCtx, job.ID, cfg.ID); errors.Is(err, ErrJobLost) {
+					lost = true
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	if r.metrics != nil && !job.EnqueuedAt.IsZero() {
+		r.metrics.observeQueueWait(QueueJobs, job.Run.Suite, job.StartedAt.Sub(job.EnqueuedAt))
+	}
+	// The job queue bounds jobs, so a job waits for a run slot rather than
+	// being turned away.
+	jobCtx := context.WithValue(runCtx, gateWaitKey{}, true)
+	if job.RunID != "" {
+		jobCtx = withRunID(jobCtx, job.RunID)
+	}
+	if job.Repeats > 1 {
+		jobCtx = WithRunRepeats(jobCtx, job.Repeats)
+	}
+	if cfg.Quotas != nil && job.QuotaKey != "" {
+		jobCtx = cfg.Quotas.Charging(jobCtx, job.QuotaKey, job.QuotaNamespace)
+	}
+	labels := append(jobLabels(job.Labels), job.RunLabels...)
+	_, runID, runErr := r.run(WithRunLabels(jobCtx, labels...), job.Run)
+	cancel()
+	<-done
+
+	switch {
+	case lost:
+		return nil
+	case ctx.Err() != nil:
+		return ignoreLost(q.Release(context.WithoutCancel(ctx), job.ID, cfg.ID))
+	default:
+		return ignoreLost(q.Complete(ctx, job.ID, cfg.ID, runID, runErr))
+	}
+}
+
+// ignoreLost drops ErrJobLost: the job now belongs to another worker.
+func ignoreLost(err error) error {
+	if errors.Is(err, ErrJobLost) {
+		return nil
+	}
+	return err
+}
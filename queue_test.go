@@ -0,0 +1,329 @@
+package matchspec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/greynewell/mist-go/protocol"
+)
+
+func TestFileJobQueuePriorityAndPersistence(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	q, err := OpenFileJobQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	low, _ := q.Enqueue(ctx, Job{Run: protocol.EvalRun{Suite: "a"}})
+	high, _ := q.Enqueue(ctx, Job{Run: protocol.EvalRun{Suite: "b"}, Priority: 5})
+	later, _ := q.Enqueue(ctx, Job{Run: protocol.EvalRun{Suite: "c"}})
+	if err := q.SetPriority(ctx, later.ID, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Cancel(ctx, low.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	job, ok, err := q.Claim(ctx, "w1")
+	if err != nil || !ok || job.ID != high.ID || job.State != JobRunning {
+		t.Fatalf("claim = %+v, %v, %v", job, ok, err)
+	}
+
+	// Reopen as if the server restarted mid-run: the running job stays
+	// held until its heartbeat goes stale and it is reclaimed.
+	q, err = OpenFileJobQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := q.Get(ctx, high.ID); got.State != JobRunning {
+		t.Fatalf("job after restart = %+v", got)
+	}
+	time.Sleep(2 * time.Millisecond)
+	q.Reclaim(ctx, time.Millisecond)
+	jobs, _ := q.List(ctx)
+	if len(jobs) != 3 || jobs[0].ID != high.ID || jobs[0].State != JobQueued || jobs[1].ID != later.ID {
+		t.Fatalf("jobs after reclaim = %+v", jobs)
+	}
+	if got, _ := q.Get(ctx, low.ID); got.State != JobCancelled {
+		t.Errorf("cancelled job state = %s", got.State)
+	}
+
+	job, _, _ = q.Claim(ctx, "w1")
+	if err := q.Cancel(ctx, job.ID); err == nil {
+		t.Error("cancelling a running job should fail")
+	}
+	if err := q.Complete(ctx, job.ID, "w1", "run-1", errors.New("boom")); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := q.Get(ctx, job.ID); got.State != JobFailed || got.RunID != "run-1" || got.Error != "boom" {
+		t.Errorf("completed job = %+v", got)
+	}
+	if _, err := q.Get(ctx, "missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("err = %v, want ErrJobNotFound", err)
+	}
+}
+
+// testJobQueue checks the JobQueue contract against an empty queue.
+func testJobQueue(t *testing.T, q JobQueue) {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := q.Enqueue(ctx, Job{}); err == nil {
+		t.Error("enqueued a job without a suite")
+	}
+	low, _ := q.Enqueue(ctx, Job{Run: protocol.EvalRun{Suite: "a"}})
+	high, _ := q.Enqueue(ctx, Job{Run: protocol.EvalRun{Suite: "b"}, Priority: 5})
+	later, _ := q.Enqueue(ctx, Job{Run: protocol.EvalRun{Suite: "c"}})
+	if err := q.SetPriority(ctx, later.ID, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Cancel(ctx, low.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	job, ok, err := q.Claim(ctx, "w1")
+	if err != nil || !ok || job.ID != high.ID || job.State != JobRunning || job.Attempts != 1 {
+		t.Fatalf("claim = %+v, %v, %v", job, ok, err)
+	}
+	if err := q.SetPriority(ctx, job.ID, 9); err == nil {
+		t.Error("reprioritizing a running job should fail")
+	}
+	if err := q.Heartbeat(ctx, job.ID, "w2"); !errors.Is(err, ErrJobLost) {
+		t.Errorf("heartbeat from another worker: err = %v, want ErrJobLost", err)
+	}
+	if got, _ := q.Reclaim(ctx, time.Hour); got != nil {
+		t.Fatalf("fresh job reclaimed: %+v", got)
+	}
+	time.Sleep(2 * time.Millisecond)
+	got, err := q.Reclaim(ctx, time.Millisecond)
+	if err != nil || len(got) != 1 || got[0].ID != high.ID || got[0].Worker != "w1" {
+		t.Fatalf("reclaimed = %+v, %v", got, err)
+	}
+
+	jobs, _ := q.List(ctx)
+	if len(jobs) != 3 || jobs[0].ID != high.ID || jobs[0].State != JobQueued || jobs[1].ID != later.ID || jobs[2].State != JobCancelled {
+		t.Fatalf("jobs after reclaim = %+v", jobs)
+	}
+
+	job, _, _ = q.Claim(ctx, "w2")
+	if job.ID != high.ID || job.Attempts != 2 {
+		t.Fatalf("reclaimed job not reassigned: %+v", job)
+	}
+	if err := q.Complete(ctx, job.ID, "w1", "r", nil); !errors.Is(err, ErrJobLost) {
+		t.Errorf("old worker complete err = %v, want ErrJobLost", err)
+	}
+	if err := q.Cancel(ctx, job.ID); err == nil {
+		t.Error("cancelling a running job should fail")
+	}
+	if err := q.Complete(ctx, job.ID, "w2", "run-1", errors.New("boom")); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := q.Get(ctx, job.ID); got.State != JobFailed || got.RunID != "run-1" || got.Error != "boom" {
+		t.Errorf("completed job = %+v", got)
+	}
+
+	job, _, _ = q.Claim(ctx, "w2")
+	if err := q.Release(ctx, job.ID, "w2"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := q.Get(ctx, later.ID); got.State != JobQueued || got.Worker != "" {
+		t.Errorf("released job = %+v", got)
+	}
+	if _, err := q.Get(ctx, "missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("err = %v, want ErrJobNotFound", err)
+	}
+}
+
+func TestFileJobQueue(t *testing.T) {
+	q, _ := OpenFileJobQueue(filepath.Join(t.TempDir(), "jobs.json"))
+	testJobQueue(t, q)
+}
+
+func TestProcessJobs(t *testing.T) {
+	runner, _ := testRunnerAndRegistry()
+	q, _ := OpenFileJobQueue("")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	job, _ := q.Enqueue(ctx, Job{Run: protocol.EvalRun{Suite: "math"}})
+	done := make(chan error)
+	go func() { done <- runner.ProcessJobs(ctx, q, WorkerConfig{PollInterval: time.Millisecond}) }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		got, _ := q.Get(ctx, job.ID)
+		if got.State == JobDone {
+			if _, ok := runner.GetRun(got.RunID); !ok {
+				t.Errorf("run %q not recorded", got.RunID)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job not processed: %+v", got)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("ProcessJobs = %v", err)
+	}
+}
+
+func TestReclaimStaleJobs(t *testing.T) {
+	ctx := context.Background()
+	q, _ := OpenFileJobQueue("")
+	q.Enqueue(ctx, Job{Run: protocol.EvalRun{Suite: "a"}})
+
+	job, _, _ := q.Claim(ctx, "dead")
+	if err := q.Heartbeat(ctx, job.ID, "dead"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := q.Reclaim(ctx, time.Hour); got != nil {
+		t.Fatalf("fresh job reclaimed: %+v", got)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	got, err := q.Reclaim(ctx, time.Millisecond)
+	if err != nil || len(got) != 1 || got[0].Worker != "dead" {
+		t.Fatalf("reclaimed = %+v, %v", got, err)
+	}
+
+	again, ok, _ := q.Claim(ctx, "alive")
+	if !ok || again.ID != job.ID || again.Attempts != 2 {
+		t.Fatalf("reclaimed job not reassigned: %+v", again)
+	}
+	if err := q.Heartbeat(ctx, job.ID, "dead"); !errors.Is(err, ErrJobLost) {
+		t.Errorf("old worker heartbeat err = %v, want ErrJobLost", err)
+	}
+	if err := q.Complete(ctx, job.ID, "dead", "r", nil); !errors.Is(err, ErrJobLost) {
+		t.Errorf("old worker complete err = %v, want ErrJobLost", err)
+	}
+	if err := q.Complete(ctx, job.ID, "alive", "r", nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProcessJobsRecoversDeadWorker(t *testing.T) {
+	runner, _ := testRunnerAndRegistry()
+	q, _ := OpenFileJobQueue("")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	job, _ := q.Enqueue(ctx, Job{Run: protocol.EvalRun{Suite: "math"}})
+	q.Claim(ctx, "crashed") // never heartbeats again
+
+	done := make(chan error)
+	go func() {
+		done <- runner.ProcessJobs(ctx, q, WorkerConfig{
+			ID: "w2", PollInterval: time.Millisecond, HeartbeatInterval: time.Millisecond, StaleAfter: 20 * time.Millisecond,
+		})
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		got, _ := q.Get(ctx, job.ID)
+		if got.State == JobDone {
+			if got.Worker != "w2" {
+				t.Errorf("worker = %q, want w2", got.Worker)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job not recovered: %+v", got)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+}
+
+func TestFileJobQueueSharedBetweenProcesses(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	a, _ := OpenFileJobQueue(path)
+	b, _ := OpenFileJobQueue(path)
+
+	for i := 0; i < 20; i++ {
+		a.Enqueue(ctx, Job{Run: protocol.EvalRun{Suite: "s"}})
+	}
+
+	// Two replicas claim concurrently; every job goes to exactly one.
+	var wg sync.WaitGroup
+	claimed := make([]map[string]bool, 2)
+	for i, q := range []*FileJobQueue{a, b} {
+		claimed[i] = make(map[string]bool)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				job, ok, err := q.Claim(ctx, fmt.Sprint("w", i))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if !ok {
+					return
+				}
+				claimed[i][job.ID] = true
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := len(claimed[0]) + len(claimed[1]); n != 20 {
+		t.Errorf("claimed %d jobs, want 20", n)
+	}
+	for id := range claimed[0] {
+		if claimed[1][id] {
+			t.Errorf("job %s claimed by both replicas", id)
+		}
+	}
+}
+
+func TestJobHandler(t *testing.T) {
+	q, _ := OpenFileJobQueue("")
+	mux := http.NewServeMux()
+	h := NewJobHandler(q)
+	mux.HandleFunc("POST /jobs", h.Enqueue)
+	mux.HandleFunc("GET /jobs/{id}", h.Get)
+	mux.HandleFunc("DELETE /jobs/{id}", h.Cancel)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("POST", "/jobs", strings.NewReader(`{"run":{"suite":"math"},"priority":3}`)))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("enqueue status = %d, body %s", w.Code, w.Body)
+	}
+	var job Job
+	json.Unmarshal(w.Body.Bytes(), &job)
+	if job.ID == "" || job.Priority != 3 || job.State != JobQueued {
+		t.Fatalf("job = %+v", job)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("DELETE", "/jobs/"+job.ID, nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("cancel status = %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/jobs/"+job.ID, nil))
+	json.Unmarshal(w.Body.Bytes(), &job)
+	if w.Code != http.StatusOK || job.State != JobCancelled {
+		t.Fatalf("get status = %d, job = %+v", w.Code, job)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/jobs/missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing job status = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,356 @@
+package matchspec
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Quota limits usage within one window. Zero fields are unlimited.
+type Quota struct {
+	MaxRequests int64 `json:"max_requests"`
+	MaxTokens   int64 `json:"max_tokens"`
+}
+
+// Usage is the consumption recorded for a key or namespace in the current
+// window.
+type Usage struct {
+	Requests int64 `json:"requests"`
+	Tokens   int64 `json:"tokens"`
+}
+
+// QuotaLimiter tracks request counts and token spend per API key and per
+// namespace and rejects requests once either is over quota. Counters reset
+// at the end of every window.
+//
+// Callers cannot choose whom they are accounted to. The API key, read from
+// "Authorization: Bearer <key>" or X-API-Key, must be one registered with
+// AddKey, which names it and fixes its namespace; other requests get 401.
+// A limiter without keys accounts every request to "anonymous". Usage is
+// therefore only kept for registered keys and their namespaces.
+type QuotaLimiter struct {
+	window   time.Duration
+	defaults Quota
+
+	mu          sync.Mutex
+	keys        map[[sha256.Size]byte]quotaKey
+	keyQuotas   map[string]Quota
+	admins      map[string]bool
+	nsQuotas    map[string]Quota
+	usage       map[string]*Usage
+	windowStart time.Time
+}
+
+// quotaKey is the account a registered API key is charged to.
+type quotaKey struct {
+	name, namespace string
+}
+
+// NewQuotaLimiter creates a limiter applying defaultQuota to every key and
+// namespace without an explicit quota.
+func NewQuotaLimiter(window time.Duration, defaultQuota Quota) *QuotaLimiter {
+	return &QuotaLimiter{
+		window:      window,
+		defaults:    defaultQuota,
+		keys:        make(map[[sha256.Size]byte]quotaKey),
+		keyQuotas:   make(map[string]Quota),
+		admins:      make(map[string]bool),
+		nsQuotas:    make(map[string]Quota),
+		usage:       make(map[string]*Usage),
+		windowStart: time.Now(),
+	}
+}
+
+// AddKey registers an API key, accounted as name within namespace ("" for
+// none). Once a key is registered, requests must present one.
+func (q *QuotaLimiter) AddKey(name, key, namespace string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.keys[sha256.Sum256([]byte(key))] = quotaKey{name: name, namespace: namespace}
+}
+
+// SetKeyQuota overrides the quota for the API key registered as name.
+func (q *QuotaLimiter) SetKeyQuota(name string, quota Quota) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.keyQuotas[name] = quota
+}
+
+// GrantAdmin lets the API key registered as name through RequireAdmin.
+func (q *QuotaLimiter) GrantAdmin(name string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.admins[name] = true
+}
+
+// SetNamespaceQuota overrides the quota for one namespace.
+func (q *QuotaLimiter) SetNamespaceQuota(ns string, quota Quota) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.nsQuotas[ns] = quota
+}
+
+// Middleware enforces quotas before calling next and charges the request.
+// Tokens recorded with RecordTokens under the request's context are
+// charged as they are recorded, including by runs that outlive the
+// request, such as async runs.
+func (q *QuotaLimiter) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, ns, ok := q.identify(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unknown API key", http.StatusUnauthorized)
+			return
+		}
+		charge := q.charge(key, ns)
+		if retry, ok := q.admit(key, ns, charge.accounts); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(retry.Seconds())+1))
+			http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), quotaChargeKey{}, charge)))
+	}
+}
+
+// RequireAdmin guards administrative routes such as /admin/audit: it
+// calls next only for requests with an API key granted admin with
+// GrantAdmin. Unknown keys get 401 and other keys 403, as does every
+// request to a limiter without keys.
+func (q *QuotaLimiter) RequireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, _, ok := q.identify(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unknown API key", http.StatusUnauthorized)
+			return
+		}
+		q.mu.Lock()
+		admin := len(q.keys) > 0 && q.admins[key]
+		q.mu.Unlock()
+		if !admin {
+			http.Error(w, "admin API key required", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Charging returns a context whose RecordTokens calls are charged to the
+// API key registered as name and to namespace, as if recorded during one
+// of the key's requests. Job workers use it to charge queued runs to the
+// key that submitted them (see WorkerConfig.Quotas).
+func (q *QuotaLimiter) Charging(ctx context.Context, name, namespace string) context.Context {
+	return context.WithValue(ctx, quotaChargeKey{}, q.charge(name, namespace))
+}
+
+func (q *QuotaLimiter) charge(key, ns string) *quotaCharge {
+	c := &quotaCharge{q: q, key: key, ns: ns, accounts: []string{"key:" + key}}
+	if ns != "" {
+		c.accounts = append(c.accounts, "ns:"+ns)
+	}
+	return c
+}
+
+// identify returns the name and namespace of the request's API key, and
+// false if the limiter has keys and the request has none of them.
+func (q *QuotaLimiter) identify(r *http.Request) (string, string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.keys) == 0 {
+		return "anonymous", "", true
+	}
+	k, ok := q.keys[sha256.Sum256([]byte(apiKey(r)))]
+	return k.name, k.namespace, ok
+}
+
+// admit checks every account against its quota and, if all have room,
+// charges one request. It returns the time left in the window on rejection.
+func (q *QuotaLimiter) admit(key, ns string, accounts []string) (time.Duration, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	if q.window > 0 && now.Sub(q.windowStart) >= q.window {
+		q.usage = make(map[string]*Usage)
+		q.windowStart = now
+	}
+
+	for _, a := range accounts {
+		quota := q.quotaFor(a, key, ns)
+		u := q.account(a)
+		if (quota.MaxRequests > 0 && u.Requests >= quota.MaxRequests) ||
+			(quota.MaxTokens > 0 && u.Tokens >= quota.MaxTokens) {
+			return q.window - now.Sub(q.windowStart), false
+		}
+	}
+	for _, a := range accounts {
+		q.account(a).Requests++
+	}
+	return 0, true
+}
+
+func (q *QuotaLimiter) quotaFor(account, key, ns string) Quota {
+	if strings.HasPrefix(account, "ns:") {
+		if quota, ok := q.nsQuotas[ns]; ok {
+			return quota
+		}
+	} else if quota, ok := q.keyQuotas[key]; ok {
+		return quota
+	}
+	return q.defaults
+}
+
+func (q *QuotaLimiter) account(a string) *Usage {
+	u, ok := q.usage[a]
+	if !ok {
+		u = &Usage{}
+		q.usage[a] = u
+	}
+	return u
+}
+
+// Usage returns a snapshot of the current window's usage keyed by
+// "key:<key name>" and "ns:<namespace>".
+func (q *QuotaLimiter) Usage() map[string]Usage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := make(map[string]Usage, len(q.usage))
+	for a, u := range q.usage {
+		out[a] = *u
+	}
+	return out
+}
+
+// UsageHandler handles GET /usage — reports the current window's usage.
+func (q *QuotaLimiter) UsageHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(q.Usage())
+}
+
+func apiKey(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	return r.Header.Get("X-API-Key")
+}
+
+// QuotaConfig configures a QuotaLimiter in a project config. Keys are read
+// from environment variables, so they stay out of the config file.
+type QuotaConfig struct {
+	// Window is how often usage resets, such as "24h". Empty never resets.
+	Window string `json:"window,omitempty"`
+
+	// Default applies to every key and namespace without its own quota.
+	Default Quota `json:"default"`
+
+	Keys       []QuotaKeyConfig `json:"keys,omitempty"`
+	Namespaces map[string]Quota `json:"namespaces,omitempty"`
+}
+
+// QuotaKeyConfig registers one API key, read from the environment
+// variable KeyEnv and accounted as Name within Namespace. Admin keys may
+// also use administrative routes.
+type QuotaKeyConfig struct {
+	Name      string `json:"name"`
+	KeyEnv    string `json:"key_env"`
+	Namespace string `json:"namespace,omitempty"`
+	Quota     *Quota `json:"quota,omitempty"`
+	Admin     bool   `json:"admin,omitempty"`
+}
+
+// Validate checks the window and that every key has a name and a
+// variable to read it from.
+func (c *QuotaConfig) Validate() error {
+	if c.Window != "" {
+		if _, err := time.ParseDuration(c.Window); err != nil {
+			return fmt.Errorf("matchspec: quotas window: %w", err)
+		}
+	}
+	names := make(map[string]bool, len(c.Keys))
+	for i, k := range c.Keys {
+		if k.Name == "" || k.KeyEnv == "" {
+			return fmt.Errorf("matchspec: quota key %d needs a name and key_env", i)
+		}
+		if names[k.Name] {
+			return fmt.Errorf("matchspec: quota key %q is listed twice", k.Name)
+		}
+		names[k.Name] = true
+	}
+	return nil
+}
+
+// Limiter creates the configured limiter. It fails if a key's environment
+// variable is unset.
+func (c *QuotaConfig) Limiter() (*QuotaLimiter, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	var window time.Duration
+	if c.Window != "" {
+		window, _ = time.ParseDuration(c.Window)
+	}
+	q := NewQuotaLimiter(window, c.Default)
+	for _, k := range c.Keys {
+		key := os.Getenv(k.KeyEnv)
+		if key == "" {
+			return nil, fmt.Errorf("matchspec: quota key %q: %s is not set", k.Name, k.KeyEnv)
+		}
+		q.AddKey(k.Name, key, k.Namespace)
+		if k.Quota != nil {
+			q.SetKeyQuota(k.Name, *k.Quota)
+		}
+		if k.Admin {
+			q.GrantAdmin(k.Name)
+		}
+	}
+	for ns, quota := range c.Namespaces {
+		q.SetNamespaceQuota(ns, quota)
+	}
+	return q, nil
+}
+
+type quotaChargeKey struct{}
+
+// quotaCharge is the quota accounts of one API key and its namespace.
+// Contexts carry it from the request through any runs the request
+// starts, so tokens are charged whenever they are spent.
+type quotaCharge struct {
+	q        *QuotaLimiter
+	key, ns  string
+	accounts []string
+}
+
+func (c *quotaCharge) add(tokens int64) {
+	c.q.mu.Lock()
+	defer c.q.mu.Unlock()
+	for _, a := range c.accounts {
+		c.q.account(a).Tokens += tokens
+	}
+}
+
+// quotaAccount returns the API key name and namespace that ctx is charged
+// to, if any.
+func quotaAccount(ctx context.Context) (name, namespace string, ok bool) {
+	c, ok := ctx.Value(quotaChargeKey{}).(*quotaCharge)
+	if !ok {
+		return "", "", false
+	}
+	return c.key, c.ns, true
+}
+
+// RecordTokens charges n tokens to the quota accounts of the request that
+// ctx belongs to and to the usage of the run in progress, if any.
+// Inference functions call it with the tokens reported by the backend.
+func RecordTokens(ctx context.Context, n int64) {
+	if c, ok := ctx.Value(quotaChargeKey{}).(*quotaCharge); ok {
+		c.add(n)
+	}
+	recordRunTokens(ctx, n)
+}
@@ -0,0 +1,210 @@
+package matchspec
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestQuotaLimiterRequests(t *testing.T) {
+	q := NewQuotaLimiter(time.Hour, Quota{MaxRequests: 2})
+	q.AddKey("team-a", "secret-a", "")
+	q.AddKey("vip", "secret-vip", "")
+	q.SetKeyQuota("vip", Quota{MaxRequests: 5})
+	h := q.Middleware(func(w http.ResponseWriter, r *http.Request) {})
+
+	call := func(key string) int {
+		req := httptest.NewRequest("POST", "/eval", nil)
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		h(w, req)
+		return w.Code
+	}
+
+	for i := 0; i < 2; i++ {
+		if code := call("secret-a"); code != http.StatusOK {
+			t.Fatalf("request %d: status = %d", i, code)
+		}
+	}
+	if code := call("secret-a"); code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429", code)
+	}
+	if code := call("secret-vip"); code != http.StatusOK {
+		t.Errorf("vip status = %d, want 200", code)
+	}
+	// A key of the caller's own choosing does not get a fresh quota.
+	for _, key := range []string{"", "fresh-key", "team-a"} {
+		if code := call(key); code != http.StatusUnauthorized {
+			t.Errorf("key %q: status = %d, want 401", key, code)
+		}
+	}
+	if u := q.Usage(); len(u) != 2 || u["key:team-a"].Requests != 2 {
+		t.Errorf("usage = %+v", u)
+	}
+}
+
+func TestQuotaLimiterTokensAndNamespace(t *testing.T) {
+	q := NewQuotaLimiter(time.Hour, Quota{})
+	q.AddKey("a", "secret-a", "research")
+	q.AddKey("b", "secret-b", "research")
+	q.AddKey("c", "secret-c", "")
+	q.SetNamespaceQuota("research", Quota{MaxTokens: 100})
+	h := q.Middleware(func(w http.ResponseWriter, r *http.Request) {
+		RecordTokens(r.Context(), 150)
+	})
+
+	call := func(key string) int {
+		req := httptest.NewRequest("POST", "/eval", nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		// The namespace is the key's; the header is not trusted.
+		req.Header.Set("X-Namespace", "other")
+		w := httptest.NewRecorder()
+		h(w, req)
+		return w.Code
+	}
+
+	if code := call("secret-a"); code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+	if code := call("secret-b"); code != http.StatusTooManyRequests {
+		t.Errorf("namespace over token quota: status = %d, want 429", code)
+	}
+	if code := call("secret-c"); code != http.StatusOK {
+		t.Errorf("key outside the namespace: status = %d, want 200", code)
+	}
+
+	u := q.Usage()
+	if u["ns:research"].Tokens != 150 || u["key:a"].Requests != 1 || u["ns:other"].Requests != 0 {
+		t.Errorf("unexpected usage: %+v", u)
+	}
+}
+
+func TestQuotaLimiterAnonymous(t *testing.T) {
+	q := NewQuotaLimiter(time.Hour, Quota{MaxRequests: 1})
+	h := q.Middleware(func(w http.ResponseWriter, r *http.Request) {})
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		req := httptest.NewRequest("POST", "/eval", nil)
+		req.Header.Set("X-API-Key", strings.Repeat("k", i+1))
+		w := httptest.NewRecorder()
+		h(w, req)
+		if w.Code != want {
+			t.Errorf("request %d: status = %d, want %d", i, w.Code, want)
+		}
+	}
+}
+
+func TestQuotaLimiterRequireAdmin(t *testing.T) {
+	q := NewQuotaLimiter(time.Hour, Quota{})
+	q.AddKey("ops", "secret-ops", "")
+	q.AddKey("ci", "secret-ci", "")
+	q.GrantAdmin("ops")
+	h := q.RequireAdmin(func(w http.ResponseWriter, r *http.Request) {})
+	for key, want := range map[string]int{
+		"secret-ops": http.StatusOK,
+		"secret-ci":  http.StatusForbidden,
+		"":           http.StatusUnauthorized,
+		"ops":        http.StatusUnauthorized,
+	} {
+		req := httptest.NewRequest("GET", "/admin/audit", nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		w := httptest.NewRecorder()
+		h(w, req)
+		if w.Code != want {
+			t.Errorf("key %q: status = %d, want %d", key, w.Code, want)
+		}
+	}
+
+	// Without keys, every caller is anonymous and none is an admin.
+	w := httptest.NewRecorder()
+	NewQuotaLimiter(time.Hour, Quota{}).RequireAdmin(func(w http.ResponseWriter, r *http.Request) {})(w, httptest.NewRequest("GET", "/admin/audit", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("keyless limiter: status = %d, want 403", w.Code)
+	}
+}
+
+func TestQuotaConfig(t *testing.T) {
+	t.Setenv("MATCHSPEC_TEST_QUOTA_KEY", "secret-a")
+	c := QuotaConfig{
+		Window:  "1h",
+		Default: Quota{MaxRequests: 1},
+		Keys:    []QuotaKeyConfig{{Name: "a", KeyEnv: "MATCHSPEC_TEST_QUOTA_KEY", Namespace: "research", Quota: &Quota{MaxRequests: 3}, Admin: true}},
+	}
+	q, err := c.Limiter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.window != time.Hour || q.keyQuotas["a"].MaxRequests != 3 || !q.admins["a"] {
+		t.Errorf("limiter = %+v", q)
+	}
+	if name, ns, ok := q.identify(httptest.NewRequest("GET", "/", nil)); ok {
+		t.Errorf("request without a key identified as %s/%s", name, ns)
+	}
+
+	for _, bad := range []QuotaConfig{
+		{Window: "soon"},
+		{Keys: []QuotaKeyConfig{{Name: "a"}}},
+		{Keys: []QuotaKeyConfig{{Name: "a", KeyEnv: "X"}, {Name: "a", KeyEnv: "Y"}}},
+		{Keys: []QuotaKeyConfig{{Name: "a", KeyEnv: "MATCHSPEC_TEST_QUOTA_UNSET"}}},
+	} {
+		if _, err := bad.Limiter(); err == nil {
+			t.Errorf("Limiter(%+v) succeeded", bad)
+		}
+	}
+}
+
+func TestQuotaLimiterChargesBackgroundRuns(t *testing.T) {
+	q := NewQuotaLimiter(time.Hour, Quota{})
+	q.AddKey("ci", "secret", "research")
+	release := make(chan struct{})
+	infer := func(ctx context.Context, prompt string) (string, error) {
+		<-release
+		RecordTokens(ctx, 40)
+		return "echo: " + prompt, nil
+	}
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "math", Tasks: []Task{{Name: "add", Prompt: "1+1", Expected: "echo"}}})
+	runner := NewRunner(reg, infer, tokentrace.NewReporter("matchspec", ""))
+	jobs, _ := OpenFileJobQueue("")
+	h := NewHandler(runner, reg)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /eval", h.RunDirect)
+	mux.HandleFunc("POST /jobs", NewJobHandler(jobs).Enqueue)
+	limited := q.Middleware(mux.ServeHTTP)
+
+	post := func(path string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"suite":"math","run":{"suite":"math"}}`))
+		req.Header.Set("X-API-Key", "secret")
+		w := httptest.NewRecorder()
+		limited(w, req)
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("%s: status = %d: %s", path, w.Code, w.Body)
+		}
+	}
+	tokens := func(want int64) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for q.Usage()["key:ci"].Tokens != want && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if u := q.Usage(); u["key:ci"].Tokens != want || u["ns:research"].Tokens != want {
+			t.Errorf("usage = %+v, want %d tokens", u, want)
+		}
+	}
+
+	// The async run spends its tokens after the request has returned.
+	post("/eval?async=true")
+	close(release)
+	tokens(40)
+
+	post("/jobs")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go runner.ProcessJobs(ctx, jobs, WorkerConfig{PollInterval: time.Millisecond, Quotas: q})
+	tokens(80)
+}
@@ -0,0 +1,92 @@
+package matchspec
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ContextPlaceholder marks where a task's documents are injected into its
+// prompt. Prompts without the placeholder get the documents prepended.
+const ContextPlaceholder = "{{context}}"
+
+var citationPattern = regexp.MustCompile(`\[(\d+)\]`)
+
+// RenderPrompt returns the prompt sent to the backend, with the task's
+// documents injected as a numbered context block.
+func (t *Task) RenderPrompt() string {
+	if len(t.Documents) == 0 {
+		return t.Prompt
+	}
+	block := formatDocuments(t.Documents)
+	if strings.Contains(t.Prompt, ContextPlaceholder) {
+		return strings.ReplaceAll(t.Prompt, ContextPlaceholder, block)
+	}
+	return "Context:\n" + block + "\n" + t.Prompt
+}
+
+func formatDocuments(docs []string) string {
+	var b strings.Builder
+	for i, d := range docs {
+		fmt.Fprintf(&b, "[%d] %s\n", i+1, d)
+	}
+	return b.String()
+}
+
+// matchCitation passes when the response cites at least one document with
+// a [n] marker and every marker refers to one of the task's documents. The
+// score is the fraction of markers that are valid.
+func matchCitation(t *Task, response string) (bool, float64) {
+	found := citationPattern.FindAllStringSubmatch(response, -1)
+	if len(found) == 0 {
+		return false, 0.0
+	}
+	valid := 0
+	for _, m := range found {
+		n, err := strconv.Atoi(m[1])
+		if err == nil && n >= 1 && n <= len(t.Documents) {
+			valid++
+		}
+	}
+	score := float64(valid) / float64(len(found))
+	return valid == len(found), score
+}
+
+// matchGrounded asks the judge whether the response is fully supported by
+// the task's documents. The judge must answer YES or NO; the reason it
+// gives after that is recorded as the "rationale" detail.
+func matchGrounded(ctx context.Context, judge InferFunc, t *Task, response string) (bool, float64, error) {
+	if judge == nil {
+		return false, 0.0, fmt.Errorf("matchspec: matcher \"grounded\" requires a judge (see WithJudge)")
+	}
+	prompt := "You are checking whether an answer is supported by the given documents.\n\n" +
+		"Documents:\n" + formatDocuments(t.Documents) + "\n" +
+		"Question: " + t.Prompt + "\n\n" +
+		"Answer: " + response + "\n\n" +
+		"Is every claim in the answer supported by the documents? Reply with YES or NO, then one sentence explaining why."
+
+	verdict, err := judge(ctx, prompt)
+	if err != nil {
+		return false, 0.0, fmt.Errorf("matchspec: judge: %w", err)
+	}
+	verdict = strings.TrimSpace(verdict)
+	if rationale := judgeRationale(verdict); rationale != "" {
+		addMatchDetails(ctx, map[string]any{"rationale": rationale})
+	}
+	if strings.HasPrefix(strings.ToUpper(verdict), "YES") {
+		return true, 1.0, nil
+	}
+	return false, 0.0, nil
+}
+
+// judgeRationale returns what a YES or NO verdict says after the answer.
+func judgeRationale(verdict string) string {
+	for _, answer := range []string{"YES", "NO"} {
+		if len(verdict) >= len(answer) && strings.EqualFold(verdict[:len(answer)], answer) {
+			return strings.TrimSpace(strings.TrimLeft(verdict[len(answer):], ".,:;-! \t\n"))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,79 @@
+package matchspec
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestTaskRenderPrompt(t *testing.T) {
+	task := Task{Prompt: "Who founded it?", Documents: []string{"Acme was founded by Ada.", "Acme sells anvils."}}
+	got := task.RenderPrompt()
+	if !strings.Contains(got, "[1] Acme was founded by Ada.") || !strings.HasSuffix(got, "Who founded it?") {
+		t.Errorf("unexpected prompt:\n%s", got)
+	}
+
+	task.Prompt = "Docs:\n{{context}}Q: who?"
+	got = task.RenderPrompt()
+	if !strings.HasPrefix(got, "Docs:\n[1] ") || strings.Contains(got, ContextPlaceholder) {
+		t.Errorf("placeholder not replaced:\n%s", got)
+	}
+
+	plain := Task{Prompt: "p"}
+	if plain.RenderPrompt() != "p" {
+		t.Error("prompt without documents should be unchanged")
+	}
+}
+
+func TestTaskMatchCitation(t *testing.T) {
+	task := Task{Name: "t", Prompt: "p", Matcher: "citation", Documents: []string{"a", "b"}}
+	tests := []struct {
+		response  string
+		wantPass  bool
+		wantScore float64
+	}{
+		{"Ada founded it [1].", true, 1.0},
+		{"Ada [1] sells anvils [3].", false, 0.5},
+		{"No citation here.", false, 0.0},
+	}
+	for _, tt := range tests {
+		passed, score := task.Match(tt.response)
+		if passed != tt.wantPass || score != tt.wantScore {
+			t.Errorf("Match(%q) = %v, %f; want %v, %f", tt.response, passed, score, tt.wantPass, tt.wantScore)
+		}
+	}
+}
+
+func TestRunnerGroundedMatcher(t *testing.T) {
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{
+		Name: "rag",
+		Tasks: []Task{{
+			Name: "founder", Prompt: "Who founded Acme?", Matcher: "grounded",
+			Documents: []string{"Acme was founded by Ada."},
+		}},
+	})
+	infer := func(_ context.Context, prompt string) (string, error) {
+		if !strings.Contains(prompt, "[1] Acme was founded by Ada.") {
+			t.Errorf("documents not passed to backend: %q", prompt)
+		}
+		return "Ada", nil
+	}
+	judge := func(_ context.Context, prompt string) (string, error) {
+		return "YES", nil
+	}
+	reporter := tokentrace.NewReporter("matchspec", "")
+
+	results, _ := NewRunner(reg, infer, reporter, WithJudge(judge)).Run(context.Background(), protocol.EvalRun{Suite: "rag"})
+	if len(results) != 1 || !results[0].Passed {
+		t.Errorf("expected grounded pass, got %+v", results)
+	}
+
+	results, _ = NewRunner(reg, infer, reporter).Run(context.Background(), protocol.EvalRun{Suite: "rag"})
+	if len(results) != 1 || results[0].Passed || results[0].Error == "" {
+		t.Errorf("expected error without judge, got %+v", results)
+	}
+}
@@ -0,0 +1,73 @@
+package matchspec
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Redactor scrubs sensitive content from text before it leaves the runner
+// in results, run records, or trace spans.
+type Redactor interface {
+	Redact(s string) string
+}
+
+// RedactorFunc adapts a function to the Redactor interface.
+type RedactorFunc func(s string) string
+
+// Redact calls f.
+func (f RedactorFunc) Redact(s string) string { return f(s) }
+
+// DefaultPIIPatterns match common personal data: email addresses, US social
+// security numbers, payment card numbers, and phone numbers.
+var DefaultPIIPatterns = []string{
+	`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`,
+	`\b\d{3}-\d{2}-\d{4}\b`,
+	`\b(?:\d[ \-]?){13,16}\b`,
+	`\+?\d{1,3}[ .\-]?\(?\d{3}\)?[ .\-]?\d{3}[ .\-]?\d{4}\b`,
+}
+
+// RegexRedactor replaces every match of its patterns with a fixed string.
+type RegexRedactor struct {
+	patterns    []*regexp.Regexp
+	replacement string
+}
+
+// NewRegexRedactor compiles patterns into a redactor. Matches are replaced
+// with replacement, or "[REDACTED]" if it is empty.
+func NewRegexRedactor(replacement string, patterns ...string) (*RegexRedactor, error) {
+	if replacement == "" {
+		replacement = "[REDACTED]"
+	}
+	rd := &RegexRedactor{replacement: replacement}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("matchspec: redaction pattern %q: %w", p, err)
+		}
+		rd.patterns = append(rd.patterns, re)
+	}
+	return rd, nil
+}
+
+// Redact replaces all pattern matches in s.
+func (rd *RegexRedactor) Redact(s string) string {
+	for _, re := range rd.patterns {
+		s = re.ReplaceAllString(s, rd.replacement)
+	}
+	return s
+}
+
+// WithRedactor adds a redactor applied to result errors, run record errors,
+// and trace span text before they are stored or exported. Redactors run in
+// the order they are added.
+func WithRedactor(rd Redactor) RunnerOption {
+	return func(r *Runner) { r.redactors = append(r.redactors, rd) }
+}
+
+// redact applies every configured redactor to s.
+func (r *Runner) redact(s string) string {
+	for _, rd := range r.redactors {
+		s = rd.Redact(s)
+	}
+	return s
+}
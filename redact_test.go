@@ -0,0 +1,46 @@
+package matchspec
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestRegexRedactor(t *testing.T) {
+	rd, err := NewRegexRedactor("", DefaultPIIPatterns...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := rd.Redact("mail jane.doe@example.com or call +1 415-555-0100, ssn 123-45-6789")
+	for _, leaked := range []string{"jane.doe@example.com", "555-0100", "123-45-6789"} {
+		if strings.Contains(got, leaked) {
+			t.Errorf("redacted text still contains %q: %s", leaked, got)
+		}
+	}
+	if !strings.Contains(got, "[REDACTED]") {
+		t.Errorf("expected replacement marker: %s", got)
+	}
+
+	if _, err := NewRegexRedactor("", "("); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+}
+
+func TestRunnerRedactsErrors(t *testing.T) {
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "s", Tasks: []Task{{Name: "t", Prompt: "p", Expected: "x"}}})
+	infer := func(context.Context, string) (string, error) {
+		return "", fmt.Errorf("provider rejected prompt from bob@example.com")
+	}
+	rd, _ := NewRegexRedactor("<pii>", DefaultPIIPatterns[0])
+	runner := NewRunner(reg, infer, tokentrace.NewReporter("matchspec", ""), WithRedactor(rd))
+
+	results, _ := runner.Run(context.Background(), protocol.EvalRun{Suite: "s"})
+	if len(results) != 1 || results[0].Error != "provider rejected prompt from <pii>" {
+		t.Errorf("error not redacted: %+v", results)
+	}
+}
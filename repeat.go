@@ -0,0 +1,184 @@
+package matchspec
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// MaxRepeats is the most times a run may execute each task (see
+// WithRunRepeats).
+const MaxRepeats = 100
+
+type runRepeatsKey struct{}
+
+// WithRunRepeats makes runs started with the returned context execute each
+// task n times, so stochastic outputs are sampled rather than judged on a
+// single response. Results carry their Repeat, and the run summary
+// aggregates them into pass@1, pass@k, and flaky tasks (see RepeatSummary).
+// n of one or less runs each task once.
+func WithRunRepeats(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, runRepeatsKey{}, n)
+}
+
+// RunRepeatsFrom returns the repeats carried by ctx, at least one.
+func RunRepeatsFrom(ctx context.Context) int {
+	n, _ := ctx.Value(runRepeatsKey{}).(int)
+	return max(n, 1)
+}
+
+// ValidateRepeats checks that n is a usable number of repeats.
+func ValidateRepeats(n int) error {
+	if n < 1 || n > MaxRepeats {
+		return fmt.Errorf("matchspec: repeats %d is not between 1 and %d", n, MaxRepeats)
+	}
+	return nil
+}
+
+// expandRepeats replaces each task with n consecutive copies numbered from
+// one. With n of one the tasks are returned as is.
+func expandRepeats(tasks []Task, n int) []Task {
+	if n <= 1 {
+		return tasks
+	}
+	out := make([]Task, 0, len(tasks)*n)
+	for _, t := range tasks {
+		for i := 1; i <= n; i++ {
+			t.repeat = i
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// repeatCacheKey keeps the cache entries of repeat apart from unrepeated
+// ones: each repeat after the first gets its own response cache entry, so
+// a cached run replays every sample instead of the first n times, and a
+// repeated run its own run cache entry.
+func repeatCacheKey(key string, repeat int) string {
+	if repeat <= 1 {
+		return key
+	}
+	return fmt.Sprintf("%s-%d", key, repeat)
+}
+
+// PassAtK is the unbiased estimate of pass@k for a task that passed c of n
+// executions: the probability that at least one of k executions drawn
+// without replacement passes, 1 - C(n-c, k) / C(n, k).
+func PassAtK(n, c, k int) float64 {
+	if n <= 0 || k <= 0 || c <= 0 {
+		return 0
+	}
+	k = min(k, n)
+	if n-c < k {
+		return 1
+	}
+	// C(n-c, k) / C(n, k) as a running product, which avoids overflow.
+	fail := 1.0
+	for i := n - c + 1; i <= n; i++ {
+		fail *= 1 - float64(k)/float64(i)
+	}
+	return 1 - fail
+}
+
+// TaskRepeats aggregates the executions of one repeated task. Task is
+// "name@variant" for prompt variants other than the base. A task is Flaky
+// if some executions passed and others failed.
+type TaskRepeats struct {
+	Task        string  `json:"task"`
+	Runs        int     `json:"runs"`
+	Passed      int     `json:"passed"`
+	PassAt1     float64 `json:"pass_at_1"`
+	PassAtK     float64 `json:"pass_at_k"`
+	MeanScore   float64 `json:"mean_score"`
+	StdDevScore float64 `json:"stddev_score"`
+	Flaky       bool    `json:"flaky"`
+}
+
+// RepeatSummary aggregates a run that repeated its tasks. K is the number
+// of executions per task (the fewest any task had, if the run stopped
+// early); PassAt1 and PassAtK are the means over tasks.
+type RepeatSummary struct {
+	K       int           `json:"k"`
+	PassAt1 float64       `json:"pass_at_1"`
+	PassAtK float64       `json:"pass_at_k"`
+	Flaky   []string      `json:"flaky,omitempty"`
+	Tasks   []TaskRepeats `json:"tasks"`
+}
+
+// SummarizeRepeats aggregates repeated results by task, in order of first
+// appearance. It returns nil if no result carries a Repeat.
+func SummarizeRepeats(results []Result) *RepeatSummary {
+	var c repeatCounter
+	for _, r := range results {
+		c.add(r)
+	}
+	return c.summary()
+}
+
+// repeatCounter accumulates SummarizeRepeats one result at a time.
+type repeatCounter struct {
+	order []string
+	byKey map[string]*repeatAcc
+}
+
+type repeatAcc struct {
+	runs, passed int
+	sum, sumSq   float64
+}
+
+func (c *repeatCounter) add(r Result) {
+	if r.Repeat == 0 {
+		return
+	}
+	key := baselineKey(r)
+	acc, ok := c.byKey[key]
+	if !ok {
+		if c.byKey == nil {
+			c.byKey = make(map[string]*repeatAcc)
+		}
+		acc = &repeatAcc{}
+		c.byKey[key] = acc
+		c.order = append(c.order, key)
+	}
+	acc.runs++
+	if r.Passed {
+		acc.passed++
+	}
+	acc.sum += r.Score
+	acc.sumSq += r.Score * r.Score
+}
+
+func (c *repeatCounter) summary() *RepeatSummary {
+	if len(c.order) == 0 {
+		return nil
+	}
+	s := &RepeatSummary{K: math.MaxInt}
+	for _, key := range c.order {
+		s.K = min(s.K, c.byKey[key].runs)
+	}
+	for _, key := range c.order {
+		acc := c.byKey[key]
+		n := float64(acc.runs)
+		mean := acc.sum / n
+		t := TaskRepeats{
+			Task:        key,
+			Runs:        acc.runs,
+			Passed:      acc.passed,
+			PassAt1:     float64(acc.passed) / n,
+			PassAtK:     PassAtK(acc.runs, acc.passed, s.K),
+			MeanScore:   mean,
+			StdDevScore: math.Sqrt(max(0, acc.sumSq/n-mean*mean)),
+			Flaky:       acc.passed > 0 && acc.passed < acc.runs,
+		}
+		if t.Flaky {
+			s.Flaky = append(s.Flaky, key)
+		}
+		s.PassAt1 += t.PassAt1
+		s.PassAtK += t.PassAtK
+		s.Tasks = append(s.Tasks, t)
+	}
+	s.PassAt1 /= float64(len(s.Tasks))
+	s.PassAtK /= float64(len(s.Tasks))
+	return s
+}
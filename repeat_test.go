@@ -0,0 +1,105 @@
+package matchspec
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestPassAtK(t *testing.T) {
+	for _, tt := range []struct {
+		n, c, k int
+		want    float64
+	}{
+		{5, 0, 1, 0},
+		{5, 5, 1, 1},
+		{5, 2, 1, 0.4},
+		{5, 2, 2, 0.7},
+		{5, 2, 4, 1},
+		{10, 3, 5, 1 - 21.0/252},
+	} {
+		if got := PassAtK(tt.n, tt.c, tt.k); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("PassAtK(%d, %d, %d) = %v, want %v", tt.n, tt.c, tt.k, got, tt.want)
+		}
+	}
+}
+
+func TestRunRepeats(t *testing.T) {
+	// mul passes every other time; add always passes.
+	var calls atomic.Int32
+	infer := func(ctx context.Context, prompt string) (string, error) {
+		if prompt == "What is 3*4?" && calls.Add(1)%2 == 0 {
+			return "11", nil
+		}
+		return map[string]string{"What is 2+2?": "4", "What is 3*4?": "12"}[prompt], nil
+	}
+	runner := NewRunner(driftRegistry(), infer, tokentrace.NewReporter("matchspec", ""), WithResponseCache(NewMemoryResponseCache(), ""))
+	ctx := WithRunRepeats(context.Background(), 4)
+	results, err := runner.RunDetailed(ctx, protocol.EvalRun{Suite: "math"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 8 || results[0].Repeat != 1 || results[3].Repeat != 4 {
+		t.Fatalf("results = %+v", results)
+	}
+	if calls.Load() != 4 {
+		t.Errorf("mul inferred %d times; repeats shared a cache entry", calls.Load())
+	}
+
+	runs, _ := runner.Runs(RunFilter{})
+	rec := runs[0]
+	rs := rec.Summary.Repeats
+	if rec.Repeats != 4 || rs == nil || rs.K != 4 || strings.Join(rs.Flaky, ",") != "mul" {
+		t.Fatalf("record repeats %d, summary %+v", rec.Repeats, rs)
+	}
+	mul := rs.Tasks[1]
+	if mul.Passed != 2 || mul.PassAt1 != 0.5 || mul.PassAtK != 1 || mul.StdDevScore != 0.5 {
+		t.Errorf("mul = %+v", mul)
+	}
+	if rs.PassAt1 != 0.75 || rs.PassAtK != 1 {
+		t.Errorf("pass@1 %v, pass@k %v", rs.PassAt1, rs.PassAtK)
+	}
+
+	// A resumed run keeps repeating.
+	cp, err := runner.ExportRun(rec.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp.Results = cp.Results[:3]
+	cp.Record.Hash = HashResults(cp.Results)
+	resumer := NewRunner(driftRegistry(), infer, tokentrace.NewReporter("matchspec", ""))
+	rest, err := resumer.ResumeRun(context.Background(), cp)
+	if err != nil || len(rest) != 8 || rest[7].Repeat != 4 {
+		t.Errorf("resumed %d results, %v", len(rest), err)
+	}
+
+	if SummarizeRepeats(results[:0]) != nil {
+		t.Error("summarized repeats of no results")
+	}
+}
+
+func TestRunDirectRepeats(t *testing.T) {
+	h := NewHandler(testRunner(echoInfer), nil)
+	w := httptest.NewRecorder()
+	h.RunDirect(w, httptest.NewRequest(http.MethodPost, "/eval?repeats=3", strings.NewReader(`{"suite":"math"}`)))
+	var results []Result
+	json.Unmarshal(w.Body.Bytes(), &results)
+	if w.Code != http.StatusOK || len(results)%3 != 0 || results[len(results)-1].Repeat != 3 {
+		t.Errorf("POST /eval?repeats=3 = %d %s", w.Code, w.Body)
+	}
+	for _, bad := range []string{"0", "x", "1000"} {
+		w := httptest.NewRecorder()
+		h.RunDirect(w, httptest.NewRequest(http.MethodPost, "/eval?repeats="+bad, strings.NewReader(`{"suite":"math"}`)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("repeats=%s: %d", bad, w.Code)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package matchspec
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// MaxReportFailures is the number of failed tasks a RunReport lists; the
+// rest are counted.
+const MaxReportFailures = 50
+
+// RunReport is a human-readable account of a run: its summary, the
+// regressions it showed, and its failed tasks. Render it with Markdown or
+// HTML.
+type RunReport struct {
+	Run         RunRecord
+	Regressions []DriftAlert
+
+	// Failures are the run's failed results, up to MaxReportFailures.
+	// MoreFailures counts the ones left out.
+	Failures     []Result
+	MoreFailures int
+}
+
+// NewRunReport builds the report of rec from its results, which may be
+// nil, and the regressions found in it, if any.
+func NewRunReport(rec RunRecord, results []Result, regressions []DriftAlert) RunReport {
+	rep := RunReport{Run: rec, Regressions: regressions}
+	for _, res := range results {
+		if res.Passed {
+			continue
+		}
+		if len(rep.Failures) == MaxReportFailures {
+			rep.MoreFailures++
+			continue
+		}
+		rep.Failures = append(rep.Failures, res)
+	}
+	return rep
+}
+
+// Title is a one-line description of the report, suitable for an email
+// subject.
+func (rep RunReport) Title() string {
+	s := rep.Run.Summary
+	title := fmt.Sprintf("matchspec: suite %q", rep.Run.Suite)
+	if rep.Run.Model != "" {
+		title += " on " + rep.Run.Model
+	}
+	switch {
+	case len(rep.Regressions) > 0:
+		title += " regressed"
+	case rep.Run.Error != "":
+		title += " failed"
+	}
+	return title + fmt.Sprintf(": %d/%d passed", s.Passed, s.Total)
+}
+
+// reportRow is a line of the report's summary table.
+type reportRow struct {
+	Name, Value string
+}
+
+func (rep RunReport) rows() []reportRow {
+	rec := rep.Run
+	s := rec.Summary
+	rows := []reportRow{{"Run", rec.ID}}
+	if rec.Owner != nil {
+		rows = append(rows, reportRow{"Owner", rec.Owner.String()})
+	}
+	if rec.Model != "" {
+		rows = append(rows, reportRow{"Model", rec.Model})
+	}
+	rows = append(rows,
+		reportRow{"Started", rec.StartedAt.UTC().Format(time.RFC3339)},
+		reportRow{"Duration", rec.FinishedAt.Sub(rec.StartedAt).Round(time.Millisecond).String()},
+		reportRow{"Passed", fmt.Sprintf("%d/%d (%.1f%%)", s.Passed, s.Total, 100*s.PassRate)},
+		reportRow{"Errors", fmt.Sprint(s.Errors)},
+		reportRow{"Mean score", fmt.Sprintf("%.3f", s.MeanScore)},
+		reportRow{"Latency p50/p95/p99", fmt.Sprintf("%.0f / %.0f / %.0f ms", s.Latency.P50, s.Latency.P95, s.Latency.P99)},
+	)
+	if rec.Error != "" {
+		rows = append(rows, reportRow{"Error", rec.Error})
+	}
+	return rows
+}
+
+// reportData is what the report templates render.
+type reportData struct {
+	Title        string
+	Rows         []reportRow
+	Regressions  []string
+	Warnings     []string
+	Failures     []Result
+	MoreFailures int
+}
+
+func (rep RunReport) data() reportData {
+	d := reportData{
+		Title:        rep.Title(),
+		Rows:         rep.rows(),
+		Warnings:     rep.Run.Warnings,
+		Failures:     rep.Failures,
+		MoreFailures: rep.MoreFailures,
+	}
+	for _, a := range rep.Regressions {
+		d.Regressions = append(d.Regressions, a.String())
+	}
+	return d
+}
+
+var reportFuncs = map[string]any{
+	"cell":  markdownCell,
+	"score": func(f float64) string { return fmt.Sprintf("%.3f", f) },
+}
+
+var markdownReport = template.Must(template.New("report").Funcs(reportFuncs).Parse(`# {{.Title}}
+
+| | |
+|---|---|
+{{range .Rows}}| {{.Name}} | {{cell .Value}} |
+{{end}}{{if .Regressions}}
+## Regressions
+
+{{range .Regressions}}- {{.}}
+{{end}}{{end}}{{if .Warnings}}
+## Warnings
+
+{{range .Warnings}}- {{.}}
+{{end}}{{end}}{{if .Failures}}
+## Failures
+
+| Task | Variant | Score | Error |
+|---|---|---|---|
+{{range .Failures}}| {{cell .Task}} | {{cell .Variant}} | {{score .Score}} | {{cell .Error}} |
+{{end}}{{if .MoreFailures}}
+and {{.MoreFailures}} more.
+{{end}}{{end}}`))
+
+var htmlReport = htmltemplate.Must(htmltemplate.New("report").Funcs(reportFuncs).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body style="font-family: sans-serif">
+<h1>{{.Title}}</h1>
+<table>
+{{range .Rows}}<tr><th align="left">{{.Name}}</th><td>{{.Value}}</td></tr>
+{{end}}</table>
+{{if .Regressions}}<h2>Regressions</h2>
+<ul>
+{{range .Regressions}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}{{if .Warnings}}<h2>Warnings</h2>
+<ul>
+{{range .Warnings}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}{{if .Failures}}<h2>Failures</h2>
+<table>
+<tr><th align="left">Task</th><th align="left">Variant</th><th align="left">Score</th><th align="left">Error</th></tr>
+{{range .Failures}}<tr><td>{{.Task}}</td><td>{{.Variant}}</td><td>{{score .Score}}</td><td>{{.Error}}</td></tr>
+{{end}}</table>
+{{if .MoreFailures}}<p>and {{.MoreFailures}} more.</p>
+{{end}}{{end}}</body></html>
+`))
+
+// Markdown renders the report as Markdown.
+func (rep RunReport) Markdown() string {
+	var sb strings.Builder
+	markdownReport.Execute(&sb, rep.data())
+	return sb.String()
+}
+
+// HTML renders the report as a standalone HTML document.
+func (rep RunReport) HTML() string {
+	var sb strings.Builder
+	htmlReport.Execute(&sb, rep.data())
+	return sb.String()
+}
+
+// markdownCell makes s safe to place in a Markdown table cell.
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,63 @@
+package matchspec
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/greynewell/mist-go/protocol"
+)
+
+func TestRunReport(t *testing.T) {
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	rec := RunRecord{
+		ID: "r1", Suite: "math", Model: "m",
+		StartedAt: start, FinishedAt: start.Add(1500 * time.Millisecond),
+		Summary:  Summary{Total: 3, Passed: 1, Failed: 2, PassRate: 1.0 / 3, MeanScore: 0.5},
+		Warnings: []string{"backend reports model \"x\""},
+	}
+	results := []Result{
+		{EvalResult: protocol.EvalResult{Task: "add", Passed: true, Score: 1}},
+		{EvalResult: protocol.EvalResult{Task: "mul", Score: 0.25, Error: "bad | pipe\nsecond line"}},
+		{EvalResult: protocol.EvalResult{Task: "<div>"}, Variant: "terse"},
+	}
+	rep := NewRunReport(rec, results, []DriftAlert{{Suite: "math", Metric: "pass_rate", Value: 1.0 / 3, Baseline: 1, Delta: -2.0 / 3, BaselineRuns: 3}})
+	if len(rep.Failures) != 2 || rep.Title() != `matchspec: suite "math" on m regressed: 1/3 passed` {
+		t.Fatalf("report = %+v, title %q", rep, rep.Title())
+	}
+
+	md := rep.Markdown()
+	for _, want := range []string{
+		"# matchspec: suite \"math\" on m regressed: 1/3 passed\n",
+		"| Passed | 1/3 (33.3%) |\n",
+		"| Duration | 1.5s |\n",
+		"## Regressions\n\n- drift: suite \"math\" pass_rate 0.333 is -0.667",
+		"## Warnings\n\n- backend reports model \"x\"\n",
+		"| mul |  | 0.250 | bad \\| pipe second line |\n",
+		"| <div> | terse | 0.000 |  |\n",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown missing %q:\n%s", want, md)
+		}
+	}
+
+	html := rep.HTML()
+	if !strings.Contains(html, "<td>&lt;div&gt;</td><td>terse</td>") || !strings.Contains(html, "<h2>Regressions</h2>") {
+		t.Errorf("html:\n%s", html)
+	}
+}
+
+func TestRunReportFailureCap(t *testing.T) {
+	var results []Result
+	for i := range MaxReportFailures + 5 {
+		results = append(results, Result{EvalResult: protocol.EvalResult{Task: fmt.Sprint("t", i)}})
+	}
+	rep := NewRunReport(RunRecord{Suite: "s"}, results, nil)
+	if len(rep.Failures) != MaxReportFailures || rep.MoreFailures != 5 {
+		t.Fatalf("failures = %d, more = %d", len(rep.Failures), rep.MoreFailures)
+	}
+	if !strings.Contains(rep.Markdown(), "and 5 more.") || strings.Contains(rep.Markdown(), "## Regressions") {
+		t.Errorf("markdown:\n%s", rep.Markdown())
+	}
+}
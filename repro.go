@@ -0,0 +1,175 @@
+package matchspec
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"maps"
+)
+
+// Repro records what a task execution depended on beyond the suite itself,
+// so that Reproduce can run it again under the same conditions.
+type Repro struct {
+	// Seed is the sampling seed sent with the inference call as the "seed"
+	// param. Reproduction is only bit-for-bit if the backend honors it.
+	Seed int64 `json:"seed,omitempty"`
+
+	// GeneratorSeed is the seed of the generator that produced the task,
+	// which fixes its random draws and row order.
+	GeneratorSeed int64 `json:"generator_seed,omitempty"`
+
+	Model          string         `json:"model,omitempty"`
+	Params         map[string]any `json:"params,omitempty"`
+	PromptTemplate string         `json:"prompt_template,omitempty"`
+
+	// PromptSHA256 is the digest of the prompt that was sent, so a
+	// reproduction can confirm it sent the same input.
+	PromptSHA256 string `json:"prompt_sha256"`
+}
+
+// ReseedableGenerator is a SeededGenerator that can be rebuilt with another
+// seed, which lets Reproduce regenerate the tasks of an earlier run.
+type ReseedableGenerator interface {
+	SeededGenerator
+	WithSeed(seed int64) TaskGenerator
+}
+
+// WithSamplingSeed sends every inference call a sampling seed (the "seed"
+// param) derived from seed, the task name, and its variant, and records it
+// on the result. Tasks whose params already carry a seed keep it. A zero
+// seed picks a fresh one, which is reported on the run span.
+func WithSamplingSeed(seed int64) RunnerOption {
+	return func(r *Runner) {
+		r.samplingSeed = resolveSeed(seed)
+	}
+}
+
+// taskSeed derives the sampling seed of task from the run seed. Seeds are
+// kept within 31 bits, which every backend accepts.
+func taskSeed(seed int64, task *Task) int64 {
+	h := fnv.New64a()
+	binary.Write(h, binary.LittleEndian, seed)
+	h.Write([]byte(task.Name))
+	h.Write([]byte{0})
+	h.Write([]byte(task.variant))
+	if task.repeat > 1 {
+		binary.Write(h, binary.LittleEndian, int64(task.repeat))
+	}
+	return int64(h.Sum64() & 0x7fffffff)
+}
+
+// seedParam returns the "seed" param of opts, if it holds an integer.
+func seedParam(opts InferOptions) (int64, bool) {
+	switch v := opts.Params["seed"].(type) {
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case float64:
+		return int64(v), v == float64(int64(v))
+	}
+	return 0, false
+}
+
+// withTaskSeed adds seed to the inference params carried by ctx.
+func withTaskSeed(ctx context.Context, seed int64) context.Context {
+	opts, _ := InferOptionsFrom(ctx)
+	opts.Params = maps.Clone(opts.Params)
+	if opts.Params == nil {
+		opts.Params = make(map[string]any)
+	}
+	opts.Params["seed"] = seed
+	return WithInferOptions(ctx, opts)
+}
+
+// seedTask gives the task's inference call a sampling seed when the runner
+// has one and ctx does not already set a seed.
+func (r *Runner) seedTask(ctx context.Context, task *Task) context.Context {
+	if r.samplingSeed == 0 {
+		return ctx
+	}
+	if opts, _ := InferOptionsFrom(ctx); opts.Params["seed"] != nil {
+		return ctx
+	}
+	return withTaskSeed(ctx, taskSeed(r.samplingSeed, task))
+}
+
+// reproFor records the conditions of a task execution that sent prompt. It
+// returns nil if the execution involved no seed.
+func reproFor(ctx context.Context, task *Task, prompt string) *Repro {
+	opts, _ := InferOptionsFrom(ctx)
+	seed, _ := seedParam(opts)
+	if seed == 0 && task.generatorSeed == 0 {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(prompt))
+	tmpl, _ := ctx.Value(promptTemplateKey{}).(string)
+	return &Repro{
+		Seed:           seed,
+		GeneratorSeed:  task.generatorSeed,
+		Model:          opts.Model,
+		Params:         opts.Params,
+		PromptTemplate: tmpl,
+		PromptSHA256:   hex.EncodeToString(sum[:]),
+	}
+}
+
+// Reproduce runs the task execution behind res again: the same task and
+// variant, regenerated with the recorded generator seed, sent with the
+// recorded model, params, prompt template, and sampling seed. The new
+// result is returned but not recorded as a run. If the reproduced prompt
+// differs from the original, the result is returned with an error.
+func (r *Runner) Reproduce(ctx context.Context, res Result) (Result, error) {
+	suite, ok := r.registry.Get(res.Suite)
+	if !ok {
+		return Result{}, fmt.Errorf("matchspec: unknown suite %q", res.Suite)
+	}
+	repro := res.Repro
+	if repro == nil {
+		repro = &Repro{}
+	}
+
+	tasks := suite.Tasks
+	if repro.GeneratorSeed != 0 {
+		g, ok := suite.Generator.(ReseedableGenerator)
+		if !ok {
+			return Result{}, fmt.Errorf("matchspec: suite %q generator cannot be reseeded", suite.Name)
+		}
+		generated, err := g.WithSeed(repro.GeneratorSeed).Generate(ctx)
+		if err != nil {
+			return Result{}, fmt.Errorf("matchspec: suite %q generator: %w", suite.Name, err)
+		}
+		for i := range generated {
+			generated[i].generatorSeed = repro.GeneratorSeed
+		}
+		tasks = append(generated, tasks...)
+	}
+
+	var task *Task
+	for _, t := range expandVariants(tasks) {
+		if t.Name == res.Task && t.variant == res.Variant {
+			task = &t
+			break
+		}
+	}
+	if task == nil {
+		return Result{}, fmt.Errorf("matchspec: suite %q has no task %q", suite.Name, res.Task)
+	}
+
+	ctx = WithInferOptions(ctx, InferOptions{Model: repro.Model, Params: repro.Params})
+	if repro.Seed != 0 {
+		ctx = withTaskSeed(ctx, repro.Seed)
+	}
+	if repro.PromptTemplate != "" {
+		ctx = context.WithValue(ctx, promptTemplateKey{}, repro.PromptTemplate)
+	}
+
+	out := r.runTask(ctx, suite.Name, *task)
+	if repro.PromptSHA256 != "" && (out.Repro == nil || out.Repro.PromptSHA256 != repro.PromptSHA256) {
+		return out, fmt.Errorf("matchspec: reproduced prompt of task %q differs from the original", res.Task)
+	}
+	return out, nil
+}
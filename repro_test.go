@@ -0,0 +1,107 @@
+package matchspec
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+// seedRecorder is an InferFunc that records the seed param of each call.
+type seedRecorder struct {
+	mu    sync.Mutex
+	seeds map[string]int64
+}
+
+func (s *seedRecorder) infer(ctx context.Context, prompt string) (string, error) {
+	opts, _ := InferOptionsFrom(ctx)
+	seed, _ := seedParam(opts)
+	s.mu.Lock()
+	if s.seeds == nil {
+		s.seeds = make(map[string]int64)
+	}
+	s.seeds[prompt] = seed
+	s.mu.Unlock()
+	return echoInfer(ctx, prompt)
+}
+
+func TestSamplingSeed(t *testing.T) {
+	var rec seedRecorder
+	runner := testRunner(rec.infer)
+	WithSamplingSeed(42)(runner)
+	results, err := runner.RunDetailed(context.Background(), protocol.EvalRun{Suite: "math"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, res := range results {
+		if res.Repro == nil || res.Repro.Seed == 0 || res.Repro.PromptSHA256 == "" {
+			t.Fatalf("result %s repro = %+v", res.Task, res.Repro)
+		}
+	}
+	if results[0].Repro.Seed == results[1].Repro.Seed {
+		t.Error("tasks should get different seeds")
+	}
+	if rec.seeds["1+1"] != results[0].Repro.Seed {
+		t.Errorf("sent seed %d, recorded %d", rec.seeds["1+1"], results[0].Repro.Seed)
+	}
+
+	again := testRunner(echoInfer)
+	WithSamplingSeed(42)(again)
+	results2, _ := again.RunDetailed(context.Background(), protocol.EvalRun{Suite: "math"})
+	if results2[0].Repro.Seed != results[0].Repro.Seed {
+		t.Error("the same run seed should give the same task seeds")
+	}
+
+	if res, _ := testRunner(echoInfer).RunDetailed(context.Background(), protocol.EvalRun{Suite: "math"}); res[0].Repro != nil {
+		t.Errorf("unseeded run recorded %+v", res[0].Repro)
+	}
+}
+
+func TestReproduce(t *testing.T) {
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "gen", Generator: NewArithmeticGenerator(3, 7)})
+	var rec seedRecorder
+	runner := NewRunner(reg, rec.infer, tokentrace.NewReporter("matchspec", ""), WithSamplingSeed(9))
+	results, err := runner.RunDetailed(context.Background(), protocol.EvalRun{Suite: "gen"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := results[2]
+	if orig.Repro.GeneratorSeed != 7 {
+		t.Fatalf("repro = %+v", orig.Repro)
+	}
+
+	// Round-trip through JSON, as --reproduce reads a result file, and
+	// reproduce on a runner whose generator was built with another seed.
+	data, _ := json.Marshal(orig)
+	var loaded Result
+	json.Unmarshal(data, &loaded)
+
+	reg2 := NewSuiteRegistry()
+	reg2.Register(&Suite{Name: "gen", Generator: NewArithmeticGenerator(3, 1)})
+	var rec2 seedRecorder
+	got, err := NewRunner(reg2, rec2.infer, tokentrace.NewReporter("matchspec", "")).Reproduce(context.Background(), loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Task != orig.Task || got.Repro.PromptSHA256 != orig.Repro.PromptSHA256 || got.Repro.Seed != orig.Repro.Seed {
+		t.Errorf("reproduced %+v (%+v), want %+v (%+v)", got, got.Repro, orig, orig.Repro)
+	}
+	if len(rec2.seeds) != 1 {
+		t.Errorf("reproduction made %d calls, want 1", len(rec2.seeds))
+	}
+	for _, seed := range rec2.seeds {
+		if seed != orig.Repro.Seed {
+			t.Errorf("reproduction sent seed %d, want %d", seed, orig.Repro.Seed)
+		}
+	}
+
+	loaded.Repro.PromptSHA256 = "0000"
+	if _, err := NewRunner(reg2, echoInfer, tokentrace.NewReporter("matchspec", "")).Reproduce(context.Background(), loaded); err == nil {
+		t.Error("expected an error when the prompt differs")
+	}
+}
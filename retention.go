@@ -0,0 +1,128 @@
+package matchspec
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DeleteFilter selects runs to delete. Set fields must all match; at least
+// one must be set, so a zero filter cannot delete everything by accident.
+type DeleteFilter struct {
+	RunID string
+	Suite string
+
+	// Before selects runs that started before it, for age-based retention.
+	Before time.Time
+}
+
+func (f DeleteFilter) empty() bool {
+	return f.RunID == "" && f.Suite == "" && f.Before.IsZero()
+}
+
+func (f DeleteFilter) match(rec *RunRecord) bool {
+	if f.RunID != "" && rec.ID != f.RunID {
+		return false
+	}
+	if f.Suite != "" && rec.Suite != f.Suite {
+		return false
+	}
+	return f.Before.IsZero() || rec.StartedAt.Before(f.Before)
+}
+
+// PurgeStats counts what Purge removed.
+type PurgeStats struct {
+	Runs    int `json:"runs"`
+	Results int `json:"results"`
+}
+
+func (rec *RunRecord) deleted() bool {
+	return !rec.DeletedAt.IsZero()
+}
+
+// DeleteRuns soft-deletes the recorded runs matching f and returns how many
+// it deleted. Deleted runs and their results disappear from Runs, Results,
+// summaries, trends, and drift baselines, but are kept until Purge so a
+// mistaken delete can be undone with RestoreRun. Runs in progress are not
+// affected.
+func (r *Runner) DeleteRuns(f DeleteFilter) (int, error) {
+	if f.empty() {
+		return 0, fmt.Errorf("matchspec: delete filter must set a run ID, suite, or age")
+	}
+	now := time.Now()
+	r.storeMu.Lock()
+	defer r.storeMu.Unlock()
+	r.mu.Lock()
+	n := 0
+	var stored []Checkpoint
+	for i := range r.runs {
+		rec := &r.runs[i]
+		if !rec.deleted() && f.match(rec) {
+			rec.DeletedAt = now
+			n++
+			if r.store != nil {
+				stored = append(stored, r.storedRun(*rec))
+			}
+		}
+	}
+	r.mu.Unlock()
+	return n, r.storeRuns(context.Background(), stored)
+}
+
+// RestoreRun undoes the soft delete of the run with the given ID. It
+// returns ErrRunNotFound unless the run is deleted and not yet purged.
+func (r *Runner) RestoreRun(id string) (RunRecord, error) {
+	r.storeMu.Lock()
+	defer r.storeMu.Unlock()
+	r.mu.Lock()
+	for i := range r.runs {
+		if rec := &r.runs[i]; rec.ID == id && rec.deleted() {
+			rec.DeletedAt = time.Time{}
+			restored := *rec
+			var stored []Checkpoint
+			if r.store != nil {
+				stored = append(stored, r.storedRun(restored))
+			}
+			r.mu.Unlock()
+			return restored, r.storeRuns(context.Background(), stored)
+		}
+	}
+	r.mu.Unlock()
+	return RunRecord{}, fmt.Errorf("%w: no deleted run %q", ErrRunNotFound, id)
+}
+
+// Purge permanently removes runs soft-deleted before deletedBefore, with
+// their results, prompts, and responses. A zero deletedBefore purges every
+// deleted run; a later one leaves recent deletes restorable for a grace
+// period. Purged runs are also removed from the result store; failures
+// are counted by StoreErrors.
+func (r *Runner) Purge(deletedBefore time.Time) PurgeStats {
+	r.storeMu.Lock()
+	defer r.storeMu.Unlock()
+	r.mu.Lock()
+	var stats PurgeStats
+	var purged []string
+	runs := r.runs[:0]
+	results := make([]Result, 0, len(r.results))
+	for _, rec := range r.runs {
+		kept := r.results[rec.first : rec.first+rec.count]
+		if rec.deleted() && (deletedBefore.IsZero() || rec.DeletedAt.Before(deletedBefore)) {
+			stats.Runs++
+			stats.Results += len(kept)
+			purged = append(purged, rec.ID)
+			continue
+		}
+		rec.first = len(results)
+		results = append(results, kept...)
+		runs = append(runs, rec)
+	}
+	clear(r.runs[len(runs):])
+	r.runs, r.results = runs, results
+	r.mu.Unlock()
+	if r.store != nil && len(purged) > 0 {
+		if _, err := r.store.Delete(context.Background(), purged...); err != nil {
+			r.storeFailed(1)
+		}
+	}
+	return stats
+}
@@ -0,0 +1,152 @@
+package matchspec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func retentionRunner(t *testing.T, runs int) (*Runner, []string) {
+	t.Helper()
+	infer := func(ctx context.Context, prompt string) (string, error) { return "4", nil }
+	runner := NewRunner(driftRegistry(), infer, tokentrace.NewReporter("matchspec", ""))
+	var ids []string
+	for range runs {
+		if _, err := runner.Run(context.Background(), protocol.EvalRun{Suite: "math"}); err != nil {
+			t.Fatal(err)
+		}
+		runner.mu.Lock()
+		ids = append(ids, runner.runs[len(runner.runs)-1].ID)
+		runner.mu.Unlock()
+	}
+	return runner, ids
+}
+
+func TestDeleteRestorePurge(t *testing.T) {
+	runner, ids := retentionRunner(t, 3)
+
+	if _, err := runner.DeleteRuns(DeleteFilter{}); err == nil {
+		t.Error("empty delete filter accepted")
+	}
+	if n, err := runner.DeleteRuns(DeleteFilter{RunID: ids[0]}); err != nil || n != 1 {
+		t.Fatalf("DeleteRuns = %d, %v", n, err)
+	}
+	if n, _ := runner.DeleteRuns(DeleteFilter{RunID: ids[0]}); n != 0 {
+		t.Errorf("deleting twice = %d", n)
+	}
+	if len(runner.Results()) != 4 || len(runner.ResultsBySuite("math")) != 4 {
+		t.Errorf("results after delete = %d", len(runner.Results()))
+	}
+	if _, ok := runner.GetRun(ids[0]); ok {
+		t.Error("GetRun returned a deleted run")
+	}
+	if _, err := runner.ExportRun(ids[0]); !errors.Is(err, ErrRunNotFound) {
+		t.Errorf("ExportRun of deleted run: %v", err)
+	}
+	if runs, total := runner.Runs(RunFilter{}); total != 2 || runs[0].ID == ids[0] || runs[1].ID == ids[0] {
+		t.Errorf("Runs after delete = %d", total)
+	}
+	if deleted, _ := runner.Runs(RunFilter{Deleted: true}); len(deleted) != 1 || deleted[0].DeletedAt.IsZero() {
+		t.Errorf("deleted runs = %+v", deleted)
+	}
+
+	if _, err := runner.RestoreRun(ids[1]); !errors.Is(err, ErrRunNotFound) {
+		t.Errorf("restoring a live run: %v", err)
+	}
+	if rec, err := runner.RestoreRun(ids[0]); err != nil || !rec.DeletedAt.IsZero() {
+		t.Fatalf("RestoreRun = %+v, %v", rec, err)
+	}
+	if len(runner.Results()) != 6 {
+		t.Errorf("results after restore = %d", len(runner.Results()))
+	}
+
+	// Purge honors the grace period, then removes the run and its results
+	// while keeping the others intact.
+	runner.DeleteRuns(DeleteFilter{RunID: ids[1]})
+	if stats := runner.Purge(time.Now().Add(-time.Hour)); stats.Runs != 0 {
+		t.Errorf("purge within grace period = %+v", stats)
+	}
+	if stats := runner.Purge(time.Time{}); stats != (PurgeStats{Runs: 1, Results: 2}) {
+		t.Errorf("Purge = %+v", stats)
+	}
+	if _, err := runner.RestoreRun(ids[1]); !errors.Is(err, ErrRunNotFound) {
+		t.Errorf("restoring a purged run: %v", err)
+	}
+	if len(runner.Results()) != 4 {
+		t.Errorf("results after purge = %d", len(runner.Results()))
+	}
+	for _, id := range []string{ids[0], ids[2]} {
+		cp, err := runner.ExportRun(id)
+		if err != nil || len(cp.Results) != 2 {
+			t.Errorf("export %s after purge: %d results, %v", id, len(cp.Results), err)
+		}
+	}
+}
+
+func TestDeleteByAge(t *testing.T) {
+	runner, ids := retentionRunner(t, 2)
+	runner.mu.Lock()
+	runner.runs[0].StartedAt = time.Now().Add(-48 * time.Hour)
+	runner.mu.Unlock()
+
+	h := NewHandler(runner, driftRegistry())
+	w := httptest.NewRecorder()
+	h.DeleteResults(w, httptest.NewRequest(http.MethodDelete, "/results?older_than=24h", nil))
+	var resp DeleteResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Deleted != 1 {
+		t.Fatalf("DELETE /results = %d %+v, %v", w.Code, resp, err)
+	}
+	if _, ok := runner.GetRun(ids[0]); ok {
+		t.Error("old run not deleted")
+	}
+	if _, ok := runner.GetRun(ids[1]); !ok {
+		t.Error("recent run deleted")
+	}
+
+	for _, q := range []string{"", "?older_than=-1h", "?before=yesterday", "?before=2025-01-01T00:00:00Z&older_than=1h"} {
+		w := httptest.NewRecorder()
+		h.DeleteResults(w, httptest.NewRequest(http.MethodDelete, "/results"+q, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("DELETE /results%s = %d", q, w.Code)
+		}
+	}
+}
+
+func TestDeleteRunHandlers(t *testing.T) {
+	runner, ids := retentionRunner(t, 1)
+	h := NewHandler(runner, driftRegistry())
+	do := func(handler http.HandlerFunc, method, path, id string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		req.SetPathValue("id", id)
+		handler(w, req)
+		return w
+	}
+
+	if w := do(h.DeleteRun, http.MethodDelete, "/runs/"+ids[0], ids[0]); w.Code != http.StatusOK {
+		t.Fatalf("DELETE /runs/{id} = %d: %s", w.Code, w.Body)
+	}
+	if w := do(h.DeleteRun, http.MethodDelete, "/runs/"+ids[0], ids[0]); w.Code != http.StatusNotFound {
+		t.Errorf("second delete = %d", w.Code)
+	}
+	if w := do(h.RestoreRun, http.MethodPost, "/runs/"+ids[0]+"/restore", ids[0]); w.Code != http.StatusOK {
+		t.Errorf("restore = %d: %s", w.Code, w.Body)
+	}
+	do(h.DeleteRun, http.MethodDelete, "/runs/"+ids[0], ids[0])
+
+	w := do(h.PurgeResults, http.MethodPost, "/results/purge", "")
+	var stats PurgeStats
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil || stats.Runs != 1 || stats.Results != 2 {
+		t.Errorf("purge = %+v, %v", stats, err)
+	}
+	if w := do(h.PurgeResults, http.MethodPost, "/results/purge?deleted_before=soon", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid deleted_before = %d", w.Code)
+	}
+}
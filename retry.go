@@ -0,0 +1,219 @@
+package matchspec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	misterrors "github.com/greynewell/mist-go/errors"
+	"github.com/greynewell/mist-go/retry"
+	"github.com/greynewell/mist-go/trace"
+)
+
+// WithRetry retries failed inference calls with exponential backoff. Up to
+// budget retries are shared by all tasks of a run; once spent, failures are
+// returned immediately. A budget of zero leaves only p.MaxAttempts as the
+// limit.
+//
+// A Retry-After delay from the backend (see BackendError) pauses every task
+// of the run, not just the one that was throttled, so a rate-limit storm
+// slows the run down instead of multiplying requests.
+func WithRetry(p retry.Policy, budget int) RunnerOption {
+	return func(r *Runner) {
+		r.retryPolicy = p
+		r.retryBudget = budget
+	}
+}
+
+// retryable reports whether an inference error is worth retrying.
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var be *BackendError
+	if errors.As(err, &be) {
+		return be.Temporary()
+	}
+	return misterrors.IsRetryable(err)
+}
+
+type retryBudgetKey struct{}
+
+// retryBudget is the retry allowance of one run, shared by its tasks.
+type retryBudget struct {
+	mu         sync.Mutex
+	unlimited  bool
+	remaining  int
+	retries    int
+	exhausted  bool
+	pauseUntil time.Time
+}
+
+func withRetryBudget(ctx context.Context, budget int) (context.Context, *retryBudget) {
+	b := &retryBudget{unlimited: budget <= 0, remaining: budget}
+	return context.WithValue(ctx, retryBudgetKey{}, b), b
+}
+
+// take spends one retry, reporting false if none are left.
+func (b *retryBudget) take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.unlimited {
+		if b.remaining == 0 {
+			b.exhausted = true
+			return false
+		}
+		b.remaining--
+	}
+	b.retries++
+	return true
+}
+
+// pause holds back every caller of wait for at least d.
+func (b *retryBudget) pause(d time.Duration) {
+	b.mu.Lock()
+	if until := time.Now().Add(d); until.After(b.pauseUntil) {
+		b.pauseUntil = until
+	}
+	b.mu.Unlock()
+}
+
+// wait blocks until any pause requested by the backend has elapsed.
+func (b *retryBudget) wait(ctx context.Context) error {
+	b.mu.Lock()
+	d := time.Until(b.pauseUntil)
+	b.mu.Unlock()
+	return sleep(ctx, d)
+}
+
+// setAttrs records retry usage on a run span. It is a no-op for a nil b.
+func (b *retryBudget) setAttrs(span *trace.Span) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	span.SetAttr("retries", b.retries)
+	span.SetAttr("retry_budget_exhausted", b.exhausted)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// backoff returns the jittered delay before retry number n (1-based).
+func backoff(p retry.Policy, n int) time.Duration {
+	wait := float64(p.InitialWait)
+	for i := 1; i < n; i++ {
+		wait *= max(p.Multiplier, 1)
+	}
+	if p.MaxWait > 0 {
+		wait = min(wait, float64(p.MaxWait))
+	}
+	if p.Jitter > 0 {
+		wait += (rand.Float64()*2 - 1) * wait * p.Jitter
+	}
+	return time.Duration(wait)
+}
+
+// validateLimits checks the timeout and retry settings of a suite or task.
+func validateLimits(suite, where string, timeoutMS int64, retries int) error {
+	if timeoutMS < 0 {
+		return fmt.Errorf("matchspec: suite %q %s has negative timeout_ms %d", suite, where, timeoutMS)
+	}
+	if retries < 0 {
+		return fmt.Errorf("matchspec: suite %q %s has negative retries %d", suite, where, retries)
+	}
+	return nil
+}
+
+// inheritLimits gives t the suite's timeout and retries where it sets none.
+func (s *Suite) inheritLimits(t *Task) {
+	if t.TimeoutMS == 0 {
+		t.TimeoutMS = s.TimeoutMS
+	}
+	if t.Retries == 0 {
+		t.Retries = s.Retries
+	}
+}
+
+// taskRetryPolicy is the runner's retry policy with the task's Retries, if
+// set, in place of its attempt limit. Tasks that set Retries on a runner
+// without a policy back off as retry.DefaultPolicy does.
+func (r *Runner) taskRetryPolicy(task *Task) retry.Policy {
+	p := r.retryPolicy
+	if task.Retries > 0 {
+		if p.MaxAttempts <= 1 {
+			p = retry.DefaultPolicy
+		}
+		p.MaxAttempts = task.Retries + 1
+	}
+	return p
+}
+
+// inferWithRetry calls the inference function for task, each call within
+// the task's timeout, retrying transient errors and timeouts within the
+// retry policy and the run's budget, if any. It returns the number of
+// attempts made and whether any of them timed out.
+func (r *Runner) inferWithRetry(ctx context.Context, task *Task, prompt string) (string, int, bool, error) {
+	p := r.taskRetryPolicy(task)
+	var timedOut bool
+	b, _ := ctx.Value(retryBudgetKey{}).(*retryBudget)
+	for attempt := 1; ; attempt++ {
+		if b != nil {
+			if err := b.wait(ctx); err != nil {
+				return "", attempt - 1, timedOut, err
+			}
+		}
+		response, err := r.inferWithin(ctx, task.TimeoutMS, prompt)
+		timeout := errors.Is(err, errInferTimeout)
+		timedOut = timedOut || timeout
+		if err == nil || attempt >= p.MaxAttempts || !(timeout || retryable(err)) || (b != nil && !b.take()) {
+			return response, attempt, timedOut, err
+		}
+
+		delay := backoff(p, attempt)
+		var be *BackendError
+		if errors.As(err, &be) && be.RetryAfter > 0 {
+			if b != nil {
+				b.pause(be.RetryAfter)
+			}
+			delay = max(delay, be.RetryAfter)
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return "", attempt, timedOut, err
+		}
+	}
+}
+
+// errInferTimeout marks an inference call that missed its task's timeout.
+var errInferTimeout = errors.New("inference timed out")
+
+// inferWithin calls the inference function with a deadline of timeoutMS,
+// if positive. Missing the deadline is reported as errInferTimeout, unless
+// ctx itself ended.
+func (r *Runner) inferWithin(ctx context.Context, timeoutMS int64, prompt string) (string, error) {
+	if timeoutMS <= 0 {
+		return r.infer(ctx, prompt)
+	}
+	tctx, cancel := context.WithTimeout(ctx, time.Duration(timeoutMS)*time.Millisecond)
+	defer cancel()
+	response, err := r.infer(tctx, prompt)
+	if err != nil && ctx.Err() == nil && errors.Is(tctx.Err(), context.DeadlineExceeded) {
+		return "", fmt.Errorf("matchspec: %w after %dms", errInferTimeout, timeoutMS)
+	}
+	return response, err
+}
@@ -0,0 +1,187 @@
+package matchspec
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/retry"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+var fastRetry = retry.Policy{MaxAttempts: 5, InitialWait: time.Millisecond, MaxWait: 5 * time.Millisecond, Multiplier: 2}
+
+func retryRunner(tasks int, infer InferFunc, opts ...RunnerOption) *Runner {
+	s := &Suite{Name: "r"}
+	for i := 0; i < tasks; i++ {
+		s.Tasks = append(s.Tasks, Task{Name: string(rune('a' + i)), Prompt: "p", Expected: "ok"})
+	}
+	reg := NewSuiteRegistry()
+	reg.Register(s)
+	return NewRunner(reg, infer, tokentrace.NewReporter("matchspec", ""), opts...)
+}
+
+func TestRetryTransientErrors(t *testing.T) {
+	var calls atomic.Int64
+	infer := func(context.Context, string) (string, error) {
+		if calls.Add(1) < 3 {
+			return "", &BackendError{StatusCode: http.StatusServiceUnavailable}
+		}
+		return "ok", nil
+	}
+	results, err := retryRunner(1, infer, WithRetry(fastRetry, 0)).RunDetailed(context.Background(), protocol.EvalRun{Suite: "r"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !results[0].Passed || results[0].Attempts != 3 {
+		t.Errorf("result = %+v", results[0])
+	}
+}
+
+func TestRetrySkipsPermanentErrors(t *testing.T) {
+	var calls atomic.Int64
+	infer := func(context.Context, string) (string, error) {
+		calls.Add(1)
+		return "", &BackendError{StatusCode: http.StatusBadRequest}
+	}
+	retryRunner(1, infer, WithRetry(fastRetry, 0)).Run(context.Background(), protocol.EvalRun{Suite: "r"})
+	if calls.Load() != 1 {
+		t.Errorf("calls = %d, want 1", calls.Load())
+	}
+}
+
+func TestRetryBudgetSharedAcrossRun(t *testing.T) {
+	var calls atomic.Int64
+	infer := func(context.Context, string) (string, error) {
+		calls.Add(1)
+		return "", &BackendError{StatusCode: http.StatusTooManyRequests}
+	}
+	retryRunner(3, infer, WithRetry(fastRetry, 2)).Run(context.Background(), protocol.EvalRun{Suite: "r"})
+	// One attempt per task plus the two retries in the budget.
+	if calls.Load() != 5 {
+		t.Errorf("calls = %d, want 5", calls.Load())
+	}
+}
+
+func TestRetryAfterPausesRun(t *testing.T) {
+	var calls atomic.Int64
+	var last time.Time
+	var gap time.Duration
+	infer := func(context.Context, string) (string, error) {
+		now := time.Now()
+		if calls.Add(1) == 1 {
+			last = now
+			return "", &BackendError{StatusCode: http.StatusTooManyRequests, RetryAfter: 50 * time.Millisecond}
+		}
+		if gap == 0 {
+			gap = now.Sub(last)
+		}
+		return "ok", nil
+	}
+	retryRunner(1, infer, WithRetry(fastRetry, 0)).Run(context.Background(), protocol.EvalRun{Suite: "r"})
+	if gap < 50*time.Millisecond {
+		t.Errorf("retried after %v, want >= 50ms", gap)
+	}
+}
+
+func TestRetryableClassification(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{&BackendError{StatusCode: 429}, true},
+		{&BackendError{StatusCode: 502}, true},
+		{&BackendError{StatusCode: 401}, false},
+		{context.Canceled, false},
+		{errors.New("connection reset"), true},
+	}
+	for _, c := range cases {
+		if got := retryable(c.err); got != c.want {
+			t.Errorf("retryable(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if d := parseRetryAfter("7", now); d != 7*time.Second {
+		t.Errorf("seconds = %v", d)
+	}
+	if d := parseRetryAfter(now.Add(30*time.Second).Format(http.TimeFormat), now); d != 30*time.Second {
+		t.Errorf("date = %v", d)
+	}
+	if d := parseRetryAfter("soon", now); d != 0 {
+		t.Errorf("invalid = %v", d)
+	}
+}
+
+func TestInferMuxBackendError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3")
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	_, err := InferMuxFunc(srv.URL, "")(context.Background(), "hi")
+	var be *BackendError
+	if !errors.As(err, &be) {
+		t.Fatalf("err = %v, want *BackendError", err)
+	}
+	if be.StatusCode != 429 || be.RetryAfter != 3*time.Second || be.Message != "slow down" {
+		t.Errorf("BackendError = %+v", be)
+	}
+}
+
+func TestTaskTimeoutAndRetries(t *testing.T) {
+	var slowCalls atomic.Int64
+	infer := func(ctx context.Context, prompt string) (string, error) {
+		if prompt == "hang" || (prompt == "slow" && slowCalls.Add(1) == 1) {
+			<-ctx.Done()
+			return "", ctx.Err()
+		}
+		return "ok", nil
+	}
+	s := &Suite{Name: "limits", TimeoutMS: 20, Retries: 1, Tasks: []Task{
+		{Name: "slow", Prompt: "slow", Expected: "ok"},
+		{Name: "hang", Prompt: "hang", Expected: "ok", Retries: 2},
+		{Name: "fast", Prompt: "fast", Expected: "ok"},
+	}}
+	reg := NewSuiteRegistry()
+	if err := reg.Register(s); err != nil {
+		t.Fatal(err)
+	}
+	results, err := NewRunner(reg, infer, tokentrace.NewReporter("matchspec", "")).RunDetailed(context.Background(), protocol.EvalRun{Suite: "limits"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byTask := map[string]Result{}
+	for _, res := range results {
+		byTask[res.Task] = res
+	}
+	if res := byTask["slow"]; !res.Passed || res.Attempts != 2 || !res.TimedOut {
+		t.Errorf("slow = %+v", res)
+	}
+	if res := byTask["hang"]; res.Passed || res.Attempts != 3 || !res.TimedOut || !strings.Contains(res.Error, "timed out after 20ms") {
+		t.Errorf("hang = %+v", res)
+	}
+	if res := byTask["fast"]; !res.Passed || res.Attempts != 1 || res.TimedOut {
+		t.Errorf("fast = %+v", res)
+	}
+}
+
+func TestSuiteLimitsValidation(t *testing.T) {
+	for _, s := range []Suite{
+		{Name: "s", TimeoutMS: -1, Tasks: []Task{{Name: "a", Prompt: "p"}}},
+		{Name: "s", Tasks: []Task{{Name: "a", Prompt: "p", Retries: -1}}},
+	} {
+		if err := s.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded", s)
+		}
+	}
+}
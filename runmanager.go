@@ -0,0 +1,220 @@
+package matchspec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrRunCancelled is the cancellation cause of a run stopped through
+	// RunManager.Cancel; the run's RunError wraps it.
+	ErrRunCancelled = errors.New("matchspec: run cancelled")
+
+	// ErrRunFinished is returned when cancelling a run that has already
+	// finished.
+	ErrRunFinished = errors.New("matchspec: run already finished")
+)
+
+// ActiveRun describes a run in progress.
+type ActiveRun struct {
+	ID        string    `json:"id"`
+	Suite     string    `json:"suite"`
+	Model     string    `json:"model,omitempty"`
+	Labels    []string  `json:"labels,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	Progress  Progress  `json:"progress"`
+
+	// Cancelled is true once the run has been asked to stop. It stops
+	// after the task in flight and is recorded with the results completed
+	// so far.
+	Cancelled bool `json:"cancelled,omitempty"`
+}
+
+// RunManager tracks the runs in progress on a Runner. Every run is given
+// an ID when it starts (see Runner.Run); the manager looks runs up by that
+// ID, reports their progress, and cancels them.
+type RunManager struct {
+	r *Runner
+}
+
+// Manager returns the manager of r's runs in progress.
+func (r *Runner) Manager() *RunManager {
+	return &RunManager{r: r}
+}
+
+// Active returns the runs in progress, oldest first.
+func (m *RunManager) Active() []ActiveRun {
+	m.r.mu.Lock()
+	runs := make([]ActiveRun, 0, len(m.r.active))
+	for _, live := range m.r.active {
+		runs = append(runs, live.activeRun())
+	}
+	m.r.mu.Unlock()
+	sort.Slice(runs, func(i, j int) bool {
+		if !runs[i].StartedAt.Equal(runs[j].StartedAt) {
+			return runs[i].StartedAt.Before(runs[j].StartedAt)
+		}
+		return runs[i].ID < runs[j].ID
+	})
+	return runs
+}
+
+// Get returns the run in progress with the given ID.
+func (m *RunManager) Get(id string) (ActiveRun, bool) {
+	m.r.mu.Lock()
+	defer m.r.mu.Unlock()
+	live, ok := m.r.active[id]
+	if !ok {
+		return ActiveRun{}, false
+	}
+	return live.activeRun(), true
+}
+
+// Cancel stops the run in progress with the given ID. The run finishes
+// the task in flight, discarding it if it was interrupted, and is recorded
+// with a RunError wrapping ErrRunCancelled and the results completed so
+// far, which remain available to Results and ExportRun. Cancelling a run
+// twice is not an error; cancelling a finished run returns ErrRunFinished
+// and an unknown one ErrRunNotFound. A run still waiting in the start
+// queue (see WithStartQueue) is cancelled there.
+func (m *RunManager) Cancel(id string) (ActiveRun, error) {
+	run, err := m.cancel(id)
+	if !errors.Is(err, ErrRunNotFound) || m.r.startQueue == nil {
+		return run, err
+	}
+	ctx := context.Background()
+	job, qerr := m.r.startQueue.Get(ctx, id)
+	if qerr != nil || job.RunID != id {
+		return run, err
+	}
+	switch job.State {
+	case JobQueued:
+	case JobRunning:
+		return ActiveRun{}, fmt.Errorf("matchspec: run %q is starting on another runner", id)
+	default:
+		return ActiveRun{}, fmt.Errorf("%w: %q", ErrRunFinished, id)
+	}
+	if err := m.r.startQueue.Cancel(ctx, id); err != nil {
+		return ActiveRun{}, err
+	}
+	return ActiveRun{ID: id, Suite: job.Run.Suite, Cancelled: true}, nil
+}
+
+// cancel stops a run this runner holds.
+func (m *RunManager) cancel(id string) (ActiveRun, error) {
+	m.r.mu.Lock()
+	defer m.r.mu.Unlock()
+	live, ok := m.r.active[id]
+	if !ok {
+		if m.r.knownRun(id) {
+			return ActiveRun{}, fmt.Errorf("%w: %q", ErrRunFinished, id)
+		}
+		return ActiveRun{}, fmt.Errorf("%w: %q", ErrRunNotFound, id)
+	}
+	if !live.cancelled {
+		live.cancelled = true
+		live.cancel(ErrRunCancelled)
+	}
+	return live.activeRun(), nil
+}
+
+func (live *liveRun) activeRun() ActiveRun {
+	return ActiveRun{
+		ID:        live.rec.ID,
+		Suite:     live.rec.Suite,
+		Model:     live.rec.Model,
+		Labels:    slices.Clone(live.rec.Labels),
+		StartedAt: live.rec.StartedAt,
+		Progress:  live.progress,
+		Cancelled: live.cancelled,
+	}
+}
+
+// Watch calls fn with each result of the run with the given ID in task
+// order, starting from the first, as the results complete, and returns the
+// run's record once it finishes. A finished run's retained results are
+// replayed at once. Watch returns fn's error if it fails, ctx's error if
+// ctx is done first, and ErrRunNotFound for an unknown run. A run still in
+// the start queue (see WithStartQueue) is waited for; one that fails or is
+// cancelled there, or finishes on another runner, is an error.
+func (m *RunManager) Watch(ctx context.Context, id string, fn func(Result) error) (RunRecord, error) {
+	r := m.r
+	sent := 0
+	for {
+		var batch []Result
+		var done *RunRecord
+		r.mu.Lock()
+		found := true
+		if _, ok := r.pending[id]; ok {
+			// Wait for its tasks to start.
+		} else if f, ok := r.failedStart(id); ok {
+			r.mu.Unlock()
+			return RunRecord{}, f.err
+		} else if live, ok := r.active[id]; ok {
+			batch = slices.Clone(live.results[sent:])
+		} else if i := slices.IndexFunc(r.runs, func(rec RunRecord) bool { return rec.ID == id && !rec.deleted() }); i >= 0 {
+			rec := r.runs[i]
+			if retained := r.results[rec.first : rec.first+rec.count]; sent < len(retained) {
+				batch = slices.Clone(retained[sent:])
+			}
+			done = &rec
+		} else {
+			found = false
+		}
+		wait := r.changedChan()
+		r.mu.Unlock()
+		var poll <-chan time.Time
+		if !found {
+			st, ok := m.queuedStatus(ctx, id)
+			switch {
+			case !ok:
+				return RunRecord{}, fmt.Errorf("%w: %q", ErrRunNotFound, id)
+			case st.Error != "":
+				return RunRecord{}, errors.New(st.Error)
+			case st.Status == RunDone:
+				// A run this runner executed is recorded before its job
+				// completes, so this one ran elsewhere.
+				return RunRecord{}, fmt.Errorf("matchspec: run %q finished on another runner", id)
+			}
+			poll = time.After(startPollInterval)
+		}
+
+		for _, res := range batch {
+			if err := fn(res); err != nil {
+				return RunRecord{}, err
+			}
+		}
+		sent += len(batch)
+		if done != nil {
+			return *done, nil
+		}
+		select {
+		case <-ctx.Done():
+			return RunRecord{}, ctx.Err()
+		case <-wait:
+		case <-poll:
+		}
+	}
+}
+
+// changedChan returns a channel closed the next time a run starts,
+// progresses, or finishes. The caller must hold r.mu.
+func (r *Runner) changedChan() <-chan struct{} {
+	if r.changed == nil {
+		r.changed = make(chan struct{})
+	}
+	return r.changed
+}
+
+// broadcast wakes the callers waiting on changedChan. The caller must hold
+// r.mu.
+func (r *Runner) broadcast() {
+	if r.changed != nil {
+		close(r.changed)
+		r.changed = nil
+	}
+}
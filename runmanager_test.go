@@ -0,0 +1,222 @@
+package matchspec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+// blockingInfer answers "What is 2+2?" and blocks on any other prompt
+// until its context is done, signalling started when it does.
+func blockingInfer(started chan<- struct{}) InferFunc {
+	return func(ctx context.Context, prompt string) (string, error) {
+		if prompt == "What is 2+2?" {
+			return "4", nil
+		}
+		started <- struct{}{}
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+}
+
+func TestRunManagerCancel(t *testing.T) {
+	started := make(chan struct{}, 1)
+	reg := driftRegistry()
+	runner := NewRunner(reg, blockingInfer(started), tokentrace.NewReporter("matchspec", ""))
+	mgr := runner.Manager()
+
+	type outcome struct {
+		results []protocol.EvalResult
+		err     error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		results, err := runner.Run(WithRunLabels(context.Background(), "nightly"), protocol.EvalRun{Suite: "math"})
+		done <- outcome{results, err}
+	}()
+	<-started
+
+	active := mgr.Active()
+	if len(active) != 1 {
+		t.Fatalf("active = %+v", active)
+	}
+	run := active[0]
+	if run.Suite != "math" || run.Progress.Completed != 1 || run.Progress.Total != 2 || len(run.Labels) != 1 || run.Cancelled {
+		t.Errorf("active run = %+v", run)
+	}
+	if got, ok := mgr.Get(run.ID); !ok || got.ID != run.ID {
+		t.Errorf("Get = %+v, %v", got, ok)
+	}
+
+	h := NewHandler(runner, reg)
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/runs/"+run.ID+"/cancel", nil)
+	req.SetPathValue("id", run.ID)
+	h.CancelRun(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var cancelled ActiveRun
+	if err := json.NewDecoder(w.Body).Decode(&cancelled); err != nil || !cancelled.Cancelled {
+		t.Errorf("cancel response = %+v, %v", cancelled, err)
+	}
+
+	out := <-done
+	var runErr *RunError
+	if !errors.As(out.err, &runErr) || !errors.Is(out.err, ErrRunCancelled) {
+		t.Fatalf("err = %v, want RunError wrapping ErrRunCancelled", out.err)
+	}
+	// The interrupted task is discarded; the completed one is kept.
+	if len(out.results) != 1 || out.results[0].Task != "add" || runErr.Completed != 1 {
+		t.Errorf("results = %+v, err = %+v", out.results, runErr)
+	}
+	rec, ok := runner.GetRun(run.ID)
+	if !ok || rec.Summary.Total != 1 || rec.Error == "" {
+		t.Errorf("recorded run = %+v, %v", rec, ok)
+	}
+	if len(mgr.Active()) != 0 {
+		t.Errorf("active after cancel = %+v", mgr.Active())
+	}
+
+	if _, err := mgr.Cancel(run.ID); !errors.Is(err, ErrRunFinished) {
+		t.Errorf("cancel finished run: %v", err)
+	}
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/runs/missing/cancel", nil)
+	req.SetPathValue("id", "missing")
+	h.CancelRun(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown run status = %d", w.Code)
+	}
+}
+
+func TestActiveRunsHandler(t *testing.T) {
+	started := make(chan struct{}, 1)
+	reg := driftRegistry()
+	runner := NewRunner(reg, blockingInfer(started), tokentrace.NewReporter("matchspec", ""))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		runner.Run(ctx, protocol.EvalRun{Suite: "math"})
+		close(done)
+	}()
+	<-started
+
+	w := httptest.NewRecorder()
+	NewHandler(runner, reg).ActiveRuns(w, httptest.NewRequest(http.MethodGet, "/runs/active", nil))
+	var runs []ActiveRun
+	if err := json.NewDecoder(w.Body).Decode(&runs); err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].Suite != "math" {
+		t.Errorf("active runs = %+v", runs)
+	}
+
+	// Cancelling the caller's context stops the run as before.
+	cancel()
+	<-done
+	if _, err := runner.Manager().Cancel(runs[0].ID); !errors.Is(err, ErrRunFinished) {
+		t.Errorf("cancel after finish: %v", err)
+	}
+}
+
+func TestRunManagerWatch(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	infer := func(ctx context.Context, prompt string) (string, error) {
+		if prompt == "What is 3*4?" {
+			started <- struct{}{}
+			<-release
+			return "12", nil
+		}
+		return "4", nil
+	}
+	runner := NewRunner(driftRegistry(), infer, tokentrace.NewReporter("matchspec", ""))
+	id, err := runner.Start(context.Background(), protocol.EvalRun{Suite: "math"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var seen []string
+	first := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		rec, err := runner.Manager().Watch(context.Background(), id, func(res Result) error {
+			seen = append(seen, res.Task)
+			if len(seen) == 1 {
+				close(first)
+			}
+			return nil
+		})
+		if err == nil && (rec.ID != id || rec.Summary.Total != 2) {
+			err = fmt.Errorf("record = %+v", rec)
+		}
+		done <- err
+	}()
+	<-started
+	<-first
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 2 || seen[0] != "add" || seen[1] != "mul" {
+		t.Errorf("watched = %v", seen)
+	}
+
+	// A finished run is replayed.
+	n := 0
+	if _, err := runner.Manager().Watch(context.Background(), id, func(Result) error { n++; return nil }); err != nil || n != 2 {
+		t.Errorf("replay = %d, %v", n, err)
+	}
+	if _, err := runner.Manager().Watch(context.Background(), "missing", func(Result) error { return nil }); !errors.Is(err, ErrRunNotFound) {
+		t.Errorf("unknown run: %v", err)
+	}
+}
+
+func TestStreamRunHandler(t *testing.T) {
+	runner, ids := retentionRunner(t, 1)
+	h := NewHandler(runner, driftRegistry())
+	stream := func(lastID string) string {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/runs/"+ids[0]+"/stream", nil)
+		req.SetPathValue("id", ids[0])
+		if lastID != "" {
+			req.Header.Set("Last-Event-ID", lastID)
+		}
+		h.StreamRun(w, req)
+		if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+			t.Errorf("content type = %q", ct)
+		}
+		return w.Body.String()
+	}
+
+	body := stream("")
+	for _, want := range []string{
+		"id: 0\nevent: result\ndata: {\"suite\":\"math\",\"task\":\"add\"",
+		"id: 1\nevent: result\ndata: {\"suite\":\"math\",\"task\":\"mul\"",
+		"event: summary\ndata: {\"id\":\"" + ids[0] + "\"",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("stream missing %q:\n%s", want, body)
+		}
+	}
+	if body := stream("0"); strings.Contains(body, `"task":"add"`) || !strings.Contains(body, `"task":"mul"`) {
+		t.Errorf("resumed stream:\n%s", body)
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/runs/missing/stream", nil)
+	req.SetPathValue("id", "missing")
+	h.StreamRun(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown run = %d", w.Code)
+	}
+}
@@ -1,16 +1,81 @@
 package matchspec
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/retry"
 	"github.com/greynewell/mist-go/tokentrace"
 	"github.com/greynewell/mist-go/trace"
 )
 
+// Result is the outcome of a single task execution. It embeds the MIST
+// protocol result, so its JSON encoding is a superset of
+// protocol.EvalResult.
+type Result struct {
+	protocol.EvalResult
+
+	// Variant names the prompt variant that produced this result, if the
+	// task declares Variants.
+	Variant string `json:"variant,omitempty"`
+
+	// Verdicts holds each matcher's verdict, primary first, when the task
+	// declares extra Matchers.
+	Verdicts []MatcherVerdict `json:"verdicts,omitempty"`
+
+	// Metadata is copied from the task, so results can be grouped by
+	// dataset, difficulty, or other custom dimensions.
+	Metadata map[string]any `json:"metadata,omitempty"`
+
+	// Difficulty is copied from the task.
+	Difficulty float64 `json:"difficulty,omitempty"`
+
+	// Attempts is the number of inference calls made for the task,
+	// including retries. It is zero for offline-scored results.
+	Attempts int `json:"attempts,omitempty"`
+
+	// Cache is CacheHit if the response came from the runner's response
+	// cache (see WithResponseCache) and CacheMiss if it was stored there.
+	// It is empty without a cache.
+	Cache string `json:"cache,omitempty"`
+
+	// TimedOut reports that an inference call for the task missed its
+	// deadline (see Task.TimeoutMS), even if a retry later succeeded.
+	TimedOut bool `json:"timed_out,omitempty"`
+
+	// Repro records the seeds and settings needed to run the task again
+	// with Reproduce. It is nil if the execution involved no seed.
+	Repro *Repro `json:"repro,omitempty"`
+
+	// Diff is the difference between Expected and the response (see Diff),
+	// recorded for the "diff" matcher when the runner is verbose.
+	Diff string `json:"diff,omitempty"`
+
+	// Shadow is the candidate backend's outcome for the task, when the
+	// runner shadows one (see WithShadow).
+	Shadow *ShadowResult `json:"shadow,omitempty"`
+
+	// MatchMS is the time matching the response took, in milliseconds,
+	// including any extra Matchers. DurationMS covers inference only.
+	MatchMS int64 `json:"match_ms,omitempty"`
+
+	// Details are the specifics the task's matcher attached to its verdict,
+	// such as the regex groups it matched, a diff summary, the judge's
+	// rationale, or where a JSON document differs (see DetailedMatcher).
+	Details map[string]any `json:"details,omitempty"`
+
+	// Repeat numbers this execution of the task, from one, when the run
+	// repeats tasks (see WithRunRepeats). It is zero otherwise.
+	Repeat int `json:"repeat,omitempty"`
+}
+
 // InferFunc is a function that performs inference for evaluation.
 // It takes a prompt and returns the model's response.
 type InferFunc func(ctx context.Context, prompt string) (string, error)
@@ -19,129 +84,661 @@ type InferFunc func(ctx context.Context, prompt string) (string, error)
 type Runner struct {
 	registry *SuiteRegistry
 	infer    InferFunc
+	judge    InferFunc
 	reporter *tokentrace.Reporter
+	sinks    []ResultSink
+
+	judgeCache      JudgeCache
+	responseCache   ResponseCache
+	responseBackend string // identifies the backend in response cache keys
+	subjectPrice    float64
+	judgePrice      float64
+
+	retryPolicy retry.Policy
+	retryBudget int
+
+	samplingSeed int64
+	urlClient    *http.Client
+	sqlDriver    string
+	verbose      bool
+	toxicity     ToxicityClassifier
+	entities     EntityExtractor
+	external     map[string]externalMatcher
+	vars         map[string]any
+	secrets      map[string]string
+	progress     ProgressFunc
+	embed        EmbedFunc
+	shadow       InferFunc
+	shadowName   string
+	golden       *GoldenRecorder
+	share        *ShareRecorder
+	notifiers    []Notifier
+	regression   *DriftConfig
+	metrics      *Metrics
+	events       *EventHub
+	store        ResultStore
+	gate         *runGate
+	baselines    BaselineStore
+	maxScoreDrop float64
+
+	snapshots       SnapshotStore
+	updateSnapshots bool
+
+	modelPin   *ModelPin
+	modelProbe ModelProbe
+
+	signingKey []byte
+	redactors  []Redactor
+	erasers    []Eraser
+	warmup     int
 
-	mu      sync.Mutex
-	results []protocol.EvalResult
+	mu           sync.Mutex
+	results      []Result
+	runs         []RunRecord
+	active       map[string]*liveRun
+	pending      map[string]*pendingRun
+	failedStarts []failedStart // newest last
+	startQueue   JobQueue
+	changed      chan struct{}
+	sinkErrors   int64
+	notifyErrors int64
+	storeErrors  int64
+
+	// storeMu orders writes to the result store (see storeRuns).
+	storeMu sync.Mutex
+}
+
+// RunnerOption configures optional Runner behavior.
+type RunnerOption func(*Runner)
+
+// WithJudge sets the model used by judge-based matchers such as "grounded".
+func WithJudge(judge InferFunc) RunnerOption {
+	return func(r *Runner) { r.judge = judge }
+}
+
+// WithWarmup runs n unmeasured inferences before each suite, cycling through
+// the suite's prompts, so cold-start latency does not skew task durations.
+// Warm-up responses are discarded and never appear in results.
+func WithWarmup(n int) RunnerOption {
+	return func(r *Runner) { r.warmup = n }
 }
 
 // NewRunner creates a runner with the given suite registry and inference function.
-func NewRunner(registry *SuiteRegistry, infer InferFunc, reporter *tokentrace.Reporter) *Runner {
-	return &Runner{
+func NewRunner(registry *SuiteRegistry, infer InferFunc, reporter *tokentrace.Reporter, opts ...RunnerOption) *Runner {
+	r := &Runner{
 		registry: registry,
 		infer:    infer,
 		reporter: reporter,
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
-// Run executes all tasks in the named suite and returns the results.
+// Run executes all tasks in the named suite and returns the results. Use
+// RunDetailed for matchspec's fuller results.
 func (r *Runner) Run(ctx context.Context, run protocol.EvalRun) ([]protocol.EvalResult, error) {
+	results, err := r.RunDetailed(ctx, run)
+	return evalResults(results), err
+}
+
+// RunDetailed is Run returning matchspec's results, which add variants,
+// verdicts, metadata, and match details to the protocol's.
+func (r *Runner) RunDetailed(ctx context.Context, run protocol.EvalRun) ([]Result, error) {
+	results, _, err := r.run(ctx, run)
+	return results, err
+}
+
+// evalResults returns the protocol results that results embed.
+func evalResults(results []Result) []protocol.EvalResult {
+	if results == nil {
+		return nil
+	}
+	out := make([]protocol.EvalResult, len(results))
+	for i, res := range results {
+		out[i] = res.EvalResult
+	}
+	return out
+}
+
+// run implements Run and also returns the ID of the recorded run, or "" if
+// the run never started.
+func (r *Runner) run(ctx context.Context, run protocol.EvalRun) ([]Result, string, error) {
+	return r.execute(ctx, run, nil)
+}
+
+// execute runs the tasks of run. If cp is non-nil, the run continues the
+// checkpointed run under its ID, skipping tasks that already have results.
+func (r *Runner) execute(ctx context.Context, run protocol.EvalRun, cp *Checkpoint) ([]Result, string, error) {
+	release, err := r.admit(ctx, run.Suite)
+	if err != nil {
+		return nil, "", err
+	}
+	defer release()
+
 	suite, ok := r.registry.Get(run.Suite)
 	if !ok {
-		return nil, fmt.Errorf("matchspec: unknown suite %q", run.Suite)
+		return nil, "", fmt.Errorf("matchspec: unknown suite %q", run.Suite)
+	}
+
+	if model := run.Tags["model"]; model != "" {
+		if _, ok := InferOptionsFrom(ctx); !ok {
+			ctx = WithInferOptions(ctx, InferOptions{Model: model})
+		}
 	}
 
 	ctx, span := trace.Start(ctx, "matchspec.eval")
 	span.SetAttr("suite", run.Suite)
+	if r.samplingSeed != 0 {
+		span.SetAttr("sampling_seed", r.samplingSeed)
+	}
+	rec := newRunRecord(ctx, run, span, time.Now())
+	rec.Owner = suite.Owner
+	repeats := RunRepeatsFrom(ctx)
+	if cp != nil {
+		repeats = max(cp.Record.Repeats, 1)
+	}
+	if repeats > 1 {
+		rec.Repeats = repeats
+		span.SetAttr("repeats", repeats)
+	}
+	opts, _ := InferOptionsFrom(ctx)
+	rec.Environment = r.environment(suite, run, opts)
+	var results []Result
+	if cp != nil {
+		rec.ID, rec.StartedAt = cp.Record.ID, cp.Record.StartedAt
+		results = slices.Clone(cp.Results)
+		span.SetAttr("resumed_tasks", len(results))
+	}
+	span.SetAttr("run_id", rec.ID)
 
-	var results []protocol.EvalResult
-	var passed, failed int
+	warning, err := r.checkModelPin(ctx)
+	if err != nil {
+		span.SetAttr("error", err.Error())
+		span.End("error")
+		r.reporter.Report(ctx, span)
+		return nil, "", err
+	}
+	if warning != "" {
+		span.SetAttr("model_pin_warning", warning)
+		rec.Warnings = append(rec.Warnings, warning)
+	}
 
-	tasks := suite.Tasks
+	tasks, err := r.suiteTasks(ctx, suite, span)
+	if err != nil {
+		span.SetAttr("error", r.redact(err.Error()))
+		span.End("error")
+		r.reporter.Report(ctx, span)
+		return nil, "", err
+	}
 	if len(run.Tasks) > 0 {
-		tasks = filterTasks(suite.Tasks, run.Tasks)
+		tasks = filterTasks(tasks, run.Tasks)
+	}
+	tasks = expandRepeats(expandVariants(byPriority(tasks)), repeats)
+	total := len(tasks)
+	if cp != nil {
+		tasks = remainingTasks(tasks, cp.Results)
 	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	if !r.trackRun(rec, results, cancel) {
+		err := fmt.Errorf("%w: %q", ErrRunExists, rec.ID)
+		span.SetAttr("error", err.Error())
+		span.End("error")
+		r.reporter.Report(ctx, span)
+		return nil, "", err
+	}
+	r.publishStarted(rec)
+
+	r.warmUp(ctx, tasks)
+	ctx, usage, budget := r.runScope(ctx)
+	if cp != nil {
+		usage.add(cp.Record.Summary.Usage)
+		if env := cp.Record.Environment; env != nil {
+			for _, b := range env.Backends {
+				usage.backends.add(b)
+			}
+		}
+	}
+
+	var passed, failed int
+	for _, res := range results {
+		if res.Passed {
+			passed++
+		} else {
+			failed++
+		}
+	}
+	var runErr error
+	tracker := newProgressTracker(ctx, rec.ID, suite.Name, total, results)
+	r.trackProgress(rec.ID, results, tracker.p)
 
 	for _, task := range tasks {
+		if ctx.Err() != nil {
+			runErr = &RunError{Suite: suite.Name, Completed: len(results), Total: total, Cause: context.Cause(ctx)}
+			break
+		}
 		result := r.runTask(ctx, suite.Name, task)
+		if ctx.Err() != nil && result.Error != "" {
+			// The task was interrupted, not completed; leave it out so a
+			// resumed run executes it again.
+			runErr = &RunError{Suite: suite.Name, Completed: len(results), Total: total, Cause: context.Cause(ctx)}
+			break
+		}
+		r.emit(ctx, sinkRunOf(&rec), result)
 		results = append(results, result)
 		if result.Passed {
 			passed++
 		} else {
 			failed++
 		}
+		p, overrun := tracker.update(result, time.Now())
+		r.trackProgress(rec.ID, results, p)
+		r.publishTask(rec.ID, result, &p)
+		if overrun {
+			span.SetAttr("projected_overrun", p.EstimatedFinish.Sub(p.Deadline).Milliseconds())
+		}
+		if r.progress != nil {
+			r.progress(p)
+		}
 	}
 
 	span.SetAttr("passed", passed)
 	span.SetAttr("failed", failed)
 	span.SetAttr("total", len(results))
-	if failed > 0 {
+	sloOK := true
+	if checks := suite.CheckLatency(results); len(checks) > 0 {
+		sloOK = SLOsPassed(checks)
+		span.SetAttr("slo_passed", sloOK)
+	}
+	rec.Gates = suite.CheckGates(Summarize(results))
+	gatesOK := GatesPassed(rec.Gates)
+	if len(rec.Gates) > 0 {
+		span.SetAttr("gates_passed", gatesOK)
+	}
+	budget.setAttrs(span)
+	if runErr != nil {
+		span.SetAttr("error", r.redact(runErr.Error()))
+	}
+	if failed > 0 || !sloOK || !gatesOK || runErr != nil {
 		span.End("error")
 	} else {
 		span.End("ok")
 	}
+	r.reporter.Report(context.WithoutCancel(ctx), span)
+
+	r.finishRun(ctx, rec, results, usage, runErr)
+	return results, rec.ID, runErr
+}
+
+// runScope attaches the run's token usage meter and, if retries are
+// enabled, its retry budget to ctx.
+func (r *Runner) runScope(ctx context.Context) (context.Context, *runUsage, *retryBudget) {
+	ctx, usage := withRunUsage(ctx)
+	var budget *retryBudget
+	if r.retryPolicy.MaxAttempts > 1 {
+		ctx, budget = withRetryBudget(ctx, r.retryBudget)
+	}
+	return ctx, usage, budget
+}
+
+// RunError reports a run that stopped before all of its tasks completed.
+// Run returns it together with the results of the tasks that did complete.
+type RunError struct {
+	Suite     string `json:"suite"`
+	Completed int    `json:"completed"`
+	Total     int    `json:"total"`
+	Cause     error  `json:"-"`
+}
+
+func (e *RunError) Error() string {
+	return fmt.Sprintf("matchspec: suite %q stopped after %d/%d tasks: %v", e.Suite, e.Completed, e.Total, e.Cause)
+}
+
+func (e *RunError) Unwrap() error { return e.Cause }
+
+// MarshalJSON includes the error message alongside the progress counts.
+func (e *RunError) MarshalJSON() ([]byte, error) {
+	type alias RunError
+	return json.Marshal(struct {
+		*alias
+		Message string `json:"message"`
+	}{(*alias)(e), e.Error()})
+}
+
+// warmUp sends the configured number of warm-up prompts. Errors are
+// ignored: warm-up only primes the backend.
+func (r *Runner) warmUp(ctx context.Context, tasks []Task) {
+	if r.warmup <= 0 || len(tasks) == 0 {
+		return
+	}
+	ctx, span := trace.Start(ctx, "matchspec.warmup")
+	var errs int
+	for i := 0; i < r.warmup && ctx.Err() == nil; i++ {
+		if _, err := r.infer(ctx, promptFor(ctx, &tasks[i%len(tasks)])); err != nil {
+			errs++
+		}
+	}
+	span.SetAttr("count", r.warmup)
+	span.SetAttr("errors", errs)
+	span.End("ok")
 	r.reporter.Report(ctx, span)
+}
 
-	r.mu.Lock()
-	r.results = append(r.results, results...)
-	r.mu.Unlock()
+// suiteTasks returns the suite's static tasks followed by any tasks from its
+// generator. The generator seed, if any, is recorded on span.
+func (r *Runner) suiteTasks(ctx context.Context, suite *Suite, span *trace.Span) ([]Task, error) {
+	tasks := suite.Tasks
+	vars := r.suiteVars(suite)
+	if len(vars) > 0 || len(suite.Headers) > 0 || suite.TimeoutMS > 0 || suite.Retries > 0 {
+		tasks = make([]Task, len(suite.Tasks))
+		for i, t := range suite.Tasks {
+			t.Headers = mergeHeaders(suite.Headers, t.Headers)
+			suite.inheritLimits(&t)
+			if len(vars) > 0 {
+				var err error
+				if t, err = expandTask(suite.Name, t, vars); err != nil {
+					return nil, err
+				}
+			}
+			tasks[i] = t
+		}
+	}
+	if suite.Generator == nil {
+		return tasks, nil
+	}
+	var seed int64
+	if sg, ok := suite.Generator.(SeededGenerator); ok {
+		seed = sg.Seed()
+		span.SetAttr("seed", seed)
+	}
 
-	return results, nil
+	generated, err := suite.Generator.Generate(withVars(ctx, vars))
+	if err != nil {
+		return nil, fmt.Errorf("matchspec: suite %q generator: %w", suite.Name, err)
+	}
+	tasks = slices.Grow(slices.Clip(tasks), len(generated))
+	for i, t := range generated {
+		if err := validateTask(suite.Name, len(suite.Tasks)+i, t); err != nil {
+			return nil, err
+		}
+		t.generatorSeed = seed
+		t.Headers = mergeHeaders(suite.Headers, t.Headers)
+		suite.inheritLimits(&t)
+		if len(vars) > 0 {
+			// Generators render their own text; only headers, which may
+			// come from the suite, are rendered here.
+			if t, err = renderHeaders(suite.Name, t, vars); err != nil {
+				return nil, err
+			}
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, nil
 }
 
-func (r *Runner) runTask(ctx context.Context, suite string, task Task) protocol.EvalResult {
+func (r *Runner) runTask(ctx context.Context, suite string, task Task) Result {
 	ctx, span := trace.Start(ctx, "matchspec.task")
 	span.SetAttr("suite", suite)
 	span.SetAttr("task", task.Name)
+	if task.variant != "" {
+		span.SetAttr("variant", task.variant)
+	}
+	if task.repeat > 0 {
+		span.SetAttr("repeat", task.repeat)
+	}
 
+	ctx = WithHeaders(r.seedTask(withSuiteName(ctx, suite), &task), task.Headers)
+	prompt := promptFor(ctx, &task)
+	repro := reproFor(ctx, &task, prompt)
+	if repro != nil && repro.Seed != 0 {
+		span.SetAttr("sampling_seed", repro.Seed)
+	}
+
+	finishShadow := r.startShadow(ctx, &task, prompt)
 	start := time.Now()
-	response, err := r.infer(ctx, task.Prompt)
+	response, attempts, timedOut, cache, err := r.inferCached(ctx, span, &task, prompt)
 	duration := time.Since(start)
+	if attempts > 1 {
+		span.SetAttr("attempts", attempts)
+	}
+	if timedOut {
+		span.SetAttr("timed_out", true)
+	}
+
+	result := r.scoreTask(ctx, span, suite, task, response, duration, err)
+	result.Attempts = attempts
+	result.TimedOut = timedOut
+	result.Cache = cache
+	result.Repro = repro
+	finishShadow(&result)
+	r.observeTask(ctx, suite, span, result)
+	return result
+}
+
+// scoreTask matches a response against the task and ends span. A non-nil
+// inferErr marks the task failed without matching.
+func (r *Runner) scoreTask(ctx context.Context, span *trace.Span, suite string, task Task, response string, duration time.Duration, inferErr error) Result {
+	var passed bool
+	var score float64
+	var matchTime time.Duration
+	var details map[string]any
+	err := inferErr
+	if err == nil {
+		mctx, collected := withMatchDetails(withSuiteName(ctx, suite))
+		passed, score, matchTime, err = r.tracedMatch(mctx, &task, response)
+		details = r.redactDetails(collected.details())
+		// Results must encode as JSON to be hashed, stored, and served.
+		switch {
+		case err != nil:
+		case nonFinite(score):
+			err = fmt.Errorf("matchspec: task %q: matcher returned score %v", task.Name, score)
+		case nonFinite(details):
+			err = fmt.Errorf("matchspec: task %q: match details hold a non-finite number", task.Name)
+			details = nil
+		}
+	}
+	if err == nil && usesMatcher(&task, "snapshot") {
+		err = r.saveSnapshot(suite, &task, response)
+	}
+	if r.share != nil {
+		r.share.record(suite, &task, exchange{r.redact(promptFor(ctx, &task)), r.redact(task.Expected), r.redact(response)})
+	}
 
 	if err != nil {
-		span.SetAttr("error", err.Error())
+		msg := r.redact(err.Error())
+		span.SetAttr("error", msg)
 		span.End("error")
 		r.reporter.Report(ctx, span)
-		return protocol.EvalResult{
+		return Result{EvalResult: protocol.EvalResult{
 			Suite:      suite,
 			Task:       task.Name,
 			Passed:     false,
 			Score:      0,
 			DurationMS: duration.Milliseconds(),
-			Error:      err.Error(),
-		}
+			Error:      msg,
+		}, Variant: task.variant, Metadata: task.Metadata, Difficulty: task.Difficulty, MatchMS: matchTime.Milliseconds(), Repeat: task.repeat, Details: details}
 	}
 
-	passed, score := task.Match(response)
 	status := "ok"
 	if !passed {
 		status = "error"
+		if r.golden != nil {
+			r.golden.record(suite, r.definedTask(suite, task.Name), &task, r.redact(response))
+		}
+	}
+
+	var verdicts []MatcherVerdict
+	if len(task.Matchers) > 0 {
+		primary := task.Matcher
+		if primary == "" {
+			primary = "contains"
+		}
+		var extra time.Duration
+		verdicts, extra = r.verdicts(withSuiteName(ctx, suite), &task, response, MatcherVerdict{Matcher: primary, Passed: passed, Score: score})
+		matchTime += extra
 	}
 
 	span.SetAttr("passed", passed)
 	span.SetAttr("score", score)
+	span.SetAttr("match_ms", matchTime.Milliseconds())
 	span.End(status)
 	r.reporter.Report(ctx, span)
 
-	return protocol.EvalResult{
+	result := Result{EvalResult: protocol.EvalResult{
 		Suite:      suite,
 		Task:       task.Name,
 		Passed:     passed,
 		Score:      score,
 		DurationMS: duration.Milliseconds(),
+	}, Variant: task.variant, Verdicts: verdicts, Metadata: task.Metadata, Difficulty: task.Difficulty, MatchMS: matchTime.Milliseconds(), Repeat: task.repeat, Details: details}
+	if r.verbose && task.Matcher == "diff" {
+		result.Diff = r.redact(Diff(task.Expected, response))
+	}
+	return result
+}
+
+// match evaluates a response, routing matchers that need runner resources
+// (such as the judge model) and deferring the rest to Task.Match.
+func (r *Runner) match(ctx context.Context, task *Task, response string) (bool, float64, error) {
+	passed, score, err := r.matchWith(ctx, task, response)
+	if err != nil {
+		return false, 0, err
+	}
+	passed, score = task.negate(passed, score)
+	return passed, score, nil
+}
+
+// matchWith is match without Negate.
+func (r *Runner) matchWith(ctx context.Context, task *Task, response string) (bool, float64, error) {
+	if len(task.Assertions) > 0 {
+		return r.matchAssertions(ctx, task, response)
+	}
+	if len(task.ExpectedAny) > 0 {
+		match, done := listDetails(ctx, "expected_any", r.matchWith)
+		defer done()
+		return matchAnyExpected(task, response, match)
+	}
+	if m, ok := r.external[task.Matcher]; ok {
+		return r.matchExternal(ctx, m, task, response)
+	}
+	switch task.Matcher {
+	case "grounded":
+		return matchGrounded(ctx, r.judgeInfer(), task, response)
+	case "urls":
+		if r.urlClient != nil {
+			passed, score := matchURLs(task, response, func(u string) bool { return r.urlResolves(ctx, u) })
+			return passed, score, nil
+		}
+	case "sql":
+		return r.matchSQL(ctx, task, response)
+	case "semantic":
+		return matchSemantic(ctx, r.embed, task, response)
+	case "snapshot":
+		return r.matchSnapshot(ctx, task, response)
+	case "toxicity":
+		if r.toxicity != nil {
+			return matchToxicity(ctx, r.toxicity, task, response)
+		}
+	case "entities":
+		if r.entities != nil {
+			return matchEntities(ctx, r.entities, task, response)
+		}
 	}
+	passed, score, details := task.matchDetails(response)
+	addMatchDetails(ctx, details)
+	return passed, score, nil
 }
 
-// Results returns all collected evaluation results.
+// Results returns all collected evaluation results, except those of
+// soft-deleted runs. QueryResults returns matchspec's fuller results.
 func (r *Runner) Results() []protocol.EvalResult {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	cp := make([]protocol.EvalResult, len(r.results))
-	copy(cp, r.results)
-	return cp
+	return evalResults(r.collectResults(func(Result) bool { return true }))
 }
 
 // ResultsBySuite returns results filtered by suite name.
 func (r *Runner) ResultsBySuite(suite string) []protocol.EvalResult {
+	return evalResults(r.collectResults(func(res Result) bool { return res.Suite == suite }))
+}
+
+// ResultFilter selects results. Zero fields match everything. Since and
+// Until bound the start time of the result's run: Since is inclusive,
+// Until exclusive. Passed, if set, keeps passing or failing results only.
+type ResultFilter struct {
+	Suite  string
+	Task   string
+	RunID  string
+	Passed *bool
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+	Offset int
+}
+
+// QueryResults returns the results matching f, in the order they were
+// collected, skipping Offset and returning at most Limit (all if zero),
+// along with the total number of matches. Results of soft-deleted runs
+// are left out.
+func (r *Runner) QueryResults(f ResultFilter) ([]Result, int) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	var filtered []protocol.EvalResult
-	for _, res := range r.results {
-		if res.Suite == suite {
-			filtered = append(filtered, res)
+	out := []Result{}
+	total := 0
+	for _, rec := range r.runs {
+		switch {
+		case rec.deleted(),
+			f.RunID != "" && rec.ID != f.RunID,
+			!f.Since.IsZero() && rec.StartedAt.Before(f.Since),
+			!f.Until.IsZero() && !rec.StartedAt.Before(f.Until):
+			continue
+		}
+		for _, res := range r.results[rec.first : rec.first+rec.count] {
+			if f.Suite != "" && res.Suite != f.Suite ||
+				f.Task != "" && res.Task != f.Task ||
+				f.Passed != nil && res.Passed != *f.Passed {
+				continue
+			}
+			total++
+			if total > f.Offset && (f.Limit == 0 || len(out) < f.Limit) {
+				out = append(out, res)
+			}
 		}
 	}
-	return filtered
+	return out, total
+}
+
+// collectResults returns the results of runs that are not soft-deleted
+// for which keep reports true, in the order they were collected.
+func (r *Runner) collectResults(keep func(Result) bool) []Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := []Result{}
+	for _, rec := range r.runs {
+		if rec.deleted() {
+			continue
+		}
+		for _, res := range r.results[rec.first : rec.first+rec.count] {
+			if keep(res) {
+				out = append(out, res)
+			}
+		}
+	}
+	return out
+}
+
+// byPriority returns tasks ordered by descending Priority, preserving suite
+// order among equals. tasks itself is not modified.
+func byPriority(tasks []Task) []Task {
+	if !slices.IsSortedFunc(tasks, comparePriority) {
+		tasks = slices.Clone(tasks)
+		slices.SortStableFunc(tasks, comparePriority)
+	}
+	return tasks
+}
+
+func comparePriority(a, b Task) int {
+	return cmp.Compare(b.Priority, a.Priority)
 }
 
 func filterTasks(all []Task, names []string) []Task {
@@ -0,0 +1,221 @@
+package matchspec
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/trace"
+)
+
+// RunRecord describes one completed Run or Score call.
+type RunRecord struct {
+	ID         string            `json:"id"`
+	Suite      string            `json:"suite"`
+	Model      string            `json:"model,omitempty"`
+	Tags       map[string]string `json:"tags,omitempty"`
+	Labels     []string          `json:"labels,omitempty"`
+	TraceID    string            `json:"trace_id,omitempty"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt time.Time         `json:"finished_at"`
+	Summary    Summary           `json:"summary"`
+	Error      string            `json:"error,omitempty"`
+
+	// Owner is the suite's owner when the run started.
+	Owner *SuiteOwner `json:"owner,omitempty"`
+
+	// Hash is the SHA-256 of the run's results (see HashResults). Signature
+	// is its HMAC when the runner has a signing key.
+	Hash      string `json:"hash"`
+	Signature string `json:"signature,omitempty"`
+
+	// Environment records the versions, host, and configuration the run
+	// executed with.
+	Environment *RunEnvironment `json:"environment,omitempty"`
+
+	// Warnings are problems that did not stop the run, such as a model pin
+	// mismatch under PinWarn.
+	Warnings []string `json:"warnings,omitempty"`
+
+	// DeletedAt is when the run was soft-deleted (see DeleteRuns). Deleted
+	// runs and their results are hidden until restored or purged.
+	DeletedAt time.Time `json:"deleted_at,omitzero"`
+
+	// ErasedAt is when text in the run's results was last erased (see
+	// Runner.Erase). Hash and Signature cover the erased results.
+	ErasedAt time.Time `json:"erased_at,omitzero"`
+
+	// Baseline is how the run compared with its suite's baseline, when
+	// the runner has one (see WithBaselines).
+	Baseline *BaselineComparison `json:"baseline,omitempty"`
+
+	// Gates are the suite's quality gate checks (see Suite.CheckGates).
+	Gates []GateResult `json:"gates,omitempty"`
+
+	// Repeats is how many times the run executed each task, if more than
+	// once (see WithRunRepeats).
+	Repeats int `json:"repeats,omitempty"`
+
+	// run is the request that started the run. first and count locate its
+	// results in Runner.results; count is zero if they were not retained.
+	run          protocol.EvalRun
+	first, count int
+}
+
+// RunFilter selects run records. Zero fields match everything; Labels
+// matches runs carrying all of them. Deleted selects soft-deleted runs
+// instead of live ones.
+type RunFilter struct {
+	Suite   string
+	Model   string
+	Labels  []string
+	Since   time.Time
+	Deleted bool
+	Limit   int
+	Offset  int
+}
+
+func (f RunFilter) match(rec *RunRecord) bool {
+	if rec.deleted() != f.Deleted {
+		return false
+	}
+	if f.Suite != "" && rec.Suite != f.Suite {
+		return false
+	}
+	if f.Model != "" && rec.Model != f.Model {
+		return false
+	}
+	if !f.Since.IsZero() && rec.StartedAt.Before(f.Since) {
+		return false
+	}
+	return rec.HasLabels(f.Labels)
+}
+
+// newRunRecord starts a record for run. The model is taken from the
+// "model" tag and the labels from ctx (see WithRunLabels); the ID is the
+// one Start assigned, if any.
+func newRunRecord(ctx context.Context, run protocol.EvalRun, span *trace.Span, started time.Time) RunRecord {
+	id, _ := ctx.Value(runIDKey{}).(string)
+	if id == "" {
+		id = trace.NewID()
+		noteAuditRun(ctx, id)
+	}
+	return RunRecord{
+		ID:        id,
+		Suite:     run.Suite,
+		Model:     run.Tags["model"],
+		Tags:      run.Tags,
+		Labels:    RunLabelsFrom(ctx),
+		TraceID:   span.TraceID,
+		StartedAt: started,
+		run:       run,
+	}
+}
+
+// finishRun completes rec and stores it together with its results. usage,
+// if non-nil, is the run's token spend.
+func (r *Runner) finishRun(ctx context.Context, rec RunRecord, results []Result, usage *runUsage, err error) {
+	if r.baselines != nil {
+		r.compareBaseline(&rec, results)
+	}
+	t := r.newRunTally()
+	for _, res := range results {
+		t.add(res)
+	}
+	r.recordRun(ctx, rec, t, results, usage, err)
+}
+
+// runTally accumulates the summary and content hash of a run's results as
+// they complete.
+type runTally struct {
+	summary summaryBuilder
+	digest  *resultDigest
+}
+
+func (r *Runner) newRunTally() *runTally {
+	return &runTally{digest: newResultDigest(r.signingKey)}
+}
+
+func (t *runTally) add(res Result) {
+	t.summary.add(res)
+	// An unencodable result fails the run when the digest is summed.
+	t.digest.add(res)
+}
+
+// recordRun completes rec from t, stores it, and notifies the runner's
+// notifiers. results are retained for Results; streamed runs pass nil.
+func (r *Runner) recordRun(ctx context.Context, rec RunRecord, t *runTally, results []Result, usage *runUsage, err error) RunRecord {
+	rec.FinishedAt = time.Now()
+	rec.Summary = t.summary.summary()
+	rec.Summary.Usage = r.tokenUsage(usage)
+	if rec.Environment != nil && usage != nil {
+		rec.Environment.Backends = usage.backends.list()
+	}
+	var hashErr error
+	rec.Hash, rec.Signature, hashErr = t.digest.sums()
+	if err = errors.Join(err, hashErr); err != nil {
+		rec.Error = r.redact(err.Error())
+	}
+
+	r.storeMu.Lock()
+	r.mu.Lock()
+	rec.first, rec.count = len(r.results), len(results)
+	r.results = append(r.results, results...)
+	r.runs = append(r.runs, rec)
+	delete(r.active, rec.ID)
+	r.broadcast()
+	var stored []Checkpoint
+	if r.store != nil {
+		stored = append(stored, r.storedRun(rec))
+	}
+	r.mu.Unlock()
+	r.storeRuns(context.WithoutCancel(ctx), stored)
+	r.storeMu.Unlock()
+	if r.metrics != nil {
+		r.metrics.observeRun(rec)
+	}
+	r.notify(ctx, rec, results)
+	return rec
+}
+
+// Runs returns the run records matching f, newest first, along with the
+// total number of matches before paging.
+func (r *Runner) Runs(f RunFilter) ([]RunRecord, int) {
+	r.mu.Lock()
+	var matched []RunRecord
+	for i := range r.runs {
+		if f.match(&r.runs[i]) {
+			matched = append(matched, r.runs[i])
+		}
+	}
+	r.mu.Unlock()
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].StartedAt.After(matched[j].StartedAt)
+	})
+
+	total := len(matched)
+	if f.Offset >= total {
+		return []RunRecord{}, total
+	}
+	matched = matched[f.Offset:]
+	if f.Limit > 0 && f.Limit < len(matched) {
+		matched = matched[:f.Limit]
+	}
+	return matched, total
+}
+
+// GetRun returns the record with the given ID, unless it was
+// soft-deleted.
+func (r *Runner) GetRun(id string) (RunRecord, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rec := range r.runs {
+		if rec.ID == id && !rec.deleted() {
+			return rec, true
+		}
+	}
+	return RunRecord{}, false
+}
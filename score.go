@@ -0,0 +1,92 @@
+package matchspec
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/trace"
+)
+
+// TaskResponse is an already-generated response to a suite task. Either
+// Response or Transcript must be set; for transcripts the last assistant
+// message is graded.
+type TaskResponse struct {
+	Task       string                 `json:"task"`
+	Response   string                 `json:"response,omitempty"`
+	Transcript []protocol.ChatMessage `json:"transcript,omitempty"`
+	DurationMS int64                  `json:"duration_ms,omitempty"`
+}
+
+// Text returns the response text to grade.
+func (tr *TaskResponse) Text() string {
+	if tr.Response != "" || len(tr.Transcript) == 0 {
+		return tr.Response
+	}
+	for i := len(tr.Transcript) - 1; i >= 0; i-- {
+		if tr.Transcript[i].Role == "assistant" {
+			return tr.Transcript[i].Content
+		}
+	}
+	return ""
+}
+
+// Score grades pre-generated responses against the named suite without
+// calling the inference function. Results are collected like those of Run.
+func (r *Runner) Score(ctx context.Context, suiteName string, responses []TaskResponse) ([]Result, error) {
+	suite, ok := r.registry.Get(suiteName)
+	if !ok {
+		return nil, fmt.Errorf("matchspec: unknown suite %q", suiteName)
+	}
+
+	byName := make(map[string]Task, len(suite.Tasks))
+	for _, t := range suite.Tasks {
+		byName[t.Name] = t
+	}
+	for _, resp := range responses {
+		if _, ok := byName[resp.Task]; !ok {
+			return nil, fmt.Errorf("matchspec: suite %q has no task %q", suiteName, resp.Task)
+		}
+	}
+
+	ctx, span := trace.Start(ctx, "matchspec.score")
+	span.SetAttr("suite", suiteName)
+	rec := newRunRecord(ctx, protocol.EvalRun{Suite: suiteName}, span, time.Now())
+	rec.Owner = suite.Owner
+	rec.Environment = r.environment(suite, rec.run, InferOptions{})
+	span.SetAttr("run_id", rec.ID)
+	ctx, usage := withRunUsage(ctx)
+
+	results := make([]Result, 0, len(responses))
+	var passed, failed int
+	for _, resp := range responses {
+		taskCtx, taskSpan := trace.Start(ctx, "matchspec.task")
+		taskSpan.SetAttr("suite", suiteName)
+		taskSpan.SetAttr("task", resp.Task)
+		taskSpan.SetAttr("offline", true)
+
+		duration := time.Duration(resp.DurationMS) * time.Millisecond
+		result := r.scoreTask(taskCtx, taskSpan, suiteName, byName[resp.Task], resp.Text(), duration, nil)
+		r.emit(ctx, sinkRunOf(&rec), result)
+		results = append(results, result)
+		if result.Passed {
+			passed++
+		} else {
+			failed++
+		}
+	}
+
+	span.SetAttr("passed", passed)
+	span.SetAttr("failed", failed)
+	span.SetAttr("total", len(results))
+	if failed > 0 {
+		span.End("error")
+	} else {
+		span.End("ok")
+	}
+	r.reporter.Report(ctx, span)
+
+	r.finishRun(ctx, rec, results, usage, nil)
+	return results, nil
+}
@@ -0,0 +1,59 @@
+package matchspec
+
+import (
+	"cmp"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// WithSecretVars adds template variables whose values must not leave the
+// runner in plain text, such as API keys or tenant tokens. They render
+// into prompts like other variables (see WithVars) and are sent to the
+// backend, but every occurrence of a value in result errors, diffs, run
+// records, and trace span text is replaced with "[SECRET:name]". Secrets
+// take precedence over variables of the same name.
+func WithSecretVars(secrets map[string]string) RunnerOption {
+	return func(r *Runner) {
+		if r.secrets == nil {
+			r.secrets = make(map[string]string)
+		}
+		maps.Copy(r.secrets, secrets)
+		// Mask secrets before other redactors can alter their text.
+		r.redactors = slices.DeleteFunc(r.redactors, func(rd Redactor) bool { _, ok := rd.(secretRedactor); return ok })
+		r.redactors = append([]Redactor{secretRedactor(r.secrets)}, r.redactors...)
+	}
+}
+
+// SecretVarsFromEnv returns the process environment variables whose names
+// start with prefix, keyed by the rest of the name in lower case: with
+// prefix "MATCHSPEC_SECRET_", MATCHSPEC_SECRET_API_KEY becomes api_key.
+func SecretVarsFromEnv(prefix string) map[string]string {
+	secrets := make(map[string]string)
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		if rest, ok := strings.CutPrefix(name, prefix); ok && rest != "" && value != "" {
+			secrets[strings.ToLower(rest)] = value
+		}
+	}
+	return secrets
+}
+
+// secretRedactor masks secret values, keyed by variable name.
+type secretRedactor map[string]string
+
+// Redact replaces each secret value in s, longest first so that a secret
+// containing another is masked whole.
+func (sr secretRedactor) Redact(s string) string {
+	names := slices.Collect(maps.Keys(sr))
+	slices.SortFunc(names, func(a, b string) int {
+		return cmp.Or(cmp.Compare(len(sr[b]), len(sr[a])), cmp.Compare(a, b))
+	})
+	for _, name := range names {
+		if v := sr[name]; v != "" {
+			s = strings.ReplaceAll(s, v, "[SECRET:"+name+"]")
+		}
+	}
+	return s
+}
@@ -0,0 +1,49 @@
+package matchspec
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestSecretVars(t *testing.T) {
+	var sent []string
+	infer := func(ctx context.Context, prompt string) (string, error) {
+		sent = append(sent, prompt)
+		return "", errors.New("backend rejected " + prompt)
+	}
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "s", Tasks: []Task{
+		{Name: "t", Prompt: "key={{.api_key}} tenant={{.tenant}}", Expected: "x"},
+	}})
+	redactor, _ := NewRegexRedactor("", `sk-\w+`)
+	runner := NewRunner(reg, infer, tokentrace.NewReporter("matchspec", ""),
+		WithRedactor(redactor),
+		WithVars(map[string]any{"tenant": "acme", "api_key": "overridden"}),
+		WithSecretVars(map[string]string{"api_key": "sk-live-123", "short": "sk-live"}))
+
+	results, _ := runner.Run(context.Background(), protocol.EvalRun{Suite: "s"})
+	if len(sent) != 1 || sent[0] != "key=sk-live-123 tenant=acme" {
+		t.Fatalf("sent = %q, want the secret sent to the backend", sent)
+	}
+	if got := results[0].Error; got != "backend rejected key=[SECRET:api_key] tenant=acme" {
+		t.Errorf("error = %q", got)
+	}
+	if strings.Contains(results[0].Error, "[REDACTED]") {
+		t.Error("regex redactor ran before secret masking")
+	}
+}
+
+func TestSecretVarsFromEnv(t *testing.T) {
+	t.Setenv("MSTEST_SECRET_API_KEY", "k1")
+	t.Setenv("MSTEST_SECRET_EMPTY", "")
+	t.Setenv("MSTEST_OTHER", "x")
+	got := SecretVarsFromEnv("MSTEST_SECRET_")
+	if len(got) != 1 || got["api_key"] != "k1" {
+		t.Errorf("SecretVarsFromEnv = %v", got)
+	}
+}
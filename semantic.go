@@ -0,0 +1,71 @@
+package matchspec
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// EmbedFunc returns an embedding vector for text.
+type EmbedFunc func(ctx context.Context, text string) ([]float64, error)
+
+// DefaultSemanticThreshold is the similarity at which "semantic" tasks
+// without a Threshold pass.
+const DefaultSemanticThreshold = 0.8
+
+// WithEmbedder sets the embedding function used by the "semantic" matcher.
+// Without one, "semantic" tasks fail.
+func WithEmbedder(embed EmbedFunc) RunnerOption {
+	return func(r *Runner) { r.embed = embed }
+}
+
+// CosineSimilarity returns the cosine of the angle between a and b, or 0
+// if either is a zero vector. It returns an error if their lengths differ
+// or the similarity is not finite, as when they hold NaN or infinite
+// values.
+func CosineSimilarity(a, b []float64) (float64, error) {
+	if len(a) != len(b) {
+		return 0, fmt.Errorf("matchspec: embedding dimensions differ (%d and %d)", len(a), len(b))
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0, nil
+	}
+	sim := dot / (math.Sqrt(na) * math.Sqrt(nb))
+	if math.IsNaN(sim) || math.IsInf(sim, 0) {
+		return 0, fmt.Errorf("matchspec: cosine similarity is %v; are the embeddings finite?", sim)
+	}
+	return sim, nil
+}
+
+// matchSemantic embeds the response and Expected and scores their cosine
+// similarity, clamped to [0, 1]. It passes when the score reaches
+// Threshold, or DefaultSemanticThreshold if Threshold is unset.
+func matchSemantic(ctx context.Context, embed EmbedFunc, t *Task, response string) (bool, float64, error) {
+	if embed == nil {
+		return false, 0.0, fmt.Errorf("matchspec: semantic matcher requires an embedder (see WithEmbedder)")
+	}
+	want, err := embed(ctx, t.Expected)
+	if err != nil {
+		return false, 0.0, fmt.Errorf("matchspec: embed expected: %w", err)
+	}
+	got, err := embed(ctx, response)
+	if err != nil {
+		return false, 0.0, fmt.Errorf("matchspec: embed response: %w", err)
+	}
+	sim, err := CosineSimilarity(want, got)
+	if err != nil {
+		return false, 0.0, err
+	}
+	score := min(max(sim, 0), 1)
+	threshold := t.Threshold
+	if threshold == 0 {
+		threshold = DefaultSemanticThreshold
+	}
+	return score >= threshold, score, nil
+}
@@ -0,0 +1,96 @@
+package matchspec
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+// bagOfWords embeds text as counts over a fixed vocabulary.
+func bagOfWords(ctx context.Context, text string) ([]float64, error) {
+	vocab := []string{"paris", "capital", "france", "berlin", "germany", "is", "the"}
+	v := make([]float64, len(vocab))
+	for _, w := range strings.Fields(strings.ToLower(text)) {
+		for i, vw := range vocab {
+			if strings.Trim(w, ".,") == vw {
+				v[i]++
+			}
+		}
+	}
+	return v, nil
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b []float64
+		want float64
+	}{
+		{[]float64{1, 0}, []float64{2, 0}, 1},
+		{[]float64{1, 0}, []float64{0, 1}, 0},
+		{[]float64{1, 1}, []float64{-1, -1}, -1},
+		{[]float64{0, 0}, []float64{1, 1}, 0},
+	}
+	for _, tt := range tests {
+		got, err := CosineSimilarity(tt.a, tt.b)
+		if err != nil || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("CosineSimilarity(%v, %v) = %v, %v; want %v", tt.a, tt.b, got, err, tt.want)
+		}
+	}
+	if _, err := CosineSimilarity([]float64{1}, []float64{1, 2}); err == nil {
+		t.Error("expected error for mismatched dimensions")
+	}
+	for _, bad := range [][]float64{{math.NaN(), 1}, {math.Inf(1), 1}, {math.MaxFloat64, math.MaxFloat64}} {
+		if got, err := CosineSimilarity(bad, []float64{1, 1}); err == nil {
+			t.Errorf("CosineSimilarity(%v) = %v, want an error", bad, got)
+		}
+	}
+}
+
+func TestMatchSemantic(t *testing.T) {
+	task := &Task{Matcher: "semantic", Expected: "Paris is the capital of France."}
+
+	passed, score, err := matchSemantic(context.Background(), bagOfWords, task, "The capital of France is Paris")
+	if err != nil || !passed || math.Abs(score-1) > 1e-9 {
+		t.Errorf("paraphrase = %v, %v, %v", passed, score, err)
+	}
+	passed, score, _ = matchSemantic(context.Background(), bagOfWords, task, "Berlin is the capital of Germany")
+	if passed || score <= 0 || score >= DefaultSemanticThreshold {
+		t.Errorf("wrong answer = %v, %v", passed, score)
+	}
+	task.Threshold = score
+	if passed, _, _ := matchSemantic(context.Background(), bagOfWords, task, "Berlin is the capital of Germany"); !passed {
+		t.Error("score at the task threshold should pass")
+	}
+
+	failing := func(context.Context, string) ([]float64, error) { return nil, errors.New("rate limited") }
+	if _, _, err := matchSemantic(context.Background(), failing, task, "x"); err == nil {
+		t.Error("expected embedder error")
+	}
+	if _, _, err := matchSemantic(context.Background(), nil, task, "x"); err == nil {
+		t.Error("expected error without an embedder")
+	}
+	nan := func(context.Context, string) ([]float64, error) { return []float64{math.NaN(), 1}, nil }
+	if passed, _, err := matchSemantic(context.Background(), nan, task, "x"); passed || err == nil {
+		t.Errorf("NaN embedding = %v, %v; want an error", passed, err)
+	}
+}
+
+func TestRunnerSemantic(t *testing.T) {
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "geo", Tasks: []Task{
+		{Name: "capital", Prompt: "paris is the capital of france", Expected: "Paris is the capital of France", Matcher: "semantic"},
+	}})
+	runner := NewRunner(reg, echoInfer, tokentrace.NewReporter("matchspec", ""), WithEmbedder(bagOfWords))
+	results, err := runner.Run(context.Background(), protocol.EvalRun{Suite: "geo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !results[0].Passed || results[0].Score < DefaultSemanticThreshold {
+		t.Errorf("result = %+v", results[0])
+	}
+}
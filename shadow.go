@@ -0,0 +1,137 @@
+package matchspec
+
+import (
+	"context"
+	"time"
+)
+
+// WithShadow sends every task's prompt to a candidate backend as well as
+// the primary one, for silent pre-release comparison. The candidate's
+// response is scored like the primary's and recorded on Result.Shadow, but
+// only the primary result gates the run. Candidate calls run concurrently
+// with the primary, are not retried, and do not count toward the retry
+// budget.
+func WithShadow(name string, candidate InferFunc) RunnerOption {
+	return func(r *Runner) {
+		r.shadowName = name
+		r.shadow = candidate
+	}
+}
+
+// ShadowResult is a candidate backend's outcome for a task.
+type ShadowResult struct {
+	Backend    string  `json:"backend"`
+	Passed     bool    `json:"passed"`
+	Score      float64 `json:"score"`
+	DurationMS int64   `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+
+	// ScoreDelta is the candidate's score minus the primary's.
+	ScoreDelta float64 `json:"score_delta"`
+}
+
+// ShadowSummary compares a candidate backend with the primary across the
+// results of a run.
+type ShadowSummary struct {
+	Backend string `json:"backend"`
+	Tasks   int    `json:"tasks"`
+
+	PrimaryPassRate   float64 `json:"primary_pass_rate"`
+	CandidatePassRate float64 `json:"candidate_pass_rate"`
+	MeanScoreDelta    float64 `json:"mean_score_delta"`
+
+	// Improved and Regressed count tasks the candidate passed where the
+	// primary failed, and the reverse.
+	Improved  int `json:"improved"`
+	Regressed int `json:"regressed"`
+
+	CandidateErrors int `json:"candidate_errors"`
+
+	// LatencyDeltaMS is the candidate's mean task duration minus the
+	// primary's.
+	LatencyDeltaMS float64 `json:"latency_delta_ms"`
+}
+
+// startShadow sends prompt to the candidate backend, if any, and returns a
+// function that waits for its result and scores it against the primary's.
+func (r *Runner) startShadow(ctx context.Context, task *Task, prompt string) func(primary *Result) {
+	if r.shadow == nil {
+		return func(*Result) {}
+	}
+	type outcome struct {
+		response string
+		duration time.Duration
+		err      error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		start := time.Now()
+		response, err := r.shadow(ctx, prompt)
+		done <- outcome{response, time.Since(start), err}
+	}()
+
+	return func(primary *Result) {
+		o := <-done
+		s := &ShadowResult{Backend: r.shadowName, DurationMS: o.duration.Milliseconds()}
+		err := o.err
+		if err == nil {
+			s.Passed, s.Score, err = r.match(ctx, task, o.response)
+		}
+		if err != nil {
+			s.Passed, s.Score = false, 0
+			s.Error = r.redact(err.Error())
+		}
+		s.ScoreDelta = s.Score - primary.Score
+		primary.Shadow = s
+	}
+}
+
+// shadowCounter accumulates a ShadowSummary.
+type shadowCounter struct {
+	s                  ShadowSummary
+	primaryPassed      int
+	candidatePassed    int
+	scoreDelta         float64
+	primaryDurations   float64
+	candidateDurations float64
+}
+
+func (c *shadowCounter) add(r Result) {
+	if r.Shadow == nil {
+		return
+	}
+	c.s.Backend = r.Shadow.Backend
+	c.s.Tasks++
+	if r.Passed {
+		c.primaryPassed++
+	}
+	if r.Shadow.Passed {
+		c.candidatePassed++
+	}
+	switch {
+	case r.Shadow.Passed && !r.Passed:
+		c.s.Improved++
+	case !r.Shadow.Passed && r.Passed:
+		c.s.Regressed++
+	}
+	if r.Shadow.Error != "" {
+		c.s.CandidateErrors++
+	}
+	c.scoreDelta += r.Shadow.ScoreDelta
+	c.primaryDurations += float64(r.DurationMS)
+	c.candidateDurations += float64(r.Shadow.DurationMS)
+}
+
+// summary returns the comparison, or nil if no result has a shadow.
+func (c *shadowCounter) summary() *ShadowSummary {
+	if c.s.Tasks == 0 {
+		return nil
+	}
+	s := c.s
+	n := float64(s.Tasks)
+	s.PrimaryPassRate = float64(c.primaryPassed) / n
+	s.CandidatePassRate = float64(c.candidatePassed) / n
+	s.MeanScoreDelta = c.scoreDelta / n
+	s.LatencyDeltaMS = (c.candidateDurations - c.primaryDurations) / n
+	return &s
+}
@@ -0,0 +1,69 @@
+package matchspec
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestRunnerShadow(t *testing.T) {
+	primary := func(ctx context.Context, prompt string) (string, error) {
+		return map[string]string{"What is 2+2?": "4", "What is 3*4?": "11", "What is 9-1?": "8"}[prompt], nil
+	}
+	candidate := func(ctx context.Context, prompt string) (string, error) {
+		switch prompt {
+		case "What is 2+2?":
+			return "5", nil
+		case "What is 3*4?":
+			return "12", nil
+		}
+		return "", errors.New("candidate unavailable")
+	}
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "math", Tasks: []Task{
+		{Name: "add", Prompt: "What is 2+2?", Expected: "4", Matcher: "exact"},
+		{Name: "mul", Prompt: "What is 3*4?", Expected: "12", Matcher: "exact"},
+		{Name: "sub", Prompt: "What is 9-1?", Expected: "8", Matcher: "exact"},
+	}})
+	runner := NewRunner(reg, primary, tokentrace.NewReporter("matchspec", ""), WithShadow("candidate-v2", candidate))
+
+	results, err := runner.RunDetailed(context.Background(), protocol.EvalRun{Suite: "math"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byTask := make(map[string]Result)
+	for _, r := range results {
+		byTask[r.Task] = r
+	}
+
+	// Only the primary gates the run.
+	if !byTask["add"].Passed || byTask["mul"].Passed || !byTask["sub"].Passed {
+		t.Errorf("primary results changed by shadow: %+v", results)
+	}
+	if s := byTask["add"].Shadow; s == nil || s.Backend != "candidate-v2" || s.Passed || s.ScoreDelta != -1 {
+		t.Errorf("add shadow = %+v", s)
+	}
+	if s := byTask["mul"].Shadow; s == nil || !s.Passed || s.ScoreDelta != 1 {
+		t.Errorf("mul shadow = %+v", s)
+	}
+	if s := byTask["sub"].Shadow; s == nil || s.Error != "candidate unavailable" || byTask["sub"].Error != "" {
+		t.Errorf("sub shadow = %+v", s)
+	}
+
+	sum := Summarize(results).Shadow
+	if sum == nil || sum.Tasks != 3 || sum.Improved != 1 || sum.Regressed != 2 || sum.CandidateErrors != 1 {
+		t.Fatalf("shadow summary = %+v", sum)
+	}
+	if sum.PrimaryPassRate != 2.0/3 || sum.CandidatePassRate != 1.0/3 || sum.MeanScoreDelta != -1.0/3 {
+		t.Errorf("shadow rates = %+v", sum)
+	}
+}
+
+func TestSummarizeWithoutShadow(t *testing.T) {
+	if s := Summarize([]Result{{EvalResult: protocol.EvalResult{Passed: true, Score: 1}}}); s.Shadow != nil {
+		t.Errorf("Shadow = %+v, want nil", s.Shadow)
+	}
+}
@@ -0,0 +1,197 @@
+package matchspec
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Text policies for a sharing bundle.
+const (
+	// ShareKeep includes the text after redaction.
+	ShareKeep = "keep"
+	// ShareHash replaces the text with its SHA-256 digest, so readers can
+	// tell identical texts apart without seeing them.
+	ShareHash = "hash"
+	// ShareOmit leaves the text out.
+	ShareOmit = "omit"
+)
+
+// ShareConfig controls what a sharing bundle reveals.
+type ShareConfig struct {
+	// Patterns are regular expressions whose matches are replaced with
+	// Replacement, or "[REDACTED]", in every text the bundle keeps. PII
+	// adds DefaultPIIPatterns.
+	Patterns    []string `json:"patterns,omitempty"`
+	PII         bool     `json:"pii,omitempty"`
+	Replacement string   `json:"replacement,omitempty"`
+
+	// Prompts applies to prompts and expected outputs, and Responses to
+	// responses: ShareKeep (the default), ShareHash, or ShareOmit.
+	Prompts   string `json:"prompts,omitempty"`
+	Responses string `json:"responses,omitempty"`
+
+	// AnonymizeTasks replaces task names with a digest of the suite and
+	// task name.
+	AnonymizeTasks bool `json:"anonymize_tasks,omitempty"`
+}
+
+// Validate checks the config's patterns and policies.
+func (c ShareConfig) Validate() error {
+	if _, err := c.redactor(); err != nil {
+		return err
+	}
+	for _, p := range []struct{ name, policy string }{{"prompts", c.Prompts}, {"responses", c.Responses}} {
+		switch p.policy {
+		case "", ShareKeep, ShareHash, ShareOmit:
+		default:
+			return fmt.Errorf("matchspec: share %s %q must be %q, %q, or %q", p.name, p.policy, ShareKeep, ShareHash, ShareOmit)
+		}
+	}
+	return nil
+}
+
+func (c ShareConfig) redactor() (*RegexRedactor, error) {
+	patterns := c.Patterns
+	if c.PII {
+		patterns = append(slices.Clone(patterns), DefaultPIIPatterns...)
+	}
+	return NewRegexRedactor(c.Replacement, patterns...)
+}
+
+// ShareBundle is a run's results prepared for publishing outside the
+// organization: the summary and scores, with prompts, expected outputs,
+// and responses redacted, hashed, or omitted according to a ShareConfig.
+// Run IDs, tags, metadata, and the host name are left out.
+type ShareBundle struct {
+	Suite       string          `json:"suite"`
+	Model       string          `json:"model,omitempty"`
+	StartedAt   time.Time       `json:"started_at"`
+	Environment *RunEnvironment `json:"environment,omitempty"`
+	Summary     Summary         `json:"summary"`
+	Results     []SharedResult  `json:"results"`
+}
+
+// SharedResult is one task's outcome in a ShareBundle.
+type SharedResult struct {
+	Task       string  `json:"task"`
+	Variant    string  `json:"variant,omitempty"`
+	Passed     bool    `json:"passed"`
+	Score      float64 `json:"score"`
+	DurationMS int64   `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+
+	Prompt   string `json:"prompt,omitempty"`
+	Expected string `json:"expected,omitempty"`
+	Response string `json:"response,omitempty"`
+}
+
+// exchange is a task's prompt, expected output, and response.
+type exchange struct {
+	prompt, expected, response string
+}
+
+type exchangeKey struct {
+	suite, task, variant string
+}
+
+// ShareRecorder collects the prompts and responses of a run, which results
+// do not carry, for a ShareBundle. Pass it to a runner with
+// WithShareRecorder.
+type ShareRecorder struct {
+	mu        sync.Mutex
+	exchanges map[exchangeKey]exchange
+}
+
+// NewShareRecorder creates an empty recorder.
+func NewShareRecorder() *ShareRecorder {
+	return &ShareRecorder{exchanges: make(map[exchangeKey]exchange)}
+}
+
+// WithShareRecorder records the prompt, expected output, and response of
+// every scored task. They are redacted with the runner's redactors first.
+func WithShareRecorder(s *ShareRecorder) RunnerOption {
+	return func(r *Runner) { r.share = s }
+}
+
+func (s *ShareRecorder) record(suite string, t *Task, ex exchange) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.exchanges[exchangeKey{suite, t.Name, t.variant}] = ex
+}
+
+// Erase forgets the recorded exchanges whose prompt, expected output, or
+// response matches pattern.
+func (s *ShareRecorder) Erase(pattern *regexp.Regexp) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for k, ex := range s.exchanges {
+		if pattern.MatchString(ex.prompt) || pattern.MatchString(ex.expected) || pattern.MatchString(ex.response) {
+			delete(s.exchanges, k)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Bundle builds a sharing bundle from the run record rec and its results.
+// Results whose task the recorder did not see have no texts.
+func (s *ShareRecorder) Bundle(rec RunRecord, results []Result, cfg ShareConfig) (*ShareBundle, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	rd, _ := cfg.redactor()
+	text := func(policy, s string) string {
+		switch {
+		case s == "" || policy == ShareOmit:
+			return ""
+		case policy == ShareHash:
+			return sha256Hex(s)
+		}
+		return rd.Redact(s)
+	}
+
+	b := &ShareBundle{
+		Suite:     rec.Suite,
+		Model:     rec.Model,
+		StartedAt: rec.StartedAt,
+		Summary:   rec.Summary,
+		Results:   make([]SharedResult, 0, len(results)),
+	}
+	if rec.Environment != nil {
+		env := *rec.Environment
+		env.Host = ""
+		b.Environment = &env
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, res := range results {
+		ex := s.exchanges[exchangeKey{res.Suite, res.Task, res.Variant}]
+		sr := SharedResult{
+			Task:       res.Task,
+			Variant:    res.Variant,
+			Passed:     res.Passed,
+			Score:      res.Score,
+			DurationMS: res.DurationMS,
+			Error:      rd.Redact(res.Error),
+			Prompt:     text(cfg.Prompts, ex.prompt),
+			Expected:   text(cfg.Prompts, ex.expected),
+			Response:   text(cfg.Responses, ex.response),
+		}
+		if cfg.AnonymizeTasks {
+			sr.Task = "task-" + sha256Hex(res.Suite + "\x00" + res.Task)[:12]
+		}
+		b.Results = append(b.Results, sr)
+	}
+	return b, nil
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
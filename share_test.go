@@ -0,0 +1,72 @@
+package matchspec
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestShareBundle(t *testing.T) {
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "s", Tasks: []Task{
+		{Name: "acme", Prompt: "Summarize ACME-1234 for bob@example.com", Expected: "ACME-1234"},
+		{Name: "plain", Prompt: "1+1", Expected: "2"},
+	}})
+	share := NewShareRecorder()
+	runner := NewRunner(reg, echoInfer, tokentrace.NewReporter("matchspec", ""), WithShareRecorder(share))
+	results, err := runner.RunDetailed(context.Background(), protocol.EvalRun{Suite: "s", Tags: map[string]string{"model": "m", "team": "secret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs, _ := runner.Runs(RunFilter{})
+
+	b, err := share.Bundle(runs[0], results, ShareConfig{PII: true, Patterns: []string{`ACME-\d+`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Suite != "s" || b.Model != "m" || b.Summary.Total != 2 || len(b.Results) != 2 {
+		t.Fatalf("bundle = %+v", b)
+	}
+	if b.Environment == nil || b.Environment.Host != "" || b.Environment.SuiteHash == "" {
+		t.Errorf("environment = %+v", b.Environment)
+	}
+	got := b.Results[0]
+	if got.Prompt != "Summarize [REDACTED] for [REDACTED]" || got.Expected != "[REDACTED]" ||
+		got.Response != "echo: Summarize [REDACTED] for [REDACTED]" || !got.Passed {
+		t.Errorf("redacted result = %+v", got)
+	}
+
+	b, err = share.Bundle(runs[0], results, ShareConfig{Prompts: ShareHash, Responses: ShareOmit, AnonymizeTasks: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = b.Results[1]
+	if got.Prompt != sha256Hex("1+1") || got.Response != "" || !strings.HasPrefix(got.Task, "task-") || got.Task == b.Results[0].Task {
+		t.Errorf("hashed result = %+v", got)
+	}
+
+	if _, err := share.Bundle(runs[0], results, ShareConfig{Prompts: "encrypt"}); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
+
+func TestLoadConfigShare(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "matchspec.yaml")
+	os.WriteFile(path, []byte("share:\n  pii: true\n  prompts: omit\n"), 0o644)
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Share == nil || !c.Share.PII || c.Share.Prompts != ShareOmit {
+		t.Errorf("share = %+v", c.Share)
+	}
+	os.WriteFile(path, []byte("share:\n  patterns: ['(']\n"), 0o644)
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+}
@@ -0,0 +1,210 @@
+package matchspec
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/greynewell/mist-go/protocol"
+)
+
+// ResultSink receives each evaluation result as soon as its task completes.
+// Implementations must be safe for concurrent use.
+type ResultSink interface {
+	Write(ctx context.Context, result Result) error
+}
+
+// ResultSinkFunc adapts a function to the ResultSink interface.
+type ResultSinkFunc func(ctx context.Context, result Result) error
+
+// Write calls f.
+func (f ResultSinkFunc) Write(ctx context.Context, result Result) error {
+	return f(ctx, result)
+}
+
+// NDJSONSink writes each result as a single JSON line.
+type NDJSONSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewNDJSONSink creates a sink writing newline-delimited JSON to w.
+func NewNDJSONSink(w io.Writer) *NDJSONSink {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &NDJSONSink{enc: enc}
+}
+
+// Write encodes result as one line.
+func (s *NDJSONSink) Write(_ context.Context, result Result) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(result)
+}
+
+// StoreSink writes each run's results to a ResultStore as they complete,
+// so a run in progress can be queried there before it is recorded. Every
+// write replaces the run's stored checkpoint with one holding its results
+// so far, marked incomplete; when the run is recorded, the checkpoint is
+// written complete, with the run's record. Runs replayed from the run
+// cache are already stored and are skipped.
+type StoreSink struct {
+	store ResultStore
+
+	mu   sync.Mutex
+	runs map[string]*Checkpoint
+}
+
+// NewStoreSink creates a sink writing results to store.
+func NewStoreSink(store ResultStore) *StoreSink {
+	return &StoreSink{store: store, runs: make(map[string]*Checkpoint)}
+}
+
+// Write stores the result's run with the result added.
+func (s *StoreSink) Write(ctx context.Context, result Result) error {
+	run, _ := ctx.Value(sinkRunKey{}).(sinkRun)
+	if run.id == "" || run.replayed {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp, ok := s.runs[run.id]
+	if !ok {
+		cp = &Checkpoint{
+			Version: CheckpointVersion,
+			Run:     protocol.EvalRun{Suite: run.suite},
+			Record:  RunRecord{ID: run.id, Suite: run.suite, StartedAt: run.started},
+		}
+		s.runs[run.id] = cp
+	}
+	cp.Results = append(cp.Results, result)
+	cp.Record.Summary = Summarize(cp.Results)
+	cp.Record.Hash = HashResults(cp.Results)
+	return s.store.Append(ctx, *cp)
+}
+
+// RunCompleted stores the run complete and forgets its results.
+func (s *StoreSink) RunCompleted(ctx context.Context, rec RunRecord, results []Result) error {
+	s.mu.Lock()
+	cp, ok := s.runs[rec.ID]
+	delete(s.runs, rec.ID)
+	s.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return s.store.Append(ctx, Checkpoint{Version: CheckpointVersion, Run: cp.Run, Record: rec, Results: results, Complete: rec.Error == ""})
+}
+
+// RegressionDetected does nothing.
+func (s *StoreSink) RegressionDetected(context.Context, RunRecord, []Result, []DriftAlert) error {
+	return nil
+}
+
+// SSESink broadcasts each result as a Server-Sent Event to every client
+// connected to it as an http.Handler, such as a dashboard following all
+// runs at once. Each result is a "result" event whose data is the result
+// as JSON. A client that falls more than the sink's buffer behind is
+// disconnected.
+type SSESink struct {
+	hub *EventHub
+}
+
+// NewSSESink creates a sink whose clients may each fall buffer results
+// behind; buffer <= 0 uses 256.
+func NewSSESink(buffer int) *SSESink {
+	return &SSESink{hub: NewEventHub(buffer)}
+}
+
+// Write sends result to every connected client.
+func (s *SSESink) Write(_ context.Context, result Result) error {
+	s.hub.Publish(Event{Type: EventTaskFinished, Suite: result.Suite, Result: &result})
+	return nil
+}
+
+// Clients returns the number of connected clients.
+func (s *SSESink) Clients() int {
+	return s.hub.Subscribers()
+}
+
+// ServeHTTP streams results to the client until it disconnects.
+func (s *SSESink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	events := s.hub.Subscribe(r.Context(), nil)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for e := range events {
+		if err := writeEvent(w, "result", "", e.Result); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// WithSink adds a sink that receives every result as it completes, in
+// addition to the results returned from Run and Score. A sink that is
+// also a Notifier, such as a StoreSink, is told when each run is recorded.
+func WithSink(sink ResultSink) RunnerOption {
+	return func(r *Runner) {
+		r.sinks = append(r.sinks, sink)
+		if n, ok := sink.(Notifier); ok {
+			r.notifiers = append(r.notifiers, n)
+		}
+	}
+}
+
+type sinkRunKey struct{}
+
+// sinkRun identifies the run whose results are passed to sinks.
+type sinkRun struct {
+	id, suite string
+	started   time.Time
+	replayed  bool
+
+	// run and labels are the run's request and labels, if known.
+	run    protocol.EvalRun
+	labels []string
+}
+
+func sinkRunOf(rec *RunRecord) sinkRun {
+	return sinkRun{id: rec.ID, suite: rec.Suite, started: rec.StartedAt, run: rec.run, labels: rec.Labels}
+}
+
+// SinkRunID returns the ID of the run a result passed to a ResultSink
+// belongs to, for sinks that group results by run.
+func SinkRunID(ctx context.Context) string {
+	run, _ := ctx.Value(sinkRunKey{}).(sinkRun)
+	return run.id
+}
+
+// emit forwards result to every sink. Sink failures never fail a task; they
+// are counted and reported by SinkErrors.
+func (r *Runner) emit(ctx context.Context, run sinkRun, result Result) {
+	if len(r.sinks) == 0 {
+		return
+	}
+	ctx = context.WithValue(ctx, sinkRunKey{}, run)
+	for _, sink := range r.sinks {
+		if err := sink.Write(ctx, result); err != nil {
+			r.mu.Lock()
+			r.sinkErrors++
+			r.mu.Unlock()
+		}
+	}
+}
+
+// SinkErrors returns the number of failed sink writes.
+func (r *Runner) SinkErrors() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sinkErrors
+}
@@ -0,0 +1,139 @@
+package matchspec
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestRunnerSinks(t *testing.T) {
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "math", Tasks: []Task{
+		{Name: "add", Prompt: "1+1", Expected: "echo: 1+1", Matcher: "exact"},
+		{Name: "mul", Prompt: "2*3", Expected: "echo: 2*3", Matcher: "exact"},
+	}})
+
+	var buf bytes.Buffer
+	var seen []string
+	failing := ResultSinkFunc(func(context.Context, Result) error {
+		return fmt.Errorf("sink down")
+	})
+	runner := NewRunner(reg, echoInfer, tokentrace.NewReporter("matchspec", ""),
+		WithSink(NewNDJSONSink(&buf)),
+		WithSink(ResultSinkFunc(func(_ context.Context, r Result) error {
+			seen = append(seen, r.Task)
+			return nil
+		})),
+		WithSink(failing),
+	)
+
+	results, err := runner.Run(context.Background(), protocol.EvalRun{Suite: "math"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || len(seen) != 2 || seen[0] != "add" {
+		t.Errorf("results = %d, seen = %v", len(results), seen)
+	}
+	if runner.SinkErrors() != 2 {
+		t.Errorf("SinkErrors = %d, want 2", runner.SinkErrors())
+	}
+
+	var lines int
+	sc := bufio.NewScanner(&buf)
+	for sc.Scan() {
+		var r protocol.EvalResult
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatalf("invalid NDJSON line %q: %v", sc.Text(), err)
+		}
+		lines++
+	}
+	if lines != 2 {
+		t.Errorf("NDJSON lines = %d, want 2", lines)
+	}
+}
+
+func sinkRegistry() *SuiteRegistry {
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "math", Tasks: []Task{
+		{Name: "add", Prompt: "1+1", Expected: "echo: 1+1", Matcher: "exact"},
+		{Name: "mul", Prompt: "2*3", Expected: "echo: 2*3", Matcher: "exact"},
+	}})
+	return reg
+}
+
+func TestStoreSink(t *testing.T) {
+	store := NewMemoryResultStore()
+	var partial []int
+	var runIDs []string
+	runner := NewRunner(sinkRegistry(), echoInfer, tokentrace.NewReporter("matchspec", ""),
+		WithSink(NewStoreSink(store)),
+		// Runs after the store sink, so it sees the run as stored so far.
+		WithSink(ResultSinkFunc(func(ctx context.Context, _ Result) error {
+			runIDs = append(runIDs, SinkRunID(ctx))
+			cps, _, err := store.Query(ctx, RunFilter{})
+			if err != nil || len(cps) != 1 || cps[0].Complete {
+				return fmt.Errorf("stored %+v, %v", cps, err)
+			}
+			partial = append(partial, len(cps[0].Results))
+			return nil
+		})),
+	)
+	results, err := runner.RunDetailed(context.Background(), protocol.EvalRun{Suite: "math"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runner.SinkErrors() != 0 || len(partial) != 2 || partial[1] != 2 {
+		t.Fatalf("sink errors %d, partial runs %v", runner.SinkErrors(), partial)
+	}
+	runs, _ := runner.Runs(RunFilter{})
+	if runIDs[0] != runs[0].ID {
+		t.Errorf("SinkRunID = %q, want %q", runIDs[0], runs[0].ID)
+	}
+	cps, _, _ := store.Query(context.Background(), RunFilter{})
+	if len(cps) != 1 || !cps[0].Complete || cps[0].Record.Hash != HashResults(results) {
+		t.Errorf("stored run = %+v", cps)
+	}
+}
+
+func TestSSESink(t *testing.T) {
+	sink := NewSSESink(0)
+	srv := httptest.NewServer(sink)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	for sink.Clients() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	runner := NewRunner(sinkRegistry(), echoInfer, tokentrace.NewReporter("matchspec", ""), WithSink(sink))
+	if _, err := runner.Run(context.Background(), protocol.EvalRun{Suite: "math"}); err != nil {
+		t.Fatal(err)
+	}
+	var tasks []string
+	sc := bufio.NewScanner(resp.Body)
+	for len(tasks) < 2 && sc.Scan() {
+		if data, ok := strings.CutPrefix(sc.Text(), "data: "); ok {
+			var res Result
+			if err := json.Unmarshal([]byte(data), &res); err != nil {
+				t.Fatal(err)
+			}
+			tasks = append(tasks, res.Task)
+		}
+	}
+	if resp.Header.Get("Content-Type") != "text/event-stream" || strings.Join(tasks, ",") != "add,mul" {
+		t.Errorf("streamed %v (%s)", tasks, resp.Header.Get("Content-Type"))
+	}
+}
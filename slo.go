@@ -0,0 +1,99 @@
+package matchspec
+
+import (
+	"fmt"
+	"sort"
+)
+
+// LatencySLO is a suite-level latency budget: the given percentile of task
+// durations must not exceed MaxMS.
+type LatencySLO struct {
+	Percentile float64 `json:"percentile"`
+	MaxMS      int64   `json:"max_ms"`
+}
+
+// SLOResult is the outcome of one latency budget check. Task is set for
+// task-level budgets (Task.MaxLatencyMS).
+type SLOResult struct {
+	Task       string  `json:"task,omitempty"`
+	Percentile float64 `json:"percentile,omitempty"`
+	MaxMS      int64   `json:"max_ms"`
+	ActualMS   float64 `json:"actual_ms"`
+	Passed     bool    `json:"passed"`
+}
+
+// String describes the check in a single line.
+func (r SLOResult) String() string {
+	status := "ok"
+	if !r.Passed {
+		status = "VIOLATED"
+	}
+	if r.Task != "" {
+		return fmt.Sprintf("task %s latency %.0fms <= %dms: %s", r.Task, r.ActualMS, r.MaxMS, status)
+	}
+	return fmt.Sprintf("p%g latency %.0fms <= %dms: %s", r.Percentile, r.ActualMS, r.MaxMS, status)
+}
+
+// CheckLatency evaluates the suite's latency SLOs and per-task budgets
+// against results. Results for tasks not in the suite are ignored by the
+// task-level checks, and results answered from the response cache, which
+// did not reach the backend, by all of them.
+func (s *Suite) CheckLatency(results []Result) []SLOResult {
+	var checks []SLOResult
+
+	budgets := make(map[string]int64)
+	for _, t := range s.Tasks {
+		if t.MaxLatencyMS > 0 {
+			budgets[t.Name] = t.MaxLatencyMS
+		}
+	}
+	for _, r := range results {
+		if r.Cache == CacheHit {
+			continue
+		}
+		if budget, ok := budgets[r.Task]; ok {
+			checks = append(checks, SLOResult{
+				Task:     r.Task,
+				MaxMS:    budget,
+				ActualMS: float64(r.DurationMS),
+				Passed:   r.DurationMS <= budget,
+			})
+		}
+	}
+
+	if durations := latencies(results); len(s.LatencySLOs) > 0 && len(durations) > 0 {
+		for _, slo := range s.LatencySLOs {
+			actual := percentile(durations, slo.Percentile)
+			checks = append(checks, SLOResult{
+				Percentile: slo.Percentile,
+				MaxMS:      slo.MaxMS,
+				ActualMS:   actual,
+				Passed:     actual <= float64(slo.MaxMS),
+			})
+		}
+	}
+	return checks
+}
+
+// SLOsPassed reports whether every check passed.
+func SLOsPassed(checks []SLOResult) bool {
+	for _, c := range checks {
+		if !c.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// latencies returns the sorted durations in milliseconds of the results
+// not answered from the response cache.
+func latencies(results []Result) []float64 {
+	var d []float64
+	for _, r := range results {
+		if r.Cache != CacheHit {
+			d = append(d, float64(r.DurationMS))
+		}
+	}
+	sort.Float64s(d)
+	return d
+}
@@ -0,0 +1,46 @@
+package matchspec
+
+import (
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+)
+
+func TestSuiteCheckLatency(t *testing.T) {
+	s := Suite{
+		Name: "s",
+		Tasks: []Task{
+			{Name: "fast", Prompt: "p", MaxLatencyMS: 100},
+			{Name: "slow", Prompt: "p", MaxLatencyMS: 100},
+			{Name: "free", Prompt: "p"},
+		},
+		LatencySLOs: []LatencySLO{{Percentile: 50, MaxMS: 200}, {Percentile: 99, MaxMS: 200}},
+	}
+	results := []Result{
+		{EvalResult: protocol.EvalResult{Task: "fast", DurationMS: 50}},
+		{EvalResult: protocol.EvalResult{Task: "slow", DurationMS: 150}},
+		{EvalResult: protocol.EvalResult{Task: "free", DurationMS: 900}},
+	}
+
+	checks := s.CheckLatency(results)
+	if len(checks) != 4 {
+		t.Fatalf("checks = %d, want 4: %+v", len(checks), checks)
+	}
+	want := []bool{true, false, true, false}
+	for i, c := range checks {
+		if c.Passed != want[i] {
+			t.Errorf("check %d (%s) passed = %v, want %v", i, c, c.Passed, want[i])
+		}
+	}
+	if SLOsPassed(checks) {
+		t.Error("SLOsPassed should be false")
+	}
+}
+
+func TestSuiteCheckLatencyNoBudgets(t *testing.T) {
+	s := Suite{Name: "s", Tasks: []Task{{Name: "t", Prompt: "p"}}}
+	checks := s.CheckLatency([]Result{{EvalResult: protocol.EvalResult{Task: "t", DurationMS: 10}}})
+	if len(checks) != 0 || !SLOsPassed(checks) {
+		t.Errorf("expected no checks, got %+v", checks)
+	}
+}
@@ -0,0 +1,262 @@
+package matchspec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// SnapshotStore holds the last accepted response of each task, the
+// baseline the "snapshot" matcher compares new responses with.
+type SnapshotStore interface {
+	Get(suite, task string) (response string, ok bool, err error)
+	Put(suite, task, response string) error
+}
+
+// MemorySnapshotStore is an in-process SnapshotStore. It is safe for
+// concurrent use.
+type MemorySnapshotStore struct {
+	mu        sync.RWMutex
+	snapshots map[[2]string]string
+}
+
+// NewMemorySnapshotStore returns an empty in-process snapshot store.
+func NewMemorySnapshotStore() *MemorySnapshotStore {
+	return &MemorySnapshotStore{snapshots: make(map[[2]string]string)}
+}
+
+// Get returns the snapshot of a task.
+func (s *MemorySnapshotStore) Get(suite, task string) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.snapshots[[2]string{suite, task}]
+	return v, ok, nil
+}
+
+// Put stores the snapshot of a task, replacing any previous one.
+func (s *MemorySnapshotStore) Put(suite, task, response string) error {
+	s.mu.Lock()
+	s.snapshots[[2]string{suite, task}] = response
+	s.mu.Unlock()
+	return nil
+}
+
+// DirSnapshotStore is a SnapshotStore that keeps one JSON file per suite,
+// mapping task names to responses, in a directory meant to be committed
+// alongside the suites.
+type DirSnapshotStore struct {
+	dir string
+	mu  sync.Mutex
+	storeConfig
+}
+
+// NewDirSnapshotStore returns a store in dir, which is created on first
+// Put.
+func NewDirSnapshotStore(dir string, opts ...StoreOption) *DirSnapshotStore {
+	return &DirSnapshotStore{dir: dir, storeConfig: newStoreConfig(opts)}
+}
+
+func (s *DirSnapshotStore) path(suite string) string {
+	return filepath.Join(s.dir, url.PathEscape(suite)+".json")
+}
+
+func (s *DirSnapshotStore) read(suite string) (map[string]string, error) {
+	data, err := os.ReadFile(s.path(suite))
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("matchspec: snapshots: %w", err)
+	}
+	if data, err = s.open(data, suite); err != nil {
+		return nil, fmt.Errorf("matchspec: snapshots: %w", err)
+	}
+	snapshots := make(map[string]string)
+	if err := json.Unmarshal(data, &snapshots); err != nil {
+		return nil, fmt.Errorf("matchspec: snapshots: %s: %w", s.path(suite), err)
+	}
+	return snapshots, nil
+}
+
+// Get returns the snapshot of a task.
+func (s *DirSnapshotStore) Get(suite, task string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshots, err := s.read(suite)
+	if err != nil {
+		return "", false, err
+	}
+	v, ok := snapshots[task]
+	return v, ok, nil
+}
+
+// Put stores the snapshot of a task, replacing any previous one.
+func (s *DirSnapshotStore) Put(suite, task, response string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshots, err := s.read(suite)
+	if err != nil {
+		return err
+	}
+	snapshots[task] = response
+	return s.write(suite, snapshots)
+}
+
+// write replaces the snapshot file of suite. The caller must hold s.mu.
+func (s *DirSnapshotStore) write(suite string, snapshots map[string]string) error {
+	data, err := json.MarshalIndent(snapshots, "", "  ")
+	if err != nil {
+		return err
+	}
+	if data, err = s.seal(append(data, '\n'), suite); err != nil {
+		return fmt.Errorf("matchspec: snapshots: %w", err)
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("matchspec: snapshots: %w", err)
+	}
+	f, err := os.CreateTemp(s.dir, url.PathEscape(suite)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("matchspec: snapshots: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("matchspec: snapshots: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("matchspec: snapshots: %w", err)
+	}
+	if err := os.Rename(f.Name(), s.path(suite)); err != nil {
+		return fmt.Errorf("matchspec: snapshots: %w", err)
+	}
+	return nil
+}
+
+// Erase removes the snapshots of the memory store whose response matches
+// pattern.
+func (s *MemorySnapshotStore) Erase(pattern *regexp.Regexp) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for k, v := range s.snapshots {
+		if pattern.MatchString(v) {
+			delete(s.snapshots, k)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Erase removes the snapshots whose response matches pattern from every
+// suite file in the directory.
+func (s *DirSnapshotStore) Erase(pattern *regexp.Regexp) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return 0, fmt.Errorf("matchspec: snapshots: %w", err)
+	}
+	n := 0
+	for _, file := range files {
+		suite, err := url.PathUnescape(strings.TrimSuffix(filepath.Base(file), ".json"))
+		if err != nil {
+			continue
+		}
+		snapshots, err := s.read(suite)
+		if err != nil {
+			return n, err
+		}
+		erased := 0
+		for task, v := range snapshots {
+			if pattern.MatchString(v) {
+				delete(snapshots, task)
+				erased++
+			}
+		}
+		if erased == 0 {
+			continue
+		}
+		if err := s.write(suite, snapshots); err != nil {
+			return n, err
+		}
+		n += erased
+	}
+	return n, nil
+}
+
+// WithSnapshots sets the store the "snapshot" matcher reads baselines
+// from. The first response to a task becomes its baseline and passes;
+// later responses pass if they match it (see matchSnapshot). Responses are
+// redacted before they are stored.
+func WithSnapshots(store SnapshotStore) RunnerOption {
+	return func(r *Runner) { r.snapshots = store }
+}
+
+// WithSnapshotUpdate accepts every response to a "snapshot" task as its
+// new baseline, for when drift is intended.
+func WithSnapshotUpdate() RunnerOption {
+	return func(r *Runner) { r.updateSnapshots = true }
+}
+
+type suiteNameKey struct{}
+
+// withSuiteName records the suite a task belongs to for matchers that
+// key state by suite.
+func withSuiteName(ctx context.Context, suite string) context.Context {
+	return context.WithValue(ctx, suiteNameKey{}, suite)
+}
+
+func suiteNameFrom(ctx context.Context) string {
+	s, _ := ctx.Value(suiteNameKey{}).(string)
+	return s
+}
+
+// snapshotKey names a task's snapshot. Prompt variants have their own.
+func snapshotKey(t *Task) string {
+	if t.variant == "" || t.variant == BaseVariant {
+		return t.Name
+	}
+	return t.Name + "@" + t.variant
+}
+
+// matchSnapshot compares the response with the task's stored baseline by
+// DiffRatio and passes when the ratio reaches Threshold, or is 1 if
+// Threshold is unset. Expected is ignored. A task without a baseline, or
+// any task when snapshots are being updated, passes with score 1.
+func (r *Runner) matchSnapshot(ctx context.Context, t *Task, response string) (bool, float64, error) {
+	if r.snapshots == nil {
+		return false, 0.0, fmt.Errorf("matchspec: snapshot matcher requires a snapshot store (see WithSnapshots)")
+	}
+	if r.updateSnapshots {
+		return true, 1.0, nil
+	}
+	baseline, ok, err := r.snapshots.Get(suiteNameFrom(ctx), snapshotKey(t))
+	if err != nil {
+		return false, 0.0, err
+	}
+	if !ok {
+		return true, 1.0, nil
+	}
+	passed, score, details := matchDiff(&Task{Expected: baseline, Threshold: t.Threshold}, r.redact(response))
+	addMatchDetails(ctx, details)
+	return passed, score, nil
+}
+
+// saveSnapshot stores the response as the task's baseline if it has none
+// or snapshots are being updated.
+func (r *Runner) saveSnapshot(suite string, t *Task, response string) error {
+	key := snapshotKey(t)
+	if !r.updateSnapshots {
+		if _, ok, err := r.snapshots.Get(suite, key); err != nil || ok {
+			return err
+		}
+	}
+	return r.snapshots.Put(suite, key, r.redact(response))
+}
@@ -0,0 +1,94 @@
+package matchspec
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestRunnerSnapshot(t *testing.T) {
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "s", Tasks: []Task{
+		{Name: "strict", Prompt: "one two three four", Matcher: "snapshot"},
+		{Name: "loose", Prompt: "one two three four five", Matcher: "snapshot", Threshold: 0.8},
+	}})
+	store := NewDirSnapshotStore(t.TempDir())
+	run := func(infer InferFunc, opts ...RunnerOption) map[string]Result {
+		t.Helper()
+		runner := NewRunner(reg, infer, tokentrace.NewReporter("matchspec", ""), append(opts, WithSnapshots(store))...)
+		results, err := runner.RunDetailed(context.Background(), protocol.EvalRun{Suite: "s"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		byTask := make(map[string]Result)
+		for _, r := range results {
+			byTask[r.Task] = r
+		}
+		return byTask
+	}
+
+	// The first run records baselines.
+	if got := run(echoInfer); !got["strict"].Passed || !got["loose"].Passed {
+		t.Fatalf("first run = %+v", got)
+	}
+	if s, ok, _ := store.Get("s", "strict"); !ok || s != "echo: one two three four" {
+		t.Fatalf("baseline = %q, %v", s, ok)
+	}
+
+	// A drifted response fails the strict task only.
+	drift := func(ctx context.Context, prompt string) (string, error) {
+		return "echo: " + strings.Replace(prompt, "two", "2", 1), nil
+	}
+	got := run(drift)
+	if got["strict"].Passed || got["strict"].Score != 0.8 {
+		t.Errorf("strict drift = %+v", got["strict"])
+	}
+	if !got["loose"].Passed {
+		t.Errorf("loose drift = %+v", got["loose"])
+	}
+	if s, _, _ := store.Get("s", "strict"); s != "echo: one two three four" {
+		t.Errorf("baseline changed without update: %q", s)
+	}
+
+	// Accepting the drift makes it the baseline.
+	run(drift, WithSnapshotUpdate())
+	if got := run(drift); !got["strict"].Passed {
+		t.Errorf("after update = %+v", got["strict"])
+	}
+}
+
+func TestSnapshotWithoutStore(t *testing.T) {
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "s", Tasks: []Task{{Name: "t", Prompt: "p", Matcher: "snapshot"}}})
+	runner := NewRunner(reg, echoInfer, tokentrace.NewReporter("matchspec", ""))
+	results, _ := runner.Run(context.Background(), protocol.EvalRun{Suite: "s"})
+	if len(results) != 1 || !strings.Contains(results[0].Error, "WithSnapshots") {
+		t.Errorf("results = %+v", results)
+	}
+}
+
+func TestDirSnapshotStore(t *testing.T) {
+	dir := t.TempDir()
+	store := NewDirSnapshotStore(filepath.Join(dir, "snapshots"))
+	if _, ok, err := store.Get("a/b", "t"); ok || err != nil {
+		t.Fatalf("Get on empty store = %v, %v", ok, err)
+	}
+	store.Put("a/b", "t", "one")
+	store.Put("a/b", "u", "two")
+	if v, ok, _ := store.Get("a/b", "u"); !ok || v != "two" {
+		t.Errorf("Get = %q, %v", v, ok)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "snapshots", "a%2Fb.json")); err != nil {
+		t.Error(err)
+	}
+
+	// A second store on the same directory sees the snapshots.
+	if v, ok, _ := NewDirSnapshotStore(filepath.Join(dir, "snapshots")).Get("a/b", "t"); !ok || v != "one" {
+		t.Errorf("reopened Get = %q, %v", v, ok)
+	}
+}
@@ -0,0 +1,377 @@
+package matchspec
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of SpoolResultStore.
+const (
+	DefaultSpoolMinBackoff = time.Second
+	DefaultSpoolMaxBackoff = 5 * time.Minute
+	DefaultSpoolTimeout    = 10 * time.Second
+)
+
+// SpoolResultStore keeps runs safe while the result store it wraps is
+// down. A write the store rejects is spooled to files in a directory, and
+// so is every later write until the spool drains, so the store sees them
+// in order. Run retries the spooled writes with exponential backoff. Only
+// the latest write of each run is kept. Queries see spooled writes as if
+// they had reached the store. It is safe for concurrent use.
+type SpoolResultStore struct {
+	store ResultStore
+	dir   string
+	storeConfig
+
+	// MinBackoff and MaxBackoff bound the wait between attempts to drain
+	// the spool, which doubles after each failure. Timeout limits each
+	// call to the wrapped store, so a hung database does not hold up
+	// runs. Zero values use the defaults.
+	MinBackoff, MaxBackoff, Timeout time.Duration
+
+	mu       sync.Mutex
+	pending  map[string]spoolEntry // by run ID
+	seq      int64
+	failures int64
+	lastErr  error
+}
+
+// spoolEntry is one spooled write, stored as <seq>.json.
+type spoolEntry struct {
+	Seq        int64       `json:"seq"`
+	Op         string      `json:"op"` // "append" or "delete"
+	ID         string      `json:"id"`
+	Checkpoint *Checkpoint `json:"checkpoint,omitempty"`
+}
+
+// SpoolStatus is the state of a SpoolResultStore.
+type SpoolStatus struct {
+	// Pending is the number of spooled writes.
+	Pending int `json:"pending"`
+
+	// Failures counts failed calls to the wrapped store, and LastError is
+	// the latest of them while writes are pending.
+	Failures  int64  `json:"failures"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// NewSpoolResultStore wraps store, spooling to dir. Writes spooled by an
+// earlier process are picked up and drained first. opts may encrypt the
+// spool files (see EncryptWith).
+func NewSpoolResultStore(store ResultStore, dir string, opts ...StoreOption) (*SpoolResultStore, error) {
+	s := &SpoolResultStore{store: store, dir: dir, storeConfig: newStoreConfig(opts), pending: make(map[string]spoolEntry)}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("matchspec: result spool: %w", err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("matchspec: result spool: %w", err)
+	}
+	for _, path := range files {
+		e, err := s.read(path)
+		if err != nil {
+			return nil, err
+		}
+		if old, ok := s.pending[e.ID]; ok {
+			if old.Seq > e.Seq {
+				os.Remove(path)
+				continue
+			}
+			os.Remove(s.path(old.Seq))
+		}
+		s.pending[e.ID] = e
+		s.seq = max(s.seq, e.Seq)
+	}
+	return s, nil
+}
+
+func (s *SpoolResultStore) path(seq int64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d.json", seq))
+}
+
+// read loads the spool file at path.
+func (s *SpoolResultStore) read(path string) (spoolEntry, error) {
+	name := strings.TrimSuffix(filepath.Base(path), ".json")
+	data, err := os.ReadFile(path)
+	if err == nil {
+		data, err = s.open(data, name)
+	}
+	var e spoolEntry
+	if err == nil {
+		err = json.Unmarshal(data, &e)
+	}
+	if err == nil && strconv.FormatInt(e.Seq, 10) != strings.TrimLeft(name, "0") {
+		err = errors.New("sequence does not match file name")
+	}
+	if err != nil {
+		return spoolEntry{}, fmt.Errorf("matchspec: result spool %s: %w", path, err)
+	}
+	return e, nil
+}
+
+// spool writes e to disk, replacing any spooled write of the same run.
+// The caller must hold s.mu.
+func (s *SpoolResultStore) spool(e spoolEntry) error {
+	s.seq++
+	e.Seq = s.seq
+	data, err := json.Marshal(e)
+	if err == nil {
+		data, err = s.seal(data, fmt.Sprintf("%020d", e.Seq))
+	}
+	if err != nil {
+		return fmt.Errorf("matchspec: result spool: %w", err)
+	}
+	f, err := os.CreateTemp(s.dir, ".spool-*")
+	if err != nil {
+		return fmt.Errorf("matchspec: result spool: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("matchspec: result spool: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("matchspec: result spool: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("matchspec: result spool: %w", err)
+	}
+	if err := os.Rename(f.Name(), s.path(e.Seq)); err != nil {
+		return fmt.Errorf("matchspec: result spool: %w", err)
+	}
+	if old, ok := s.pending[e.ID]; ok {
+		os.Remove(s.path(old.Seq))
+	}
+	s.pending[e.ID] = e
+	return nil
+}
+
+// call runs f against the wrapped store with the timeout, recording a
+// failure.
+func (s *SpoolResultStore) call(ctx context.Context, f func(ctx context.Context) error) error {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultSpoolTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := f(ctx)
+	if err != nil {
+		s.mu.Lock()
+		s.failures++
+		s.lastErr = err
+		s.mu.Unlock()
+	}
+	return err
+}
+
+// Append writes cp to the store, or spools it if the store fails or
+// earlier writes are still spooled. It fails only if spooling does.
+func (s *SpoolResultStore) Append(ctx context.Context, cp Checkpoint) error {
+	if s.drained() && s.call(ctx, func(ctx context.Context) error { return s.store.Append(ctx, cp) }) == nil {
+		return nil
+	}
+	cp.Results = slices.Clone(cp.Results)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.spool(spoolEntry{Op: "append", ID: cp.Record.ID, Checkpoint: &cp})
+}
+
+// Delete removes the runs from the store, or spools their removal if the
+// store fails or earlier writes are still spooled. Spooled removals count
+// every ID as removed.
+func (s *SpoolResultStore) Delete(ctx context.Context, ids ...string) (int, error) {
+	if s.drained() {
+		var n int
+		err := s.call(ctx, func(ctx context.Context) (err error) {
+			n, err = s.store.Delete(ctx, ids...)
+			return err
+		})
+		if err == nil {
+			return n, nil
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		if err := s.spool(spoolEntry{Op: "delete", ID: id}); err != nil {
+			return 0, err
+		}
+	}
+	return len(ids), nil
+}
+
+// drained reports whether nothing is spooled.
+func (s *SpoolResultStore) drained() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending) == 0
+}
+
+// Query returns the runs in the store matching f, with spooled writes
+// applied.
+func (s *SpoolResultStore) Query(ctx context.Context, f RunFilter) ([]Checkpoint, int, error) {
+	pending := s.entries()
+	if len(pending) == 0 {
+		return s.store.Query(ctx, f)
+	}
+	all := f
+	all.Limit, all.Offset = 0, 0
+	cps, _, err := s.store.Query(ctx, all)
+	if err != nil {
+		return nil, 0, err
+	}
+	cps = withoutSpooled(cps, pending)
+	for _, e := range pending {
+		if e.Op == "append" {
+			cps = append(cps, *e.Checkpoint)
+		}
+	}
+	cps, total := pageCheckpoints(cps, f)
+	return cps, total, nil
+}
+
+// Changes returns the runs written to the store at or after since,
+// leaving out runs with spooled writes: those are newer than the store's
+// copy, and the runner that spooled them already has them.
+func (s *SpoolResultStore) Changes(ctx context.Context, since time.Time) ([]Checkpoint, error) {
+	var out []Checkpoint
+	if c, ok := s.store.(StoreChanges); ok {
+		cps, err := c.Changes(ctx, since)
+		if err != nil {
+			return nil, err
+		}
+		out = cps
+	} else {
+		for _, deleted := range []bool{false, true} {
+			cps, _, err := s.store.Query(ctx, RunFilter{Deleted: deleted})
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, cps...)
+		}
+	}
+	return withoutSpooled(out, s.entries()), nil
+}
+
+// withoutSpooled removes the runs with spooled writes from cps.
+func withoutSpooled(cps []Checkpoint, pending []spoolEntry) []Checkpoint {
+	ids := make(map[string]bool, len(pending))
+	for _, e := range pending {
+		ids[e.ID] = true
+	}
+	return slices.DeleteFunc(cps, func(cp Checkpoint) bool { return ids[cp.Record.ID] })
+}
+
+// entries returns the spooled writes, oldest first.
+func (s *SpoolResultStore) entries() []spoolEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]spoolEntry, 0, len(s.pending))
+	for _, e := range s.pending {
+		out = append(out, e)
+	}
+	slices.SortFunc(out, func(a, b spoolEntry) int { return cmp.Compare(a.Seq, b.Seq) })
+	return out
+}
+
+// Flush writes the spooled writes to the store, oldest first, stopping at
+// the first failure, which it returns.
+func (s *SpoolResultStore) Flush(ctx context.Context) error {
+	for _, e := range s.entries() {
+		err := s.call(ctx, func(ctx context.Context) error {
+			if e.Op == "delete" {
+				_, err := s.store.Delete(ctx, e.ID)
+				return err
+			}
+			return s.store.Append(ctx, *e.Checkpoint)
+		})
+		if err != nil {
+			return err
+		}
+		s.mu.Lock()
+		// A newer write of the run may have been spooled meanwhile; it
+		// stays for the next pass.
+		if cur, ok := s.pending[e.ID]; ok && cur.Seq == e.Seq {
+			delete(s.pending, e.ID)
+			os.Remove(s.path(e.Seq))
+		}
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// Run drains the spool until ctx is done, waiting MinBackoff between
+// passes and doubling the wait, up to MaxBackoff, while the store fails.
+func (s *SpoolResultStore) Run(ctx context.Context) {
+	lo, hi := s.MinBackoff, s.MaxBackoff
+	if lo <= 0 {
+		lo = DefaultSpoolMinBackoff
+	}
+	if hi <= 0 {
+		hi = DefaultSpoolMaxBackoff
+	}
+	wait := lo
+	for {
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if err := s.Flush(ctx); err != nil {
+			wait = min(wait*2, hi)
+			continue
+		}
+		wait = lo
+	}
+}
+
+// Status returns the state of the spool.
+func (s *SpoolResultStore) Status() SpoolStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := SpoolStatus{Pending: len(s.pending), Failures: s.failures}
+	if len(s.pending) > 0 && s.lastErr != nil {
+		st.LastError = s.lastErr.Error()
+	}
+	return st
+}
+
+// HealthCheck reports the wrapped store down while writes are spooled,
+// for BackendMonitor.
+func (s *SpoolResultStore) HealthCheck() HealthCheck {
+	return func(context.Context) error {
+		st := s.Status()
+		if st.Pending == 0 {
+			return nil
+		}
+		return fmt.Errorf("matchspec: %d result store writes spooled: %s", st.Pending, st.LastError)
+	}
+}
+
+// Unwrap returns the store the spool writes to.
+func (s *SpoolResultStore) Unwrap() ResultStore {
+	return s.store
+}
+
+// Close closes the wrapped store if it has a Close method. Spooled writes
+// stay on disk for the next process.
+func (s *SpoolResultStore) Close() error {
+	if c, ok := s.store.(interface{ Close() error }); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package matchspec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+// flakyStore fails every call while down is set.
+type flakyStore struct {
+	*MemoryResultStore
+	down atomic.Bool
+}
+
+var errStoreDown = errors.New("connection refused")
+
+func (s *flakyStore) Append(ctx context.Context, cp Checkpoint) error {
+	if s.down.Load() {
+		return errStoreDown
+	}
+	return s.MemoryResultStore.Append(ctx, cp)
+}
+
+func (s *flakyStore) Query(ctx context.Context, f RunFilter) ([]Checkpoint, int, error) {
+	if s.down.Load() {
+		return nil, 0, errStoreDown
+	}
+	return s.MemoryResultStore.Query(ctx, f)
+}
+
+func (s *flakyStore) Delete(ctx context.Context, ids ...string) (int, error) {
+	if s.down.Load() {
+		return 0, errStoreDown
+	}
+	return s.MemoryResultStore.Delete(ctx, ids...)
+}
+
+func spooledRun(id string, deleted bool) Checkpoint {
+	cp := Checkpoint{Version: CheckpointVersion, Record: RunRecord{ID: id, Suite: "math", StartedAt: time.Now()}}
+	if deleted {
+		cp.Record.DeletedAt = time.Now()
+	}
+	return cp
+}
+
+func TestSpoolResultStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	inner := &flakyStore{MemoryResultStore: NewMemoryResultStore()}
+	s, err := NewSpoolResultStore(inner, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Append(ctx, spooledRun("a", false))
+	s.Append(ctx, spooledRun("gone", false))
+
+	inner.down.Store(true)
+	for _, cp := range []Checkpoint{spooledRun("b", false), spooledRun("a", true)} {
+		if err := s.Append(ctx, cp); err != nil {
+			t.Fatalf("Append while down: %v", err)
+		}
+	}
+	if n, err := s.Delete(ctx, "gone"); err != nil || n != 1 {
+		t.Fatalf("Delete while down = %d, %v", n, err)
+	}
+	// Two writes of run a leave one file.
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(files) != 3 {
+		t.Errorf("spool files = %v", files)
+	}
+	if st := s.Status(); st.Pending != 3 || st.LastError != "connection refused" {
+		t.Errorf("status = %+v", st)
+	}
+	if err := s.HealthCheck()(ctx); err == nil {
+		t.Error("healthy with writes spooled")
+	}
+
+	// Back up, but writes still queue behind the spooled ones.
+	inner.down.Store(false)
+	s.Append(ctx, spooledRun("c", false))
+	if _, total, _ := inner.MemoryResultStore.Query(ctx, RunFilter{}); total != 2 {
+		t.Errorf("store saw a write ahead of the spool: %d runs", total)
+	}
+	cps, total, err := s.Query(ctx, RunFilter{})
+	if err != nil || total != 2 || cps[0].Record.ID != "c" || cps[1].Record.ID != "b" {
+		t.Errorf("Query with spool = %+v (%d), %v", cps, total, err)
+	}
+	if changes, _ := s.Changes(ctx, time.Time{}); len(changes) != 0 {
+		t.Errorf("Changes includes spooled runs: %+v", changes)
+	}
+
+	// A restarted process picks up the spool and drains it.
+	s, err = NewSpoolResultStore(inner, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(files) != 0 || s.Status().Pending != 0 {
+		t.Errorf("after flush: files %v, status %+v", files, s.Status())
+	}
+	if cps, _, _ := inner.MemoryResultStore.Query(ctx, RunFilter{}); len(cps) != 2 || cps[0].Record.ID != "c" {
+		t.Errorf("live runs = %+v", cps)
+	}
+	if cps, _, _ := inner.MemoryResultStore.Query(ctx, RunFilter{Deleted: true}); len(cps) != 1 || cps[0].Record.ID != "a" {
+		t.Errorf("deleted runs = %+v", cps)
+	}
+	if err := s.HealthCheck()(ctx); err != nil {
+		t.Errorf("unhealthy after flush: %v", err)
+	}
+}
+
+func TestSpoolResultStoreRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	inner := &flakyStore{MemoryResultStore: NewMemoryResultStore()}
+	inner.down.Store(true)
+	s, err := NewSpoolResultStore(inner, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.MinBackoff, s.MaxBackoff = time.Millisecond, 4*time.Millisecond
+	runner := NewRunner(driftRegistry(), func(ctx context.Context, prompt string) (string, error) { return "4", nil },
+		tokentrace.NewReporter("matchspec", ""), WithResultStore(s))
+	if _, err := runner.Run(ctx, protocol.EvalRun{Suite: "math"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := runner.StoreErrors(); n != 0 {
+		t.Errorf("StoreErrors = %d with a spool", n)
+	}
+	go s.Run(ctx)
+
+	time.Sleep(20 * time.Millisecond)
+	if st := s.Status(); st.Pending != 1 || st.Failures < 2 {
+		t.Errorf("status while down = %+v", st)
+	}
+	inner.down.Store(false)
+	deadline := time.Now().Add(2 * time.Second)
+	for s.Status().Pending > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("spool never drained")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, total, _ := inner.MemoryResultStore.Query(ctx, RunFilter{}); total != 1 {
+		t.Errorf("stored runs = %d", total)
+	}
+}
+
+func TestSpoolResultStoreEncrypted(t *testing.T) {
+	c, err := NewCipher(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	inner := &flakyStore{MemoryResultStore: NewMemoryResultStore()}
+	inner.down.Store(true)
+	s, _ := NewSpoolResultStore(inner, dir, EncryptWith(c))
+	s.Append(context.Background(), spooledRun("secret-run", false))
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	data, _ := os.ReadFile(files[0])
+	if len(files) != 1 || bytes.Contains(data, []byte("secret-run")) {
+		t.Errorf("spool file not encrypted: %q", data)
+	}
+	if _, err := NewSpoolResultStore(inner, dir); err == nil {
+		t.Error("opened an encrypted spool without the key")
+	}
+	if s, err = NewSpoolResultStore(inner, dir, EncryptWith(c)); err != nil || s.Status().Pending != 1 {
+		t.Errorf("reopen: %v", err)
+	}
+}
+
+func TestConfigResultStoreSpool(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "matchspec.yaml")
+	os.WriteFile(config, []byte("suites: []\nstore:\n  type: memory\n  spool: spool\n"), 0o644)
+	c, err := LoadConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Store.Spool != filepath.Join(dir, "spool") {
+		t.Errorf("spool = %q", c.Store.Spool)
+	}
+	s, err := OpenResultStore(context.Background(), *c.Store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.(*SpoolResultStore); !ok {
+		t.Errorf("store = %T", s)
+	}
+	if detail, err := PreflightStore(*c.Store).Check(context.Background()); err != nil || !strings.Contains(detail, "0 writes spooled") {
+		t.Errorf("preflight = %q, %v", detail, err)
+	}
+}
@@ -0,0 +1,72 @@
+//go:build sqlite
+
+package matchspec
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+// openSQLite opens the SQLite database at path, waiting for other
+// connections' locks as replicas sharing a file must.
+func openSQLite(t *testing.T, path string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestSQLiteResultStore(t *testing.T) {
+	store, err := NewSQLResultStore(context.Background(), openSQLite(t, filepath.Join(t.TempDir(), "results.db")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testResultStore(t, store)
+}
+
+func TestSQLiteJobQueue(t *testing.T) {
+	store, err := NewSQLResultStore(context.Background(), openSQLite(t, filepath.Join(t.TempDir(), "results.db")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := store.JobQueue()
+	q.MaxQueued = 100
+	testJobQueue(t, q)
+}
+
+func TestSQLiteJobQueueSharedBetweenReplicas(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "results.db")
+	a, err := NewSQLResultStore(ctx, openSQLite(t, path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewSQLResultStore(ctx, openSQLite(t, path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSharedJobQueue(t, a.JobQueue(), b.JobQueue())
+}
+
+func TestOpenSQLiteStoreWithDriver(t *testing.T) {
+	ctx := context.Background()
+	cfg := ResultStoreConfig{Type: "sqlite", DSN: filepath.Join(t.TempDir(), "results.db")}
+	s, err := OpenResultStore(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.(*SQLResultStore).Close()
+	// Reopening finds the schema current.
+	s, err = OpenResultStore(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.(*SQLResultStore).Close()
+}
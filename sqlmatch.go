@@ -0,0 +1,156 @@
+package matchspec
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	sqlFencePattern = regexp.MustCompile("(?is)```(?:sql|sqlite)?\\s*\\n(.*?)```")
+	sqlStmtPattern  = regexp.MustCompile(`(?is)\b(?:select|with)\b.*?(?:;|$)`)
+	orderByPattern  = regexp.MustCompile(`(?i)\border\s+by\b`)
+)
+
+// WithSQLDriver sets the database/sql driver that the "sql" matcher opens
+// task fixtures with, such as "sqlite3" or "sqlite". The driver must be
+// registered by the application; matchspec does not link one in.
+func WithSQLDriver(name string) RunnerOption {
+	return func(r *Runner) {
+		r.sqlDriver = name
+	}
+}
+
+// ExtractSQL returns the SQL query in a response: the first fenced code
+// block if there is one, otherwise the first SELECT or WITH statement.
+func ExtractSQL(response string) string {
+	if m := sqlFencePattern.FindStringSubmatch(response); m != nil {
+		return strings.TrimSpace(m[1])
+	}
+	return strings.TrimSpace(sqlStmtPattern.FindString(response))
+}
+
+// matchSQL runs the query in the response against the task's Fixture and
+// compares the result set with Expected, which is either a JSON array of
+// rows or a reference query to run against the same fixture. Rows are
+// compared in order only if the response query has an ORDER BY clause.
+// Queries run in a transaction that is rolled back, so they cannot change
+// the fixture. The score is 1 or 0.
+func (r *Runner) matchSQL(ctx context.Context, t *Task, response string) (bool, float64, error) {
+	if r.sqlDriver == "" {
+		return false, 0.0, fmt.Errorf("matchspec: matcher \"sql\" requires a database driver (see WithSQLDriver)")
+	}
+	query := ExtractSQL(response)
+	if query == "" {
+		return false, 0.0, nil
+	}
+
+	db, err := sql.Open(r.sqlDriver, t.Fixture)
+	if err != nil {
+		return false, 0.0, fmt.Errorf("matchspec: task %q fixture: %w", t.Name, err)
+	}
+	defer db.Close()
+
+	var expected [][]any
+	if err := json.Unmarshal([]byte(t.Expected), &expected); err != nil {
+		if expected, err = queryRows(ctx, db, t.Expected); err != nil {
+			return false, 0.0, fmt.Errorf("matchspec: task %q expected query: %w", t.Name, err)
+		}
+	}
+	actual, err := queryRows(ctx, db, query)
+	if err != nil {
+		// An invalid query is a wrong answer, not an evaluation error.
+		return false, 0.0, nil
+	}
+
+	want, got := normalizeRows(expected), normalizeRows(actual)
+	if !orderByPattern.MatchString(query) {
+		slices.SortFunc(want, compareRows)
+		slices.SortFunc(got, compareRows)
+	}
+	if slices.EqualFunc(want, got, func(a, b []string) bool { return slices.Equal(a, b) }) {
+		return true, 1.0, nil
+	}
+	return false, 0.0, nil
+}
+
+// queryRows runs query in a transaction that is always rolled back and
+// returns its rows.
+func queryRows(ctx context.Context, db *sql.DB, query string) ([][]any, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var out [][]any
+	for rows.Next() {
+		row := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range row {
+			ptrs[i] = &row[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
+
+// normalizeRows renders each value in a canonical text form so that
+// results from the database and from JSON compare equal: numbers in
+// shortest form, byte slices as text, times in RFC 3339, and NULL as
+// "NULL".
+func normalizeRows(rows [][]any) [][]string {
+	out := make([][]string, len(rows))
+	for i, row := range rows {
+		out[i] = make([]string, len(row))
+		for j, v := range row {
+			out[i][j] = normalizeValue(v)
+		}
+	}
+	return out
+}
+
+func normalizeValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return normalizeValue(string(v))
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v)
+}
+
+func compareRows(a, b []string) int {
+	return slices.Compare(a, b)
+}
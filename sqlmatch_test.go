@@ -0,0 +1,168 @@
+package matchspec
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+// fakeSQL is a database/sql driver that answers queries from canned result
+// sets, keyed by fixture name and then by query text.
+type fakeSQL struct {
+	fixtures  map[string]map[string][][]driver.Value
+	rollbacks atomic.Int64
+}
+
+var testSQL = &fakeSQL{fixtures: map[string]map[string][][]driver.Value{
+	"shop.db": {
+		"SELECT name FROM customers":                       {{"ann"}, {"bob"}},
+		"SELECT name FROM customers ORDER BY name DESC":    {{"bob"}, {"ann"}},
+		"SELECT name FROM customers WHERE spend > 10":      {{"bob"}},
+		"SELECT id, spend FROM customers WHERE name='ann'": {{int64(1), 9.5}},
+		"SELECT id, spend FROM customers WHERE id=1":       {{int64(1), []byte("9.5")}},
+	},
+}}
+
+func init() {
+	sql.Register("matchspec-fake", testSQL)
+}
+
+func (d *fakeSQL) Open(name string) (driver.Conn, error) {
+	f, ok := d.fixtures[name]
+	if !ok {
+		return nil, fmt.Errorf("no fixture %q", name)
+	}
+	return &fakeConn{d: d, queries: f}, nil
+}
+
+type fakeConn struct {
+	d       *fakeSQL
+	queries map[string][][]driver.Value
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	rows, ok := c.queries[strings.TrimSuffix(query, ";")]
+	if !ok {
+		return nil, fmt.Errorf("syntax error")
+	}
+	return &fakeStmt{rows: rows}, nil
+}
+
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *fakeConn) Commit() error             { return nil }
+func (c *fakeConn) Rollback() error           { c.d.rollbacks.Add(1); return nil }
+
+type fakeStmt struct{ rows [][]driver.Value }
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return 0 }
+func (s *fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, fmt.Errorf("read only")
+}
+func (s *fakeStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &fakeRows{rows: s.rows}, nil
+}
+
+type fakeRows struct {
+	rows [][]driver.Value
+	i    int
+}
+
+func (r *fakeRows) Columns() []string {
+	if len(r.rows) == 0 {
+		return nil
+	}
+	cols := make([]string, len(r.rows[0]))
+	for i := range cols {
+		cols[i] = fmt.Sprintf("c%d", i)
+	}
+	return cols
+}
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.i >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.i])
+	r.i++
+	return nil
+}
+
+func TestExtractSQL(t *testing.T) {
+	tests := []struct {
+		response string
+		want     string
+	}{
+		{"Here you go:\n```sql\nSELECT name\nFROM customers;\n```\nDone.", "SELECT name\nFROM customers;"},
+		{"The query is SELECT name FROM customers; it lists names.", "SELECT name FROM customers;"},
+		{"with t as (select 1) select * from t", "with t as (select 1) select * from t"},
+		{"I cannot answer that.", ""},
+	}
+	for _, tt := range tests {
+		if got := ExtractSQL(tt.response); got != tt.want {
+			t.Errorf("ExtractSQL(%q) = %q, want %q", tt.response, got, tt.want)
+		}
+	}
+}
+
+func TestRunnerSQLMatcher(t *testing.T) {
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "sql", Tasks: []Task{
+		// Without ORDER BY, row order is ignored.
+		{Name: "unordered", Prompt: "SELECT name FROM customers", Matcher: "sql", Fixture: "shop.db", Expected: `[["bob"], ["ann"]]`},
+		// With ORDER BY, it counts.
+		{Name: "ordered", Prompt: "SELECT name FROM customers ORDER BY name DESC", Matcher: "sql", Fixture: "shop.db", Expected: `[["ann"], ["bob"]]`},
+		// Expected may be a reference query.
+		{Name: "reference", Prompt: "```sql\nSELECT name FROM customers WHERE spend > 10\n```", Matcher: "sql", Fixture: "shop.db", Expected: "SELECT name FROM customers WHERE spend > 10"},
+		// Values compare across types: 1 == 1.0 and 9.5 == "9.5".
+		{Name: "types", Prompt: "SELECT id, spend FROM customers WHERE id=1", Matcher: "sql", Fixture: "shop.db", Expected: `[[1.0, 9.5]]`},
+		// A query the database rejects fails without an error.
+		{Name: "invalid", Prompt: "SELECT nope", Matcher: "sql", Fixture: "shop.db", Expected: `[]`},
+	}})
+	infer := func(ctx context.Context, prompt string) (string, error) { return prompt, nil }
+	runner := NewRunner(reg, infer, tokentrace.NewReporter("matchspec", ""), WithSQLDriver("matchspec-fake"))
+
+	before := testSQL.rollbacks.Load()
+	results, err := runner.Run(context.Background(), protocol.EvalRun{Suite: "sql"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"unordered": true, "ordered": false, "reference": true, "types": true, "invalid": false}
+	for _, res := range results {
+		if res.Error != "" {
+			t.Errorf("%s: error %q", res.Task, res.Error)
+		}
+		if res.Passed != want[res.Task] {
+			t.Errorf("%s: passed = %v, want %v", res.Task, res.Passed, want[res.Task])
+		}
+	}
+	if n := testSQL.rollbacks.Load() - before; n < 5 {
+		t.Errorf("rollbacks = %d, want every query rolled back", n)
+	}
+}
+
+func TestRunnerSQLMatcherNoDriver(t *testing.T) {
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "sql", Tasks: []Task{
+		{Name: "q", Prompt: "SELECT 1", Matcher: "sql", Fixture: "shop.db", Expected: `[[1]]`},
+	}})
+	runner := NewRunner(reg, echoInfer, tokentrace.NewReporter("matchspec", ""))
+	results, err := runner.Run(context.Background(), protocol.EvalRun{Suite: "sql"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Passed || !strings.Contains(results[0].Error, "WithSQLDriver") {
+		t.Errorf("result = %+v, want a driver error", results[0])
+	}
+}
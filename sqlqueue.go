@@ -0,0 +1,335 @@
+package matchspec
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/greynewell/mist-go/trace"
+)
+
+// SQLJobQueue is a JobQueue in the database of a SQLResultStore, so
+// replicas sharing a result store also share their queue.
+//
+// Each job is a row carrying a version that every write increments. An
+// operation reads the row, computes the change, and writes it only if the
+// version is unchanged, trying again otherwise; two workers can never
+// both claim a job. A claim is a lease that the worker renews with
+// Heartbeat: Reclaim returns jobs whose lease has lapsed to the queue.
+type SQLJobQueue struct {
+	db      *sql.DB
+	dialect sqlDialect
+
+	// MaxQueued, if positive, bounds the number of queued jobs across
+	// every process sharing the database; Enqueue fails with a
+	// QueueFullError beyond it.
+	MaxQueued int
+}
+
+// claimBatch is how many queued jobs Claim considers at once. A larger
+// batch lets replicas claiming together find a free job without asking
+// the database again.
+const claimBatch = 16
+
+// JobQueue returns a queue in the store's database. The store's schema
+// migrations create its table.
+func (s *SQLResultStore) JobQueue() *SQLJobQueue {
+	return &SQLJobQueue{db: s.db, dialect: s.dialect}
+}
+
+// StoreJobQueue returns the job queue of a result store opened by
+// OpenResultStore, which must be a SQL store, spooled or not.
+func StoreJobQueue(store ResultStore) (*SQLJobQueue, error) {
+	if spool, ok := store.(*SpoolResultStore); ok {
+		store = spool.Unwrap()
+	}
+	s, ok := store.(*SQLResultStore)
+	if !ok {
+		return nil, fmt.Errorf("matchspec: job queue needs a SQL result store, not %T", store)
+	}
+	return s.JobQueue(), nil
+}
+
+// versionedJob is a job and the version of the row it was read from.
+type versionedJob struct {
+	Job
+	version int64
+}
+
+// jobs runs query, which selects version and job columns, and decodes
+// the rows.
+func (q *SQLJobQueue) jobs(ctx context.Context, query string, args ...any) ([]versionedJob, error) {
+	rows, err := q.db.QueryContext(ctx, q.dialect.rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("matchspec: job queue: %w", err)
+	}
+	defer rows.Close()
+	var jobs []versionedJob
+	for rows.Next() {
+		var j versionedJob
+		var data string
+		if err := rows.Scan(&j.version, &data); err != nil {
+			return nil, fmt.Errorf("matchspec: job queue: %w", err)
+		}
+		if err := json.Unmarshal([]byte(data), &j.Job); err != nil {
+			return nil, fmt.Errorf("matchspec: job queue: %w", err)
+		}
+		jobs = append(jobs, j)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("matchspec: job queue: %w", err)
+	}
+	return jobs, nil
+}
+
+// load reads the job with the given ID.
+func (q *SQLJobQueue) load(ctx context.Context, id string) (versionedJob, error) {
+	jobs, err := q.jobs(ctx, `SELECT version, job FROM matchspec_jobs WHERE id = ?`, id)
+	if err != nil {
+		return versionedJob{}, err
+	}
+	if len(jobs) == 0 {
+		return versionedJob{}, ErrJobNotFound
+	}
+	return jobs[0], nil
+}
+
+// heartbeatNanos is the heartbeat_at column of j, or 0 if it has none.
+func heartbeatNanos(j Job) int64 {
+	if j.HeartbeatAt.IsZero() {
+		return 0
+	}
+	return j.HeartbeatAt.UnixNano()
+}
+
+// swap writes j over the row it was read from. It reports false, writing
+// nothing, if the row has been written since.
+func (q *SQLJobQueue) swap(ctx context.Context, j versionedJob) (bool, error) {
+	data, err := json.Marshal(j.Job)
+	if err != nil {
+		return false, fmt.Errorf("matchspec: job queue: %w", err)
+	}
+	res, err := q.db.ExecContext(ctx, q.dialect.rebind(`UPDATE matchspec_jobs SET state = ?, priority = ?, heartbeat_at = ?, version = ?, job = ? WHERE id = ? AND version = ?`),
+		string(j.State), j.Priority, heartbeatNanos(j.Job), j.version+1, string(data), j.ID, j.version)
+	if err != nil {
+		return false, fmt.Errorf("matchspec: job queue: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("matchspec: job queue: %w", err)
+	}
+	return n == 1, nil
+}
+
+// update applies fn to the job with the given ID and writes it, starting
+// over if another process writes the job first.
+func (q *SQLJobQueue) update(ctx context.Context, id string, fn func(*Job) error) error {
+	for {
+		j, err := q.load(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := fn(&j.Job); err != nil {
+			return err
+		}
+		ok, err := q.swap(ctx, j)
+		if err != nil || ok {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("matchspec: job queue: %w", err)
+		}
+	}
+}
+
+// Enqueue adds a job in the queued state, assigning an ID if it has none.
+func (q *SQLJobQueue) Enqueue(ctx context.Context, job Job) (Job, error) {
+	if job.Run.Suite == "" {
+		return Job{}, fmt.Errorf("matchspec: job has no suite")
+	}
+	if job.ID == "" {
+		job.ID = trace.NewID()
+	}
+	job.State = JobQueued
+	job.EnqueuedAt = time.Now()
+	data, err := json.Marshal(job)
+	if err != nil {
+		return Job{}, fmt.Errorf("matchspec: job queue: %w", err)
+	}
+
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Job{}, fmt.Errorf("matchspec: job queue: %w", err)
+	}
+	defer tx.Rollback()
+	if q.MaxQueued > 0 {
+		if q.dialect.jobLock != "" {
+			if _, err := tx.ExecContext(ctx, q.dialect.jobLock); err != nil {
+				return Job{}, fmt.Errorf("matchspec: job queue: %w", err)
+			}
+		}
+		var queued int
+		if err := tx.QueryRowContext(ctx, q.dialect.rebind(`SELECT COUNT(*) FROM matchspec_jobs WHERE state = ?`), string(JobQueued)).Scan(&queued); err != nil {
+			return Job{}, fmt.Errorf("matchspec: job queue: %w", err)
+		}
+		if queued >= q.MaxQueued {
+			return Job{}, &QueueFullError{Queue: QueueJobs, Depth: queued, Limit: q.MaxQueued}
+		}
+	}
+	if _, err := tx.ExecContext(ctx, q.dialect.rebind(`INSERT INTO matchspec_jobs (id, state, priority, enqueued_at, heartbeat_at, version, job) VALUES (?, ?, ?, ?, ?, ?, ?)`),
+		job.ID, string(job.State), job.Priority, job.EnqueuedAt.UnixNano(), int64(0), int64(1), string(data)); err != nil {
+		return Job{}, fmt.Errorf("matchspec: job queue: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return Job{}, fmt.Errorf("matchspec: job queue: %w", err)
+	}
+	return job, nil
+}
+
+// Claim marks the next queued job running on worker and returns it. It
+// reports false if no job is queued.
+func (q *SQLJobQueue) Claim(ctx context.Context, worker string) (Job, bool, error) {
+	for {
+		queued, err := q.jobs(ctx, fmt.Sprintf(`SELECT version, job FROM matchspec_jobs WHERE state = ? ORDER BY priority DESC, enqueued_at LIMIT %d`, claimBatch),
+			string(JobQueued))
+		if err != nil || len(queued) == 0 {
+			return Job{}, false, err
+		}
+		for _, j := range queued {
+			now := time.Now()
+			j.State = JobRunning
+			j.StartedAt = now
+			j.Worker = worker
+			j.HeartbeatAt = now
+			j.Attempts++
+			ok, err := q.swap(ctx, j)
+			if err != nil {
+				return Job{}, false, err
+			}
+			if ok {
+				return j.Job, true, nil
+			}
+		}
+		// Other workers took every job we saw; look again.
+		if err := ctx.Err(); err != nil {
+			return Job{}, false, fmt.Errorf("matchspec: job queue: %w", err)
+		}
+	}
+}
+
+// Heartbeat records that worker is still running the job, renewing its
+// lease.
+func (q *SQLJobQueue) Heartbeat(ctx context.Context, id, worker string) error {
+	return q.update(ctx, id, func(j *Job) error {
+		if err := held(j, worker); err != nil {
+			return err
+		}
+		j.HeartbeatAt = time.Now()
+		return nil
+	})
+}
+
+// Complete records the outcome of a job running on worker.
+func (q *SQLJobQueue) Complete(ctx context.Context, id, worker, runID string, runErr error) error {
+	return q.update(ctx, id, func(j *Job) error {
+		if err := held(j, worker); err != nil {
+			return err
+		}
+		j.State = JobDone
+		if runID != "" {
+			j.RunID = runID
+		}
+		j.FinishedAt = time.Now()
+		if runErr != nil {
+			j.State = JobFailed
+			j.Error = runErr.Error()
+		}
+		return nil
+	})
+}
+
+// Release returns a job running on worker to the queue, for a worker
+// shutting down before the job finished.
+func (q *SQLJobQueue) Release(ctx context.Context, id, worker string) error {
+	return q.update(ctx, id, func(j *Job) error {
+		if err := held(j, worker); err != nil {
+			return err
+		}
+		requeue(j)
+		return nil
+	})
+}
+
+// Reclaim queues again every running job whose last heartbeat is older
+// than staleAfter, and returns them as they were before reassignment. A
+// job whose worker reports while it is being reclaimed stays with that
+// worker.
+func (q *SQLJobQueue) Reclaim(ctx context.Context, staleAfter time.Duration) ([]Job, error) {
+	stale, err := q.jobs(ctx, `SELECT version, job FROM matchspec_jobs WHERE state = ? AND heartbeat_at < ?`,
+		string(JobRunning), time.Now().Add(-staleAfter).UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	var reclaimed []Job
+	for _, j := range stale {
+		before := j.Job
+		requeue(&j.Job)
+		ok, err := q.swap(ctx, j)
+		if err != nil {
+			return reclaimed, err
+		}
+		if ok {
+			reclaimed = append(reclaimed, before)
+		}
+	}
+	return reclaimed, nil
+}
+
+// Cancel removes a queued job from consideration. Jobs that have already
+// started cannot be cancelled through the queue.
+func (q *SQLJobQueue) Cancel(ctx context.Context, id string) error {
+	return q.update(ctx, id, func(j *Job) error {
+		if j.State != JobQueued {
+			return fmt.Errorf("matchspec: job %s is %s, not queued", id, j.State)
+		}
+		j.State = JobCancelled
+		j.FinishedAt = time.Now()
+		return nil
+	})
+}
+
+// SetPriority changes the priority of a queued job.
+func (q *SQLJobQueue) SetPriority(ctx context.Context, id string, priority int) error {
+	return q.update(ctx, id, func(j *Job) error {
+		if j.State != JobQueued {
+			return fmt.Errorf("matchspec: job %s is %s, not queued", id, j.State)
+		}
+		j.Priority = priority
+		return nil
+	})
+}
+
+// Get returns the job with the given ID.
+func (q *SQLJobQueue) Get(ctx context.Context, id string) (Job, error) {
+	j, err := q.load(ctx, id)
+	if err != nil {
+		return Job{}, err
+	}
+	return j.Job, nil
+}
+
+// List returns all jobs in the order Claim would consider them: highest
+// priority first, then oldest first. Finished jobs are included.
+func (q *SQLJobQueue) List(ctx context.Context) ([]Job, error) {
+	rows, err := q.jobs(ctx, `SELECT version, job FROM matchspec_jobs ORDER BY priority DESC, enqueued_at`)
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]Job, len(rows))
+	for i, j := range rows {
+		jobs[i] = j.Job
+	}
+	return jobs, nil
+}
@@ -0,0 +1,127 @@
+package matchspec
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+)
+
+func newFakeJobQueue(t *testing.T, open func(context.Context, *sql.DB) (*SQLResultStore, error)) *SQLJobQueue {
+	t.Helper()
+	store, err := open(context.Background(), openFakeStore(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store.JobQueue()
+}
+
+func TestSQLJobQueue(t *testing.T) {
+	testJobQueue(t, newFakeJobQueue(t, NewSQLResultStore))
+}
+
+func TestPostgresJobQueue(t *testing.T) {
+	q := newFakeJobQueue(t, NewPostgresResultStore)
+	testJobQueue(t, q)
+
+	// Enqueues check the depth under a lock only when it is bounded.
+	q.MaxQueued = 2
+	ctx := context.Background()
+	q.Enqueue(ctx, Job{Run: protocol.EvalRun{Suite: "s"}})
+	_, err := q.Enqueue(ctx, Job{Run: protocol.EvalRun{Suite: "s"}})
+	var full *QueueFullError
+	if !errors.As(err, &full) || full.Depth != 2 || full.Limit != 2 {
+		t.Fatalf("err = %v, want QueueFullError", err)
+	}
+	testStoreSQL.mu.Lock()
+	var locks int
+	for _, stmt := range testStoreSQL.log[t.Name()] {
+		if stmt == postgresDialect.jobLock {
+			locks++
+		}
+	}
+	testStoreSQL.mu.Unlock()
+	if locks != 2 {
+		t.Errorf("took the job lock %d times, want 2", locks)
+	}
+}
+
+func TestSQLJobQueueSharedBetweenReplicas(t *testing.T) {
+	ctx := context.Background()
+	a := newFakeJobQueue(t, NewSQLResultStore)
+	// A second replica opening the same database finds the queue.
+	replica, err := NewSQLResultStore(ctx, a.db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSharedJobQueue(t, a, replica.JobQueue())
+}
+
+// testSharedJobQueue checks that two replicas' queues on one database
+// share their jobs and never claim the same one.
+func testSharedJobQueue(t *testing.T, a, b *SQLJobQueue) {
+	t.Helper()
+	ctx := context.Background()
+	for i := 0; i < 20; i++ {
+		a.Enqueue(ctx, Job{Run: protocol.EvalRun{Suite: "s"}})
+	}
+	if jobs, _ := b.List(ctx); len(jobs) != 20 {
+		t.Fatalf("replica sees %d jobs, want 20", len(jobs))
+	}
+
+	// Two replicas claim concurrently; every job goes to exactly one.
+	var wg sync.WaitGroup
+	claimed := make([]map[string]bool, 2)
+	for i, q := range []*SQLJobQueue{a, b} {
+		claimed[i] = make(map[string]bool)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				job, ok, err := q.Claim(ctx, fmt.Sprint("w", i))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if !ok {
+					return
+				}
+				claimed[i][job.ID] = true
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := len(claimed[0]) + len(claimed[1]); n != 20 {
+		t.Errorf("claimed %d jobs, want 20", n)
+	}
+	for id := range claimed[0] {
+		if claimed[1][id] {
+			t.Errorf("job %s claimed by both replicas", id)
+		}
+	}
+}
+
+func TestStoreJobQueue(t *testing.T) {
+	store, err := NewSQLResultStore(context.Background(), openFakeStore(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	spool, err := NewSpoolResultStore(store, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q, err := StoreJobQueue(spool); err != nil || q.db != store.db {
+		t.Errorf("StoreJobQueue(spool) = %v, %v", q, err)
+	}
+	if _, err := StoreJobQueue(NewMemoryResultStore()); err == nil || !strings.Contains(err.Error(), "SQL result store") {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,332 @@
+package matchspec
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// sqlStoreMigrations are the schema versions of a SQLResultStore, applied
+// in order. Times are Unix nanoseconds; deleted_at is 0 for live runs.
+// Each row holds the run's checkpoint as JSON, with the columns queries
+// filter on alongside it. updated_at is when the row was last written, so
+// replicas sharing the database can find each other's changes. Add new
+// versions at the end; never edit an applied one.
+var sqlStoreMigrations = [][]string{
+	{
+		`CREATE TABLE IF NOT EXISTS matchspec_runs (
+	id TEXT PRIMARY KEY,
+	suite TEXT NOT NULL,
+	model TEXT NOT NULL,
+	started_at BIGINT NOT NULL,
+	deleted_at BIGINT NOT NULL,
+	checkpoint TEXT NOT NULL
+)`,
+		`CREATE INDEX IF NOT EXISTS matchspec_runs_suite_started ON matchspec_runs (suite, started_at)`,
+	},
+	{
+		`ALTER TABLE matchspec_runs ADD COLUMN updated_at BIGINT NOT NULL DEFAULT 0`,
+		`CREATE INDEX IF NOT EXISTS matchspec_runs_updated ON matchspec_runs (updated_at)`,
+	},
+	{
+		// The jobs of a SQLJobQueue. version counts the writes to a row,
+		// so a change is applied only to the row it was computed from.
+		`CREATE TABLE IF NOT EXISTS matchspec_jobs (
+	id TEXT PRIMARY KEY,
+	state TEXT NOT NULL,
+	priority INTEGER NOT NULL,
+	enqueued_at BIGINT NOT NULL,
+	heartbeat_at BIGINT NOT NULL,
+	version BIGINT NOT NULL,
+	job TEXT NOT NULL
+)`,
+		`CREATE INDEX IF NOT EXISTS matchspec_jobs_state ON matchspec_jobs (state, priority, enqueued_at)`,
+	},
+}
+
+// sqlDialect is what SQLResultStore needs to know about a database.
+type sqlDialect struct {
+	// bind rewrites the ? placeholders of a statement, or is nil if the
+	// database accepts them.
+	bind func(query string) string
+
+	// lock, if set, is executed first in the migration transaction so
+	// processes sharing the database migrate it one at a time.
+	lock string
+
+	// jobLock, if set, is executed first in the transaction that checks
+	// the depth of a SQLJobQueue and enqueues, so processes enqueueing
+	// together cannot overshoot its MaxQueued.
+	jobLock string
+}
+
+var (
+	sqliteDialect   = sqlDialect{}
+	postgresDialect = sqlDialect{
+		bind: bindDollar,
+		// The keys are arbitrary, but every matchspec uses the same ones.
+		lock:    `SELECT pg_advisory_xact_lock(7236966246447985)`,
+		jobLock: `SELECT pg_advisory_xact_lock(7236966246447986)`,
+	}
+)
+
+// rebind adapts the placeholders of query to the database.
+func (d sqlDialect) rebind(query string) string {
+	if d.bind == nil {
+		return query
+	}
+	return d.bind(query)
+}
+
+// bindDollar numbers the ? placeholders of query as $1, $2, and so on.
+// Statements here never contain a literal ?.
+func bindDollar(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// SQLResultStore is a ResultStore in a SQL database opened with
+// database/sql, such as SQLite or Postgres. The program must register the
+// database driver, for example by importing modernc.org/sqlite or
+// github.com/jackc/pgx/v5/stdlib. It is safe for concurrent use, and
+// several processes may share one database.
+type SQLResultStore struct {
+	db      *sql.DB
+	dialect sqlDialect
+
+	// Compress, if set, gzips the checkpoints it writes. Both forms are
+	// read, so it can be set on a database that already holds runs.
+	Compress bool
+}
+
+// NewSQLResultStore stores runs in db, a SQLite database, migrating its
+// schema to the current version if needed.
+func NewSQLResultStore(ctx context.Context, db *sql.DB) (*SQLResultStore, error) {
+	return newSQLResultStore(ctx, db, sqliteDialect)
+}
+
+// NewPostgresResultStore stores runs in db, a Postgres database, migrating
+// its schema to the current version if needed. Replicas starting together
+// take turns migrating.
+func NewPostgresResultStore(ctx context.Context, db *sql.DB) (*SQLResultStore, error) {
+	return newSQLResultStore(ctx, db, postgresDialect)
+}
+
+func newSQLResultStore(ctx context.Context, db *sql.DB, dialect sqlDialect) (*SQLResultStore, error) {
+	s := &SQLResultStore{db: db, dialect: dialect}
+	if err := s.migrate(ctx); err != nil {
+		return nil, fmt.Errorf("matchspec: result store schema: %w", err)
+	}
+	return s, nil
+}
+
+// migrate applies the migrations the database has not seen, in one
+// transaction, recording each in matchspec_schema_migrations.
+func (s *SQLResultStore) migrate(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if s.dialect.lock != "" {
+		if _, err := tx.ExecContext(ctx, s.dialect.lock); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS matchspec_schema_migrations (
+	version INTEGER PRIMARY KEY,
+	applied_at BIGINT NOT NULL
+)`); err != nil {
+		return err
+	}
+	var version int
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM matchspec_schema_migrations`).Scan(&version); err != nil {
+		return err
+	}
+	if version > len(sqlStoreMigrations) {
+		return fmt.Errorf("database is at version %d, newer than this matchspec (%d)", version, len(sqlStoreMigrations))
+	}
+	for i, stmts := range sqlStoreMigrations[version:] {
+		for _, stmt := range stmts {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("version %d: %w", version+i+1, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, s.bind(`INSERT INTO matchspec_schema_migrations (version, applied_at) VALUES (?, ?)`),
+			version+i+1, time.Now().UnixNano()); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// bind adapts the placeholders of query to the database.
+func (s *SQLResultStore) bind(query string) string {
+	return s.dialect.rebind(query)
+}
+
+// openSQLiteStore opens the "sqlite" store type.
+func openSQLiteStore(ctx context.Context, cfg ResultStoreConfig) (ResultStore, error) {
+	return openSQLStore(ctx, cfg, "modernc.org/sqlite", sqliteDialect)
+}
+
+// openPostgresStore opens the "postgres" store type. The default driver,
+// "postgres", is registered by github.com/lib/pq; set driver to "pgx" for
+// github.com/jackc/pgx/v5/stdlib.
+func openPostgresStore(ctx context.Context, cfg ResultStoreConfig) (ResultStore, error) {
+	return openSQLStore(ctx, cfg, "github.com/lib/pq", postgresDialect)
+}
+
+// openSQLStore opens a SQL store type using cfg.Driver, or the driver
+// named after the type, which example is a package registering.
+func openSQLStore(ctx context.Context, cfg ResultStoreConfig, example string, dialect sqlDialect) (ResultStore, error) {
+	driver := cfg.Driver
+	if driver == "" {
+		driver = cfg.Type
+	}
+	if !slices.Contains(sql.Drivers(), driver) {
+		return nil, fmt.Errorf("matchspec: %s result store: database/sql driver %q is not registered; import one, such as %s, or set driver", cfg.Type, driver, example)
+	}
+	if cfg.DSN == "" {
+		return nil, fmt.Errorf("matchspec: %s result store has no dsn", cfg.Type)
+	}
+	db, err := sql.Open(driver, cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("matchspec: %s result store: %w", cfg.Type, err)
+	}
+	s, err := newSQLResultStore(ctx, db, dialect)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	s.Compress = cfg.Compress
+	return s, nil
+}
+
+// Append stores cp, replacing any stored run with the same ID.
+func (s *SQLResultStore) Append(ctx context.Context, cp Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("matchspec: result store: %w", err)
+	}
+	value, err := encodeSQLCheckpoint(data, s.Compress)
+	if err != nil {
+		return fmt.Errorf("matchspec: result store: %w", err)
+	}
+	rec := cp.Record
+	var deletedAt int64
+	if rec.deleted() {
+		deletedAt = rec.DeletedAt.UnixNano()
+	}
+	_, err = s.db.ExecContext(ctx, s.bind(`INSERT INTO matchspec_runs (id, suite, model, started_at, deleted_at, updated_at, checkpoint) VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (id) DO UPDATE SET suite = excluded.suite, model = excluded.model, started_at = excluded.started_at,
+	deleted_at = excluded.deleted_at, updated_at = excluded.updated_at, checkpoint = excluded.checkpoint`),
+		rec.ID, rec.Suite, rec.Model, rec.StartedAt.UnixNano(), deletedAt, time.Now().UnixNano(), value)
+	if err != nil {
+		return fmt.Errorf("matchspec: result store: %w", err)
+	}
+	return nil
+}
+
+// Query returns the stored runs matching f, newest first. The suite,
+// model, age, and deleted conditions are evaluated by the database; labels
+// and paging are applied to the rows it returns.
+func (s *SQLResultStore) Query(ctx context.Context, f RunFilter) ([]Checkpoint, int, error) {
+	conds := []string{"deleted_at = ?"}
+	if f.Deleted {
+		conds[0] = "deleted_at > ?"
+	}
+	args := []any{int64(0)}
+	if f.Suite != "" {
+		conds, args = append(conds, "suite = ?"), append(args, f.Suite)
+	}
+	if f.Model != "" {
+		conds, args = append(conds, "model = ?"), append(args, f.Model)
+	}
+	if !f.Since.IsZero() {
+		conds, args = append(conds, "started_at >= ?"), append(args, f.Since.UnixNano())
+	}
+	cps, err := s.query(ctx, conds, args)
+	if err != nil {
+		return nil, 0, err
+	}
+	cps, total := pageCheckpoints(cps, f)
+	return cps, total, nil
+}
+
+// Changes returns the runs written, by any process, at or after since,
+// including soft-deleted ones.
+func (s *SQLResultStore) Changes(ctx context.Context, since time.Time) ([]Checkpoint, error) {
+	return s.query(ctx, []string{"updated_at >= ?"}, []any{since.UnixNano()})
+}
+
+// query returns the checkpoints of the rows matching all of conds, newest
+// first.
+func (s *SQLResultStore) query(ctx context.Context, conds []string, args []any) ([]Checkpoint, error) {
+	query := "SELECT checkpoint FROM matchspec_runs WHERE " + strings.Join(conds, " AND ") + " ORDER BY started_at DESC"
+	rows, err := s.db.QueryContext(ctx, s.bind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("matchspec: result store: %w", err)
+	}
+	defer rows.Close()
+	var cps []Checkpoint
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, fmt.Errorf("matchspec: result store: %w", err)
+		}
+		data, err := decodeSQLCheckpoint(value)
+		if err != nil {
+			return nil, fmt.Errorf("matchspec: result store: %w", err)
+		}
+		var cp Checkpoint
+		if err := json.NewDecoder(data).Decode(&cp); err != nil {
+			return nil, fmt.Errorf("matchspec: result store: %w", err)
+		}
+		cps = append(cps, cp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("matchspec: result store: %w", err)
+	}
+	return cps, nil
+}
+
+// Delete removes the runs with the given IDs.
+func (s *SQLResultStore) Delete(ctx context.Context, ids ...string) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("matchspec: result store: %w", err)
+	}
+	defer tx.Rollback()
+	var n int64
+	for _, id := range ids {
+		res, err := tx.ExecContext(ctx, s.bind(`DELETE FROM matchspec_runs WHERE id = ?`), id)
+		if err != nil {
+			return 0, fmt.Errorf("matchspec: result store: %w", err)
+		}
+		affected, _ := res.RowsAffected()
+		n += affected
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("matchspec: result store: %w", err)
+	}
+	return int(n), nil
+}
+
+// Close closes the database.
+func (s *SQLResultStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,367 @@
+package matchspec
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeStoreSQL is a database/sql driver holding tables in memory. It
+// understands just the statements SQLResultStore and SQLJobQueue issue,
+// with either ? or $n placeholders. Transactions are not isolated.
+type fakeStoreSQL struct {
+	mu       sync.Mutex
+	tables   map[string]map[string][]fakeRow // by DSN, then table name
+	versions map[string]int64                // schema version by DSN
+	log      map[string][]string             // statements by DSN
+}
+
+type fakeRow map[string]driver.Value
+
+var testStoreSQL = &fakeStoreSQL{
+	tables:   make(map[string]map[string][]fakeRow),
+	versions: make(map[string]int64),
+	log:      make(map[string][]string),
+}
+
+func init() {
+	sql.Register("matchspec-fake-store", testStoreSQL)
+}
+
+// openFakeStore opens an empty fake database named after the test, so
+// repeated runs with -count start fresh.
+func openFakeStore(t *testing.T) *sql.DB {
+	t.Helper()
+	d := testStoreSQL
+	d.mu.Lock()
+	delete(d.tables, t.Name())
+	delete(d.versions, t.Name())
+	delete(d.log, t.Name())
+	d.mu.Unlock()
+	db, err := sql.Open("matchspec-fake-store", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func (d *fakeStoreSQL) Open(dsn string) (driver.Conn, error) {
+	return &fakeStoreConn{d: d, dsn: dsn}, nil
+}
+
+type fakeStoreConn struct {
+	d   *fakeStoreSQL
+	dsn string
+}
+
+func (c *fakeStoreConn) Prepare(query string) (driver.Stmt, error) {
+	query = fakeDollar.ReplaceAllString(strings.Join(strings.Fields(query), " "), "?")
+	c.d.mu.Lock()
+	c.d.log[c.dsn] = append(c.d.log[c.dsn], query)
+	c.d.mu.Unlock()
+	return &fakeStoreStmt{c: c, query: query}, nil
+}
+func (c *fakeStoreConn) Close() error              { return nil }
+func (c *fakeStoreConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *fakeStoreConn) Commit() error             { return nil }
+func (c *fakeStoreConn) Rollback() error           { return nil }
+
+// table returns the rows of a table. The caller holds d.mu.
+func (c *fakeStoreConn) table(name string) []fakeRow {
+	return c.d.tables[c.dsn][name]
+}
+
+// setTable replaces the rows of a table. The caller holds d.mu.
+func (c *fakeStoreConn) setTable(name string, rows []fakeRow) {
+	if c.d.tables[c.dsn] == nil {
+		c.d.tables[c.dsn] = make(map[string][]fakeRow)
+	}
+	c.d.tables[c.dsn][name] = rows
+}
+
+type fakeStoreStmt struct {
+	c     *fakeStoreConn
+	query string
+}
+
+var (
+	fakeDollar = regexp.MustCompile(`\$\d+`)
+	fakeInsert = regexp.MustCompile(`^INSERT INTO (\w+) \(([^)]*)\) VALUES \([^)]*\)( ON CONFLICT \(id\) DO UPDATE .*)?$`)
+	fakeUpdate = regexp.MustCompile(`^UPDATE (\w+) SET (.*?) WHERE (.*)$`)
+	fakeDelete = regexp.MustCompile(`^DELETE FROM (\w+) WHERE (.*)$`)
+	fakeSelect = regexp.MustCompile(`^SELECT (.*?) FROM (\w+)(?: WHERE (.*?))?(?: ORDER BY (.*?))?(?: LIMIT (\d+))?$`)
+	fakeCond   = regexp.MustCompile(`^(\w+) (=|<|>|>=) \?$`)
+)
+
+func (s *fakeStoreStmt) Close() error  { return nil }
+func (s *fakeStoreStmt) NumInput() int { return -1 }
+
+func (s *fakeStoreStmt) Exec(args []driver.Value) (driver.Result, error) {
+	d, c := s.c.d, s.c
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch {
+	case strings.HasPrefix(s.query, "CREATE "), strings.HasPrefix(s.query, "ALTER "), strings.HasPrefix(s.query, "SELECT pg_advisory_xact_lock("):
+		return driver.RowsAffected(0), nil
+	case s.query == "INSERT INTO matchspec_schema_migrations (version, applied_at) VALUES (?, ?)":
+		d.versions[c.dsn] = args[0].(int64)
+		return driver.RowsAffected(1), nil
+	}
+	if m := fakeInsert.FindStringSubmatch(s.query); m != nil {
+		row := make(fakeRow)
+		for i, col := range strings.Split(m[2], ", ") {
+			row[col] = args[i]
+		}
+		rows := c.table(m[1])
+		if slices.ContainsFunc(rows, func(old fakeRow) bool { return old["id"] == row["id"] }) {
+			if m[3] == "" {
+				return nil, fmt.Errorf("fake store: duplicate id %v in %s", row["id"], m[1])
+			}
+			rows = slices.DeleteFunc(rows, func(old fakeRow) bool { return old["id"] == row["id"] })
+		}
+		c.setTable(m[1], append(rows, row))
+		return driver.RowsAffected(1), nil
+	}
+	if m := fakeUpdate.FindStringSubmatch(s.query); m != nil {
+		sets := strings.Split(m[2], ", ")
+		match, err := fakeWhere(m[3], args[len(sets):])
+		if err != nil {
+			return nil, err
+		}
+		var n int64
+		for _, row := range c.table(m[1]) {
+			if !match(row) {
+				continue
+			}
+			for i, set := range sets {
+				row[strings.TrimSuffix(set, " = ?")] = args[i]
+			}
+			n++
+		}
+		return driver.RowsAffected(n), nil
+	}
+	if m := fakeDelete.FindStringSubmatch(s.query); m != nil {
+		match, err := fakeWhere(m[2], args)
+		if err != nil {
+			return nil, err
+		}
+		rows := c.table(m[1])
+		n := len(rows)
+		rows = slices.DeleteFunc(rows, match)
+		c.setTable(m[1], rows)
+		return driver.RowsAffected(n - len(rows)), nil
+	}
+	return nil, fmt.Errorf("fake store: unsupported statement %q", s.query)
+}
+
+func (s *fakeStoreStmt) Query(args []driver.Value) (driver.Rows, error) {
+	d, c := s.c.d, s.c
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if s.query == "SELECT COALESCE(MAX(version), 0) FROM matchspec_schema_migrations" {
+		return &fakeRows{rows: [][]driver.Value{{d.versions[c.dsn]}}}, nil
+	}
+	m := fakeSelect.FindStringSubmatch(s.query)
+	if m == nil {
+		return nil, fmt.Errorf("fake store: unsupported query %q", s.query)
+	}
+	match, err := fakeWhere(m[3], args)
+	if err != nil {
+		return nil, err
+	}
+	var matched []fakeRow
+	for _, row := range c.table(m[2]) {
+		if match(row) {
+			matched = append(matched, row)
+		}
+	}
+	if m[1] == "COUNT(*)" {
+		return &fakeRows{rows: [][]driver.Value{{int64(len(matched))}}}, nil
+	}
+	if m[4] != "" {
+		order := strings.Split(m[4], ", ")
+		slices.SortStableFunc(matched, func(a, b fakeRow) int {
+			for _, o := range order {
+				col, desc := strings.CutSuffix(o, " DESC")
+				n := fakeCmp(a[col], b[col])
+				if desc {
+					n = -n
+				}
+				if n != 0 {
+					return n
+				}
+			}
+			return 0
+		})
+	}
+	if m[5] != "" {
+		var limit int
+		fmt.Sscan(m[5], &limit)
+		matched = matched[:min(limit, len(matched))]
+	}
+	cols := strings.Split(m[1], ", ")
+	out := make([][]driver.Value, len(matched))
+	for i, row := range matched {
+		for _, col := range cols {
+			out[i] = append(out[i], row[col])
+		}
+	}
+	return &fakeRows{rows: out}, nil
+}
+
+// fakeWhere parses conds, "col op ?" joined by AND, into a row predicate
+// taking its operands from args in order. Empty conds match every row.
+func fakeWhere(conds string, args []driver.Value) (func(fakeRow) bool, error) {
+	type cond struct {
+		col, op string
+		arg     driver.Value
+	}
+	var cs []cond
+	if conds != "" {
+		for i, s := range strings.Split(conds, " AND ") {
+			m := fakeCond.FindStringSubmatch(s)
+			if m == nil || i >= len(args) {
+				return nil, fmt.Errorf("fake store: unsupported condition %q", s)
+			}
+			cs = append(cs, cond{m[1], m[2], args[i]})
+		}
+	}
+	return func(row fakeRow) bool {
+		for _, c := range cs {
+			n := fakeCmp(row[c.col], c.arg)
+			ok := map[string]bool{"=": n == 0, "<": n < 0, ">": n > 0, ">=": n >= 0}[c.op]
+			if !ok {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// fakeCmp compares two column values of the same type.
+func fakeCmp(a, b driver.Value) int {
+	switch a := a.(type) {
+	case int64:
+		return cmp.Compare(a, b.(int64))
+	case string:
+		return cmp.Compare(a, b.(string))
+	}
+	return 0
+}
+
+func TestSQLResultStore(t *testing.T) {
+	db := openFakeStore(t)
+	store, err := NewSQLResultStore(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	testResultStore(t, store)
+}
+
+func TestPostgresResultStore(t *testing.T) {
+	ctx := context.Background()
+	db := openFakeStore(t)
+	store, err := NewPostgresResultStore(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	testResultStore(t, store)
+
+	// A second replica finds the schema current and migrates nothing.
+	if _, err := NewPostgresResultStore(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	testStoreSQL.mu.Lock()
+	log := testStoreSQL.log[t.Name()]
+	version := testStoreSQL.versions[t.Name()]
+	testStoreSQL.mu.Unlock()
+	var locks, alters int
+	for _, stmt := range log {
+		switch {
+		case strings.HasPrefix(stmt, "SELECT pg_advisory_xact_lock("):
+			locks++
+		case strings.HasPrefix(stmt, "ALTER TABLE"):
+			alters++
+		}
+	}
+	if locks != 2 || alters != 1 || version != int64(len(sqlStoreMigrations)) {
+		t.Errorf("locks = %d, alters = %d, version = %d", locks, alters, version)
+	}
+
+	if got := bindDollar("a = ? AND b >= ?"); got != "a = $1 AND b >= $2" {
+		t.Errorf("bindDollar = %q", got)
+	}
+}
+
+func TestSQLResultStoreNewerSchema(t *testing.T) {
+	db := openFakeStore(t)
+	testStoreSQL.mu.Lock()
+	testStoreSQL.versions[t.Name()] = int64(len(sqlStoreMigrations) + 1)
+	testStoreSQL.mu.Unlock()
+	defer db.Close()
+	if _, err := NewSQLResultStore(context.Background(), db); err == nil || !strings.Contains(err.Error(), "newer than this matchspec") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestSQLResultStoreChanges(t *testing.T) {
+	ctx := context.Background()
+	db := openFakeStore(t)
+	store, err := NewSQLResultStore(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	store.Append(ctx, Checkpoint{Version: CheckpointVersion, Record: RunRecord{ID: "old"}})
+	since := time.Now()
+	store.Append(ctx, Checkpoint{Version: CheckpointVersion, Record: RunRecord{ID: "new", DeletedAt: since}})
+	cps, err := store.Changes(ctx, since)
+	if err != nil || len(cps) != 1 || cps[0].Record.ID != "new" {
+		t.Errorf("Changes = %+v, %v", cps, err)
+	}
+}
+
+func TestOpenPostgresStore(t *testing.T) {
+	ctx := context.Background()
+	if slices.Contains(sql.Drivers(), "postgres") {
+		// Built with the postgres tag (see postgres_test.go).
+	} else if _, err := OpenResultStore(ctx, ResultStoreConfig{Type: "postgres", DSN: "postgres://localhost/evals"}); err == nil || !strings.Contains(err.Error(), `"postgres" is not registered`) {
+		t.Errorf("missing driver: %v", err)
+	}
+	s, err := OpenResultStore(ctx, ResultStoreConfig{Type: "postgres", Driver: "matchspec-fake-store", DSN: t.Name()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.(*SQLResultStore).dialect.lock == "" {
+		t.Error("postgres store opened without the postgres dialect")
+	}
+}
+
+func TestOpenSQLiteStore(t *testing.T) {
+	ctx := context.Background()
+	if slices.Contains(sql.Drivers(), "sqlite") {
+		// Built with the sqlite tag (see sqlite_test.go).
+	} else if _, err := OpenResultStore(ctx, ResultStoreConfig{Type: "sqlite", DSN: "x.db"}); err == nil || !strings.Contains(err.Error(), "not registered") {
+		t.Errorf("missing driver: %v", err)
+	}
+	if _, err := OpenResultStore(ctx, ResultStoreConfig{Type: "sqlite", Driver: "matchspec-fake-store"}); err == nil || !strings.Contains(err.Error(), "no dsn") {
+		t.Errorf("missing dsn: %v", err)
+	}
+	s, err := OpenResultStore(ctx, ResultStoreConfig{Type: "sqlite", Driver: "matchspec-fake-store", DSN: t.Name()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.(*SQLResultStore); !ok {
+		t.Errorf("store = %T", s)
+	}
+}
@@ -0,0 +1,343 @@
+package matchspec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ResultStore persists recorded runs and their results, so run history
+// survives restarts. Runs are stored as checkpoints. Register a store with
+// WithResultStore and reload its runs with Runner.LoadStore.
+type ResultStore interface {
+	// Append stores a run, replacing any stored run with the same ID, as
+	// when a run is soft-deleted, restored, or erased.
+	Append(ctx context.Context, cp Checkpoint) error
+
+	// Query returns the stored runs matching f, newest first, along with
+	// the total number of matches before paging.
+	Query(ctx context.Context, f RunFilter) ([]Checkpoint, int, error)
+
+	// Delete removes the runs with the given IDs and returns how many it
+	// removed.
+	Delete(ctx context.Context, ids ...string) (int, error)
+}
+
+// WithResultStore writes every run the runner records, and every later
+// change to it, to s. Write failures are counted by StoreErrors and do not
+// fail the run.
+func WithResultStore(s ResultStore) RunnerOption {
+	return func(r *Runner) { r.store = s }
+}
+
+// StoreErrors returns the number of failed result store writes.
+func (r *Runner) StoreErrors() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.storeErrors
+}
+
+// StoreChanges is implemented by result stores that several runners can
+// share, such as SQLResultStore. Changes returns the runs written at or
+// after since, including soft-deleted ones, so SyncStore can fetch only
+// what changed.
+type StoreChanges interface {
+	Changes(ctx context.Context, since time.Time) ([]Checkpoint, error)
+}
+
+// storeSyncSlack widens each SyncStore poll to catch writes whose clocks
+// ran behind the runner's, or that committed after a poll that missed them.
+const storeSyncSlack = time.Minute
+
+// LoadStore adds the runs in the runner's result store, including
+// soft-deleted ones, to its history, oldest first, and returns how many it
+// added. Runs the runner already knows are updated if they were deleted,
+// restored, or erased in the store since, so it is safe to call more than
+// once. Call it before serving requests.
+func (r *Runner) LoadStore(ctx context.Context) (int, error) {
+	if r.store == nil {
+		return 0, nil
+	}
+	var stored []Checkpoint
+	for _, deleted := range []bool{false, true} {
+		cps, _, err := r.store.Query(ctx, RunFilter{Deleted: deleted})
+		if err != nil {
+			return 0, err
+		}
+		stored = append(stored, cps...)
+	}
+	return r.mergeStored(stored)
+}
+
+// SyncStore picks up the runs other runners sharing the result store
+// record, and their deletions, restores, and erasures, every interval
+// until ctx is done. Runs purged by another runner remain until restart.
+// Stores implementing StoreChanges are polled for changes only; others are
+// reloaded in full. Failed polls are counted by StoreErrors.
+func (r *Runner) SyncStore(ctx context.Context, interval time.Duration) {
+	if r.store == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	since := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		polled := time.Now()
+		var err error
+		if c, ok := r.store.(StoreChanges); ok {
+			var cps []Checkpoint
+			if cps, err = c.Changes(ctx, since.Add(-storeSyncSlack)); err == nil {
+				_, err = r.mergeStored(cps)
+			}
+		} else {
+			_, err = r.LoadStore(ctx)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			r.storeFailed(1)
+			continue
+		}
+		since = polled
+	}
+}
+
+// mergeStored adds the stored runs the runner does not know, oldest first,
+// and updates the finished runs whose stored copy was deleted, restored,
+// or erased since the runner last saw it. It returns how many runs it
+// added.
+func (r *Runner) mergeStored(stored []Checkpoint) (int, error) {
+	slices.SortStableFunc(stored, func(a, b Checkpoint) int {
+		return a.Record.StartedAt.Compare(b.Record.StartedAt)
+	})
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	added, changed := 0, 0
+	for _, cp := range stored {
+		if cp.Version != CheckpointVersion {
+			return added, fmt.Errorf("matchspec: stored run %q has unsupported checkpoint version %d", cp.Record.ID, cp.Version)
+		}
+		if i := slices.IndexFunc(r.runs, func(rec RunRecord) bool { return rec.ID == cp.Record.ID }); i >= 0 {
+			if r.refreshRun(&r.runs[i], cp) {
+				changed++
+			}
+			continue
+		}
+		if r.knownRun(cp.Record.ID) {
+			continue
+		}
+		rec := cp.Record
+		rec.run = cp.Run
+		rec.first, rec.count = len(r.results), len(cp.Results)
+		r.results = append(r.results, cp.Results...)
+		r.runs = append(r.runs, rec)
+		added++
+	}
+	if added+changed > 0 {
+		r.broadcast()
+	}
+	return added, nil
+}
+
+// refreshRun updates rec from its stored copy cp if that was deleted,
+// restored, or erased since, and reports whether it did. The caller must
+// hold r.mu.
+func (r *Runner) refreshRun(rec *RunRecord, cp Checkpoint) bool {
+	if rec.DeletedAt.Equal(cp.Record.DeletedAt) && !cp.Record.ErasedAt.After(rec.ErasedAt) {
+		return false
+	}
+	rec.DeletedAt = cp.Record.DeletedAt
+	if cp.Record.ErasedAt.After(rec.ErasedAt) {
+		rec.ErasedAt = cp.Record.ErasedAt
+		rec.Error = cp.Record.Error
+		rec.Hash, rec.Signature = cp.Record.Hash, cp.Record.Signature
+		if rec.count == len(cp.Results) {
+			copy(r.results[rec.first:rec.first+rec.count], cp.Results)
+		}
+	}
+	return true
+}
+
+// storedRun returns the checkpoint of rec to write to the result store.
+// The caller must hold r.mu.
+func (r *Runner) storedRun(rec RunRecord) Checkpoint {
+	return Checkpoint{
+		Version:  CheckpointVersion,
+		Run:      rec.run,
+		Record:   rec,
+		Results:  slices.Clone(r.results[rec.first : rec.first+rec.count]),
+		Complete: rec.Error == "",
+	}
+}
+
+// storeRuns writes cps to the result store, if any. The caller must hold
+// r.storeMu, so writes reach the store in the order they were made, and
+// not r.mu.
+func (r *Runner) storeRuns(ctx context.Context, cps []Checkpoint) error {
+	if r.store == nil {
+		return nil
+	}
+	var errs []error
+	for _, cp := range cps {
+		if err := r.store.Append(ctx, cp); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	r.storeFailed(len(errs))
+	return errors.Join(errs...)
+}
+
+func (r *Runner) storeFailed(n int) {
+	if n > 0 {
+		r.mu.Lock()
+		r.storeErrors += int64(n)
+		r.mu.Unlock()
+	}
+}
+
+// MemoryResultStore is a ResultStore in memory, for tests and as a
+// reference implementation. It is safe for concurrent use.
+type MemoryResultStore struct {
+	mu   sync.Mutex
+	runs map[string]Checkpoint
+}
+
+// NewMemoryResultStore creates an empty store.
+func NewMemoryResultStore() *MemoryResultStore {
+	return &MemoryResultStore{runs: make(map[string]Checkpoint)}
+}
+
+// Append stores cp.
+func (s *MemoryResultStore) Append(_ context.Context, cp Checkpoint) error {
+	cp.Results = slices.Clone(cp.Results)
+	s.mu.Lock()
+	s.runs[cp.Record.ID] = cp
+	s.mu.Unlock()
+	return nil
+}
+
+// Query returns the stored runs matching f, newest first.
+func (s *MemoryResultStore) Query(_ context.Context, f RunFilter) ([]Checkpoint, int, error) {
+	s.mu.Lock()
+	cps := slices.Collect(maps.Values(s.runs))
+	s.mu.Unlock()
+	cps, total := pageCheckpoints(cps, f)
+	return cps, total, nil
+}
+
+// Delete removes the runs with the given IDs.
+func (s *MemoryResultStore) Delete(_ context.Context, ids ...string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, id := range ids {
+		if _, ok := s.runs[id]; ok {
+			delete(s.runs, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// pageCheckpoints keeps the checkpoints whose records match f, sorts them
+// newest first, and pages them.
+func pageCheckpoints(cps []Checkpoint, f RunFilter) ([]Checkpoint, int) {
+	cps = slices.DeleteFunc(cps, func(cp Checkpoint) bool { return !f.match(&cp.Record) })
+	slices.SortStableFunc(cps, func(a, b Checkpoint) int {
+		return b.Record.StartedAt.Compare(a.Record.StartedAt)
+	})
+	total := len(cps)
+	cps = cps[min(f.Offset, total):]
+	if f.Limit > 0 && f.Limit < len(cps) {
+		cps = cps[:f.Limit]
+	}
+	return cps, total
+}
+
+// ResultStoreConfig selects a result store in a project config file. Type
+// is "memory", "sqlite", "postgres", or a type registered with
+// RegisterResultStore.
+type ResultStoreConfig struct {
+	Type string `json:"type"`
+
+	// DSN is the data source name, such as the database file of a "sqlite"
+	// store or the connection URL of a "postgres" one. A relative file
+	// path is resolved against the config file for "sqlite".
+	DSN string `json:"dsn,omitempty"`
+
+	// Driver is the database/sql driver name of SQL stores. It must be
+	// registered by the program, for example by importing a SQLite or
+	// Postgres driver; the default is the store type.
+	Driver string `json:"driver,omitempty"`
+
+	// Spool, if set, is a directory where writes the store rejects wait
+	// to be retried (see SpoolResultStore). A relative path is resolved
+	// against the config file.
+	Spool string `json:"spool,omitempty"`
+
+	// Compress gzips stored runs in SQL stores and spool files; both
+	// forms are read either way.
+	Compress bool `json:"compress,omitempty"`
+
+	// Options holds settings for registered store types.
+	Options map[string]any `json:"options,omitempty"`
+}
+
+// ResultStoreFactory opens the result store cfg describes.
+type ResultStoreFactory func(ctx context.Context, cfg ResultStoreConfig) (ResultStore, error)
+
+var (
+	storesMu sync.RWMutex
+	stores   = map[string]ResultStoreFactory{
+		"memory": func(context.Context, ResultStoreConfig) (ResultStore, error) {
+			return NewMemoryResultStore(), nil
+		},
+		"postgres": openPostgresStore,
+		"sqlite":   openSQLiteStore,
+	}
+)
+
+// RegisterResultStore makes a result store type available to config files
+// under typ, replacing any store type of that name. It panics if typ is
+// empty or f is nil.
+func RegisterResultStore(typ string, f ResultStoreFactory) {
+	if typ == "" || f == nil {
+		panic(fmt.Sprintf("matchspec: RegisterResultStore(%q) with empty type or nil factory", typ))
+	}
+	storesMu.Lock()
+	defer storesMu.Unlock()
+	stores[typ] = f
+}
+
+// OpenResultStore opens the result store cfg describes, wrapped in a
+// SpoolResultStore if it has a spool directory.
+func OpenResultStore(ctx context.Context, cfg ResultStoreConfig) (ResultStore, error) {
+	storesMu.RLock()
+	f, ok := stores[cfg.Type]
+	types := slices.Sorted(maps.Keys(stores))
+	storesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("matchspec: unknown result store type %q (want one of %s)", cfg.Type, strings.Join(types, ", "))
+	}
+	s, err := f(ctx, cfg)
+	if err != nil || cfg.Spool == "" {
+		return s, err
+	}
+	var opts []StoreOption
+	if cfg.Compress {
+		opts = append(opts, Compress())
+	}
+	return NewSpoolResultStore(s, cfg.Spool, opts...)
+}
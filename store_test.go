@@ -0,0 +1,238 @@
+package matchspec
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+// testResultStore checks the ResultStore contract against s, which must
+// be empty.
+func testResultStore(t *testing.T, s ResultStore) {
+	t.Helper()
+	ctx := context.Background()
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	stored := func(id, suite string, day int, labels ...string) Checkpoint {
+		return Checkpoint{
+			Version: CheckpointVersion,
+			Run:     protocol.EvalRun{Suite: suite},
+			Record:  RunRecord{ID: id, Suite: suite, Labels: labels, StartedAt: base.AddDate(0, 0, day)},
+			Results: []Result{{EvalResult: protocol.EvalResult{Suite: suite, Task: id + "-task", Passed: true}}},
+		}
+	}
+	for _, cp := range []Checkpoint{
+		stored("a", "math", 0, "nightly"),
+		stored("b", "math", 1),
+		stored("c", "qa", 2, "nightly"),
+	} {
+		if err := s.Append(ctx, cp); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids := func(cps []Checkpoint) string {
+		var out []string
+		for _, cp := range cps {
+			out = append(out, cp.Record.ID)
+		}
+		return strings.Join(out, ",")
+	}
+	for _, tt := range []struct {
+		f     RunFilter
+		want  string
+		total int
+	}{
+		{RunFilter{}, "c,b,a", 3},
+		{RunFilter{Suite: "math"}, "b,a", 2},
+		{RunFilter{Labels: []string{"nightly"}}, "c,a", 2},
+		{RunFilter{Since: base.AddDate(0, 0, 1)}, "c,b", 2},
+		{RunFilter{Limit: 1, Offset: 1}, "b", 3},
+	} {
+		cps, total, err := s.Query(ctx, tt.f)
+		if err != nil || ids(cps) != tt.want || total != tt.total {
+			t.Errorf("Query(%+v) = %s (%d), %v; want %s (%d)", tt.f, ids(cps), total, err, tt.want, tt.total)
+		}
+	}
+
+	// Appending a run again replaces it.
+	deleted := stored("b", "math", 1)
+	deleted.Record.DeletedAt = base.AddDate(0, 0, 5)
+	if err := s.Append(ctx, deleted); err != nil {
+		t.Fatal(err)
+	}
+	if cps, _, _ := s.Query(ctx, RunFilter{}); ids(cps) != "c,a" {
+		t.Errorf("live after soft delete = %s", ids(cps))
+	}
+	cps, _, err := s.Query(ctx, RunFilter{Deleted: true})
+	if err != nil || ids(cps) != "b" || cps[0].Results[0].Task != "b-task" || !cps[0].Record.DeletedAt.Equal(deleted.Record.DeletedAt) {
+		t.Errorf("deleted = %+v, %v", cps, err)
+	}
+
+	if n, err := s.Delete(ctx, "a", "b", "missing"); err != nil || n != 2 {
+		t.Errorf("Delete = %d, %v", n, err)
+	}
+	if cps, total, _ := s.Query(ctx, RunFilter{}); ids(cps) != "c" || total != 1 {
+		t.Errorf("after delete = %s", ids(cps))
+	}
+}
+
+func TestMemoryResultStore(t *testing.T) {
+	testResultStore(t, NewMemoryResultStore())
+}
+
+func TestRunnerResultStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryResultStore()
+	infer := func(ctx context.Context, prompt string) (string, error) {
+		if prompt == "What is 3*4?" {
+			return "", errors.New("account alice@example.com is rate limited")
+		}
+		return "4", nil
+	}
+	newRunner := func() *Runner {
+		return NewRunner(driftRegistry(), infer, tokentrace.NewReporter("matchspec", ""), WithResultStore(store))
+	}
+
+	runner := newRunner()
+	for range 3 {
+		runner.Run(ctx, protocol.EvalRun{Suite: "math"})
+	}
+	runs, _ := runner.Runs(RunFilter{})
+	if n, err := runner.DeleteRuns(DeleteFilter{RunID: runs[0].ID}); err != nil || n != 1 {
+		t.Fatalf("DeleteRuns = %d, %v", n, err)
+	}
+	if _, err := runner.Erase(regexp.MustCompile(`alice@example\.com`)); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new runner, as after a restart, picks up where the old one left off.
+	restarted := newRunner()
+	if n, err := restarted.LoadStore(ctx); err != nil || n != 3 {
+		t.Fatalf("LoadStore = %d, %v", n, err)
+	}
+	if n, _ := restarted.LoadStore(ctx); n != 0 {
+		t.Errorf("second LoadStore added %d runs", n)
+	}
+	got, total := restarted.Runs(RunFilter{})
+	if total != 2 || got[0].ID != runs[1].ID || got[1].ID != runs[2].ID {
+		t.Errorf("restored runs = %+v", got)
+	}
+	results := restarted.Results()
+	if len(results) != 4 {
+		t.Fatalf("restored results = %d", len(results))
+	}
+	for _, res := range results {
+		if strings.Contains(res.Error, "alice") {
+			t.Errorf("erased error stored: %q", res.Error)
+		}
+	}
+	if _, err := restarted.RestoreRun(runs[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if cps, _, _ := store.Query(ctx, RunFilter{}); len(cps) != 3 {
+		t.Errorf("stored live runs after restore = %d", len(cps))
+	}
+
+	restarted.DeleteRuns(DeleteFilter{Suite: "math"})
+	if stats := restarted.Purge(time.Time{}); stats.Runs != 3 {
+		t.Errorf("purge = %+v", stats)
+	}
+	for _, deleted := range []bool{false, true} {
+		if _, total, _ := store.Query(ctx, RunFilter{Deleted: deleted}); total != 0 {
+			t.Errorf("stored runs after purge (deleted=%v) = %d", deleted, total)
+		}
+	}
+}
+
+// failingStore fails every write.
+type failingStore struct{ *MemoryResultStore }
+
+func (failingStore) Append(context.Context, Checkpoint) error { return errors.New("disk full") }
+
+func TestRunnerResultStoreErrors(t *testing.T) {
+	runner := NewRunner(driftRegistry(), func(ctx context.Context, prompt string) (string, error) { return "4", nil },
+		tokentrace.NewReporter("matchspec", ""), WithResultStore(failingStore{NewMemoryResultStore()}))
+	if _, err := runner.Run(context.Background(), protocol.EvalRun{Suite: "math"}); err != nil {
+		t.Fatalf("run failed with the store: %v", err)
+	}
+	if _, err := runner.DeleteRuns(DeleteFilter{Suite: "math"}); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("DeleteRuns: %v", err)
+	}
+	if n := runner.StoreErrors(); n != 2 {
+		t.Errorf("StoreErrors = %d, want 2", n)
+	}
+}
+
+func TestConfigResultStore(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "matchspec.yaml")
+	os.WriteFile(config, []byte("suites: []\nstore:\n  type: sqlite\n  dsn: data/results.db\n"), 0o644)
+	c, err := LoadConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Store == nil || c.Store.DSN != filepath.Join(dir, "data", "results.db") {
+		t.Errorf("store = %+v", c.Store)
+	}
+	if !isRelativeFile("results.db") || isRelativeFile("file:results.db?mode=ro") || isRelativeFile(":memory:") {
+		t.Error("isRelativeFile")
+	}
+	if _, err := OpenResultStore(context.Background(), ResultStoreConfig{Type: "mongo"}); err == nil || !strings.Contains(err.Error(), "memory, postgres, sqlite") {
+		t.Errorf("unknown type: %v", err)
+	}
+}
+
+func TestRunnerSyncStore(t *testing.T) {
+	shared, err := NewPostgresResultStore(context.Background(), openFakeStore(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shared.Close()
+	for name, store := range map[string]ResultStore{"changes": shared, "reload": NewMemoryResultStore()} {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			infer := func(ctx context.Context, prompt string) (string, error) { return "4", nil }
+			a := NewRunner(driftRegistry(), infer, tokentrace.NewReporter("matchspec", ""), WithResultStore(store))
+			b := NewRunner(driftRegistry(), infer, tokentrace.NewReporter("matchspec", ""), WithResultStore(store))
+			go b.SyncStore(ctx, 10*time.Millisecond)
+			synced := func(want int) {
+				t.Helper()
+				deadline := time.Now().Add(2 * time.Second)
+				for {
+					if _, total := b.Runs(RunFilter{}); total == want {
+						return
+					}
+					if time.Now().After(deadline) {
+						t.Fatalf("replica never saw %d runs", want)
+					}
+					time.Sleep(5 * time.Millisecond)
+				}
+			}
+
+			a.Run(ctx, protocol.EvalRun{Suite: "math"})
+			runs, _ := a.Runs(RunFilter{})
+			synced(1)
+
+			if _, err := a.DeleteRuns(DeleteFilter{RunID: runs[0].ID}); err != nil {
+				t.Fatal(err)
+			}
+			synced(0)
+			if _, err := a.RestoreRun(runs[0].ID); err != nil {
+				t.Fatal(err)
+			}
+			synced(1)
+			if n := b.StoreErrors(); n != 0 {
+				t.Errorf("StoreErrors = %d", n)
+			}
+		})
+	}
+}
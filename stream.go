@@ -0,0 +1,177 @@
+package matchspec
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/trace"
+)
+
+// maxTaskLineBytes bounds a single JSONL task line.
+const maxTaskLineBytes = 16 << 20
+
+// TaskReader yields tasks one at a time. Next returns io.EOF after the last
+// task.
+type TaskReader interface {
+	Next() (Task, error)
+}
+
+// JSONLTaskReader reads one JSON-encoded Task per line. Blank lines are
+// skipped and each task is validated as it is read.
+type JSONLTaskReader struct {
+	suite string
+	sc    *bufio.Scanner
+	line  int
+}
+
+// NewJSONLTaskReader returns a reader of tasks for suite from r.
+func NewJSONLTaskReader(suite string, r io.Reader) *JSONLTaskReader {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), maxTaskLineBytes)
+	return &JSONLTaskReader{suite: suite, sc: sc}
+}
+
+// Next returns the next task.
+func (r *JSONLTaskReader) Next() (Task, error) {
+	for r.sc.Scan() {
+		r.line++
+		line := r.sc.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var t Task
+		if err := json.Unmarshal(line, &t); err != nil {
+			return Task{}, fmt.Errorf("matchspec: suite %q line %d: %w", r.suite, r.line, err)
+		}
+		if err := validateTask(r.suite, r.line-1, t); err != nil {
+			return Task{}, fmt.Errorf("%w (line %d)", err, r.line)
+		}
+		return t, nil
+	}
+	if err := r.sc.Err(); err != nil {
+		return Task{}, fmt.Errorf("matchspec: suite %q: %w", r.suite, err)
+	}
+	return Task{}, io.EOF
+}
+
+// RunStream evaluates tasks as they are read, with up to workers tasks in
+// flight, and sends each result to the runner's sinks. Results are neither
+// returned nor retained; only the score and duration of each task are kept
+// for the summary. The returned run record carries the summary and content
+// hash. With more than one worker, results (and the hash) follow
+// completion order.
+//
+// Suite-level features that need the whole task list, such as latency SLOs
+// and priority ordering, do not apply to streamed runs; quality gates do.
+func (r *Runner) RunStream(ctx context.Context, suite string, tasks TaskReader, workers int) (RunRecord, error) {
+	release, err := r.admit(ctx, suite)
+	if err != nil {
+		return RunRecord{}, err
+	}
+	defer release()
+	workers = max(workers, 1)
+	ctx, span := trace.Start(ctx, "matchspec.eval")
+	span.SetAttr("suite", suite)
+	span.SetAttr("streaming", true)
+	rec := newRunRecord(ctx, protocol.EvalRun{Suite: suite}, span, time.Now())
+	if s, ok := r.registry.Get(suite); ok {
+		rec.Owner = s.Owner
+	}
+	rec.Environment = r.environment(nil, rec.run, InferOptions{})
+	span.SetAttr("run_id", rec.ID)
+	ctx, usage, budget := r.runScope(ctx)
+	r.publishStarted(rec)
+
+	jobs := make(chan Task, workers)
+	out := make(chan Result, workers)
+	var readErr error
+	go func() {
+		defer close(jobs)
+		for {
+			t, err := tasks.Next()
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					readErr = err
+				}
+				return
+			}
+			for _, vt := range expandVariants([]Task{t}) {
+				select {
+				case jobs <- vt:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				out <- r.runTask(ctx, suite, t)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	tally := r.newRunTally()
+	var completed, failed int
+	for res := range out {
+		r.emit(ctx, sinkRunOf(&rec), res)
+		r.publishTask(rec.ID, res, nil)
+		tally.add(res)
+		completed++
+		if !res.Passed {
+			failed++
+		}
+	}
+
+	var runErr error
+	switch {
+	case readErr != nil:
+		runErr = readErr
+	case ctx.Err() != nil:
+		runErr = fmt.Errorf("matchspec: suite %q stopped after %d tasks: %w", suite, completed, ctx.Err())
+	}
+
+	span.SetAttr("passed", completed-failed)
+	span.SetAttr("failed", failed)
+	span.SetAttr("total", completed)
+	gatesOK := true
+	if s, ok := r.registry.Get(suite); ok {
+		rec.Gates = s.CheckGates(tally.summary.summary())
+		if len(rec.Gates) > 0 {
+			gatesOK = GatesPassed(rec.Gates)
+			span.SetAttr("gates_passed", gatesOK)
+		}
+	}
+	budget.setAttrs(span)
+	if runErr != nil {
+		span.SetAttr("error", r.redact(runErr.Error()))
+	}
+	if failed > 0 || !gatesOK || runErr != nil {
+		span.End("error")
+	} else {
+		span.End("ok")
+	}
+	r.reporter.Report(context.WithoutCancel(ctx), span)
+
+	return r.recordRun(ctx, rec, tally, nil, usage, runErr), runErr
+}
@@ -0,0 +1,105 @@
+package matchspec
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestJSONLTaskReader(t *testing.T) {
+	in := `{"name": "a", "prompt": "1+1", "expected": "2"}
+
+{"name": "b", "prompt": "2+2", "expected": "4", "matcher": "exact"}
+`
+	r := NewJSONLTaskReader("s", strings.NewReader(in))
+	var names []string
+	for {
+		task, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, task.Name)
+	}
+	if strings.Join(names, ",") != "a,b" {
+		t.Errorf("names = %v", names)
+	}
+
+	r = NewJSONLTaskReader("s", strings.NewReader("{\"name\": \"a\", \"prompt\": \"p\"}\n{\"name\": \"b\"}\n"))
+	r.Next()
+	if _, err := r.Next(); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("err = %v, want validation error on line 2", err)
+	}
+
+	r = NewJSONLTaskReader("s", strings.NewReader("not json\n"))
+	if _, err := r.Next(); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("err = %v, want decode error on line 1", err)
+	}
+}
+
+func TestRunStream(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 200; i++ {
+		expected := "echo"
+		if i%4 == 0 {
+			expected = "nope"
+		}
+		fmt.Fprintf(&b, "{\"name\": \"t%d\", \"prompt\": \"p%d\", \"expected\": %q}\n", i, i, expected)
+	}
+
+	var mu sync.Mutex
+	var seen []Result
+	sink := ResultSinkFunc(func(_ context.Context, r Result) error {
+		mu.Lock()
+		seen = append(seen, r)
+		mu.Unlock()
+		return nil
+	})
+	runner := NewRunner(NewSuiteRegistry(), echoInfer, tokentrace.NewReporter("matchspec", ""), WithSink(sink))
+
+	rec, err := runner.RunStream(context.Background(), "big", NewJSONLTaskReader("big", strings.NewReader(b.String())), 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 200 || rec.Summary.Total != 200 || rec.Summary.Passed != 150 {
+		t.Errorf("seen = %d, summary = %+v", len(seen), rec.Summary)
+	}
+	if rec.Hash != HashResults(seen) {
+		t.Error("streamed hash does not match the results in sink order")
+	}
+	if len(runner.Results()) != 0 {
+		t.Error("streamed results should not be retained")
+	}
+	if got, ok := runner.GetRun(rec.ID); !ok || got.Hash != rec.Hash {
+		t.Error("streamed run was not recorded")
+	}
+}
+
+func TestRunStreamReadError(t *testing.T) {
+	runner := NewRunner(NewSuiteRegistry(), echoInfer, tokentrace.NewReporter("matchspec", ""))
+	in := "{\"name\": \"a\", \"prompt\": \"p\", \"expected\": \"p\"}\nbroken\n"
+	rec, err := runner.RunStream(context.Background(), "s", NewJSONLTaskReader("s", strings.NewReader(in)), 1)
+	if err == nil {
+		t.Fatal("expected read error")
+	}
+	if rec.Summary.Total != 1 || rec.Error == "" {
+		t.Errorf("record = %+v", rec)
+	}
+}
+
+func TestRunStreamCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	runner := NewRunner(NewSuiteRegistry(), echoInfer, tokentrace.NewReporter("matchspec", ""))
+	in := "{\"name\": \"a\", \"prompt\": \"p\"}\n"
+	if _, err := runner.RunStream(ctx, "s", NewJSONLTaskReader("s", strings.NewReader(in)), 2); err == nil {
+		t.Error("expected cancellation error")
+	}
+}
@@ -0,0 +1,123 @@
+package matchspec
+
+import (
+	"strings"
+	"unicode"
+)
+
+// LevenshteinSimilarity returns 1 minus the edit distance between a and
+// b, in characters, divided by the length of the longer. Two empty
+// strings have similarity 1.
+func LevenshteinSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+// levenshtein returns the number of single-character insertions,
+// deletions, and substitutions that turn a into b.
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// scoreTokens splits text into lowercase words, dropping punctuation, for
+// TokenF1 and RougeL.
+func scoreTokens(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// TokenF1 returns the F1 of the words the response shares with expected,
+// counted as multisets, ignoring case and punctuation. Two texts without
+// words have F1 1.
+func TokenF1(expected, response string) float64 {
+	want, got := scoreTokens(expected), scoreTokens(response)
+	if len(want) == 0 || len(got) == 0 {
+		return boolFloat(len(want) == len(got))
+	}
+	counts := make(map[string]int, len(want))
+	for _, w := range want {
+		counts[w]++
+	}
+	common := 0
+	for _, w := range got {
+		if counts[w] > 0 {
+			counts[w]--
+			common++
+		}
+	}
+	return f1(float64(common)/float64(len(got)), float64(common)/float64(len(want)))
+}
+
+// RougeL returns the ROUGE-L F1 of the response against expected: the F1
+// of the precision and recall of their longest common subsequence of
+// words, ignoring case and punctuation. Two texts without words have
+// ROUGE-L 1.
+func RougeL(expected, response string) float64 {
+	want, got := scoreTokens(expected), scoreTokens(response)
+	if len(want) == 0 || len(got) == 0 {
+		return boolFloat(len(want) == len(got))
+	}
+	lcs := lcsLen(want, got)
+	return f1(float64(lcs)/float64(len(got)), float64(lcs)/float64(len(want)))
+}
+
+// lcsLen returns the length of the longest common subsequence of a and b.
+func lcsLen(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				cur[j] = prev[j-1] + 1
+			} else {
+				cur[j] = max(prev[j], cur[j-1])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func f1(precision, recall float64) float64 {
+	if precision+recall == 0 {
+		return 0
+	}
+	return 2 * precision * recall / (precision + recall)
+}
+
+func boolFloat(ok bool) float64 {
+	_, score := boolScore(ok)
+	return score
+}
+
+// matchGraded scores the response against Expected with score and passes
+// when the score reaches Threshold, or is 1 if Threshold is unset.
+func matchGraded(t *Task, response string, score func(expected, response string) float64) (bool, float64) {
+	s := score(t.Expected, response)
+	threshold := t.Threshold
+	if threshold == 0 {
+		threshold = 1
+	}
+	return s >= threshold, s
+}
@@ -0,0 +1,90 @@
+package matchspec
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLevenshteinSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"", "", 1},
+		{"abc", "", 0},
+		{"kitten", "sitting", 1 - 3.0/7},
+		{"Canberra", "Canberra", 1},
+		{"Canberra", "Canbera", 1 - 1.0/8},
+		{"café", "cafe", 0.75},
+	}
+	for _, tt := range tests {
+		if got := LevenshteinSimilarity(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("LevenshteinSimilarity(%q, %q) = %f, want %f", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestTokenF1(t *testing.T) {
+	tests := []struct {
+		expected, response string
+		want               float64
+	}{
+		{"", "", 1},
+		{"Paris", "", 0},
+		{"the Eiffel Tower", "The eiffel tower!", 1},
+		// 2 of 4 response words match, 2 of 3 expected words: P=0.5, R=2/3.
+		{"the Eiffel Tower", "it is the tower", 2 * 0.5 * (2.0 / 3) / (0.5 + 2.0/3)},
+		{"a a b", "a b b", 2.0 / 3},
+		{"yes", "no", 0},
+	}
+	for _, tt := range tests {
+		if got := TokenF1(tt.expected, tt.response); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("TokenF1(%q, %q) = %f, want %f", tt.expected, tt.response, got, tt.want)
+		}
+	}
+}
+
+func TestRougeL(t *testing.T) {
+	tests := []struct {
+		expected, response string
+		want               float64
+	}{
+		{"", "", 1},
+		{"the cat sat on the mat", "the cat sat on the mat", 1},
+		// LCS "the cat the mat" = 4; P=4/6, R=4/6.
+		{"the cat sat on the mat", "the cat lay upon the mat", 4.0 / 6},
+		// Order matters, unlike TokenF1: LCS is 1 of 3.
+		{"a b c", "c b a", 1.0 / 3},
+	}
+	for _, tt := range tests {
+		if got := RougeL(tt.expected, tt.response); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("RougeL(%q, %q) = %f, want %f", tt.expected, tt.response, got, tt.want)
+		}
+	}
+}
+
+func TestTaskMatchGraded(t *testing.T) {
+	tests := []struct {
+		matcher   string
+		threshold float64
+		response  string
+		wantPass  bool
+	}{
+		{"levenshtein", 0, "Canberra", true},
+		{"levenshtein", 0, "Canbera", false},
+		{"levenshtein", 0.85, "Canbera", true},
+		{"token_f1", 0.5, "It is Canberra.", true},
+		{"token_f1", 0.5, "Sydney", false},
+		{"rouge_l", 0, "canberra", true},
+	}
+	for _, tt := range tests {
+		task := Task{Matcher: tt.matcher, Expected: "Canberra", Threshold: tt.threshold}
+		passed, score := task.Match(tt.response)
+		if passed != tt.wantPass {
+			t.Errorf("%s(%q, threshold %v) = %v, %f; want passed %v", tt.matcher, tt.response, tt.threshold, passed, score, tt.wantPass)
+		}
+		if score <= 0 && tt.response != "Sydney" {
+			t.Errorf("%s(%q) score = %f, want partial credit", tt.matcher, tt.response, score)
+		}
+	}
+}
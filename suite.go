@@ -5,13 +5,53 @@ package matchspec
 
 import (
 	"fmt"
-	"strings"
+	"math"
+	"unicode/utf8"
 )
 
 // Suite defines an evaluation benchmark suite.
 type Suite struct {
 	Name  string `json:"name"`
 	Tasks []Task `json:"tasks"`
+
+	// LatencySLOs are percentile latency budgets checked after each run.
+	LatencySLOs []LatencySLO `json:"latency_slos,omitempty"`
+
+	// MinPassRate and MinAvgScore are quality gates checked after each run:
+	// the fraction of tasks passed and the mean score must reach them. Zero
+	// disables a gate. See CheckGates.
+	MinPassRate float64 `json:"min_pass_rate,omitempty"`
+	MinAvgScore float64 `json:"min_avg_score,omitempty"`
+
+	// Generator, if set, synthesizes additional tasks on every run.
+	Generator TaskGenerator `json:"-"`
+
+	// Sources are path patterns of files that affect every task in the
+	// suite, and TagSources those that affect the tasks with a given tag.
+	// See Affected.
+	Sources    []string            `json:"sources,omitempty"`
+	TagSources map[string][]string `json:"tag_sources,omitempty"`
+
+	// Vars are default template variables for the suite's tasks, overridden
+	// by the runner's (see WithVars).
+	Vars map[string]any `json:"vars,omitempty"`
+
+	// Headers are HTTP headers added to every task's inference request,
+	// such as tenant routing or auth for a multi-tenant gateway. Task
+	// headers override them.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// TimeoutMS and Retries are the defaults of the suite's tasks (see
+	// Task.TimeoutMS and Task.Retries).
+	TimeoutMS int64 `json:"timeout_ms,omitempty"`
+	Retries   int   `json:"retries,omitempty"`
+
+	// Owner is who maintains the suite, so failures on a shared server can
+	// be routed to them. It is copied to each run record.
+	Owner *SuiteOwner `json:"owner,omitempty"`
+
+	// file is the suite file the suite was loaded from, if any.
+	file string
 }
 
 // Task is a single evaluation task within a suite.
@@ -20,41 +60,196 @@ type Task struct {
 	Prompt   string `json:"prompt"`
 	Expected string `json:"expected"`
 
+	// ExpectedAny lists further acceptable answers, such as "four" and
+	// "IV" for "4". The task passes if the response matches Expected or
+	// any of them, and scores the best match.
+	ExpectedAny []string `json:"expected_any,omitempty"`
+
+	// ExpectedFile is a golden file holding the expected output, relative
+	// to the suite file. LoadSuiteFile reads it into Expected, and
+	// Suite.ApplyGolden rewrites it.
+	ExpectedFile string `json:"expected_file,omitempty"`
+
+	// Documents are retrieval context for RAG tasks. They are injected into
+	// the prompt (see RenderPrompt) and used by the grounding matchers.
+	Documents []string `json:"documents,omitempty"`
+
 	// Matcher determines how Expected is compared to the response.
-	// "exact", "contains", "prefix", "suffix"
+	// "exact", "contains", "not_contains", "prefix", "suffix", "citation", "grounded",
+	// "language", "numbers", "numeric", "date", "quantity", "urls", "sql",
+	// "semantic", "diff", "levenshtein", "token_f1", "rouge_l", "regex",
+	// "json", "jsonpath", "snapshot", "toxicity", "entities", a name
+	// registered with RegisterMatcher, or a name registered with
+	// WithExternalMatcher. Unknown names use DefaultMatcher.
 	Matcher string `json:"matcher"`
+
+	// Assertions, if set, replace Matcher and Expected with several
+	// checks, each with its own matcher. AssertionMode "all" (the default)
+	// passes when every assertion does, and "any" when one does; the score
+	// is their weighted mean either way.
+	Assertions    []Assertion `json:"assertions,omitempty"`
+	AssertionMode string      `json:"assertion_mode,omitempty"`
+
+	// Negate inverts the matcher: the task passes only if the matcher
+	// fails, and scores 1 minus the matcher's score. Use it to assert that
+	// forbidden content is absent.
+	Negate bool `json:"negate,omitempty"`
+
+	// Normalization options, such as ignore_case, apply to both the
+	// response and Expected before string matchers compare them.
+	Normalization
+
+	// MatcherOptions are passed to external matchers with each request.
+	MatcherOptions map[string]any `json:"matcher_options,omitempty"`
+
+	// AllowedURLs lists the URLs, URL prefixes (ending in "/"), or hosts
+	// that the "urls" matcher accepts.
+	AllowedURLs []string `json:"allowed_urls,omitempty"`
+
+	// Captures maps named groups of the "regex" matcher's pattern to the
+	// values they must capture.
+	Captures map[string]string `json:"captures,omitempty"`
+
+	// Entities are the entities the "entities" matcher expects to find in
+	// the response.
+	Entities []Entity `json:"entities,omitempty"`
+
+	// JSONPath is the path, such as "$.items[0].name", that the "jsonpath"
+	// matcher selects from the response. Expected is the value it must
+	// select.
+	JSONPath string `json:"jsonpath,omitempty"`
+
+	// Fixture is the data source name the "sql" matcher opens with the
+	// runner's SQL driver, typically the path to a SQLite database.
+	Fixture string `json:"fixture,omitempty"`
+
+	// Tolerance is the largest absolute difference at which numeric
+	// matchers treat two numbers as equal. For "quantity" it is in the
+	// unit of Expected.
+	Tolerance float64 `json:"tolerance,omitempty"`
+
+	// RelTolerance is the largest difference, as a fraction of the
+	// expected number, at which "numbers" and "numeric" treat two numbers
+	// as equal. The larger of Tolerance and RelTolerance applies.
+	RelTolerance float64 `json:"rel_tolerance,omitempty"`
+
+	// Unordered makes list matchers ignore the order of elements.
+	Unordered bool `json:"unordered,omitempty"`
+
+	// Threshold is the minimum score at which graded matchers such as
+	// "diff", "levenshtein", "token_f1", "rouge_l", "toxicity", "entities",
+	// and "semantic" pass. Zero requires a perfect score, except for
+	// "semantic" (see DefaultSemanticThreshold).
+	Threshold float64 `json:"threshold,omitempty"`
+
+	// Matchers are additional matchers run on every response for
+	// comparison. Their verdicts are recorded on the result (see
+	// CompareMatchers), but only Matcher decides pass or fail.
+	Matchers []string `json:"matchers,omitempty"`
+
+	// MaxLatencyMS, if positive, is this task's latency budget.
+	MaxLatencyMS int64 `json:"max_latency_ms,omitempty"`
+
+	// TimeoutMS, if positive, is the deadline of each inference call for
+	// the task. A call that misses it is retried like a transient error.
+	TimeoutMS int64 `json:"timeout_ms,omitempty"`
+
+	// Retries is how many times a transient inference failure of the task
+	// is retried, with exponential backoff. It overrides the runner's
+	// policy (see WithRetry) for the task.
+	Retries int `json:"retries,omitempty"`
+
+	// Tags label the task for filtering and reporting.
+	Tags []string `json:"tags,omitempty"`
+
+	// Headers are HTTP headers added to the task's inference request (see
+	// WithHeaders). Values are templates like Prompt, so credentials can
+	// come from secret variables.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Metadata is arbitrary data copied to the task's results, such as the
+	// dataset source or a difficulty label.
+	Metadata map[string]any `json:"metadata,omitempty"`
+
+	// Difficulty rates how hard the task is, on any positive scale such as
+	// 1 to 5. It weights the task in Summary.Weighted (see ScoringCurves).
+	Difficulty float64 `json:"difficulty,omitempty"`
+
+	// Priority orders execution within a run: higher values run first, and
+	// tasks of equal priority keep their suite order.
+	Priority int `json:"priority,omitempty"`
+
+	// Variants are alternative prompts run alongside Prompt for A/B
+	// comparison. See CompareVariants.
+	Variants []TaskVariant `json:"variants,omitempty"`
+
+	// Sources are path patterns of the files this task exercises, such as
+	// its prompt template or the code under test. See Suite.Affected.
+	Sources []string `json:"sources,omitempty"`
+
+	// variant names the prompt this copy of the task runs, set when
+	// Variants are expanded for a run.
+	variant string
+
+	// repeat numbers this copy of the task, from one, when the run repeats
+	// tasks (see WithRunRepeats).
+	repeat int
+
+	// generatorSeed is the seed of the generator that produced the task.
+	generatorSeed int64
 }
 
-// Match evaluates whether a response satisfies this task's expected output.
+// Match evaluates whether a response satisfies this task's expected output
+// with the registered matcher named by Matcher (see RegisterMatcher), or
+// with its Assertions, inverted if Negate is set.
 func (t *Task) Match(response string) (bool, float64) {
-	switch t.Matcher {
-	case "exact":
-		if response == t.Expected {
-			return true, 1.0
-		}
-		return false, 0.0
-	case "contains":
-		if strings.Contains(response, t.Expected) {
-			return true, 1.0
-		}
-		return false, 0.0
-	case "prefix":
-		if strings.HasPrefix(response, t.Expected) {
-			return true, 1.0
-		}
-		return false, 0.0
-	case "suffix":
-		if strings.HasSuffix(response, t.Expected) {
-			return true, 1.0
-		}
-		return false, 0.0
-	default:
-		// Default to contains match.
-		if strings.Contains(response, t.Expected) {
-			return true, 1.0
-		}
-		return false, 0.0
+	passed, score := t.match(response)
+	return t.negate(passed, score)
+}
+
+// match is Match without Negate.
+func (t *Task) match(response string) (bool, float64) {
+	passed, score, _ := t.matchDetails(response)
+	return passed, score
+}
+
+// matchDetails is match, with the details of a DetailedMatcher. Tasks
+// with Assertions or ExpectedAny have none.
+func (t *Task) matchDetails(response string) (bool, float64, map[string]any) {
+	if len(t.Assertions) > 0 {
+		passed, score, _ := combineAssertions(t, response, func(at *Task, response string) (bool, float64, error) {
+			passed, score := at.Match(response)
+			return passed, score, nil
+		})
+		return passed, score, nil
+	}
+	if len(t.ExpectedAny) > 0 {
+		passed, score, _ := matchAnyExpected(t, response, func(ct *Task, response string) (bool, float64, error) {
+			passed, score := ct.match(response)
+			return passed, score, nil
+		})
+		return passed, score, nil
 	}
+	name := t.Matcher
+	m, ok := LookupMatcher(name)
+	if !ok {
+		name = DefaultMatcher
+		m, _ = LookupMatcher(name)
+	}
+	nt, response := normalizeFor(name, *t, response)
+	if dm, ok := m.(DetailedMatcher); ok {
+		return dm.MatchDetails(nt, response)
+	}
+	passed, score := m.Match(nt, response)
+	return passed, score, nil
+}
+
+// negate inverts a match result if the task is negated.
+func (t *Task) negate(passed bool, score float64) (bool, float64) {
+	if !t.Negate {
+		return passed, score
+	}
+	return !passed, 1 - score
 }
 
 // Validate checks that the suite is well-formed.
@@ -62,20 +257,143 @@ func (s *Suite) Validate() error {
 	if s.Name == "" {
 		return fmt.Errorf("matchspec: suite name is required")
 	}
-	if len(s.Tasks) == 0 {
+	if len(s.Tasks) == 0 && s.Generator == nil {
 		return fmt.Errorf("matchspec: suite %q has no tasks", s.Name)
 	}
 	for i, t := range s.Tasks {
-		if t.Name == "" {
-			return fmt.Errorf("matchspec: suite %q task[%d] has no name", s.Name, i)
+		if err := validateTask(s.Name, i, t); err != nil {
+			return err
+		}
+	}
+	if err := validateSources(s.Name, "suite", s.Sources); err != nil {
+		return err
+	}
+	if err := validateHeaders(s.Name, "suite", s.Headers); err != nil {
+		return err
+	}
+	if err := validateLimits(s.Name, "suite", s.TimeoutMS, s.Retries); err != nil {
+		return err
+	}
+	if err := validateGates(s); err != nil {
+		return err
+	}
+	for tag, patterns := range s.TagSources {
+		if err := validateSources(s.Name, fmt.Sprintf("tag %q", tag), patterns); err != nil {
+			return err
+		}
+	}
+	if s.Owner != nil {
+		if err := s.Owner.validate(s.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateTask(suite string, i int, t Task) error {
+	if t.Name == "" {
+		return fmt.Errorf("matchspec: suite %q task[%d] has no name", suite, i)
+	}
+	if t.Prompt == "" {
+		return fmt.Errorf("matchspec: suite %q task %q has no prompt", suite, t.Name)
+	}
+	if err := validateSources(suite, fmt.Sprintf("task %q", t.Name), t.Sources); err != nil {
+		return err
+	}
+	if err := validateHeaders(suite, fmt.Sprintf("task %q", t.Name), t.Headers); err != nil {
+		return err
+	}
+	if err := validateLimits(suite, fmt.Sprintf("task %q", t.Name), t.TimeoutMS, t.Retries); err != nil {
+		return err
+	}
+	if nonFinite(t.Metadata) || nonFinite(t.Difficulty) {
+		return fmt.Errorf("matchspec: suite %q task %q has a NaN or infinite number in its metadata or difficulty", suite, t.Name)
+	}
+	if err := validateExpected(suite, t); err != nil {
+		return err
+	}
+	if err := validateAssertions(suite, t); err != nil {
+		return err
+	}
+	return validateVariants(suite, t)
+}
+
+// nonFinite reports whether v, a number or decoded JSON value, is or
+// holds a NaN or infinity, which JSON cannot encode.
+func nonFinite(v any) bool {
+	switch v := v.(type) {
+	case float64:
+		return math.IsNaN(v) || math.IsInf(v, 0)
+	case float32:
+		return nonFinite(float64(v))
+	case map[string]any:
+		for _, e := range v {
+			if nonFinite(e) {
+				return true
+			}
 		}
-		if t.Prompt == "" {
-			return fmt.Errorf("matchspec: suite %q task %q has no prompt", s.Name, t.Name)
+	case []any:
+		for _, e := range v {
+			if nonFinite(e) {
+				return true
+			}
 		}
 	}
+	return false
+}
+
+// validateMatcher checks the task's settings for its matcher.
+func validateMatcher(suite string, t Task) error {
+	switch t.Matcher {
+	case "regex":
+		return validateRegex(suite, t)
+	case "numeric":
+		return validateNumeric(suite, t)
+	case "json", "jsonpath":
+		return validateJSON(suite, t)
+	}
 	return nil
 }
 
+// SuiteStats describes the composition of a suite.
+type SuiteStats struct {
+	Name            string         `json:"name"`
+	TaskCount       int            `json:"task_count"`
+	Generated       bool           `json:"generated"`
+	Matchers        map[string]int `json:"matchers"`
+	Tags            map[string]int `json:"tags"`
+	AvgPromptLength float64        `json:"avg_prompt_length"`
+}
+
+// Stats returns matcher and tag counts and the average prompt length (in
+// characters) of the suite's static tasks. Tasks without a matcher count
+// as "contains", the default.
+func (s *Suite) Stats() SuiteStats {
+	st := SuiteStats{
+		Name:      s.Name,
+		TaskCount: len(s.Tasks),
+		Generated: s.Generator != nil,
+		Matchers:  make(map[string]int),
+		Tags:      make(map[string]int),
+	}
+	var chars int
+	for _, t := range s.Tasks {
+		m := t.Matcher
+		if m == "" {
+			m = "contains"
+		}
+		st.Matchers[m]++
+		for _, tag := range t.Tags {
+			st.Tags[tag]++
+		}
+		chars += utf8.RuneCountInString(t.Prompt)
+	}
+	if len(s.Tasks) > 0 {
+		st.AvgPromptLength = float64(chars) / float64(len(s.Tasks))
+	}
+	return st
+}
+
 // SuiteRegistry holds named evaluation suites.
 type SuiteRegistry struct {
 	suites map[string]*Suite
@@ -0,0 +1,212 @@
+package matchspec
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Config is a matchspec project file, such as matchspec.yaml.
+type Config struct {
+	// Suites are suite definition files or directories of them, relative
+	// to the config file.
+	Suites []string `json:"suites"`
+
+	// ModelPin, if set, is the model version runs must be served by (see
+	// WithModelPin).
+	ModelPin *ModelPin `json:"model_pin,omitempty"`
+
+	// Share controls what sharing bundles reveal (see ShareRecorder).
+	Share *ShareConfig `json:"share,omitempty"`
+
+	// Notifiers are told about every run, and Regression, if set, is
+	// when they are told a run regressed (see WithRegressionCheck).
+	Notifiers  []NotifierConfig `json:"notifiers,omitempty"`
+	Regression *DriftConfig     `json:"regression,omitempty"`
+
+	// Store, if set, persists run history across restarts (see
+	// OpenResultStore).
+	Store *ResultStoreConfig `json:"store,omitempty"`
+
+	// InferLimits, if set, cap the runner's inference calls (see
+	// WithInferLimits); "matchspec bench --write-config" records its
+	// recommendation here.
+	InferLimits *InferLimits `json:"infer_limits,omitempty"`
+
+	// Quotas, if set, limit each API key's use of the server (see
+	// QuotaLimiter).
+	Quotas *QuotaConfig `json:"quotas,omitempty"`
+}
+
+// LoadConfig reads a project config from a .yaml, .yml, or .json file and
+// resolves its suite paths against the file's directory.
+func LoadConfig(path string) (*Config, error) {
+	var c Config
+	if err := loadConfigFile(path, "config", &c); err != nil {
+		return nil, err
+	}
+	if c.ModelPin != nil {
+		if err := c.ModelPin.Validate(); err != nil {
+			return nil, fmt.Errorf("%w (in %s)", err, path)
+		}
+	}
+	if c.Share != nil {
+		if err := c.Share.Validate(); err != nil {
+			return nil, fmt.Errorf("%w (in %s)", err, path)
+		}
+	}
+	if c.Quotas != nil {
+		if err := c.Quotas.Validate(); err != nil {
+			return nil, fmt.Errorf("%w (in %s)", err, path)
+		}
+	}
+	dir := filepath.Dir(path)
+	for i, p := range c.Suites {
+		if !filepath.IsAbs(p) {
+			c.Suites[i] = filepath.Join(dir, p)
+		}
+	}
+	if s := c.Store; s != nil && s.Type == "sqlite" && isRelativeFile(s.DSN) {
+		s.DSN = filepath.Join(dir, s.DSN)
+	}
+	if s := c.Store; s != nil && s.Spool != "" && !filepath.IsAbs(s.Spool) {
+		s.Spool = filepath.Join(dir, s.Spool)
+	}
+	return &c, nil
+}
+
+// isRelativeFile reports whether dsn is a relative file path, rather than
+// an absolute path, a URI such as "file:evals.db?mode=ro", or ":memory:".
+func isRelativeFile(dsn string) bool {
+	return dsn != "" && !filepath.IsAbs(dsn) && !strings.Contains(dsn, ":")
+}
+
+// NotifierOptions builds the config's notifiers and regression check as
+// runner options. client is passed to notifiers that call HTTP endpoints.
+func (c *Config) NotifierOptions(client *http.Client) ([]RunnerOption, error) {
+	var opts []RunnerOption
+	for _, nc := range c.Notifiers {
+		n, err := NewNotifier(nc, client)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithNotifier(n))
+	}
+	if c.Regression != nil {
+		opts = append(opts, WithRegressionCheck(*c.Regression))
+	}
+	return opts, nil
+}
+
+// RegisterSuites loads the config's suites into the registry.
+func (c *Config) RegisterSuites(r *SuiteRegistry) error {
+	return RegisterSuiteFiles(r, c.Suites...)
+}
+
+// LoadSuiteFile reads a suite definition from a .yaml, .yml, or .json
+// file and validates it. The file holds one suite in the same form as the
+// built-in suites. Tasks' expected_file golden files are read relative to
+// it.
+func LoadSuiteFile(path string) (*Suite, error) {
+	var s Suite
+	if err := loadConfigFile(path, "suite", &s); err != nil {
+		return nil, err
+	}
+	s.file = path
+	for i := range s.Tasks {
+		t := &s.Tasks[i]
+		if t.ExpectedFile == "" {
+			continue
+		}
+		if t.Expected != "" {
+			return nil, fmt.Errorf("matchspec: %s: task %q sets both expected and expected_file", path, t.Name)
+		}
+		if !filepath.IsAbs(t.ExpectedFile) {
+			t.ExpectedFile = filepath.Join(filepath.Dir(path), t.ExpectedFile)
+		}
+		data, err := os.ReadFile(t.ExpectedFile)
+		if err != nil {
+			return nil, fmt.Errorf("matchspec: %s: task %q: %w", path, t.Name, err)
+		}
+		t.Expected = string(data)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, fmt.Errorf("%w (in %s)", err, path)
+	}
+	return &s, nil
+}
+
+// LoadSuiteDir reads every .yaml, .yml, and .json file under dir as a
+// suite, in lexical path order. Two files defining the same suite name
+// are an error.
+func LoadSuiteDir(dir string) ([]*Suite, error) {
+	var suites []*Suite
+	seen := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !isSuiteFile(path) {
+			return err
+		}
+		s, err := LoadSuiteFile(path)
+		if err != nil {
+			return err
+		}
+		if prev, dup := seen[s.Name]; dup {
+			return fmt.Errorf("matchspec: suite %q is defined in both %s and %s", s.Name, prev, path)
+		}
+		seen[s.Name] = path
+		suites = append(suites, s)
+		return nil
+	})
+	if err != nil {
+		if _, ok := err.(*fs.PathError); ok {
+			return nil, fmt.Errorf("matchspec: %w", err)
+		}
+		return nil, err
+	}
+	return suites, nil
+}
+
+// RegisterSuiteFiles loads the suites in each path, a suite file or a
+// directory of them, and adds them to the registry. A suite whose name is
+// already registered is an error, so a file cannot silently replace a
+// built-in or another file's suite.
+func RegisterSuiteFiles(r *SuiteRegistry, paths ...string) error {
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("matchspec: %w", err)
+		}
+		var suites []*Suite
+		if info.IsDir() {
+			if suites, err = LoadSuiteDir(path); err != nil {
+				return err
+			}
+		} else {
+			s, err := LoadSuiteFile(path)
+			if err != nil {
+				return err
+			}
+			suites = []*Suite{s}
+		}
+		for _, s := range suites {
+			if _, dup := r.Get(s.Name); dup {
+				return fmt.Errorf("matchspec: suite %q in %s is already registered", s.Name, path)
+			}
+			if err := r.Register(s); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func isSuiteFile(path string) bool {
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
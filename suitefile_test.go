@@ -0,0 +1,101 @@
+package matchspec
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadSuiteFileYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "math.yaml")
+	os.WriteFile(path, []byte(`name: math
+vars:
+  unit: cm
+tasks:
+  - name: add
+    prompt: What is 1+1?
+    expected: "2"
+    matcher: exact
+  - name: area
+    prompt: |
+      A square has sides of 3 {{.unit}}.
+      What is its area?
+    expected: "9"
+    matcher: numbers
+    tags: [geometry]
+`), 0o644)
+
+	s, err := LoadSuiteFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "math" || len(s.Tasks) != 2 || s.Vars["unit"] != "cm" {
+		t.Fatalf("unexpected suite: %+v", s)
+	}
+	if s.Tasks[0].Expected != "2" || s.Tasks[1].Matcher != "numbers" || !strings.HasSuffix(s.Tasks[1].Prompt, "area?\n") {
+		t.Errorf("unexpected tasks: %+v", s.Tasks)
+	}
+}
+
+func TestLoadSuiteFileInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.json")
+	os.WriteFile(path, []byte(`{"name": "empty"}`), 0o644)
+	_, err := LoadSuiteFile(path)
+	if err == nil || !strings.Contains(err.Error(), "bad.json") {
+		t.Errorf("err = %v, want validation error naming the file", err)
+	}
+}
+
+func TestLoadSuiteDir(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "nested"), 0o755)
+	os.WriteFile(filepath.Join(dir, "a.json"), []byte(`{"name": "a", "tasks": [{"name": "t", "prompt": "p", "expected": "e"}]}`), 0o644)
+	os.WriteFile(filepath.Join(dir, "nested", "b.yml"), []byte("name: b\ntasks:\n  - {name: t, prompt: p, expected: e}\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a suite"), 0o644)
+
+	suites, err := LoadSuiteDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range suites {
+		names = append(names, s.Name)
+	}
+	if !slices.Equal(names, []string{"a", "b"}) {
+		t.Errorf("names = %v, want [a b]", names)
+	}
+
+	os.WriteFile(filepath.Join(dir, "c.json"), []byte(`{"name": "a", "tasks": [{"name": "t", "prompt": "p", "expected": "e"}]}`), 0o644)
+	if _, err := LoadSuiteDir(dir); err == nil || !strings.Contains(err.Error(), "defined in both") {
+		t.Errorf("err = %v, want duplicate suite error", err)
+	}
+}
+
+func TestConfigRegisterSuites(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "suites"), 0o755)
+	os.WriteFile(filepath.Join(dir, "suites", "a.json"), []byte(`{"name": "a", "tasks": [{"name": "t", "prompt": "p", "expected": "e"}]}`), 0o644)
+	os.WriteFile(filepath.Join(dir, "extra.yaml"), []byte("name: extra\ntasks:\n  - {name: t, prompt: p, expected: e}\n"), 0o644)
+	config := filepath.Join(dir, "matchspec.yaml")
+	os.WriteFile(config, []byte("suites:\n  - suites\n  - extra.yaml\n"), 0o644)
+
+	c, err := LoadConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := NewSuiteRegistry()
+	if err := c.RegisterSuites(reg); err != nil {
+		t.Fatal(err)
+	}
+	names := reg.Names()
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"a", "extra"}) {
+		t.Errorf("names = %v, want [a extra]", names)
+	}
+
+	if err := c.RegisterSuites(reg); err == nil || !strings.Contains(err.Error(), "already registered") {
+		t.Errorf("err = %v, want already registered error", err)
+	}
+}
@@ -0,0 +1,216 @@
+package matchspec
+
+import (
+	"math"
+	"slices"
+	"sort"
+)
+
+// HistogramBuckets is the number of equal-width score buckets in a Summary.
+const HistogramBuckets = 10
+
+// Summary aggregates a set of evaluation results.
+type Summary struct {
+	Total     int     `json:"total"`
+	Passed    int     `json:"passed"`
+	Failed    int     `json:"failed"`
+	Errors    int     `json:"errors"`
+	PassRate  float64 `json:"pass_rate"`
+	MeanScore float64 `json:"mean_score"`
+
+	// Score distribution, for interpreting continuous matchers.
+	MinScore    float64         `json:"min_score"`
+	MaxScore    float64         `json:"max_score"`
+	StdDevScore float64         `json:"stddev_score"`
+	Percentiles ScorePercentile `json:"percentiles"`
+	Histogram   []HistogramBin  `json:"histogram"`
+
+	// Task duration percentiles in milliseconds.
+	Latency LatencyPercentile `json:"latency"`
+
+	// Variants compares prompt variants, for tasks that declare them.
+	Variants []VariantStats `json:"variants,omitempty"`
+
+	// Agreement reports disagreement between matchers, for tasks that
+	// declare extra Matchers.
+	Agreement []MatcherAgreement `json:"agreement,omitempty"`
+
+	// Weighted aggregates results by task difficulty under
+	// DefaultScoringCurve. It is nil if no task has a difficulty.
+	Weighted *WeightedSummary `json:"weighted,omitempty"`
+
+	// Shadow compares the candidate backend with the primary, for runs
+	// with WithShadow. It is nil otherwise.
+	Shadow *ShadowSummary `json:"shadow,omitempty"`
+
+	// Repeats aggregates repeated executions of each task into pass@k and
+	// flakiness, for runs with WithRunRepeats. It is nil otherwise.
+	Repeats *RepeatSummary `json:"repeats,omitempty"`
+
+	// CacheHits and CacheMisses count the results answered from and stored
+	// in the runner's response cache (see WithResponseCache).
+	CacheHits   int `json:"cache_hits,omitempty"`
+	CacheMisses int `json:"cache_misses,omitempty"`
+
+	// Usage is the token spend of the subject and judge models. It is set
+	// on run records; Summarize leaves it nil.
+	Usage *TokenUsage `json:"usage,omitempty"`
+}
+
+// LatencyPercentile holds task duration percentiles in milliseconds.
+// Results answered from the response cache are not counted.
+type LatencyPercentile struct {
+	P50 float64 `json:"p50_ms"`
+	P95 float64 `json:"p95_ms"`
+	P99 float64 `json:"p99_ms"`
+}
+
+// ScorePercentile holds score percentiles.
+type ScorePercentile struct {
+	P10 float64 `json:"p10"`
+	P25 float64 `json:"p25"`
+	P50 float64 `json:"p50"`
+	P75 float64 `json:"p75"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+}
+
+// HistogramBin counts scores in [Lower, Upper). The last bin includes 1.0.
+type HistogramBin struct {
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+	Count int     `json:"count"`
+}
+
+// Summarize computes pass counts and the score distribution of results.
+// Scores are expected in [0, 1]; values outside are clamped into the edge
+// histogram bins.
+func Summarize(results []Result) Summary {
+	var b summaryBuilder
+	for _, r := range results {
+		b.add(r)
+	}
+	return b.summary()
+}
+
+// summaryBuilder accumulates a Summary one result at a time. It keeps only
+// counters and the score and duration of each result, so streamed runs can
+// be summarized without holding their results.
+type summaryBuilder struct {
+	s          Summary
+	sum        float64
+	scores     []float64
+	durations  []float64
+	variants   variantCounter
+	agreement  agreementCounter
+	difficulty difficultyCounter
+	shadow     shadowCounter
+	repeats    repeatCounter
+}
+
+func (b *summaryBuilder) add(r Result) {
+	if b.s.Histogram == nil {
+		b.s.Histogram = make([]HistogramBin, HistogramBuckets)
+	}
+	b.s.Total++
+	if r.Passed {
+		b.s.Passed++
+	} else {
+		b.s.Failed++
+	}
+	if r.Error != "" {
+		b.s.Errors++
+	}
+	switch r.Cache {
+	case CacheHit:
+		b.s.CacheHits++
+	case CacheMiss:
+		b.s.CacheMisses++
+	}
+	b.scores = append(b.scores, r.Score)
+	if r.Cache != CacheHit {
+		b.durations = append(b.durations, float64(r.DurationMS))
+	}
+	b.sum += r.Score
+
+	bin := int(r.Score * HistogramBuckets)
+	bin = max(0, min(bin, HistogramBuckets-1))
+	b.s.Histogram[bin].Count++
+
+	b.variants.add(r)
+	b.agreement.add(r)
+	b.difficulty.add(r)
+	b.shadow.add(r)
+	b.repeats.add(r)
+}
+
+func (b *summaryBuilder) summary() Summary {
+	s := b.s
+	s.Histogram = make([]HistogramBin, HistogramBuckets)
+	for i := range s.Histogram {
+		s.Histogram[i].Lower = float64(i) / HistogramBuckets
+		s.Histogram[i].Upper = float64(i+1) / HistogramBuckets
+		if b.s.Histogram != nil {
+			s.Histogram[i].Count = b.s.Histogram[i].Count
+		}
+	}
+	if s.Total == 0 {
+		return s
+	}
+
+	scores := slices.Clone(b.scores)
+	sort.Float64s(scores)
+
+	n := float64(len(scores))
+	s.PassRate = float64(s.Passed) / n
+	s.MeanScore = b.sum / n
+	s.MinScore = scores[0]
+	s.MaxScore = scores[len(scores)-1]
+
+	var sq float64
+	for _, v := range scores {
+		sq += (v - s.MeanScore) * (v - s.MeanScore)
+	}
+	s.StdDevScore = math.Sqrt(sq / n)
+
+	s.Percentiles = ScorePercentile{
+		P10: percentile(scores, 10),
+		P25: percentile(scores, 25),
+		P50: percentile(scores, 50),
+		P75: percentile(scores, 75),
+		P90: percentile(scores, 90),
+		P99: percentile(scores, 99),
+	}
+
+	durations := slices.Clone(b.durations)
+	sort.Float64s(durations)
+	s.Latency = LatencyPercentile{
+		P50: percentile(durations, 50),
+		P95: percentile(durations, 95),
+		P99: percentile(durations, 99),
+	}
+	s.Variants = b.variants.stats()
+	s.Agreement = b.agreement.stats()
+	if b.difficulty.rated {
+		w := b.difficulty.summary(DefaultScoringCurve)
+		s.Weighted = &w
+	}
+	s.Shadow = b.shadow.summary()
+	s.Repeats = b.repeats.summary()
+	return s
+}
+
+// percentile returns the p-th percentile of sorted using linear
+// interpolation between closest ranks.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	if lo == hi {
+		return sorted[lo]
+	}
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(rank-float64(lo))
+}
@@ -0,0 +1,52 @@
+package matchspec
+
+import (
+	"math"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+)
+
+func TestSummarize(t *testing.T) {
+	results := []Result{
+		{EvalResult: protocol.EvalResult{Passed: true, Score: 1.0}},
+		{EvalResult: protocol.EvalResult{Passed: true, Score: 0.75}},
+		{EvalResult: protocol.EvalResult{Passed: false, Score: 0.5}},
+		{EvalResult: protocol.EvalResult{Passed: false, Score: 0.0, Error: "timeout"}},
+	}
+	s := Summarize(results)
+
+	if s.Total != 4 || s.Passed != 2 || s.Failed != 2 || s.Errors != 1 {
+		t.Errorf("counts = %+v", s)
+	}
+	if s.PassRate != 0.5 {
+		t.Errorf("PassRate = %f, want 0.5", s.PassRate)
+	}
+	if math.Abs(s.MeanScore-0.5625) > 1e-9 {
+		t.Errorf("MeanScore = %f, want 0.5625", s.MeanScore)
+	}
+	if s.MinScore != 0 || s.MaxScore != 1 {
+		t.Errorf("min/max = %f/%f", s.MinScore, s.MaxScore)
+	}
+	if s.Percentiles.P50 != 0.625 {
+		t.Errorf("P50 = %f, want 0.625", s.Percentiles.P50)
+	}
+
+	if len(s.Histogram) != HistogramBuckets {
+		t.Fatalf("histogram bins = %d", len(s.Histogram))
+	}
+	var total int
+	for _, b := range s.Histogram {
+		total += b.Count
+	}
+	if total != 4 || s.Histogram[0].Count != 1 || s.Histogram[9].Count != 1 {
+		t.Errorf("unexpected histogram: %+v", s.Histogram)
+	}
+}
+
+func TestSummarizeEmpty(t *testing.T) {
+	s := Summarize(nil)
+	if s.Total != 0 || s.PassRate != 0 || len(s.Histogram) != HistogramBuckets {
+		t.Errorf("unexpected empty summary: %+v", s)
+	}
+}
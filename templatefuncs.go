@@ -0,0 +1,85 @@
+package matchspec
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"text/template"
+	"unicode/utf8"
+)
+
+var (
+	templateFuncsMu sync.RWMutex
+	customFuncs     = template.FuncMap{}
+)
+
+// RegisterTemplateFunc makes fn available as name in task templates, such
+// as those of TemplateGenerator. It overrides a built-in function of the
+// same name. It panics if fn is not a function, like template.Funcs.
+func RegisterTemplateFunc(name string, fn any) {
+	if reflect.ValueOf(fn).Kind() != reflect.Func {
+		panic(fmt.Sprintf("matchspec: template func %q is not a function", name))
+	}
+	templateFuncsMu.Lock()
+	defer templateFuncsMu.Unlock()
+	customFuncs[name] = fn
+}
+
+// templateFuncs returns the functions available in task templates. Random
+// functions draw from rng so that rendering is reproducible from a seed,
+// and include reads files relative to dir.
+//
+//	json v           v encoded as JSON
+//	upper s, lower s s in upper or lower case
+//	trim s           s without leading and trailing space
+//	truncate n s     the first n characters of s
+//	choice a b ...   one argument at random
+//	include path     the contents of a file
+//	default d v      v, or d if v is empty
+func templateFuncs(rng *rand.Rand, dir string) template.FuncMap {
+	funcs := template.FuncMap{
+		"json": func(v any) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+		"trim":  strings.TrimSpace,
+		"truncate": func(n int, s string) string {
+			if utf8.RuneCountInString(s) <= n {
+				return s
+			}
+			return string([]rune(s)[:n])
+		},
+		"choice": func(options ...any) (any, error) {
+			if len(options) == 0 {
+				return nil, fmt.Errorf("choice of nothing")
+			}
+			return options[rng.IntN(len(options))], nil
+		},
+		"include": func(path string) (string, error) {
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+			b, err := os.ReadFile(path)
+			return string(b), err
+		},
+		"default": func(d, v any) any {
+			if v == nil || reflect.ValueOf(v).IsZero() {
+				return d
+			}
+			return v
+		},
+	}
+	templateFuncsMu.RLock()
+	defer templateFuncsMu.RUnlock()
+	for name, fn := range customFuncs {
+		funcs[name] = fn
+	}
+	return funcs
+}
@@ -0,0 +1,68 @@
+package matchspec
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTemplateGeneratorFuncs(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "policy.txt"), []byte("Be brief."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	g := NewTemplateGenerator("f",
+		`{{include "policy.txt"}} {{upper .name}} {{truncate 3 .city}} {{json .}} {{default "none" .missing}} {{choice "a" "b" "c"}}`,
+		`{{lower .name | trim}}`,
+		[]map[string]string{{"name": " Ada ", "city": "London", "missing": ""}}, 3)
+	g.Dir = dir
+
+	tasks, err := g.Generate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	prompt := tasks[0].Prompt
+	want := `Be brief.  ADA  Lon {"city":"London","missing":"","name":" Ada "} none `
+	if !strings.HasPrefix(prompt, want) {
+		t.Errorf("prompt = %q, want prefix %q", prompt, want)
+	}
+	if c := strings.TrimPrefix(prompt, want); c != "a" && c != "b" && c != "c" {
+		t.Errorf("choice = %q", c)
+	}
+	if tasks[0].Expected != "ada" {
+		t.Errorf("expected = %q, want %q", tasks[0].Expected, "ada")
+	}
+
+	// choice is reproducible from the seed.
+	again, _ := g.WithSeed(3).Generate(context.Background())
+	if again[0].Prompt != prompt {
+		t.Errorf("same seed rendered %q, then %q", prompt, again[0].Prompt)
+	}
+}
+
+func TestRegisterTemplateFunc(t *testing.T) {
+	RegisterTemplateFunc("reverse", func(s string) string {
+		r := []rune(s)
+		for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+			r[i], r[j] = r[j], r[i]
+		}
+		return string(r)
+	})
+	g := NewTemplateGenerator("r", `{{reverse .w}}`, `x`, []map[string]string{{"w": "abc"}}, 1)
+	tasks, err := g.Generate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tasks[0].Prompt != "cba" {
+		t.Errorf("prompt = %q, want %q", tasks[0].Prompt, "cba")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a non-function did not panic")
+		}
+	}()
+	RegisterTemplateFunc("bad", 42)
+}
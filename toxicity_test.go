@@ -0,0 +1,82 @@
+package matchspec
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestWordlistClassifier(t *testing.T) {
+	c := NewWordlistClassifier("darn", "go away")
+	tests := []struct {
+		text string
+		want float64
+	}{
+		{"Darn it.", 1},
+		{"He kept darning socks.", 1},
+		{"Please GO\n away now", 1},
+		{"The dam held.", 0},
+		{"Let's go to the bay.", 0},
+	}
+	for _, tt := range tests {
+		if got, _ := c.Toxicity(context.Background(), tt.text); got != tt.want {
+			t.Errorf("Toxicity(%q) = %f, want %f", tt.text, got, tt.want)
+		}
+	}
+	if got := c.Matches("darn, darned, go away"); !slices.Equal(got, []string{"darn", "darned", "go away"}) {
+		t.Errorf("Matches = %q", got)
+	}
+	if got, _ := NewWordlistClassifier().Toxicity(context.Background(), "anything"); got != 0 {
+		t.Errorf("empty wordlist Toxicity = %f, want 0", got)
+	}
+}
+
+func TestTaskMatchToxicity(t *testing.T) {
+	task := Task{Name: "t", Matcher: "toxicity"}
+	if passed, score := task.Match("Happy to help with your question."); !passed || score != 1 {
+		t.Errorf("clean response: %v, %f", passed, score)
+	}
+	if passed, score := task.Match("Shut up and read the manual."); passed || score != 0 {
+		t.Errorf("toxic response: %v, %f", passed, score)
+	}
+}
+
+func TestRunnerToxicityClassifier(t *testing.T) {
+	classifier := ToxicityClassifierFunc(func(ctx context.Context, text string) (float64, error) {
+		switch {
+		case strings.Contains(text, "rude"):
+			return 0.9, nil
+		case strings.Contains(text, "edgy"):
+			return 0.3, nil
+		case strings.Contains(text, "fail"):
+			return 0, errors.New("model unavailable")
+		}
+		return 0.05, nil
+	})
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "safety", Tasks: []Task{
+		{Name: "polite", Prompt: "polite", Matcher: "toxicity", Threshold: 0.5},
+		{Name: "edgy", Prompt: "edgy", Matcher: "toxicity", Threshold: 0.5},
+		{Name: "rude", Prompt: "rude", Matcher: "toxicity", Threshold: 0.5},
+		{Name: "fail", Prompt: "fail", Matcher: "toxicity"},
+	}})
+	runner := NewRunner(reg, echoInfer, tokentrace.NewReporter("matchspec", ""), WithToxicityClassifier(classifier))
+	results, err := runner.Run(context.Background(), protocol.EvalRun{Suite: "safety"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"polite": true, "edgy": true, "rude": false, "fail": false}
+	for _, res := range results {
+		if res.Passed != want[res.Task] {
+			t.Errorf("%s: passed = %v, want %v", res.Task, res.Passed, want[res.Task])
+		}
+	}
+	if !strings.Contains(results[3].Error, "model unavailable") {
+		t.Errorf("fail: error = %q, want classifier error", results[3].Error)
+	}
+}
@@ -0,0 +1,101 @@
+package matchspec
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultMaxIdleConnsPerHost is the idle connection pool size per host used
+// when TransportConfig.MaxIdleConnsPerHost is zero. net/http's default of 2
+// forces most connections of a concurrent run to be re-dialed.
+const DefaultMaxIdleConnsPerHost = 64
+
+// TransportConfig tunes the HTTP connections to an inference backend. The
+// zero value keeps a warm pool of DefaultMaxIdleConnsPerHost connections
+// and otherwise behaves like http.DefaultTransport.
+type TransportConfig struct {
+	// Timeout bounds each request, including reading the response.
+	// Zero means 2 minutes.
+	Timeout time.Duration
+
+	// MaxIdleConnsPerHost is the number of idle connections kept open to
+	// the backend. MaxConnsPerHost caps open connections; zero is no cap.
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+
+	// IdleConnTimeout closes pooled connections idle this long. Zero
+	// means 90 seconds.
+	IdleConnTimeout time.Duration
+
+	// KeepAlive is the TCP keep-alive probe interval. Zero means 30
+	// seconds. DisableKeepAlives turns off connection reuse entirely.
+	KeepAlive         time.Duration
+	DisableKeepAlives bool
+
+	// HTTP2 selects the protocol: "" negotiates HTTP/2 over TLS, "off"
+	// uses HTTP/1.1 only, and "h2c" speaks HTTP/2 over cleartext
+	// connections, as many self-hosted inference servers expect.
+	HTTP2 string
+
+	// Proxy is the proxy URL for backend requests. Empty uses the
+	// HTTP_PROXY and HTTPS_PROXY environment variables, and "direct"
+	// bypasses any proxy.
+	Proxy string
+}
+
+// Client returns an HTTP client with its own transport configured by c.
+func (c TransportConfig) Client() (*http.Client, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	keepAlive := c.KeepAlive
+	if keepAlive == 0 {
+		keepAlive = 30 * time.Second
+	}
+	t.DialContext = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: keepAlive}).DialContext
+	t.MaxIdleConnsPerHost = c.MaxIdleConnsPerHost
+	if t.MaxIdleConnsPerHost == 0 {
+		t.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	}
+	t.MaxIdleConns = max(t.MaxIdleConns, t.MaxIdleConnsPerHost)
+	t.MaxConnsPerHost = c.MaxConnsPerHost
+	if c.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = c.IdleConnTimeout
+	}
+	t.DisableKeepAlives = c.DisableKeepAlives
+
+	switch c.HTTP2 {
+	case "":
+	case "off":
+		t.Protocols = new(http.Protocols)
+		t.Protocols.SetHTTP1(true)
+	case "h2c":
+		// Without HTTP/1 enabled, http:// URLs use HTTP/2 with prior
+		// knowledge instead of an upgrade.
+		t.Protocols = new(http.Protocols)
+		t.Protocols.SetHTTP2(true)
+		t.Protocols.SetUnencryptedHTTP2(true)
+	default:
+		return nil, fmt.Errorf("matchspec: unknown HTTP2 mode %q (want \"\", \"off\", or \"h2c\")", c.HTTP2)
+	}
+
+	switch c.Proxy {
+	case "":
+	case "direct":
+		t.Proxy = nil
+	default:
+		u, err := url.Parse(c.Proxy)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("matchspec: invalid proxy URL %q", c.Proxy)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = 2 * time.Minute
+	}
+	return &http.Client{Transport: t, Timeout: timeout}, nil
+}
@@ -0,0 +1,98 @@
+package matchspec
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestTransportConfigReusesConnections(t *testing.T) {
+	var dials atomic.Int64
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"content": "ok"}`))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, s http.ConnState) {
+		if s == http.StateNew {
+			dials.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	client, err := TransportConfig{MaxIdleConnsPerHost: 8}.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	infer := InferMuxClientFunc(srv.URL, "m", client)
+	for range 5 {
+		var wg sync.WaitGroup
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := infer(context.Background(), "p"); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+	// Later rounds reuse the first round's connections rather than
+	// redialing, as net/http's default pool of 2 would.
+	if n := dials.Load(); n > 8 {
+		t.Errorf("dialed %d connections for 8 concurrent requests", n)
+	}
+}
+
+func TestTransportConfigH2C(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetHTTP1(true)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	defer srv.Close()
+
+	for mode, want := range map[string]int{"h2c": 2, "off": 1, "": 1} {
+		client, err := TransportConfig{HTTP2: mode}.Client()
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("%q: %v", mode, err)
+		}
+		resp.Body.Close()
+		if resp.ProtoMajor != want {
+			t.Errorf("HTTP2 %q: protocol %s, want HTTP/%d", mode, resp.Proto, want)
+		}
+	}
+}
+
+func TestTransportConfigProxy(t *testing.T) {
+	client, err := TransportConfig{Proxy: "http://proxy.internal:3128"}.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://backend/infer", nil)
+	u, err := client.Transport.(*http.Transport).Proxy(req)
+	if err != nil || u == nil || u.Host != "proxy.internal:3128" {
+		t.Errorf("proxy = %v, %v", u, err)
+	}
+
+	client, _ = TransportConfig{Proxy: "direct"}.Client()
+	if client.Transport.(*http.Transport).Proxy != nil {
+		t.Error("direct should bypass the proxy")
+	}
+
+	for _, bad := range []TransportConfig{{Proxy: "::"}, {HTTP2: "yes"}} {
+		if _, err := bad.Client(); err == nil {
+			t.Errorf("%+v: expected error", bad)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package matchspec
+
+import (
+	"cmp"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// unit converts a value to the SI base unit of its dimension as
+// (value + offset) * factor.
+type unit struct {
+	dim    string
+	factor float64
+	offset float64
+}
+
+// unitDefs lists units by symbol (matched case-sensitively) and by name
+// (matched case-insensitively, with a regular plural "s" accepted). "in"
+// is left out as a symbol because "5 in the box" is not a length.
+var unitDefs = []struct {
+	symbols []string
+	names   []string
+	unit
+}{
+	// Length, in meters.
+	{[]string{"km"}, []string{"kilometer", "kilometre"}, unit{"length", 1e3, 0}},
+	{[]string{"m"}, []string{"meter", "metre"}, unit{"length", 1, 0}},
+	{[]string{"cm"}, []string{"centimeter", "centimetre"}, unit{"length", 1e-2, 0}},
+	{[]string{"mm"}, []string{"millimeter", "millimetre"}, unit{"length", 1e-3, 0}},
+	{[]string{"µm", "μm", "um"}, []string{"micrometer", "micrometre", "micron"}, unit{"length", 1e-6, 0}},
+	{[]string{"nm"}, []string{"nanometer", "nanometre"}, unit{"length", 1e-9, 0}},
+	{[]string{"mi"}, []string{"mile"}, unit{"length", 1609.344, 0}},
+	{[]string{"yd"}, []string{"yard"}, unit{"length", 0.9144, 0}},
+	{[]string{"ft", "′"}, []string{"foot", "feet"}, unit{"length", 0.3048, 0}},
+	{[]string{"″"}, []string{"inch", "inches"}, unit{"length", 0.0254, 0}},
+
+	// Mass, in kilograms.
+	{[]string{"t"}, []string{"tonne", "metric ton"}, unit{"mass", 1e3, 0}},
+	{[]string{"kg"}, []string{"kilogram", "kilo"}, unit{"mass", 1, 0}},
+	{[]string{"g"}, []string{"gram"}, unit{"mass", 1e-3, 0}},
+	{[]string{"mg"}, []string{"milligram"}, unit{"mass", 1e-6, 0}},
+	{[]string{"lb", "lbs"}, []string{"pound"}, unit{"mass", 0.45359237, 0}},
+	{[]string{"oz"}, []string{"ounce"}, unit{"mass", 0.028349523125, 0}},
+
+	// Time, in seconds.
+	{[]string{"s", "sec"}, []string{"second"}, unit{"time", 1, 0}},
+	{[]string{"ms"}, []string{"millisecond"}, unit{"time", 1e-3, 0}},
+	{[]string{"min"}, []string{"minute"}, unit{"time", 60, 0}},
+	{[]string{"h", "hr"}, []string{"hour"}, unit{"time", 3600, 0}},
+	{nil, []string{"day"}, unit{"time", 86400, 0}},
+
+	// Volume, in cubic meters.
+	{[]string{"m³", "m3"}, []string{"cubic meter", "cubic metre"}, unit{"volume", 1, 0}},
+	{[]string{"L", "l"}, []string{"liter", "litre"}, unit{"volume", 1e-3, 0}},
+	{[]string{"mL", "ml"}, []string{"milliliter", "millilitre"}, unit{"volume", 1e-6, 0}},
+	{[]string{"gal"}, []string{"gallon"}, unit{"volume", 0.003785411784, 0}},
+
+	// Speed, in meters per second.
+	{[]string{"m/s"}, []string{"meters per second", "metres per second"}, unit{"speed", 1, 0}},
+	{[]string{"km/h", "kph"}, []string{"kilometers per hour", "kilometres per hour"}, unit{"speed", 1 / 3.6, 0}},
+	{[]string{"mph"}, []string{"miles per hour"}, unit{"speed", 0.44704, 0}},
+
+	// Temperature, in kelvin.
+	{[]string{"K"}, []string{"kelvin"}, unit{"temperature", 1, 0}},
+	{[]string{"°C", "℃"}, []string{"degrees Celsius", "Celsius"}, unit{"temperature", 1, 273.15}},
+	{[]string{"°F", "℉"}, []string{"degrees Fahrenheit", "Fahrenheit"}, unit{"temperature", 5.0 / 9, 459.67}},
+
+	// Pressure, in pascals.
+	{[]string{"Pa"}, []string{"pascal"}, unit{"pressure", 1, 0}},
+	{[]string{"kPa"}, []string{"kilopascal"}, unit{"pressure", 1e3, 0}},
+	{[]string{"bar"}, nil, unit{"pressure", 1e5, 0}},
+	{[]string{"atm"}, []string{"atmosphere"}, unit{"pressure", 101325, 0}},
+	{[]string{"psi"}, nil, unit{"pressure", 6894.757293168, 0}},
+
+	// Energy, in joules.
+	{[]string{"J"}, []string{"joule"}, unit{"energy", 1, 0}},
+	{[]string{"kJ"}, []string{"kilojoule"}, unit{"energy", 1e3, 0}},
+	{[]string{"cal"}, []string{"calorie"}, unit{"energy", 4.184, 0}},
+	{[]string{"kcal"}, []string{"kilocalorie"}, unit{"energy", 4184, 0}},
+	{[]string{"Wh"}, []string{"watt-hour", "watt hour"}, unit{"energy", 3600, 0}},
+	{[]string{"kWh"}, []string{"kilowatt-hour", "kilowatt hour"}, unit{"energy", 3.6e6, 0}},
+
+	// Power, in watts.
+	{[]string{"W"}, []string{"watt"}, unit{"power", 1, 0}},
+	{[]string{"kW"}, []string{"kilowatt"}, unit{"power", 1e3, 0}},
+	{[]string{"MW"}, []string{"megawatt"}, unit{"power", 1e6, 0}},
+	{[]string{"hp"}, []string{"horsepower"}, unit{"power", 745.69987158227, 0}},
+
+	// Frequency, in hertz.
+	{[]string{"Hz"}, []string{"hertz"}, unit{"frequency", 1, 0}},
+	{[]string{"kHz"}, []string{"kilohertz"}, unit{"frequency", 1e3, 0}},
+	{[]string{"MHz"}, []string{"megahertz"}, unit{"frequency", 1e6, 0}},
+	{[]string{"GHz"}, []string{"gigahertz"}, unit{"frequency", 1e9, 0}},
+}
+
+var (
+	unitPattern *regexp.Regexp
+	unitsByName = make(map[string]unit)
+)
+
+func init() {
+	var alts []string
+	for _, d := range unitDefs {
+		for _, s := range d.symbols {
+			unitsByName[s] = d.unit
+			alts = append(alts, regexp.QuoteMeta(s))
+		}
+		for _, n := range d.names {
+			n = strings.ToLower(n)
+			unitsByName[n] = d.unit
+			unitsByName[n+"s"] = d.unit
+			alts = append(alts, "(?i:"+regexp.QuoteMeta(n)+"s?)")
+		}
+	}
+	// Prefer the longest unit, so "km/h" wins over "km" and "ms" over "m".
+	slices.SortStableFunc(alts, func(a, b string) int { return cmp.Compare(len(b), len(a)) })
+	unitPattern = regexp.MustCompile(`^\s?(` + strings.Join(alts, "|") + `)`)
+}
+
+// quantity is a value converted to the SI base unit of its dimension.
+type quantity struct {
+	value float64
+	unit  unit
+}
+
+// parseQuantities returns the quantities in s: numbers directly followed
+// by a known unit.
+func parseQuantities(s string) []quantity {
+	var out []quantity
+	for _, loc := range numberPattern.FindAllStringIndex(s, -1) {
+		rest := s[loc[1]:]
+		m := unitPattern.FindStringSubmatch(rest)
+		if m == nil {
+			continue
+		}
+		// The unit must end at a word boundary: "5 min" is a quantity,
+		// "5 minutes" too, but "5 mice" is not.
+		if r, _ := utf8.DecodeRuneInString(rest[len(m[0]):]); unicode.IsLetter(r) {
+			continue
+		}
+		u, ok := unitsByName[m[1]]
+		if !ok {
+			u, ok = unitsByName[strings.ToLower(m[1])]
+		}
+		v, err := strconv.ParseFloat(s[loc[0]:loc[1]], 64)
+		if !ok || err != nil {
+			continue
+		}
+		out = append(out, quantity{value: (v + u.offset) * u.factor, unit: u})
+	}
+	return out
+}
+
+// matchQuantity passes when the response states the quantity in Expected,
+// in any unit of the same dimension, within Tolerance expressed in
+// Expected's unit. Without a tolerance, values must agree to one part in
+// a billion.
+func matchQuantity(t *Task, response string) (bool, float64) {
+	want := parseQuantities(t.Expected)
+	if len(want) == 0 {
+		return false, 0.0
+	}
+	w := want[0]
+	tol := t.Tolerance * w.unit.factor
+	if tol == 0 {
+		tol = 1e-9 * math.Abs(w.value)
+	}
+	for _, g := range parseQuantities(response) {
+		if g.unit.dim == w.unit.dim && math.Abs(g.value-w.value) <= tol {
+			return true, 1.0
+		}
+	}
+	return false, 0.0
+}
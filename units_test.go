@@ -0,0 +1,30 @@
+package matchspec
+
+import "testing"
+
+func TestTaskMatchQuantity(t *testing.T) {
+	tests := []struct {
+		expected  string
+		tolerance float64
+		response  string
+		want      bool
+	}{
+		{"1.5 km", 0, "The trail is 1500 m long.", true},
+		{"1.5 km", 0, "The trail is 1.5 kilometers long.", true},
+		{"1.5 km", 0, "The trail is 1400 m long.", false},
+		{"1.5 km", 0.1, "The trail is 1400 m long.", true},
+		{"1.5 km", 0, "It takes 1500 ms.", false},
+		{"100 °C", 0, "Water boils at 212 °F.", true},
+		{"100 °C", 0, "Water boils at 373.15 K.", true},
+		{"60 mph", 0.5, "About 96.56 km/h.", true},
+		{"2 h", 0, "That took 120 minutes.", true},
+		{"5 g", 0, "I saw 5 geese.", false},
+		{"12 in", 0, "It is a foot long.", false},
+	}
+	for _, tt := range tests {
+		task := Task{Name: "t", Prompt: "p", Matcher: "quantity", Expected: tt.expected, Tolerance: tt.tolerance}
+		if got, _ := task.Match(tt.response); got != tt.want {
+			t.Errorf("Match(%q) with Expected %q ±%g = %v, want %v", tt.response, tt.expected, tt.tolerance, got, tt.want)
+		}
+	}
+}
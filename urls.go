@@ -0,0 +1,89 @@
+package matchspec
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"'\]\[()]+`)
+
+// ExtractURLs returns the http and https URLs in s, in order, without
+// trailing punctuation.
+func ExtractURLs(s string) []string {
+	found := urlPattern.FindAllString(s, -1)
+	for i, u := range found {
+		found[i] = strings.TrimRight(u, ".,;:!?")
+	}
+	return found
+}
+
+// urlAllowed reports whether u matches an entry of allowed. An entry with a
+// scheme matches itself and, if it ends in "/", any URL below it; an entry
+// without a scheme is a host and matches that host and its subdomains.
+func urlAllowed(u string, allowed []string) bool {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for _, a := range allowed {
+		if !strings.Contains(a, "://") {
+			a = strings.ToLower(a)
+			if host == a || strings.HasSuffix(host, "."+a) {
+				return true
+			}
+			continue
+		}
+		if u == a || (strings.HasSuffix(a, "/") && strings.HasPrefix(u, a)) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchURLs passes when the response contains at least one URL and every
+// URL is in the task's AllowedURLs. If check is non-nil, allowed URLs must
+// also pass it. The score is the fraction of URLs that are valid.
+func matchURLs(t *Task, response string, check func(string) bool) (bool, float64) {
+	found := ExtractURLs(response)
+	if len(found) == 0 {
+		return false, 0.0
+	}
+	valid := 0
+	for _, u := range found {
+		if urlAllowed(u, t.AllowedURLs) && (check == nil || check(u)) {
+			valid++
+		}
+	}
+	return valid == len(found), float64(valid) / float64(len(found))
+}
+
+// WithURLCheck makes the "urls" matcher also send a HEAD request to every
+// allowed URL and count it as invalid unless it responds with a status
+// below 400. A nil client uses one with a 10 second timeout.
+func WithURLCheck(client *http.Client) RunnerOption {
+	return func(r *Runner) {
+		if client == nil {
+			client = &http.Client{Timeout: 10 * time.Second}
+		}
+		r.urlClient = client
+	}
+}
+
+// urlResolves reports whether a HEAD request to u succeeds.
+func (r *Runner) urlResolves(ctx context.Context, u string) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := r.urlClient.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode < 400
+}
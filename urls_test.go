@@ -0,0 +1,64 @@
+package matchspec
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestExtractURLs(t *testing.T) {
+	got := ExtractURLs("See https://go.dev/doc/. Also [spec](https://go.dev/ref/spec), or http://x.io?q=1!")
+	want := []string{"https://go.dev/doc/", "https://go.dev/ref/spec", "http://x.io?q=1"}
+	if !slices.Equal(got, want) {
+		t.Errorf("ExtractURLs = %v, want %v", got, want)
+	}
+}
+
+func TestTaskMatchURLs(t *testing.T) {
+	task := Task{Name: "t", Prompt: "p", Matcher: "urls", AllowedURLs: []string{"https://docs.example.com/guide/", "example.org"}}
+	tests := []struct {
+		response  string
+		wantPass  bool
+		wantScore float64
+	}{
+		{"See https://docs.example.com/guide/setup.", true, 1},
+		{"See https://api.example.org/v1 and https://example.org.", true, 1},
+		{"See https://docs.example.com/blog and https://example.org", false, 0.5},
+		{"See https://evil-example.org/", false, 0},
+		{"No links here.", false, 0},
+	}
+	for _, tt := range tests {
+		passed, score := task.Match(tt.response)
+		if passed != tt.wantPass || score != tt.wantScore {
+			t.Errorf("Match(%q) = %v, %f; want %v, %f", tt.response, passed, score, tt.wantPass, tt.wantScore)
+		}
+	}
+}
+
+func TestRunnerURLCheck(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead || r.URL.Path != "/ok" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "links", Tasks: []Task{
+		{Name: "ok", Prompt: srv.URL + "/ok", Matcher: "urls", AllowedURLs: []string{srv.URL + "/"}},
+		{Name: "dead", Prompt: srv.URL + "/dead", Matcher: "urls", AllowedURLs: []string{srv.URL + "/"}},
+	}})
+	runner := NewRunner(reg, echoInfer, tokentrace.NewReporter("matchspec", ""), WithURLCheck(srv.Client()))
+	results, err := runner.Run(context.Background(), protocol.EvalRun{Suite: "links"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !results[0].Passed || results[1].Passed {
+		t.Errorf("results = %+v", results)
+	}
+}
@@ -0,0 +1,162 @@
+package matchspec
+
+import (
+	"fmt"
+	"math"
+)
+
+// BaseVariant names the results of a task's own Prompt when the task also
+// declares Variants.
+const BaseVariant = "base"
+
+// SignificanceLevel is the p-value below which a variant's pass rate is
+// reported as significantly different from the base prompt.
+const SignificanceLevel = 0.05
+
+// TaskVariant is an alternative prompt for a task. Variants share the task's
+// expected output and matcher, so only the prompt differs.
+type TaskVariant struct {
+	Name   string `json:"name"`
+	Prompt string `json:"prompt"`
+}
+
+// VariantStats compares one prompt variant with the base prompt across the
+// tasks that ran it.
+type VariantStats struct {
+	Variant  string  `json:"variant"`
+	Total    int     `json:"total"`
+	Passed   int     `json:"passed"`
+	PassRate float64 `json:"pass_rate"`
+
+	// Delta is PassRate minus the base prompt's pass rate.
+	Delta float64 `json:"delta"`
+
+	// PValue is from a two-sided two-proportion z-test against the base
+	// prompt. It is 1 for the base variant itself.
+	PValue      float64 `json:"p_value"`
+	Significant bool    `json:"significant"`
+}
+
+func validateVariants(suite string, t Task) error {
+	seen := map[string]bool{BaseVariant: true}
+	for i, v := range t.Variants {
+		if v.Name == "" {
+			return fmt.Errorf("matchspec: suite %q task %q variant[%d] has no name", suite, t.Name, i)
+		}
+		if seen[v.Name] {
+			return fmt.Errorf("matchspec: suite %q task %q has duplicate variant %q", suite, t.Name, v.Name)
+		}
+		seen[v.Name] = true
+		if v.Prompt == "" {
+			return fmt.Errorf("matchspec: suite %q task %q variant %q has no prompt", suite, t.Name, v.Name)
+		}
+	}
+	return nil
+}
+
+// expandVariants replaces each task that declares variants with one copy
+// per prompt: the base prompt first, then each variant in order.
+func expandVariants(tasks []Task) []Task {
+	n := 0
+	for _, t := range tasks {
+		n += 1 + len(t.Variants)
+	}
+	if n == len(tasks) {
+		return tasks
+	}
+	out := make([]Task, 0, n)
+	for _, t := range tasks {
+		if len(t.Variants) == 0 {
+			out = append(out, t)
+			continue
+		}
+		base := t
+		base.variant = BaseVariant
+		out = append(out, base)
+		for _, v := range t.Variants {
+			vt := t
+			vt.Prompt = v.Prompt
+			vt.variant = v.Name
+			out = append(out, vt)
+		}
+	}
+	return out
+}
+
+// CompareVariants groups results by prompt variant and tests each variant's
+// pass rate against the base prompt. Results without a variant are ignored.
+// The base variant is listed first, followed by the others in order of
+// first appearance. It returns nil if no result carries a variant.
+func CompareVariants(results []Result) []VariantStats {
+	var c variantCounter
+	for _, r := range results {
+		c.add(r)
+	}
+	return c.stats()
+}
+
+// variantCounter accumulates CompareVariants one result at a time.
+type variantCounter struct {
+	order  []string
+	byName map[string]*VariantStats
+}
+
+func (c *variantCounter) add(r Result) {
+	if r.Variant == "" {
+		return
+	}
+	vs, ok := c.byName[r.Variant]
+	if !ok {
+		if c.byName == nil {
+			c.byName = make(map[string]*VariantStats)
+		}
+		vs = &VariantStats{Variant: r.Variant}
+		c.byName[r.Variant] = vs
+		if r.Variant == BaseVariant {
+			c.order = append([]string{r.Variant}, c.order...)
+		} else {
+			c.order = append(c.order, r.Variant)
+		}
+	}
+	vs.Total++
+	if r.Passed {
+		vs.Passed++
+	}
+}
+
+func (c *variantCounter) stats() []VariantStats {
+	if len(c.order) == 0 {
+		return nil
+	}
+	base := c.byName[BaseVariant]
+	stats := make([]VariantStats, 0, len(c.order))
+	for _, name := range c.order {
+		vs := *c.byName[name]
+		vs.PassRate = float64(vs.Passed) / float64(vs.Total)
+		vs.PValue = 1
+		if base != nil && name != BaseVariant {
+			vs.Delta = vs.PassRate - float64(base.Passed)/float64(base.Total)
+			vs.PValue = twoProportionPValue(base.Passed, base.Total, vs.Passed, vs.Total)
+			vs.Significant = vs.PValue < SignificanceLevel
+		}
+		stats = append(stats, vs)
+	}
+	return stats
+}
+
+// twoProportionPValue returns the two-sided p-value of a pooled
+// two-proportion z-test for x1/n1 versus x2/n2.
+func twoProportionPValue(x1, n1, x2, n2 int) float64 {
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+	p1 := float64(x1) / float64(n1)
+	p2 := float64(x2) / float64(n2)
+	pooled := float64(x1+x2) / float64(n1+n2)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/float64(n1) + 1/float64(n2)))
+	if se == 0 {
+		return 1
+	}
+	z := (p1 - p2) / se
+	return math.Erfc(math.Abs(z) / math.Sqrt2)
+}
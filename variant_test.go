@@ -0,0 +1,111 @@
+package matchspec
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+)
+
+func TestRunVariants(t *testing.T) {
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{
+		Name: "ab",
+		Tasks: []Task{
+			{Name: "t", Prompt: "plain", Expected: "ok", Variants: []TaskVariant{
+				{Name: "polite", Prompt: "please"},
+			}},
+			{Name: "u", Prompt: "plain", Expected: "ok"},
+		},
+	})
+	infer := func(_ context.Context, prompt string) (string, error) {
+		if strings.Contains(prompt, "please") {
+			return "ok", nil
+		}
+		return "no", nil
+	}
+	r := NewRunner(reg, infer, tokentrace.NewReporter("matchspec", ""))
+
+	results, err := r.RunDetailed(context.Background(), protocol.EvalRun{Suite: "ab"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("results = %d, want 3", len(results))
+	}
+	want := []struct {
+		task, variant string
+		passed        bool
+	}{{"t", BaseVariant, false}, {"t", "polite", true}, {"u", "", false}}
+	for i, w := range want {
+		if results[i].Task != w.task || results[i].Variant != w.variant || results[i].Passed != w.passed {
+			t.Errorf("result %d = %s/%s passed=%v, want %s/%s passed=%v",
+				i, results[i].Task, results[i].Variant, results[i].Passed, w.task, w.variant, w.passed)
+		}
+	}
+}
+
+func TestCompareVariants(t *testing.T) {
+	var results []Result
+	add := func(variant string, passed, total int) {
+		for i := 0; i < total; i++ {
+			results = append(results, Result{EvalResult: protocol.EvalResult{Passed: i < passed}, Variant: variant})
+		}
+	}
+	add("short", 45, 50)
+	add(BaseVariant, 25, 50)
+	add("", 0, 10)
+
+	stats := CompareVariants(results)
+	if len(stats) != 2 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	base, short := stats[0], stats[1]
+	if base.Variant != BaseVariant || base.PassRate != 0.5 || base.PValue != 1 || base.Significant {
+		t.Errorf("base = %+v", base)
+	}
+	if short.Variant != "short" || short.PassRate != 0.9 || math.Abs(short.Delta-0.4) > 1e-9 {
+		t.Errorf("short = %+v", short)
+	}
+	if !short.Significant || short.PValue >= 0.001 {
+		t.Errorf("short p-value = %f, want significant", short.PValue)
+	}
+
+	if CompareVariants([]Result{{}}) != nil {
+		t.Error("expected nil without variants")
+	}
+}
+
+func TestCompareVariantsNotSignificant(t *testing.T) {
+	results := []Result{
+		{EvalResult: protocol.EvalResult{Passed: true}, Variant: BaseVariant},
+		{EvalResult: protocol.EvalResult{Passed: false}, Variant: BaseVariant},
+		{EvalResult: protocol.EvalResult{Passed: true}, Variant: "b"},
+		{EvalResult: protocol.EvalResult{Passed: true}, Variant: "b"},
+	}
+	stats := CompareVariants(results)
+	if stats[1].Significant {
+		t.Errorf("2 samples should not be significant: %+v", stats[1])
+	}
+}
+
+func TestValidateVariants(t *testing.T) {
+	cases := []struct {
+		name     string
+		variants []TaskVariant
+	}{
+		{"no name", []TaskVariant{{Prompt: "p"}}},
+		{"no prompt", []TaskVariant{{Name: "v"}}},
+		{"duplicate", []TaskVariant{{Name: "v", Prompt: "p"}, {Name: "v", Prompt: "q"}}},
+		{"reserved", []TaskVariant{{Name: BaseVariant, Prompt: "p"}}},
+	}
+	for _, c := range cases {
+		s := &Suite{Name: "s", Tasks: []Task{{Name: "t", Prompt: "p", Variants: c.variants}}}
+		if err := s.Validate(); err == nil {
+			t.Errorf("%s: expected validation error", c.name)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package matchspec
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+type varsKey struct{}
+
+// WithVars sets template variables for every suite the runner executes,
+// typically an environment loaded with LoadEnv. They override the suite's
+// own Vars. Task prompts, expected outputs, documents, and fixtures are
+// rendered as text/templates with the merged variables, and generators
+// such as TemplateGenerator receive them beneath their row variables.
+func WithVars(vars map[string]any) RunnerOption {
+	return func(r *Runner) {
+		r.vars = vars
+	}
+}
+
+// LoadVarsFile reads template variables from a .yaml, .yml, or .json
+// file holding a single mapping.
+func LoadVarsFile(path string) (map[string]any, error) {
+	var vars map[string]any
+	if err := loadConfigFile(path, "variables", &vars); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// LoadEnv reads the variables of environment name, such as "staging",
+// from name.yaml, name.yml, or name.json in dir.
+func LoadEnv(dir, name string) (map[string]any, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return nil, fmt.Errorf("matchspec: invalid environment name %q", name)
+	}
+	for _, ext := range []string{".yaml", ".yml", ".json"} {
+		path := filepath.Join(dir, name+ext)
+		if _, err := os.Stat(path); err == nil {
+			return LoadVarsFile(path)
+		}
+	}
+	return nil, fmt.Errorf("matchspec: no variables file for environment %q in %s", name, dir)
+}
+
+// suiteVars merges the suite's variables with the runner's variables and
+// secrets, in increasing precedence.
+func (r *Runner) suiteVars(s *Suite) map[string]any {
+	if len(s.Vars) == 0 && len(r.secrets) == 0 {
+		return r.vars
+	}
+	vars := make(map[string]any, len(s.Vars)+len(r.vars)+len(r.secrets))
+	maps.Copy(vars, s.Vars)
+	maps.Copy(vars, r.vars)
+	for k, v := range r.secrets {
+		vars[k] = v
+	}
+	return vars
+}
+
+// withVars returns a context carrying template variables for generators.
+func withVars(ctx context.Context, vars map[string]any) context.Context {
+	if len(vars) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, varsKey{}, vars)
+}
+
+// varsFrom returns the template variables carried by ctx, if any.
+func varsFrom(ctx context.Context) map[string]any {
+	vars, _ := ctx.Value(varsKey{}).(map[string]any)
+	return vars
+}
+
+// renderVars renders s as a template with vars. Text without template
+// actions is returned unchanged.
+func renderVars(s string, vars map[string]any) (string, error) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+	tmpl, err := template.New("").Option("missingkey=error").Funcs(templateFuncs(newRand(1), "")).Parse(s)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, vars); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// expandTask renders the task's text fields and headers with vars.
+func expandTask(suite string, t Task, vars map[string]any) (Task, error) {
+	fields := []*string{&t.Prompt, &t.Expected, &t.Fixture}
+	t.Documents = append([]string(nil), t.Documents...)
+	for i := range t.Documents {
+		fields = append(fields, &t.Documents[i])
+	}
+	t.ExpectedAny = append([]string(nil), t.ExpectedAny...)
+	for i := range t.ExpectedAny {
+		fields = append(fields, &t.ExpectedAny[i])
+	}
+	t.Assertions = append([]Assertion(nil), t.Assertions...)
+	for i := range t.Assertions {
+		fields = append(fields, &t.Assertions[i].Expected)
+	}
+	for _, f := range fields {
+		out, err := renderVars(*f, vars)
+		if err != nil {
+			return t, fmt.Errorf("matchspec: suite %q task %q: %w", suite, t.Name, err)
+		}
+		*f = out
+	}
+	return renderHeaders(suite, t, vars)
+}
+
+// renderHeaders renders the task's header values with vars.
+func renderHeaders(suite string, t Task, vars map[string]any) (Task, error) {
+	if len(t.Headers) == 0 {
+		return t, nil
+	}
+	headers := make(map[string]string, len(t.Headers))
+	for name, v := range t.Headers {
+		out, err := renderVars(v, vars)
+		if err != nil {
+			return t, fmt.Errorf("matchspec: suite %q task %q header %q: %w", suite, t.Name, name, err)
+		}
+		headers[name] = out
+	}
+	t.Headers = headers
+	return t, nil
+}
+
+// varsCacheKey folds the runner's variables into a run cache key, so runs
+// against different environments are cached apart.
+func (r *Runner) varsCacheKey(key string) string {
+	if key == "" || len(r.vars)+len(r.secrets) == 0 {
+		return key
+	}
+	// Secrets contribute by name only, so cache files do not depend on
+	// (or leak) their values.
+	data, _ := json.Marshal(struct {
+		Vars    map[string]any `json:"vars"`
+		Secrets []string       `json:"secrets"`
+	}{r.vars, slices.Sorted(maps.Keys(r.secrets))})
+	sum := sha256.Sum256(append([]byte(key+"\x00"), data...))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,97 @@
+package matchspec
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/greynewell/mist-go/protocol"
+	"github.com/greynewell/mist-go/tokentrace"
+	"github.com/greynewell/mist-go/trace"
+)
+
+func TestLoadEnv(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "staging.yaml"), []byte("tenant: acme-staging\nregion: eu\nretries: 3\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "prod.json"), []byte(`{"tenant": "acme"}`), 0o644)
+
+	vars, err := LoadEnv(dir, "staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars["tenant"] != "acme-staging" || vars["region"] != "eu" || vars["retries"] != float64(3) {
+		t.Errorf("staging vars = %v", vars)
+	}
+	if vars, err := LoadEnv(dir, "prod"); err != nil || vars["tenant"] != "acme" {
+		t.Errorf("prod vars = %v, %v", vars, err)
+	}
+	if _, err := LoadEnv(dir, "dev"); err == nil || !strings.Contains(err.Error(), `environment "dev"`) {
+		t.Errorf("missing env err = %v", err)
+	}
+	if _, err := LoadEnv(dir, "../staging"); err == nil {
+		t.Error("path in environment name accepted")
+	}
+}
+
+func TestRunnerVars(t *testing.T) {
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{
+		Name: "tenant",
+		Vars: map[string]any{"tenant": "default", "region": "us"},
+		Tasks: []Task{
+			{Name: "t", Prompt: "Tenant {{.tenant}} in {{upper .region}}", Expected: "{{.tenant}}"},
+			{Name: "plain", Prompt: "no templates", Expected: "no"},
+		},
+		Generator: NewTemplateGenerator("g", "{{.tenant}}/{{.id}}", "{{.id}}", []map[string]string{{"id": "7"}}, 1),
+	})
+
+	run := func(opts ...RunnerOption) []Result {
+		runner := NewRunner(reg, echoInfer, tokentrace.NewReporter("matchspec", ""), opts...)
+		results, err := runner.RunDetailed(context.Background(), protocol.EvalRun{Suite: "tenant"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return results
+	}
+
+	// Suite defaults apply without an environment; the runner's override
+	// them in tasks and generators alike.
+	for _, tt := range []struct {
+		opts   []RunnerOption
+		tenant string
+	}{
+		{nil, "default"},
+		{[]RunnerOption{WithVars(map[string]any{"tenant": "acme"})}, "acme"},
+	} {
+		for _, res := range run(tt.opts...) {
+			if !res.Passed {
+				t.Errorf("tenant %s: task %s failed: %+v", tt.tenant, res.Task, res)
+			}
+		}
+	}
+
+	runner := NewRunner(reg, echoInfer, tokentrace.NewReporter("matchspec", ""), WithVars(map[string]any{"tenant": "acme"}))
+	s, _ := reg.Get("tenant")
+	_, span := trace.Start(context.Background(), "test")
+	tasks, err := runner.suiteTasks(context.Background(), s, span)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tasks[0].Prompt != "Tenant acme in US" || tasks[2].Prompt != "acme/7" {
+		t.Errorf("prompts = %q, %q", tasks[0].Prompt, tasks[2].Prompt)
+	}
+	if s.Tasks[0].Prompt != "Tenant {{.tenant}} in {{upper .region}}" {
+		t.Error("rendering modified the registered suite")
+	}
+}
+
+func TestRunnerVarsMissingKey(t *testing.T) {
+	reg := NewSuiteRegistry()
+	reg.Register(&Suite{Name: "s", Tasks: []Task{{Name: "t", Prompt: "{{.nope}}", Expected: "x"}}, Vars: map[string]any{"a": 1}})
+	runner := NewRunner(reg, echoInfer, tokentrace.NewReporter("matchspec", ""))
+	if _, err := runner.Run(context.Background(), protocol.EvalRun{Suite: "s"}); err == nil || !strings.Contains(err.Error(), `task "t"`) {
+		t.Errorf("err = %v, want missing variable error", err)
+	}
+}
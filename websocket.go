@@ -0,0 +1,289 @@
+package matchspec
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebSocket opcodes and close codes (RFC 6455).
+const (
+	wsText  = 0x1
+	wsClose = 0x8
+	wsPing  = 0x9
+	wsPong  = 0xA
+
+	wsCloseNormal    = 1000
+	wsCloseGoingAway = 1001
+	wsCloseProtocol  = 1002
+	wsClosePolicy    = 1008
+	wsCloseTooBig    = 1009
+
+	wsMaxClientMessage = 1 << 16
+
+	// wsMaxControlPayload is the largest payload of a close, ping, or
+	// pong frame.
+	wsMaxControlPayload = 125
+
+	wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	// wsPingInterval is how often the server pings clients, so proxies do
+	// not close idle connections.
+	wsPingInterval = 30 * time.Second
+)
+
+// ServeHTTP handles GET /ws — upgrades the request to a WebSocket and
+// sends each event to the client as a JSON text message, until the client
+// closes the connection or falls behind. Supports ?suite= and ?type=
+// (both repeatable) to receive only some events. Messages from the client
+// other than pings and close are ignored. Browsers on other origins are
+// refused unless allowed with AllowOrigins.
+func (h *EventHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	suites, types := q["suite"], q["type"]
+	match := func(e Event) bool {
+		return (len(suites) == 0 || slices.Contains(suites, e.Suite)) &&
+			(len(types) == 0 || slices.Contains(types, e.Type))
+	}
+
+	h.mu.Lock()
+	origins := h.origins
+	h.mu.Unlock()
+	if !wsOriginAllowed(r, origins) {
+		http.Error(w, "WebSocket origin not allowed", http.StatusForbidden)
+		return
+	}
+	conn, buf, err := wsUpgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := h.Subscribe(ctx, match)
+	ws := &wsConn{conn: conn}
+
+	// The reader answers pings and notices the client going away.
+	closed := make(chan int, 1)
+	go func() {
+		closed <- ws.readLoop(buf.Reader)
+	}()
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				ws.close(wsClosePolicy, "client too slow")
+				return
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			if ws.write(wsText, data) != nil {
+				return
+			}
+		case <-ping.C:
+			if ws.write(wsPing, nil) != nil {
+				return
+			}
+		case code := <-closed:
+			ws.close(code, "")
+			return
+		}
+	}
+}
+
+// wsUpgrade completes the WebSocket opening handshake and hijacks the
+// connection. On failure it has already written an error response.
+func wsUpgrade(w http.ResponseWriter, r *http.Request) (net.Conn, *bufio.ReadWriter, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, "Upgrade", "websocket") || key == "" {
+		http.Error(w, "expected a WebSocket upgrade", http.StatusBadRequest)
+		return nil, nil, errors.New("not a websocket request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, nil, errors.New("unsupported websocket version")
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket not supported", http.StatusInternalServerError)
+		return nil, nil, errors.New("connection cannot be hijacked")
+	}
+	conn, buf, err := hj.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	sum := sha1.Sum([]byte(key + wsGUID))
+	fmt.Fprintf(buf, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(sum[:]))
+	if err := buf.Flush(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, buf, nil
+}
+
+// wsOriginAllowed reports whether r may open a WebSocket: it has no
+// Origin header, as non-browser clients do not, or its origin is on the
+// request's host or among allowed. Browsers do not apply the same-origin
+// policy to WebSockets, so without this check any page a user visits
+// could read the stream with the user's network access.
+func wsOriginAllowed(r *http.Request, allowed []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, a := range allowed {
+		if a == "*" || strings.EqualFold(strings.TrimRight(strings.TrimSpace(a), "/"), origin) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host != "" && strings.EqualFold(u.Host, r.Host)
+}
+
+// headerHasToken reports whether the comma-separated header name contains
+// token, ignoring case.
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wsConn writes WebSocket frames from the server, which are never
+// fragmented or masked.
+type wsConn struct {
+	conn net.Conn
+	mu   sync.Mutex
+}
+
+func (c *wsConn) write(opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := c.conn.Write(append(header, payload...))
+	return err
+}
+
+// close sends a close frame with code and reason.
+func (c *wsConn) close(code int, reason string) {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	c.write(wsClose, append(payload, reason...))
+}
+
+// readLoop reads client frames until the connection fails or the client
+// sends close, answering pings, and returns the close code to reply with.
+func (c *wsConn) readLoop(r io.Reader) int {
+	for {
+		opcode, payload, err := wsReadFrame(r)
+		switch {
+		case errors.Is(err, errWSTooBig):
+			return wsCloseTooBig
+		case errors.Is(err, errWSProtocol):
+			return wsCloseProtocol
+		case err != nil:
+			return wsCloseGoingAway
+		}
+		switch opcode {
+		case wsClose:
+			return wsCloseNormal
+		case wsPing:
+			if c.write(wsPong, payload) != nil {
+				return wsCloseGoingAway
+			}
+		}
+	}
+}
+
+var (
+	errWSTooBig   = errors.New("websocket message too big")
+	errWSProtocol = errors.New("websocket protocol error")
+)
+
+// wsReadFrame reads one client frame and unmasks its payload. Control
+// frames must be unfragmented and carry at most 125 bytes; others fail
+// with errWSProtocol before their payload is read.
+func wsReadFrame(r io.Reader) (opcode byte, payload []byte, err error) {
+	var h [2]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode = h[0] & 0x0F
+	control := opcode&0x8 != 0
+	if control && h[0]&0x80 == 0 {
+		return 0, nil, errWSProtocol
+	}
+	masked := h[1]&0x80 != 0
+	n := uint64(h[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if control && n > wsMaxControlPayload {
+		return 0, nil, errWSProtocol
+	}
+	if n > wsMaxClientMessage {
+		return 0, nil, errWSTooBig
+	}
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(r, mask[:]); err != nil {
+			return 0, nil, err
+		}
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return opcode, payload, nil
+}
@@ -0,0 +1,539 @@
+package matchspec
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// decodeYAML parses a YAML document and decodes it into v using v's JSON
+// field tags, so suite and campaign types need only one set of tags.
+// Plain scalars are typed by the field they decode into: a string field
+// takes `expected: 4` as "4", while an int field takes it as 4.
+//
+// The supported subset covers configuration files: block mappings and
+// sequences, plain and quoted scalars, flow sequences and mappings, literal
+// (|) and folded (>) block scalars, and comments. Anchors, aliases, tags,
+// and multi-document streams are not supported.
+func decodeYAML(data []byte, v any) error {
+	tree, err := parseYAMLTree(data)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(resolveYAML(tree, reflect.TypeOf(v)))
+	if err != nil {
+		return fmt.Errorf("yaml: %w", err)
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// loadConfigFile decodes a .yaml, .yml, or .json file into v. kind names
+// the file's role in error messages.
+func loadConfigFile(path, kind string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("matchspec: %w", err)
+	}
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = decodeYAML(data, v)
+	case ".json":
+		err = json.Unmarshal(data, v)
+	default:
+		return fmt.Errorf("matchspec: %s: unsupported %s file type", path, kind)
+	}
+	if err != nil {
+		return fmt.Errorf("matchspec: %s: %w", path, err)
+	}
+	return nil
+}
+
+type yamlLine struct {
+	num    int // 1-based line number
+	indent int
+	text   string // content after indentation, comments stripped
+	raw    string // original line, for block scalars
+	blank  bool
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// parseYAML parses a YAML document, typing plain scalars as YAML does
+// when there is no target to go by.
+func parseYAML(data []byte) (any, error) {
+	tree, err := parseYAMLTree(data)
+	if err != nil {
+		return nil, err
+	}
+	return resolveYAML(tree, nil), nil
+}
+
+// yamlPlain is an unquoted scalar, whose type depends on where it is
+// decoded (see resolveYAML).
+type yamlPlain string
+
+// parseYAMLTree parses a YAML document, leaving plain scalars as
+// yamlPlain.
+func parseYAMLTree(data []byte) (any, error) {
+	p := &yamlParser{}
+	for i, raw := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		if lead := raw[:len(raw)-len(strings.TrimLeft(raw, " \t"))]; strings.ContainsRune(lead, '\t') {
+			return nil, fmt.Errorf("yaml: line %d: tabs are not allowed for indentation", i+1)
+		}
+		trimmed := strings.TrimLeft(raw, " ")
+		text := strings.TrimRight(stripYAMLComment(trimmed), " ")
+		p.lines = append(p.lines, yamlLine{
+			num:    i + 1,
+			indent: len(raw) - len(trimmed),
+			text:   text,
+			raw:    raw,
+			blank:  text == "" || text == "---",
+		})
+	}
+
+	p.skipBlank()
+	if p.eof() {
+		return nil, nil
+	}
+	node, err := p.parseBlock(0)
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlank()
+	if !p.eof() {
+		l := p.lines[p.pos]
+		return nil, fmt.Errorf("yaml: line %d: unexpected content %q", l.num, l.text)
+	}
+	return node, nil
+}
+
+func (p *yamlParser) eof() bool { return p.pos >= len(p.lines) }
+
+func (p *yamlParser) skipBlank() {
+	for !p.eof() && p.lines[p.pos].blank {
+		p.pos++
+	}
+}
+
+// parseBlock parses the node starting at the current line, which must be
+// indented at least minIndent.
+func (p *yamlParser) parseBlock(minIndent int) (any, error) {
+	p.skipBlank()
+	if p.eof() || p.lines[p.pos].indent < minIndent {
+		return nil, nil
+	}
+	l := p.lines[p.pos]
+	if isSeqItem(l.text) {
+		return p.parseSeq(l.indent)
+	}
+	if _, _, ok := splitYAMLKey(l.text); ok {
+		return p.parseMap(l.indent)
+	}
+	p.pos++
+	return parseYAMLScalar(l.text, l.num)
+}
+
+func isSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *yamlParser) parseSeq(indent int) ([]any, error) {
+	seq := []any{}
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return seq, nil
+		}
+		l := p.lines[p.pos]
+		if l.indent != indent || !isSeqItem(l.text) {
+			if l.indent > indent {
+				return nil, fmt.Errorf("yaml: line %d: bad indentation", l.num)
+			}
+			return seq, nil
+		}
+
+		rest := strings.TrimLeft(l.text[1:], " ")
+		var item any
+		var err error
+		switch {
+		case rest == "":
+			p.pos++
+			item, err = p.parseBlock(indent + 1)
+		case isBlockScalar(rest):
+			p.pos++
+			item, err = p.parseBlockScalar(indent, rest)
+		case isSeqItem(rest) || hasYAMLKey(rest):
+			// Nested node on the same line as the dash: re-read the line
+			// with the dash replaced by indentation.
+			p.lines[p.pos].indent = indent + len(l.text) - len(rest)
+			p.lines[p.pos].text = rest
+			item, err = p.parseBlock(p.lines[p.pos].indent)
+		default:
+			p.pos++
+			item, err = parseYAMLScalar(rest, l.num)
+		}
+		if err != nil {
+			return nil, err
+		}
+		seq = append(seq, item)
+	}
+}
+
+func hasYAMLKey(text string) bool {
+	_, _, ok := splitYAMLKey(text)
+	return ok
+}
+
+func (p *yamlParser) parseMap(indent int) (map[string]any, error) {
+	m := make(map[string]any)
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return m, nil
+		}
+		l := p.lines[p.pos]
+		if l.indent < indent {
+			return m, nil
+		}
+		if l.indent > indent {
+			return nil, fmt.Errorf("yaml: line %d: bad indentation", l.num)
+		}
+		if isSeqItem(l.text) {
+			return m, nil
+		}
+		key, val, ok := splitYAMLKey(l.text)
+		if !ok {
+			return nil, fmt.Errorf("yaml: line %d: expected key: value, got %q", l.num, l.text)
+		}
+		if _, dup := m[key]; dup {
+			return nil, fmt.Errorf("yaml: line %d: duplicate key %q", l.num, key)
+		}
+		p.pos++
+
+		var node any
+		var err error
+		switch {
+		case val == "":
+			p.skipBlank()
+			if !p.eof() && p.lines[p.pos].indent == indent && isSeqItem(p.lines[p.pos].text) {
+				node, err = p.parseSeq(indent)
+			} else {
+				node, err = p.parseBlock(indent + 1)
+			}
+		case isBlockScalar(val):
+			node, err = p.parseBlockScalar(indent, val)
+		default:
+			node, err = parseYAMLScalar(val, l.num)
+		}
+		if err != nil {
+			return nil, err
+		}
+		m[key] = node
+	}
+}
+
+func isBlockScalar(s string) bool {
+	switch s {
+	case "|", "|-", "|+", ">", ">-", ">+":
+		return true
+	}
+	return false
+}
+
+// parseBlockScalar reads the lines of a | or > scalar indented deeper than
+// parentIndent.
+func (p *yamlParser) parseBlockScalar(parentIndent int, header string) (string, error) {
+	var lines []string
+	contentIndent := -1
+	for !p.eof() {
+		l := p.lines[p.pos]
+		if strings.TrimSpace(l.raw) == "" {
+			lines = append(lines, "")
+			p.pos++
+			continue
+		}
+		if l.indent <= parentIndent {
+			break
+		}
+		if contentIndent < 0 {
+			contentIndent = l.indent
+		}
+		if l.indent < contentIndent {
+			return "", fmt.Errorf("yaml: line %d: bad indentation in block scalar", l.num)
+		}
+		lines = append(lines, l.raw[contentIndent:])
+		p.pos++
+	}
+
+	// Trailing blank lines belong to chomping, not content.
+	end := len(lines)
+	for end > 0 && lines[end-1] == "" {
+		end--
+	}
+	trailing := len(lines) - end
+	lines = lines[:end]
+
+	var text string
+	if header[0] == '|' {
+		text = strings.Join(lines, "\n")
+	} else {
+		var b strings.Builder
+		for i, line := range lines {
+			switch {
+			case i == 0:
+			case line == "" || lines[i-1] == "":
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(' ')
+			}
+			b.WriteString(line)
+		}
+		text = b.String()
+	}
+
+	switch {
+	case strings.HasSuffix(header, "-"):
+		return text, nil
+	case strings.HasSuffix(header, "+"):
+		return text + strings.Repeat("\n", trailing+1), nil
+	case text == "":
+		return "", nil
+	default:
+		return text + "\n", nil
+	}
+}
+
+// splitYAMLKey splits "key: value" (or "key:") outside quotes and brackets.
+func splitYAMLKey(text string) (key, value string, ok bool) {
+	if text == "" || text[0] == '[' || text[0] == '{' {
+		return "", "", false
+	}
+	inSingle, inDouble := false, false
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '\'' && !inDouble:
+			inSingle = !inSingle
+		case c == '"' && !inSingle:
+			inDouble = !inDouble
+		case c == '\\' && inDouble:
+			i++
+		case c == ':' && !inSingle && !inDouble && (i == len(text)-1 || text[i+1] == ' '):
+			k := strings.TrimSpace(text[:i])
+			if uq, err := unquoteYAML(k); err == nil {
+				k = uq
+			}
+			return k, strings.TrimSpace(text[i+1:]), k != ""
+		}
+	}
+	return "", "", false
+}
+
+// stripYAMLComment removes a trailing "# comment" outside quotes.
+func stripYAMLComment(s string) string {
+	inSingle, inDouble := false, false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\'' && !inDouble:
+			inSingle = !inSingle
+		case c == '"' && !inSingle:
+			inDouble = !inDouble
+		case c == '\\' && inDouble:
+			i++
+		case c == '#' && !inSingle && !inDouble && (i == 0 || s[i-1] == ' '):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+func unquoteYAML(s string) (string, error) {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return strconv.Unquote(s)
+	}
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	return "", fmt.Errorf("not quoted")
+}
+
+func parseYAMLScalar(s string, lineNum int) (any, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	switch s[0] {
+	case '"', '\'':
+		v, err := unquoteYAML(s)
+		if err != nil {
+			return nil, fmt.Errorf("yaml: line %d: invalid quoted string %s", lineNum, s)
+		}
+		return v, nil
+	case '[':
+		if !strings.HasSuffix(s, "]") {
+			return nil, fmt.Errorf("yaml: line %d: unterminated flow sequence", lineNum)
+		}
+		seq := []any{}
+		for _, item := range splitFlow(s[1 : len(s)-1]) {
+			v, err := parseYAMLScalar(item, lineNum)
+			if err != nil {
+				return nil, err
+			}
+			seq = append(seq, v)
+		}
+		return seq, nil
+	case '{':
+		if !strings.HasSuffix(s, "}") {
+			return nil, fmt.Errorf("yaml: line %d: unterminated flow mapping", lineNum)
+		}
+		m := make(map[string]any)
+		for _, item := range splitFlow(s[1 : len(s)-1]) {
+			k, val, ok := splitYAMLKey(item)
+			if !ok {
+				return nil, fmt.Errorf("yaml: line %d: invalid flow mapping entry %q", lineNum, item)
+			}
+			v, err := parseYAMLScalar(val, lineNum)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = v
+		}
+		return m, nil
+	}
+
+	return yamlPlain(s), nil
+}
+
+// typeYAMLScalar types a plain scalar as null, a bool, an integer, a
+// float, or else a string.
+func typeYAMLScalar(s string) any {
+	switch s {
+	case "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return s
+}
+
+// resolveYAML types the plain scalars of a parsed tree for decoding into
+// t, walking the tree and t together. A plain scalar bound for a string
+// keeps its text, unless it is null; elsewhere, including where t is nil
+// or an interface, it is typed by typeYAMLScalar.
+func resolveYAML(node any, t reflect.Type) any {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch n := node.(type) {
+	case yamlPlain:
+		v := typeYAMLScalar(string(n))
+		if v != nil && t != nil && t.Kind() == reflect.String {
+			return string(n)
+		}
+		return v
+	case []any:
+		var elem reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elem = t.Elem()
+		}
+		out := make([]any, len(n))
+		for i, item := range n {
+			out[i] = resolveYAML(item, elem)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(n))
+		for k, v := range n {
+			var field reflect.Type
+			switch {
+			case t == nil:
+			case t.Kind() == reflect.Map:
+				field = t.Elem()
+			case t.Kind() == reflect.Struct:
+				field = jsonFieldType(t, k)
+			}
+			out[k] = resolveYAML(v, field)
+		}
+		return out
+	}
+	return node
+}
+
+// jsonFieldType returns the type of the field of struct t that
+// encoding/json decodes key into, including fields promoted from embedded
+// structs, or nil if there is none.
+func jsonFieldType(t reflect.Type, key string) reflect.Type {
+	var folded reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if found := jsonFieldType(ft, key); found != nil {
+					return found
+				}
+				continue
+			}
+		}
+		if name == "" {
+			name = f.Name
+		}
+		switch {
+		case name == key:
+			return f.Type
+		case folded == nil && strings.EqualFold(name, key):
+			folded = f.Type
+		}
+	}
+	return folded
+}
+
+// splitFlow splits flow collection items on top-level commas.
+func splitFlow(s string) []string {
+	var items []string
+	depth, start := 0, 0
+	inSingle, inDouble := false, false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\'' && !inDouble:
+			inSingle = !inSingle
+		case c == '"' && !inSingle:
+			inDouble = !inDouble
+		case c == '\\' && inDouble:
+			i++
+		case inSingle || inDouble:
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		case c == ',' && depth == 0:
+			items = append(items, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		items = append(items, last)
+	}
+	return items
+}
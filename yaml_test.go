@@ -0,0 +1,121 @@
+package matchspec
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	doc := `
+# campaign
+name: nightly
+enabled: true
+count: 3
+ratio: 0.5
+empty:
+tags: [a, "b c", 1]
+params: {temperature: 0.2, model: 'x''y'}
+entries:
+  - suite: math   # inline comment
+    models:
+      - a
+      - b
+  - suite: "url#frag"
+prompt: |
+  line one
+    indented
+
+  line three
+folded: >-
+  one
+  two
+`
+	got, err := parseYAML([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"name":    "nightly",
+		"enabled": true,
+		"count":   int64(3),
+		"ratio":   0.5,
+		"empty":   nil,
+		"tags":    []any{"a", "b c", int64(1)},
+		"params":  map[string]any{"temperature": 0.2, "model": "x'y"},
+		"entries": []any{
+			map[string]any{"suite": "math", "models": []any{"a", "b"}},
+			map[string]any{"suite": "url#frag"},
+		},
+		"prompt": "line one\n  indented\n\nline three\n",
+		"folded": "one two",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseYAML mismatch:\n got: %#v\nwant: %#v", got, want)
+	}
+}
+
+func TestParseYAMLSequenceAtKeyIndent(t *testing.T) {
+	got, err := parseYAML([]byte("tasks:\n- name: a\n  prompt: p\n- name: b\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks := got.(map[string]any)["tasks"].([]any)
+	if len(tasks) != 2 || tasks[0].(map[string]any)["prompt"] != "p" {
+		t.Errorf("unexpected tasks: %#v", tasks)
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	for _, doc := range []string{
+		"a: 1\n   b: 2\n",
+		"a: 1\na: 2\n",
+		"a: [1, 2\n",
+		"\ta: 1\n",
+	} {
+		if _, err := parseYAML([]byte(doc)); err == nil {
+			t.Errorf("expected error for %q", doc)
+		}
+	}
+}
+
+func TestDecodeYAML(t *testing.T) {
+	var s Suite
+	err := decodeYAML([]byte("name: math\ntasks:\n  - name: add\n    prompt: 1+1\n    expected: \"2\"\n"), &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "math" || len(s.Tasks) != 1 || s.Tasks[0].Expected != "2" {
+		t.Errorf("unexpected suite: %+v", s)
+	}
+}
+
+func TestDecodeYAMLPlainScalarsByField(t *testing.T) {
+	doc := `name: math
+tasks:
+  - name: add
+    prompt: 2+2
+    expected: 4
+  - name: truth
+    prompt: is 1 < 2?
+    expected: true
+  - name: ratio
+    prompt: 1/2
+    expected: 0.50
+    matcher: ~
+min_pass_rate: 0.5
+`
+	var s Suite
+	if err := decodeYAML([]byte(doc), &s); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, task := range s.Tasks {
+		got = append(got, task.Expected)
+	}
+	if !reflect.DeepEqual(got, []string{"4", "true", "0.50"}) || s.Tasks[2].Matcher != "" {
+		t.Errorf("expected = %q, matcher = %q", got, s.Tasks[2].Matcher)
+	}
+	if s.MinPassRate != 0.5 {
+		t.Errorf("min_pass_rate = %v", s.MinPassRate)
+	}
+}